@@ -44,6 +44,13 @@ const (
 	Saml PostAdminSsoProvidersJSONBodyType = "saml"
 )
 
+// Defines values for GetAdminUsersParamsCount.
+const (
+	Estimated GetAdminUsersParamsCount = "estimated"
+	Exact     GetAdminUsersParamsCount = "exact"
+	None      GetAdminUsersParamsCount = "none"
+)
+
 // ErrorSchema defines model for ErrorSchema.
 type ErrorSchema struct {
 	// Code The HTTP status code. Usually missing if `error` is present.
@@ -218,8 +225,62 @@ type PutAdminSsoProvidersSsoProviderIdJSONBody struct {
 type GetAdminUsersParams struct {
 	Page    *int `form:"page,omitempty" json:"page,omitempty"`
 	PerPage *int `form:"per_page,omitempty" json:"per_page,omitempty"`
+
+	// Sort `created_at desc` (the default) or `created_at asc`.
+	Sort *string `form:"sort,omitempty" json:"sort,omitempty"`
+
+	// Filter Substring of the email or full name.
+	Filter *string `form:"filter,omitempty" json:"filter,omitempty"`
+
+	// Email Exact email.
+	Email *string `form:"email,omitempty" json:"email,omitempty"`
+
+	// EmailPrefix Prefix of the email.
+	EmailPrefix *string `form:"email_prefix,omitempty" json:"email_prefix,omitempty"`
+
+	// Phone Exact phone number.
+	Phone *string `form:"phone,omitempty" json:"phone,omitempty"`
+
+	// PhonePrefix Prefix of the phone number.
+	PhonePrefix *string `form:"phone_prefix,omitempty" json:"phone_prefix,omitempty"`
+
+	// Provider Provider of an identity of the users, such as `google`.
+	Provider *string `form:"provider,omitempty" json:"provider,omitempty"`
+
+	// Confirmed Whether the users confirmed their email or phone.
+	Confirmed *bool `form:"confirmed,omitempty" json:"confirmed,omitempty"`
+
+	// Banned Whether the users are banned.
+	Banned *bool `form:"banned,omitempty" json:"banned,omitempty"`
+
+	// Anonymous Whether the users are anonymous.
+	Anonymous *bool `form:"anonymous,omitempty" json:"anonymous,omitempty"`
+
+	// MfaEnabled Whether the users have a verified MFA factor.
+	MfaEnabled *bool `form:"mfa_enabled,omitempty" json:"mfa_enabled,omitempty"`
+
+	// CreatedAfter Users created at or after the time.
+	CreatedAfter *time.Time `form:"created_after,omitempty" json:"created_after,omitempty"`
+
+	// CreatedBefore Users created before the time.
+	CreatedBefore *time.Time `form:"created_before,omitempty" json:"created_before,omitempty"`
+
+	// LastSignInAfter Users who last signed in at or after the time.
+	LastSignInAfter *time.Time `form:"last_sign_in_after,omitempty" json:"last_sign_in_after,omitempty"`
+
+	// LastSignInBefore Users who last signed in before the time.
+	LastSignInBefore *time.Time `form:"last_sign_in_before,omitempty" json:"last_sign_in_before,omitempty"`
+
+	// Cursor Switches to cursor pagination, which stays fast on large user bases. Empty for the first page, then the `next_cursor` of the previous page. Users can also be filtered by app metadata with `app_metadata.<path>=<value>` parameters, where the path is dot separated.
+	Cursor *string `form:"cursor,omitempty" json:"cursor,omitempty"`
+
+	// Count How `X-Total-Count` is computed with cursor pagination.
+	Count *GetAdminUsersParamsCount `form:"count,omitempty" json:"count,omitempty"`
 }
 
+// GetAdminUsersParamsCount defines parameters for GetAdminUsers.
+type GetAdminUsersParamsCount string
+
 // PutAdminUsersUserIdFactorsFactorIdJSONBody defines parameters for PutAdminUsersUserIdFactorsFactorId.
 type PutAdminUsersUserIdFactorsFactorIdJSONBody = map[string]interface{}
 
@@ -971,6 +1032,278 @@ func NewGetAdminUsersRequest(server string, params *GetAdminUsersParams) (*http.
 
 		}
 
+		if params.Sort != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "sort", runtime.ParamLocationQuery, *params.Sort); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.Filter != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "filter", runtime.ParamLocationQuery, *params.Filter); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.Email != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "email", runtime.ParamLocationQuery, *params.Email); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.EmailPrefix != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "email_prefix", runtime.ParamLocationQuery, *params.EmailPrefix); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.Phone != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "phone", runtime.ParamLocationQuery, *params.Phone); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.PhonePrefix != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "phone_prefix", runtime.ParamLocationQuery, *params.PhonePrefix); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.Provider != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "provider", runtime.ParamLocationQuery, *params.Provider); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.Confirmed != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "confirmed", runtime.ParamLocationQuery, *params.Confirmed); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.Banned != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "banned", runtime.ParamLocationQuery, *params.Banned); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.Anonymous != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "anonymous", runtime.ParamLocationQuery, *params.Anonymous); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.MfaEnabled != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "mfa_enabled", runtime.ParamLocationQuery, *params.MfaEnabled); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.CreatedAfter != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "created_after", runtime.ParamLocationQuery, *params.CreatedAfter); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.CreatedBefore != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "created_before", runtime.ParamLocationQuery, *params.CreatedBefore); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.LastSignInAfter != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "last_sign_in_after", runtime.ParamLocationQuery, *params.LastSignInAfter); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.LastSignInBefore != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "last_sign_in_before", runtime.ParamLocationQuery, *params.LastSignInBefore); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.Cursor != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "cursor", runtime.ParamLocationQuery, *params.Cursor); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.Count != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "count", runtime.ParamLocationQuery, *params.Count); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
 		queryURL.RawQuery = queryValues.Encode()
 	}
 
@@ -1602,8 +1935,11 @@ type GetAdminUsersResponse struct {
 	HTTPResponse *http.Response
 	JSON200      *struct {
 		// Deprecated:
-		Aud   *string       `json:"aud,omitempty"`
-		Users *[]UserSchema `json:"users,omitempty"`
+		Aud *string `json:"aud,omitempty"`
+
+		// NextCursor Cursor of the next page with cursor pagination, missing on the last page.
+		NextCursor *string       `json:"next_cursor,omitempty"`
+		Users      *[]UserSchema `json:"users,omitempty"`
 	}
 	JSON401 *UnauthorizedResponse
 	JSON403 *ForbiddenResponse
@@ -2377,8 +2713,11 @@ func ParseGetAdminUsersResponse(rsp *http.Response) (*GetAdminUsersResponse, err
 	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 200:
 		var dest struct {
 			// Deprecated:
-			Aud   *string       `json:"aud,omitempty"`
-			Users *[]UserSchema `json:"users,omitempty"`
+			Aud *string `json:"aud,omitempty"`
+
+			// NextCursor Cursor of the next page with cursor pagination, missing on the last page.
+			NextCursor *string       `json:"next_cursor,omitempty"`
+			Users      *[]UserSchema `json:"users,omitempty"`
 		}
 		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
 			return nil, err
@@ -1,9 +1,18 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
 	"github.com/gofrs/uuid"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/supabase/auth/internal/api"
 	"github.com/supabase/auth/internal/conf"
 	"github.com/supabase/auth/internal/models"
 	"github.com/supabase/auth/internal/storage"
@@ -11,6 +20,9 @@ import (
 
 var autoconfirm, isAdmin bool
 var audience string
+var apiKeyExpiresIn time.Duration
+var userImportFormat, userImportResume string
+var userExportPasswordHashes bool
 
 func getAudience(c *conf.GlobalConfiguration) string {
 	if audience == "" {
@@ -25,12 +37,19 @@ func adminCmd() *cobra.Command {
 		Use: "admin",
 	}
 
-	adminCmd.AddCommand(&adminCreateUserCmd, &adminDeleteUserCmd)
+	adminCmd.AddCommand(&adminCreateUserCmd, &adminDeleteUserCmd, &adminCreateAPIKeyCmd, &adminRevokeAPIKeyCmd, &adminImportCmd, &adminExportCmd)
 	adminCmd.PersistentFlags().StringVarP(&audience, "aud", "a", "", "Set the new user's audience")
 
 	adminCreateUserCmd.Flags().BoolVar(&autoconfirm, "confirm", false, "Automatically confirm user without sending an email")
 	adminCreateUserCmd.Flags().BoolVar(&isAdmin, "admin", false, "Create user with admin privileges")
 
+	adminCreateAPIKeyCmd.Flags().DurationVar(&apiKeyExpiresIn, "expires-in", 0, "Expire the API key after this duration, e.g. 720h (never by default)")
+
+	adminImportCmd.Flags().StringVar(&userImportFormat, "format", "", "Format of the file, ndjson or csv (by the file extension by default)")
+	adminImportCmd.Flags().StringVar(&userImportResume, "resume", "", "Resume the import job with this ID instead of importing a file")
+	adminExportCmd.Flags().StringVar(&userImportFormat, "format", "", "Format of the file, ndjson or csv (by the file extension by default)")
+	adminExportCmd.Flags().BoolVar(&userExportPasswordHashes, "include-password-hashes", false, "Export the users' password hashes")
+
 	return adminCmd
 }
 
@@ -58,6 +77,51 @@ var adminDeleteUserCmd = cobra.Command{
 	},
 }
 
+var adminCreateAPIKeyCmd = cobra.Command{
+	Use: "createapikey",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 2 {
+			logrus.Fatal("Not enough arguments to createapikey command. Expected a name and at least one permission, e.g. support users:read audit:read")
+			return
+		}
+
+		execWithConfigAndArgs(cmd, adminCreateAPIKey, args)
+	},
+}
+
+var adminRevokeAPIKeyCmd = cobra.Command{
+	Use: "revokeapikey",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			logrus.Fatal("Not enough arguments to revokeapikey command. Expected the ID of the API key")
+			return
+		}
+
+		execWithConfigAndArgs(cmd, adminRevokeAPIKey, args)
+	},
+}
+
+var adminImportCmd = cobra.Command{
+	Use:   "import",
+	Short: "Import users from an NDJSON or CSV file, or - for stdin",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 && userImportResume == "" {
+			logrus.Fatal("Not enough arguments to import command. Expected a file or the --resume flag")
+			return
+		}
+
+		execWithConfigAndArgs(cmd, adminImport, args)
+	},
+}
+
+var adminExportCmd = cobra.Command{
+	Use:   "export",
+	Short: "Export users to an NDJSON or CSV file, or stdout",
+	Run: func(cmd *cobra.Command, args []string) {
+		execWithConfigAndArgs(cmd, adminExport, args)
+	},
+}
+
 func adminCreateUser(config *conf.GlobalConfiguration, args []string) {
 	db, err := storage.Dial(config)
 	if err != nil {
@@ -129,3 +193,165 @@ func adminDeleteUser(config *conf.GlobalConfiguration, args []string) {
 
 	logrus.Infof("Removed user: %s", args[0])
 }
+
+func adminCreateAPIKey(config *conf.GlobalConfiguration, args []string) {
+	permissions, err := api.ParseAdminPermissions(args[1:])
+	if err != nil {
+		logrus.Fatalf("Error creating API key: %+v", err)
+	}
+
+	var expiresAt *time.Time
+	if apiKeyExpiresIn > 0 {
+		t := time.Now().Add(apiKeyExpiresIn)
+		expiresAt = &t
+	}
+
+	db, err := storage.Dial(config)
+	if err != nil {
+		logrus.Fatalf("Error opening database: %+v", err)
+	}
+	defer db.Close()
+
+	k, key := models.NewAdminAPIKey(args[0], permissions, expiresAt)
+	if err := db.Create(k); err != nil {
+		logrus.Fatalf("Unable to create API key (%s): %+v", args[0], err)
+	}
+
+	logrus.Infof("Created API key %s (%s), it can't be shown again", k.ID, args[0])
+
+	// the key is printed to stdout, so that it can be piped into a secret store
+	fmt.Println(key)
+}
+
+func adminRevokeAPIKey(config *conf.GlobalConfiguration, args []string) {
+	keyID, err := uuid.FromString(args[0])
+	if err != nil {
+		logrus.Fatalf("Invalid API key ID (%s): %+v", args[0], err)
+	}
+
+	db, err := storage.Dial(config)
+	if err != nil {
+		logrus.Fatalf("Error opening database: %+v", err)
+	}
+	defer db.Close()
+
+	k, err := models.FindAdminAPIKeyByID(db, keyID)
+	if err != nil {
+		logrus.Fatalf("Error finding API key (%s): %+v", keyID, err)
+	}
+
+	if err := k.Revoke(db); err != nil {
+		logrus.Fatalf("Error revoking API key (%s): %+v", keyID, err)
+	}
+
+	logrus.Infof("Revoked API key: %s", keyID)
+}
+
+// userImportFileFormat returns the format of the --format flag, or else by
+// the extension of the file.
+func userImportFileFormat(path string) string {
+	if userImportFormat != "" {
+		return userImportFormat
+	}
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return api.UserImportFormatCSV
+	}
+	return api.UserImportFormatNDJSON
+}
+
+func adminImport(config *conf.GlobalConfiguration, args []string) {
+	ctx := context.Background()
+
+	db, err := storage.Dial(config)
+	if err != nil {
+		logrus.Fatalf("Error opening database: %+v", err)
+	}
+	defer db.Close()
+
+	a := api.NewAPI(config, db)
+
+	var job *models.UserImportJob
+	if userImportResume != "" {
+		jobID, err := uuid.FromString(userImportResume)
+		if err != nil {
+			logrus.Fatalf("Invalid import job ID (%s): %+v", userImportResume, err)
+		}
+
+		job, err = models.FindUserImportJobByID(db, jobID)
+		if err != nil {
+			logrus.Fatalf("Error finding import job (%s): %+v", jobID, err)
+		}
+		if job.Status == models.UserImportUploading {
+			logrus.Fatalf("Import job %s wasn't uploaded completely and can't be resumed", jobID)
+		}
+	} else {
+		var in io.Reader = os.Stdin
+		if args[0] != "-" {
+			f, err := os.Open(args[0]) // #nosec G304
+			if err != nil {
+				logrus.Fatalf("Error opening file (%s): %+v", args[0], err)
+			}
+			defer f.Close()
+			in = f
+		}
+
+		job, err = a.ImportUsers(ctx, getAudience(config), userImportFileFormat(args[0]), in, true)
+		if err != nil {
+			logrus.Fatalf("Error uploading users (%s): %+v", args[0], err)
+		}
+
+		logrus.Infof("Uploaded %d rows as import job %s, resume it with --resume if it is interrupted", job.TotalRows, job.ID)
+	}
+
+	if err := a.ProcessUserImportJob(ctx, job); err != nil {
+		logrus.Fatalf("Error importing users, resume import job %s with --resume: %+v", job.ID, err)
+	}
+
+	job, err = models.FindUserImportJobByID(db, job.ID)
+	if err != nil {
+		logrus.Fatalf("Error finding import job: %+v", err)
+	}
+
+	rows, err := models.FindFailedUserImportRows(db, job.ID, nil)
+	if err != nil {
+		logrus.Fatalf("Error finding failed rows of import job (%s): %+v", job.ID, err)
+	}
+	for _, row := range rows {
+		logrus.Warnf("Line %d: %s", row.Line, *row.Error)
+	}
+
+	logrus.Infof("Imported %d of %d users, %d failed", job.ImportedRows, job.TotalRows, job.FailedRows)
+}
+
+func adminExport(config *conf.GlobalConfiguration, args []string) {
+	ctx := context.Background()
+
+	db, err := storage.Dial(config)
+	if err != nil {
+		logrus.Fatalf("Error opening database: %+v", err)
+	}
+	defer db.Close()
+
+	path := "-"
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	var out io.Writer = os.Stdout
+	if path != "-" {
+		f, err := os.Create(path) // #nosec G304
+		if err != nil {
+			logrus.Fatalf("Error creating file (%s): %+v", path, err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	count, err := api.NewAPI(config, db).ExportUsers(ctx, getAudience(config), userImportFileFormat(path), out, userExportPasswordHashes)
+	if err != nil {
+		logrus.Fatalf("Error exporting users after %d users: %+v", count, err)
+	}
+
+	// the export may be written to stdout, so the summary goes to the log
+	logrus.Infof("Exported %d users", count)
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"bufio"
+	"os"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/supabase/auth/internal/utilities"
+)
+
+var hibpBuildOptions utilities.HIBPBuildOptions
+
+func hibpCmd() *cobra.Command {
+	var hibpCmd = &cobra.Command{
+		Use:   "hibp",
+		Short: "Manage the offline Pwned Passwords dataset",
+	}
+
+	hibpCmd.AddCommand(&hibpBuildCmd)
+
+	hibpBuildCmd.Flags().Float64Var(&hibpBuildOptions.FalsePositives, "false-positives", 0.001, "False positive rate of the filter")
+	hibpBuildCmd.Flags().Uint64Var(&hibpBuildOptions.MinCount, "min-count", 1, "Exclude passwords seen fewer times than this in breaches")
+
+	return hibpCmd
+}
+
+var hibpBuildCmd = cobra.Command{
+	Use:   "build <dataset> <output>",
+	Short: "Compile a Pwned Passwords dataset into a filter for GOTRUE_PASSWORD_HIBP_OFFLINE_PATH",
+	Long: "Compile a Pwned Passwords dataset into a filter for GOTRUE_PASSWORD_HIBP_OFFLINE_PATH.\n\n" +
+		"The dataset is either a directory of range files as downloaded by the official downloader,\n" +
+		"or a single file with HASH:COUNT lines such as the SHA-1 \"ordered by hash\" download.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 2 {
+			logrus.Fatal("Not enough arguments to build command. Expected dataset and output paths")
+			return
+		}
+
+		hibpBuild(args[0], args[1])
+	},
+}
+
+func hibpBuild(input, output string) {
+	if hibpBuildOptions.FalsePositives <= 0 || hibpBuildOptions.FalsePositives >= 1 {
+		logrus.Fatal("False positive rate must be between 0 and 1")
+	}
+
+	f, err := os.Create(output) // #nosec G304
+	if err != nil {
+		logrus.Fatalf("Error creating output file: %+v", err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+
+	n, err := utilities.BuildHIBPFilter(input, w, hibpBuildOptions)
+	if err != nil {
+		logrus.Fatalf("Error building filter: %+v", err)
+	}
+
+	if err := w.Flush(); err != nil {
+		logrus.Fatalf("Error writing output file: %+v", err)
+	}
+
+	if err := f.Sync(); err != nil {
+		logrus.Fatalf("Error writing output file: %+v", err)
+	}
+
+	logrus.Infof("Built filter with %d pwned password hashes in %s", n, output)
+}
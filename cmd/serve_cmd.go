@@ -94,6 +94,24 @@ func serve(ctx context.Context) {
 		}()
 	}
 
+	if config.UserImport.WorkerEnabled {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			a.RunUserImportWorker(ctx)
+		}()
+	}
+
+	if config.UserDeletion.WorkerEnabled {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			a.RunUserDeletionWorker(ctx)
+		}()
+	}
+
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
@@ -32,6 +32,7 @@ type AdminUserParams struct {
 	UserMetaData map[string]interface{} `json:"user_metadata"`
 	AppMetaData  map[string]interface{} `json:"app_metadata"`
 	BanDuration  string                 `json:"ban_duration"`
+	Unlock       bool                   `json:"unlock"`
 }
 
 type adminUserDeleteParams struct {
@@ -44,8 +45,9 @@ type adminUserUpdateFactorParams struct {
 }
 
 type AdminListUsersResponse struct {
-	Users []*models.User `json:"users"`
-	Aud   string         `json:"aud"`
+	Users      []*models.User `json:"users"`
+	Aud        string         `json:"aud"`
+	NextCursor string         `json:"next_cursor,omitempty"`
 }
 
 func (a *API) loadUser(w http.ResponseWriter, r *http.Request) (context.Context, error) {
@@ -101,7 +103,9 @@ func (a *API) getAdminParams(r *http.Request) (*AdminUserParams, error) {
 	return params, nil
 }
 
-// adminUsers responds with a list of all users in a given audience
+// adminUsers responds with a list of all users in a given audience matching
+// the search filters. Users are paginated by page, or by cursor when the
+// cursor parameter is present, which stays fast however deep the page.
 func (a *API) adminUsers(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 	db := a.db.WithContext(ctx)
@@ -117,18 +121,60 @@ func (a *API) adminUsers(w http.ResponseWriter, r *http.Request) error {
 		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Bad Sort Parameters: %v", err)
 	}
 
-	filter := r.URL.Query().Get("filter")
+	searchParams, err := userSearchParams(r)
+	if err != nil {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Bad Search Parameters: %v", err)
+	}
+
+	if !r.URL.Query().Has("cursor") {
+		users, err := models.SearchUsersInAudience(db, aud, searchParams, pageParams, sortParams)
+		if err != nil {
+			return apierrors.NewInternalServerError("Database error finding users").WithInternalError(err)
+		}
+		addPaginationHeaders(w, r, pageParams)
+
+		return sendJSON(w, http.StatusOK, AdminListUsersResponse{
+			Users: users,
+			Aud:   aud,
+		})
+	}
 
-	users, err := models.FindUsersInAudience(db, aud, pageParams, sortParams, filter)
+	cursor, err := decodeUserSearchCursor(r.URL.Query().Get("cursor"))
+	if err != nil {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Bad Pagination Parameters: %v", err)
+	}
+
+	countMode, err := userCountMode(r)
+	if err != nil {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Bad Pagination Parameters: %v", err)
+	}
+
+	perPage := pageParams.PerPage
+	if perPage == 0 {
+		perPage = defaultPerPage
+	}
+
+	users, nextCursor, err := models.SearchUsersInAudienceAfter(db, aud, searchParams, cursor, sortParams.Fields[0].Dir, int(perPage)) // #nosec G115
 	if err != nil {
 		return apierrors.NewInternalServerError("Database error finding users").WithInternalError(err)
 	}
-	addPaginationHeaders(w, r, pageParams)
 
-	return sendJSON(w, http.StatusOK, AdminListUsersResponse{
-		Users: users,
-		Aud:   aud,
-	})
+	if countMode != models.UserCountNone {
+		count, err := models.CountUsersInAudience(db, aud, searchParams, countMode)
+		if err != nil {
+			return apierrors.NewInternalServerError("Database error counting users").WithInternalError(err)
+		}
+		addCountHeaders(w, count, countMode == models.UserCountEstimated)
+	}
+
+	response := AdminListUsersResponse{
+		Users:      users,
+		Aud:        aud,
+		NextCursor: encodeUserSearchCursor(nextCursor),
+	}
+	addCursorPaginationHeaders(w, r, response.NextCursor)
+
+	return sendJSON(w, http.StatusOK, response)
 }
 
 // adminUserGet returns information about a single user
@@ -142,7 +188,7 @@ func (a *API) adminUserGet(w http.ResponseWriter, r *http.Request) error {
 func (a *API) adminUserUpdate(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 	db := a.db.WithContext(ctx)
-	config := a.config
+	config := a.requestConfig(ctx)
 	user := getUser(ctx)
 	adminUser := getAdminUser(ctx)
 	params, err := a.getAdminParams(r)
@@ -179,7 +225,11 @@ func (a *API) adminUserUpdate(w http.ResponseWriter, r *http.Request) error {
 	if params.Password != nil {
 		password := *params.Password
 
-		if err := a.checkPasswordStrength(ctx, password); err != nil {
+		if err := a.checkPasswordStrength(ctx, password, user.GetEmail(), user.GetPhone(), params.Email, params.Phone); err != nil {
+			return err
+		}
+
+		if err := a.checkPasswordHistory(ctx, db, user, password); err != nil {
 			return err
 		}
 
@@ -188,6 +238,12 @@ func (a *API) adminUserUpdate(w http.ResponseWriter, r *http.Request) error {
 		}
 	}
 
+	if params.Role != "" {
+		if err := a.checkAssignableRole(ctx, params.Role); err != nil {
+			return err
+		}
+	}
+
 	err = db.Transaction(func(tx *storage.Connection) error {
 		if params.Role != "" {
 			if terr := user.SetRole(tx, params.Role); terr != nil {
@@ -211,6 +267,10 @@ func (a *API) adminUserUpdate(w http.ResponseWriter, r *http.Request) error {
 			if terr := user.UpdatePassword(tx, nil); terr != nil {
 				return terr
 			}
+
+			if terr := a.recordPasswordHistory(tx, user); terr != nil {
+				return terr
+			}
 		}
 
 		var identities []models.Identity
@@ -304,6 +364,12 @@ func (a *API) adminUserUpdate(w http.ResponseWriter, r *http.Request) error {
 			}
 		}
 
+		if params.Unlock {
+			if terr := a.unlockUser(r, tx, adminUser, user, models.FailedPasswordAttempt, models.FailedMFAAttempt); terr != nil {
+				return terr
+			}
+		}
+
 		if terr := models.NewAuditLogEntry(r, tx, adminUser, models.UserModifiedAction, "", map[string]interface{}{
 			"user_id":    user.ID,
 			"user_email": user.Email,
@@ -325,7 +391,7 @@ func (a *API) adminUserUpdate(w http.ResponseWriter, r *http.Request) error {
 func (a *API) adminUserCreate(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 	db := a.db.WithContext(ctx)
-	config := a.config
+	config := a.requestConfig(ctx)
 
 	adminUser := getAdminUser(ctx)
 	params, err := a.getAdminParams(r)
@@ -342,6 +408,12 @@ func (a *API) adminUserCreate(w http.ResponseWriter, r *http.Request) error {
 		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Cannot create a user without either an email or phone")
 	}
 
+	if params.Role != "" {
+		if err := a.checkAssignableRole(ctx, params.Role); err != nil {
+			return err
+		}
+	}
+
 	var providers []string
 	if params.Email != "" {
 		params.Email, err = a.validateEmail(params.Email)
@@ -430,6 +502,10 @@ func (a *API) adminUserCreate(w http.ResponseWriter, r *http.Request) error {
 			return terr
 		}
 
+		if terr := a.recordPasswordHistory(tx, user); terr != nil {
+			return terr
+		}
+
 		var identities []models.Identity
 		if user.GetEmail() != "" {
 			identity, terr := a.createNewIdentity(tx, user, "email", structs.Map(provider.Claims{
@@ -532,34 +608,7 @@ func (a *API) adminUserDelete(w http.ResponseWriter, r *http.Request) error {
 			return apierrors.NewInternalServerError("Error recording audit log entry").WithInternalError(terr)
 		}
 
-		if params.ShouldSoftDelete {
-			if user.DeletedAt != nil {
-				// user has been soft deleted already
-				return nil
-			}
-			if terr := user.SoftDeleteUser(tx); terr != nil {
-				return apierrors.NewInternalServerError("Error soft deleting user").WithInternalError(terr)
-			}
-
-			if terr := user.SoftDeleteUserIdentities(tx); terr != nil {
-				return apierrors.NewInternalServerError("Error soft deleting user identities").WithInternalError(terr)
-			}
-
-			// hard delete all associated factors
-			if terr := models.DeleteFactorsByUserId(tx, user.ID); terr != nil {
-				return apierrors.NewInternalServerError("Error deleting user's factors").WithInternalError(terr)
-			}
-			// hard delete all associated sessions
-			if terr := models.Logout(tx, user.ID); terr != nil {
-				return apierrors.NewInternalServerError("Error deleting user's sessions").WithInternalError(terr)
-			}
-		} else {
-			if terr := tx.Destroy(user); terr != nil {
-				return apierrors.NewInternalServerError("Database error deleting user").WithInternalError(terr)
-			}
-		}
-
-		return nil
+		return deleteUser(tx, user, params.ShouldSoftDelete)
 	})
 	if err != nil {
 		return err
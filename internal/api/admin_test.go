@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
 
@@ -17,6 +18,7 @@ import (
 	"github.com/stretchr/testify/suite"
 	"github.com/supabase/auth/internal/api/apierrors"
 	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/crypto"
 	"github.com/supabase/auth/internal/models"
 )
 
@@ -227,6 +229,119 @@ func (ts *AdminTestSuite) TestAdminUsers_FilterName() {
 	assert.Equal(ts.T(), "test1@example.com", data.Users[0].GetEmail())
 }
 
+// TestAdminUsers tests API /admin/users route
+func (ts *AdminTestSuite) TestAdminUsers_StructuredFilters() {
+	u, err := models.NewUser("", "alice@example.com", "test", ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err, "Error making new user")
+	u.AppMetaData = map[string]interface{}{"plan": "pro", "org": map[string]interface{}{"id": 42}}
+	require.NoError(ts.T(), ts.API.db.Create(u), "Error creating user")
+	require.NoError(ts.T(), u.Confirm(ts.API.db))
+
+	u, err = models.NewUser("12345678", "bob@example.com", "test", ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err, "Error making new user")
+	u.AppMetaData = map[string]interface{}{"plan": "free"}
+	require.NoError(ts.T(), ts.API.db.Create(u), "Error creating user")
+
+	cases := []struct {
+		query    string
+		expected []string
+	}{
+		{"email=ALICE@example.com", []string{"alice@example.com"}},
+		{"email_prefix=bo", []string{"bob@example.com"}},
+		{"phone_prefix=%2B1234", []string{"bob@example.com"}},
+		{"confirmed=true", []string{"alice@example.com"}},
+		{"confirmed=false", []string{"bob@example.com"}},
+		{"anonymous=true", []string{}},
+		{"banned=false&mfa_enabled=false", []string{"alice@example.com", "bob@example.com"}},
+		{"app_metadata.plan=pro", []string{"alice@example.com"}},
+		{"app_metadata.org.id=42", []string{"alice@example.com"}},
+		{"app_metadata.plan=free&email_prefix=alice", []string{}},
+		{"created_after=" + url.QueryEscape(time.Now().Add(time.Hour).Format(time.RFC3339)), []string{}},
+	}
+
+	for _, c := range cases {
+		ts.Run(c.query, func() {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/admin/users?sort=created_at+asc&"+c.query, nil)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ts.token))
+
+			ts.API.handler.ServeHTTP(w, req)
+			require.Equal(ts.T(), http.StatusOK, w.Code)
+
+			data := AdminListUsersResponse{}
+			require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+
+			emails := []string{}
+			for _, user := range data.Users {
+				emails = append(emails, user.GetEmail())
+			}
+			assert.Equal(ts.T(), c.expected, emails)
+		})
+	}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/admin/users?confirmed=maybe", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ts.token))
+
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+}
+
+// TestAdminUsers tests API /admin/users route
+func (ts *AdminTestSuite) TestAdminUsers_CursorPagination() {
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		u, err := models.NewUser("", fmt.Sprintf("test%d@example.com", i), "test", ts.Config.JWT.Aud, nil)
+		require.NoError(ts.T(), err, "Error making new user")
+		u.CreatedAt = now.Add(time.Duration(i) * time.Minute)
+		require.NoError(ts.T(), ts.API.db.Create(u), "Error creating user")
+	}
+
+	emails := []string{}
+	path := "/admin/users?count=exact&cursor=&per_page=2"
+	for pages := 0; path != ""; pages++ {
+		require.Less(ts.T(), pages, 2)
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ts.token))
+
+		ts.API.handler.ServeHTTP(w, req)
+		require.Equal(ts.T(), http.StatusOK, w.Code)
+		assert.Equal(ts.T(), "3", w.Header().Get("X-Total-Count"))
+		assert.Empty(ts.T(), w.Header().Get("X-Total-Count-Estimated"))
+
+		data := AdminListUsersResponse{}
+		require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+		for _, user := range data.Users {
+			emails = append(emails, user.GetEmail())
+		}
+
+		path = ""
+		if data.NextCursor != "" {
+			path = "/admin/users?count=exact&cursor=" + data.NextCursor + "&per_page=2"
+			assert.Equal(ts.T(), "<"+path+">; rel=\"next\"", w.Header().Get("Link"))
+		}
+	}
+
+	assert.Equal(ts.T(), []string{"test2@example.com", "test1@example.com", "test0@example.com"}, emails)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/admin/users?cursor=", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ts.token))
+
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+	assert.Equal(ts.T(), "true", w.Header().Get("X-Total-Count-Estimated"))
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/admin/users?cursor=invalid", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ts.token))
+
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+}
+
 // TestAdminUserCreate tests API /admin/user route (POST)
 func (ts *AdminTestSuite) TestAdminUserCreate() {
 	cases := []struct {
@@ -429,6 +544,33 @@ func (ts *AdminTestSuite) TestAdminUserGet() {
 }
 
 // TestAdminUserUpdate tests API /admin/user route (UPDATE)
+func (ts *AdminTestSuite) TestAdminUserUpdateUnlock() {
+	u, err := models.NewUser("", "test1@example.com", "test", ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err, "Error making new user")
+	require.NoError(ts.T(), ts.API.db.Create(u), "Error creating user")
+
+	require.NoError(ts.T(), models.AddFailedAuthAttempt(ts.API.db, u.ID, models.FailedPasswordAttempt, "127.0.0.1"))
+	require.NoError(ts.T(), models.AddFailedAuthAttempt(ts.API.db, u.ID, models.FailedMFAAttempt, "127.0.0.1"))
+
+	var buffer bytes.Buffer
+	require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
+		"unlock": true,
+	}))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/admin/users/%s", u.ID), &buffer)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ts.token))
+
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	for _, kind := range []models.FailedAuthAttemptKind{models.FailedPasswordAttempt, models.FailedMFAAttempt} {
+		attempts, err := models.FindFailedAuthAttempts(ts.API.db, u.ID, kind, time.Time{})
+		require.NoError(ts.T(), err)
+		require.Empty(ts.T(), attempts)
+	}
+}
+
 func (ts *AdminTestSuite) TestAdminUserUpdate() {
 	u, err := models.NewUser("12345678", "test1@example.com", "test", ts.Config.JWT.Aud, nil)
 	require.NoError(ts.T(), err, "Error making new user")
@@ -914,3 +1056,84 @@ func (ts *AdminTestSuite) TestAdminUserCreateValidationErrors() {
 
 	}
 }
+
+func (ts *AdminTestSuite) TestAdminUsersImportExport() {
+	hash, err := crypto.GenerateFromPassword(context.Background(), "test-password")
+	require.NoError(ts.T(), err)
+
+	var body bytes.Buffer
+	for _, record := range []map[string]interface{}{
+		{
+			"email":         "import@example.com",
+			"password_hash": hash,
+			"email_confirm": true,
+			"user_metadata": map[string]interface{}{"name": "Imported"},
+			"identities": []map[string]interface{}{
+				{"provider": "google", "provider_id": "google-123", "identity_data": map[string]interface{}{"email": "import@example.com"}},
+			},
+		},
+		{
+			"email": "import@example.com",
+		},
+	} {
+		require.NoError(ts.T(), json.NewEncoder(&body).Encode(record))
+	}
+	body.WriteString("not json\n")
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/users/import", &body)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ts.token))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusAccepted, w.Code)
+
+	job := models.UserImportJob{}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&job))
+	require.Equal(ts.T(), models.UserImportPending, job.Status)
+	require.Equal(ts.T(), 3, job.TotalRows)
+	require.Equal(ts.T(), 1, job.FailedRows)
+
+	require.NoError(ts.T(), ts.API.ProcessUserImports(context.Background()))
+
+	req = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/admin/users/import/%s", job.ID), nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ts.token))
+	w = httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&job))
+	require.Equal(ts.T(), models.UserImportCompleted, job.Status)
+	require.Equal(ts.T(), 1, job.ImportedRows)
+	require.Equal(ts.T(), 2, job.FailedRows)
+
+	req = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/admin/users/import/%s/errors", job.ID), nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ts.token))
+	w = httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+	errs := AdminUserImportErrorsResponse{}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&errs))
+	require.Len(ts.T(), errs.Errors, 2)
+	require.Equal(ts.T(), 2, errs.Errors[0].Line)
+	require.Equal(ts.T(), 3, errs.Errors[1].Line)
+
+	u, err := models.FindUserByEmailAndAudience(ts.API.db, "import@example.com", ts.Config.JWT.Aud)
+	require.NoError(ts.T(), err)
+	require.True(ts.T(), u.IsConfirmed())
+	require.Len(ts.T(), u.Identities, 2)
+	valid, _, err := u.Authenticate(context.Background(), ts.API.db, "test-password", nil, false, "")
+	require.NoError(ts.T(), err)
+	require.True(ts.T(), valid)
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/users/export", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ts.token))
+	w = httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+	require.Equal(ts.T(), "application/x-ndjson", w.Header().Get("Content-Type"))
+
+	exported := UserImportRecord{}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&exported))
+	require.Equal(ts.T(), u.ID.String(), exported.ID)
+	require.Equal(ts.T(), hash, exported.PasswordHash)
+	require.Len(ts.T(), exported.Identities, 2)
+}
@@ -0,0 +1,242 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gofrs/uuid"
+	"github.com/supabase/auth/internal/api/apierrors"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/observability"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// AdminAPIKeyParams are the parameters the create admin API key endpoint
+// accepts.
+type AdminAPIKeyParams struct {
+	Name        string     `json:"name"`
+	Permissions []string   `json:"permissions"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
+
+// AdminAPIKeyResponse is an admin API key along with the key itself, which
+// is only returned when it's created or rotated.
+type AdminAPIKeyResponse struct {
+	*models.AdminAPIKey
+	Key string `json:"key"`
+}
+
+// AdminListAPIKeysResponse is the response struct from the adminAPIKeys
+// endpoint.
+type AdminListAPIKeysResponse struct {
+	APIKeys []*models.AdminAPIKey `json:"api_keys"`
+}
+
+// adminAPIKeyUser is the admin user of requests authenticated with the
+// admin API key, as recorded in the audit log.
+func adminAPIKeyUser(k *models.AdminAPIKey) *models.User {
+	return &models.User{
+		Role:  "admin_api_key",
+		Email: storage.NullString("admin_api_key:" + k.ID.String()),
+	}
+}
+
+// requireAdminAPIKey authenticates the request with an admin API key,
+// granting the key's permissions. Every use is recorded in the audit log.
+func (a *API) requireAdminAPIKey(req *http.Request, key string) (context.Context, error) {
+	ctx := req.Context()
+	db := a.db.WithContext(ctx)
+
+	k, err := models.FindAdminAPIKeyByKey(db, key)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return nil, apierrors.NewHTTPError(http.StatusUnauthorized, apierrors.ErrorCodeInvalidAdminAPIKey, "Invalid admin API key")
+		}
+		return nil, apierrors.NewInternalServerError("Database error finding admin API key").WithInternalError(err)
+	}
+
+	if !k.IsActive(time.Now()) {
+		return nil, apierrors.NewHTTPError(http.StatusUnauthorized, apierrors.ErrorCodeInvalidAdminAPIKey, "Admin API key has expired or was revoked")
+	}
+
+	observability.LogEntrySetField(req, "admin_api_key_id", k.ID.String())
+
+	adminUser := adminAPIKeyUser(k)
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		if terr := k.UpdateLastUsedAt(tx); terr != nil {
+			return terr
+		}
+
+		return models.NewAuditLogEntry(req, tx, adminUser, models.AdminAPIKeyUsedAction, "", map[string]interface{}{
+			"admin_api_key_id": k.ID,
+			"method":           req.Method,
+			"path":             req.URL.Path,
+		})
+	}); err != nil {
+		return nil, apierrors.NewInternalServerError("Database error recording admin API key use").WithInternalError(err)
+	}
+
+	ctx = withAdminPermissions(ctx, AdminPermissions(k.Permissions))
+	return withAdminUser(ctx, adminUser), nil
+}
+
+// loadAdminAPIKey looks for a key_id parameter in the URL route and adds the
+// admin API key with that ID to the context.
+func (a *API) loadAdminAPIKey(w http.ResponseWriter, r *http.Request) (context.Context, error) {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	keyID, err := uuid.FromString(chi.URLParam(r, "key_id"))
+	if err != nil {
+		return nil, apierrors.NewNotFoundError(apierrors.ErrorCodeValidationFailed, "key_id must be an UUID")
+	}
+
+	k, err := models.FindAdminAPIKeyByID(db, keyID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return nil, apierrors.NewNotFoundError(apierrors.ErrorCodeAdminAPIKeyNotFound, "Admin API key not found")
+		}
+		return nil, apierrors.NewInternalServerError("Database error loading admin API key").WithInternalError(err)
+	}
+
+	return withAdminAPIKey(ctx, k), nil
+}
+
+// adminAPIKeys lists the admin API keys, including revoked and expired ones.
+func (a *API) adminAPIKeys(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	keys, err := models.FindAdminAPIKeys(db)
+	if err != nil {
+		return apierrors.NewInternalServerError("Database error finding admin API keys").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, AdminListAPIKeysResponse{
+		APIKeys: keys,
+	})
+}
+
+// adminAPIKeyCreate creates an admin API key. Admins can't grant keys
+// permissions they don't have themselves.
+func (a *API) adminAPIKeyCreate(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	adminUser := getAdminUser(ctx)
+
+	params := &AdminAPIKeyParams{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	params.Name = strings.TrimSpace(params.Name)
+	if params.Name == "" {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Admin API key name is required")
+	}
+
+	if len(params.Permissions) == 0 {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Admin API keys require at least one permission")
+	}
+
+	permissions, err := ParseAdminPermissions(params.Permissions)
+	if err != nil {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Invalid permissions: %v", err)
+	}
+
+	if !getAdminPermissions(ctx).HasAll(permissions) {
+		return apierrors.NewForbiddenError(apierrors.ErrorCodeAdminPermissionDenied, "Admin API keys can't be granted permissions the admin doesn't have")
+	}
+
+	if params.ExpiresAt != nil && !params.ExpiresAt.After(time.Now()) {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "expires_at must be in the future")
+	}
+
+	k, key := models.NewAdminAPIKey(params.Name, permissions, params.ExpiresAt)
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		if terr := tx.Create(k); terr != nil {
+			return terr
+		}
+
+		return models.NewAuditLogEntry(r, tx, adminUser, models.AdminAPIKeyCreatedAction, "", map[string]interface{}{
+			"admin_api_key_id": k.ID,
+			"name":             k.Name,
+			"permissions":      k.Permissions,
+		})
+	}); err != nil {
+		return apierrors.NewInternalServerError("Database error creating admin API key").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusCreated, &AdminAPIKeyResponse{
+		AdminAPIKey: k,
+		Key:         key,
+	})
+}
+
+// adminAPIKeyGet returns a single admin API key, without the key.
+func (a *API) adminAPIKeyGet(w http.ResponseWriter, r *http.Request) error {
+	return sendJSON(w, http.StatusOK, getAdminAPIKey(r.Context()))
+}
+
+// adminAPIKeyRotate replaces the key of an admin API key, which keeps its
+// permissions and expiry.
+func (a *API) adminAPIKeyRotate(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	adminUser := getAdminUser(ctx)
+	k := getAdminAPIKey(ctx)
+
+	if !k.IsActive(time.Now()) {
+		return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeInvalidAdminAPIKey, "Expired or revoked admin API keys can't be rotated")
+	}
+
+	if !getAdminPermissions(ctx).HasAll(AdminPermissions(k.Permissions)) {
+		return apierrors.NewForbiddenError(apierrors.ErrorCodeAdminPermissionDenied, "Admin API keys with permissions the admin doesn't have can't be rotated")
+	}
+
+	var key string
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		if key, terr = k.Rotate(tx); terr != nil {
+			return terr
+		}
+
+		return models.NewAuditLogEntry(r, tx, adminUser, models.AdminAPIKeyRotatedAction, "", map[string]interface{}{
+			"admin_api_key_id": k.ID,
+		})
+	}); err != nil {
+		return apierrors.NewInternalServerError("Database error rotating admin API key").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, &AdminAPIKeyResponse{
+		AdminAPIKey: k,
+		Key:         key,
+	})
+}
+
+// adminAPIKeyRevoke revokes an admin API key. Revoked keys are kept, so that
+// their audit log entries can still be traced back to them.
+func (a *API) adminAPIKeyRevoke(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	adminUser := getAdminUser(ctx)
+	k := getAdminAPIKey(ctx)
+
+	if k.RevokedAt == nil {
+		if err := db.Transaction(func(tx *storage.Connection) error {
+			if terr := k.Revoke(tx); terr != nil {
+				return terr
+			}
+
+			return models.NewAuditLogEntry(r, tx, adminUser, models.AdminAPIKeyRevokedAction, "", map[string]interface{}{
+				"admin_api_key_id": k.ID,
+			})
+		}); err != nil {
+			return apierrors.NewInternalServerError("Database error revoking admin API key").WithInternalError(err)
+		}
+	}
+
+	return sendJSON(w, http.StatusOK, k)
+}
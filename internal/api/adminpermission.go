@@ -0,0 +1,172 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/supabase/auth/internal/api/apierrors"
+)
+
+// AdminPermission is a permission required by an admin API route.
+// Permissions are named <resource>:<action>.
+type AdminPermission string
+
+const (
+	AdminPermissionAuditRead          AdminPermission = "audit:read"
+	AdminPermissionUsersRead          AdminPermission = "users:read"
+	AdminPermissionUsersWrite         AdminPermission = "users:write"
+	AdminPermissionUsersDelete        AdminPermission = "users:delete"
+	AdminPermissionUsersExport        AdminPermission = "users:export"
+	AdminPermissionSSOManage          AdminPermission = "sso:manage"
+	AdminPermissionOrganizationsRead  AdminPermission = "organizations:read"
+	AdminPermissionOrganizationsWrite AdminPermission = "organizations:write"
+	AdminPermissionMailPreview        AdminPermission = "mail:preview"
+	AdminPermissionAPIKeysManage      AdminPermission = "api_keys:manage"
+	AdminPermissionAudiencesManage    AdminPermission = "audiences:manage"
+	AdminPermissionAdminRolesAssign   AdminPermission = "admin_roles:assign"
+)
+
+// adminPermissions are all of the admin permissions.
+var adminPermissions = []AdminPermission{
+	AdminPermissionAuditRead,
+	AdminPermissionUsersRead,
+	AdminPermissionUsersWrite,
+	AdminPermissionUsersDelete,
+	AdminPermissionUsersExport,
+	AdminPermissionSSOManage,
+	AdminPermissionOrganizationsRead,
+	AdminPermissionOrganizationsWrite,
+	AdminPermissionMailPreview,
+	AdminPermissionAPIKeysManage,
+	AdminPermissionAudiencesManage,
+	AdminPermissionAdminRolesAssign,
+}
+
+// adminPermissionAll grants every permission.
+const adminPermissionAll = "*"
+
+// AdminPermissions are the permissions of an admin. Besides permissions,
+// it may contain "*" for all permissions or "<resource>:*" for all
+// permissions of a resource.
+type AdminPermissions []string
+
+// Has reports whether the permissions grant the permission.
+func (p AdminPermissions) Has(permission AdminPermission) bool {
+	resource, _, _ := strings.Cut(string(permission), ":")
+
+	for _, granted := range p {
+		switch granted {
+		case adminPermissionAll, string(permission), resource + ":*":
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasAll reports whether the permissions grant all of the other permissions,
+// including their wildcards.
+func (p AdminPermissions) HasAll(other AdminPermissions) bool {
+	for _, permission := range other {
+		if !p.Has(AdminPermission(permission)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ParseAdminPermissions checks that the permissions are known permissions
+// or wildcards.
+func ParseAdminPermissions(permissions []string) (AdminPermissions, error) {
+	parsed := make(AdminPermissions, 0, len(permissions))
+
+	for _, permission := range permissions {
+		permission = strings.TrimSpace(permission)
+
+		if !isValidAdminPermission(permission) {
+			return nil, fmt.Errorf("unknown admin permission %q", permission)
+		}
+
+		parsed = append(parsed, permission)
+	}
+
+	return parsed, nil
+}
+
+func isValidAdminPermission(permission string) bool {
+	if permission == adminPermissionAll {
+		return true
+	}
+
+	for _, known := range adminPermissions {
+		resource, _, _ := strings.Cut(string(known), ":")
+
+		if permission == string(known) || permission == resource+":*" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// withAdminPermissions adds the admin's permissions to the context.
+func withAdminPermissions(ctx context.Context, permissions AdminPermissions) context.Context {
+	return context.WithValue(ctx, adminPermissionsKey, permissions)
+}
+
+// getAdminPermissions reads the admin's permissions from the context.
+func getAdminPermissions(ctx context.Context) AdminPermissions {
+	obj := ctx.Value(adminPermissionsKey)
+	if obj == nil {
+		return nil
+	}
+	return obj.(AdminPermissions)
+}
+
+// adminPermissionsFromClaims returns the permissions of an admin JWT. Tokens
+// without an admin_permissions claim have all permissions, like before
+// permissions were introduced, unless restrictUsers is set and the token was
+// issued to a signed-in user, which has a session.
+func adminPermissionsFromClaims(claims *AccessTokenClaims, restrictUsers bool) AdminPermissions {
+	if claims.AdminPermissions == nil {
+		if restrictUsers && claims.SessionId != "" {
+			return AdminPermissions{}
+		}
+
+		return AdminPermissions{adminPermissionAll}
+	}
+
+	return AdminPermissions(claims.AdminPermissions)
+}
+
+// requireAdminPermission fails unless the admin has the permission. Use only
+// after requireAdminCredentials.
+func (a *API) requireAdminPermission(permission AdminPermission) middlewareHandler {
+	return func(w http.ResponseWriter, req *http.Request) (context.Context, error) {
+		ctx := req.Context()
+
+		if !getAdminPermissions(ctx).Has(permission) {
+			return nil, apierrors.NewForbiddenError(apierrors.ErrorCodeAdminPermissionDenied, "This endpoint requires the %s admin permission", permission)
+		}
+
+		return ctx, nil
+	}
+}
+
+// checkAssignableRole fails if the role is one of the admin roles and the
+// admin isn't allowed to assign them, as users with an admin role can call
+// the admin API.
+func (a *API) checkAssignableRole(ctx context.Context, role string) error {
+	if !isStringInSlice(role, a.config.JWT.AdminRoles) {
+		return nil
+	}
+
+	if !getAdminPermissions(ctx).Has(AdminPermissionAdminRolesAssign) {
+		return apierrors.NewForbiddenError(apierrors.ErrorCodeAdminPermissionDenied, "Assigning the %s role requires the %s admin permission", role, AdminPermissionAdminRolesAssign)
+	}
+
+	return nil
+}
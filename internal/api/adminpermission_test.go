@@ -0,0 +1,161 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminPermissionsHas(t *testing.T) {
+	cases := []struct {
+		desc        string
+		permissions AdminPermissions
+		permission  AdminPermission
+		expected    bool
+	}{
+		{
+			desc:        "No permissions",
+			permissions: nil,
+			permission:  AdminPermissionUsersRead,
+			expected:    false,
+		},
+		{
+			desc:        "Exact permission",
+			permissions: AdminPermissions{"audit:read", "users:read"},
+			permission:  AdminPermissionUsersRead,
+			expected:    true,
+		},
+		{
+			desc:        "Write doesn't grant delete",
+			permissions: AdminPermissions{"users:read", "users:write"},
+			permission:  AdminPermissionUsersDelete,
+			expected:    false,
+		},
+		{
+			desc:        "Resource wildcard",
+			permissions: AdminPermissions{"users:*"},
+			permission:  AdminPermissionUsersDelete,
+			expected:    true,
+		},
+		{
+			desc:        "Resource wildcard of another resource",
+			permissions: AdminPermissions{"users:*"},
+			permission:  AdminPermissionSSOManage,
+			expected:    false,
+		},
+		{
+			desc:        "All permissions",
+			permissions: AdminPermissions{"*"},
+			permission:  AdminPermissionSSOManage,
+			expected:    true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			require.Equal(t, c.expected, c.permissions.Has(c.permission))
+		})
+	}
+}
+
+func TestAdminPermissionsFromClaims(t *testing.T) {
+	permissions := adminPermissionsFromClaims(&AccessTokenClaims{Role: "service_role"}, false)
+	require.True(t, permissions.Has(AdminPermissionUsersDelete))
+
+	permissions = adminPermissionsFromClaims(&AccessTokenClaims{Role: "service_role", AdminPermissions: []string{}}, false)
+	require.False(t, permissions.Has(AdminPermissionUsersRead))
+
+	// users given an admin role get all permissions, unless restricted
+	userClaims := &AccessTokenClaims{Role: "service_role", SessionId: "00000000-0000-0000-0000-000000000000"}
+	permissions = adminPermissionsFromClaims(userClaims, false)
+	require.True(t, permissions.Has(AdminPermissionUsersRead))
+
+	permissions = adminPermissionsFromClaims(userClaims, true)
+	require.False(t, permissions.Has(AdminPermissionUsersRead))
+}
+
+func TestAdminRoleAssignmentRequiresPermission(t *testing.T) {
+	api, config, err := setupAPIForTest()
+	require.NoError(t, err)
+	defer api.db.Close()
+
+	claims := &AccessTokenClaims{
+		Role:             "supabase_admin",
+		AdminPermissions: []string{string(AdminPermissionUsersWrite)},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(config.JWT.Secret))
+	require.NoError(t, err)
+
+	body := strings.NewReader(`{"email": "admin@example.com", "role": "service_role"}`)
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/admin/users", body)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	api.handler.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+}
+
+func TestRequireAdminPermission(t *testing.T) {
+	api, config, err := setupAPIForTest()
+	require.NoError(t, err)
+	defer api.db.Close()
+
+	claims := &AccessTokenClaims{
+		Role:             "supabase_admin",
+		AdminPermissions: []string{string(AdminPermissionUsersRead)},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(config.JWT.Secret))
+	require.NoError(t, err)
+
+	cases := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodPost, "/admin/users"},
+		{http.MethodDelete, "/admin/users/00000000-0000-0000-0000-000000000000"},
+		{http.MethodGet, "/admin/audit"},
+		{http.MethodGet, "/admin/sso/providers"},
+		{http.MethodPost, "/admin/generate_link"},
+		{http.MethodPost, "/invite"},
+		{http.MethodGet, "/admin/users/export"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.method+" "+c.path, func(t *testing.T) {
+			req := httptest.NewRequest(c.method, "http://localhost"+c.path, nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+
+			api.handler.ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestParseAdminPermissions(t *testing.T) {
+	permissions, err := ParseAdminPermissions([]string{"users:read", " audit:read ", "sso:*", "*"})
+	require.NoError(t, err)
+	require.Equal(t, AdminPermissions{"users:read", "audit:read", "sso:*", "*"}, permissions)
+
+	for _, permission := range []string{"", "users", "users:admin", "unknown:*", "users:read:*"} {
+		_, err := ParseAdminPermissions([]string{permission})
+		require.Error(t, err, permission)
+	}
+}
+
+func TestAdminPermissionsHasAll(t *testing.T) {
+	admin := AdminPermissions{"users:*", "audit:read"}
+
+	require.True(t, admin.HasAll(AdminPermissions{"users:read", "users:delete", "audit:read"}))
+	require.True(t, admin.HasAll(AdminPermissions{"users:*"}))
+	require.False(t, admin.HasAll(AdminPermissions{"users:read", "sso:manage"}))
+	require.False(t, admin.HasAll(AdminPermissions{"*"}))
+	require.True(t, AdminPermissions{"*"}.HasAll(AdminPermissions{"*"}))
+}
@@ -327,3 +327,68 @@ func (ts *AnonymousTestSuite) TestAdminUpdateAnonymousUser() {
 		})
 	}
 }
+
+func (ts *AnonymousTestSuite) TestMergeAnonymousUser() {
+	ts.Config.External.AnonymousUsers.Enabled = true
+
+	signIn := func(path string, body map[string]interface{}) *AccessTokenResponse {
+		var buffer bytes.Buffer
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(body))
+
+		req := httptest.NewRequest(http.MethodPost, path, &buffer)
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		ts.API.handler.ServeHTTP(w, req)
+		require.Equal(ts.T(), http.StatusOK, w.Code)
+
+		data := &AccessTokenResponse{}
+		require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+		return data
+	}
+
+	merge := func(token string, body map[string]interface{}) *httptest.ResponseRecorder {
+		var buffer bytes.Buffer
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(body))
+
+		req := httptest.NewRequest(http.MethodPost, "/user/merge", &buffer)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+		w := httptest.NewRecorder()
+		ts.API.handler.ServeHTTP(w, req)
+		return w
+	}
+
+	u, err := models.NewUser("", "merge@example.com", "test-password", ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), ts.API.db.Create(u))
+	require.NoError(ts.T(), u.Confirm(ts.API.db))
+
+	anonymous := signIn("/signup", map[string]interface{}{})
+	target := signIn("/token?grant_type=password", map[string]interface{}{
+		"email":    "merge@example.com",
+		"password": "test-password",
+	})
+
+	// the target must be a permanent user
+	w := merge(anonymous.Token, map[string]interface{}{"access_token": anonymous.Token})
+	require.Equal(ts.T(), http.StatusUnprocessableEntity, w.Code)
+
+	// only anonymous users can be merged
+	w = merge(target.Token, map[string]interface{}{"access_token": target.Token})
+	require.Equal(ts.T(), http.StatusUnprocessableEntity, w.Code)
+
+	w = merge(anonymous.Token, map[string]interface{}{"access_token": "invalid"})
+	require.Equal(ts.T(), http.StatusForbidden, w.Code)
+
+	w = merge(anonymous.Token, map[string]interface{}{"access_token": target.Token})
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	merged := &models.User{}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(merged))
+	require.Equal(ts.T(), u.ID, merged.ID)
+
+	_, err = models.FindUserByID(ts.API.db, anonymous.User.ID)
+	require.True(ts.T(), models.IsNotFoundError(err))
+}
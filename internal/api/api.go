@@ -1,8 +1,10 @@
 package api
 
 import (
+	"context"
 	"net/http"
 	"regexp"
+	"strconv"
 	"time"
 
 	"github.com/rs/cors"
@@ -10,6 +12,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/supabase/auth/internal/api/apierrors"
 	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/crypto"
 	"github.com/supabase/auth/internal/hooks/hookshttp"
 	"github.com/supabase/auth/internal/hooks/hookspgfunc"
 	"github.com/supabase/auth/internal/hooks/v0hooks"
@@ -19,6 +22,7 @@ import (
 	"github.com/supabase/auth/internal/observability"
 	"github.com/supabase/auth/internal/storage"
 	"github.com/supabase/auth/internal/utilities"
+	"github.com/supabase/auth/internal/utilities/siwe"
 	"github.com/supabase/hibp"
 )
 
@@ -37,7 +41,16 @@ type API struct {
 	version string
 
 	hooksMgr   *v0hooks.Manager
-	hibpClient *hibp.PwnedClient
+	audiences  *audienceConfigs
+	hibpClient utilities.HIBPChecker
+
+	// mailLayouts are the parsed layouts and partials of the local mail
+	// templates, nil if there are none or they failed to parse
+	mailLayouts *mailer.LocalLayouts
+
+	// web3ContractVerifier verifies signatures of Ethereum smart contract
+	// wallets, it is nil when no JSON-RPC endpoints are configured
+	web3ContractVerifier siwe.ContractVerifier
 
 	// overrideTime can be used to override the clock used by handlers. Should only be used in tests!
 	overrideTime func() time.Time
@@ -81,7 +94,7 @@ func (a *API) deprecationNotices() {
 
 // NewAPIWithVersion creates a new REST API using the specified version
 func NewAPIWithVersion(globalConfig *conf.GlobalConfiguration, db *storage.Connection, version string, opt ...Option) *API {
-	api := &API{config: globalConfig, db: db, version: version}
+	api := &API{config: globalConfig, db: db, version: version, audiences: &audienceConfigs{}}
 
 	for _, o := range opt {
 		o.apply(api)
@@ -94,24 +107,72 @@ func NewAPIWithVersion(globalConfig *conf.GlobalConfiguration, db *storage.Conne
 		pgfuncDr := hookspgfunc.New(db)
 		api.hooksMgr = v0hooks.NewManager(globalConfig, httpDr, pgfuncDr)
 	}
-	if api.config.Password.HIBP.Enabled {
+	if api.config.Password.HIBP.Enabled && api.config.Password.HIBP.OfflinePath != "" {
+		checker, err := utilities.LoadHIBPOffline(api.config.Password.HIBP.OfflinePath)
+		if err != nil {
+			// checks fail with this error, so that
+			// GOTRUE_PASSWORD_HIBP_FAIL_CLOSED applies
+			logrus.WithError(err).Error("Unable to load offline pwned passwords dataset")
+			checker = &hibpUnavailable{err: err}
+		} else if filter, ok := checker.(*utilities.HIBPFilter); ok {
+			logrus.Infof("Offline pwned passwords filter is %.2f MB", float64(filter.Cap())/(8*1024.0*1024.0))
+		}
+
+		api.hibpClient = checker
+	} else if api.config.Password.HIBP.Enabled {
 		httpClient := &http.Client{
 			// all HIBP API requests should finish quickly to avoid
 			// unnecessary slowdowns
 			Timeout: 5 * time.Second,
 		}
 
-		api.hibpClient = &hibp.PwnedClient{
+		client := &hibp.PwnedClient{
 			UserAgent: api.config.Password.HIBP.UserAgent,
 			HTTP:      httpClient,
 		}
 
 		if api.config.Password.HIBP.Bloom.Enabled {
 			cache := utilities.NewHIBPBloomCache(api.config.Password.HIBP.Bloom.Items, api.config.Password.HIBP.Bloom.FalsePositives)
-			api.hibpClient.Cache = cache
+			client.Cache = cache
 
 			logrus.Infof("Pwned passwords cache is %.2f KB", float64(cache.Cap())/(8*1024.0))
 		}
+
+		api.hibpClient = client
+	}
+
+	if path := api.config.Mailer.TemplateStore.Path; path != "" {
+		layouts, err := mailer.ParseLocalLayouts(path, nil)
+		if err != nil {
+			// mails parse the layouts again and fail with this error
+			logrus.WithError(err).Error("Unable to parse mail template layouts")
+		}
+
+		api.mailLayouts = layouts
+	}
+
+	if api.config.External.Web3Ethereum.Enabled && len(api.config.External.Web3Ethereum.RPCURLs) > 0 {
+		urls := make(map[uint64]string, len(api.config.External.Web3Ethereum.RPCURLs))
+		for chainID, rpcURL := range api.config.External.Web3Ethereum.RPCURLs {
+			// chain IDs are validated by the configuration
+			id, _ := strconv.ParseUint(chainID, 10, 64)
+			urls[id] = rpcURL
+		}
+
+		api.web3ContractVerifier = &siwe.RPCContractVerifier{
+			HTTPClient: &http.Client{
+				Timeout: 10 * time.Second,
+			},
+			URLs: urls,
+		}
+	}
+
+	crypto.PasswordHashing = crypto.PasswordHashingParameters{
+		Algorithm:     globalConfig.Password.Hashing.Algorithm,
+		BcryptCost:    globalConfig.Password.Hashing.BcryptCost,
+		Argon2Memory:  globalConfig.Password.Hashing.Argon2Memory,
+		Argon2Time:    globalConfig.Password.Hashing.Argon2Time,
+		Argon2Threads: globalConfig.Password.Hashing.Argon2Threads,
 	}
 
 	api.deprecationNotices()
@@ -135,6 +196,10 @@ func NewAPIWithVersion(globalConfig *conf.GlobalConfiguration, db *storage.Conne
 		r.UseBypass(observability.RequestTracing())
 	}
 
+	if globalConfig.Metrics.Enabled && db != nil {
+		models.RegisterLegacyPasswordHashesMetric(db)
+	}
+
 	if globalConfig.DB.CleanupEnabled {
 		cleanup := models.NewCleanup(globalConfig)
 		r.UseBypass(api.databaseCleanup(cleanup))
@@ -144,6 +209,7 @@ func NewAPIWithVersion(globalConfig *conf.GlobalConfiguration, db *storage.Conne
 	r.Get("/.well-known/jwks.json", api.Jwks)
 
 	r.Route("/callback", func(r *router) {
+		r.Use(api.loadAudienceConfig)
 		r.Use(api.isValidExternalHost)
 		r.Use(api.loadFlowState)
 
@@ -153,13 +219,15 @@ func NewAPIWithVersion(globalConfig *conf.GlobalConfiguration, db *storage.Conne
 
 	r.Route("/", func(r *router) {
 
+		r.Use(api.loadAudienceConfig)
 		r.Use(api.isValidExternalHost)
 
 		r.Get("/settings", api.Settings)
 
 		r.Get("/authorize", api.ExternalProviderRedirect)
 
-		r.With(api.requireAdminCredentials).Post("/invite", api.Invite)
+		r.With(api.requireAdminCredentials).
+			With(api.requireAdminPermission(AdminPermissionUsersWrite)).Post("/invite", api.Invite)
 		r.With(api.verifyCaptcha).With(api.verifyYuZhaLabCode).Route("/signup", func(r *router) {
 			// rate limit per hour
 			limitAnonymousSignIns := api.limiterOpts.AnonymousSignIns
@@ -201,26 +269,41 @@ func NewAPIWithVersion(globalConfig *conf.GlobalConfiguration, db *storage.Conne
 		// rate limiting applied in handler
 		r.With(api.verifyCaptcha).Post("/token", api.Token)
 
+		r.With(api.limitHandler(api.limiterOpts.Web3)).Get("/web3/nonce", api.Web3Nonce)
+
 		r.With(api.limitHandler(api.limiterOpts.Verify)).Route("/verify", func(r *router) {
 			r.Get("/", api.Verify)
 			r.Post("/", api.Verify)
 		})
 
+		r.With(api.limitHandler(api.limiterOpts.Verify)).Route("/revoke_sign_in", func(r *router) {
+			r.Get("/", api.RevokeSignInConfirm)
+			r.Post("/", api.RevokeSignIn)
+		})
+
 		r.With(api.requireAuthentication).Post("/logout", api.Logout)
 
-		r.With(api.requireAuthentication).Route("/reauthenticate", func(r *router) {
+		r.With(api.requireAuthentication).With(api.requireSteppedUp).Route("/reauthenticate", func(r *router) {
 			r.Get("/", api.Reauthenticate)
 		})
 
-		r.With(api.requireAuthentication).Route("/user", func(r *router) {
+		r.With(api.requireAuthentication).With(api.requireSteppedUp).Route("/user", func(r *router) {
 			r.Get("/", api.UserGet)
 			r.With(api.limitHandler(api.limiterOpts.User)).Put("/", api.UserUpdate)
+			r.With(api.limitHandler(api.limiterOpts.User)).Post("/merge", api.UserMerge)
+			r.With(api.limitHandler(api.limiterOpts.User)).Get("/export", api.UserExport)
+			r.With(api.requireNotAnonymous).With(api.requireUserDeletionEnabled).With(api.limitHandler(api.limiterOpts.User)).Delete("/", api.UserDelete)
 
 			r.Route("/identities", func(r *router) {
 				r.Use(api.requireManualLinkingEnabled)
 				r.Get("/authorize", api.LinkIdentity)
 				r.Delete("/{identity_id}", api.DeleteIdentity)
 			})
+
+			r.Route("/organizations", func(r *router) {
+				r.Get("/", api.UserListOrganizations)
+				r.Post("/switch", api.UserSwitchOrganization)
+			})
 		})
 
 		r.With(api.requireAuthentication).Route("/factors", func(r *router) {
@@ -248,40 +331,106 @@ func NewAPIWithVersion(globalConfig *conf.GlobalConfiguration, db *storage.Conne
 
 				r.With(api.limitHandler(api.limiterOpts.SAMLAssertion)).
 					Post("/acs", api.SamlAcs)
+
+				r.With(api.limitHandler(api.limiterOpts.SAMLAssertion)).
+					Get("/slo", api.SamlSlo)
+				r.With(api.limitHandler(api.limiterOpts.SAMLAssertion)).
+					Post("/slo", api.SamlSlo)
+			})
+		})
+
+		r.Route("/scim/v2", func(r *router) {
+			r.Use(api.requireSAMLEnabled)
+
+			r.Get("/ServiceProviderConfig", api.scimHandler(api.scimServiceProviderConfig))
+
+			r.Route("/Users", func(r *router) {
+				r.Get("/", api.scimHandler(api.scimUsersList))
+				r.Post("/", api.scimHandler(api.scimUsersCreate))
+
+				r.Route("/{scim_id}", func(r *router) {
+					r.Get("/", api.scimHandler(api.scimUsersGet))
+					r.Put("/", api.scimHandler(api.scimUsersReplace))
+					r.Patch("/", api.scimHandler(api.scimUsersPatch))
+					r.Delete("/", api.scimHandler(api.scimUsersDelete))
+				})
+			})
+
+			r.Route("/Groups", func(r *router) {
+				r.Get("/", api.scimHandler(api.scimGroupsList))
+				r.Post("/", api.scimHandler(api.scimGroupsCreate))
+
+				r.Route("/{scim_id}", func(r *router) {
+					r.Get("/", api.scimHandler(api.scimGroupsGet))
+					r.Put("/", api.scimHandler(api.scimGroupsReplace))
+					r.Patch("/", api.scimHandler(api.scimGroupsPatch))
+					r.Delete("/", api.scimHandler(api.scimGroupsDelete))
+				})
 			})
 		})
 
 		r.Route("/admin", func(r *router) {
 			r.Use(api.requireAdminCredentials)
 
+			// permissions are checked before loading resources, so
+			// that admins without them can't probe which exist
+			auditRead := api.requireAdminPermission(AdminPermissionAuditRead)
+			usersRead := api.requireAdminPermission(AdminPermissionUsersRead)
+			usersWrite := api.requireAdminPermission(AdminPermissionUsersWrite)
+			usersDelete := api.requireAdminPermission(AdminPermissionUsersDelete)
+			usersExport := api.requireAdminPermission(AdminPermissionUsersExport)
+			ssoManage := api.requireAdminPermission(AdminPermissionSSOManage)
+			organizationsRead := api.requireAdminPermission(AdminPermissionOrganizationsRead)
+			organizationsWrite := api.requireAdminPermission(AdminPermissionOrganizationsWrite)
+			mailPreview := api.requireAdminPermission(AdminPermissionMailPreview)
+			apiKeysManage := api.requireAdminPermission(AdminPermissionAPIKeysManage)
+			audiencesManage := api.requireAdminPermission(AdminPermissionAudiencesManage)
+
 			r.Route("/audit", func(r *router) {
-				r.Get("/", api.adminAuditLog)
+				r.With(auditRead).Get("/", api.adminAuditLog)
 			})
 
 			r.Route("/users", func(r *router) {
-				r.Get("/", api.adminUsers)
-				r.Post("/", api.adminUserCreate)
+				r.With(usersRead).Get("/", api.adminUsers)
+				r.With(usersWrite).Post("/", api.adminUserCreate)
+				r.With(usersExport).Get("/export", api.adminUsersExport)
+
+				r.Route("/import", func(r *router) {
+					r.With(usersWrite).Post("/", api.adminUsersImport)
+					r.Route("/{job_id}", func(r *router) {
+						r.Use(usersWrite)
+						r.Use(api.loadUserImportJob)
+						r.Get("/", api.adminUsersImportGet)
+						r.Get("/errors", api.adminUsersImportErrors)
+					})
+				})
 
 				r.Route("/{user_id}", func(r *router) {
-					r.Use(api.loadUser)
 					r.Route("/factors", func(r *router) {
-						r.Get("/", api.adminUserGetFactors)
+						r.With(usersRead).With(api.loadUser).Get("/", api.adminUserGetFactors)
 						r.Route("/{factor_id}", func(r *router) {
+							r.Use(usersWrite)
+							r.Use(api.loadUser)
 							r.Use(api.loadFactor)
 							r.Delete("/", api.adminUserDeleteFactor)
 							r.Put("/", api.adminUserUpdateFactor)
 						})
 					})
 
-					r.Get("/", api.adminUserGet)
-					r.Put("/", api.adminUserUpdate)
-					r.Delete("/", api.adminUserDelete)
+					r.With(usersRead).With(api.loadUser).Get("/", api.adminUserGet)
+					r.With(usersWrite).With(api.loadUser).Put("/", api.adminUserUpdate)
+					r.With(usersDelete).With(api.loadUser).Delete("/", api.adminUserDelete)
 				})
 			})
 
-			r.Post("/generate_link", api.adminGenerateLink)
+			r.With(usersWrite).Post("/generate_link", api.adminGenerateLink)
+
+			r.Route("/mail", func(r *router) {
+				r.With(mailPreview).Post("/preview", api.adminMailPreview)
+			})
 
 			r.Route("/sso", func(r *router) {
+				r.Use(ssoManage)
 				r.Route("/providers", func(r *router) {
 					r.Get("/", api.adminSSOProvidersList)
 					r.Post("/", api.adminSSOProvidersCreate)
@@ -292,6 +441,59 @@ func NewAPIWithVersion(globalConfig *conf.GlobalConfiguration, db *storage.Conne
 						r.Get("/", api.adminSSOProvidersGet)
 						r.Put("/", api.adminSSOProvidersUpdate)
 						r.Delete("/", api.adminSSOProvidersDelete)
+
+						r.Post("/scim_token", api.adminSSOProvidersCreateSCIMToken)
+						r.Delete("/scim_token", api.adminSSOProvidersDeleteSCIMToken)
+					})
+				})
+			})
+
+			r.Route("/api_keys", func(r *router) {
+				r.Use(apiKeysManage)
+				r.Get("/", api.adminAPIKeys)
+				r.Post("/", api.adminAPIKeyCreate)
+
+				r.Route("/{key_id}", func(r *router) {
+					r.Use(api.loadAdminAPIKey)
+
+					r.Get("/", api.adminAPIKeyGet)
+					r.Post("/rotate", api.adminAPIKeyRotate)
+					r.Delete("/", api.adminAPIKeyRevoke)
+				})
+			})
+
+			r.Route("/audiences", func(r *router) {
+				r.Use(audiencesManage)
+				r.Get("/", api.adminAudienceConfigs)
+
+				r.Route("/{aud}", func(r *router) {
+					r.Get("/", api.adminAudienceConfigGet)
+					r.Put("/", api.adminAudienceConfigUpdate)
+					r.Delete("/", api.adminAudienceConfigDelete)
+				})
+			})
+
+			r.Route("/organizations", func(r *router) {
+				r.With(organizationsRead).Get("/", api.adminOrganizations)
+				r.With(organizationsWrite).Post("/", api.adminOrganizationCreate)
+
+				r.Route("/{organization_id}", func(r *router) {
+					r.With(organizationsRead).With(api.loadOrganization).Get("/", api.adminOrganizationGet)
+					r.With(organizationsWrite).With(api.loadOrganization).Put("/", api.adminOrganizationUpdate)
+					r.With(organizationsWrite).With(api.loadOrganization).Delete("/", api.adminOrganizationDelete)
+
+					r.Route("/members", func(r *router) {
+						r.With(organizationsRead).With(api.loadOrganization).Get("/", api.adminOrganizationMembers)
+						r.With(organizationsWrite).With(api.loadOrganization).Post("/", api.adminOrganizationMemberCreate)
+
+						r.Route("/{user_id}", func(r *router) {
+							r.Use(organizationsWrite)
+							r.Use(api.loadOrganization)
+							r.Use(api.loadUser)
+
+							r.Put("/", api.adminOrganizationMemberUpdate)
+							r.Delete("/", api.adminOrganizationMemberDelete)
+						})
 					})
 				})
 			})
@@ -302,7 +504,7 @@ func NewAPIWithVersion(globalConfig *conf.GlobalConfiguration, db *storage.Conne
 	corsHandler := cors.New(cors.Options{
 		AllowedMethods:   []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete},
 		AllowedHeaders:   globalConfig.CORS.AllAllowedHeaders([]string{"Accept", "Authorization", "Content-Type", "X-Client-IP", "X-Client-Info", audHeaderName, useCookieHeader, APIVersionHeaderName}),
-		ExposedHeaders:   []string{"X-Total-Count", "Link", APIVersionHeaderName},
+		ExposedHeaders:   []string{"X-Total-Count", "X-Total-Count-Estimated", "Link", APIVersionHeaderName},
 		AllowCredentials: true,
 	})
 
@@ -326,9 +528,9 @@ func (a *API) HealthCheck(w http.ResponseWriter, r *http.Request) error {
 }
 
 // Mailer returns NewMailer with the current tenant config
-func (a *API) Mailer() mailer.Mailer {
-	config := a.config
-	return mailer.NewMailer(config)
+func (a *API) Mailer(ctx context.Context) mailer.Mailer {
+	config := a.requestConfig(ctx)
+	return mailer.NewMailer(config, a.db, a.mailLayouts)
 }
 
 // ServeHTTP implements the http.Handler interface by passing the request along
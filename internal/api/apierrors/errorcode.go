@@ -94,4 +94,21 @@ const (
 	ErrorCodeEmailAddressInvalid       ErrorCode = "email_address_invalid"
 	ErrorCodeWeb3ProviderDisabled      ErrorCode = "web3_provider_disabled"
 	ErrorCodeWeb3UnsupportedChain      ErrorCode = "web3_unsupported_chain"
+
+	ErrorCodeOrganizationNotFound       ErrorCode = "organization_not_found"
+	ErrorCodeOrganizationExists         ErrorCode = "organization_already_exists"
+	ErrorCodeOrganizationMemberNotFound ErrorCode = "organization_member_not_found"
+	ErrorCodeOrganizationMemberExists   ErrorCode = "organization_member_already_exists"
+	ErrorCodeAdminPermissionDenied      ErrorCode = "admin_permission_denied"
+	ErrorCodeInvalidAdminAPIKey         ErrorCode = "invalid_admin_api_key"
+	ErrorCodeAdminAPIKeyNotFound        ErrorCode = "admin_api_key_not_found"
+	ErrorCodeUserLocked                 ErrorCode = "user_locked"
+	ErrorCodeMFAVerificationLocked      ErrorCode = "mfa_verification_locked"
+	ErrorCodeSignInBlocked              ErrorCode = "sign_in_blocked"
+	ErrorCodeRevokeLinkInvalid          ErrorCode = "revoke_link_invalid"
+	ErrorCodeUserNotAnonymous           ErrorCode = "user_not_anonymous"
+	ErrorCodeMergeTargetInvalid         ErrorCode = "merge_target_invalid"
+	ErrorCodeUserImportNotFound         ErrorCode = "user_import_not_found"
+	ErrorCodeAudienceConfigNotFound     ErrorCode = "audience_config_not_found"
+	ErrorCodeUserDeletionDisabled       ErrorCode = "user_deletion_disabled"
 )
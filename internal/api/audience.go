@@ -0,0 +1,445 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sirupsen/logrus"
+	"github.com/supabase/auth/internal/api/apierrors"
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/crypto"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/observability"
+	"github.com/supabase/auth/internal/storage"
+	"github.com/supabase/auth/internal/utilities"
+	"golang.org/x/sync/singleflight"
+)
+
+// audienceConfigs resolves the configuration of audiences with overlays,
+// from GOTRUE_AUDIENCES_FILE and the audience_configs table. Overlays in
+// the database are cached for GOTRUE_AUDIENCES_CACHE_TTL.
+//
+// Lookups read an immutable snapshot without locking. A stale snapshot is
+// replaced by a single reload at a time, which the other lookups wait for.
+type audienceConfigs struct {
+	snapshot atomic.Pointer[audienceSnapshot]
+
+	// generation is incremented when the overlays are changed, so that
+	// snapshots loaded before are stale.
+	generation atomic.Uint64
+
+	reloads singleflight.Group
+}
+
+type audienceSnapshot struct {
+	generation uint64
+	loadedAt   time.Time
+
+	// dbOverlays are kept from the last successful load, in case the
+	// database is unavailable.
+	dbOverlays map[string]conf.AudienceConfiguration
+
+	byAud  map[string]*conf.GlobalConfiguration
+	byHost map[string]string
+}
+
+// invalidate makes the next lookup reload the overlays.
+func (c *audienceConfigs) invalidate() {
+	c.generation.Add(1)
+}
+
+// lookup returns the configuration of the audience, or nil if it has no
+// overlay.
+func (c *audienceConfigs) lookup(ctx context.Context, a *API, aud string) *conf.GlobalConfiguration {
+	return c.load(ctx, a).byAud[aud]
+}
+
+// lookupHost returns the audience selected by the host, if any.
+func (c *audienceConfigs) lookupHost(ctx context.Context, a *API, host string) string {
+	return c.load(ctx, a).byHost[conf.NormalizeAudienceHost(host)]
+}
+
+// load returns the current snapshot, reloading it if it is stale.
+func (c *audienceConfigs) load(ctx context.Context, a *API) *audienceSnapshot {
+	if snapshot := c.snapshot.Load(); c.isFresh(a.config, snapshot) {
+		return snapshot
+	}
+
+	// the reload is shared with other lookups, so it shouldn't be
+	// cancelled with this request
+	ctx = context.WithoutCancel(ctx)
+	snapshot, _, _ := c.reloads.Do("reload", func() (interface{}, error) {
+		return c.reload(ctx, a), nil
+	})
+
+	return snapshot.(*audienceSnapshot)
+}
+
+func (c *audienceConfigs) isFresh(config *conf.GlobalConfiguration, snapshot *audienceSnapshot) bool {
+	return snapshot != nil &&
+		snapshot.generation == c.generation.Load() &&
+		time.Since(snapshot.loadedAt) < config.Audiences.CacheTTL
+}
+
+func (c *audienceConfigs) reload(ctx context.Context, a *API) *audienceSnapshot {
+	config := a.config
+	previous := c.snapshot.Load()
+
+	snapshot := &audienceSnapshot{
+		generation: c.generation.Load(),
+		loadedAt:   time.Now(),
+	}
+	if previous != nil {
+		snapshot.dbOverlays = previous.dbOverlays
+	}
+
+	if a.db != nil {
+		dbOverlays, err := loadAudienceConfigOverlays(a.db.WithContext(ctx))
+		if err != nil {
+			logrus.WithError(err).Error("Unable to load audience configs, using the previous ones")
+		} else {
+			snapshot.dbOverlays = dbOverlays
+		}
+	}
+
+	overlays := make(map[string]conf.AudienceConfiguration, len(config.Audiences.Overlays)+len(snapshot.dbOverlays))
+	for aud, overlay := range config.Audiences.Overlays {
+		overlays[aud] = overlay
+	}
+	for aud, overlay := range snapshot.dbOverlays {
+		overlays[aud] = overlay
+	}
+
+	auds := make([]string, 0, len(overlays))
+	for aud := range overlays {
+		auds = append(auds, aud)
+	}
+	slices.Sort(auds)
+
+	snapshot.byAud = make(map[string]*conf.GlobalConfiguration, len(overlays))
+	snapshot.byHost = make(map[string]string)
+	for _, aud := range auds {
+		overlay := overlays[aud]
+
+		audConfig, err := config.WithAudience(&overlay)
+		if err != nil {
+			// overlays are validated when they're stored, but the
+			// global configuration may have changed since
+			logrus.WithError(err).WithField("aud", aud).Error("Ignoring invalid audience config")
+			continue
+		}
+		snapshot.byAud[aud] = audConfig
+
+		for _, host := range overlay.Hosts {
+			host = conf.NormalizeAudienceHost(host)
+			if other, ok := snapshot.byHost[host]; ok {
+				logrus.WithField("aud", aud).Errorf("Ignoring host %q of audience config, which is used by audience %q", host, other)
+				continue
+			}
+			snapshot.byHost[host] = aud
+		}
+	}
+
+	c.snapshot.Store(snapshot)
+	return snapshot
+}
+
+// loadAudienceConfigOverlays loads the overlays stored in the database.
+func loadAudienceConfigOverlays(db *storage.Connection) (map[string]conf.AudienceConfiguration, error) {
+	configs, err := models.FindAudienceConfigs(db)
+	if err != nil {
+		return nil, err
+	}
+
+	overlays := make(map[string]conf.AudienceConfiguration, len(configs))
+	for _, config := range configs {
+		overlay, err := parseAudienceConfig(config)
+		if err != nil {
+			logrus.WithError(err).WithField("aud", config.Aud).Error("Ignoring invalid audience config")
+			continue
+		}
+		overlays[config.Aud] = *overlay
+	}
+
+	return overlays, nil
+}
+
+func parseAudienceConfig(config *models.AudienceConfig) (*conf.AudienceConfiguration, error) {
+	data, err := json.Marshal(config.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	return conf.ParseAudienceConfiguration(data)
+}
+
+// requestConfig returns the configuration of the request, which has the
+// overlay of its audience applied, if any.
+func (a *API) requestConfig(ctx context.Context) *conf.GlobalConfiguration {
+	if config := getAudienceConfig(ctx); config != nil {
+		return config
+	}
+
+	return a.config
+}
+
+// requestHost returns the host the request was sent to.
+func requestHost(r *http.Request) string {
+	if host := r.Header.Get("X-Forwarded-Host"); host != "" {
+		return host
+	}
+
+	return r.Host
+}
+
+// loadAudienceConfig selects the configuration of the request by the
+// audience in the X-JWT-AUD header, by the signed audience of an email link
+// or, without either, by the host the request was sent to.
+func (a *API) loadAudienceConfig(w http.ResponseWriter, r *http.Request) (context.Context, error) {
+	ctx := r.Context()
+
+	if aud := r.Header.Get(audHeaderName); aud != "" {
+		return a.withAudienceOverlay(ctx, aud), nil
+	}
+
+	aud := a.linkAudience(r)
+	if aud == "" {
+		aud = a.audiences.lookupHost(ctx, a, requestHost(r))
+		if aud == "" {
+			return ctx, nil
+		}
+	}
+
+	return a.withAudienceOverlay(withAudience(ctx, aud), aud), nil
+}
+
+// linkAudience returns the audience of an email link, if it has one with a
+// valid signature.
+func (a *API) linkAudience(r *http.Request) string {
+	query := r.URL.Query()
+
+	aud := query.Get("aud")
+	if aud == "" {
+		return ""
+	}
+
+	if !crypto.VerifyAudienceSignature(a.config.JWT.Secret, aud, query.Get("token"), query.Get("aud_signature")) {
+		observability.GetLogEntry(r).Entry.WithField("aud", aud).Warn("Ignoring audience of email link with an invalid signature")
+		return ""
+	}
+
+	return aud
+}
+
+// withAudienceOverlay selects the configuration of the audience, if it has
+// an overlay.
+func (a *API) withAudienceOverlay(ctx context.Context, aud string) context.Context {
+	if config := a.audiences.lookup(ctx, a, aud); config != nil {
+		return withAudienceConfig(ctx, config)
+	}
+
+	return ctx
+}
+
+// loadTokenAudienceConfig selects the configuration of the audience of the
+// access token, for requests that didn't select one otherwise.
+func (a *API) loadTokenAudienceConfig(ctx context.Context, r *http.Request) context.Context {
+	if r.Header.Get(audHeaderName) != "" || getAudience(ctx) != "" {
+		return ctx
+	}
+
+	claims := getClaims(ctx)
+	if claims == nil {
+		return ctx
+	}
+
+	aud, _ := claims.GetAudience()
+	if len(aud) == 0 || aud[0] == "" {
+		return ctx
+	}
+
+	return a.withAudienceOverlay(ctx, aud[0])
+}
+
+// AdminListAudienceConfigsResponse is the response struct from the
+// adminAudienceConfigs endpoint.
+type AdminListAudienceConfigsResponse struct {
+	Audiences []*models.AudienceConfig `json:"audiences"`
+}
+
+// adminAudienceConfigs lists the overlays stored in the database. Overlays
+// in GOTRUE_AUDIENCES_FILE aren't included.
+func (a *API) adminAudienceConfigs(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	configs, err := models.FindAudienceConfigs(db)
+	if err != nil {
+		return apierrors.NewInternalServerError("Database error finding audience configs").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, AdminListAudienceConfigsResponse{
+		Audiences: configs,
+	})
+}
+
+// adminAudienceConfigGet returns the overlay of an audience stored in the
+// database.
+func (a *API) adminAudienceConfigGet(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	config, err := models.FindAudienceConfigByAud(db, chi.URLParam(r, "aud"))
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return apierrors.NewNotFoundError(apierrors.ErrorCodeAudienceConfigNotFound, "Audience config not found")
+		}
+		return apierrors.NewInternalServerError("Database error finding audience config").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, config)
+}
+
+// adminAudienceConfigUpdate creates or replaces the overlay of an audience,
+// which takes effect on other instances within GOTRUE_AUDIENCES_CACHE_TTL.
+func (a *API) adminAudienceConfigUpdate(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	adminUser := getAdminUser(ctx)
+	aud := chi.URLParam(r, "aud")
+
+	if strings.TrimSpace(aud) == "" {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Audience is required")
+	}
+
+	body, err := utilities.GetBodyBytes(r)
+	if err != nil {
+		return apierrors.NewInternalServerError("Could not read body into byte slice").WithInternalError(err)
+	}
+
+	overlay, err := conf.ParseAudienceConfiguration(body)
+	if err != nil {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeBadJSON, "Could not parse audience config: %v", err)
+	}
+
+	if _, err := a.config.WithAudience(overlay); err != nil {
+		return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeValidationFailed, "Invalid audience config: %v", err)
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeBadJSON, "Could not parse audience config: %v", err)
+	}
+
+	var config *models.AudienceConfig
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		if terr := checkAudienceHosts(tx, a.config, aud, overlay.Hosts); terr != nil {
+			return terr
+		}
+
+		existing, terr := models.FindAudienceConfigByAud(tx, aud)
+		switch {
+		case terr == nil:
+			config = existing
+			config.Config = raw
+			if terr := tx.UpdateOnly(config, "config", "updated_at"); terr != nil {
+				return terr
+			}
+		case models.IsNotFoundError(terr):
+			config = models.NewAudienceConfig(aud, raw)
+			if terr := tx.Create(config); terr != nil {
+				return terr
+			}
+		default:
+			return terr
+		}
+
+		return models.NewAuditLogEntry(r, tx, adminUser, models.AudienceConfigUpdatedAction, "", map[string]interface{}{
+			"aud": aud,
+		})
+	}); err != nil {
+		if _, ok := err.(*HTTPError); ok {
+			return err
+		}
+		return apierrors.NewInternalServerError("Database error updating audience config").WithInternalError(err)
+	}
+
+	a.audiences.invalidate()
+
+	return sendJSON(w, http.StatusOK, config)
+}
+
+// checkAudienceHosts checks that no other audience selects any of the
+// hosts.
+func checkAudienceHosts(tx *storage.Connection, config *conf.GlobalConfiguration, aud string, hosts []string) error {
+	if len(hosts) == 0 {
+		return nil
+	}
+
+	used := make(map[string]string)
+	for other, overlay := range config.Audiences.Overlays {
+		for _, host := range overlay.Hosts {
+			used[conf.NormalizeAudienceHost(host)] = other
+		}
+	}
+
+	configs, err := models.FindAudienceConfigs(tx)
+	if err != nil {
+		return err
+	}
+	for _, c := range configs {
+		overlay, err := parseAudienceConfig(c)
+		if err != nil {
+			continue
+		}
+		for _, host := range overlay.Hosts {
+			used[conf.NormalizeAudienceHost(host)] = c.Aud
+		}
+	}
+
+	for _, host := range hosts {
+		if other, ok := used[conf.NormalizeAudienceHost(host)]; ok && other != aud {
+			return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeValidationFailed, "Host %q is used by audience %q", host, other)
+		}
+	}
+
+	return nil
+}
+
+// adminAudienceConfigDelete deletes the overlay of an audience from the
+// database. An overlay in GOTRUE_AUDIENCES_FILE applies again afterwards.
+func (a *API) adminAudienceConfigDelete(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	adminUser := getAdminUser(ctx)
+
+	config, err := models.FindAudienceConfigByAud(db, chi.URLParam(r, "aud"))
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return apierrors.NewNotFoundError(apierrors.ErrorCodeAudienceConfigNotFound, "Audience config not found")
+		}
+		return apierrors.NewInternalServerError("Database error finding audience config").WithInternalError(err)
+	}
+
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		if terr := tx.Destroy(config); terr != nil {
+			return terr
+		}
+
+		return models.NewAuditLogEntry(r, tx, adminUser, models.AudienceConfigDeletedAction, "", map[string]interface{}{
+			"aud": config.Aud,
+		})
+	}); err != nil {
+		return apierrors.NewInternalServerError("Database error deleting audience config").WithInternalError(err)
+	}
+
+	a.audiences.invalidate()
+
+	return sendJSON(w, http.StatusOK, config)
+}
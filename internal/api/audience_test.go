@@ -0,0 +1,261 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/crypto"
+	"github.com/supabase/auth/internal/models"
+)
+
+func TestLoadAudienceConfig(t *testing.T) {
+	config := &conf.GlobalConfiguration{
+		SiteURL: "https://app.example.com",
+		JWT:     conf.JWTConfiguration{Aud: "authenticated", Secret: "secret"},
+		Audiences: conf.AudiencesConfiguration{
+			Overlays: map[string]conf.AudienceConfiguration{
+				"brand": {
+					Hosts:   []string{"brand.example.com"},
+					SiteURL: "https://brand.example.com",
+				},
+			},
+		},
+	}
+	a := &API{config: config, audiences: &audienceConfigs{}}
+
+	signature := crypto.GenerateAudienceSignature("secret", "brand", "token")
+
+	cases := []struct {
+		desc    string
+		url     string
+		host    string
+		headers map[string]string
+		aud     string
+		siteURL string
+	}{
+		{
+			desc:    "Default",
+			host:    "auth.example.com",
+			aud:     "authenticated",
+			siteURL: "https://app.example.com",
+		},
+		{
+			desc:    "Host",
+			host:    "Brand.example.com:443",
+			aud:     "brand",
+			siteURL: "https://brand.example.com",
+		},
+		{
+			desc:    "Forwarded host",
+			host:    "auth.internal",
+			headers: map[string]string{"X-Forwarded-Host": "brand.example.com"},
+			aud:     "brand",
+			siteURL: "https://brand.example.com",
+		},
+		{
+			desc:    "Header",
+			host:    "auth.example.com",
+			headers: map[string]string{audHeaderName: "brand"},
+			aud:     "brand",
+			siteURL: "https://brand.example.com",
+		},
+		{
+			desc:    "Header takes precedence over host",
+			host:    "brand.example.com",
+			headers: map[string]string{audHeaderName: "other"},
+			aud:     "other",
+			siteURL: "https://app.example.com",
+		},
+		{
+			desc:    "Signed link",
+			url:     "/verify?token=token&type=signup&aud=brand&aud_signature=" + signature,
+			host:    "auth.example.com",
+			aud:     "brand",
+			siteURL: "https://brand.example.com",
+		},
+		{
+			desc:    "Link signed for another token",
+			url:     "/verify?token=other&type=signup&aud=brand&aud_signature=" + signature,
+			host:    "auth.example.com",
+			aud:     "authenticated",
+			siteURL: "https://app.example.com",
+		},
+		{
+			desc:    "Unsigned link",
+			url:     "/verify?token=token&type=signup&aud=brand",
+			host:    "auth.example.com",
+			aud:     "authenticated",
+			siteURL: "https://app.example.com",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			url := c.url
+			if url == "" {
+				url = "/settings"
+			}
+
+			req := httptest.NewRequest(http.MethodGet, url, nil)
+			req.Host = c.host
+			for name, value := range c.headers {
+				req.Header.Set(name, value)
+			}
+
+			ctx, err := a.loadAudienceConfig(httptest.NewRecorder(), req)
+			require.NoError(t, err)
+
+			require.Equal(t, c.aud, a.requestAud(ctx, req))
+			require.Equal(t, c.siteURL, a.requestConfig(ctx).SiteURL)
+		})
+	}
+}
+
+type AudienceTestSuite struct {
+	suite.Suite
+	API      *API
+	Config   *conf.GlobalConfiguration
+	AdminJWT string
+}
+
+func TestAudience(t *testing.T) {
+	api, config, err := setupAPIForTest()
+	require.NoError(t, err)
+
+	ts := &AudienceTestSuite{
+		API:    api,
+		Config: config,
+	}
+	defer api.db.Close()
+
+	suite.Run(t, ts)
+}
+
+func (ts *AudienceTestSuite) SetupTest() {
+	models.TruncateAll(ts.API.db)
+	ts.API.audiences.invalidate()
+
+	claims := &AccessTokenClaims{
+		Role: "supabase_admin",
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(ts.Config.JWT.Secret))
+	require.NoError(ts.T(), err, "Error generating admin jwt")
+
+	ts.AdminJWT = token
+}
+
+func (ts *AudienceTestSuite) request(method, url, host string, body interface{}) *httptest.ResponseRecorder {
+	var buffer bytes.Buffer
+	if body != nil {
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(body))
+	}
+
+	req := httptest.NewRequest(method, url, &buffer)
+	req.Host = host
+	req.Header.Set("Authorization", "Bearer "+ts.AdminJWT)
+	w := httptest.NewRecorder()
+
+	ts.API.handler.ServeHTTP(w, req)
+
+	return w
+}
+
+func (ts *AudienceTestSuite) settings(host string) *Settings {
+	w := ts.request(http.MethodGet, "http://localhost/settings", host, nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	settings := &Settings{}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(settings))
+	return settings
+}
+
+func (ts *AudienceTestSuite) TestAudienceConfigs() {
+	require.False(ts.T(), ts.settings("brand.example.com").DisableSignup)
+
+	w := ts.request(http.MethodPut, "http://localhost/admin/audiences/brand", "localhost", map[string]interface{}{
+		"hosts":          []string{"brand.example.com"},
+		"disable_signup": true,
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+
+	config := &models.AudienceConfig{}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(config))
+	require.Equal(ts.T(), "brand", config.Aud)
+
+	require.True(ts.T(), ts.settings("brand.example.com").DisableSignup)
+	require.False(ts.T(), ts.settings("localhost").DisableSignup)
+
+	w = ts.request(http.MethodGet, "http://localhost/admin/audiences", "localhost", nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+	list := AdminListAudienceConfigsResponse{}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&list))
+	require.Len(ts.T(), list.Audiences, 1)
+
+	w = ts.request(http.MethodDelete, "http://localhost/admin/audiences/brand", "localhost", nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	require.False(ts.T(), ts.settings("brand.example.com").DisableSignup)
+
+	w = ts.request(http.MethodGet, "http://localhost/admin/audiences/brand", "localhost", nil)
+	require.Equal(ts.T(), http.StatusNotFound, w.Code)
+}
+
+func (ts *AudienceTestSuite) TestAudienceConfigOAuthState() {
+	w := ts.request(http.MethodPut, "http://localhost/admin/audiences/brand", "localhost", map[string]interface{}{
+		"site_url": "https://brand.example.com",
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/authorize?provider=github", nil)
+	req.Header.Set(audHeaderName, "brand")
+	ctx, err := ts.API.loadAudienceConfig(httptest.NewRecorder(), req)
+	require.NoError(ts.T(), err)
+
+	authURL, err := ts.API.GetExternalProviderRedirectURL(httptest.NewRecorder(), req.WithContext(ctx), nil)
+	require.NoError(ts.T(), err)
+	u, err := url.Parse(authURL)
+	require.NoError(ts.T(), err)
+
+	// the callback has no X-JWT-AUD header
+	req = httptest.NewRequest(http.MethodGet, "http://localhost/callback?state="+u.Query().Get("state"), nil)
+	ctx, err = ts.API.loadAudienceConfig(httptest.NewRecorder(), req)
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), ts.Config.SiteURL, ts.API.requestConfig(ctx).SiteURL)
+
+	ctx, err = ts.API.loadExternalState(ctx, req)
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), "brand", ts.API.requestAud(ctx, req))
+	require.Equal(ts.T(), "https://brand.example.com", ts.API.requestConfig(ctx).SiteURL)
+}
+
+func (ts *AudienceTestSuite) TestAudienceConfigsInvalid() {
+	w := ts.request(http.MethodPut, "http://localhost/admin/audiences/brand", "localhost", map[string]interface{}{
+		"site_ulr": "https://brand.example.com",
+	})
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+
+	w = ts.request(http.MethodPut, "http://localhost/admin/audiences/brand", "localhost", map[string]interface{}{
+		"external": map[string]interface{}{
+			"unknown": map[string]interface{}{"enabled": true},
+		},
+	})
+	require.Equal(ts.T(), http.StatusUnprocessableEntity, w.Code)
+
+	w = ts.request(http.MethodPut, "http://localhost/admin/audiences/brand", "localhost", map[string]interface{}{
+		"hosts": []string{"brand.example.com"},
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	w = ts.request(http.MethodPut, "http://localhost/admin/audiences/other", "localhost", map[string]interface{}{
+		"hosts": []string{"Brand.example.com"},
+	})
+	require.Equal(ts.T(), http.StatusUnprocessableEntity, w.Code)
+}
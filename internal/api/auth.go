@@ -25,6 +25,7 @@ func (a *API) requireAuthentication(w http.ResponseWriter, r *http.Request) (con
 	if err != nil {
 		return ctx, err
 	}
+	ctx = a.loadTokenAudienceConfig(ctx, r)
 
 	ctx, err = a.maybeLoadUserOrSession(ctx)
 	if err != nil {
@@ -53,6 +54,7 @@ func (a *API) requireAdmin(ctx context.Context) (context.Context, error) {
 
 	if isStringInSlice(claims.Role, adminRoles) {
 		// successful authentication
+		ctx = withAdminPermissions(ctx, adminPermissionsFromClaims(claims, a.config.JWT.RestrictUserAdminPermissions))
 		return withAdminUser(ctx, &models.User{Role: claims.Role, Email: storage.NullString(claims.Role)}), nil
 	}
 
@@ -5,6 +5,7 @@ import (
 	"net/url"
 
 	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/supabase/auth/internal/conf"
 	"github.com/supabase/auth/internal/models"
 )
 
@@ -31,6 +32,13 @@ const (
 	ssoProviderKey          = contextKey("sso_provider")
 	externalHostKey         = contextKey("external_host")
 	flowStateKey            = contextKey("flow_state_id")
+	organizationKey         = contextKey("organization")
+	adminPermissionsKey     = contextKey("admin_permissions")
+	adminAPIKeyKey          = contextKey("admin_api_key")
+	userImportJobKey        = contextKey("user_import_job")
+	audienceKey             = contextKey("audience")
+	audienceConfigKey       = contextKey("audience_config")
+	oidcNonceKey            = contextKey("oidc_nonce")
 )
 
 // withToken adds the JWT token to the context.
@@ -142,6 +150,21 @@ func getFlowStateID(ctx context.Context) string {
 	return obj.(string)
 }
 
+// withOIDCNonce adds the nonce of an OIDC SSO sign in to the context.
+func withOIDCNonce(ctx context.Context, nonce string) context.Context {
+	return context.WithValue(ctx, oidcNonceKey, nonce)
+}
+
+// getOIDCNonce reads the nonce of an OIDC SSO sign in from the context.
+func getOIDCNonce(ctx context.Context) string {
+	obj := ctx.Value(oidcNonceKey)
+	if obj == nil {
+		return ""
+	}
+
+	return obj.(string)
+}
+
 func getInviteToken(ctx context.Context) string {
 	obj := ctx.Value(inviteTokenKey)
 	if obj == nil {
@@ -230,6 +253,42 @@ func getSSOProvider(ctx context.Context) *models.SSOProvider {
 	return obj.(*models.SSOProvider)
 }
 
+func withOrganization(ctx context.Context, organization *models.Organization) context.Context {
+	return context.WithValue(ctx, organizationKey, organization)
+}
+
+func getOrganization(ctx context.Context) *models.Organization {
+	obj := ctx.Value(organizationKey)
+	if obj == nil {
+		return nil
+	}
+	return obj.(*models.Organization)
+}
+
+func withAdminAPIKey(ctx context.Context, k *models.AdminAPIKey) context.Context {
+	return context.WithValue(ctx, adminAPIKeyKey, k)
+}
+
+func getAdminAPIKey(ctx context.Context) *models.AdminAPIKey {
+	obj := ctx.Value(adminAPIKeyKey)
+	if obj == nil {
+		return nil
+	}
+	return obj.(*models.AdminAPIKey)
+}
+
+func withUserImportJob(ctx context.Context, job *models.UserImportJob) context.Context {
+	return context.WithValue(ctx, userImportJobKey, job)
+}
+
+func getUserImportJob(ctx context.Context) *models.UserImportJob {
+	obj := ctx.Value(userImportJobKey)
+	if obj == nil {
+		return nil
+	}
+	return obj.(*models.UserImportJob)
+}
+
 func withExternalHost(ctx context.Context, u *url.URL) context.Context {
 	return context.WithValue(ctx, externalHostKey, u)
 }
@@ -241,3 +300,31 @@ func getExternalHost(ctx context.Context) *url.URL {
 	}
 	return obj.(*url.URL)
 }
+
+// withAudience adds the audience selected by the host of the request to
+// the context.
+func withAudience(ctx context.Context, aud string) context.Context {
+	return context.WithValue(ctx, audienceKey, aud)
+}
+
+func getAudience(ctx context.Context) string {
+	obj := ctx.Value(audienceKey)
+	if obj == nil {
+		return ""
+	}
+	return obj.(string)
+}
+
+// withAudienceConfig adds the configuration of the audience of the request
+// to the context.
+func withAudienceConfig(ctx context.Context, config *conf.GlobalConfiguration) context.Context {
+	return context.WithValue(ctx, audienceConfigKey, config)
+}
+
+func getAudienceConfig(ctx context.Context) *conf.GlobalConfiguration {
+	obj := ctx.Value(audienceConfigKey)
+	if obj == nil {
+		return nil
+	}
+	return obj.(*conf.GlobalConfiguration)
+}
@@ -31,15 +31,22 @@ func HandleResponseError_YuZhaGai(err error, w http.ResponseWriter, r *http.Requ
 		log.Info("Weak password error: ", e.Error())
 
 		// Get localized message
-		localizedMessage := i18n.GetMessage(userLang, "weak_password")
+		localizedMessage := e.LocalizedMessage(userLang)
+		if localizedMessage == "" {
+			localizedMessage = i18n.GetMessage(userLang, "weak_password")
+		}
 
 		if apiVersion.Compare(APIVersion20240101) >= 0 {
 			var output struct {
 				HTTPErrorResponse20240101
+				Payload struct {
+					Reasons []string `json:"reasons,omitempty"`
+				} `json:"weak_password,omitempty"`
 			}
 
 			output.Code = apierrors.ErrorCodeWeakPassword
 			output.Message = localizedMessage
+			output.Payload.Reasons = e.Reasons
 
 			if jsonErr := sendJSON(w, http.StatusUnprocessableEntity, output); jsonErr != nil && jsonErr != context.DeadlineExceeded {
 				log.WithError(jsonErr).Warn("Failed to send JSON on ResponseWriter")
@@ -48,11 +55,15 @@ func HandleResponseError_YuZhaGai(err error, w http.ResponseWriter, r *http.Requ
 		} else {
 			var output struct {
 				HTTPError
+				Payload struct {
+					Reasons []string `json:"reasons,omitempty"`
+				} `json:"weak_password,omitempty"`
 			}
 
 			output.HTTPStatus = http.StatusUnprocessableEntity
 			output.ErrorCode = apierrors.ErrorCodeWeakPassword
 			output.Message = localizedMessage
+			output.Payload.Reasons = e.Reasons
 
 			w.Header().Set("x-sb-error-code", output.ErrorCode)
 
@@ -30,6 +30,13 @@ type ExternalProviderClaims struct {
 	Referrer        string `json:"referrer,omitempty"`
 	FlowStateID     string `json:"flow_state_id"`
 	LinkingTargetID string `json:"linking_target_id,omitempty"`
+
+	// Aud is the audience of the request starting the flow, whose
+	// configuration is restored in the callback.
+	Aud string `json:"request_aud,omitempty"`
+
+	// Nonce is sent to OIDC SSO providers and must be in their ID token.
+	Nonce string `json:"nonce,omitempty"`
 }
 
 // ExternalProviderRedirect redirects the request to the oauth provider
@@ -46,7 +53,7 @@ func (a *API) ExternalProviderRedirect(w http.ResponseWriter, r *http.Request) e
 func (a *API) GetExternalProviderRedirectURL(w http.ResponseWriter, r *http.Request, linkingTargetUser *models.User) (string, error) {
 	ctx := r.Context()
 	db := a.db.WithContext(ctx)
-	config := a.config
+	config := a.requestConfig(ctx)
 
 	query := r.URL.Query()
 	providerType := query.Get("provider")
@@ -54,6 +61,11 @@ func (a *API) GetExternalProviderRedirectURL(w http.ResponseWriter, r *http.Requ
 	codeChallenge := query.Get("code_challenge")
 	codeChallengeMethod := query.Get("code_challenge_method")
 
+	if strings.HasPrefix(strings.ToLower(providerType), ssoOIDCProviderPrefix) {
+		// OIDC SSO providers are only signed in with through /sso
+		return "", apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Unsupported provider: %s", providerType)
+	}
+
 	p, err := a.Provider(ctx, providerType, scopes)
 	if err != nil {
 		return "", apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Unsupported provider: %+v", err).WithInternalError(err)
@@ -101,6 +113,10 @@ func (a *API) GetExternalProviderRedirectURL(w http.ResponseWriter, r *http.Requ
 		FlowStateID: flowStateID,
 	}
 
+	if aud := a.requestAud(ctx, r); aud != a.config.JWT.Aud {
+		claims.Aud = aud
+	}
+
 	if linkingTargetUser != nil {
 		// this means that the user is performing manual linking
 		claims.LinkingTargetID = linkingTargetUser.ID.String()
@@ -174,7 +190,7 @@ func (a *API) internalExternalProviderCallback(w http.ResponseWriter, r *http.Re
 	}
 
 	userData := data.userData
-	if len(userData.Emails) <= 0 {
+	if len(userData.Emails) <= 0 && !provider.IsEmailOptional(providerType) {
 		return apierrors.NewInternalServerError("Error getting user email from external provider")
 	}
 	userData.Metadata.EmailVerified = false
@@ -228,6 +244,9 @@ func (a *API) internalExternalProviderCallback(w http.ResponseWriter, r *http.Re
 			if user, terr = a.createAccountFromExternalIdentity(tx, r, userData, providerType); terr != nil {
 				return terr
 			}
+			if grantParams.OrganizationID, terr = joinSSOProviderOrganization(tx, user, providerType); terr != nil {
+				return terr
+			}
 		}
 		if flowState != nil {
 			// This means that the callback is using PKCE
@@ -239,7 +258,12 @@ func (a *API) internalExternalProviderCallback(w http.ResponseWriter, r *http.Re
 
 			terr = tx.Update(flowState)
 		} else {
-			token, terr = a.issueRefreshToken(r, tx, user, models.OAuth, grantParams)
+			authMethod := models.OAuth
+			if strings.HasPrefix(providerType, ssoOIDCProviderPrefix) {
+				authMethod = models.SSOOIDC
+			}
+
+			token, terr = a.issueRefreshToken(r, tx, user, authMethod, grantParams)
 		}
 
 		if terr != nil {
@@ -280,7 +304,7 @@ func (a *API) internalExternalProviderCallback(w http.ResponseWriter, r *http.Re
 func (a *API) createAccountFromExternalIdentity(tx *storage.Connection, r *http.Request, userData *provider.UserProvidedData, providerType string) (*models.User, error) {
 	ctx := r.Context()
 	aud := a.requestAud(ctx, r)
-	config := a.config
+	config := a.requestConfig(ctx)
 
 	var user *models.User
 	var identity *models.Identity
@@ -372,6 +396,9 @@ func (a *API) createAccountFromExternalIdentity(tx *storage.Connection, r *http.
 
 	// TODO(hf): Expand this boolean with all providers that may not have emails (like X/Twitter, Discord).
 	hasEmails := providerType != "web3" // intentionally not using len(userData.Emails) != 0 for better backward compatibility control
+	if provider.IsEmailOptional(providerType) {
+		hasEmails = len(userData.Emails) != 0
+	}
 
 	if hasEmails && !user.IsConfirmed() {
 		// The user may have other unconfirmed email + password
@@ -498,7 +525,7 @@ func (a *API) loadExternalState(ctx context.Context, r *http.Request) (context.C
 	if state == "" {
 		return ctx, apierrors.NewBadRequestError(apierrors.ErrorCodeBadOAuthCallback, "OAuth state parameter missing")
 	}
-	config := a.config
+	config := a.requestConfig(ctx)
 	claims := ExternalProviderClaims{}
 	p := jwt.NewParser(jwt.WithValidMethods(config.JWT.ValidMethods))
 	_, err := p.ParseWithClaims(state, &claims, func(token *jwt.Token) (interface{}, error) {
@@ -521,6 +548,11 @@ func (a *API) loadExternalState(ctx context.Context, r *http.Request) (context.C
 	if claims.Provider == "" {
 		return ctx, apierrors.NewBadRequestError(apierrors.ErrorCodeBadOAuthState, "OAuth callback with invalid state (missing provider)")
 	}
+	if claims.Aud != "" {
+		// the callback doesn't carry the audience of the request that
+		// started the flow
+		ctx = a.withAudienceOverlay(withAudience(ctx, claims.Aud), claims.Aud)
+	}
 	if claims.InviteToken != "" {
 		ctx = withInviteToken(ctx, claims.InviteToken)
 	}
@@ -530,6 +562,9 @@ func (a *API) loadExternalState(ctx context.Context, r *http.Request) (context.C
 	if claims.FlowStateID != "" {
 		ctx = withFlowStateID(ctx, claims.FlowStateID)
 	}
+	if claims.Nonce != "" {
+		ctx = withOIDCNonce(ctx, claims.Nonce)
+	}
 	if claims.LinkingTargetID != "" {
 		linkingTargetUserID, err := uuid.FromString(claims.LinkingTargetID)
 		if err != nil {
@@ -550,7 +585,7 @@ func (a *API) loadExternalState(ctx context.Context, r *http.Request) (context.C
 
 // Provider returns a Provider interface for the given name.
 func (a *API) Provider(ctx context.Context, name string, scopes string) (provider.Provider, error) {
-	config := a.config
+	config := a.requestConfig(ctx)
 	name = strings.ToLower(name)
 
 	switch name {
@@ -560,10 +595,14 @@ func (a *API) Provider(ctx context.Context, name string, scopes string) (provide
 		return provider.NewAzureProvider(config.External.Azure, scopes)
 	case "bitbucket":
 		return provider.NewBitbucketProvider(config.External.Bitbucket)
+	case "dingtalk":
+		return provider.NewDingTalkProvider(config.External.DingTalk, scopes)
 	case "discord":
 		return provider.NewDiscordProvider(config.External.Discord, scopes)
 	case "facebook":
 		return provider.NewFacebookProvider(config.External.Facebook, scopes)
+	case "feishu":
+		return provider.NewFeishuProvider(config.External.Feishu, scopes)
 	case "figma":
 		return provider.NewFigmaProvider(config.External.Figma, scopes)
 	case "fly":
@@ -578,12 +617,16 @@ func (a *API) Provider(ctx context.Context, name string, scopes string) (provide
 		return provider.NewKakaoProvider(config.External.Kakao, scopes)
 	case "keycloak":
 		return provider.NewKeycloakProvider(config.External.Keycloak, scopes)
+	case "lark":
+		return provider.NewLarkProvider(config.External.Lark, scopes)
 	case "linkedin":
 		return provider.NewLinkedinProvider(config.External.Linkedin, scopes)
 	case "linkedin_oidc":
 		return provider.NewLinkedinOIDCProvider(config.External.LinkedinOIDC, scopes)
 	case "notion":
 		return provider.NewNotionProvider(config.External.Notion)
+	case "qq":
+		return provider.NewQQProvider(config.External.QQ, scopes)
 	case "spotify":
 		return provider.NewSpotifyProvider(config.External.Spotify, scopes)
 	case "slack":
@@ -596,11 +639,23 @@ func (a *API) Provider(ctx context.Context, name string, scopes string) (provide
 		return provider.NewTwitterProvider(config.External.Twitter, scopes)
 	case "vercel_marketplace":
 		return provider.NewVercelMarketplaceProvider(config.External.VercelMarketplace, scopes)
+	case "wechat":
+		return provider.NewWeChatProvider(config.External.WeChat, scopes)
+	case "wechat_mp":
+		return provider.NewWeChatMPProvider(config.External.WeChatMP, scopes)
+	case "weibo":
+		return provider.NewWeiboProvider(config.External.Weibo, scopes)
 	case "workos":
 		return provider.NewWorkOSProvider(config.External.WorkOS)
 	case "zoom":
 		return provider.NewZoomProvider(config.External.Zoom)
 	default:
+		if custom, ok := config.External.CustomProvider(name); ok {
+			return provider.NewCustomProvider(ctx, *custom, scopes)
+		}
+		if strings.HasPrefix(name, ssoOIDCProviderPrefix) {
+			return a.loadSSOOIDCProvider(ctx, name)
+		}
 		return nil, fmt.Errorf("Provider %s could not be found", name)
 	}
 }
@@ -678,7 +733,7 @@ func getErrorQueryString(err error, errorID string, log logrus.FieldLogger, q ur
 
 func (a *API) getExternalRedirectURL(r *http.Request) string {
 	ctx := r.Context()
-	config := a.config
+	config := a.requestConfig(ctx)
 	if config.External.RedirectURL != "" {
 		return config.External.RedirectURL
 	}
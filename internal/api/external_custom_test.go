@@ -0,0 +1,112 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/supabase/auth/internal/conf"
+)
+
+func CustomTestSignupSetup(ts *ExternalTestSuite, tokenCount *int, userCount *int, code string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/oauth/token":
+			*tokenCount++
+			ts.Equal(code, r.FormValue("code"))
+			ts.Equal("authorization_code", r.FormValue("grant_type"))
+			ts.Equal("https://example.com/callback", r.FormValue("redirect_uri"))
+
+			w.Header().Add("Content-Type", "application/json")
+			fmt.Fprint(w, `{"access_token":"custom_token","token_type":"bearer","expires_in":3600}`)
+		case "/api/me":
+			*userCount++
+			ts.Equal("Bearer custom_token", r.Header.Get("Authorization"))
+
+			w.Header().Add("Content-Type", "application/json")
+			fmt.Fprint(w, `{"user":{"id":4321,"display_name":"Custom Test","photo":"http://example.com/avatar","mail":"custom@example.com","mail_verified":true}}`)
+		default:
+			w.WriteHeader(500)
+			ts.Fail("unknown custom oauth call %s", r.URL.Path)
+		}
+	}))
+
+	ts.Config.External.Custom = conf.CustomOAuthProviders{
+		"corp": {
+			OAuthProviderConfiguration: conf.OAuthProviderConfiguration{
+				Enabled:     true,
+				ClientID:    []string{"custom_client"},
+				Secret:      "custom_secret",
+				RedirectURI: "https://example.com/callback",
+			},
+			AuthorizationURL: server.URL + "/oauth/authorize",
+			TokenURL:         server.URL + "/oauth/token",
+			UserinfoURL:      server.URL + "/api/me",
+			Scopes:           []string{"profile"},
+			ClaimMapping: map[string]string{
+				"sub":            "$.user.id",
+				"name":           "$.user.display_name",
+				"picture":        "$.user.photo",
+				"email":          "$.user.mail",
+				"email_verified": "$.user.mail_verified",
+			},
+		},
+	}
+
+	return server
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalCustom() {
+	server := CustomTestSignupSetup(ts, new(int), new(int), "")
+	defer server.Close()
+	defer func() { ts.Config.External.Custom = nil }()
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/authorize?provider=custom:corp", nil)
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	ts.Require().Equal(http.StatusFound, w.Code)
+	u, err := url.Parse(w.Header().Get("Location"))
+	ts.Require().NoError(err, "redirect url parse failed")
+	ts.Equal(server.URL+"/oauth/authorize", u.Scheme+"://"+u.Host+u.Path)
+	q := u.Query()
+	ts.Equal("https://example.com/callback", q.Get("redirect_uri"))
+	ts.Equal("custom_client", q.Get("client_id"))
+	ts.Equal("code", q.Get("response_type"))
+	ts.Equal("profile", q.Get("scope"))
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalCustom_UnknownProvider() {
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/authorize?provider=custom:unknown", nil)
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	ts.Equal(http.StatusBadRequest, w.Code)
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalCustom_AuthorizationCode() {
+	ts.Config.DisableSignup = false
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := CustomTestSignupSetup(ts, &tokenCount, &userCount, code)
+	defer server.Close()
+	defer func() { ts.Config.External.Custom = nil }()
+
+	u := performAuthorization(ts, "custom:corp", code, "")
+
+	assertAuthorizationSuccess(ts, u, tokenCount, userCount, "custom@example.com", "Custom Test", "4321", "http://example.com/avatar")
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalCustomDisabled() {
+	server := CustomTestSignupSetup(ts, new(int), new(int), "")
+	defer server.Close()
+	defer func() { ts.Config.External.Custom = nil }()
+
+	custom := ts.Config.External.Custom["corp"]
+	custom.Enabled = false
+	ts.Config.External.Custom["corp"] = custom
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/authorize?provider=custom:corp", nil)
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	ts.Equal(http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+)
+
+func (ts *ExternalTestSuite) TestSignupExternalDingTalk() {
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/authorize?provider=dingtalk", nil)
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	ts.Require().Equal(http.StatusFound, w.Code)
+	u, err := url.Parse(w.Header().Get("Location"))
+	ts.Require().NoError(err, "redirect url parse failed")
+	q := u.Query()
+	ts.Equal(ts.Config.External.DingTalk.RedirectURI, q.Get("redirect_uri"))
+	ts.Equal(ts.Config.External.DingTalk.ClientID, []string{q.Get("client_id")})
+	ts.Equal("code", q.Get("response_type"))
+	ts.Equal("openid", q.Get("scope"))
+	ts.Equal("consent", q.Get("prompt"))
+}
+
+func DingTalkTestSignupSetup(ts *ExternalTestSuite, tokenCount *int, userCount *int, code string, email string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1.0/oauth2/userAccessToken":
+			*tokenCount++
+			var body map[string]string
+			ts.Require().NoError(json.NewDecoder(r.Body).Decode(&body))
+			ts.Equal(code, body["code"])
+			ts.Equal("authorization_code", body["grantType"])
+			ts.Equal(ts.Config.External.DingTalk.ClientID[0], body["clientId"])
+			w.Header().Add("Content-Type", "application/json")
+			fmt.Fprint(w, `{"accessToken":"dingtalk_token","refreshToken":"dingtalk_refresh","expireIn":7200}`)
+		case "/v1.0/contact/users/me":
+			*userCount++
+			ts.Equal("dingtalk_token", r.Header.Get("x-acs-dingtalk-access-token"))
+			w.Header().Add("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"nick":"DingTalk Test","avatarUrl":"http://example.com/avatar","mobile":"13800000000","stateCode":"86","openId":"openid123","unionId":"unionid123","email":%q}`, email)
+		default:
+			w.WriteHeader(500)
+			ts.Fail("unknown dingtalk oauth call %s", r.URL.Path)
+		}
+	}))
+
+	ts.Config.External.DingTalk.URL = server.URL
+
+	return server
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalDingTalk_AuthorizationCode() {
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := DingTalkTestSignupSetup(ts, &tokenCount, &userCount, code, "")
+	defer server.Close()
+
+	u := performAuthorization(ts, "dingtalk", code, "")
+	ts.Equal(1, tokenCount)
+	ts.Equal(1, userCount)
+
+	user := assertAuthorizationSuccessWithoutEmail(ts, u, "dingtalk", "unionid123", "DingTalk Test", "http://example.com/avatar")
+	ts.Equal("+8613800000000", user.UserMetaData["phone"])
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalDingTalkErrorWhenEmailUnverified() {
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := DingTalkTestSignupSetup(ts, &tokenCount, &userCount, code, "dingtalk@example.com")
+	defer server.Close()
+
+	u := performAuthorization(ts, "dingtalk", code, "")
+
+	assertAuthorizationFailure(ts, u, "Unverified email with dingtalk. A confirmation email has been sent to your dingtalk email", "access_denied", "")
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+)
+
+func (ts *ExternalTestSuite) TestSignupExternalFeishu() {
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/authorize?provider=feishu", nil)
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	ts.Require().Equal(http.StatusFound, w.Code)
+	u, err := url.Parse(w.Header().Get("Location"))
+	ts.Require().NoError(err, "redirect url parse failed")
+	ts.Equal("accounts.feishu.cn", u.Host)
+	q := u.Query()
+	ts.Equal(ts.Config.External.Feishu.RedirectURI, q.Get("redirect_uri"))
+	ts.Equal(ts.Config.External.Feishu.ClientID, []string{q.Get("client_id")})
+	ts.Equal("code", q.Get("response_type"))
+}
+
+func FeishuTestSignupSetup(ts *ExternalTestSuite, tokenCount *int, userCount *int, code string, enterpriseEmail string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/open-apis/authen/v2/oauth/token":
+			*tokenCount++
+			var body map[string]string
+			ts.Require().NoError(json.NewDecoder(r.Body).Decode(&body))
+			ts.Equal(code, body["code"])
+			ts.Equal("authorization_code", body["grant_type"])
+			ts.Equal(ts.Config.External.Feishu.RedirectURI, body["redirect_uri"])
+			w.Header().Add("Content-Type", "application/json")
+			fmt.Fprint(w, `{"code":0,"access_token":"feishu_token","expires_in":7200,"token_type":"Bearer"}`)
+		case "/open-apis/authen/v1/user_info":
+			*userCount++
+			ts.Equal("Bearer feishu_token", r.Header.Get("Authorization"))
+			w.Header().Add("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"code":0,"msg":"success","data":{"name":"Feishu Test","avatar_url":"http://example.com/avatar","open_id":"ou_123","union_id":"on_123","enterprise_email":%q}}`, enterpriseEmail)
+		default:
+			w.WriteHeader(500)
+			ts.Fail("unknown feishu oauth call %s", r.URL.Path)
+		}
+	}))
+
+	ts.Config.External.Feishu.URL = server.URL
+
+	return server
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalFeishu_AuthorizationCode() {
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := FeishuTestSignupSetup(ts, &tokenCount, &userCount, code, "feishu@example.com")
+	defer server.Close()
+
+	u := performAuthorization(ts, "feishu", code, "")
+	assertAuthorizationSuccess(ts, u, tokenCount, userCount, "feishu@example.com", "Feishu Test", "on_123", "http://example.com/avatar")
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalFeishuWithoutEmail() {
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := FeishuTestSignupSetup(ts, &tokenCount, &userCount, code, "")
+	defer server.Close()
+
+	u := performAuthorization(ts, "feishu", code, "")
+	assertAuthorizationSuccessWithoutEmail(ts, u, "feishu", "on_123", "Feishu Test", "http://example.com/avatar")
+}
@@ -38,7 +38,7 @@ func (a *API) loadFlowState(w http.ResponseWriter, r *http.Request) (context.Con
 	var err error
 	ctx, err = a.loadExternalState(ctx, r)
 	if err != nil {
-		u, uerr := url.ParseRequestURI(a.config.SiteURL)
+		u, uerr := url.ParseRequestURI(a.requestConfig(ctx).SiteURL)
 		if uerr != nil {
 			return ctx, apierrors.NewInternalServerError("site url is improperly formatted").WithInternalError(uerr)
 		}
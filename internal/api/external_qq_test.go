@@ -0,0 +1,65 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+)
+
+func (ts *ExternalTestSuite) TestSignupExternalQQ() {
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/authorize?provider=qq", nil)
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	ts.Require().Equal(http.StatusFound, w.Code)
+	u, err := url.Parse(w.Header().Get("Location"))
+	ts.Require().NoError(err, "redirect url parse failed")
+	q := u.Query()
+	ts.Equal(ts.Config.External.QQ.RedirectURI, q.Get("redirect_uri"))
+	ts.Equal(ts.Config.External.QQ.ClientID, []string{q.Get("client_id")})
+	ts.Equal("code", q.Get("response_type"))
+	ts.Equal("get_user_info", q.Get("scope"))
+}
+
+func QQTestSignupSetup(ts *ExternalTestSuite, tokenCount *int, userCount *int, code string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		switch r.URL.Path {
+		case "/oauth2.0/token":
+			*tokenCount++
+			ts.Equal(code, q.Get("code"))
+			ts.Equal("json", q.Get("fmt"))
+			ts.Equal(ts.Config.External.QQ.RedirectURI, q.Get("redirect_uri"))
+			fmt.Fprint(w, `{"access_token":"qq_token","expires_in":"7776000","refresh_token":"qq_refresh"}`)
+		case "/oauth2.0/me":
+			ts.Equal("qq_token", q.Get("access_token"))
+			fmt.Fprint(w, `{"client_id":"testclientid","openid":"openid123","unionid":"unionid123"}`)
+		case "/user/get_user_info":
+			*userCount++
+			ts.Equal("openid123", q.Get("openid"))
+			ts.Equal(ts.Config.External.QQ.ClientID[0], q.Get("oauth_consumer_key"))
+			fmt.Fprint(w, `{"ret":0,"msg":"","nickname":"QQ Test","figureurl_qq_1":"http://example.com/small","figureurl_qq_2":"http://example.com/avatar"}`)
+		default:
+			w.WriteHeader(500)
+			ts.Fail("unknown qq oauth call %s", r.URL.Path)
+		}
+	}))
+
+	ts.Config.External.QQ.URL = server.URL
+
+	return server
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalQQ_AuthorizationCode() {
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := QQTestSignupSetup(ts, &tokenCount, &userCount, code)
+	defer server.Close()
+
+	u := performAuthorization(ts, "qq", code, "")
+	ts.Equal(1, tokenCount)
+	ts.Equal(1, userCount)
+
+	assertAuthorizationSuccessWithoutEmail(ts, u, "qq", "unionid123", "QQ Test", "http://example.com/avatar")
+}
@@ -252,3 +252,29 @@ func (ts *ExternalTestSuite) TestRedirectErrorsShouldPreserveParams() {
 		}
 	}
 }
+
+// assertAuthorizationSuccessWithoutEmail checks a successful sign in with a
+// provider that didn't share an email address, returning the user.
+func assertAuthorizationSuccessWithoutEmail(ts *ExternalTestSuite, u *url.URL, providerType string, providerId string, name string, avatar string) *models.User {
+	v, err := url.ParseQuery(u.RawQuery)
+	ts.Require().NoError(err)
+	ts.Require().Empty(v.Get("error_description"))
+	ts.Require().Empty(v.Get("error"))
+
+	v, err = url.ParseQuery(u.Fragment)
+	ts.Require().NoError(err)
+	ts.NotEmpty(v.Get("access_token"))
+	ts.NotEmpty(v.Get("refresh_token"))
+
+	identity, err := models.FindIdentityByIdAndProvider(ts.API.db, providerId, providerType)
+	ts.Require().NoError(err)
+
+	user, err := models.FindUserByID(ts.API.db, identity.UserID)
+	ts.Require().NoError(err)
+	ts.Empty(user.GetEmail())
+	ts.Equal(providerId, user.UserMetaData["provider_id"])
+	ts.Equal(name, user.UserMetaData["full_name"])
+	ts.Equal(avatar, user.UserMetaData["avatar_url"])
+
+	return user
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+func (ts *ExternalTestSuite) TestSignupExternalWeChat() {
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/authorize?provider=wechat", nil)
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	ts.Require().Equal(http.StatusFound, w.Code)
+	u, err := url.Parse(w.Header().Get("Location"))
+	ts.Require().NoError(err, "redirect url parse failed")
+	ts.Equal("/connect/qrconnect", u.Path)
+	ts.Equal("wechat_redirect", u.Fragment)
+	q := u.Query()
+	ts.Equal(ts.Config.External.WeChat.RedirectURI, q.Get("redirect_uri"))
+	ts.Equal(ts.Config.External.WeChat.ClientID, []string{q.Get("appid")})
+	ts.Equal("code", q.Get("response_type"))
+	ts.Equal("snsapi_login", q.Get("scope"))
+
+	claims := ExternalProviderClaims{}
+	p := jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}))
+	_, err = p.ParseWithClaims(q.Get("state"), &claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(ts.Config.JWT.Secret), nil
+	})
+	ts.Require().NoError(err)
+
+	ts.Equal("wechat", claims.Provider)
+	ts.Equal(ts.Config.SiteURL, claims.SiteURL)
+}
+
+func WeChatTestSignupSetup(ts *ExternalTestSuite, tokenCount *int, userCount *int, code string, openID string, unionID string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sns/oauth2/access_token":
+			*tokenCount++
+			ts.Equal(code, r.URL.Query().Get("code"))
+			ts.Equal("authorization_code", r.URL.Query().Get("grant_type"))
+			w.Header().Add("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"access_token":"wechat_token","expires_in":7200,"refresh_token":"wechat_refresh","openid":%q,"unionid":%q}`, openID, unionID)
+		case "/sns/userinfo":
+			*userCount++
+			ts.Equal(openID, r.URL.Query().Get("openid"))
+			w.Header().Add("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"openid":%q,"unionid":%q,"nickname":"WeChat Test","headimgurl":"http://example.com/avatar"}`, openID, unionID)
+		default:
+			w.WriteHeader(500)
+			ts.Fail("unknown wechat oauth call %s", r.URL.Path)
+		}
+	}))
+
+	ts.Config.External.WeChat.URL = server.URL
+	ts.Config.External.WeChatMP.URL = server.URL
+
+	return server
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalWeChat_AuthorizationCode() {
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := WeChatTestSignupSetup(ts, &tokenCount, &userCount, code, "openid123", "unionid123")
+	defer server.Close()
+
+	u := performAuthorization(ts, "wechat", code, "")
+	ts.Equal(1, tokenCount)
+	ts.Equal(1, userCount)
+
+	assertAuthorizationSuccessWithoutEmail(ts, u, "wechat", "unionid123", "WeChat Test", "http://example.com/avatar")
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalWeChatWithoutUnionID() {
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := WeChatTestSignupSetup(ts, &tokenCount, &userCount, code, "openid123", "")
+	defer server.Close()
+
+	u := performAuthorization(ts, "wechat", code, "")
+
+	assertAuthorizationSuccessWithoutEmail(ts, u, "wechat", "openid123", "WeChat Test", "http://example.com/avatar")
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalWeChatLinksOnUnionID() {
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := WeChatTestSignupSetup(ts, &tokenCount, &userCount, code, "openid123", "unionid123")
+	defer server.Close()
+
+	u := performAuthorization(ts, "wechat", code, "")
+	user := assertAuthorizationSuccessWithoutEmail(ts, u, "wechat", "unionid123", "WeChat Test", "http://example.com/avatar")
+
+	// the official account app has a different openid for the same user
+	mpServer := WeChatTestSignupSetup(ts, &tokenCount, &userCount, code, "mpopenid456", "unionid123")
+	defer mpServer.Close()
+
+	u = performAuthorization(ts, "wechat_mp", code, "")
+	mpUser := assertAuthorizationSuccessWithoutEmail(ts, u, "wechat_mp", "unionid123", "WeChat Test", "http://example.com/avatar")
+
+	ts.Equal(user.ID, mpUser.ID)
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalWeChatDisableSignupErrorWhenNoUser() {
+	ts.Config.DisableSignup = true
+
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := WeChatTestSignupSetup(ts, &tokenCount, &userCount, code, "openid123", "unionid123")
+	defer server.Close()
+
+	u := performAuthorization(ts, "wechat", code, "")
+
+	v, err := url.ParseQuery(u.RawQuery)
+	ts.Require().NoError(err)
+	ts.Equal("Signups not allowed for this instance", v.Get("error_description"))
+	ts.Equal("access_denied", v.Get("error"))
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+)
+
+func (ts *ExternalTestSuite) TestSignupExternalWeibo() {
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/authorize?provider=weibo", nil)
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	ts.Require().Equal(http.StatusFound, w.Code)
+	u, err := url.Parse(w.Header().Get("Location"))
+	ts.Require().NoError(err, "redirect url parse failed")
+	q := u.Query()
+	ts.Equal(ts.Config.External.Weibo.RedirectURI, q.Get("redirect_uri"))
+	ts.Equal(ts.Config.External.Weibo.ClientID, []string{q.Get("client_id")})
+	ts.Equal("code", q.Get("response_type"))
+}
+
+func WeiboTestSignupSetup(ts *ExternalTestSuite, tokenCount *int, userCount *int, code string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/oauth2/access_token":
+			*tokenCount++
+			ts.Equal(code, r.FormValue("code"))
+			ts.Equal("authorization_code", r.FormValue("grant_type"))
+			ts.Equal(ts.Config.External.Weibo.RedirectURI, r.FormValue("redirect_uri"))
+			// Weibo responds with JSON but a text/plain content type
+			w.Header().Add("Content-Type", "text/plain;charset=UTF-8")
+			fmt.Fprint(w, `{"access_token":"weibo_token","remind_in":"157679999","expires_in":157679999,"uid":"1234567890","isRealName":"true"}`)
+		case "/2/users/show.json":
+			*userCount++
+			ts.Equal("weibo_token", r.URL.Query().Get("access_token"))
+			ts.Equal("1234567890", r.URL.Query().Get("uid"))
+			fmt.Fprint(w, `{"id":1234567890,"idstr":"1234567890","screen_name":"Weibo Test","name":"Weibo Test","profile_image_url":"http://example.com/small","avatar_large":"http://example.com/avatar"}`)
+		default:
+			w.WriteHeader(500)
+			ts.Fail("unknown weibo oauth call %s", r.URL.Path)
+		}
+	}))
+
+	ts.Config.External.Weibo.URL = server.URL
+
+	return server
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalWeibo_AuthorizationCode() {
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := WeiboTestSignupSetup(ts, &tokenCount, &userCount, code)
+	defer server.Close()
+
+	u := performAuthorization(ts, "weibo", code, "")
+	ts.Equal(1, tokenCount)
+	ts.Equal(1, userCount)
+
+	assertAuthorizationSuccessWithoutEmail(ts, u, "weibo", "1234567890", "Weibo Test", "http://example.com/avatar")
+}
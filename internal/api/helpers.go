@@ -47,6 +47,11 @@ func (a *API) requestAud(ctx context.Context, r *http.Request) string {
 		}
 	}
 
+	// Then check for an audience selected by the host
+	if aud := getAudience(ctx); aud != "" {
+		return aud
+	}
+
 	// Finally, return the default if none of the above methods are successful
 	return config.JWT.Aud
 }
@@ -61,23 +66,33 @@ func isStringInSlice(checkValue string, list []string) bool {
 }
 
 type RequestParams interface {
-	AdminUserParams |
+	AdminAPIKeyParams |
+		AdminUserParams |
 		CreateSSOProviderParams |
 		EnrollFactorParams |
 		GenerateLinkParams |
 		IdTokenGrantParams |
 		InviteParams |
+		MailPreviewParams |
+		OrganizationParams |
+		OrganizationMemberParams |
 		OtpParams |
 		PKCEGrantParams |
 		PasswordGrantParams |
 		RecoverParams |
 		RefreshTokenGrantParams |
 		ResendConfirmationParams |
+		SCIMGroup |
+		SCIMPatchParams |
+		SCIMUser |
 		SignupParams |
 		SingleSignOnParams |
+		SwitchOrganizationParams |
 		SmsParams |
 		Web3GrantParams |
 		UserUpdateParams |
+		UserDeleteParams |
+		UserMergeParams |
 		VerifyFactorParams |
 		VerifyParams |
 		adminUserUpdateFactorParams |
@@ -44,7 +44,7 @@ func (a *API) triggerBeforeUserCreatedExternal(
 
 	ctx := r.Context()
 	aud := a.requestAud(ctx, r)
-	config := a.config
+	config := a.requestConfig(ctx)
 
 	var identityData map[string]interface{}
 	if userData.Metadata != nil {
@@ -9,6 +9,7 @@ import (
 	"github.com/gofrs/uuid"
 	"github.com/supabase/auth/internal/api/apierrors"
 	"github.com/supabase/auth/internal/api/provider"
+	mail "github.com/supabase/auth/internal/mailer"
 	"github.com/supabase/auth/internal/models"
 	"github.com/supabase/auth/internal/storage"
 )
@@ -86,6 +87,10 @@ func (a *API) DeleteIdentity(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
+	a.notifyUser(r, user, mail.IdentityUnlinkedNotification, map[string]interface{}{
+		"Provider": identityToBeDeleted.Provider,
+	})
+
 	return sendJSON(w, http.StatusOK, map[string]interface{}{})
 }
 
@@ -108,6 +113,7 @@ func (a *API) LinkIdentity(w http.ResponseWriter, r *http.Request) error {
 
 func (a *API) linkIdentityToUser(r *http.Request, ctx context.Context, tx *storage.Connection, userData *provider.UserProvidedData, providerType string) (*models.User, error) {
 	targetUser := getTargetUser(ctx)
+	wasAnonymous := targetUser.IsAnonymous
 	identity, terr := models.FindIdentityByIdAndProvider(tx, userData.Metadata.Subject, providerType)
 	if terr != nil {
 		if !models.IsNotFoundError(terr) {
@@ -124,7 +130,15 @@ func (a *API) linkIdentityToUser(r *http.Request, ctx context.Context, tx *stora
 		return nil, terr
 	}
 
-	if targetUser.GetEmail() == "" {
+	if targetUser.GetEmail() == "" && len(userData.Emails) == 0 && provider.IsEmailOptional(providerType) {
+		// nothing to confirm, but the user is no longer anonymous
+		if targetUser.IsAnonymous {
+			targetUser.IsAnonymous = false
+			if terr := tx.UpdateOnly(targetUser, "is_anonymous"); terr != nil {
+				return nil, terr
+			}
+		}
+	} else if targetUser.GetEmail() == "" {
 		if terr := targetUser.UpdateUserEmailFromIdentities(tx); terr != nil {
 			if models.IsUniqueConstraintViolatedError(terr) {
 				return nil, apierrors.NewBadRequestError(apierrors.ErrorCodeEmailExists, DuplicateEmailMsg)
@@ -152,5 +166,12 @@ func (a *API) linkIdentityToUser(r *http.Request, ctx context.Context, tx *stora
 	if terr := targetUser.UpdateAppMetaDataProviders(tx); terr != nil {
 		return nil, terr
 	}
+
+	if !wasAnonymous {
+		a.notifyUser(r, targetUser, mail.IdentityLinkedNotification, map[string]interface{}{
+			"Provider": providerType,
+		})
+	}
+
 	return targetUser, nil
 }
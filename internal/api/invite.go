@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/fatih/structs"
+	"github.com/gofrs/uuid"
 	"github.com/supabase/auth/internal/api/apierrors"
 	"github.com/supabase/auth/internal/api/provider"
 	"github.com/supabase/auth/internal/models"
@@ -14,6 +15,11 @@ import (
 type InviteParams struct {
 	Email string                 `json:"email"`
 	Data  map[string]interface{} `json:"data"`
+
+	// OrganizationID invites the user to the organization, with the
+	// OrganizationRole or as a member.
+	OrganizationID   *uuid.UUID `json:"organization_id"`
+	OrganizationRole string     `json:"organization_role"`
 }
 
 // Invite is the endpoint for inviting a new user
@@ -32,13 +38,46 @@ func (a *API) Invite(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
+	var organization *models.Organization
+	if params.OrganizationID != nil {
+		if !getAdminPermissions(ctx).Has(AdminPermissionOrganizationsWrite) {
+			return apierrors.NewForbiddenError(apierrors.ErrorCodeAdminPermissionDenied, "Inviting users to organizations requires the %s admin permission", AdminPermissionOrganizationsWrite)
+		}
+
+		if params.OrganizationRole == "" {
+			params.OrganizationRole = models.OrganizationRoleMember
+		} else if !models.IsValidOrganizationRole(params.OrganizationRole) {
+			return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Invalid organization role %q", params.OrganizationRole)
+		}
+
+		organization, err = models.FindOrganizationByID(db, *params.OrganizationID)
+		if err != nil {
+			if models.IsNotFoundError(err) {
+				return apierrors.NewNotFoundError(apierrors.ErrorCodeOrganizationNotFound, "Organization not found")
+			}
+			return apierrors.NewInternalServerError("Database error finding organization").WithInternalError(err)
+		}
+	}
+
 	aud := a.requestAud(ctx, r)
 	user, err := models.FindUserByEmailAndAudience(db, params.Email, aud)
 	if err != nil && !models.IsNotFoundError(err) {
 		return apierrors.NewInternalServerError("Database error finding user").WithInternalError(err)
 	}
 	if user != nil && user.IsConfirmed() {
-		return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeEmailExists, DuplicateEmailMsg)
+		if organization == nil {
+			return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeEmailExists, DuplicateEmailMsg)
+		}
+
+		// existing users are added to the organization directly, as
+		// they don't need to accept an invite to sign in
+		if err := db.Transaction(func(tx *storage.Connection) error {
+			return addOrganizationMember(r, tx, adminUser, organization, user, params.OrganizationRole)
+		}); err != nil {
+			return err
+		}
+
+		return sendJSON(w, http.StatusOK, user)
 	}
 
 	signupParams := SignupParams{
@@ -77,6 +116,12 @@ func (a *API) Invite(w http.ResponseWriter, r *http.Request) error {
 			return terr
 		}
 
+		if organization != nil {
+			if terr := addOrganizationMember(r, tx, adminUser, organization, user, params.OrganizationRole); terr != nil {
+				return terr
+			}
+		}
+
 		if err := a.sendInvite(r, tx, user); err != nil {
 			return err
 		}
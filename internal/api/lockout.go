@@ -0,0 +1,214 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/supabase/auth/internal/api/apierrors"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/storage"
+	"github.com/supabase/auth/internal/utilities"
+)
+
+// lockedUntil returns until when attempts of the kind are refused for the
+// user, or the zero time, along with the number of recent failed attempts.
+func (a *API) lockedUntil(db *storage.Connection, user *models.User, kind models.FailedAuthAttemptKind) (time.Time, int, error) {
+	config := a.config.Security.Lockout
+
+	if !config.Enabled {
+		return time.Time{}, 0, nil
+	}
+
+	attempts, err := models.FindFailedAuthAttempts(db, user.ID, kind, a.Now().Add(-config.Window))
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+
+	return a.lockedUntilAttempts(attempts), len(attempts), nil
+}
+
+// identifierLockedUntil is lockedUntil for an identifier without an account.
+func (a *API) identifierLockedUntil(db *storage.Connection, identifier string, kind models.FailedAuthAttemptKind) (time.Time, error) {
+	if !a.config.Security.Lockout.Enabled {
+		return time.Time{}, nil
+	}
+
+	attempts, err := models.FindFailedIdentifierAttempts(db, identifier, kind, a.Now().Add(-a.config.Security.Lockout.Window))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return a.lockedUntilAttempts(attempts), nil
+}
+
+func (a *API) lockedUntilAttempts(attempts []*models.FailedAuthAttempt) time.Time {
+	if len(attempts) == 0 {
+		return time.Time{}
+	}
+
+	return a.config.Security.Lockout.LockedUntil(len(attempts), attempts[0].CreatedAt)
+}
+
+// failedAttemptIdentifier returns the identifier of failed attempts to sign
+// in to an account that doesn't exist. It is hashed, so that arbitrary email
+// addresses and phone numbers aren't stored.
+func failedAttemptIdentifier(aud, provider, value string) string {
+	hash := sha256.Sum256([]byte(aud + "\x00" + provider + "\x00" + strings.ToLower(value)))
+	return hex.EncodeToString(hash[:])
+}
+
+// checkLockout refuses the attempt if the user is locked because of recent
+// failed attempts of the kind, telling the client when to retry.
+func (a *API) checkLockout(w http.ResponseWriter, db *storage.Connection, user *models.User, kind models.FailedAuthAttemptKind) error {
+	lockedUntil, _, err := a.lockedUntil(db, user, kind)
+	if err != nil {
+		return apierrors.NewInternalServerError("Database error checking failed attempts").WithInternalError(err)
+	}
+
+	return a.lockoutError(w, lockedUntil, kind)
+}
+
+// lockoutError returns the error refusing attempts of the kind until
+// lockedUntil, or nil if it has passed.
+func (a *API) lockoutError(w http.ResponseWriter, lockedUntil time.Time, kind models.FailedAuthAttemptKind) error {
+	now := a.Now()
+	if !now.Before(lockedUntil) {
+		return nil
+	}
+
+	retryAfter := int(math.Ceil(lockedUntil.Sub(now).Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+
+	if kind == models.FailedMFAAttempt {
+		return apierrors.NewTooManyRequestsError(apierrors.ErrorCodeMFAVerificationLocked, "Too many failed MFA verification attempts, try again in %d seconds", retryAfter)
+	}
+
+	return apierrors.NewTooManyRequestsError(apierrors.ErrorCodeUserLocked, "Too many failed sign in attempts, try again in %d seconds or reset your password", retryAfter)
+}
+
+// recordFailedAttempt records a failed attempt of the user, which the
+// lockout and the risk engine use, and adds an audit log entry if the
+// attempt locks the user.
+func (a *API) recordFailedAttempt(r *http.Request, db *storage.Connection, user *models.User, kind models.FailedAuthAttemptKind) error {
+	if !a.config.Security.Lockout.Enabled && !a.config.Security.Risk.Enabled {
+		return nil
+	}
+
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		if terr := models.AddFailedAuthAttempt(tx, user.ID, kind, utilities.GetIPAddress(r)); terr != nil {
+			return terr
+		}
+
+		lockedUntil, failures, terr := a.lockedUntil(tx, user, kind)
+		if terr != nil {
+			return terr
+		}
+
+		if lockedUntil.IsZero() {
+			return nil
+		}
+
+		return models.NewAuditLogEntry(r, tx, user, models.UserLockedAction, "", map[string]interface{}{
+			"kind":            kind,
+			"failed_attempts": failures,
+			"locked_until":    lockedUntil,
+		})
+	}); err != nil {
+		return apierrors.NewInternalServerError("Database error recording failed attempt").WithInternalError(err)
+	}
+
+	return nil
+}
+
+// authenticateLocked runs authenticate with the attempts of the kind of the
+// user locked, refusing it while the user is locked and recording it if it
+// fails. Concurrent attempts therefore can't all pass the lockout check
+// before any of them is recorded.
+func (a *API) authenticateLocked(w http.ResponseWriter, r *http.Request, db *storage.Connection, user *models.User, kind models.FailedAuthAttemptKind, authenticate func(tx *storage.Connection) (bool, error)) (bool, error) {
+	valid := false
+
+	err := db.Transaction(func(tx *storage.Connection) error {
+		if a.config.Security.Lockout.Enabled {
+			if terr := models.LockFailedAuthAttempts(tx, user.ID); terr != nil {
+				return apierrors.NewInternalServerError("Database error checking failed attempts").WithInternalError(terr)
+			}
+
+			if terr := a.checkLockout(w, tx, user, kind); terr != nil {
+				return terr
+			}
+		}
+
+		var terr error
+		if valid, terr = authenticate(tx); terr != nil || valid {
+			return terr
+		}
+
+		return a.recordFailedAttempt(r, tx, user, kind)
+	})
+
+	return valid, err
+}
+
+// recordFailedIdentifierAttempt records a failed sign in to an account that
+// doesn't exist, refusing it like checkLockout while the identifier is
+// locked, so that the response doesn't tell whether the account exists.
+func (a *API) recordFailedIdentifierAttempt(w http.ResponseWriter, r *http.Request, db *storage.Connection, identifier string, kind models.FailedAuthAttemptKind) error {
+	if !a.config.Security.Lockout.Enabled {
+		return nil
+	}
+
+	return db.Transaction(func(tx *storage.Connection) error {
+		if terr := models.LockFailedIdentifierAttempts(tx, identifier); terr != nil {
+			return apierrors.NewInternalServerError("Database error checking failed attempts").WithInternalError(terr)
+		}
+
+		lockedUntil, terr := a.identifierLockedUntil(tx, identifier, kind)
+		if terr != nil {
+			return apierrors.NewInternalServerError("Database error checking failed attempts").WithInternalError(terr)
+		}
+
+		if terr := a.lockoutError(w, lockedUntil, kind); terr != nil {
+			return terr
+		}
+
+		if terr := models.AddFailedIdentifierAttempt(tx, identifier, kind, utilities.GetIPAddress(r)); terr != nil {
+			return apierrors.NewInternalServerError("Database error recording failed attempt").WithInternalError(terr)
+		}
+
+		return nil
+	})
+}
+
+// clearFailedAttempts unlocks the user after a successful attempt.
+func (a *API) clearFailedAttempts(tx *storage.Connection, user *models.User, kind models.FailedAuthAttemptKind) error {
+	if !a.config.Security.Lockout.Enabled && !a.config.Security.Risk.Enabled {
+		return nil
+	}
+
+	_, err := models.ClearFailedAuthAttempts(tx, user.ID, kind)
+	return err
+}
+
+// unlockUser clears the failed attempts of the given kinds of the user,
+// adding an audit log entry by the actor if the user had any.
+func (a *API) unlockUser(r *http.Request, tx *storage.Connection, actor, user *models.User, kinds ...models.FailedAuthAttemptKind) error {
+	cleared, err := models.ClearFailedAuthAttempts(tx, user.ID, kinds...)
+	if err != nil {
+		return err
+	}
+
+	if cleared == 0 {
+		return nil
+	}
+
+	return models.NewAuditLogEntry(r, tx, actor, models.UserUnlockedAction, "", map[string]interface{}{
+		"user_id":         user.ID,
+		"kinds":           kinds,
+		"failed_attempts": cleared,
+	})
+}
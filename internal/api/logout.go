@@ -7,6 +7,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/supabase/auth/internal/api/apierrors"
 	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/observability"
 	"github.com/supabase/auth/internal/storage"
 )
 
@@ -43,6 +44,17 @@ func (a *API) Logout(w http.ResponseWriter, r *http.Request) error {
 	s := getSession(ctx)
 	u := getUser(ctx)
 
+	// sessions created by a SAML identity provider are also ended at the
+	// identity provider, unless the current session is kept
+	var samlSession *models.SAMLSession
+	if s != nil && scope != LogoutOthers {
+		var err error
+		samlSession, err = models.FindSAMLSessionBySessionID(db, s.ID)
+		if err != nil && !models.IsNotFoundError(err) {
+			return apierrors.NewInternalServerError("Error logging out user").WithInternalError(err)
+		}
+	}
+
 	err := db.Transaction(func(tx *storage.Connection) error {
 		if terr := models.NewAuditLogEntry(r, tx, u, models.LogoutAction, "", nil); terr != nil {
 			return terr
@@ -68,6 +80,19 @@ func (a *API) Logout(w http.ResponseWriter, r *http.Request) error {
 		return apierrors.NewInternalServerError("Error logging out user").WithInternalError(err)
 	}
 
+	if samlSession != nil && a.config.SAML.Enabled {
+		logoutURL, err := a.samlLogoutURL(db, samlSession, r.URL.Query().Get("redirect_to"))
+		if err != nil {
+			// the session has already ended, so logout succeeds
+			// even if the identity provider can't be notified
+			observability.GetLogEntry(r).Entry.WithError(err).Warn("unable to create SAML LogoutRequest")
+		} else if logoutURL != "" {
+			return sendJSON(w, http.StatusOK, SAMLLogoutResponse{
+				SAMLLogoutURL: logoutURL,
+			})
+		}
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 
 	return nil
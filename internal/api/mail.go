@@ -2,6 +2,7 @@ package api
 
 import (
 	"net/http"
+	"net/url"
 	"regexp"
 	"strings"
 	"time"
@@ -19,6 +20,7 @@ import (
 	"github.com/supabase/auth/internal/api/provider"
 	"github.com/supabase/auth/internal/crypto"
 	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/observability"
 	"github.com/supabase/auth/internal/storage"
 	"github.com/supabase/auth/internal/utilities"
 )
@@ -48,8 +50,8 @@ type GenerateLinkResponse struct {
 func (a *API) adminGenerateLink(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 	db := a.db.WithContext(ctx)
-	config := a.config
-	mailer := a.Mailer()
+	config := a.requestConfig(ctx)
+	mailer := a.Mailer(ctx)
 	adminUser := getAdminUser(ctx)
 	params := &GenerateLinkParams{}
 	if err := retrieveRequestParams(r, params); err != nil {
@@ -307,7 +309,7 @@ func (a *API) adminGenerateLink(w http.ResponseWriter, r *http.Request) error {
 func (a *API) sendConfirmation(r *http.Request, tx *storage.Connection, u *models.User, flowType models.FlowType) error {
 	var err error
 
-	config := a.config
+	config := a.requestConfig(r.Context())
 	maxFrequency := config.SMTP.MaxFrequency
 	otpLength := config.Mailer.OtpLength
 
@@ -342,7 +344,7 @@ func (a *API) sendConfirmation(r *http.Request, tx *storage.Connection, u *model
 }
 
 func (a *API) sendInvite(r *http.Request, tx *storage.Connection, u *models.User) error {
-	config := a.config
+	config := a.requestConfig(r.Context())
 	otpLength := config.Mailer.OtpLength
 	var err error
 	oldToken := u.ConfirmationToken
@@ -375,7 +377,7 @@ func (a *API) sendInvite(r *http.Request, tx *storage.Connection, u *models.User
 }
 
 func (a *API) sendPasswordRecovery(r *http.Request, tx *storage.Connection, u *models.User, flowType models.FlowType) error {
-	config := a.config
+	config := a.requestConfig(r.Context())
 	otpLength := config.Mailer.OtpLength
 
 	if err := validateSentWithinFrequencyLimit(u.RecoverySentAt, config.SMTP.MaxFrequency); err != nil {
@@ -411,7 +413,7 @@ func (a *API) sendPasswordRecovery(r *http.Request, tx *storage.Connection, u *m
 }
 
 func (a *API) sendReauthenticationOtp(r *http.Request, tx *storage.Connection, u *models.User) error {
-	config := a.config
+	config := a.requestConfig(r.Context())
 	maxFrequency := config.SMTP.MaxFrequency
 	otpLength := config.Mailer.OtpLength
 
@@ -448,7 +450,7 @@ func (a *API) sendReauthenticationOtp(r *http.Request, tx *storage.Connection, u
 
 func (a *API) sendMagicLink(r *http.Request, tx *storage.Connection, u *models.User, flowType models.FlowType) error {
 	var err error
-	config := a.config
+	config := a.requestConfig(r.Context())
 	otpLength := config.Mailer.OtpLength
 
 	// since Magic Link is just a recovery with a different template and behaviour
@@ -487,7 +489,7 @@ func (a *API) sendMagicLink(r *http.Request, tx *storage.Connection, u *models.U
 
 // sendEmailChange sends out an email change token to the new email.
 func (a *API) sendEmailChange(r *http.Request, tx *storage.Connection, u *models.User, email string, flowType models.FlowType) error {
-	config := a.config
+	config := a.requestConfig(r.Context())
 	otpLength := config.Mailer.OtpLength
 
 	if err := validateSentWithinFrequencyLimit(u.EmailChangeSentAt, config.SMTP.MaxFrequency); err != nil {
@@ -587,9 +589,85 @@ func (a *API) checkEmailAddressAuthorization(email string) bool {
 	return true
 }
 
+type MailPreviewParams struct {
+	Email     string                 `json:"email"`
+	NewEmail  string                 `json:"new_email"`
+	Data      map[string]interface{} `json:"data"`
+	EmailData mail.EmailData         `json:"email_data"`
+}
+
+type MailPreviewResponse struct {
+	Messages []*mail.Message `json:"messages"`
+}
+
+// adminMailPreview renders the mails for an email action type with sample
+// data, without sending them.
+func (a *API) adminMailPreview(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	config := a.requestConfig(ctx)
+	params := &MailPreviewParams{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	if params.EmailData.EmailActionType == "" {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "email_data.email_action_type is required")
+	}
+
+	if params.Email == "" {
+		params.Email = "user@example.com"
+	}
+	if params.EmailData.EmailActionType == mail.EmailChangeVerification && params.NewEmail == "" {
+		params.NewEmail = "new-user@example.com"
+	}
+
+	emailData := params.EmailData
+	if emailData.Token == "" {
+		emailData.Token = crypto.GenerateOtp(config.Mailer.OtpLength)
+	}
+	if emailData.TokenHash == "" {
+		emailData.TokenHash = crypto.GenerateTokenHash(params.Email, emailData.Token)
+	}
+	if emailData.EmailActionType == mail.EmailChangeVerification {
+		if emailData.TokenNew == "" {
+			emailData.TokenNew = crypto.GenerateOtp(config.Mailer.OtpLength)
+		}
+		if emailData.TokenHashNew == "" {
+			emailData.TokenHashNew = crypto.GenerateTokenHash(params.NewEmail, emailData.TokenNew)
+		}
+	}
+	if emailData.RedirectTo == "" {
+		emailData.RedirectTo = config.SiteURL
+	}
+
+	externalURL := getExternalHost(ctx)
+	if emailData.SiteURL != "" {
+		u, err := url.ParseRequestURI(emailData.SiteURL)
+		if err != nil {
+			return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "email_data.site_url is not a valid URL")
+		}
+		externalURL = u
+	}
+
+	user := &models.User{
+		Email:        storage.NullString(params.Email),
+		EmailChange:  params.NewEmail,
+		UserMetaData: params.Data,
+	}
+
+	messages, err := a.Mailer(ctx).Preview(r, user, emailData, externalURL)
+	if err != nil {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Error rendering mail template: %v", err).WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, MailPreviewResponse{
+		Messages: messages,
+	})
+}
+
 func (a *API) sendEmail(r *http.Request, tx *storage.Connection, u *models.User, emailActionType, otp, otpNew, tokenHashWithPrefix string) error {
 	ctx := r.Context()
-	config := a.config
+	config := a.requestConfig(ctx)
 	referrerURL := utilities.GetReferrer(r, config)
 	externalURL := getExternalHost(ctx)
 
@@ -685,7 +763,7 @@ func (a *API) sendEmail(r *http.Request, tx *storage.Connection, u *models.User,
 		return a.hooksMgr.InvokeHook(tx, r, &input, &output)
 	}
 
-	mr := a.Mailer()
+	mr := a.Mailer(ctx)
 	var err error
 	switch emailActionType {
 	case mail.SignupVerification:
@@ -716,3 +794,53 @@ func (a *API) sendEmail(r *http.Request, tx *storage.Connection, u *models.User,
 		return err
 	}
 }
+
+// sendNotification sends a notification of the type to the user, if it's
+// enabled and the user has an email address. Notifications are sent through
+// the send email hook when it's enabled.
+func (a *API) sendNotification(r *http.Request, tx *storage.Connection, u *models.User, notificationType string, data map[string]interface{}) error {
+	ctx := r.Context()
+	config := a.requestConfig(ctx)
+
+	notification, ok := mail.NotificationConfig(&config.Mailer.Notifications, notificationType)
+	if !ok || !notification.Enabled {
+		return nil
+	}
+
+	if u.GetEmail() == "" || !a.checkEmailAddressAuthorization(u.GetEmail()) {
+		return nil
+	}
+
+	if config.RateLimitEmailSent.Events == 0 || !a.limiterOpts.Email.Allow() {
+		emailRateLimitCounter.Add(
+			ctx,
+			1,
+			metric.WithAttributeSet(attribute.NewSet(attribute.String("path", r.URL.Path))),
+		)
+		return EmailRateLimitExceeded
+	}
+
+	if config.Hook.SendEmail.Enabled {
+		input := v0hooks.SendEmailInput{
+			User: u,
+			EmailData: mail.EmailData{
+				EmailActionType:  notificationType,
+				SiteURL:          getExternalHost(ctx).String(),
+				NotificationData: data,
+			},
+		}
+		output := v0hooks.SendEmailOutput{}
+		return a.hooksMgr.InvokeHook(tx, r, &input, &output)
+	}
+
+	return a.Mailer(ctx).NotificationMail(r, u, notificationType, data)
+}
+
+// notifyUser sends a notification of the type to the user, outside of any
+// transaction so that a failing hook doesn't abort it. Failing to notify the
+// user doesn't fail the request.
+func (a *API) notifyUser(r *http.Request, u *models.User, notificationType string, data map[string]interface{}) {
+	if err := a.sendNotification(r, a.db.WithContext(r.Context()), u, notificationType, data); err != nil {
+		observability.GetLogEntry(r).Entry.WithError(err).WithField("notification", notificationType).Warn("Error sending notification")
+	}
+}
@@ -249,6 +249,90 @@ func (ts *MailTestSuite) TestGenerateLink() {
 	ts.API.config.Mailer.ExternalHosts = originalHosts
 }
 
+func (ts *MailTestSuite) TestMailPreview() {
+	claims := &AccessTokenClaims{
+		Role: "supabase_admin",
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(ts.Config.JWT.Secret))
+	require.NoError(ts.T(), err, "Error generating admin jwt")
+
+	cases := []struct {
+		Desc             string
+		Body             map[string]interface{}
+		ExpectedCode     int
+		ExpectedMessages int
+	}{
+		{
+			Desc: "Preview recovery mail",
+			Body: map[string]interface{}{
+				"email": "preview@example.com",
+				"email_data": map[string]interface{}{
+					"email_action_type": "recovery",
+					"token":             "123456",
+				},
+			},
+			ExpectedCode:     http.StatusOK,
+			ExpectedMessages: 1,
+		},
+		{
+			Desc: "Preview email change mail with secure email change",
+			Body: map[string]interface{}{
+				"email":     "preview@example.com",
+				"new_email": "new-preview@example.com",
+				"email_data": map[string]interface{}{
+					"email_action_type": "email_change",
+				},
+			},
+			ExpectedCode:     http.StatusOK,
+			ExpectedMessages: 2,
+		},
+		{
+			Desc: "Missing email action type",
+			Body: map[string]interface{}{
+				"email": "preview@example.com",
+			},
+			ExpectedCode: http.StatusBadRequest,
+		},
+		{
+			Desc: "Unknown email action type",
+			Body: map[string]interface{}{
+				"email_data": map[string]interface{}{
+					"email_action_type": "unknown",
+				},
+			},
+			ExpectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, c := range cases {
+		ts.Run(c.Desc, func() {
+			var buffer bytes.Buffer
+			require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(c.Body))
+			req := httptest.NewRequest(http.MethodPost, "/admin/mail/preview", &buffer)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+			w := httptest.NewRecorder()
+
+			ts.API.handler.ServeHTTP(w, req)
+			require.Equal(ts.T(), c.ExpectedCode, w.Code)
+
+			if c.ExpectedCode != http.StatusOK {
+				return
+			}
+
+			data := MailPreviewResponse{}
+			require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+			require.Len(ts.T(), data.Messages, c.ExpectedMessages)
+
+			for _, msg := range data.Messages {
+				require.NotEmpty(ts.T(), msg.To)
+				require.NotEmpty(ts.T(), msg.Subject)
+				require.NotEmpty(ts.T(), msg.HTML)
+				require.NotEmpty(ts.T(), msg.Text)
+			}
+		})
+	}
+}
+
 func (ts *MailTestSuite) setURIAllowListMap(uris ...string) {
 	for _, uri := range uris {
 		g := glob.MustCompile(uri, '.', '/')
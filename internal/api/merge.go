@@ -0,0 +1,117 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/supabase/auth/internal/api/apierrors"
+	"github.com/supabase/auth/internal/hooks/v0hooks"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// UserMergeParams are the parameters of merging an anonymous user into an
+// existing user. The access token of the existing user proves that the
+// caller owns it.
+type UserMergeParams struct {
+	AccessToken string `json:"access_token"`
+}
+
+// UserMerge merges the anonymous user of the request into the user of the
+// access token in the body. The user merged hook receives both user IDs so
+// that the app can move the anonymous user's data, and the anonymous user is
+// then deleted along with its sessions.
+func (a *API) UserMerge(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	aud := a.requestAud(ctx, r)
+
+	anonymousUser := getUser(ctx)
+	if !anonymousUser.IsAnonymous {
+		return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeUserNotAnonymous, "Only anonymous users can be merged into another user")
+	}
+
+	params := &UserMergeParams{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	if params.AccessToken == "" {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "An access token of the user to merge into is required")
+	}
+
+	targetUser, err := a.findMergeTarget(r, params.AccessToken)
+	if err != nil {
+		return err
+	}
+
+	if targetUser.ID == anonymousUser.ID || targetUser.IsAnonymous || targetUser.Aud != aud {
+		return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeMergeTargetInvalid, "Anonymous users can only be merged into a permanent user")
+	}
+
+	if targetUser.IsBanned() {
+		return apierrors.NewForbiddenError(apierrors.ErrorCodeUserBanned, "User is banned")
+	}
+
+	err = db.Transaction(func(tx *storage.Connection) error {
+		// the anonymous user may have been merged or deleted by a
+		// concurrent request
+		if _, terr := models.FindUserByID(tx, anonymousUser.ID); terr != nil {
+			return terr
+		}
+
+		if terr := models.NewAuditLogEntry(r, tx, targetUser, models.UserMergedAction, "", map[string]interface{}{
+			"anonymous_user_id": anonymousUser.ID,
+		}); terr != nil {
+			return terr
+		}
+
+		if a.hooksMgr.Enabled(v0hooks.UserMerged) {
+			input := v0hooks.NewUserMergedInput(r, anonymousUser, targetUser)
+			output := new(v0hooks.UserMergedOutput)
+			if terr := a.hooksMgr.InvokeHook(tx, r, input, output); terr != nil {
+				return terr
+			}
+		}
+
+		return tx.Destroy(anonymousUser)
+	})
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return apierrors.NewForbiddenError(apierrors.ErrorCodeUserNotFound, "User from sub claim in JWT does not exist")
+		}
+		if _, ok := err.(*HTTPError); ok {
+			return err
+		}
+		return apierrors.NewInternalServerError("Database error merging user").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, targetUser)
+}
+
+// findMergeTarget returns the user of the access token, which must belong to
+// an existing session.
+func (a *API) findMergeTarget(r *http.Request, accessToken string) (*models.User, error) {
+	ctx, err := a.parseJWTClaims(accessToken, r)
+	if err != nil {
+		return nil, apierrors.NewForbiddenError(apierrors.ErrorCodeMergeTargetInvalid, "Access token of the user to merge into is invalid").WithInternalError(err)
+	}
+
+	claims := getClaims(ctx)
+	if claims == nil || claims.SessionId == "" {
+		return nil, apierrors.NewForbiddenError(apierrors.ErrorCodeMergeTargetInvalid, "Access token of the user to merge into is invalid")
+	}
+
+	ctx, err = a.maybeLoadUserOrSession(ctx)
+	if err != nil {
+		if herr, ok := err.(*HTTPError); ok {
+			return nil, apierrors.NewForbiddenError(apierrors.ErrorCodeMergeTargetInvalid, "Access token of the user to merge into is invalid").WithInternalError(herr)
+		}
+		return nil, err
+	}
+
+	if getSession(ctx) == nil {
+		return nil, apierrors.NewForbiddenError(apierrors.ErrorCodeMergeTargetInvalid, "Access token of the user to merge into is invalid")
+	}
+
+	return getUser(ctx), nil
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/supabase/auth/internal/conf"
 	"github.com/supabase/auth/internal/crypto"
 	"github.com/supabase/auth/internal/hooks/v0hooks"
+	mail "github.com/supabase/auth/internal/mailer"
 	"github.com/supabase/auth/internal/metering"
 	"github.com/supabase/auth/internal/models"
 	"github.com/supabase/auth/internal/storage"
@@ -199,7 +200,7 @@ func (a *API) enrollPhoneFactor(w http.ResponseWriter, r *http.Request, params *
 		return apierrors.NewInternalServerError("Database error deleting unverified phone factors").WithInternalError(err)
 	}
 
-	if err := validateFactors(db, user, params.FriendlyName, a.config, session); err != nil {
+	if err := validateFactors(db, user, params.FriendlyName, a.requestConfig(ctx), session); err != nil {
 		return err
 	}
 
@@ -233,7 +234,7 @@ func (a *API) enrollWebAuthnFactor(w http.ResponseWriter, r *http.Request, param
 	session := getSession(ctx)
 	db := a.db.WithContext(ctx)
 
-	if err := validateFactors(db, user, params.FriendlyName, a.config, session); err != nil {
+	if err := validateFactors(db, user, params.FriendlyName, a.requestConfig(ctx), session); err != nil {
 		return err
 	}
 
@@ -264,7 +265,7 @@ func (a *API) enrollTOTPFactor(w http.ResponseWriter, r *http.Request, params *E
 	ctx := r.Context()
 	user := getUser(ctx)
 	db := a.db.WithContext(ctx)
-	config := a.config
+	config := a.requestConfig(ctx)
 	session := getSession(ctx)
 	issuer := ""
 	if params.Issuer == "" {
@@ -338,7 +339,7 @@ func (a *API) EnrollFactor(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 	user := getUser(ctx)
 	session := getSession(ctx)
-	config := a.config
+	config := a.requestConfig(ctx)
 
 	if session == nil || user == nil {
 		return apierrors.NewInternalServerError("A valid session and a registered user are required to enroll a factor")
@@ -372,7 +373,7 @@ func (a *API) EnrollFactor(w http.ResponseWriter, r *http.Request) error {
 
 func (a *API) challengePhoneFactor(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
-	config := a.config
+	config := a.requestConfig(ctx)
 	db := a.db.WithContext(ctx)
 	user := getUser(ctx)
 	factor := getFactor(ctx)
@@ -454,7 +455,7 @@ func (a *API) challengePhoneFactor(w http.ResponseWriter, r *http.Request) error
 
 func (a *API) challengeTOTPFactor(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
-	config := a.config
+	config := a.requestConfig(ctx)
 	db := a.db.WithContext(ctx)
 
 	user := getUser(ctx)
@@ -488,7 +489,7 @@ func (a *API) challengeTOTPFactor(w http.ResponseWriter, r *http.Request) error
 func (a *API) challengeWebAuthnFactor(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 	db := a.db.WithContext(ctx)
-	config := a.config
+	config := a.requestConfig(ctx)
 
 	user := getUser(ctx)
 	factor := getFactor(ctx)
@@ -551,7 +552,7 @@ func (a *API) challengeWebAuthnFactor(w http.ResponseWriter, r *http.Request) er
 }
 
 func (a *API) validateChallenge(r *http.Request, db *storage.Connection, factor *models.Factor, challengeID uuid.UUID) (*models.Challenge, error) {
-	config := a.config
+	config := a.requestConfig(r.Context())
 	currentIP := utilities.GetIPAddress(r)
 
 	challenge, err := factor.FindChallengeByID(db, challengeID)
@@ -578,7 +579,7 @@ func (a *API) validateChallenge(r *http.Request, db *storage.Connection, factor
 
 func (a *API) ChallengeFactor(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
-	config := a.config
+	config := a.requestConfig(ctx)
 	factor := getFactor(ctx)
 
 	switch factor.FactorType {
@@ -609,7 +610,7 @@ func (a *API) verifyTOTPFactor(w http.ResponseWriter, r *http.Request, params *V
 	ctx := r.Context()
 	user := getUser(ctx)
 	factor := getFactor(ctx)
-	config := a.config
+	config := a.requestConfig(ctx)
 	db := a.db.WithContext(ctx)
 
 	challenge, err := a.validateChallenge(r, db, factor, params.ChallengeID)
@@ -622,12 +623,20 @@ func (a *API) verifyTOTPFactor(w http.ResponseWriter, r *http.Request, params *V
 		return apierrors.NewInternalServerError("Database error verifying MFA TOTP secret").WithInternalError(err)
 	}
 
-	valid, verr := totp.ValidateCustom(params.Code, secret, time.Now().UTC(), totp.ValidateOpts{
-		Period:    30,
-		Skew:      1,
-		Digits:    otp.DigitsSix,
-		Algorithm: otp.AlgorithmSHA1,
+	var verr error
+	valid, err := a.authenticateLocked(w, r, db, user, models.FailedMFAAttempt, func(tx *storage.Connection) (bool, error) {
+		var valid bool
+		valid, verr = totp.ValidateCustom(params.Code, secret, time.Now().UTC(), totp.ValidateOpts{
+			Period:    30,
+			Skew:      1,
+			Digits:    otp.DigitsSix,
+			Algorithm: otp.AlgorithmSHA1,
+		})
+		return valid, nil
 	})
+	if err != nil {
+		return err
+	}
 
 	if config.Hook.MFAVerificationAttempt.Enabled {
 		input := v0hooks.MFAVerificationAttemptInput{
@@ -668,6 +677,7 @@ func (a *API) verifyTOTPFactor(w http.ResponseWriter, r *http.Request, params *V
 		return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeMFAVerificationFailed, "Invalid TOTP code entered").WithInternalError(verr)
 	}
 
+	enrolled := !factor.IsVerified()
 	var token *AccessTokenResponse
 
 	err = db.Transaction(func(tx *storage.Connection) error {
@@ -679,6 +689,9 @@ func (a *API) verifyTOTPFactor(w http.ResponseWriter, r *http.Request, params *V
 		}); terr != nil {
 			return terr
 		}
+		if terr = a.clearFailedAttempts(tx, user, models.FailedMFAAttempt); terr != nil {
+			return terr
+		}
 		if terr = challenge.Verify(tx); terr != nil {
 			return terr
 		}
@@ -722,13 +735,17 @@ func (a *API) verifyTOTPFactor(w http.ResponseWriter, r *http.Request, params *V
 	}
 	metering.RecordLogin(string(models.MFACodeLoginAction), user.ID)
 
+	if enrolled {
+		a.notifyFactor(r, user, mail.FactorEnrolledNotification, factor)
+	}
+
 	return sendJSON(w, http.StatusOK, token)
 
 }
 
 func (a *API) verifyPhoneFactor(w http.ResponseWriter, r *http.Request, params *VerifyFactorParams) error {
 	ctx := r.Context()
-	config := a.config
+	config := a.requestConfig(ctx)
 	user := getUser(ctx)
 	factor := getFactor(ctx)
 	db := a.db.WithContext(ctx)
@@ -749,24 +766,34 @@ func (a *API) verifyPhoneFactor(w http.ResponseWriter, r *http.Request, params *
 		}
 		return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeMFAChallengeExpired, "MFA challenge %v has expired, verify against another challenge or create a new challenge.", challenge.ID)
 	}
-	var valid bool
 	var otpCode string
 	var shouldReEncrypt bool
+	var smsProvider sms_provider.SmsProvider
 	if config.Sms.IsTwilioVerifyProvider() {
-		smsProvider, err := sms_provider.GetSmsProvider(*config)
+		smsProvider, err = sms_provider.GetSmsProvider(*config)
 		if err != nil {
 			return apierrors.NewInternalServerError("Failed to get SMS provider").WithInternalError(err)
 		}
-		if err := smsProvider.VerifyOTP(factor.Phone.String(), params.Code); err != nil {
-			return apierrors.NewForbiddenError(apierrors.ErrorCodeOTPExpired, "Token has expired or is invalid").WithInternalError(err)
-		}
-		valid = true
 	} else {
 		otpCode, shouldReEncrypt, err = challenge.GetOtpCode(config.Security.DBEncryption.DecryptionKeys, config.Security.DBEncryption.Encrypt, config.Security.DBEncryption.EncryptionKeyID)
 		if err != nil {
 			return apierrors.NewInternalServerError("Database error verifying MFA TOTP secret").WithInternalError(err)
 		}
-		valid = subtle.ConstantTimeCompare([]byte(otpCode), []byte(params.Code)) == 1
+	}
+
+	var verr error
+	valid, err := a.authenticateLocked(w, r, db, user, models.FailedMFAAttempt, func(tx *storage.Connection) (bool, error) {
+		if smsProvider != nil {
+			verr = smsProvider.VerifyOTP(factor.Phone.String(), params.Code)
+			return verr == nil, nil
+		}
+		return subtle.ConstantTimeCompare([]byte(otpCode), []byte(params.Code)) == 1, nil
+	})
+	if err != nil {
+		return err
+	}
+	if verr != nil {
+		return apierrors.NewForbiddenError(apierrors.ErrorCodeOTPExpired, "Token has expired or is invalid").WithInternalError(verr)
 	}
 	if config.Hook.MFAVerificationAttempt.Enabled {
 		input := v0hooks.MFAVerificationAttemptInput{
@@ -807,6 +834,7 @@ func (a *API) verifyPhoneFactor(w http.ResponseWriter, r *http.Request, params *
 		return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeMFAVerificationFailed, "Invalid MFA Phone code entered")
 	}
 
+	enrolled := !factor.IsVerified()
 	var token *AccessTokenResponse
 
 	err = db.Transaction(func(tx *storage.Connection) error {
@@ -818,6 +846,9 @@ func (a *API) verifyPhoneFactor(w http.ResponseWriter, r *http.Request, params *
 		}); terr != nil {
 			return terr
 		}
+		if terr = a.clearFailedAttempts(tx, user, models.FailedMFAAttempt); terr != nil {
+			return terr
+		}
 		if terr = challenge.Verify(tx); terr != nil {
 			return terr
 		}
@@ -850,6 +881,10 @@ func (a *API) verifyPhoneFactor(w http.ResponseWriter, r *http.Request, params *
 	}
 	metering.RecordLogin(string(models.MFACodeLoginAction), user.ID)
 
+	if enrolled {
+		a.notifyFactor(r, user, mail.FactorEnrolledNotification, factor)
+	}
+
 	return sendJSON(w, http.StatusOK, token)
 }
 
@@ -888,6 +923,10 @@ func (a *API) verifyWebAuthnFactor(w http.ResponseWriter, r *http.Request, param
 	}
 
 	if factor.IsUnverified() {
+		if err := a.checkLockout(w, db, user, models.FailedMFAAttempt); err != nil {
+			return err
+		}
+
 		parsedResponse, err := wbnprotocol.ParseCredentialCreationResponseBody(bytes.NewReader(params.WebAuthn.CreationResponse))
 		if err != nil {
 			return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Invalid credential_creation_response")
@@ -902,11 +941,19 @@ func (a *API) verifyWebAuthnFactor(w http.ResponseWriter, r *http.Request, param
 		if err != nil {
 			return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Invalid credential_request_response")
 		}
-		credential, err = webAuthn.ValidateLogin(user, webAuthnSession, parsedResponse)
+		var verr error
+		valid, err := a.authenticateLocked(w, r, db, user, models.FailedMFAAttempt, func(tx *storage.Connection) (bool, error) {
+			credential, verr = webAuthn.ValidateLogin(user, webAuthnSession, parsedResponse)
+			return verr == nil, nil
+		})
 		if err != nil {
-			return apierrors.NewInternalServerError("Failed to validate WebAuthn MFA response").WithInternalError(err)
+			return err
+		}
+		if !valid {
+			return apierrors.NewInternalServerError("Failed to validate WebAuthn MFA response").WithInternalError(verr)
 		}
 	}
+	enrolled := !factor.IsVerified()
 	var token *AccessTokenResponse
 	err = db.Transaction(func(tx *storage.Connection) error {
 		var terr error
@@ -917,6 +964,9 @@ func (a *API) verifyWebAuthnFactor(w http.ResponseWriter, r *http.Request, param
 		}); terr != nil {
 			return terr
 		}
+		if terr = a.clearFailedAttempts(tx, user, models.FailedMFAAttempt); terr != nil {
+			return terr
+		}
 		// Challenge verification not needed as the challenge is destroyed on use
 		if !factor.IsVerified() {
 			if terr = factor.UpdateStatus(tx, models.FactorStateVerified); terr != nil {
@@ -949,13 +999,17 @@ func (a *API) verifyWebAuthnFactor(w http.ResponseWriter, r *http.Request, param
 	}
 	metering.RecordLogin(string(models.MFACodeLoginAction), user.ID)
 
+	if enrolled {
+		a.notifyFactor(r, user, mail.FactorEnrolledNotification, factor)
+	}
+
 	return sendJSON(w, http.StatusOK, token)
 }
 
 func (a *API) VerifyFactor(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 	factor := getFactor(ctx)
-	config := a.config
+	config := a.requestConfig(ctx)
 
 	params := &VerifyFactorParams{}
 	if err := retrieveRequestParams(r, params); err != nil {
@@ -965,6 +1019,8 @@ func (a *API) VerifyFactor(w http.ResponseWriter, r *http.Request) error {
 		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Code needs to be non-empty")
 	}
 
+	// the lockout is checked when verifying the code, under the same lock
+	// as recording a failed attempt
 	switch factor.FactorType {
 	case models.Phone:
 		if !config.MFA.Phone.VerifyEnabled {
@@ -988,6 +1044,14 @@ func (a *API) VerifyFactor(w http.ResponseWriter, r *http.Request) error {
 
 }
 
+// notifyFactor notifies the user of the factor being enrolled or removed.
+func (a *API) notifyFactor(r *http.Request, user *models.User, notificationType string, factor *models.Factor) {
+	a.notifyUser(r, user, notificationType, map[string]interface{}{
+		"FactorType":   factor.FactorType,
+		"FriendlyName": factor.FriendlyName,
+	})
+}
+
 func (a *API) UnenrollFactor(w http.ResponseWriter, r *http.Request) error {
 	var err error
 	ctx := r.Context()
@@ -1025,6 +1089,10 @@ func (a *API) UnenrollFactor(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
+	if factor.IsVerified() {
+		a.notifyFactor(r, user, mail.FactorRemovedNotification, factor)
+	}
+
 	return sendJSON(w, http.StatusOK, &UnenrollFactorResponse{
 		ID: factor.ID,
 	})
@@ -590,6 +590,54 @@ func (ts *MFATestSuite) TestMFAVerifyFactor() {
 	}
 }
 
+func (ts *MFATestSuite) TestMFAVerifyFactorLockout() {
+	ts.Config.Security.Lockout = conf.LockoutConfiguration{
+		Enabled:     true,
+		MaxAttempts: 2,
+		Window:      15 * time.Minute,
+		Strategy:    conf.LockoutStrategyLockout,
+		Duration:    15 * time.Minute,
+	}
+	defer func() {
+		ts.Config.Security.Lockout = conf.LockoutConfiguration{}
+	}()
+
+	r, err := models.GrantAuthenticatedUser(ts.API.db, ts.TestUser, models.GrantParams{})
+	require.NoError(ts.T(), err)
+	token := ts.generateAAL1Token(ts.TestUser, r.SessionId)
+
+	f := models.NewTOTPFactor(ts.TestUser, uuid.Must(uuid.NewV4()).String())
+	f.Secret = ts.TestOTPKey.Secret()
+	require.NoError(ts.T(), ts.API.db.Create(f))
+
+	w := performChallengeFlow(ts, f.ID, token)
+	challenge := ChallengeFactorResponse{}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&challenge))
+
+	verify := func(code string) *httptest.ResponseRecorder {
+		var buffer bytes.Buffer
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
+			"challenge_id": challenge.ID,
+			"code":         code,
+		}))
+		return ServeAuthenticatedRequest(ts, http.MethodPost, fmt.Sprintf("/factors/%s/verify", f.ID), token, buffer)
+	}
+
+	for i := 0; i < 2; i++ {
+		require.Equal(ts.T(), http.StatusUnprocessableEntity, verify("000000").Code)
+	}
+
+	// the correct code is refused while locked
+	code, err := totp.GenerateCode(ts.TestOTPKey.Secret(), time.Now().UTC())
+	require.NoError(ts.T(), err)
+	w = verify(code)
+	require.Equal(ts.T(), http.StatusTooManyRequests, w.Code)
+
+	data := &HTTPError{}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(data))
+	require.Equal(ts.T(), apierrors.ErrorCodeMFAVerificationLocked, data.ErrorCode)
+}
+
 func (ts *MFATestSuite) TestUnenrollVerifiedFactor() {
 	cases := []struct {
 		desc             string
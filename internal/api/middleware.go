@@ -14,6 +14,7 @@ import (
 	chimiddleware "github.com/go-chi/chi/v5/middleware"
 	"github.com/sirupsen/logrus"
 	"github.com/supabase/auth/internal/api/apierrors"
+	"github.com/supabase/auth/internal/conf"
 	"github.com/supabase/auth/internal/models"
 	"github.com/supabase/auth/internal/observability"
 	"github.com/supabase/auth/internal/security"
@@ -87,6 +88,10 @@ func (a *API) requireAdminCredentials(w http.ResponseWriter, req *http.Request)
 		return nil, err
 	}
 
+	if strings.HasPrefix(t, models.AdminAPIKeyPrefix) {
+		return a.requireAdminAPIKey(req, t)
+	}
+
 	ctx, err := a.parseJWTClaims(t, req)
 	if err != nil {
 		return nil, err
@@ -97,7 +102,7 @@ func (a *API) requireAdminCredentials(w http.ResponseWriter, req *http.Request)
 
 func (a *API) requireEmailProvider(w http.ResponseWriter, req *http.Request) (context.Context, error) {
 	ctx := req.Context()
-	config := a.config
+	config := a.requestConfig(ctx)
 
 	if !config.External.Email.Enabled {
 		return nil, apierrors.NewBadRequestError(apierrors.ErrorCodeEmailProviderDisabled, "Email logins are disabled")
@@ -108,34 +113,119 @@ func (a *API) requireEmailProvider(w http.ResponseWriter, req *http.Request) (co
 
 func (a *API) verifyCaptcha(w http.ResponseWriter, req *http.Request) (context.Context, error) {
 	ctx := req.Context()
-	config := a.config
+	config := a.requestConfig(ctx)
 
-	if !config.Security.Captcha.Enabled {
+	route := captchaRoute(req)
+	if !config.Security.Captcha.RequiresCaptcha(route) {
 		return ctx, nil
 	}
-	if _, err := a.requireAdminCredentials(w, req); err == nil {
-		// skip captcha validation if authorization header contains an admin role
+	if a.adminCredentialPermissions(req).Has(captchaAdminPermission(route)) {
+		// skip captcha validation if the authorization header contains admin
+		// credentials allowed to act for the route
 		return ctx, nil
 	}
 	if shouldIgnore := isIgnoreCaptchaRoute(req); shouldIgnore {
 		return ctx, nil
 	}
 
+	if err := a.verifyCaptchaToken(req, route); err != nil {
+		return nil, err
+	}
+
+	return ctx, nil
+}
+
+// adminCredentialPermissions returns the admin permissions of the admin JWT
+// or admin API key in the authorization header, if any. Unlike
+// requireAdminCredentials, it doesn't record the use of admin API keys.
+func (a *API) adminCredentialPermissions(req *http.Request) AdminPermissions {
+	t, err := a.extractBearerToken(req)
+	if err != nil || t == "" {
+		return nil
+	}
+
+	if strings.HasPrefix(t, models.AdminAPIKeyPrefix) {
+		k, err := models.FindAdminAPIKeyByKey(a.db.WithContext(req.Context()), t)
+		if err != nil || !k.IsActive(time.Now()) {
+			return nil
+		}
+		return AdminPermissions(k.Permissions)
+	}
+
+	ctx, err := a.parseJWTClaims(t, req)
+	if err != nil {
+		return nil
+	}
+
+	ctx, err = a.requireAdmin(ctx)
+	if err != nil {
+		return nil
+	}
+
+	return getAdminPermissions(ctx)
+}
+
+// captchaAdminPermission returns the admin permission that lets requests to
+// the captcha route skip captcha.
+func captchaAdminPermission(route string) AdminPermission {
+	if route == "sso" {
+		return AdminPermissionSSOManage
+	}
+
+	return AdminPermissionUsersWrite
+}
+
+// verifyCaptchaToken verifies the captcha token in the request body for the
+// route.
+func (a *API) verifyCaptchaToken(req *http.Request, route string) error {
+	config := a.requestConfig(req.Context())
+
 	body := &security.GotrueRequest{}
 	if err := retrieveRequestParams(req, body); err != nil {
-		return nil, err
+		return err
 	}
 
-	verificationResult, err := security.VerifyRequest(body, utilities.GetIPAddress(req), strings.TrimSpace(config.Security.Captcha.Secret), config.Security.Captcha.Provider)
+	verificationResult, err := security.VerifyRequest(body, utilities.GetIPAddress(req), &config.Security.Captcha)
 	if err != nil {
-		return nil, apierrors.NewInternalServerError("captcha verification process failed").WithInternalError(err)
+		return apierrors.NewInternalServerError("captcha verification process failed").WithInternalError(err)
 	}
 
 	if !verificationResult.Success {
-		return nil, apierrors.NewBadRequestError(apierrors.ErrorCodeCaptchaFailed, "captcha protection: request disallowed (%s)", strings.Join(verificationResult.ErrorCodes, ", "))
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeCaptchaFailed, "captcha protection: request disallowed (%s)", strings.Join(verificationResult.ErrorCodes, ", "))
 	}
 
-	return ctx, nil
+	if config.Security.Captcha.Provider == conf.CaptchaProviderRecaptchaV3 {
+		if verificationResult.Score < config.Security.Captcha.MinScoreFor(route) {
+			return apierrors.NewBadRequestError(apierrors.ErrorCodeCaptchaFailed, "captcha protection: request disallowed (score too low)")
+		}
+
+		if action, ok := config.Security.Captcha.RouteActions[route]; ok && verificationResult.Action != action {
+			return apierrors.NewBadRequestError(apierrors.ErrorCodeCaptchaFailed, "captcha protection: request disallowed (unexpected action %q)", verificationResult.Action)
+		}
+	}
+
+	return nil
+}
+
+// captchaRoute returns which of conf.CaptchaRoutes the request is for, or
+// an empty string.
+func captchaRoute(req *http.Request) string {
+	switch strings.TrimSuffix(req.URL.Path, "/") {
+	case "/signup":
+		return "signup"
+	case "/token":
+		return "token"
+	case "/otp", "/magiclink":
+		return "otp"
+	case "/recover":
+		return "recover"
+	case "/resend":
+		return "resend"
+	case "/sso":
+		return "sso"
+	}
+
+	return ""
 }
 
 func isIgnoreCaptchaRoute(req *http.Request) bool {
@@ -165,7 +255,7 @@ func isIgnoreCaptchaRoute(req *http.Request) bool {
 
 func (a *API) isValidExternalHost(w http.ResponseWriter, req *http.Request) (context.Context, error) {
 	ctx := req.Context()
-	config := a.config
+	config := a.requestConfig(ctx)
 
 	xForwardedHost := req.Header.Get("X-Forwarded-Host")
 	xForwardedProto := req.Header.Get("X-Forwarded-Proto")
@@ -364,9 +454,21 @@ func (t *timeoutResponseWriter) finallyWrite(w http.ResponseWriter) {
 	}
 }
 
+// untimedPaths are the paths of bulk endpoints that stream their request or
+// response, which the request timeout doesn't apply to.
+var untimedPaths = map[string]bool{
+	"/admin/users/import": true,
+	"/admin/users/export": true,
+}
+
 func timeoutMiddleware(timeout time.Duration) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if untimedPaths[strings.TrimSuffix(r.URL.Path, "/")] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
 			ctx, cancel := context.WithTimeout(r.Context(), timeout)
 			defer cancel()
 
@@ -19,6 +19,7 @@ import (
 	"github.com/stretchr/testify/suite"
 	"github.com/supabase/auth/internal/api/apierrors"
 	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/models"
 	"github.com/supabase/auth/internal/storage"
 )
 
@@ -134,6 +135,64 @@ func (ts *MiddlewareTestSuite) TestVerifyCaptchaValid() {
 	}
 }
 
+func (ts *MiddlewareTestSuite) TestVerifyCaptchaAdminPermissions() {
+	ts.Config.Security.Captcha.Enabled = true
+	ts.Config.Security.Captcha.Provider = "hcaptcha"
+	ts.Config.Security.Captcha.Secret = HCaptchaSecret
+
+	auditorJwt, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &AccessTokenClaims{
+		Role:             "supabase_admin",
+		AdminPermissions: []string{string(AdminPermissionAuditRead)},
+	}).SignedString([]byte(ts.Config.JWT.Secret))
+	require.NoError(ts.T(), err)
+
+	auditorKey, key := models.NewAdminAPIKey("auditor", []string{string(AdminPermissionAuditRead)}, nil)
+	require.NoError(ts.T(), ts.API.db.Create(auditorKey))
+	defer func() {
+		require.NoError(ts.T(), ts.API.db.Destroy(auditorKey))
+	}()
+
+	writerKey, writer := models.NewAdminAPIKey("writer", []string{string(AdminPermissionUsersWrite)}, nil)
+	require.NoError(ts.T(), ts.API.db.Create(writerKey))
+	defer func() {
+		require.NoError(ts.T(), ts.API.db.Destroy(writerKey))
+	}()
+
+	cases := []struct {
+		desc     string
+		bearer   string
+		expected bool
+	}{
+		{"JWT without users:write", auditorJwt, false},
+		{"API key without users:write", key, false},
+		{"API key with users:write", writer, true},
+	}
+	for _, c := range cases {
+		ts.Run(c.desc, func() {
+			var buffer bytes.Buffer
+			require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
+				"email":    "test@example.com",
+				"password": "secret",
+			}))
+			req := httptest.NewRequest(http.MethodPost, "http://localhost/signup", &buffer)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+c.bearer)
+
+			_, err := ts.API.verifyCaptcha(httptest.NewRecorder(), req)
+			if c.expected {
+				require.NoError(ts.T(), err)
+			} else {
+				require.Error(ts.T(), err)
+			}
+		})
+	}
+
+	// checking the keys doesn't record their use
+	found, err := models.FindAdminAPIKeyByKey(ts.API.db, writer)
+	require.NoError(ts.T(), err)
+	require.Nil(ts.T(), found.LastUsedAt)
+}
+
 func (ts *MiddlewareTestSuite) TestVerifyCaptchaInvalid() {
 	cases := []struct {
 		desc         string
@@ -0,0 +1,258 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/supabase/auth/internal/api/apierrors"
+	"github.com/supabase/auth/internal/conf"
+	mail "github.com/supabase/auth/internal/mailer"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/observability"
+	"github.com/supabase/auth/internal/storage"
+	"github.com/supabase/auth/internal/utilities"
+)
+
+// RevokeSignInClaims are the JWT claims of the link in new device
+// notifications. They share no claims with access tokens, so that neither
+// can be used as the other.
+type RevokeSignInClaims struct {
+	jwt.RegisteredClaims
+	RevokeUserID    string `json:"revoke_user_id"`
+	RevokeSessionID string `json:"revoke_session_id"`
+}
+
+// revokeSignInConfirmTemplate asks to confirm revoking a sign in, so that
+// mail scanners following the link don't revoke it.
+var revokeSignInConfirmTemplate = template.Must(template.New("revoke-sign-in").Parse(`<!DOCTYPE html>` +
+	`<html><head><meta name="viewport" content="width=device-width, initial-scale=1" /></head><body>` +
+	`<p>Revoke this sign in? You are signed out everywhere and, if you have a password, it is cleared and you get an email to set a new one.</p>` +
+	`<form method="post" action="{{.URL}}">` +
+	`<input type="hidden" name="token" value="{{.Token}}" />` +
+	`<input type="submit" value="Revoke sign in" />` +
+	`</form></body></html>`))
+
+// isNewDevice reports whether the session was created from an IP address and
+// user agent the user never signed in from before. It must be called before
+// the sign in is recorded.
+func (a *API) isNewDevice(r *http.Request, tx *storage.Connection, user *models.User, sessionID uuid.UUID) bool {
+	config := a.requestConfig(r.Context())
+
+	if !config.Mailer.Notifications.NewDevice.Enabled || user.IsAnonymous || user.GetEmail() == "" {
+		return false
+	}
+
+	known, signedIn, err := models.HasSignedInFrom(tx, user.ID, utilities.GetIPAddress(r), r.Header.Get("User-Agent"), sessionID)
+	if err != nil {
+		observability.GetLogEntry(r).Entry.WithError(err).Warn("Error checking for a new device")
+		return false
+	}
+
+	return !known && signedIn
+}
+
+// notifyNewDevice notifies the user of a sign in from a new device, with a
+// link to revoke it. It is called once the sign in is committed, and failing
+// to notify the user doesn't fail the sign in.
+func (a *API) notifyNewDevice(r *http.Request, user *models.User, sessionID uuid.UUID) {
+	config := a.requestConfig(r.Context())
+	ctx := r.Context()
+	log := observability.GetLogEntry(r).Entry
+
+	ipAddress := utilities.GetIPAddress(r)
+	userAgent := r.Header.Get("User-Agent")
+
+	now := time.Now()
+	claims := RevokeSignInClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(config.Mailer.Notifications.RevokeLinkExpiry)),
+		},
+		RevokeUserID:    user.ID.String(),
+		RevokeSessionID: sessionID.String(),
+	}
+
+	token, err := signJwt(&config.JWT, claims)
+	if err != nil {
+		log.WithError(err).Warn("Error signing revoke link")
+		return
+	}
+
+	revokeURL := getExternalHost(ctx).ResolveReference(&url.URL{
+		Path:     "/revoke_sign_in",
+		RawQuery: url.Values{"token": {token}}.Encode(),
+	})
+
+	a.notifyUser(r, user, mail.NewDeviceNotification, map[string]interface{}{
+		"IPAddress":  ipAddress,
+		"UserAgent":  userAgent,
+		"SignedInAt": now.UTC().Format(time.RFC1123),
+		"RevokeURL":  revokeURL.String(),
+	})
+}
+
+// parseRevokeSignInToken verifies the token of a revoke link, returning the
+// user and session it revokes.
+func (a *API) parseRevokeSignInToken(token string) (uuid.UUID, uuid.UUID, error) {
+	config := a.config
+	claims := RevokeSignInClaims{}
+
+	p := jwt.NewParser(jwt.WithValidMethods(config.JWT.ValidMethods), jwt.WithExpirationRequired())
+	if _, err := p.ParseWithClaims(token, &claims, func(token *jwt.Token) (interface{}, error) {
+		if kid, ok := token.Header["kid"]; ok {
+			if kidStr, ok := kid.(string); ok {
+				return conf.FindPublicKeyByKid(kidStr, &config.JWT)
+			}
+		}
+		if alg, ok := token.Header["alg"]; ok {
+			if alg == jwt.SigningMethodHS256.Name {
+				// preserve backward compatibility for cases where the kid is not set
+				return []byte(config.JWT.Secret), nil
+			}
+		}
+		return nil, fmt.Errorf("missing kid")
+	}); err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+
+	userID, err := uuid.FromString(claims.RevokeUserID)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+
+	sessionID, err := uuid.FromString(claims.RevokeSessionID)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+
+	return userID, sessionID, nil
+}
+
+// RevokeSignInConfirm handles the link in new device notifications, asking
+// to confirm revoking the sign in. It doesn't check the token, so that
+// following the link has no effect.
+func (a *API) RevokeSignInConfirm(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	actionURL := getExternalHost(ctx).ResolveReference(&url.URL{
+		Path: "/revoke_sign_in",
+	})
+
+	var body bytes.Buffer
+	if err := revokeSignInConfirmTemplate.Execute(&body, map[string]string{
+		"URL":   actionURL.String(),
+		"Token": r.URL.Query().Get("token"),
+	}); err != nil {
+		return apierrors.NewInternalServerError("Error rendering revoke sign in page").WithInternalError(err)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.WriteHeader(http.StatusOK)
+	_, err := w.Write(body.Bytes())
+
+	return err
+}
+
+// RevokeSignIn signs the user of the confirmed revoke link out everywhere
+// and, if the user has a password, clears it and sends a password recovery
+// email.
+func (a *API) RevokeSignIn(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	config := a.requestConfig(ctx)
+
+	resetPassword, err := a.revokeSignIn(r, db)
+	if err != nil {
+		herr, ok := err.(*HTTPError)
+		if !ok {
+			herr = apierrors.NewInternalServerError("Error revoking sign in").WithInternalError(err)
+		}
+
+		rurl, perr := a.prepErrorRedirectURL(herr, r, config.SiteURL, models.ImplicitFlow)
+		if perr != nil {
+			return perr
+		}
+
+		http.Redirect(w, r, rurl, http.StatusSeeOther)
+		return nil
+	}
+
+	message := "The sign in was revoked and you were signed out everywhere"
+	if resetPassword {
+		message = "The sign in was revoked, check your email to reset your password"
+	}
+
+	rurl, err := a.prepRedirectURL(message, config.SiteURL, models.ImplicitFlow)
+	if err != nil {
+		return err
+	}
+
+	http.Redirect(w, r, rurl, http.StatusSeeOther)
+	return nil
+}
+
+// revokeSignIn signs the user out everywhere and reports whether the user's
+// password was cleared.
+func (a *API) revokeSignIn(r *http.Request, db *storage.Connection) (bool, error) {
+	userID, sessionID, err := a.parseRevokeSignInToken(r.PostFormValue("token"))
+	if err != nil {
+		return false, apierrors.NewForbiddenError(apierrors.ErrorCodeRevokeLinkInvalid, "Revoke link is invalid or has expired").WithInternalError(err)
+	}
+
+	user, err := models.FindUserByID(db, userID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return false, apierrors.NewForbiddenError(apierrors.ErrorCodeRevokeLinkInvalid, "Revoke link is invalid or has expired")
+		}
+		return false, apierrors.NewInternalServerError("Database error finding user").WithInternalError(err)
+	}
+
+	// the link only works while the session exists, so that a password
+	// reset after following it isn't undone by following it again
+	if _, err := models.FindSessionByID(db, sessionID, false); err != nil {
+		if models.IsNotFoundError(err) {
+			return false, apierrors.NewForbiddenError(apierrors.ErrorCodeRevokeLinkInvalid, "The sign in has already been revoked")
+		}
+		return false, apierrors.NewInternalServerError("Database error finding session").WithInternalError(err)
+	}
+
+	resetPassword := user.HasPassword()
+
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		if terr := models.Logout(tx, user.ID); terr != nil {
+			return terr
+		}
+
+		if resetPassword {
+			user.EncryptedPassword = nil
+			if terr := user.UpdatePassword(tx, nil); terr != nil {
+				return terr
+			}
+		}
+
+		return models.NewAuditLogEntry(r, tx, user, models.SignInRevokedAction, "", map[string]interface{}{
+			"session_id":     sessionID,
+			"password_reset": resetPassword,
+		})
+	}); err != nil {
+		return false, apierrors.NewInternalServerError("Database error revoking sign in").WithInternalError(err)
+	}
+
+	if resetPassword {
+		if err := db.Transaction(func(tx *storage.Connection) error {
+			return a.sendPasswordRecovery(r, tx, user, models.ImplicitFlow)
+		}); err != nil {
+			observability.GetLogEntry(r).Entry.WithError(err).Warn("Error sending recovery email after revoking sign in")
+		}
+	}
+
+	return resetPassword, nil
+}
@@ -0,0 +1,196 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gofrs/uuid"
+	"github.com/supabase/auth/internal/api/apierrors"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// SwitchOrganizationParams are the parameters the switch organization
+// endpoint accepts. A null organization ID deselects the organization.
+type SwitchOrganizationParams struct {
+	OrganizationID *uuid.UUID `json:"organization_id"`
+}
+
+// UserOrganizationsResponse lists the organizations of the user.
+type UserOrganizationsResponse struct {
+	Organizations []*models.UserOrganization `json:"organizations"`
+}
+
+// UserListOrganizations lists the organizations the user is a member of.
+func (a *API) UserListOrganizations(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	user := getUser(ctx)
+
+	organizations, err := models.FindUserOrganizations(db, user.ID)
+	if err != nil {
+		return apierrors.NewInternalServerError("Database error finding organizations").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, &UserOrganizationsResponse{
+		Organizations: organizations,
+	})
+}
+
+// UserSwitchOrganization selects one of the user's organizations for the
+// current session and issues a new access token with the organization's
+// claims.
+func (a *API) UserSwitchOrganization(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	config := a.requestConfig(ctx)
+	user := getUser(ctx)
+	session := getSession(ctx)
+
+	if session == nil {
+		return apierrors.NewForbiddenError(apierrors.ErrorCodeSessionNotFound, "Organizations can only be switched with a session")
+	}
+
+	params := &SwitchOrganizationParams{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	if params.OrganizationID != nil {
+		if _, err := models.FindOrganizationMembership(db, *params.OrganizationID, user.ID); err != nil {
+			if models.IsNotFoundError(err) {
+				return apierrors.NewNotFoundError(apierrors.ErrorCodeOrganizationNotFound, "Organization not found")
+			}
+			return apierrors.NewInternalServerError("Database error finding organization").WithInternalError(err)
+		}
+	}
+
+	var tokenString string
+	var expiresAt int64
+	var refreshToken *models.RefreshToken
+
+	err := db.Transaction(func(tx *storage.Connection) error {
+		if terr := session.SelectOrganization(tx, params.OrganizationID); terr != nil {
+			return terr
+		}
+
+		currentToken, terr := models.FindTokenBySessionID(tx, &session.ID)
+		if terr != nil {
+			return terr
+		}
+
+		// Swap to ensure current token is the latest one
+		refreshToken, terr = models.GrantRefreshTokenSwap(r, tx, user, currentToken)
+		if terr != nil {
+			return terr
+		}
+
+		tokenString, expiresAt, terr = a.generateAccessToken(r, tx, user, &session.ID, models.TokenRefresh)
+		if terr != nil {
+			httpErr, ok := terr.(*HTTPError)
+			if ok {
+				return httpErr
+			}
+			return apierrors.NewInternalServerError("error generating jwt token").WithInternalError(terr)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, &AccessTokenResponse{
+		Token:        tokenString,
+		TokenType:    "bearer",
+		ExpiresIn:    config.JWT.Exp,
+		ExpiresAt:    expiresAt,
+		RefreshToken: refreshToken.Token,
+		User:         user,
+	})
+}
+
+// sessionOrganizationMembership returns the user's membership of the
+// organization selected by the session. Sessions that haven't selected an
+// organization default to the user's only organization, so that users of a
+// single organization don't need to switch to it. It returns nil if there's
+// no organization to add to the access token.
+func sessionOrganizationMembership(tx *storage.Connection, user *models.User, session *models.Session) (*models.OrganizationMember, error) {
+	if session.OrganizationID == nil {
+		organizations, err := models.FindUserOrganizations(tx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(organizations) != 1 {
+			return nil, nil
+		}
+
+		return models.NewOrganizationMember(organizations[0].ID, user.ID, organizations[0].Role), nil
+	}
+
+	membership, err := models.FindOrganizationMembership(tx, *session.OrganizationID, user.ID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			// the user has left the organization since selecting it
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return membership, nil
+}
+
+// addOrganizationMember makes the user a member of the organization and
+// records it in the audit log.
+func addOrganizationMember(r *http.Request, tx *storage.Connection, actor *models.User, organization *models.Organization, user *models.User, role string) error {
+	member, err := models.AddOrganizationMember(tx, organization.ID, user.ID, role)
+	if err != nil {
+		return apierrors.NewInternalServerError("Database error adding organization member").WithInternalError(err)
+	}
+
+	return models.NewAuditLogEntry(r, tx, actor, models.OrganizationMemberAddedAction, "", map[string]interface{}{
+		"organization_id":   organization.ID,
+		"organization_slug": organization.Slug,
+		"user_id":           user.ID,
+		"role":              member.Role,
+	})
+}
+
+// joinSSOProviderOrganization makes a user signing in with an SSO provider a
+// member of the organization the provider is attached to, and returns the
+// organization to select for the new session. Other external providers
+// return nil.
+func joinSSOProviderOrganization(tx *storage.Connection, user *models.User, providerType string) (*uuid.UUID, error) {
+	if !strings.HasPrefix(providerType, ssoOIDCProviderPrefix) {
+		return nil, nil
+	}
+
+	id, err := uuid.FromString(strings.TrimPrefix(providerType, ssoOIDCProviderPrefix))
+	if err != nil {
+		return nil, nil
+	}
+
+	ssoProvider, err := models.FindSSOProviderByID(tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if ssoProvider.OrganizationID == nil {
+		return nil, nil
+	}
+
+	if _, err := models.AddOrganizationMember(tx, *ssoProvider.OrganizationID, user.ID, models.OrganizationRoleMember); err != nil {
+		return nil, err
+	}
+
+	return ssoProvider.OrganizationID, nil
+}
+
+func uuidPtrEqual(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
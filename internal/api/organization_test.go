@@ -0,0 +1,355 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/models"
+)
+
+type OrganizationTestSuite struct {
+	suite.Suite
+	API      *API
+	Config   *conf.GlobalConfiguration
+	AdminJWT string
+}
+
+func TestOrganization(t *testing.T) {
+	api, config, err := setupAPIForTest()
+	require.NoError(t, err)
+
+	ts := &OrganizationTestSuite{
+		API:    api,
+		Config: config,
+	}
+	defer api.db.Close()
+
+	suite.Run(t, ts)
+}
+
+func (ts *OrganizationTestSuite) SetupTest() {
+	models.TruncateAll(ts.API.db)
+
+	claims := &AccessTokenClaims{
+		Role: "supabase_admin",
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(ts.Config.JWT.Secret))
+	require.NoError(ts.T(), err, "Error generating admin jwt")
+
+	ts.AdminJWT = token
+}
+
+func (ts *OrganizationTestSuite) request(method, url, token string, body interface{}) *httptest.ResponseRecorder {
+	var buffer bytes.Buffer
+	if body != nil {
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(body))
+	}
+
+	req := httptest.NewRequest(method, url, &buffer)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+
+	ts.API.handler.ServeHTTP(w, req)
+
+	return w
+}
+
+func (ts *OrganizationTestSuite) createOrganization(name, slug string) *models.Organization {
+	w := ts.request(http.MethodPost, "http://localhost/admin/organizations", ts.AdminJWT, map[string]interface{}{
+		"name": name,
+		"slug": slug,
+	})
+	require.Equal(ts.T(), http.StatusCreated, w.Code, w.Body.String())
+
+	organization := &models.Organization{}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(organization))
+
+	return organization
+}
+
+func (ts *OrganizationTestSuite) createUser(email string) *models.User {
+	u, err := models.NewUser("", email, "password", ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), ts.API.db.Create(u))
+
+	return u
+}
+
+// signIn creates a session for the user and returns its access token.
+func (ts *OrganizationTestSuite) signIn(u *models.User) string {
+	refreshToken, err := models.GrantAuthenticatedUser(ts.API.db, u, models.GrantParams{})
+	require.NoError(ts.T(), err)
+
+	req := httptest.NewRequest(http.MethodPost, "/token?grant_type=password", nil)
+	token, _, err := ts.API.generateAccessToken(req, ts.API.db, u, refreshToken.SessionId, models.PasswordGrant)
+	require.NoError(ts.T(), err)
+
+	return token
+}
+
+func (ts *OrganizationTestSuite) tokenClaims(token string) *AccessTokenClaims {
+	claims := &AccessTokenClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(ts.Config.JWT.Secret), nil
+	})
+	require.NoError(ts.T(), err)
+
+	return claims
+}
+
+func (ts *OrganizationTestSuite) TestAdminOrganizations() {
+	acme := ts.createOrganization("Acme", "acme")
+
+	cases := []struct {
+		desc     string
+		body     map[string]interface{}
+		expected int
+	}{
+		{
+			desc:     "Missing name",
+			body:     map[string]interface{}{"slug": "other"},
+			expected: http.StatusBadRequest,
+		},
+		{
+			desc:     "Invalid slug",
+			body:     map[string]interface{}{"name": "Other", "slug": "Not A Slug"},
+			expected: http.StatusBadRequest,
+		},
+		{
+			desc:     "Duplicate slug",
+			body:     map[string]interface{}{"name": "Other", "slug": "acme"},
+			expected: http.StatusConflict,
+		},
+	}
+
+	for _, c := range cases {
+		ts.Run(c.desc, func() {
+			w := ts.request(http.MethodPost, "http://localhost/admin/organizations", ts.AdminJWT, c.body)
+			require.Equal(ts.T(), c.expected, w.Code, w.Body.String())
+		})
+	}
+
+	w := ts.request(http.MethodPut, "http://localhost/admin/organizations/"+acme.ID.String(), ts.AdminJWT, map[string]interface{}{
+		"name":     "Acme Corp",
+		"metadata": map[string]interface{}{"plan": "enterprise"},
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+
+	w = ts.request(http.MethodGet, "http://localhost/admin/organizations", ts.AdminJWT, nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+	require.Equal(ts.T(), "1", w.Header().Get("X-Total-Count"))
+
+	var list AdminListOrganizationsResponse
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&list))
+	require.Len(ts.T(), list.Organizations, 1)
+	require.Equal(ts.T(), "Acme Corp", list.Organizations[0].Name)
+	require.Equal(ts.T(), "acme", list.Organizations[0].Slug)
+	require.Equal(ts.T(), "enterprise", list.Organizations[0].Metadata["plan"])
+
+	w = ts.request(http.MethodDelete, "http://localhost/admin/organizations/"+acme.ID.String(), ts.AdminJWT, nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	w = ts.request(http.MethodGet, "http://localhost/admin/organizations/"+acme.ID.String(), ts.AdminJWT, nil)
+	require.Equal(ts.T(), http.StatusNotFound, w.Code)
+}
+
+func (ts *OrganizationTestSuite) TestAdminOrganizationMembers() {
+	acme := ts.createOrganization("Acme", "acme")
+	u := ts.createUser("member@example.com")
+	membersURL := "http://localhost/admin/organizations/" + acme.ID.String() + "/members"
+
+	w := ts.request(http.MethodPost, membersURL, ts.AdminJWT, map[string]interface{}{
+		"user_id": u.ID,
+		"role":    "superuser",
+	})
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+
+	w = ts.request(http.MethodPost, membersURL, ts.AdminJWT, map[string]interface{}{
+		"user_id": u.ID,
+	})
+	require.Equal(ts.T(), http.StatusCreated, w.Code, w.Body.String())
+
+	member := &models.OrganizationMember{}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(member))
+	require.Equal(ts.T(), models.OrganizationRoleMember, member.Role)
+
+	w = ts.request(http.MethodPost, membersURL, ts.AdminJWT, map[string]interface{}{
+		"user_id": u.ID,
+	})
+	require.Equal(ts.T(), http.StatusConflict, w.Code)
+
+	w = ts.request(http.MethodPut, membersURL+"/"+u.ID.String(), ts.AdminJWT, map[string]interface{}{
+		"role": models.OrganizationRoleAdmin,
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+
+	w = ts.request(http.MethodGet, membersURL, ts.AdminJWT, nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var list AdminListOrganizationMembersResponse
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&list))
+	require.Len(ts.T(), list.Members, 1)
+	require.Equal(ts.T(), models.OrganizationRoleAdmin, list.Members[0].Role)
+
+	w = ts.request(http.MethodDelete, membersURL+"/"+u.ID.String(), ts.AdminJWT, nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	w = ts.request(http.MethodDelete, membersURL+"/"+u.ID.String(), ts.AdminJWT, nil)
+	require.Equal(ts.T(), http.StatusNotFound, w.Code)
+}
+
+func (ts *OrganizationTestSuite) TestSwitchOrganization() {
+	acme := ts.createOrganization("Acme", "acme")
+	globex := ts.createOrganization("Globex", "globex")
+	initech := ts.createOrganization("Initech", "initech")
+	u := ts.createUser("member@example.com")
+
+	_, err := models.AddOrganizationMember(ts.API.db, acme.ID, u.ID, models.OrganizationRoleOwner)
+	require.NoError(ts.T(), err)
+
+	// users of a single organization get its claims without switching
+	claims := ts.tokenClaims(ts.signIn(u))
+	require.Equal(ts.T(), acme.ID.String(), claims.OrganizationID)
+	require.Equal(ts.T(), models.OrganizationRoleOwner, claims.OrganizationRole)
+
+	_, err = models.AddOrganizationMember(ts.API.db, globex.ID, u.ID, models.OrganizationRoleMember)
+	require.NoError(ts.T(), err)
+
+	token := ts.signIn(u)
+	claims = ts.tokenClaims(token)
+	require.Empty(ts.T(), claims.OrganizationID)
+
+	w := ts.request(http.MethodGet, "http://localhost/user/organizations", token, nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var organizations UserOrganizationsResponse
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&organizations))
+	require.Len(ts.T(), organizations.Organizations, 2)
+	require.Equal(ts.T(), "acme", organizations.Organizations[0].Slug)
+	require.Equal(ts.T(), models.OrganizationRoleOwner, organizations.Organizations[0].Role)
+	require.Equal(ts.T(), "globex", organizations.Organizations[1].Slug)
+
+	w = ts.request(http.MethodPost, "http://localhost/user/organizations/switch", token, map[string]interface{}{
+		"organization_id": initech.ID,
+	})
+	require.Equal(ts.T(), http.StatusNotFound, w.Code)
+
+	w = ts.request(http.MethodPost, "http://localhost/user/organizations/switch", token, map[string]interface{}{
+		"organization_id": globex.ID,
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+
+	var response AccessTokenResponse
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&response))
+	require.NotEmpty(ts.T(), response.RefreshToken)
+
+	claims = ts.tokenClaims(response.Token)
+	require.Equal(ts.T(), globex.ID.String(), claims.OrganizationID)
+	require.Equal(ts.T(), models.OrganizationRoleMember, claims.OrganizationRole)
+
+	// the selected organization is kept when the session is refreshed
+	w = ts.request(http.MethodPost, "http://localhost/token?grant_type=refresh_token", "", map[string]interface{}{
+		"refresh_token": response.RefreshToken,
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&response))
+	require.Equal(ts.T(), globex.ID.String(), ts.tokenClaims(response.Token).OrganizationID)
+
+	w = ts.request(http.MethodPost, "http://localhost/user/organizations/switch", response.Token, map[string]interface{}{
+		"organization_id": nil,
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&response))
+	require.Empty(ts.T(), ts.tokenClaims(response.Token).OrganizationID)
+}
+
+func (ts *OrganizationTestSuite) TestInviteToOrganization() {
+	acme := ts.createOrganization("Acme", "acme")
+
+	w := ts.request(http.MethodPost, "http://localhost/invite", ts.AdminJWT, map[string]interface{}{
+		"email":             "invited@example.com",
+		"organization_id":   acme.ID,
+		"organization_role": models.OrganizationRoleAdmin,
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+
+	invited, err := models.FindUserByEmailAndAudience(ts.API.db, "invited@example.com", ts.Config.JWT.Aud)
+	require.NoError(ts.T(), err)
+
+	member, err := models.FindOrganizationMembership(ts.API.db, acme.ID, invited.ID)
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), models.OrganizationRoleAdmin, member.Role)
+
+	// confirmed users are added without an invite
+	u := ts.createUser("existing@example.com")
+	require.NoError(ts.T(), u.Confirm(ts.API.db))
+
+	w = ts.request(http.MethodPost, "http://localhost/invite", ts.AdminJWT, map[string]interface{}{
+		"email": "existing@example.com",
+	})
+	require.Equal(ts.T(), http.StatusUnprocessableEntity, w.Code)
+
+	w = ts.request(http.MethodPost, "http://localhost/invite", ts.AdminJWT, map[string]interface{}{
+		"email":           "existing@example.com",
+		"organization_id": acme.ID,
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+
+	member, err = models.FindOrganizationMembership(ts.API.db, acme.ID, u.ID)
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), models.OrganizationRoleMember, member.Role)
+
+	w = ts.request(http.MethodPost, "http://localhost/invite", ts.AdminJWT, map[string]interface{}{
+		"email":           "other@example.com",
+		"organization_id": uuid.Must(uuid.NewV4()),
+	})
+	require.Equal(ts.T(), http.StatusNotFound, w.Code)
+}
+
+func TestOrganizationParamsValidate(t *testing.T) {
+	for _, slug := range []string{"acme", "acme-corp", "a1", "123"} {
+		p := &OrganizationParams{Name: "Acme", Slug: slug}
+		require.NoError(t, p.validate(false), slug)
+	}
+
+	for _, slug := range []string{"Acme", "acme corp", "-acme", "acme-", "acme--corp", "acme_corp"} {
+		p := &OrganizationParams{Name: "Acme", Slug: slug}
+		require.Error(t, p.validate(false), slug)
+	}
+
+	p := &OrganizationParams{Slug: "acme"}
+	require.Error(t, p.validate(false))
+	require.NoError(t, p.validate(true))
+}
+
+func TestUUIDPtrEqual(t *testing.T) {
+	a := uuid.Must(uuid.NewV4())
+	b := uuid.Must(uuid.NewV4())
+	aCopy := a
+
+	cases := []struct {
+		a, b     *uuid.UUID
+		expected bool
+	}{
+		{nil, nil, true},
+		{&a, nil, false},
+		{nil, &a, false},
+		{&a, &aCopy, true},
+		{&a, &b, false},
+	}
+
+	for i, c := range cases {
+		require.Equal(t, c.expected, uuidPtrEqual(c.a, c.b), fmt.Sprintf("case %d", i))
+	}
+}
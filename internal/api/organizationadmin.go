@@ -0,0 +1,363 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gofrs/uuid"
+	"github.com/supabase/auth/internal/api/apierrors"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/observability"
+	"github.com/supabase/auth/internal/storage"
+)
+
+var organizationSlugRegexp = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// OrganizationParams are the parameters the organization admin endpoints
+// accept. Empty values are left unchanged on update.
+type OrganizationParams struct {
+	Name     string                 `json:"name"`
+	Slug     string                 `json:"slug"`
+	Metadata map[string]interface{} `json:"metadata"`
+}
+
+func (p *OrganizationParams) validate(forUpdate bool) error {
+	p.Name = strings.TrimSpace(p.Name)
+	p.Slug = strings.TrimSpace(p.Slug)
+
+	if !forUpdate {
+		if p.Name == "" {
+			return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Organization name is required")
+		}
+
+		if p.Slug == "" {
+			return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Organization slug is required")
+		}
+	}
+
+	if p.Slug != "" && !organizationSlugRegexp.MatchString(p.Slug) {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Organization slug must only contain lower case letters, digits and dashes")
+	}
+
+	return nil
+}
+
+// OrganizationMemberParams are the parameters the organization member admin
+// endpoints accept.
+type OrganizationMemberParams struct {
+	UserID uuid.UUID `json:"user_id"`
+	Role   string    `json:"role"`
+}
+
+func (p *OrganizationMemberParams) validate() error {
+	if p.Role == "" {
+		p.Role = models.OrganizationRoleMember
+	} else if !models.IsValidOrganizationRole(p.Role) {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Invalid organization role %q", p.Role)
+	}
+
+	return nil
+}
+
+// AdminListOrganizationsResponse is the response struct from the
+// adminOrganizations endpoint.
+type AdminListOrganizationsResponse struct {
+	Organizations []*models.Organization `json:"organizations"`
+}
+
+// AdminListOrganizationMembersResponse is the response struct from the
+// adminOrganizationMembers endpoint.
+type AdminListOrganizationMembersResponse struct {
+	Members []*models.OrganizationMember `json:"members"`
+}
+
+// loadOrganization looks for an organization_id parameter in the URL route
+// and adds the organization with that ID to the context.
+func (a *API) loadOrganization(w http.ResponseWriter, r *http.Request) (context.Context, error) {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	organizationID, err := uuid.FromString(chi.URLParam(r, "organization_id"))
+	if err != nil {
+		return nil, apierrors.NewNotFoundError(apierrors.ErrorCodeValidationFailed, "organization_id must be an UUID")
+	}
+
+	observability.LogEntrySetField(r, "organization_id", organizationID)
+
+	organization, err := models.FindOrganizationByID(db, organizationID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return nil, apierrors.NewNotFoundError(apierrors.ErrorCodeOrganizationNotFound, "Organization not found")
+		}
+		return nil, apierrors.NewInternalServerError("Database error loading organization").WithInternalError(err)
+	}
+
+	return withOrganization(ctx, organization), nil
+}
+
+// checkOrganizationSlug fails if another organization has the slug.
+func checkOrganizationSlug(db *storage.Connection, slug string) error {
+	existing, err := models.FindOrganizationBySlug(db, slug)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return nil
+		}
+		return apierrors.NewInternalServerError("Database error finding organization").WithInternalError(err)
+	}
+
+	return apierrors.NewHTTPError(http.StatusConflict, apierrors.ErrorCodeOrganizationExists, "Organization with slug '%s' already exists (%s)", slug, existing.ID.String())
+}
+
+// adminOrganizations lists the organizations, a page at a time.
+func (a *API) adminOrganizations(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	pageParams, err := paginate(r)
+	if err != nil {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Bad Pagination Parameters: %v", err).WithInternalError(err)
+	}
+
+	organizations, err := models.FindOrganizations(db, pageParams)
+	if err != nil {
+		return apierrors.NewInternalServerError("Database error finding organizations").WithInternalError(err)
+	}
+	addPaginationHeaders(w, r, pageParams)
+
+	return sendJSON(w, http.StatusOK, AdminListOrganizationsResponse{
+		Organizations: organizations,
+	})
+}
+
+// adminOrganizationCreate creates an organization.
+func (a *API) adminOrganizationCreate(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	params := &OrganizationParams{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	if err := params.validate(false /* <- forUpdate */); err != nil {
+		return err
+	}
+
+	if err := checkOrganizationSlug(db, params.Slug); err != nil {
+		return err
+	}
+
+	organization := models.NewOrganization(params.Name, params.Slug, params.Metadata)
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		return tx.Create(organization)
+	}); err != nil {
+		return apierrors.NewInternalServerError("Database error creating organization").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusCreated, organization)
+}
+
+// adminOrganizationGet returns a single organization.
+func (a *API) adminOrganizationGet(w http.ResponseWriter, r *http.Request) error {
+	return sendJSON(w, http.StatusOK, getOrganization(r.Context()))
+}
+
+// adminOrganizationUpdate updates the name, slug or metadata of an
+// organization.
+func (a *API) adminOrganizationUpdate(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	organization := getOrganization(ctx)
+
+	params := &OrganizationParams{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	if err := params.validate(true /* <- forUpdate */); err != nil {
+		return err
+	}
+
+	if params.Slug != "" && params.Slug != organization.Slug {
+		if err := checkOrganizationSlug(db, params.Slug); err != nil {
+			return err
+		}
+		organization.Slug = params.Slug
+	}
+
+	if params.Name != "" {
+		organization.Name = params.Name
+	}
+
+	if params.Metadata != nil {
+		organization.Metadata = params.Metadata
+	}
+
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		return tx.Update(organization)
+	}); err != nil {
+		return apierrors.NewInternalServerError("Database error updating organization").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, organization)
+}
+
+// adminOrganizationDelete deletes an organization along with its
+// memberships. Sessions and SSO providers of the organization are detached
+// from it.
+func (a *API) adminOrganizationDelete(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	organization := getOrganization(ctx)
+
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		return tx.Destroy(organization)
+	}); err != nil {
+		return apierrors.NewInternalServerError("Database error deleting organization").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, organization)
+}
+
+// adminOrganizationMembers lists the members of an organization.
+func (a *API) adminOrganizationMembers(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	organization := getOrganization(ctx)
+
+	members, err := models.FindOrganizationMembers(db, organization.ID)
+	if err != nil {
+		return apierrors.NewInternalServerError("Database error finding organization members").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, AdminListOrganizationMembersResponse{
+		Members: members,
+	})
+}
+
+// adminOrganizationMemberCreate adds a user to an organization.
+func (a *API) adminOrganizationMemberCreate(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	adminUser := getAdminUser(ctx)
+	organization := getOrganization(ctx)
+
+	params := &OrganizationMemberParams{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	if err := params.validate(); err != nil {
+		return err
+	}
+
+	user, err := models.FindUserByID(db, params.UserID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return apierrors.NewNotFoundError(apierrors.ErrorCodeUserNotFound, "User not found")
+		}
+		return apierrors.NewInternalServerError("Database error finding user").WithInternalError(err)
+	}
+
+	if _, err := models.FindOrganizationMembership(db, organization.ID, user.ID); err == nil {
+		return apierrors.NewHTTPError(http.StatusConflict, apierrors.ErrorCodeOrganizationMemberExists, "User is already a member of the organization")
+	} else if !models.IsNotFoundError(err) {
+		return apierrors.NewInternalServerError("Database error finding organization member").WithInternalError(err)
+	}
+
+	var member *models.OrganizationMember
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		if terr := addOrganizationMember(r, tx, adminUser, organization, user, params.Role); terr != nil {
+			return terr
+		}
+
+		var terr error
+		member, terr = models.FindOrganizationMembership(tx, organization.ID, user.ID)
+		return terr
+	}); err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusCreated, member)
+}
+
+// loadOrganizationMember loads the membership of the user in the URL route.
+// Use only after loadOrganization and loadUser.
+func loadOrganizationMember(db *storage.Connection, organization *models.Organization, user *models.User) (*models.OrganizationMember, error) {
+	member, err := models.FindOrganizationMembership(db, organization.ID, user.ID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return nil, apierrors.NewNotFoundError(apierrors.ErrorCodeOrganizationMemberNotFound, "Organization member not found")
+		}
+		return nil, apierrors.NewInternalServerError("Database error finding organization member").WithInternalError(err)
+	}
+
+	return member, nil
+}
+
+// adminOrganizationMemberUpdate changes the role of an organization member.
+func (a *API) adminOrganizationMemberUpdate(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	organization := getOrganization(ctx)
+	user := getUser(ctx)
+
+	params := &OrganizationMemberParams{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	if params.Role == "" {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Organization role is required")
+	}
+
+	if err := params.validate(); err != nil {
+		return err
+	}
+
+	member, err := loadOrganizationMember(db, organization, user)
+	if err != nil {
+		return err
+	}
+
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		return member.UpdateRole(tx, params.Role)
+	}); err != nil {
+		return apierrors.NewInternalServerError("Database error updating organization member").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, member)
+}
+
+// adminOrganizationMemberDelete removes a user from an organization.
+func (a *API) adminOrganizationMemberDelete(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	adminUser := getAdminUser(ctx)
+	organization := getOrganization(ctx)
+	user := getUser(ctx)
+
+	member, err := loadOrganizationMember(db, organization, user)
+	if err != nil {
+		return err
+	}
+
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		if terr := models.RemoveOrganizationMember(tx, member); terr != nil {
+			return terr
+		}
+
+		return models.NewAuditLogEntry(r, tx, adminUser, models.OrganizationMemberRemovedAction, "", map[string]interface{}{
+			"organization_id":   organization.ID,
+			"organization_slug": organization.Slug,
+			"user_id":           user.ID,
+		})
+	}); err != nil {
+		return apierrors.NewInternalServerError("Database error removing organization member").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, member)
+}
@@ -85,6 +85,10 @@ func (a *API) Otp(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
+	if err := a.enforceOtpRisk(r, params); err != nil {
+		return err
+	}
+
 	if params.Email != "" {
 		return a.MagicLink(w, r)
 	} else if params.Phone != "" {
@@ -94,6 +98,44 @@ func (a *API) Otp(w http.ResponseWriter, r *http.Request) error {
 	return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "One of email or phone must be set")
 }
 
+// enforceOtpRisk assesses the risk of sending an OTP to an existing user.
+// The session is only issued once the OTP is verified, so requiring MFA
+// falls back to requiring captcha.
+func (a *API) enforceOtpRisk(r *http.Request, params *OtpParams) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	if !a.config.Security.Risk.Enabled {
+		return nil
+	}
+
+	aud := a.requestAud(ctx, r)
+
+	var user *models.User
+	var err error
+	if params.Email != "" {
+		user, err = models.FindUserByEmailAndAudience(db, params.Email, aud)
+	} else if params.Phone != "" {
+		user, err = models.FindUserByPhoneAndAudience(db, formatPhoneNumber(params.Phone), aud)
+	} else {
+		return nil
+	}
+
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return nil
+		}
+		return apierrors.NewInternalServerError("Database error finding user").WithInternalError(err)
+	}
+
+	risk, err := a.assessRisk(r, db, user)
+	if err != nil {
+		return err
+	}
+
+	return a.enforceRisk(r, db, user, "otp", false, risk)
+}
+
 type SmsOtpResponse struct {
 	MessageID string `json:"message_id,omitempty"`
 }
@@ -102,7 +144,7 @@ type SmsOtpResponse struct {
 func (a *API) SmsOtp(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 	db := a.db.WithContext(ctx)
-	config := a.config
+	config := a.requestConfig(ctx)
 
 	if !config.External.Phone.Enabled {
 		return apierrors.NewBadRequestError(apierrors.ErrorCodePhoneProviderDisabled, "Unsupported phone provider")
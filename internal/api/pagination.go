@@ -37,6 +37,30 @@ func addPaginationHeaders(w http.ResponseWriter, r *http.Request, p *models.Pagi
 	w.Header().Add("X-Total-Count", fmt.Sprintf("%v", p.Count))
 }
 
+// addCursorPaginationHeaders links to the next page of cursor paginated
+// results, if there is one.
+func addCursorPaginationHeaders(w http.ResponseWriter, r *http.Request, nextCursor string) {
+	if nextCursor == "" {
+		return
+	}
+
+	url, _ := url.ParseRequestURI(r.URL.String())
+	query := url.Query()
+	query.Set("cursor", nextCursor)
+	url.RawQuery = query.Encode()
+
+	w.Header().Add("Link", "<"+url.String()+">; rel=\"next\"")
+}
+
+// addCountHeaders sets the total number of results, which may be an
+// estimate.
+func addCountHeaders(w http.ResponseWriter, count uint64, estimated bool) {
+	w.Header().Add("X-Total-Count", fmt.Sprintf("%v", count))
+	if estimated {
+		w.Header().Add("X-Total-Count-Estimated", "true")
+	}
+}
+
 func paginate(r *http.Request) (*models.Pagination, error) {
 	params := r.URL.Query()
 	queryPage := params.Get("page")
@@ -7,6 +7,9 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"github.com/supabase/auth/internal/api/apierrors"
+	"github.com/supabase/auth/internal/i18n"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/storage"
 )
 
 // BCrypt hashed passwords have a 72 character limit
@@ -19,31 +22,84 @@ const MaxPasswordLength = 72
 type WeakPasswordError struct {
 	Message string   `json:"message,omitempty"`
 	Reasons []string `json:"reasons,omitempty"`
+
+	// messages holds the i18n key and arguments of the message for each
+	// reason, so that Message can be localized.
+	messages []weakPasswordMessage
+}
+
+type weakPasswordMessage struct {
+	key  string
+	args []interface{}
 }
 
 func (e *WeakPasswordError) Error() string {
 	return e.Message
 }
 
-func (a *API) checkPasswordStrength(ctx context.Context, password string) error {
-	config := a.config
+// addReason adds a reason with the message identified by the i18n key. The
+// English message is appended to Message.
+func (e *WeakPasswordError) addReason(reason, key string, args ...interface{}) {
+	e.Reasons = append(e.Reasons, reason)
+	e.messages = append(e.messages, weakPasswordMessage{key: key, args: args})
+
+	message := i18n.GetMessagef(i18n.LanguageEnglish, key, args...)
+	if e.Message == "" {
+		e.Message = message
+	} else {
+		e.Message += " " + message
+	}
+}
+
+// LocalizedMessage returns Message in the language.
+func (e *WeakPasswordError) LocalizedMessage(lang i18n.Language) string {
+	if len(e.messages) == 0 {
+		return e.Message
+	}
+
+	messages := make([]string, 0, len(e.messages))
+	for _, m := range e.messages {
+		messages = append(messages, i18n.GetMessagef(lang, m.key, m.args...))
+	}
+
+	return strings.Join(messages, " ")
+}
+
+// checkPasswordStrength checks the password against the password policy.
+// userInputs are the email addresses and phone numbers of the user, which
+// must not be part of the password.
+func (a *API) checkPasswordStrength(ctx context.Context, password string, userInputs ...string) error {
+	config := a.requestConfig(ctx)
 
 	if len(password) > MaxPasswordLength {
 		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, fmt.Sprintf("Password cannot be longer than %v characters", MaxPasswordLength))
 	}
 
-	var messages, reasons []string
+	weakPasswordError := &WeakPasswordError{}
 
 	if len(password) < config.Password.MinLength {
-		reasons = append(reasons, "length")
-		messages = append(messages, fmt.Sprintf("Password should be at least %d characters.", config.Password.MinLength))
+		weakPasswordError.addReason("length", "weak_password_length", config.Password.MinLength)
+	} else if config.Password.MaxLength > 0 && len(password) > config.Password.MaxLength {
+		weakPasswordError.addReason("length", "weak_password_max_length", config.Password.MaxLength)
 	}
 
 	for _, characterSet := range config.Password.RequiredCharacters {
 		if characterSet != "" && !strings.ContainsAny(password, characterSet) {
-			reasons = append(reasons, "characters")
+			weakPasswordError.addReason("characters", "weak_password_characters", strings.Join(config.Password.RequiredCharacters, ", "))
 
-			messages = append(messages, fmt.Sprintf("Password should contain at least one character of each: %s.", strings.Join(config.Password.RequiredCharacters, ", ")))
+			break
+		}
+	}
+
+	if passwordContainsUserInput(password, userInputs) {
+		weakPasswordError.addReason("context", "weak_password_context")
+	}
+
+	lowerPassword := strings.ToLower(password)
+	for _, word := range config.Password.BannedWords {
+		word = strings.ToLower(strings.TrimSpace(word))
+		if word != "" && strings.Contains(lowerPassword, word) {
+			weakPasswordError.addReason("dictionary", "weak_password_dictionary")
 
 			break
 		}
@@ -58,17 +114,115 @@ func (a *API) checkPasswordStrength(ctx context.Context, password string) error
 				logrus.WithError(err).Warn("Unable to perform password strength check with HaveIBeenPwned.org, pwned passwords are being allowed")
 			}
 		} else if pwned {
-			reasons = append(reasons, "pwned")
-			messages = append(messages, "Password is known to be weak and easy to guess, please choose a different one.")
+			weakPasswordError.addReason("pwned", "weak_password_pwned")
+		}
+	}
+
+	if len(weakPasswordError.Reasons) > 0 {
+		return weakPasswordError
+	}
+
+	return nil
+}
+
+// hibpUnavailable fails all pwned password checks, used when the offline
+// dataset could not be loaded.
+type hibpUnavailable struct {
+	err error
+}
+
+func (h *hibpUnavailable) Check(ctx context.Context, password string) (bool, error) {
+	return false, h.err
+}
+
+// minUserInputLength is the minimum length of an email local-part or phone
+// number to be checked against a password, shorter ones would reject too many
+// passwords.
+const minUserInputLength = 3
+
+// passwordContainsUserInput reports whether the password contains the local
+// part of one of the email addresses or one of the phone numbers.
+func passwordContainsUserInput(password string, userInputs []string) bool {
+	lowerPassword := strings.ToLower(password)
+
+	for _, input := range userInputs {
+		input = strings.ToLower(strings.TrimSpace(input))
+
+		if at := strings.LastIndex(input, "@"); at >= 0 {
+			input = input[:at]
+		} else {
+			input = strings.TrimPrefix(input, "+")
 		}
+
+		if len(input) >= minUserInputLength && strings.Contains(lowerPassword, input) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkPasswordHistory rejects passwords that match one of the user's
+// recent passwords.
+func (a *API) checkPasswordHistory(ctx context.Context, db *storage.Connection, user *models.User, password string) error {
+	config := a.requestConfig(ctx)
+
+	if config.Password.HistoryCount <= 0 {
+		return nil
 	}
 
-	if len(reasons) > 0 {
-		return &WeakPasswordError{
-			Message: strings.Join(messages, " "),
-			Reasons: reasons,
+	reused, err := user.IsReusedPassword(ctx, db, password, config.Security.DBEncryption.DecryptionKeys, config.Password.HistoryCount)
+	if err != nil {
+		return apierrors.NewInternalServerError("Database error checking password history").WithInternalError(err)
+	}
+
+	if reused {
+		weakPasswordError := &WeakPasswordError{}
+		weakPasswordError.addReason("reused", "weak_password_reused", config.Password.HistoryCount)
+
+		return weakPasswordError
+	}
+
+	return nil
+}
+
+// recordPasswordHistory records the user's new password in the password
+// history, if password history or expiry is enabled.
+func (a *API) recordPasswordHistory(tx *storage.Connection, user *models.User) error {
+	config := a.config
+
+	if !config.Password.HistoryEnabled() {
+		return nil
+	}
+
+	if err := models.AddPasswordHistory(tx, user, config.Password.HistoryCount); err != nil {
+		return apierrors.NewInternalServerError("Database error updating password history").WithInternalError(err)
+	}
+
+	return nil
+}
+
+// checkPasswordExpiry rejects sign ins with a password older than the
+// maximum password age.
+func (a *API) checkPasswordExpiry(db *storage.Connection, user *models.User) error {
+	config := a.config
+
+	if config.Password.MaxAge <= 0 {
+		return nil
+	}
+
+	if user.PasswordChangedAt == nil {
+		if err := user.StartPasswordAge(db, a.Now()); err != nil {
+			return apierrors.NewInternalServerError("Database error checking password age").WithInternalError(err)
 		}
 	}
 
+	if a.Now().After(user.PasswordChangedAt.Add(config.Password.MaxAge)) {
+		weakPasswordError := &WeakPasswordError{}
+		weakPasswordError.addReason("expired", "weak_password_expired")
+
+		return weakPasswordError
+	}
+
 	return nil
 }
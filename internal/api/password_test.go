@@ -7,6 +7,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/supabase/auth/internal/api/apierrors"
 	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/i18n"
 )
 
 func TestPasswordStrengthChecks(t *testing.T) {
@@ -116,3 +117,82 @@ func TestPasswordStrengthChecks(t *testing.T) {
 		}
 	}
 }
+
+func TestPasswordPolicyChecks(t *testing.T) {
+	api := &API{
+		config: &conf.GlobalConfiguration{
+			Password: conf.PasswordConfiguration{
+				MinLength:   6,
+				MaxLength:   16,
+				BannedWords: []string{"acme", " Summer "},
+			},
+		},
+	}
+
+	examples := []struct {
+		Password   string
+		UserInputs []string
+		Reasons    []string
+	}{
+		{
+			Password: "correct-horse-battery",
+			Reasons:  []string{"length"},
+		},
+		{
+			Password:   "Jane.Doe2024",
+			UserInputs: []string{"jane.doe@example.com", ""},
+			Reasons:    []string{"context"},
+		},
+		{
+			Password:   "x15551234567",
+			UserInputs: []string{"", "+15551234567"},
+			Reasons:    []string{"context"},
+		},
+		{
+			Password:   "ab@example",
+			UserInputs: []string{"ab@example.com"},
+			Reasons:    nil,
+		},
+		{
+			Password: "ACME-rocks",
+			Reasons:  []string{"dictionary"},
+		},
+		{
+			Password:   "summer-jane.doe",
+			UserInputs: []string{"jane.doe@example.com"},
+			Reasons:    []string{"context", "dictionary"},
+		},
+		{
+			Password: "unrelated-pw",
+			Reasons:  nil,
+		},
+	}
+
+	for i, example := range examples {
+		err := api.checkPasswordStrength(context.Background(), example.Password, example.UserInputs...)
+
+		if example.Reasons == nil {
+			require.NoError(t, err, "Example %d failed with error", i)
+			continue
+		}
+
+		e, ok := err.(*WeakPasswordError)
+		require.True(t, ok, "Example %d failed with unexpected error %v", i, err)
+		require.Equal(t, example.Reasons, e.Reasons, "Example %d failed with wrong reasons", i)
+	}
+}
+
+func TestWeakPasswordErrorLocalizedMessage(t *testing.T) {
+	e := &WeakPasswordError{}
+	e.addReason("length", "weak_password_length", 8)
+	e.addReason("reused", "weak_password_reused", 5)
+
+	require.Equal(t, []string{"length", "reused"}, e.Reasons)
+	require.Equal(t, "Password should be at least 8 characters. Password should be different from your last 5 passwords.", e.Message)
+	require.Equal(t, e.Message, e.LocalizedMessage(i18n.LanguageEnglish))
+	require.Equal(t, "密码长度至少为8个字符。 密码不能与最近5次使用的密码相同。", e.LocalizedMessage(i18n.LanguageChinese))
+
+	// errors without i18n keys keep their message
+	e = &WeakPasswordError{Message: "Password is too weak", Reasons: []string{"hook"}}
+	require.Equal(t, "Password is too weak", e.LocalizedMessage(i18n.LanguageChinese))
+}
@@ -44,7 +44,7 @@ func formatPhoneNumber(phone string) string {
 }
 
 func (a *API) sendPasswordRecoverySMS(r *http.Request, tx *storage.Connection, u *models.User, flowType models.FlowType) error {
-	config := a.config
+	config := a.requestConfig(r.Context())
 	otpLength := config.Sms.OtpLength
 
 	if err := validateSentWithinFrequencyLimit(u.RecoverySentAt, config.Sms.MaxFrequency); err != nil {
@@ -81,7 +81,7 @@ func (a *API) sendPasswordRecoverySMS(r *http.Request, tx *storage.Connection, u
 
 // sendPhoneConfirmation sends an otp to the user's phone number
 func (a *API) sendPhoneConfirmation(r *http.Request, tx *storage.Connection, user *models.User, phone, otpType string, channel string) (string, error) {
-	config := a.config
+	config := a.requestConfig(r.Context())
 
 	var token *string
 	var sentAt *time.Time
@@ -0,0 +1,333 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/supabase/auth/internal/conf"
+	"golang.org/x/oauth2"
+)
+
+// customProvider is a generic OAuth2 or OpenID Connect provider defined in
+// the configuration.
+type customProvider struct {
+	*oauth2.Config
+	oidc         *oidc.Provider
+	userinfoURL  string
+	claimMapping map[string]string
+}
+
+// NewCustomProvider creates a provider from a custom provider configuration.
+// OpenID Connect endpoints are discovered from the issuer, unless set
+// explicitly.
+func NewCustomProvider(ctx context.Context, ext conf.CustomOAuthProviderConfiguration, scopes string) (OAuthProvider, error) {
+	if err := ext.ValidateOAuth(); err != nil {
+		return nil, err
+	}
+
+	p := &customProvider{
+		Config: &oauth2.Config{
+			ClientID:     ext.ClientID[0],
+			ClientSecret: ext.Secret,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  ext.AuthorizationURL,
+				TokenURL: ext.TokenURL,
+			},
+			RedirectURL: ext.RedirectURI,
+		},
+		userinfoURL:  ext.UserinfoURL,
+		claimMapping: ext.ClaimMapping,
+	}
+
+	oauthScopes := ext.Scopes
+
+	if ext.Issuer != "" {
+		oidcProvider, err := oidc.NewProvider(ctx, ext.Issuer)
+		if err != nil {
+			return nil, err
+		}
+
+		p.oidc = oidcProvider
+
+		endpoint := oidcProvider.Endpoint()
+		if p.Endpoint.AuthURL == "" {
+			p.Endpoint.AuthURL = endpoint.AuthURL
+		}
+		if p.Endpoint.TokenURL == "" {
+			p.Endpoint.TokenURL = endpoint.TokenURL
+		}
+		if p.userinfoURL == "" {
+			p.userinfoURL = oidcProvider.UserInfoEndpoint()
+		}
+
+		if len(oauthScopes) == 0 {
+			oauthScopes = []string{oidc.ScopeOpenID, "profile", "email"}
+		}
+	}
+
+	if p.Endpoint.AuthURL == "" || p.Endpoint.TokenURL == "" {
+		return nil, errors.New("missing authorization or token URL")
+	}
+
+	if scopes != "" {
+		oauthScopes = append(oauthScopes, strings.Split(scopes, ",")...)
+	}
+
+	p.Scopes = oauthScopes
+
+	return p, nil
+}
+
+func (p customProvider) GetOAuthToken(code string) (*oauth2.Token, error) {
+	return p.Exchange(context.Background(), code)
+}
+
+func (p customProvider) GetUserData(ctx context.Context, tok *oauth2.Token) (*UserProvidedData, error) {
+	raw := make(map[string]interface{})
+
+	if idToken, ok := tok.Extra("id_token").(string); ok && idToken != "" && p.oidc != nil {
+		token, err := p.oidc.VerifierContext(ctx, &oidc.Config{ClientID: p.ClientID}).Verify(ctx, idToken)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := token.Claims(&raw); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.userinfoURL != "" {
+		var userinfo map[string]interface{}
+		if err := makeRequest(ctx, tok, p.Config, p.userinfoURL, &userinfo); err != nil {
+			return nil, err
+		}
+
+		// claims of a verified ID token take precedence
+		for key, value := range userinfo {
+			if _, ok := raw[key]; !ok {
+				raw[key] = value
+			}
+		}
+	}
+
+	if len(raw) == 0 {
+		return nil, errors.New("custom provider returned neither an ID token nor userinfo")
+	}
+
+	return mapCustomClaims(raw, p.claimMapping)
+}
+
+func parseCustomIDToken(token *oidc.IDToken, claimMapping map[string]string) (*oidc.IDToken, *UserProvidedData, error) {
+	raw := make(map[string]interface{})
+	if err := token.Claims(&raw); err != nil {
+		return nil, nil, err
+	}
+
+	data, err := mapCustomClaims(raw, claimMapping)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(data.Emails) <= 0 {
+		return nil, nil, fmt.Errorf("provider: Custom provider ID token from issuer %q must contain an email address", token.Issuer)
+	}
+
+	return token, data, nil
+}
+
+// claimFields indexes the fields of Claims by their JSON name.
+var claimFields = func() map[string]int {
+	fields := make(map[string]int)
+
+	t := reflect.TypeOf(Claims{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "custom_claims" {
+			fields[name] = i
+		}
+	}
+
+	return fields
+}()
+
+// mapCustomClaims converts the raw claims of a custom provider into user data.
+// Each claim in claimMapping is read from the JSONPath expression it maps to,
+// other standard claims are read as they are. Mapped claims that aren't
+// standard claims are kept as custom claims.
+func mapCustomClaims(raw map[string]interface{}, claimMapping map[string]string) (*UserProvidedData, error) {
+	mapped := make(map[string]interface{}, len(raw))
+	for key, value := range raw {
+		if _, ok := claimFields[key]; ok {
+			mapped[key] = value
+		}
+	}
+
+	claims := &Claims{}
+
+	for claim, path := range claimMapping {
+		value, err := lookupJSONPath(raw, path)
+		if err != nil {
+			return nil, fmt.Errorf("provider: claim mapping for %q: %w", claim, err)
+		}
+
+		if _, ok := claimFields[claim]; ok {
+			if value == nil {
+				delete(mapped, claim)
+			} else {
+				mapped[claim] = value
+			}
+		} else if value != nil {
+			if claims.CustomClaims == nil {
+				claims.CustomClaims = make(map[string]interface{})
+			}
+			claims.CustomClaims[claim] = value
+		}
+	}
+
+	v := reflect.ValueOf(claims).Elem()
+	for claim, value := range mapped {
+		field := v.Field(claimFields[claim])
+
+		switch field.Kind() {
+		case reflect.String:
+			if s, ok := claimString(value); ok {
+				field.SetString(s)
+			}
+		case reflect.Bool:
+			if b, ok := claimBool(value); ok {
+				field.SetBool(b)
+			}
+		case reflect.Float64:
+			if f, ok := value.(float64); ok {
+				field.SetFloat(f)
+			}
+		}
+	}
+
+	if claims.Subject == "" {
+		return nil, errors.New("provider: custom provider claims are missing the subject")
+	}
+
+	// To be deprecated
+	if claims.FullName == "" {
+		claims.FullName = claims.Name
+	}
+	if claims.AvatarURL == "" {
+		claims.AvatarURL = claims.Picture
+	}
+	if claims.ProviderId == "" {
+		claims.ProviderId = claims.Subject
+	}
+
+	data := &UserProvidedData{Metadata: claims}
+
+	if claims.Email != "" {
+		data.Emails = []Email{{
+			Email:    claims.Email,
+			Verified: claims.EmailVerified,
+			Primary:  true,
+		}}
+	}
+
+	return data, nil
+}
+
+func claimString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case float64:
+		// numeric IDs are common with OAuth2 providers
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+
+	return "", false
+}
+
+func claimBool(value interface{}) (bool, bool) {
+	switch v := value.(type) {
+	case bool:
+		return v, true
+	case string:
+		b, err := strconv.ParseBool(v)
+		return b, err == nil
+	}
+
+	return false, false
+}
+
+// lookupJSONPath evaluates a JSONPath expression against a decoded JSON
+// document. Only child and array index selectors are supported, such as
+// $.data.emails[0].value or $['user-info'].id. It returns nil when the path
+// doesn't exist in the document.
+func lookupJSONPath(document interface{}, path string) (interface{}, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("JSONPath %q must start with $", path)
+	}
+
+	current := document
+	rest := path[1:]
+
+	for rest != "" {
+		var key string
+		index := -1
+
+		switch {
+		case rest[0] == '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			key, rest = rest[:end], rest[end:]
+			if key == "" {
+				return nil, fmt.Errorf("JSONPath %q has an empty name", path)
+			}
+
+		case strings.HasPrefix(rest, "['") || strings.HasPrefix(rest, `["`):
+			quote := rest[1:2]
+			end := strings.Index(rest[2:], quote+"]")
+			if end < 0 {
+				return nil, fmt.Errorf("JSONPath %q has an unterminated name", path)
+			}
+			key, rest = rest[2:2+end], rest[2+end+2:]
+
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("JSONPath %q has an unterminated index", path)
+			}
+			i, err := strconv.Atoi(rest[1:end])
+			if err != nil || i < 0 {
+				return nil, fmt.Errorf("JSONPath %q has an invalid index", path)
+			}
+			index, rest = i, rest[end+1:]
+
+		default:
+			return nil, fmt.Errorf("JSONPath %q is not supported", path)
+		}
+
+		if index >= 0 {
+			array, ok := current.([]interface{})
+			if !ok || index >= len(array) {
+				return nil, nil
+			}
+			current = array[index]
+		} else {
+			object, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, nil
+			}
+			current = object[key]
+		}
+	}
+
+	return current, nil
+}
@@ -0,0 +1,128 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/auth/internal/conf"
+)
+
+func TestLookupJSONPath(t *testing.T) {
+	var document interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{"data":{"id":42,"emails":[{"value":"a@example.com"},{"value":"b@example.com"}]},"user-info":{"name":"Test"}}`), &document))
+
+	cases := []struct {
+		path     string
+		expected interface{}
+	}{
+		{"$", document},
+		{"$.data.id", float64(42)},
+		{"$.data.emails[1].value", "b@example.com"},
+		{"$['user-info'].name", "Test"},
+		{`$["user-info"]["name"]`, "Test"},
+		{"$.data.missing", nil},
+		{"$.data.emails[5].value", nil},
+		{"$.data.id.value", nil},
+	}
+
+	for _, c := range cases {
+		value, err := lookupJSONPath(document, c.path)
+		require.NoError(t, err, c.path)
+		require.Equal(t, c.expected, value, c.path)
+	}
+
+	for _, path := range []string{"data.id", "$.", "$['name", "$[x]", "$[-1]", "$..id"} {
+		_, err := lookupJSONPath(document, path)
+		require.Error(t, err, path)
+	}
+}
+
+func TestMapCustomClaims(t *testing.T) {
+	raw := map[string]interface{}{
+		"id":   float64(1234),
+		"name": "Custom Test",
+		"mail": "custom@example.com",
+		"flags": map[string]interface{}{
+			"verified": "true",
+		},
+		"department": "engineering",
+		"sub":        "ignored",
+	}
+
+	data, err := mapCustomClaims(raw, map[string]string{
+		"sub":            "$.id",
+		"email":          "$.mail",
+		"email_verified": "$.flags.verified",
+		"department":     "$.department",
+		"missing":        "$.missing",
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, "1234", data.Metadata.Subject)
+	require.Equal(t, "1234", data.Metadata.ProviderId)
+	require.Equal(t, "Custom Test", data.Metadata.Name)
+	require.Equal(t, "Custom Test", data.Metadata.FullName)
+	require.True(t, data.Metadata.EmailVerified)
+	require.Equal(t, map[string]interface{}{"department": "engineering"}, data.Metadata.CustomClaims)
+	require.Equal(t, []Email{{Email: "custom@example.com", Verified: true, Primary: true}}, data.Emails)
+
+	_, err = mapCustomClaims(raw, map[string]string{"sub": "$.missing"})
+	require.Error(t, err)
+}
+
+func TestCustomProviderOAuth2(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			require.NoError(t, r.ParseForm())
+			require.Equal(t, "code", r.PostForm.Get("code"))
+
+			w.Header().Add("Content-Type", "application/json")
+			fmt.Fprint(w, `{"access_token":"token","token_type":"bearer","expires_in":3600}`)
+		case "/userinfo":
+			require.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+
+			w.Header().Add("Content-Type", "application/json")
+			fmt.Fprint(w, `{"user":{"id":"user123","login":"test","primary_email":"test@example.com"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p, err := NewCustomProvider(context.Background(), conf.CustomOAuthProviderConfiguration{
+		OAuthProviderConfiguration: conf.OAuthProviderConfiguration{
+			Enabled:     true,
+			ClientID:    []string{"client"},
+			Secret:      "secret",
+			RedirectURI: "https://example.com/callback",
+		},
+		AuthorizationURL: server.URL + "/authorize",
+		TokenURL:         server.URL + "/token",
+		UserinfoURL:      server.URL + "/userinfo",
+		Scopes:           []string{"read:user"},
+		ClaimMapping: map[string]string{
+			"sub":                "$.user.id",
+			"preferred_username": "$.user.login",
+			"email":              "$.user.primary_email",
+		},
+	}, "write:user")
+	require.NoError(t, err)
+
+	require.Contains(t, p.AuthCodeURL("state"), server.URL+"/authorize?")
+	require.Contains(t, p.AuthCodeURL("state"), "scope=read%3Auser+write%3Auser")
+
+	tok, err := p.GetOAuthToken("code")
+	require.NoError(t, err)
+
+	data, err := p.GetUserData(context.Background(), tok)
+	require.NoError(t, err)
+	require.Equal(t, "user123", data.Metadata.Subject)
+	require.Equal(t, "test", data.Metadata.PreferredUsername)
+	require.Equal(t, []Email{{Email: "test@example.com", Verified: false, Primary: true}}, data.Emails)
+}
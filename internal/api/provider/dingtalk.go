@@ -0,0 +1,179 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/supabase/auth/internal/conf"
+	"golang.org/x/oauth2"
+)
+
+// See https://open.dingtalk.com/document/orgapp/tutorial-obtaining-user-personal-information
+const (
+	defaultDingTalkAuthBase = "login.dingtalk.com"
+	defaultDingTalkAPIBase  = "api.dingtalk.com"
+)
+
+type dingtalkProvider struct {
+	*oauth2.Config
+	APIHost string
+}
+
+type dingtalkTokenRequest struct {
+	ClientID     string `json:"clientId"`
+	ClientSecret string `json:"clientSecret"`
+	Code         string `json:"code"`
+	GrantType    string `json:"grantType"`
+}
+
+type dingtalkToken struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	ExpireIn     int    `json:"expireIn"`
+	CorpID       string `json:"corpId"`
+}
+
+type dingtalkUser struct {
+	Nick      string `json:"nick"`
+	AvatarURL string `json:"avatarUrl"`
+	Mobile    string `json:"mobile"`
+	StateCode string `json:"stateCode"`
+	OpenID    string `json:"openId"`
+	UnionID   string `json:"unionId"`
+	Email     string `json:"email"`
+}
+
+// NewDingTalkProvider creates a DingTalk account provider.
+func NewDingTalkProvider(ext conf.OAuthProviderConfiguration, scopes string) (OAuthProvider, error) {
+	if err := ext.ValidateOAuth(); err != nil {
+		return nil, err
+	}
+
+	authHost := chooseHost(ext.URL, defaultDingTalkAuthBase)
+	apiHost := chooseHost(ext.URL, defaultDingTalkAPIBase)
+
+	oauthScopes := []string{
+		"openid",
+	}
+
+	if scopes != "" {
+		oauthScopes = append(oauthScopes, strings.Split(scopes, ",")...)
+	}
+
+	return &dingtalkProvider{
+		Config: &oauth2.Config{
+			ClientID:     ext.ClientID[0],
+			ClientSecret: ext.Secret,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  authHost + "/oauth2/auth",
+				TokenURL: apiHost + "/v1.0/oauth2/userAccessToken",
+			},
+			RedirectURL: ext.RedirectURI,
+			Scopes:      oauthScopes,
+		},
+		APIHost: apiHost,
+	}, nil
+}
+
+func (p dingtalkProvider) AuthCodeURL(state string, opts ...oauth2.AuthCodeOption) string {
+	opts = append([]oauth2.AuthCodeOption{oauth2.SetAuthURLParam("prompt", "consent")}, opts...)
+	return p.Config.AuthCodeURL(state, opts...)
+}
+
+// GetOAuthToken exchanges the code itself, as DingTalk expects a JSON request
+// with camel case parameters.
+func (p dingtalkProvider) GetOAuthToken(code string) (*oauth2.Token, error) {
+	body, err := json.Marshal(dingtalkTokenRequest{
+		ClientID:     p.ClientID,
+		ClientSecret: p.ClientSecret,
+		Code:         code,
+		GrantType:    "authorization_code",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, p.Endpoint.TokenURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var t dingtalkToken
+	if err := makeJSONRequest(req, &t); err != nil {
+		return nil, err
+	}
+
+	token := &oauth2.Token{
+		AccessToken:  t.AccessToken,
+		RefreshToken: t.RefreshToken,
+		TokenType:    "Bearer",
+	}
+
+	if t.ExpireIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(t.ExpireIn) * time.Second)
+	}
+
+	return token, nil
+}
+
+func (p dingtalkProvider) GetUserData(ctx context.Context, tok *oauth2.Token) (*UserProvidedData, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.APIHost+"/v1.0/contact/users/me", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-acs-dingtalk-access-token", tok.AccessToken)
+
+	var u dingtalkUser
+	if err := makeJSONRequest(req, &u); err != nil {
+		return nil, err
+	}
+
+	// the unionId identifies the user across all apps of the same
+	// developer, the openId only within this app
+	subject := u.UnionID
+	if subject == "" {
+		subject = u.OpenID
+	}
+
+	data := &UserProvidedData{}
+
+	if u.Email != "" {
+		data.Emails = []Email{{
+			Email:    u.Email,
+			Verified: false,
+			Primary:  true,
+		}}
+	}
+
+	phone := u.Mobile
+	if phone != "" && u.StateCode != "" {
+		phone = "+" + u.StateCode + phone
+	}
+
+	data.Metadata = &Claims{
+		Issuer:        p.APIHost,
+		Subject:       subject,
+		Name:          u.Nick,
+		NickName:      u.Nick,
+		Picture:       u.AvatarURL,
+		Phone:         phone,
+		PhoneVerified: phone != "",
+
+		CustomClaims: map[string]interface{}{
+			"openid":  u.OpenID,
+			"unionid": u.UnionID,
+		},
+
+		// To be deprecated
+		AvatarURL:  u.AvatarURL,
+		FullName:   u.Nick,
+		ProviderId: subject,
+	}
+
+	return data, nil
+}
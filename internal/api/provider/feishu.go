@@ -0,0 +1,206 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/supabase/auth/internal/conf"
+	"golang.org/x/oauth2"
+)
+
+// Feishu and its international version Lark share the same API on different
+// hosts.
+// See https://open.feishu.cn/document/common-capabilities/sso/api/obtain-oauth-code
+const (
+	defaultFeishuAuthBase = "accounts.feishu.cn"
+	defaultFeishuAPIBase  = "open.feishu.cn"
+	defaultLarkAuthBase   = "accounts.larksuite.com"
+	defaultLarkAPIBase    = "open.larksuite.com"
+)
+
+type feishuProvider struct {
+	*oauth2.Config
+	APIHost string
+}
+
+type feishuTokenRequest struct {
+	GrantType    string `json:"grant_type"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	Code         string `json:"code"`
+	RedirectURI  string `json:"redirect_uri"`
+}
+
+type feishuToken struct {
+	Code             int    `json:"code"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+	AccessToken      string `json:"access_token"`
+	ExpiresIn        int    `json:"expires_in"`
+	RefreshToken     string `json:"refresh_token"`
+}
+
+type feishuUser struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+	Data struct {
+		Name            string `json:"name"`
+		EnName          string `json:"en_name"`
+		AvatarURL       string `json:"avatar_url"`
+		OpenID          string `json:"open_id"`
+		UnionID         string `json:"union_id"`
+		UserID          string `json:"user_id"`
+		TenantKey       string `json:"tenant_key"`
+		Email           string `json:"email"`
+		EnterpriseEmail string `json:"enterprise_email"`
+		Mobile          string `json:"mobile"`
+	} `json:"data"`
+}
+
+// NewFeishuProvider creates a Feishu account provider.
+func NewFeishuProvider(ext conf.OAuthProviderConfiguration, scopes string) (OAuthProvider, error) {
+	return newFeishuProvider(ext, scopes, defaultFeishuAuthBase, defaultFeishuAPIBase)
+}
+
+// NewLarkProvider creates a Lark account provider.
+func NewLarkProvider(ext conf.OAuthProviderConfiguration, scopes string) (OAuthProvider, error) {
+	return newFeishuProvider(ext, scopes, defaultLarkAuthBase, defaultLarkAPIBase)
+}
+
+func newFeishuProvider(ext conf.OAuthProviderConfiguration, scopes, defaultAuthBase, defaultAPIBase string) (OAuthProvider, error) {
+	if err := ext.ValidateOAuth(); err != nil {
+		return nil, err
+	}
+
+	authHost := chooseHost(ext.URL, defaultAuthBase)
+	apiHost := chooseHost(ext.URL, defaultAPIBase)
+
+	var oauthScopes []string
+
+	if scopes != "" {
+		oauthScopes = strings.Split(scopes, ",")
+	}
+
+	return &feishuProvider{
+		Config: &oauth2.Config{
+			ClientID:     ext.ClientID[0],
+			ClientSecret: ext.Secret,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  authHost + "/open-apis/authen/v1/authorize",
+				TokenURL: apiHost + "/open-apis/authen/v2/oauth/token",
+			},
+			RedirectURL: ext.RedirectURI,
+			Scopes:      oauthScopes,
+		},
+		APIHost: apiHost,
+	}, nil
+}
+
+// GetOAuthToken exchanges the code itself, as Feishu expects a JSON request.
+func (p feishuProvider) GetOAuthToken(code string) (*oauth2.Token, error) {
+	body, err := json.Marshal(feishuTokenRequest{
+		GrantType:    "authorization_code",
+		ClientID:     p.ClientID,
+		ClientSecret: p.ClientSecret,
+		Code:         code,
+		RedirectURI:  p.RedirectURL,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, p.Endpoint.TokenURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	var t feishuToken
+	if err := makeJSONRequest(req, &t); err != nil {
+		return nil, err
+	}
+
+	if t.Code != 0 {
+		return nil, fmt.Errorf("feishu: error %d: %s: %s", t.Code, t.Error, t.ErrorDescription)
+	}
+
+	token := &oauth2.Token{
+		AccessToken:  t.AccessToken,
+		RefreshToken: t.RefreshToken,
+		TokenType:    "Bearer",
+	}
+
+	if t.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(t.ExpiresIn) * time.Second)
+	}
+
+	return token, nil
+}
+
+func (p feishuProvider) GetUserData(ctx context.Context, tok *oauth2.Token) (*UserProvidedData, error) {
+	var u feishuUser
+
+	if err := makeRequest(ctx, tok, p.Config, p.APIHost+"/open-apis/authen/v1/user_info", &u); err != nil {
+		return nil, err
+	}
+
+	if u.Code != 0 {
+		return nil, fmt.Errorf("feishu: error %d: %s", u.Code, u.Msg)
+	}
+
+	// the union_id identifies the user across all apps of the same
+	// developer, the open_id only within this app
+	subject := u.Data.UnionID
+	if subject == "" {
+		subject = u.Data.OpenID
+	}
+
+	data := &UserProvidedData{}
+
+	// the enterprise email is assigned by the user's organization, the
+	// other email is entered by the user and not verified
+	if u.Data.EnterpriseEmail != "" {
+		data.Emails = append(data.Emails, Email{
+			Email:    u.Data.EnterpriseEmail,
+			Verified: true,
+			Primary:  true,
+		})
+	}
+
+	if u.Data.Email != "" && !strings.EqualFold(u.Data.Email, u.Data.EnterpriseEmail) {
+		data.Emails = append(data.Emails, Email{
+			Email:    u.Data.Email,
+			Verified: false,
+			Primary:  u.Data.EnterpriseEmail == "",
+		})
+	}
+
+	data.Metadata = &Claims{
+		Issuer:        p.APIHost,
+		Subject:       subject,
+		Name:          u.Data.Name,
+		NickName:      u.Data.EnName,
+		Picture:       u.Data.AvatarURL,
+		Phone:         u.Data.Mobile,
+		PhoneVerified: u.Data.Mobile != "",
+
+		CustomClaims: map[string]interface{}{
+			"open_id":    u.Data.OpenID,
+			"union_id":   u.Data.UnionID,
+			"user_id":    u.Data.UserID,
+			"tenant_key": u.Data.TenantKey,
+		},
+
+		// To be deprecated
+		AvatarURL:  u.Data.AvatarURL,
+		FullName:   u.Data.Name,
+		ProviderId: subject,
+	}
+
+	return data, nil
+}
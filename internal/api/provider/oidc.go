@@ -14,6 +14,11 @@ import (
 type ParseIDTokenOptions struct {
 	SkipAccessTokenCheck bool
 	AccessToken          string
+
+	// Custom parses the ID token of a custom provider, with its claim
+	// mapping.
+	Custom       bool
+	ClaimMapping map[string]string
 }
 
 // OverrideVerifiers can be used to set a custom verifier for an OIDC provider
@@ -52,14 +57,16 @@ func ParseIDToken(ctx context.Context, provider *oidc.Provider, config *oidc.Con
 
 	var data *UserProvidedData
 
-	switch token.Issuer {
-	case IssuerGoogle:
+	switch {
+	case options.Custom:
+		token, data, err = parseCustomIDToken(token, options.ClaimMapping)
+	case token.Issuer == IssuerGoogle:
 		token, data, err = parseGoogleIDToken(token)
-	case IssuerLinkedin:
+	case token.Issuer == IssuerLinkedin:
 		token, data, err = parseLinkedinIDToken(token)
-	case IssuerKakao:
+	case token.Issuer == IssuerKakao:
 		token, data, err = parseKakaoIDToken(token)
-	case IssuerVercelMarketplace:
+	case token.Issuer == IssuerVercelMarketplace:
 		token, data, err = parseVercelMarketplaceIDToken(token)
 	default:
 		if IsAzureIssuer(token.Issuer) {
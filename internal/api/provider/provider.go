@@ -126,3 +126,41 @@ func makeRequest(ctx context.Context, tok *oauth2.Token, g *oauth2.Config, url s
 
 	return nil
 }
+
+// makeJSONRequest sends req and decodes the JSON response into dst. Unlike
+// makeRequest it leaves authentication to the caller, for providers that
+// don't accept the access token in the Authorization header.
+func makeJSONRequest(req *http.Request, dst interface{}) error {
+	client := &http.Client{Timeout: defaultTimeout}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer utilities.SafeClose(res.Body)
+
+	bodyBytes, _ := io.ReadAll(res.Body)
+
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		return httpError(res.StatusCode, string(bodyBytes))
+	}
+
+	return json.Unmarshal(bodyBytes, dst)
+}
+
+// emailOptionalProviders don't always share the user's email address, so users
+// signing in with them may not have one.
+var emailOptionalProviders = map[string]bool{
+	"wechat":    true,
+	"wechat_mp": true,
+	"qq":        true,
+	"weibo":     true,
+	"dingtalk":  true,
+	"feishu":    true,
+	"lark":      true,
+}
+
+// IsEmailOptional reports whether users can sign in with the named provider
+// without an email address.
+func IsEmailOptional(name string) bool {
+	return emailOptionalProviders[name]
+}
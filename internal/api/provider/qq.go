@@ -0,0 +1,213 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/supabase/auth/internal/conf"
+	"golang.org/x/oauth2"
+)
+
+// See https://wiki.connect.qq.com/
+const defaultQQAPIBase = "graph.qq.com"
+
+type qqProvider struct {
+	*oauth2.Config
+	APIHost string
+}
+
+type qqError struct {
+	Error            int    `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func (e *qqError) check() error {
+	if e.Error != 0 {
+		return fmt.Errorf("qq: error %d: %s", e.Error, e.ErrorDescription)
+	}
+	return nil
+}
+
+type qqToken struct {
+	qqError
+	AccessToken  string      `json:"access_token"`
+	ExpiresIn    json.Number `json:"expires_in"`
+	RefreshToken string      `json:"refresh_token"`
+}
+
+type qqOpenID struct {
+	qqError
+	ClientID string `json:"client_id"`
+	OpenID   string `json:"openid"`
+	UnionID  string `json:"unionid"`
+}
+
+type qqUser struct {
+	Ret          int    `json:"ret"`
+	Msg          string `json:"msg"`
+	Nickname     string `json:"nickname"`
+	FigureURL    string `json:"figureurl_qq_1"`
+	FigureURLBig string `json:"figureurl_qq_2"`
+	Gender       string `json:"gender"`
+}
+
+// NewQQProvider creates a QQ account provider.
+func NewQQProvider(ext conf.OAuthProviderConfiguration, scopes string) (OAuthProvider, error) {
+	if err := ext.ValidateOAuth(); err != nil {
+		return nil, err
+	}
+
+	apiHost := chooseHost(ext.URL, defaultQQAPIBase)
+
+	oauthScopes := []string{
+		"get_user_info",
+	}
+
+	if scopes != "" {
+		oauthScopes = append(oauthScopes, strings.Split(scopes, ",")...)
+	}
+
+	return &qqProvider{
+		Config: &oauth2.Config{
+			ClientID:     ext.ClientID[0],
+			ClientSecret: ext.Secret,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  apiHost + "/oauth2.0/authorize",
+				TokenURL: apiHost + "/oauth2.0/token",
+			},
+			RedirectURL: ext.RedirectURI,
+			Scopes:      oauthScopes,
+		},
+		APIHost: apiHost,
+	}, nil
+}
+
+// AuthCodeURL returns the QQ authorization URL, which takes comma separated
+// scopes.
+func (p qqProvider) AuthCodeURL(state string, opts ...oauth2.AuthCodeOption) string {
+	u, err := url.Parse(p.Config.AuthCodeURL(state, opts...))
+	if err != nil {
+		return ""
+	}
+
+	q := u.Query()
+	q.Set("scope", strings.Join(p.Scopes, ","))
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
+
+func (p qqProvider) GetOAuthToken(code string) (*oauth2.Token, error) {
+	q := url.Values{}
+	q.Set("grant_type", "authorization_code")
+	q.Set("client_id", p.ClientID)
+	q.Set("client_secret", p.ClientSecret)
+	q.Set("code", code)
+	q.Set("redirect_uri", p.RedirectURL)
+	q.Set("fmt", "json")
+
+	req, err := http.NewRequest(http.MethodGet, p.Endpoint.TokenURL+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var t qqToken
+	if err := makeJSONRequest(req, &t); err != nil {
+		return nil, err
+	}
+
+	if err := t.check(); err != nil {
+		return nil, err
+	}
+
+	token := &oauth2.Token{
+		AccessToken:  t.AccessToken,
+		RefreshToken: t.RefreshToken,
+		TokenType:    "Bearer",
+	}
+
+	if expiresIn, err := t.ExpiresIn.Int64(); err == nil && expiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(expiresIn) * time.Second)
+	}
+
+	return token, nil
+}
+
+func (p qqProvider) GetUserData(ctx context.Context, tok *oauth2.Token) (*UserProvidedData, error) {
+	q := url.Values{}
+	q.Set("access_token", tok.AccessToken)
+	q.Set("unionid", "1")
+	q.Set("fmt", "json")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.APIHost+"/oauth2.0/me?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var id qqOpenID
+	if err := makeJSONRequest(req, &id); err != nil {
+		return nil, err
+	}
+
+	if err := id.check(); err != nil {
+		return nil, err
+	}
+
+	q = url.Values{}
+	q.Set("access_token", tok.AccessToken)
+	q.Set("oauth_consumer_key", p.ClientID)
+	q.Set("openid", id.OpenID)
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, p.APIHost+"/user/get_user_info?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var u qqUser
+	if err := makeJSONRequest(req, &u); err != nil {
+		return nil, err
+	}
+
+	if u.Ret != 0 {
+		return nil, fmt.Errorf("qq: error %d: %s", u.Ret, u.Msg)
+	}
+
+	// the unionid is only available when the app has been granted access
+	// to it, otherwise the openid is specific to this app
+	subject := id.UnionID
+	if subject == "" {
+		subject = id.OpenID
+	}
+
+	avatarURL := u.FigureURLBig
+	if avatarURL == "" {
+		avatarURL = u.FigureURL
+	}
+
+	data := &UserProvidedData{}
+
+	data.Metadata = &Claims{
+		Issuer:   p.APIHost,
+		Subject:  subject,
+		Name:     u.Nickname,
+		NickName: u.Nickname,
+		Picture:  avatarURL,
+
+		CustomClaims: map[string]interface{}{
+			"openid":  id.OpenID,
+			"unionid": id.UnionID,
+		},
+
+		// To be deprecated
+		AvatarURL:  avatarURL,
+		FullName:   u.Nickname,
+		ProviderId: subject,
+	}
+
+	return data, nil
+}
@@ -0,0 +1,249 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/supabase/auth/internal/conf"
+	"golang.org/x/oauth2"
+)
+
+// WeChat's OAuth flavor identifies the app with appid and secret instead of
+// client_id and client_secret, reports errors with a 200 status and an
+// errcode, and doesn't share the user's email address.
+// See https://developers.weixin.qq.com/doc/oplatform/en/Website_App/WeChat_Login/Wechat_Login.html
+// and https://developers.weixin.qq.com/doc/offiaccount/en/OA_Web_Apps/Wechat_webpage_authorization.html
+
+const (
+	defaultWeChatAuthBase = "open.weixin.qq.com"
+	defaultWeChatAPIBase  = "api.weixin.qq.com"
+)
+
+// unionIDProviders lists groups of providers whose subjects are unionids in
+// the same namespace. A WeChat unionid identifies a user across all apps bound
+// to the same WeChat Open Platform account, so identities of these providers
+// with the same subject belong to the same user.
+var unionIDProviders = [][]string{
+	{"wechat", "wechat_mp"},
+}
+
+// UnionIDProviders returns the other providers whose subjects identify the
+// same user as subjects of the named provider.
+func UnionIDProviders(name string) []string {
+	for _, group := range unionIDProviders {
+		for _, member := range group {
+			if member != name {
+				continue
+			}
+
+			var others []string
+			for _, other := range group {
+				if other != name {
+					others = append(others, other)
+				}
+			}
+
+			return others
+		}
+	}
+
+	return nil
+}
+
+type wechatProvider struct {
+	*oauth2.Config
+	APIHost string
+}
+
+type wechatError struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+func (e *wechatError) check() error {
+	if e.ErrCode != 0 {
+		return fmt.Errorf("wechat: error %d: %s", e.ErrCode, e.ErrMsg)
+	}
+	return nil
+}
+
+type wechatToken struct {
+	wechatError
+	AccessToken  string `json:"access_token"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	OpenID       string `json:"openid"`
+	UnionID      string `json:"unionid"`
+}
+
+type wechatUser struct {
+	wechatError
+	OpenID     string `json:"openid"`
+	UnionID    string `json:"unionid"`
+	Nickname   string `json:"nickname"`
+	HeadImgURL string `json:"headimgurl"`
+	Country    string `json:"country"`
+	Province   string `json:"province"`
+	City       string `json:"city"`
+}
+
+// NewWeChatProvider creates a WeChat Open Platform account provider, used to
+// sign in on websites by scanning a QR code with the WeChat app.
+func NewWeChatProvider(ext conf.OAuthProviderConfiguration, scopes string) (OAuthProvider, error) {
+	return newWeChatProvider(ext, scopes, "/connect/qrconnect", "snsapi_login")
+}
+
+// NewWeChatMPProvider creates a WeChat Official Account provider, used to sign
+// in on web pages opened in the WeChat in-app browser.
+func NewWeChatMPProvider(ext conf.OAuthProviderConfiguration, scopes string) (OAuthProvider, error) {
+	return newWeChatProvider(ext, scopes, "/connect/oauth2/authorize", "snsapi_userinfo")
+}
+
+func newWeChatProvider(ext conf.OAuthProviderConfiguration, scopes, authPath, defaultScope string) (OAuthProvider, error) {
+	if err := ext.ValidateOAuth(); err != nil {
+		return nil, err
+	}
+
+	authHost := chooseHost(ext.URL, defaultWeChatAuthBase)
+	apiHost := chooseHost(ext.URL, defaultWeChatAPIBase)
+
+	oauthScopes := []string{defaultScope}
+
+	if scopes != "" {
+		oauthScopes = append(oauthScopes, strings.Split(scopes, ",")...)
+	}
+
+	return &wechatProvider{
+		Config: &oauth2.Config{
+			ClientID:     ext.ClientID[0],
+			ClientSecret: ext.Secret,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  authHost + authPath,
+				TokenURL: apiHost + "/sns/oauth2/access_token",
+			},
+			RedirectURL: ext.RedirectURI,
+			Scopes:      oauthScopes,
+		},
+		APIHost: apiHost,
+	}, nil
+}
+
+// AuthCodeURL returns the WeChat authorization URL, which takes the app ID as
+// appid, comma separated scopes and must end with the #wechat_redirect
+// fragment.
+func (p wechatProvider) AuthCodeURL(state string, opts ...oauth2.AuthCodeOption) string {
+	u, err := url.Parse(p.Config.AuthCodeURL(state, opts...))
+	if err != nil {
+		return ""
+	}
+
+	q := u.Query()
+	q.Set("appid", q.Get("client_id"))
+	q.Del("client_id")
+	q.Set("scope", strings.Join(p.Scopes, ","))
+
+	u.RawQuery = q.Encode()
+	u.Fragment = "wechat_redirect"
+
+	return u.String()
+}
+
+func (p wechatProvider) GetOAuthToken(code string) (*oauth2.Token, error) {
+	q := url.Values{}
+	q.Set("appid", p.ClientID)
+	q.Set("secret", p.ClientSecret)
+	q.Set("code", code)
+	q.Set("grant_type", "authorization_code")
+
+	req, err := http.NewRequest(http.MethodGet, p.Endpoint.TokenURL+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var t wechatToken
+	if err := makeJSONRequest(req, &t); err != nil {
+		return nil, err
+	}
+
+	if err := t.check(); err != nil {
+		return nil, err
+	}
+
+	token := &oauth2.Token{
+		AccessToken:  t.AccessToken,
+		RefreshToken: t.RefreshToken,
+		TokenType:    "Bearer",
+	}
+
+	if t.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(t.ExpiresIn) * time.Second)
+	}
+
+	return token.WithExtra(map[string]interface{}{
+		"openid":  t.OpenID,
+		"unionid": t.UnionID,
+	}), nil
+}
+
+func (p wechatProvider) GetUserData(ctx context.Context, tok *oauth2.Token) (*UserProvidedData, error) {
+	openID, _ := tok.Extra("openid").(string)
+
+	q := url.Values{}
+	q.Set("access_token", tok.AccessToken)
+	q.Set("openid", openID)
+	q.Set("lang", "zh_CN")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.APIHost+"/sns/userinfo?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var u wechatUser
+	if err := makeJSONRequest(req, &u); err != nil {
+		return nil, err
+	}
+
+	if err := u.check(); err != nil {
+		return nil, err
+	}
+
+	if u.UnionID == "" {
+		u.UnionID, _ = tok.Extra("unionid").(string)
+	}
+
+	// the unionid is only available when the app is bound to a WeChat Open
+	// Platform account, otherwise the openid is specific to this app
+	subject := u.UnionID
+	if subject == "" {
+		subject = u.OpenID
+	}
+
+	data := &UserProvidedData{}
+
+	data.Metadata = &Claims{
+		Issuer:   p.APIHost,
+		Subject:  subject,
+		Name:     u.Nickname,
+		NickName: u.Nickname,
+		Picture:  u.HeadImgURL,
+
+		CustomClaims: map[string]interface{}{
+			"openid":   u.OpenID,
+			"unionid":  u.UnionID,
+			"country":  u.Country,
+			"province": u.Province,
+			"city":     u.City,
+		},
+
+		// To be deprecated
+		AvatarURL:  u.HeadImgURL,
+		FullName:   u.Nickname,
+		ProviderId: subject,
+	}
+
+	return data, nil
+}
@@ -0,0 +1,111 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/auth/internal/conf"
+)
+
+func TestWeChatAuthCodeURL(t *testing.T) {
+	ext := conf.OAuthProviderConfiguration{
+		Enabled:     true,
+		ClientID:    []string{"wx123"},
+		Secret:      "secret",
+		RedirectURI: "https://example.com/callback",
+	}
+
+	p, err := NewWeChatProvider(ext, "")
+	require.NoError(t, err)
+
+	u, err := url.Parse(p.AuthCodeURL("state"))
+	require.NoError(t, err)
+	require.Equal(t, "open.weixin.qq.com", u.Host)
+	require.Equal(t, "/connect/qrconnect", u.Path)
+	require.Equal(t, "wechat_redirect", u.Fragment)
+
+	q := u.Query()
+	require.Equal(t, "wx123", q.Get("appid"))
+	require.Empty(t, q.Get("client_id"))
+	require.Equal(t, "snsapi_login", q.Get("scope"))
+	require.Equal(t, "state", q.Get("state"))
+	require.Equal(t, "code", q.Get("response_type"))
+
+	p, err = NewWeChatMPProvider(ext, "")
+	require.NoError(t, err)
+
+	u, err = url.Parse(p.AuthCodeURL("state"))
+	require.NoError(t, err)
+	require.Equal(t, "/connect/oauth2/authorize", u.Path)
+	require.Equal(t, "snsapi_userinfo", u.Query().Get("scope"))
+}
+
+func TestWeChatUserData(t *testing.T) {
+	for _, unionID := range []string{"union123", ""} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			q := r.URL.Query()
+
+			switch r.URL.Path {
+			case "/sns/oauth2/access_token":
+				require.Equal(t, "wx123", q.Get("appid"))
+				require.Equal(t, "secret", q.Get("secret"))
+
+				if q.Get("code") != "code" {
+					fmt.Fprint(w, `{"errcode":40029,"errmsg":"invalid code"}`)
+					return
+				}
+
+				fmt.Fprintf(w, `{"access_token":"token","expires_in":7200,"refresh_token":"refresh","openid":"open123","scope":"snsapi_login","unionid":%q}`, unionID)
+			case "/sns/userinfo":
+				require.Equal(t, "token", q.Get("access_token"))
+				require.Equal(t, "open123", q.Get("openid"))
+
+				fmt.Fprintf(w, `{"openid":"open123","nickname":"WeChat Test","headimgurl":"http://example.com/avatar","unionid":%q}`, unionID)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer server.Close()
+
+		p, err := NewWeChatProvider(conf.OAuthProviderConfiguration{
+			Enabled:     true,
+			ClientID:    []string{"wx123"},
+			Secret:      "secret",
+			RedirectURI: "https://example.com/callback",
+			URL:         server.URL,
+		}, "")
+		require.NoError(t, err)
+
+		_, err = p.GetOAuthToken("bad")
+		require.Error(t, err)
+
+		tok, err := p.GetOAuthToken("code")
+		require.NoError(t, err)
+		require.Equal(t, "token", tok.AccessToken)
+		require.Equal(t, "refresh", tok.RefreshToken)
+
+		data, err := p.GetUserData(context.Background(), tok)
+		require.NoError(t, err)
+		require.Empty(t, data.Emails)
+		require.Equal(t, "WeChat Test", data.Metadata.Name)
+		require.Equal(t, "http://example.com/avatar", data.Metadata.Picture)
+		require.Equal(t, "open123", data.Metadata.CustomClaims["openid"])
+
+		if unionID != "" {
+			require.Equal(t, unionID, data.Metadata.Subject)
+		} else {
+			require.Equal(t, "open123", data.Metadata.Subject)
+		}
+	}
+}
+
+func TestUnionIDProviders(t *testing.T) {
+	require.Equal(t, []string{"wechat_mp"}, UnionIDProviders("wechat"))
+	require.Equal(t, []string{"wechat"}, UnionIDProviders("wechat_mp"))
+	require.Empty(t, UnionIDProviders("qq"))
+}
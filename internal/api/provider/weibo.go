@@ -0,0 +1,180 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/supabase/auth/internal/conf"
+	"golang.org/x/oauth2"
+)
+
+// See https://open.weibo.com/wiki/Oauth2
+const defaultWeiboAPIBase = "api.weibo.com"
+
+type weiboProvider struct {
+	*oauth2.Config
+	APIHost string
+}
+
+type weiboError struct {
+	Error     string `json:"error"`
+	ErrorCode int    `json:"error_code"`
+}
+
+func (e *weiboError) check() error {
+	if e.ErrorCode != 0 || e.Error != "" {
+		return fmt.Errorf("weibo: error %d: %s", e.ErrorCode, e.Error)
+	}
+	return nil
+}
+
+type weiboToken struct {
+	weiboError
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+	UID         string `json:"uid"`
+}
+
+type weiboUser struct {
+	weiboError
+	ID              string `json:"idstr"`
+	ScreenName      string `json:"screen_name"`
+	Name            string `json:"name"`
+	ProfileImageURL string `json:"profile_image_url"`
+	AvatarLarge     string `json:"avatar_large"`
+}
+
+// NewWeiboProvider creates a Weibo account provider.
+func NewWeiboProvider(ext conf.OAuthProviderConfiguration, scopes string) (OAuthProvider, error) {
+	if err := ext.ValidateOAuth(); err != nil {
+		return nil, err
+	}
+
+	apiHost := chooseHost(ext.URL, defaultWeiboAPIBase)
+
+	var oauthScopes []string
+
+	if scopes != "" {
+		oauthScopes = strings.Split(scopes, ",")
+	}
+
+	return &weiboProvider{
+		Config: &oauth2.Config{
+			ClientID:     ext.ClientID[0],
+			ClientSecret: ext.Secret,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  apiHost + "/oauth2/authorize",
+				TokenURL: apiHost + "/oauth2/access_token",
+			},
+			RedirectURL: ext.RedirectURI,
+			Scopes:      oauthScopes,
+		},
+		APIHost: apiHost,
+	}, nil
+}
+
+// AuthCodeURL returns the Weibo authorization URL, which takes comma
+// separated scopes.
+func (p weiboProvider) AuthCodeURL(state string, opts ...oauth2.AuthCodeOption) string {
+	u, err := url.Parse(p.Config.AuthCodeURL(state, opts...))
+	if err != nil {
+		return ""
+	}
+
+	if len(p.Scopes) > 0 {
+		q := u.Query()
+		q.Set("scope", strings.Join(p.Scopes, ","))
+		u.RawQuery = q.Encode()
+	}
+
+	return u.String()
+}
+
+// GetOAuthToken exchanges the code itself, as Weibo doesn't always respond
+// with a JSON content type.
+func (p weiboProvider) GetOAuthToken(code string) (*oauth2.Token, error) {
+	form := url.Values{}
+	form.Set("client_id", p.ClientID)
+	form.Set("client_secret", p.ClientSecret)
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+
+	req, err := http.NewRequest(http.MethodPost, p.Endpoint.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var t weiboToken
+	if err := makeJSONRequest(req, &t); err != nil {
+		return nil, err
+	}
+
+	if err := t.check(); err != nil {
+		return nil, err
+	}
+
+	token := &oauth2.Token{
+		AccessToken: t.AccessToken,
+		TokenType:   "Bearer",
+	}
+
+	if t.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(t.ExpiresIn) * time.Second)
+	}
+
+	return token.WithExtra(map[string]interface{}{
+		"uid": t.UID,
+	}), nil
+}
+
+func (p weiboProvider) GetUserData(ctx context.Context, tok *oauth2.Token) (*UserProvidedData, error) {
+	uid, _ := tok.Extra("uid").(string)
+
+	q := url.Values{}
+	q.Set("access_token", tok.AccessToken)
+	q.Set("uid", uid)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.APIHost+"/2/users/show.json?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var u weiboUser
+	if err := makeJSONRequest(req, &u); err != nil {
+		return nil, err
+	}
+
+	if err := u.check(); err != nil {
+		return nil, err
+	}
+
+	avatarURL := u.AvatarLarge
+	if avatarURL == "" {
+		avatarURL = u.ProfileImageURL
+	}
+
+	data := &UserProvidedData{}
+
+	data.Metadata = &Claims{
+		Issuer:            p.APIHost,
+		Subject:           u.ID,
+		Name:              u.ScreenName,
+		PreferredUsername: u.ScreenName,
+		Picture:           avatarURL,
+		Profile:           "https://weibo.com/u/" + u.ID,
+
+		// To be deprecated
+		AvatarURL:   avatarURL,
+		FullName:    u.ScreenName,
+		ProviderId:  u.ID,
+		UserNameKey: u.ScreenName,
+	}
+
+	return data, nil
+}
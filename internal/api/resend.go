@@ -17,8 +17,8 @@ type ResendConfirmationParams struct {
 	Phone string `json:"phone"`
 }
 
-func (p *ResendConfirmationParams) Validate(a *API) error {
-	config := a.config
+func (p *ResendConfirmationParams) Validate(r *http.Request, a *API) error {
+	config := a.requestConfig(r.Context())
 
 	switch p.Type {
 	case mail.SignupVerification, mail.EmailChangeVerification, smsVerification, phoneChangeVerification:
@@ -70,7 +70,7 @@ func (a *API) Resend(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
-	if err := params.Validate(a); err != nil {
+	if err := params.Validate(r, a); err != nil {
 		return err
 	}
 
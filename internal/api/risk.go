@@ -0,0 +1,236 @@
+package api
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/supabase/auth/internal/api/apierrors"
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/observability"
+	"github.com/supabase/auth/internal/storage"
+	"github.com/supabase/auth/internal/utilities"
+)
+
+// signInEventHistory is the number of recent sign ins kept per user to
+// recognize known devices and countries.
+const signInEventHistory = 50
+
+// impossibleTravelMinDistance is the distance in km below which travel is
+// never considered impossible, as IP geolocation is imprecise.
+const impossibleTravelMinDistance = 300
+
+// riskAssessment is the risk engine's assessment of a sign in attempt.
+type riskAssessment struct {
+	Score   int
+	Reasons []string
+	Action  string
+}
+
+func (r *riskAssessment) add(score int, reason string) {
+	r.Score += score
+	r.Reasons = append(r.Reasons, reason)
+}
+
+// RequiresAAL2 reports whether the session issued for the attempt needs to
+// be stepped up to AAL2.
+func (r *riskAssessment) RequiresAAL2() bool {
+	return r.Action == conf.RiskActionMFA
+}
+
+// signInEvent returns the sign in event of the request, with the location
+// from the configured headers.
+func (a *API) signInEvent(r *http.Request, user *models.User) *models.SignInEvent {
+	config := a.config.Security.Risk
+
+	event := &models.SignInEvent{
+		UserID:    user.ID,
+		IPAddress: utilities.GetIPAddress(r),
+		UserAgent: r.Header.Get("User-Agent"),
+	}
+
+	if config.CountryHeader != "" {
+		event.Country = strings.ToUpper(strings.TrimSpace(r.Header.Get(config.CountryHeader)))
+	}
+
+	if config.LatitudeHeader != "" && config.LongitudeHeader != "" {
+		latitude, laterr := strconv.ParseFloat(strings.TrimSpace(r.Header.Get(config.LatitudeHeader)), 64)
+		longitude, lonerr := strconv.ParseFloat(strings.TrimSpace(r.Header.Get(config.LongitudeHeader)), 64)
+
+		if laterr == nil && lonerr == nil && math.Abs(latitude) <= 90 && math.Abs(longitude) <= 180 {
+			event.Latitude = &latitude
+			event.Longitude = &longitude
+		}
+	}
+
+	return event
+}
+
+// assessRisk scores a sign in attempt of the user from its device and
+// location compared to the user's recent sign ins, and from the user's
+// recent failed attempts from the same IP address. Failed attempts from other
+// addresses aren't counted, so that others can't get the user blocked.
+func (a *API) assessRisk(r *http.Request, db *storage.Connection, user *models.User) (*riskAssessment, error) {
+	config := a.config.Security.Risk
+	assessment := &riskAssessment{Action: conf.RiskActionAllow}
+
+	if !config.Enabled {
+		return assessment, nil
+	}
+
+	current := a.signInEvent(r, user)
+
+	events, err := models.FindSignInEvents(db, user.ID, signInEventHistory)
+	if err != nil {
+		return nil, apierrors.NewInternalServerError("Database error assessing sign in risk").WithInternalError(err)
+	}
+
+	// without previous sign ins there is nothing to compare against
+	if len(events) > 0 {
+		knownDevice := false
+		knownCountry := false
+		hasCountries := false
+
+		for _, event := range events {
+			if event.UserAgent == current.UserAgent {
+				knownDevice = true
+			}
+
+			if event.Country != "" {
+				hasCountries = true
+
+				if event.Country == current.Country {
+					knownCountry = true
+				}
+			}
+		}
+
+		if !knownDevice {
+			assessment.add(config.NewDeviceScore, "new_device")
+		}
+
+		if current.Country != "" && hasCountries && !knownCountry {
+			assessment.add(config.NewCountryScore, "new_country")
+		}
+
+		if last := events[0]; current.HasLocation() && last.HasLocation() {
+			distance := greatCircleDistance(*last.Latitude, *last.Longitude, *current.Latitude, *current.Longitude)
+			hours := a.Now().Sub(last.CreatedAt).Hours()
+
+			if distance > impossibleTravelMinDistance && (hours <= 0 || distance/hours > config.MaxTravelSpeed) {
+				assessment.add(config.ImpossibleTravelScore, "impossible_travel")
+			}
+		}
+	}
+
+	since := a.Now().Add(-config.VelocityWindow)
+	failures := 0
+
+	for _, kind := range []models.FailedAuthAttemptKind{models.FailedPasswordAttempt, models.FailedMFAAttempt} {
+		attempts, err := models.FindFailedAuthAttempts(db, user.ID, kind, since)
+		if err != nil {
+			return nil, apierrors.NewInternalServerError("Database error assessing sign in risk").WithInternalError(err)
+		}
+
+		for _, attempt := range attempts {
+			if attempt.IPAddress == current.IPAddress {
+				failures++
+			}
+		}
+	}
+
+	if failures > 0 {
+		assessment.add(failures*config.FailedAttemptScore, "failed_attempts")
+	}
+
+	assessment.Action = config.Action(assessment.Score)
+
+	observability.LogEntrySetField(r, "risk_score", assessment.Score)
+	observability.LogEntrySetField(r, "risk_action", assessment.Action)
+
+	return assessment, nil
+}
+
+// enforceRisk blocks the attempt or requires captcha according to the
+// assessment. When the user has no verified MFA factor, or stepUp is false
+// because the flow doesn't issue a session, requiring MFA falls back to
+// requiring captcha, and requiring captcha falls back to blocking when
+// captcha is disabled. The assessment is changed accordingly.
+func (a *API) enforceRisk(r *http.Request, db *storage.Connection, user *models.User, route string, stepUp bool, assessment *riskAssessment) error {
+	config := a.config.Security
+
+	if assessment.Action == conf.RiskActionMFA && stepUp {
+		// reload the user, as its factors may not have been loaded
+		u, err := models.FindUserByID(db, user.ID)
+		if err != nil {
+			return apierrors.NewInternalServerError("Database error finding user").WithInternalError(err)
+		}
+
+		stepUp = u.HighestPossibleAAL() == models.AAL2
+	}
+
+	if assessment.Action == conf.RiskActionMFA && !stepUp {
+		assessment.Action = conf.RiskActionCaptcha
+	}
+
+	if assessment.Action == conf.RiskActionCaptcha && !config.Captcha.Enabled {
+		assessment.Action = conf.RiskActionBlock
+	}
+
+	switch assessment.Action {
+	case conf.RiskActionBlock:
+		return apierrors.NewForbiddenError(apierrors.ErrorCodeSignInBlocked, "Sign in blocked for security reasons")
+
+	case conf.RiskActionCaptcha:
+		// the verifyCaptcha middleware has already verified it
+		if config.Captcha.RequiresCaptcha(route) && !isIgnoreCaptchaRoute(r) {
+			return nil
+		}
+
+		return a.verifyCaptchaToken(r, route)
+	}
+
+	return nil
+}
+
+// requireSteppedUp refuses access tokens of sessions that the risk engine
+// requires to be stepped up to AAL2 until they are, so that they can only be
+// used to verify an MFA factor or to log out.
+func (a *API) requireSteppedUp(w http.ResponseWriter, r *http.Request) (context.Context, error) {
+	ctx := r.Context()
+
+	if session := getSession(ctx); session != nil && session.RequiresAAL2 && !session.IsAAL2() {
+		return nil, apierrors.NewHTTPError(http.StatusUnauthorized, apierrors.ErrorCodeInsufficientAAL, "AAL2 session is required, verify an MFA factor first")
+	}
+
+	return ctx, nil
+}
+
+// recordSignIn records a successful sign in of the user for future risk
+// assessments and new device notifications.
+func (a *API) recordSignIn(r *http.Request, tx *storage.Connection, user *models.User) error {
+	if !a.config.Security.Risk.Enabled && !a.config.Mailer.Notifications.NewDevice.Enabled {
+		return nil
+	}
+
+	return models.AddSignInEvent(tx, a.signInEvent(r, user), signInEventHistory)
+}
+
+// greatCircleDistance returns the distance in km between two coordinates.
+func greatCircleDistance(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadius = 6371
+
+	toRadians := func(deg float64) float64 {
+		return deg * math.Pi / 180
+	}
+
+	dLat := toRadians(lat2 - lat1)
+	dLon := toRadians(lon2 - lon1)
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
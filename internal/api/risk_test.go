@@ -0,0 +1,17 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGreatCircleDistance(t *testing.T) {
+	require.Zero(t, greatCircleDistance(52.52, 13.405, 52.52, 13.405))
+
+	// Berlin to New York
+	require.InDelta(t, 6385, greatCircleDistance(52.52, 13.405, 40.7128, -74.006), 10)
+
+	// Sydney to London
+	require.InDelta(t, 16990, greatCircleDistance(-33.8688, 151.2093, 51.5074, -0.1278), 20)
+}
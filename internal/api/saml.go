@@ -47,6 +47,10 @@ func (a *API) getSAMLServiceProvider(identityProvider *saml.EntityDescriptor, id
 		SignRequest:       true,
 		AllowIDPInitiated: idpInitiated,
 		IDPMetadata:       identityProvider,
+		LogoutBindings: []string{
+			saml.HTTPRedirectBinding,
+			saml.HTTPPostBinding,
+		},
 	})
 
 	provider.AuthnNameIDFormat = saml.PersistentNameIDFormat
@@ -47,8 +47,11 @@ func TestSAMLMetadataWithAPI(t *tst.T) {
 	require.Equal(t, len(metadata.SPSSODescriptors[0].AssertionConsumerServices), 2)
 	require.Equal(t, metadata.SPSSODescriptors[0].AssertionConsumerServices[0].Location, "https://projectref.supabase.co/auth/v1/sso/saml/acs")
 	require.Equal(t, metadata.SPSSODescriptors[0].AssertionConsumerServices[1].Location, "https://projectref.supabase.co/auth/v1/sso/saml/acs")
-	require.Equal(t, len(metadata.SPSSODescriptors[0].SingleLogoutServices), 1)
+	require.Equal(t, len(metadata.SPSSODescriptors[0].SingleLogoutServices), 2)
+	require.Equal(t, metadata.SPSSODescriptors[0].SingleLogoutServices[0].Binding, saml.HTTPRedirectBinding)
 	require.Equal(t, metadata.SPSSODescriptors[0].SingleLogoutServices[0].Location, "https://projectref.supabase.co/auth/v1/sso/saml/slo")
+	require.Equal(t, metadata.SPSSODescriptors[0].SingleLogoutServices[1].Binding, saml.HTTPPostBinding)
+	require.Equal(t, metadata.SPSSODescriptors[0].SingleLogoutServices[1].Location, "https://projectref.supabase.co/auth/v1/sso/saml/slo")
 
 	require.Equal(t, len(metadata.SPSSODescriptors[0].KeyDescriptors), 1)
 	require.Equal(t, metadata.SPSSODescriptors[0].KeyDescriptors[0].Use, "signing")
@@ -46,7 +46,7 @@ func IsSAMLMetadataStale(idpMetadata *saml.EntityDescriptor, samlProvider models
 
 func (a *API) SamlAcs(w http.ResponseWriter, r *http.Request) error {
 	if err := a.handleSamlAcs(w, r); err != nil {
-		u, uerr := url.Parse(a.config.SiteURL)
+		u, uerr := url.Parse(a.requestConfig(r.Context()).SiteURL)
 		if uerr != nil {
 			return apierrors.NewInternalServerError("site url is improperly formattted").WithInternalError(err)
 		}
@@ -63,7 +63,7 @@ func (a *API) handleSamlAcs(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 
 	db := a.db.WithContext(ctx)
-	config := a.config
+	config := a.requestConfig(ctx)
 	log := observability.GetLogEntry(r).Entry
 
 	relayStateValue := r.FormValue("RelayState")
@@ -102,6 +102,10 @@ func (a *API) handleSamlAcs(w http.ResponseWriter, r *http.Request) error {
 			return apierrors.NewInternalServerError("Unable to find SSO Provider from SAML RelayState")
 		}
 
+		if relayState.IsLogout() {
+			return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "SAML RelayState was created for Single Logout, try logging in again?")
+		}
+
 		initiatedBy = "sp"
 		entityId = ssoProvider.SAMLProvider.EntityID
 		redirectTo = relayState.RedirectTo
@@ -196,6 +200,17 @@ func (a *API) handleSamlAcs(w http.ResponseWriter, r *http.Request) error {
 		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "SAML Assertion is not valid").WithInternalError(err)
 	}
 
+	if spAssertion.Subject != nil && spAssertion.Subject.NameID == nil {
+		// the Subject's NameID may be encrypted, which isn't
+		// supported by the SAML library
+		nameID, err := samlEncryptedNameID(a.config.SAML.RSAPrivateKey, r, spAssertion.ID)
+		if err != nil {
+			return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "SAML Assertion EncryptedID could not be decrypted").WithInternalError(err)
+		}
+
+		spAssertion.Subject.NameID = nameID
+	}
+
 	assertion := SAMLAssertion{
 		spAssertion,
 	}
@@ -295,6 +310,9 @@ func (a *API) handleSamlAcs(w http.ResponseWriter, r *http.Request) error {
 		if user, terr = a.createAccountFromExternalIdentity(tx, r, &userProvidedData, providerType); terr != nil {
 			return terr
 		}
+		if grantParams.OrganizationID, terr = joinSSOProviderOrganization(tx, user, providerType); terr != nil {
+			return terr
+		}
 		if flowState != nil {
 			// This means that the callback is using PKCE
 			flowState.UserID = &(user.ID)
@@ -309,6 +327,30 @@ func (a *API) handleSamlAcs(w http.ResponseWriter, r *http.Request) error {
 			return apierrors.NewInternalServerError("Unable to issue refresh token from SAML Assertion").WithInternalError(terr)
 		}
 
+		if assertion.Subject != nil && assertion.Subject.NameID != nil && assertion.Subject.NameID.Value != "" {
+			// remember the NameID and SessionIndex, so that the
+			// session can be found on Single Logout
+			nameID := assertion.Subject.NameID
+			samlSession := models.NewSAMLSession(ssoProvider.ID, nameID.Value, nameID.Format, assertion.SessionIndex())
+
+			if flowState != nil {
+				// linked to the session once the auth code is
+				// exchanged
+				samlSession.FlowStateID = &flowState.ID
+			} else {
+				_, _, session, terr := models.FindUserWithRefreshToken(tx, token.RefreshToken, false)
+				if terr != nil {
+					return apierrors.NewInternalServerError("Unable to find session issued from SAML Assertion").WithInternalError(terr)
+				}
+
+				samlSession.SessionID = &session.ID
+			}
+
+			if terr := tx.Create(samlSession); terr != nil {
+				return apierrors.NewInternalServerError("Unable to save SAML session").WithInternalError(terr)
+			}
+		}
+
 		return nil
 	}); err != nil {
 		return err
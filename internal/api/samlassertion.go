@@ -55,6 +55,19 @@ func (a *SAMLAssertion) UserID() string {
 	return subjectID
 }
 
+// SessionIndex returns the SessionIndex of the first AuthnStatement, which
+// identifies the session at the Identity Provider in Single Logout messages.
+// Returns an empty string if the assertion doesn't have one.
+func (a *SAMLAssertion) SessionIndex() string {
+	for _, stmt := range a.AuthnStatements {
+		if stmt.SessionIndex != "" {
+			return stmt.SessionIndex
+		}
+	}
+
+	return ""
+}
+
 // SubjectID returns the user identifier in present in the Subject section of
 // the SAML assertion. Note that this way of identifying the Subject is
 // generally superseded by the SAMLSubjectIDAttributeName assertion attribute;
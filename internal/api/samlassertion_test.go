@@ -345,3 +345,19 @@ func TestSAMLAssertionProcessing(t *tst.T) {
 		})
 	}
 }
+
+func TestSAMLAssertionSessionIndex(t *tst.T) {
+	assertion := SAMLAssertion{
+		&saml.Assertion{
+			AuthnStatements: []saml.AuthnStatement{
+				{},
+				{SessionIndex: "_session-index"},
+			},
+		},
+	}
+
+	require.Equal(t, "_session-index", assertion.SessionIndex())
+
+	assertion.AuthnStatements = nil
+	require.Equal(t, "", assertion.SessionIndex())
+}
@@ -0,0 +1,938 @@
+package api
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/crewjam/saml"
+	"github.com/crewjam/saml/xmlenc"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	dsig "github.com/russellhaering/goxmldsig"
+	"github.com/supabase/auth/internal/api/apierrors"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/observability"
+	"github.com/supabase/auth/internal/storage"
+	"github.com/supabase/auth/internal/utilities"
+)
+
+// maxSAMLMessageSize limits the size of inflated HTTP-Redirect binding
+// messages.
+const maxSAMLMessageSize = 1024 * 1024
+
+// SAMLLogoutResponse is returned from the logout endpoint when the session
+// was created by a SAML identity provider supporting Single Logout. The user
+// should be sent to the URL to end the session at the identity provider too.
+type SAMLLogoutResponse struct {
+	SAMLLogoutURL string `json:"saml_logout_url"`
+}
+
+// samlMessage is a SAML protocol message received with the HTTP-Redirect or
+// HTTP-POST binding.
+type samlMessage struct {
+	Binding    string
+	Parameter  string
+	XML        []byte
+	RelayState string
+}
+
+var samlPostFormTemplate = template.Must(template.New("saml-post-form").Parse(`<!DOCTYPE html>` +
+	`<html><body onload="document.forms[0].submit()">` +
+	`<noscript><p>JavaScript is disabled. Press the button to continue.</p></noscript>` +
+	`<form method="post" action="{{.URL}}">` +
+	`<input type="hidden" name="{{.Parameter}}" value="{{.Message}}" />` +
+	`{{if .RelayState}}<input type="hidden" name="RelayState" value="{{.RelayState}}" />{{end}}` +
+	`<noscript><input type="submit" value="Continue" /></noscript>` +
+	`</form></body></html>`))
+
+// SamlSlo is the SAML Single Logout endpoint. It receives LogoutRequests
+// from identity providers, LogoutResponses to LogoutRequests sent on logout
+// and renders LogoutRequests sent with the HTTP-POST binding.
+func (a *API) SamlSlo(w http.ResponseWriter, r *http.Request) error {
+	if err := a.handleSamlSlo(w, r); err != nil {
+		u, uerr := url.Parse(a.requestConfig(r.Context()).SiteURL)
+		if uerr != nil {
+			return apierrors.NewInternalServerError("site url is improperly formattted").WithInternalError(err)
+		}
+
+		q := getErrorQueryString(err, utilities.GetRequestID(r.Context()), observability.GetLogEntry(r).Entry, u.Query())
+		u.RawQuery = q.Encode()
+		http.Redirect(w, r, u.String(), http.StatusSeeOther)
+	}
+	return nil
+}
+
+func (a *API) handleSamlSlo(w http.ResponseWriter, r *http.Request) error {
+	switch {
+	case r.FormValue("SAMLRequest") != "":
+		return a.handleSamlLogoutRequest(w, r)
+
+	case r.FormValue("SAMLResponse") != "":
+		return a.handleSamlLogoutResponse(w, r)
+
+	case r.Method == http.MethodGet && r.FormValue("RelayState") != "":
+		return a.renderSamlLogoutRequest(w, r)
+	}
+
+	return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "SAMLRequest or SAMLResponse is missing")
+}
+
+// handleSamlLogoutRequest ends the sessions identified by an identity provider
+// initiated LogoutRequest and replies with a LogoutResponse.
+func (a *API) handleSamlLogoutRequest(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	log := observability.GetLogEntry(r).Entry
+
+	message, err := decodeSAMLMessage(r, "SAMLRequest")
+	if err != nil {
+		return err
+	}
+
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(message.XML); err != nil || doc.Root() == nil || doc.Root().Tag != "LogoutRequest" {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "SAMLRequest is not a valid XML SAML LogoutRequest").WithInternalError(err)
+	}
+
+	// the Issuer is only used to find the keys to verify the signature with,
+	// everything else is read from the element the signature covers
+	issuer := doc.Root().FindElement("./Issuer")
+	if issuer == nil || strings.TrimSpace(issuer.Text()) == "" {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "SAML LogoutRequest does not have an Issuer")
+	}
+
+	ssoProvider, err := models.FindSAMLProviderByEntityID(db, strings.TrimSpace(issuer.Text()))
+	if models.IsNotFoundError(err) {
+		return apierrors.NewNotFoundError(apierrors.ErrorCodeSAMLIdPNotFound, "A SAML connection has not been established with this Identity Provider")
+	} else if err != nil {
+		return err
+	}
+
+	idpMetadata, err := ssoProvider.SAMLProvider.EntityDescriptor()
+	if err != nil {
+		return apierrors.NewInternalServerError("Error parsing SAML Metadata for SAML provider").WithInternalError(err)
+	}
+
+	signed, err := verifySAMLMessageSignature(r, message, doc.Root(), idpMetadata)
+	if err != nil {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "SAML LogoutRequest signature is not valid").WithInternalError(err)
+	}
+
+	var logoutRequest saml.LogoutRequest
+	if err := unmarshalSAMLElement(signed, &logoutRequest); err != nil {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "SAMLRequest is not a valid XML SAML LogoutRequest").WithInternalError(err)
+	}
+
+	if logoutRequest.Issuer == nil || logoutRequest.Issuer.Value != ssoProvider.SAMLProvider.EntityID {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeSAMLEntityIDMismatch, "SAML LogoutRequest Issuer does not match the Identity Provider")
+	}
+
+	serviceProvider := a.getSAMLServiceProvider(idpMetadata, false)
+
+	if logoutRequest.Destination != "" && logoutRequest.Destination != serviceProvider.SloURL.String() {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "SAML LogoutRequest Destination does not match this service provider")
+	}
+
+	if logoutRequest.NotOnOrAfter != nil && !time.Now().Before(*logoutRequest.NotOnOrAfter) {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "SAML LogoutRequest has expired")
+	}
+
+	nameID := logoutRequest.NameID
+	if nameID == nil {
+		if encryptedID := signed.FindElement("./EncryptedID"); encryptedID != nil {
+			nameID, err = decryptSAMLNameID(a.config.SAML.RSAPrivateKey, encryptedID)
+			if err != nil {
+				return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "SAML LogoutRequest EncryptedID could not be decrypted").WithInternalError(err)
+			}
+		}
+	}
+
+	if nameID == nil || nameID.Value == "" {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "SAML LogoutRequest does not identify the user with a NameID")
+	}
+
+	var sessionIndexes []string
+	for _, el := range signed.FindElements("./SessionIndex") {
+		if value := strings.TrimSpace(el.Text()); value != "" {
+			sessionIndexes = append(sessionIndexes, value)
+		}
+	}
+
+	status := saml.StatusSuccess
+
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		samlSessions, terr := models.FindSAMLSessionsByNameID(tx, ssoProvider.ID, nameID.Value, sessionIndexes)
+		if terr != nil {
+			return terr
+		}
+
+		for _, samlSession := range samlSessions {
+			session, terr := models.FindSessionByID(tx, *samlSession.SessionID, false)
+			if models.IsNotFoundError(terr) {
+				continue
+			} else if terr != nil {
+				return terr
+			}
+
+			user, terr := models.FindUserByID(tx, session.UserID)
+			if terr != nil {
+				return terr
+			}
+
+			if terr := models.NewAuditLogEntry(r, tx, user, models.LogoutAction, "", map[string]interface{}{
+				"provider_type":   "sso:" + ssoProvider.ID.String(),
+				"saml_initiated":  "idp",
+				"saml_session_id": samlSession.ID,
+			}); terr != nil {
+				return terr
+			}
+
+			if terr := models.LogoutSession(tx, session.ID); terr != nil {
+				return terr
+			}
+		}
+
+		return nil
+	}); err != nil {
+		// the identity provider is told that logout failed, so that it
+		// can inform the user
+		log.WithError(err).WithField("sso_provider_id", ssoProvider.ID.String()).Error("SAML LogoutRequest could not be processed")
+		status = saml.StatusResponder
+	}
+
+	return a.sendSAMLLogoutResponse(w, r, serviceProvider, idpMetadata, logoutRequest.ID, message, status)
+}
+
+// sendSAMLLogoutResponse replies to a LogoutRequest, preferring the binding
+// the request was received with.
+func (a *API) sendSAMLLogoutResponse(w http.ResponseWriter, r *http.Request, serviceProvider *saml.ServiceProvider, idpMetadata *saml.EntityDescriptor, requestID string, request *samlMessage, status string) error {
+	binding, endpoint := samlIdPSLOEndpoint(idpMetadata, request.Binding, saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if endpoint == nil {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "SAML Identity Provider does not support Single Logout")
+	}
+
+	destination := endpoint.ResponseLocation
+	if destination == "" {
+		destination = endpoint.Location
+	}
+
+	logoutResponse := saml.LogoutResponse{
+		ID:           samlMessageID(),
+		InResponseTo: requestID,
+		Version:      "2.0",
+		IssueInstant: saml.TimeNow(),
+		Destination:  destination,
+		Issuer: &saml.Issuer{
+			Format: "urn:oasis:names:tc:SAML:2.0:nameid-format:entity",
+			Value:  serviceProvider.Metadata().EntityID,
+		},
+		Status: saml.Status{
+			StatusCode: saml.StatusCode{
+				Value: status,
+			},
+		},
+	}
+
+	if binding == saml.HTTPRedirectBinding {
+		redirectURL, err := a.samlRedirectURL(serviceProvider, destination, "SAMLResponse", logoutResponse.Element(), request.RelayState)
+		if err != nil {
+			return apierrors.NewInternalServerError("Error creating SAML LogoutResponse").WithInternalError(err)
+		}
+
+		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+		return nil
+	}
+
+	if err := serviceProvider.SignLogoutResponse(&logoutResponse); err != nil {
+		return apierrors.NewInternalServerError("Error signing SAML LogoutResponse").WithInternalError(err)
+	}
+
+	return sendSAMLPostForm(w, destination, "SAMLResponse", logoutResponse.Element(), request.RelayState)
+}
+
+// handleSamlLogoutResponse completes a Single Logout started by the logout
+// endpoint, sending the user to the redirect URL given on logout.
+func (a *API) handleSamlLogoutResponse(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	config := a.requestConfig(ctx)
+	log := observability.GetLogEntry(r).Entry
+
+	message, err := decodeSAMLMessage(r, "SAMLResponse")
+	if err != nil {
+		return err
+	}
+
+	relayState, err := a.findSAMLLogoutRelayState(ctx, message.RelayState)
+	if err != nil {
+		return err
+	}
+
+	if err := a.samlDestroyRelayState(ctx, relayState); err != nil {
+		return err
+	}
+
+	ssoProvider, err := models.FindSSOProviderByID(db, relayState.SSOProviderID)
+	if err != nil {
+		return apierrors.NewInternalServerError("Unable to find SSO Provider from SAML RelayState").WithInternalError(err)
+	}
+
+	idpMetadata, err := ssoProvider.SAMLProvider.EntityDescriptor()
+	if err != nil {
+		return apierrors.NewInternalServerError("Error parsing SAML Metadata for SAML provider").WithInternalError(err)
+	}
+
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(message.XML); err != nil || doc.Root() == nil || doc.Root().Tag != "LogoutResponse" {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "SAMLResponse is not a valid XML SAML LogoutResponse").WithInternalError(err)
+	}
+
+	signed, err := verifySAMLMessageSignature(r, message, doc.Root(), idpMetadata)
+	if err != nil {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "SAML LogoutResponse signature is not valid").WithInternalError(err)
+	}
+
+	var logoutResponse saml.LogoutResponse
+	if err := unmarshalSAMLElement(signed, &logoutResponse); err != nil {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "SAMLResponse is not a valid XML SAML LogoutResponse").WithInternalError(err)
+	}
+
+	if logoutResponse.Issuer == nil || logoutResponse.Issuer.Value != ssoProvider.SAMLProvider.EntityID {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeSAMLEntityIDMismatch, "SAML LogoutResponse Issuer does not match the Identity Provider")
+	}
+
+	serviceProvider := a.getSAMLServiceProvider(idpMetadata, false)
+
+	if logoutResponse.Destination != "" && logoutResponse.Destination != serviceProvider.SloURL.String() {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "SAML LogoutResponse Destination does not match this service provider")
+	}
+
+	if logoutResponse.InResponseTo != relayState.RequestID {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "SAML LogoutResponse is not a response to the LogoutRequest")
+	}
+
+	if logoutResponse.Status.StatusCode.Value != saml.StatusSuccess {
+		// the session has already ended locally, so the user is
+		// redirected as usual
+		log.WithField("sso_provider_id", ssoProvider.ID.String()).WithField("saml_status", logoutResponse.Status.StatusCode.Value).Warn("SAML Identity Provider did not complete Single Logout")
+	}
+
+	redirectTo := relayState.RedirectTo
+	if !utilities.IsRedirectURLValid(config, redirectTo) {
+		redirectTo = config.SiteURL
+	}
+
+	http.Redirect(w, r, redirectTo, http.StatusSeeOther)
+	return nil
+}
+
+// renderSamlLogoutRequest renders a LogoutRequest created on logout for an
+// identity provider only supporting the HTTP-POST binding.
+func (a *API) renderSamlLogoutRequest(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	relayState, err := a.findSAMLLogoutRelayState(ctx, r.FormValue("RelayState"))
+	if err != nil {
+		return err
+	}
+
+	ssoProvider, err := models.FindSSOProviderByID(db, relayState.SSOProviderID)
+	if err != nil {
+		return apierrors.NewInternalServerError("Unable to find SSO Provider from SAML RelayState").WithInternalError(err)
+	}
+
+	idpMetadata, err := ssoProvider.SAMLProvider.EntityDescriptor()
+	if err != nil {
+		return apierrors.NewInternalServerError("Error parsing SAML Metadata for SAML provider").WithInternalError(err)
+	}
+
+	_, endpoint := samlIdPSLOEndpoint(idpMetadata, saml.HTTPPostBinding)
+	if endpoint == nil {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "SAML Identity Provider does not support Single Logout with the HTTP-POST binding")
+	}
+
+	doc := etree.NewDocument()
+	if err := doc.ReadFromString(*relayState.LogoutRequest); err != nil {
+		return apierrors.NewInternalServerError("Error reading SAML LogoutRequest").WithInternalError(err)
+	}
+
+	return sendSAMLPostForm(w, endpoint.Location, "SAMLRequest", doc.Root(), relayState.ID.String())
+}
+
+// findSAMLLogoutRelayState finds an unexpired relay state created for a
+// LogoutRequest.
+func (a *API) findSAMLLogoutRelayState(ctx context.Context, value string) (*models.SAMLRelayState, error) {
+	db := a.db.WithContext(ctx)
+
+	relayStateUUID := uuid.FromStringOrNil(value)
+	if relayStateUUID == uuid.Nil {
+		return nil, apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "SAML RelayState is not a valid UUID")
+	}
+
+	relayState, err := models.FindSAMLRelayStateByID(db, relayStateUUID)
+	if models.IsNotFoundError(err) {
+		return nil, apierrors.NewNotFoundError(apierrors.ErrorCodeSAMLRelayStateNotFound, "SAML RelayState does not exist")
+	} else if err != nil {
+		return nil, err
+	}
+
+	if !relayState.IsLogout() {
+		return nil, apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "SAML RelayState was not created for Single Logout")
+	}
+
+	if time.Since(relayState.CreatedAt) >= a.config.SAML.RelayStateValidityPeriod {
+		if err := a.samlDestroyRelayState(ctx, relayState); err != nil {
+			return nil, apierrors.NewInternalServerError("SAML RelayState has expired and destroying it failed").WithInternalError(err)
+		}
+
+		return nil, apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeSAMLRelayStateExpired, "SAML RelayState has expired")
+	}
+
+	return relayState, nil
+}
+
+// samlLogoutURL creates a LogoutRequest for the SAML session and returns the
+// URL the user should be sent to, to end their session at the identity
+// provider. An empty URL is returned if the identity provider doesn't
+// support Single Logout.
+func (a *API) samlLogoutURL(tx *storage.Connection, samlSession *models.SAMLSession, redirectTo string) (string, error) {
+	ssoProvider, err := models.FindSSOProviderByID(tx, samlSession.SSOProviderID)
+	if err != nil {
+		return "", err
+	}
+
+	idpMetadata, err := ssoProvider.SAMLProvider.EntityDescriptor()
+	if err != nil {
+		return "", err
+	}
+
+	binding, endpoint := samlIdPSLOEndpoint(idpMetadata, saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if endpoint == nil {
+		return "", nil
+	}
+
+	serviceProvider := a.getSAMLServiceProvider(idpMetadata, false)
+
+	logoutRequest := saml.LogoutRequest{
+		ID:           samlMessageID(),
+		Version:      "2.0",
+		IssueInstant: saml.TimeNow(),
+		Destination:  endpoint.Location,
+		Issuer: &saml.Issuer{
+			Format: "urn:oasis:names:tc:SAML:2.0:nameid-format:entity",
+			Value:  serviceProvider.Metadata().EntityID,
+		},
+		NameID: &saml.NameID{
+			Value: samlSession.NameID,
+		},
+	}
+
+	if samlSession.NameIDFormat != nil {
+		logoutRequest.NameID.Format = *samlSession.NameIDFormat
+	}
+
+	if samlSession.SessionIndex != nil {
+		logoutRequest.SessionIndex = &saml.SessionIndex{
+			Value: *samlSession.SessionIndex,
+		}
+	}
+
+	// the HTTP-Redirect binding signs the query string instead
+	unsignedRequest := logoutRequest.Element()
+
+	if err := serviceProvider.SignLogoutRequest(&logoutRequest); err != nil {
+		return "", err
+	}
+
+	signedRequest, err := logoutRequest.Bytes()
+	if err != nil {
+		return "", err
+	}
+
+	signedRequestString := string(signedRequest)
+
+	relayState := models.SAMLRelayState{
+		SSOProviderID: ssoProvider.ID,
+		RequestID:     logoutRequest.ID,
+		RedirectTo:    redirectTo,
+		LogoutRequest: &signedRequestString,
+	}
+
+	if err := tx.Create(&relayState); err != nil {
+		return "", err
+	}
+
+	if binding == saml.HTTPRedirectBinding {
+		return a.samlRedirectURL(serviceProvider, endpoint.Location, "SAMLRequest", unsignedRequest, relayState.ID.String())
+	}
+
+	sloURL := serviceProvider.SloURL
+	sloURL.RawQuery = url.Values{"RelayState": []string{relayState.ID.String()}}.Encode()
+
+	return sloURL.String(), nil
+}
+
+// samlIdPSLOEndpoint returns the identity provider's Single Logout endpoint
+// for the first of the bindings it supports.
+func samlIdPSLOEndpoint(idpMetadata *saml.EntityDescriptor, bindings ...string) (string, *saml.Endpoint) {
+	for _, binding := range bindings {
+		for _, descriptor := range idpMetadata.IDPSSODescriptors {
+			for i := range descriptor.SingleLogoutServices {
+				endpoint := &descriptor.SingleLogoutServices[i]
+
+				if endpoint.Binding == binding && endpoint.Location != "" {
+					return binding, endpoint
+				}
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// samlMessageID returns a new ID for a SAML protocol message. IDs must not
+// start with a digit.
+func samlMessageID() string {
+	return "id-" + uuid.Must(uuid.NewV4()).String()
+}
+
+// decodeSAMLMessage decodes the SAML protocol message in the parameter.
+// Messages are deflated with the HTTP-Redirect binding, which uses the query
+// string, but not with the HTTP-POST binding.
+func decodeSAMLMessage(r *http.Request, parameter string) (*samlMessage, error) {
+	message := &samlMessage{
+		Parameter: parameter,
+	}
+
+	var encoded string
+
+	if r.Method == http.MethodPost {
+		message.Binding = saml.HTTPPostBinding
+		encoded = r.PostFormValue(parameter)
+		message.RelayState = r.PostFormValue("RelayState")
+	} else {
+		message.Binding = saml.HTTPRedirectBinding
+		encoded = r.URL.Query().Get(parameter)
+		message.RelayState = r.URL.Query().Get("RelayState")
+	}
+
+	if encoded == "" {
+		return nil, apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "%s is missing", parameter)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "%s is not a valid Base64 string", parameter)
+	}
+
+	if message.Binding == saml.HTTPPostBinding {
+		message.XML = decoded
+		return message, nil
+	}
+
+	inflated, err := io.ReadAll(io.LimitReader(flate.NewReader(bytes.NewReader(decoded)), maxSAMLMessageSize+1))
+	if err != nil {
+		return nil, apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "%s could not be inflated", parameter).WithInternalError(err)
+	}
+
+	if len(inflated) > maxSAMLMessageSize {
+		return nil, apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "%s is too large", parameter)
+	}
+
+	message.XML = inflated
+
+	return message, nil
+}
+
+var samlSignatureHashes = map[string]crypto.Hash{
+	dsig.RSASHA1SignatureMethod:   crypto.SHA1,
+	dsig.RSASHA256SignatureMethod: crypto.SHA256,
+	dsig.RSASHA512SignatureMethod: crypto.SHA512,
+}
+
+// samlRedirectURL returns the URL sending the message to the destination
+// with the HTTP-Redirect binding. The query string is signed as described in
+// section 3.4.4.1 of the SAML bindings specification.
+func (a *API) samlRedirectURL(serviceProvider *saml.ServiceProvider, destination, parameter string, el *etree.Element, relayState string) (string, error) {
+	doc := etree.NewDocument()
+	doc.SetRoot(el)
+
+	var deflated bytes.Buffer
+
+	writer, err := flate.NewWriter(&deflated, flate.BestCompression)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := doc.WriteTo(writer); err != nil {
+		return "", err
+	}
+
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	hash, ok := samlSignatureHashes[serviceProvider.SignatureMethod]
+	if !ok {
+		return "", fmt.Errorf("unsupported SAML signature method %q", serviceProvider.SignatureMethod)
+	}
+
+	query := parameter + "=" + url.QueryEscape(base64.StdEncoding.EncodeToString(deflated.Bytes()))
+	if relayState != "" {
+		query += "&RelayState=" + url.QueryEscape(relayState)
+	}
+	query += "&SigAlg=" + url.QueryEscape(serviceProvider.SignatureMethod)
+
+	hasher := hash.New()
+	hasher.Write([]byte(query))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.config.SAML.RSAPrivateKey, hash, hasher.Sum(nil))
+	if err != nil {
+		return "", err
+	}
+
+	query += "&Signature=" + url.QueryEscape(base64.StdEncoding.EncodeToString(signature))
+
+	separator := "?"
+	if strings.Contains(destination, "?") {
+		separator = "&"
+	}
+
+	return destination + separator + query, nil
+}
+
+// sendSAMLPostForm sends the message to the destination with the HTTP-POST
+// binding, as an automatically submitted form.
+func sendSAMLPostForm(w http.ResponseWriter, destination, parameter string, el *etree.Element, relayState string) error {
+	doc := etree.NewDocument()
+	doc.SetRoot(el)
+
+	message, err := doc.WriteToBytes()
+	if err != nil {
+		return apierrors.NewInternalServerError("Error serializing SAML message").WithInternalError(err)
+	}
+
+	var body bytes.Buffer
+	if err := samlPostFormTemplate.Execute(&body, map[string]string{
+		"URL":        destination,
+		"Parameter":  parameter,
+		"Message":    base64.StdEncoding.EncodeToString(message),
+		"RelayState": relayState,
+	}); err != nil {
+		return apierrors.NewInternalServerError("Error rendering SAML form").WithInternalError(err)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(body.Bytes())
+
+	return err
+}
+
+// verifySAMLMessageSignature verifies the signature of a message from the
+// identity provider. The HTTP-Redirect binding signs the query string, while
+// the HTTP-POST binding signs the XML.
+func verifySAMLMessageSignature(r *http.Request, message *samlMessage, root *etree.Element, idpMetadata *saml.EntityDescriptor) (*etree.Element, error) {
+	certs, err := samlIdPSigningCertificates(idpMetadata)
+	if err != nil {
+		return nil, err
+	}
+
+	if message.Binding == saml.HTTPRedirectBinding {
+		// the signature covers the whole encoded message
+		if err := verifySAMLRedirectSignature(r.URL.RawQuery, message.Parameter, certs); err != nil {
+			return nil, err
+		}
+
+		return root, nil
+	}
+
+	return verifySAMLXMLSignature(root, certs)
+}
+
+// unmarshalSAMLElement decodes the element into v.
+func unmarshalSAMLElement(el *etree.Element, v interface{}) error {
+	doc := etree.NewDocument()
+	doc.SetRoot(el.Copy())
+
+	data, err := doc.WriteToBytes()
+	if err != nil {
+		return err
+	}
+
+	return xml.Unmarshal(data, v)
+}
+
+// verifySAMLRedirectSignature verifies the query string signature of a
+// HTTP-Redirect binding message. The signed string is built from the values
+// as they were encoded in the query string.
+func verifySAMLRedirectSignature(rawQuery, parameter string, certs []*x509.Certificate) error {
+	rawValues := make(map[string]string)
+
+	for _, part := range strings.Split(rawQuery, "&") {
+		key, value, _ := strings.Cut(part, "=")
+		if _, ok := rawValues[key]; !ok {
+			rawValues[key] = value
+		}
+	}
+
+	if rawValues["Signature"] == "" || rawValues["SigAlg"] == "" {
+		return errors.New("saml: message is not signed")
+	}
+
+	sigAlg, err := url.QueryUnescape(rawValues["SigAlg"])
+	if err != nil {
+		return err
+	}
+
+	hash, ok := samlSignatureHashes[sigAlg]
+	if !ok {
+		return fmt.Errorf("saml: unsupported signature algorithm %q", sigAlg)
+	}
+
+	encodedSignature, err := url.QueryUnescape(rawValues["Signature"])
+	if err != nil {
+		return err
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return err
+	}
+
+	signed := parameter + "=" + rawValues[parameter]
+	if relayState, ok := rawValues["RelayState"]; ok {
+		signed += "&RelayState=" + relayState
+	}
+	signed += "&SigAlg=" + rawValues["SigAlg"]
+
+	hasher := hash.New()
+	hasher.Write([]byte(signed))
+	digest := hasher.Sum(nil)
+
+	for _, cert := range certs {
+		publicKey, ok := cert.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			continue
+		}
+
+		if rsa.VerifyPKCS1v15(publicKey, hash, digest, signature) == nil {
+			return nil
+		}
+	}
+
+	return errors.New("saml: query string signature does not match any signing certificate")
+}
+
+// verifySAMLXMLSignature verifies the enveloped signature of a HTTP-POST
+// binding message and returns the element it covers. Only that element may be
+// read, as other elements of the message aren't protected by the signature.
+func verifySAMLXMLSignature(el *etree.Element, certs []*x509.Certificate) (*etree.Element, error) {
+	if el.FindElement("./Signature") == nil {
+		return nil, errors.New("saml: message is not signed")
+	}
+
+	// Like for assertions, a KeyInfo without a certificate is removed so
+	// that the signature is verified with the certificates in the metadata.
+	if el.FindElement("./Signature/KeyInfo/X509Data/X509Certificate") == nil {
+		if sigEl := el.FindElement("./Signature"); sigEl != nil {
+			if keyInfo := sigEl.FindElement("KeyInfo"); keyInfo != nil {
+				sigEl.RemoveChild(keyInfo)
+			}
+		}
+	}
+
+	var err error
+
+	for _, cert := range certs {
+		validationContext := dsig.NewDefaultValidationContext(&dsig.MemoryX509CertificateStore{
+			Roots: []*x509.Certificate{cert},
+		})
+		validationContext.IdAttribute = "ID"
+
+		var signed *etree.Element
+		if signed, err = validationContext.Validate(el); err == nil {
+			return signed, nil
+		}
+	}
+
+	return nil, errors.Wrap(err, "saml: signature does not match any signing certificate")
+}
+
+var samlWhitespacePattern = regexp.MustCompile(`\s+`)
+
+// samlIdPSigningCertificates returns the certificates the identity provider
+// signs messages with, according to its metadata.
+func samlIdPSigningCertificates(idpMetadata *saml.EntityDescriptor) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+
+	for _, descriptor := range idpMetadata.IDPSSODescriptors {
+		for _, keyDescriptor := range descriptor.KeyDescriptors {
+			if keyDescriptor.Use != "" && keyDescriptor.Use != "signing" {
+				continue
+			}
+
+			for _, certificate := range keyDescriptor.KeyInfo.X509Data.X509Certificates {
+				der, err := base64.StdEncoding.DecodeString(samlWhitespacePattern.ReplaceAllString(certificate.Data, ""))
+				if err != nil {
+					return nil, errors.Wrap(err, "saml: cannot decode signing certificate")
+				}
+
+				cert, err := x509.ParseCertificate(der)
+				if err != nil {
+					return nil, errors.Wrap(err, "saml: cannot parse signing certificate")
+				}
+
+				certs = append(certs, cert)
+			}
+		}
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.New("saml: identity provider metadata has no signing certificates")
+	}
+
+	return certs, nil
+}
+
+// decryptSAMLElement decrypts an element encrypted with XML Encryption, such
+// as an EncryptedID or EncryptedAssertion, with the service provider's key.
+func decryptSAMLElement(key *rsa.PrivateKey, encryptedEl *etree.Element) (*etree.Element, error) {
+	encryptedDataEl := encryptedEl.FindElement("./EncryptedData")
+	if encryptedDataEl == nil {
+		return nil, errors.New("saml: EncryptedData is missing")
+	}
+
+	var decryptionKey interface{} = key
+
+	// the encrypted key may be a sibling of the encrypted data, instead
+	// of in its KeyInfo
+	if keyEl := encryptedEl.FindElement("./EncryptedKey"); keyEl != nil {
+		symmetricKey, err := xmlenc.Decrypt(key, keyEl)
+		if err != nil {
+			return nil, errors.Wrap(err, "saml: cannot decrypt EncryptedKey")
+		}
+
+		decryptionKey = symmetricKey
+	}
+
+	plaintext, err := xmlenc.Decrypt(decryptionKey, encryptedDataEl)
+	if err != nil {
+		return nil, errors.Wrap(err, "saml: cannot decrypt EncryptedData")
+	}
+
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(plaintext); err != nil {
+		return nil, errors.Wrap(err, "saml: decrypted data is not valid XML")
+	}
+
+	if doc.Root() == nil {
+		return nil, errors.New("saml: decrypted data is empty")
+	}
+
+	return doc.Root(), nil
+}
+
+// decryptSAMLNameID decrypts an EncryptedID element into the NameID it holds.
+func decryptSAMLNameID(key *rsa.PrivateKey, encryptedID *etree.Element) (*saml.NameID, error) {
+	el, err := decryptSAMLElement(key, encryptedID)
+	if err != nil {
+		return nil, err
+	}
+
+	if el.Tag != "NameID" {
+		return nil, fmt.Errorf("saml: EncryptedID holds %q instead of a NameID", el.Tag)
+	}
+
+	doc := etree.NewDocument()
+	doc.SetRoot(el)
+
+	raw, err := doc.WriteToBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	var nameID saml.NameID
+	if err := xml.Unmarshal(raw, &nameID); err != nil {
+		return nil, errors.Wrap(err, "saml: cannot parse decrypted NameID")
+	}
+
+	return &nameID, nil
+}
+
+// samlEncryptedNameID finds the assertion with the ID in a HTTP-POST binding
+// SAMLResponse and decrypts the EncryptedID in its Subject. Assertions are
+// looked up in the same order as they're parsed, so the NameID comes from the
+// assertion that was validated. It returns nil if there's no EncryptedID.
+func samlEncryptedNameID(key *rsa.PrivateKey, r *http.Request, assertionID string) (*saml.NameID, error) {
+	encoded := r.PostFormValue("SAMLResponse")
+	if encoded == "" {
+		return nil, nil
+	}
+
+	responseXML, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(responseXML); err != nil {
+		return nil, err
+	}
+
+	if doc.Root() == nil {
+		return nil, errors.New("saml: SAMLResponse is empty")
+	}
+
+	var assertionEl *etree.Element
+
+	for _, el := range doc.Root().FindElements("./Assertion") {
+		if el.SelectAttrValue("ID", "") == assertionID {
+			assertionEl = el
+			break
+		}
+	}
+
+	if assertionEl == nil {
+		for _, encryptedEl := range doc.Root().FindElements("./EncryptedAssertion") {
+			el, err := decryptSAMLElement(key, encryptedEl)
+			if err != nil {
+				return nil, err
+			}
+
+			if el.SelectAttrValue("ID", "") == assertionID {
+				assertionEl = el
+				break
+			}
+		}
+	}
+
+	if assertionEl == nil {
+		return nil, nil
+	}
+
+	encryptedID := assertionEl.FindElement("./Subject/EncryptedID")
+	if encryptedID == nil {
+		return nil, nil
+	}
+
+	return decryptSAMLNameID(key, encryptedID)
+}
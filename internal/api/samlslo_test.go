@@ -0,0 +1,464 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/beevik/etree"
+	"github.com/crewjam/saml"
+	"github.com/crewjam/saml/xmlenc"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/models"
+)
+
+const sloTestIdPEntityID = "https://idp.example.com/saml"
+const sloTestIdPSLOURL = "https://idp.example.com/saml/slo"
+
+// sloSAMLIDPMetadata returns metadata for an identity provider supporting
+// Single Logout with the bindings. It signs with the certificate, which the
+// tests set to the service provider's own, so that messages from the
+// identity provider can be signed with the service provider's key.
+func sloSAMLIDPMetadata(cert string, bindings ...string) string {
+	var services strings.Builder
+	for _, binding := range bindings {
+		fmt.Fprintf(&services, `<md:SingleLogoutService Binding="%s" Location="%s"/>`, binding, sloTestIdPSLOURL)
+	}
+
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?><md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="%s">
+  <md:IDPSSODescriptor WantAuthnRequestsSigned="false" protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+    <md:KeyDescriptor use="signing">
+      <ds:KeyInfo xmlns:ds="http://www.w3.org/2000/09/xmldsig#">
+        <ds:X509Data>
+          <ds:X509Certificate>%s</ds:X509Certificate>
+        </ds:X509Data>
+      </ds:KeyInfo>
+    </md:KeyDescriptor>
+    %s
+    <md:NameIDFormat>urn:oasis:names:tc:SAML:2.0:nameid-format:persistent</md:NameIDFormat>
+    <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="%s"/>
+  </md:IDPSSODescriptor>
+</md:EntityDescriptor>`, sloTestIdPEntityID, cert, services.String(), sloTestIdPEntityID)
+}
+
+func sloTestAPI(t *testing.T) *API {
+	config, err := conf.LoadGlobal(apiTestConfig)
+	require.NoError(t, err)
+	require.True(t, config.SAML.Enabled)
+
+	return NewAPI(config, nil)
+}
+
+func sloTestMetadata(t *testing.T, api *API, bindings ...string) *saml.EntityDescriptor {
+	cert := base64.StdEncoding.EncodeToString(api.config.SAML.Certificate.Raw)
+
+	provider := models.SAMLProvider{
+		MetadataXML: sloSAMLIDPMetadata(cert, bindings...),
+	}
+
+	metadata, err := provider.EntityDescriptor()
+	require.NoError(t, err)
+
+	return metadata
+}
+
+func TestSAMLRedirectBindingSignature(t *testing.T) {
+	api := sloTestAPI(t)
+	metadata := sloTestMetadata(t, api, saml.HTTPRedirectBinding)
+	serviceProvider := api.getSAMLServiceProvider(metadata, false)
+
+	logoutRequest := saml.LogoutRequest{
+		ID:           samlMessageID(),
+		Version:      "2.0",
+		IssueInstant: saml.TimeNow(),
+		Destination:  serviceProvider.SloURL.String(),
+		Issuer: &saml.Issuer{
+			Value: sloTestIdPEntityID,
+		},
+		NameID: &saml.NameID{
+			Value: "name-id",
+		},
+	}
+
+	redirectURL, err := api.samlRedirectURL(serviceProvider, serviceProvider.SloURL.String(), "SAMLRequest", logoutRequest.Element(), "relay state")
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, redirectURL, nil)
+
+	message, err := decodeSAMLMessage(req, "SAMLRequest")
+	require.NoError(t, err)
+	require.Equal(t, saml.HTTPRedirectBinding, message.Binding)
+	require.Equal(t, "relay state", message.RelayState)
+
+	var decoded saml.LogoutRequest
+	require.NoError(t, xml.Unmarshal(message.XML, &decoded))
+	require.Equal(t, logoutRequest.ID, decoded.ID)
+	require.Equal(t, "name-id", decoded.NameID.Value)
+
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromBytes(message.XML))
+	validated, err := verifySAMLMessageSignature(req, message, doc.Root(), metadata)
+	require.NoError(t, err)
+	require.Equal(t, doc.Root(), validated)
+
+	// a different RelayState invalidates the signature
+	tampered := httptest.NewRequest(http.MethodGet, strings.Replace(redirectURL, "RelayState=relay+state", "RelayState=other", 1), nil)
+	_, err = verifySAMLMessageSignature(tampered, message, doc.Root(), metadata)
+	require.Error(t, err)
+
+	// unsigned messages are rejected
+	unsigned, err := url.Parse(redirectURL)
+	require.NoError(t, err)
+	query := unsigned.Query()
+	query.Del("Signature")
+	unsigned.RawQuery = query.Encode()
+	_, err = verifySAMLMessageSignature(httptest.NewRequest(http.MethodGet, unsigned.String(), nil), message, doc.Root(), metadata)
+	require.Error(t, err)
+}
+
+func TestSAMLPostBindingSignature(t *testing.T) {
+	api := sloTestAPI(t)
+	metadata := sloTestMetadata(t, api, saml.HTTPPostBinding)
+	serviceProvider := api.getSAMLServiceProvider(metadata, false)
+
+	logoutResponse := saml.LogoutResponse{
+		ID:           samlMessageID(),
+		InResponseTo: "id-request",
+		Version:      "2.0",
+		IssueInstant: saml.TimeNow(),
+		Issuer: &saml.Issuer{
+			Value: sloTestIdPEntityID,
+		},
+		Status: saml.Status{
+			StatusCode: saml.StatusCode{
+				Value: saml.StatusSuccess,
+			},
+		},
+	}
+	require.NoError(t, serviceProvider.SignLogoutResponse(&logoutResponse))
+
+	doc := etree.NewDocument()
+	doc.SetRoot(logoutResponse.Element())
+	signed, err := doc.WriteToBytes()
+	require.NoError(t, err)
+
+	form := url.Values{
+		"SAMLResponse": []string{base64.StdEncoding.EncodeToString(signed)},
+	}
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/sso/saml/slo", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	message, err := decodeSAMLMessage(req, "SAMLResponse")
+	require.NoError(t, err)
+	require.Equal(t, saml.HTTPPostBinding, message.Binding)
+
+	parsed := etree.NewDocument()
+	require.NoError(t, parsed.ReadFromBytes(message.XML))
+	validated, err := verifySAMLMessageSignature(req, message, parsed.Root(), metadata)
+	require.NoError(t, err)
+
+	var decoded saml.LogoutResponse
+	require.NoError(t, unmarshalSAMLElement(validated, &decoded))
+	require.Equal(t, logoutResponse.ID, decoded.ID)
+	require.Equal(t, "id-request", decoded.InResponseTo)
+	require.Equal(t, sloTestIdPEntityID, decoded.Issuer.Value)
+
+	// changing the signed message invalidates the signature
+	tampered := etree.NewDocument()
+	require.NoError(t, tampered.ReadFromBytes(message.XML))
+	tampered.Root().CreateAttr("InResponseTo", "id-other")
+	_, err = verifySAMLMessageSignature(req, message, tampered.Root(), metadata)
+	require.Error(t, err)
+}
+
+func TestDecryptSAMLNameID(t *testing.T) {
+	api := sloTestAPI(t)
+
+	encryptor := xmlenc.OAEP()
+	encryptor.BlockCipher = xmlenc.AES128CBC
+	encryptor.DigestMethod = &xmlenc.SHA1
+
+	plaintext := `<saml:NameID xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" Format="urn:oasis:names:tc:SAML:2.0:nameid-format:persistent">encrypted-name-id</saml:NameID>`
+
+	encryptedData, err := encryptor.Encrypt(api.config.SAML.Certificate, []byte(plaintext), nil)
+	require.NoError(t, err)
+
+	encryptedID := etree.NewElement("saml:EncryptedID")
+	encryptedID.AddChild(encryptedData)
+
+	nameID, err := decryptSAMLNameID(api.config.SAML.RSAPrivateKey, encryptedID)
+	require.NoError(t, err)
+	require.Equal(t, "encrypted-name-id", nameID.Value)
+	require.Equal(t, string(saml.PersistentNameIDFormat), nameID.Format)
+
+	_, err = decryptSAMLNameID(api.config.SAML.RSAPrivateKey, etree.NewElement("saml:EncryptedID"))
+	require.Error(t, err)
+}
+
+type SAMLSLOTestSuite struct {
+	suite.Suite
+	API    *API
+	Config *conf.GlobalConfiguration
+}
+
+func TestSAMLSLO(t *testing.T) {
+	api, config, err := setupAPIForTest()
+	require.NoError(t, err)
+
+	ts := &SAMLSLOTestSuite{
+		API:    api,
+		Config: config,
+	}
+	defer api.db.Close()
+
+	if config.SAML.Enabled {
+		suite.Run(t, ts)
+	}
+}
+
+func (ts *SAMLSLOTestSuite) SetupTest() {
+	models.TruncateAll(ts.API.db)
+}
+
+// createSAMLSession creates a user with a session created by the identity
+// provider, returning an access token for the session.
+func (ts *SAMLSLOTestSuite) createSAMLSession(ssoProvider *models.SSOProvider, nameID, sessionIndex string) (*models.Session, string) {
+	u, err := models.NewUser("", nameID+"@example.com", "", ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), ts.API.db.Create(u))
+
+	s, err := models.NewSession(u.ID, nil)
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), ts.API.db.Create(s))
+
+	samlSession := models.NewSAMLSession(ssoProvider.ID, nameID, string(saml.PersistentNameIDFormat), sessionIndex)
+	samlSession.SessionID = &s.ID
+	require.NoError(ts.T(), ts.API.db.Create(samlSession))
+
+	req := httptest.NewRequest(http.MethodPost, "/token", nil)
+	token, _, err := ts.API.generateAccessToken(req, ts.API.db, u, &s.ID, models.SSOSAML)
+	require.NoError(ts.T(), err)
+
+	return s, token
+}
+
+func (ts *SAMLSLOTestSuite) createSSOProvider(bindings ...string) *models.SSOProvider {
+	cert := base64.StdEncoding.EncodeToString(ts.Config.SAML.Certificate.Raw)
+
+	ssoProvider := &models.SSOProvider{}
+	require.NoError(ts.T(), ts.API.db.Create(ssoProvider))
+
+	samlProvider := &models.SAMLProvider{
+		SSOProviderID: ssoProvider.ID,
+		EntityID:      sloTestIdPEntityID,
+		MetadataXML:   sloSAMLIDPMetadata(cert, bindings...),
+	}
+	require.NoError(ts.T(), ts.API.db.Create(samlProvider))
+
+	ssoProvider.SAMLProvider = *samlProvider
+
+	return ssoProvider
+}
+
+func (ts *SAMLSLOTestSuite) TestIdPInitiatedLogout() {
+	ssoProvider := ts.createSSOProvider(saml.HTTPRedirectBinding)
+
+	loggedOut, _ := ts.createSAMLSession(ssoProvider, "user-a", "index-1")
+	otherIndex, _ := ts.createSAMLSession(ssoProvider, "user-a", "index-2")
+	otherUser, _ := ts.createSAMLSession(ssoProvider, "user-b", "index-1")
+
+	metadata, err := ssoProvider.SAMLProvider.EntityDescriptor()
+	require.NoError(ts.T(), err)
+	serviceProvider := ts.API.getSAMLServiceProvider(metadata, false)
+
+	logoutRequest := saml.LogoutRequest{
+		ID:           samlMessageID(),
+		Version:      "2.0",
+		IssueInstant: saml.TimeNow(),
+		Destination:  serviceProvider.SloURL.String(),
+		Issuer: &saml.Issuer{
+			Value: sloTestIdPEntityID,
+		},
+		NameID: &saml.NameID{
+			Format: string(saml.PersistentNameIDFormat),
+			Value:  "user-a",
+		},
+		SessionIndex: &saml.SessionIndex{
+			Value: "index-1",
+		},
+	}
+
+	requestURL, err := ts.API.samlRedirectURL(serviceProvider, "http://localhost/sso/saml/slo", "SAMLRequest", logoutRequest.Element(), "idp-state")
+	require.NoError(ts.T(), err)
+
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, requestURL, nil))
+	require.Equal(ts.T(), http.StatusSeeOther, w.Code)
+
+	location, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), sloTestIdPSLOURL, location.Scheme+"://"+location.Host+location.Path)
+	require.Equal(ts.T(), "idp-state", location.Query().Get("RelayState"))
+	require.NotEmpty(ts.T(), location.Query().Get("Signature"))
+
+	message, err := decodeSAMLMessage(httptest.NewRequest(http.MethodGet, location.String(), nil), "SAMLResponse")
+	require.NoError(ts.T(), err)
+
+	var logoutResponse saml.LogoutResponse
+	require.NoError(ts.T(), xml.Unmarshal(message.XML, &logoutResponse))
+	require.Equal(ts.T(), logoutRequest.ID, logoutResponse.InResponseTo)
+	require.Equal(ts.T(), saml.StatusSuccess, logoutResponse.Status.StatusCode.Value)
+
+	_, err = models.FindSessionByID(ts.API.db, loggedOut.ID, false)
+	require.True(ts.T(), models.IsNotFoundError(err))
+
+	for _, s := range []*models.Session{otherIndex, otherUser} {
+		_, err = models.FindSessionByID(ts.API.db, s.ID, false)
+		require.NoError(ts.T(), err)
+	}
+}
+
+func (ts *SAMLSLOTestSuite) TestIdPInitiatedLogoutRequiresSignature() {
+	ssoProvider := ts.createSSOProvider(saml.HTTPPostBinding)
+	s, _ := ts.createSAMLSession(ssoProvider, "user-a", "")
+
+	logoutRequest := saml.LogoutRequest{
+		ID:           samlMessageID(),
+		Version:      "2.0",
+		IssueInstant: saml.TimeNow(),
+		Issuer: &saml.Issuer{
+			Value: sloTestIdPEntityID,
+		},
+		NameID: &saml.NameID{
+			Value: "user-a",
+		},
+	}
+
+	requestXML, err := logoutRequest.Bytes()
+	require.NoError(ts.T(), err)
+
+	form := url.Values{
+		"SAMLRequest": []string{base64.StdEncoding.EncodeToString(requestXML)},
+	}
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/sso/saml/slo", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusSeeOther, w.Code)
+
+	location, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), "SAML LogoutRequest signature is not valid", location.Query().Get("error_description"))
+
+	_, err = models.FindSessionByID(ts.API.db, s.ID, false)
+	require.NoError(ts.T(), err)
+}
+
+func (ts *SAMLSLOTestSuite) TestSPInitiatedLogout() {
+	ssoProvider := ts.createSSOProvider(saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	s, token := ts.createSAMLSession(ssoProvider, "user-a", "index-1")
+
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/logout?redirect_to="+url.QueryEscape(ts.Config.SiteURL), nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var response SAMLLogoutResponse
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&response))
+
+	_, err := models.FindSessionByID(ts.API.db, s.ID, false)
+	require.True(ts.T(), models.IsNotFoundError(err))
+
+	logoutURL, err := url.Parse(response.SAMLLogoutURL)
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), sloTestIdPSLOURL, logoutURL.Scheme+"://"+logoutURL.Host+logoutURL.Path)
+
+	message, err := decodeSAMLMessage(httptest.NewRequest(http.MethodGet, logoutURL.String(), nil), "SAMLRequest")
+	require.NoError(ts.T(), err)
+
+	var logoutRequest saml.LogoutRequest
+	require.NoError(ts.T(), xml.Unmarshal(message.XML, &logoutRequest))
+	require.Equal(ts.T(), "user-a", logoutRequest.NameID.Value)
+	require.Equal(ts.T(), "index-1", logoutRequest.SessionIndex.Value)
+
+	metadata, err := ssoProvider.SAMLProvider.EntityDescriptor()
+	require.NoError(ts.T(), err)
+	serviceProvider := ts.API.getSAMLServiceProvider(metadata, false)
+
+	// the identity provider responds to the LogoutRequest
+	logoutResponse := saml.LogoutResponse{
+		ID:           samlMessageID(),
+		InResponseTo: logoutRequest.ID,
+		Version:      "2.0",
+		IssueInstant: saml.TimeNow(),
+		Destination:  serviceProvider.SloURL.String(),
+		Issuer: &saml.Issuer{
+			Value: sloTestIdPEntityID,
+		},
+		Status: saml.Status{
+			StatusCode: saml.StatusCode{
+				Value: saml.StatusSuccess,
+			},
+		},
+	}
+
+	responseURL, err := ts.API.samlRedirectURL(serviceProvider, "http://localhost/sso/saml/slo", "SAMLResponse", logoutResponse.Element(), message.RelayState)
+	require.NoError(ts.T(), err)
+
+	w = httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, responseURL, nil))
+	require.Equal(ts.T(), http.StatusSeeOther, w.Code)
+	require.Equal(ts.T(), ts.Config.SiteURL, w.Header().Get("Location"))
+
+	// the relay state can't be used again
+	w = httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, responseURL, nil))
+	require.Equal(ts.T(), http.StatusSeeOther, w.Code)
+	require.NotEqual(ts.T(), ts.Config.SiteURL, w.Header().Get("Location"))
+}
+
+func (ts *SAMLSLOTestSuite) TestSPInitiatedLogoutPostBinding() {
+	ssoProvider := ts.createSSOProvider(saml.HTTPPostBinding)
+	_, token := ts.createSAMLSession(ssoProvider, "user-a", "index-1")
+
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/logout", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var response SAMLLogoutResponse
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&response))
+
+	logoutURL, err := url.Parse(response.SAMLLogoutURL)
+	require.NoError(ts.T(), err)
+	require.True(ts.T(), strings.HasSuffix(logoutURL.Path, "/sso/saml/slo"))
+
+	w = httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://localhost/sso/saml/slo?"+logoutURL.RawQuery, nil))
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+	require.Contains(ts.T(), w.Header().Get("Content-Type"), "text/html")
+	require.Contains(ts.T(), w.Body.String(), `action="`+sloTestIdPSLOURL+`"`)
+	require.Contains(ts.T(), w.Body.String(), `name="SAMLRequest"`)
+}
+
+func (ts *SAMLSLOTestSuite) TestLogoutWithoutSingleLogout() {
+	ssoProvider := ts.createSSOProvider()
+	_, token := ts.createSAMLSession(ssoProvider, "user-a", "index-1")
+
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/logout", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusNoContent, w.Code)
+}
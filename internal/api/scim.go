@@ -0,0 +1,1202 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gofrs/uuid"
+	"github.com/supabase/auth/internal/api/apierrors"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/observability"
+	"github.com/supabase/auth/internal/storage"
+)
+
+const (
+	scimSchemaUser                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	scimSchemaGroup                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	scimSchemaListResponse          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	scimSchemaError                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+	scimSchemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+
+	scimContentType = "application/scim+json"
+
+	// scimMaxResults is the maximum and default number of resources
+	// returned by a list request.
+	scimMaxResults = 100
+
+	// scimDeactivatedBanDuration bans deactivated users for good, or until
+	// they are activated again.
+	scimDeactivatedBanDuration = 100 * 365 * 24 * time.Hour
+)
+
+// SCIM error types, see RFC 7644 section 3.12.
+const (
+	scimTypeInvalidFilter = "invalidFilter"
+	scimTypeInvalidSyntax = "invalidSyntax"
+	scimTypeInvalidPath   = "invalidPath"
+	scimTypeInvalidValue  = "invalidValue"
+	scimTypeNoTarget      = "noTarget"
+	scimTypeMutability    = "mutability"
+	scimTypeUniqueness    = "uniqueness"
+)
+
+// scimError is an error returned to SCIM clients in the SCIM error format.
+type scimError struct {
+	Status   int
+	SCIMType string
+	Detail   string
+}
+
+func newSCIMError(status int, scimType string, fmtString string, args ...any) *scimError {
+	return &scimError{
+		Status:   status,
+		SCIMType: scimType,
+		Detail:   fmt.Sprintf(fmtString, args...),
+	}
+}
+
+func (e *scimError) Error() string {
+	return e.Detail
+}
+
+func sendSCIMJSON(w http.ResponseWriter, status int, obj interface{}) error {
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return fmt.Errorf("error encoding SCIM response: %w", err)
+	}
+
+	w.Header().Set("Content-Type", scimContentType)
+	w.WriteHeader(status)
+	_, err = w.Write(b)
+	return err
+}
+
+// sendSCIMError renders an error in the SCIM error format. Errors other than
+// scimError and HTTPError are logged and hidden from the client.
+func sendSCIMError(w http.ResponseWriter, r *http.Request, err error) {
+	log := observability.GetLogEntry(r).Entry
+
+	output := struct {
+		Schemas  []string `json:"schemas"`
+		Status   string   `json:"status"`
+		SCIMType string   `json:"scimType,omitempty"`
+		Detail   string   `json:"detail,omitempty"`
+	}{
+		Schemas: []string{scimSchemaError},
+	}
+
+	status := http.StatusInternalServerError
+
+	switch e := err.(type) {
+	case *scimError:
+		status = e.Status
+		output.SCIMType = e.SCIMType
+		output.Detail = e.Detail
+
+	case *apierrors.HTTPError:
+		status = e.HTTPStatus
+		output.Detail = e.Message
+
+		if status >= http.StatusInternalServerError {
+			log.WithError(e.Cause()).Error(e.Error())
+		}
+
+		if e.ErrorCode == apierrors.ErrorCodeBadJSON {
+			output.SCIMType = scimTypeInvalidSyntax
+		}
+
+	default:
+		log.WithError(err).Error("Unhandled SCIM server error")
+		output.Detail = "Internal server error"
+	}
+
+	output.Status = strconv.Itoa(status)
+
+	if jsonErr := sendSCIMJSON(w, status, output); jsonErr != nil && jsonErr != context.DeadlineExceeded {
+		log.WithError(jsonErr).Warn("Failed to send JSON on ResponseWriter")
+	}
+}
+
+// scimHandler authenticates SCIM requests with the bearer token of an SSO
+// provider, which is added to the context, and renders errors in the SCIM
+// error format.
+func (a *API) scimHandler(fn apiHandler) apiHandler {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		ctx, err := a.requireSCIMToken(r)
+		if err == nil {
+			err = fn(w, r.WithContext(ctx))
+		}
+
+		if err != nil {
+			sendSCIMError(w, r, err)
+		}
+
+		return nil
+	}
+}
+
+func (a *API) requireSCIMToken(r *http.Request) (context.Context, error) {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	token, err := a.extractBearerToken(r)
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := models.FindSSOProviderBySCIMToken(db, token)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return nil, newSCIMError(http.StatusUnauthorized, "", "Invalid SCIM token")
+		}
+
+		return nil, apierrors.NewInternalServerError("Database error finding SSO provider").WithInternalError(err)
+	}
+
+	observability.LogEntrySetField(r, "sso_provider_id", provider.ID.String())
+
+	return withSSOProvider(ctx, provider), nil
+}
+
+type SCIMMeta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location"`
+}
+
+type SCIMName struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type SCIMEmail struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type SCIMReference struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+// SCIMUser is a SCIM User resource. Attributes not listed here are ignored.
+type SCIMUser struct {
+	Schemas    []string        `json:"schemas"`
+	ID         string          `json:"id,omitempty"`
+	ExternalID string          `json:"externalId,omitempty"`
+	UserName   string          `json:"userName"`
+	Name       *SCIMName       `json:"name,omitempty"`
+	Emails     []SCIMEmail     `json:"emails,omitempty"`
+	Active     *bool           `json:"active,omitempty"`
+	Groups     []SCIMReference `json:"groups,omitempty"`
+	Meta       *SCIMMeta       `json:"meta,omitempty"`
+}
+
+// email returns the primary email address of the user, or the first one if
+// none is primary.
+func (u *SCIMUser) email() string {
+	for _, email := range u.Emails {
+		if email.Primary {
+			return email.Value
+		}
+	}
+
+	if len(u.Emails) > 0 {
+		return u.Emails[0].Value
+	}
+
+	return ""
+}
+
+func (a *API) validateSCIMUser(u *SCIMUser) error {
+	if strings.TrimSpace(u.UserName) == "" {
+		return newSCIMError(http.StatusBadRequest, scimTypeInvalidValue, "userName is required")
+	}
+
+	email := u.email()
+	if email == "" {
+		return newSCIMError(http.StatusBadRequest, scimTypeInvalidValue, "An email address is required")
+	}
+
+	if _, err := a.validateEmail(email); err != nil {
+		return newSCIMError(http.StatusBadRequest, scimTypeInvalidValue, "Invalid email address %q", email)
+	}
+
+	return nil
+}
+
+// userMetaData returns the user metadata updates for the user's name.
+func (u *SCIMUser) userMetaData() map[string]interface{} {
+	name := u.Name
+	if name == nil {
+		name = &SCIMName{}
+	}
+
+	data := make(map[string]interface{})
+	for key, value := range map[string]string{
+		"name":        name.Formatted,
+		"given_name":  name.GivenName,
+		"family_name": name.FamilyName,
+	} {
+		if value != "" {
+			data[key] = value
+		} else {
+			data[key] = nil
+		}
+	}
+
+	return data
+}
+
+// SCIMGroup is a SCIM Group resource.
+type SCIMGroup struct {
+	Schemas     []string        `json:"schemas"`
+	ID          string          `json:"id,omitempty"`
+	ExternalID  string          `json:"externalId,omitempty"`
+	DisplayName string          `json:"displayName"`
+	Members     []SCIMReference `json:"members"`
+	Meta        *SCIMMeta       `json:"meta,omitempty"`
+}
+
+func (g *SCIMGroup) validate() error {
+	if strings.TrimSpace(g.DisplayName) == "" {
+		return newSCIMError(http.StatusBadRequest, scimTypeInvalidValue, "displayName is required")
+	}
+
+	return nil
+}
+
+type SCIMPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// SCIMPatchParams is a SCIM PatchOp request.
+type SCIMPatchParams struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []SCIMPatchOperation `json:"Operations"`
+}
+
+type scimListResponse struct {
+	Schemas      []string      `json:"schemas"`
+	TotalResults int           `json:"totalResults"`
+	StartIndex   int           `json:"startIndex"`
+	ItemsPerPage int           `json:"itemsPerPage"`
+	Resources    []interface{} `json:"Resources"`
+}
+
+var scimFilterRegexp = regexp.MustCompile(`^\s*([A-Za-z][A-Za-z0-9.:]*)\s+(?i:eq)\s+("(?:[^"\\]|\\.)*")\s*$`)
+
+// parseSCIMFilter parses an `attribute eq "value"` filter. Other filters
+// aren't supported.
+func parseSCIMFilter(filter string) (*models.SCIMFilter, error) {
+	if strings.TrimSpace(filter) == "" {
+		return nil, nil
+	}
+
+	matches := scimFilterRegexp.FindStringSubmatch(filter)
+	if matches == nil {
+		return nil, newSCIMError(http.StatusBadRequest, scimTypeInvalidFilter, "Only filters of the form 'attribute eq \"value\"' are supported")
+	}
+
+	var value string
+	if err := json.Unmarshal([]byte(matches[2]), &value); err != nil {
+		return nil, newSCIMError(http.StatusBadRequest, scimTypeInvalidFilter, "Invalid filter value")
+	}
+
+	attribute := strings.ToLower(matches[1])
+	attribute = strings.TrimPrefix(attribute, strings.ToLower(scimSchemaUser)+":")
+	attribute = strings.TrimPrefix(attribute, strings.ToLower(scimSchemaGroup)+":")
+	if attribute == "emails" {
+		attribute = models.SCIMFilterEmail
+	}
+
+	return &models.SCIMFilter{
+		Attribute: attribute,
+		Value:     value,
+	}, nil
+}
+
+// scimListParams parses the filter and the 1-based pagination of a list
+// request. Only the attributes are supported by the filter.
+func scimListParams(r *http.Request, attributes ...string) (filter *models.SCIMFilter, startIndex, count int, err error) {
+	query := r.URL.Query()
+
+	filter, err = parseSCIMFilter(query.Get("filter"))
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	if filter != nil {
+		supported := false
+		for _, attribute := range attributes {
+			if filter.Attribute == attribute {
+				supported = true
+				break
+			}
+		}
+
+		if !supported {
+			return nil, 0, 0, newSCIMError(http.StatusBadRequest, scimTypeInvalidFilter, "Filtering on %q is not supported", filter.Attribute)
+		}
+	}
+
+	startIndex = 1
+	if value := query.Get("startIndex"); value != "" {
+		startIndex, err = strconv.Atoi(value)
+		if err != nil {
+			return nil, 0, 0, newSCIMError(http.StatusBadRequest, scimTypeInvalidValue, "startIndex must be an integer")
+		}
+
+		if startIndex < 1 {
+			startIndex = 1
+		}
+	}
+
+	count = scimMaxResults
+	if value := query.Get("count"); value != "" {
+		count, err = strconv.Atoi(value)
+		if err != nil {
+			return nil, 0, 0, newSCIMError(http.StatusBadRequest, scimTypeInvalidValue, "count must be an integer")
+		}
+
+		if count < 0 {
+			count = 0
+		} else if count > scimMaxResults {
+			count = scimMaxResults
+		}
+	}
+
+	return filter, startIndex, count, nil
+}
+
+func (a *API) scimURL(resourceType, id string) string {
+	return strings.TrimSuffix(a.config.API.ExternalURL, "/") + "/scim/v2/" + resourceType + "/" + id
+}
+
+// scimResourceID parses the ID in the URL, which is not found if it's not a
+// UUID.
+func scimResourceID(r *http.Request, resourceType string) (uuid.UUID, error) {
+	id, err := uuid.FromString(chi.URLParam(r, "scim_id"))
+	if err != nil {
+		return uuid.Nil, newSCIMError(http.StatusNotFound, "", "%s not found", resourceType)
+	}
+
+	return id, nil
+}
+
+// scimIdentity returns the identity of the user for the SSO provider, whose
+// provider ID is the SCIM userName.
+func scimIdentity(ssoProvider *models.SSOProvider, user *models.User) *models.Identity {
+	for i := range user.Identities {
+		if user.Identities[i].Provider == ssoProvider.SCIMProviderType() {
+			return &user.Identities[i]
+		}
+	}
+
+	return nil
+}
+
+func (a *API) scimUserResource(tx *storage.Connection, ssoProvider *models.SSOProvider, user *models.User) (*SCIMUser, error) {
+	active := !user.IsBanned()
+
+	resource := &SCIMUser{
+		Schemas: []string{scimSchemaUser},
+		ID:      user.ID.String(),
+		Active:  &active,
+		Meta: &SCIMMeta{
+			ResourceType: "User",
+			Created:      user.CreatedAt,
+			LastModified: user.UpdatedAt,
+			Location:     a.scimURL("Users", user.ID.String()),
+		},
+	}
+
+	if identity := scimIdentity(ssoProvider, user); identity != nil {
+		resource.UserName = identity.ProviderID
+	}
+
+	if externalID, ok := user.AppMetaData[models.SCIMExternalIDKey].(string); ok {
+		resource.ExternalID = externalID
+	}
+
+	name := &SCIMName{}
+	name.Formatted, _ = user.UserMetaData["name"].(string)
+	name.GivenName, _ = user.UserMetaData["given_name"].(string)
+	name.FamilyName, _ = user.UserMetaData["family_name"].(string)
+	if *name != (SCIMName{}) {
+		resource.Name = name
+	}
+
+	if email := user.GetEmail(); email != "" {
+		resource.Emails = []SCIMEmail{{
+			Value:   email,
+			Type:    "work",
+			Primary: true,
+		}}
+	}
+
+	groups, err := models.FindSCIMGroupsForUser(tx, ssoProvider.ID, user.ID)
+	if err != nil {
+		return nil, apierrors.NewInternalServerError("Database error finding SCIM groups").WithInternalError(err)
+	}
+
+	for _, group := range groups {
+		resource.Groups = append(resource.Groups, SCIMReference{
+			Value:   group.ID.String(),
+			Display: group.DisplayName,
+			Ref:     a.scimURL("Groups", group.ID.String()),
+		})
+	}
+
+	return resource, nil
+}
+
+func (a *API) scimGroupResource(tx *storage.Connection, group *models.SCIMGroup) (*SCIMGroup, error) {
+	resource := &SCIMGroup{
+		Schemas:     []string{scimSchemaGroup},
+		ID:          group.ID.String(),
+		DisplayName: group.DisplayName,
+		Members:     []SCIMReference{},
+		Meta: &SCIMMeta{
+			ResourceType: "Group",
+			Created:      group.CreatedAt,
+			LastModified: group.UpdatedAt,
+			Location:     a.scimURL("Groups", group.ID.String()),
+		},
+	}
+
+	if group.ExternalID != nil {
+		resource.ExternalID = *group.ExternalID
+	}
+
+	memberIDs, err := group.MemberIDs(tx)
+	if err != nil {
+		return nil, apierrors.NewInternalServerError("Database error finding SCIM group members").WithInternalError(err)
+	}
+
+	for _, id := range memberIDs {
+		resource.Members = append(resource.Members, SCIMReference{
+			Value: id.String(),
+			Ref:   a.scimURL("Users", id.String()),
+		})
+	}
+
+	return resource, nil
+}
+
+// scimAuditLogEntry records the change of a user by the SSO provider's SCIM
+// client, as the user.
+func scimAuditLogEntry(r *http.Request, tx *storage.Connection, ssoProvider *models.SSOProvider, user *models.User, action models.AuditAction) error {
+	if err := models.NewAuditLogEntry(r, tx, user, action, "", map[string]interface{}{
+		"provider":        "scim",
+		"sso_provider_id": ssoProvider.ID,
+		"user_id":         user.ID,
+		"user_email":      user.Email,
+	}); err != nil {
+		return apierrors.NewInternalServerError("Error recording audit log entry").WithInternalError(err)
+	}
+
+	return nil
+}
+
+// scimSetUserActive bans deactivated users and revokes their sessions, or
+// lifts the ban of activated users if it was set by deactivating them. Bans
+// set otherwise, e.g. by an admin, are kept.
+func scimSetUserActive(tx *storage.Connection, user *models.User, active bool) error {
+	if active {
+		if deactivated, _ := user.AppMetaData[models.SCIMDeactivatedKey].(bool); !deactivated {
+			return nil
+		}
+
+		if err := user.Ban(tx, 0); err != nil {
+			return apierrors.NewInternalServerError("Database error activating user").WithInternalError(err)
+		}
+
+		if err := user.UpdateAppMetaData(tx, map[string]interface{}{models.SCIMDeactivatedKey: nil}); err != nil {
+			return apierrors.NewInternalServerError("Database error activating user").WithInternalError(err)
+		}
+
+		return nil
+	}
+
+	if err := user.Ban(tx, scimDeactivatedBanDuration); err != nil {
+		return apierrors.NewInternalServerError("Database error deactivating user").WithInternalError(err)
+	}
+
+	if err := user.UpdateAppMetaData(tx, map[string]interface{}{models.SCIMDeactivatedKey: true}); err != nil {
+		return apierrors.NewInternalServerError("Database error deactivating user").WithInternalError(err)
+	}
+
+	if err := models.Logout(tx, user.ID); err != nil {
+		return apierrors.NewInternalServerError("Error deleting user's sessions").WithInternalError(err)
+	}
+
+	return nil
+}
+
+// scimCheckUserNameAvailable returns a uniqueness error if another user of
+// the SSO provider has the userName.
+func scimCheckUserNameAvailable(tx *storage.Connection, ssoProvider *models.SSOProvider, userName string, userID uuid.UUID) error {
+	users, _, err := models.FindSCIMUsers(tx, ssoProvider, &models.SCIMFilter{
+		Attribute: models.SCIMFilterUserName,
+		Value:     userName,
+	}, 0, scimMaxResults)
+	if err != nil {
+		return apierrors.NewInternalServerError("Database error finding SCIM users").WithInternalError(err)
+	}
+
+	for _, user := range users {
+		if user.ID != userID {
+			return newSCIMError(http.StatusConflict, scimTypeUniqueness, "A user with userName %q already exists", userName)
+		}
+	}
+
+	return nil
+}
+
+func (a *API) scimUsersList(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	ssoProvider := getSSOProvider(ctx)
+
+	filter, startIndex, count, err := scimListParams(r, models.SCIMFilterUserName, models.SCIMFilterExternalID, models.SCIMFilterEmail)
+	if err != nil {
+		return err
+	}
+
+	users, total, err := models.FindSCIMUsers(db, ssoProvider, filter, startIndex-1, count)
+	if err != nil {
+		return apierrors.NewInternalServerError("Database error finding SCIM users").WithInternalError(err)
+	}
+
+	resources := make([]interface{}, 0, len(users))
+	for _, user := range users {
+		resource, err := a.scimUserResource(db, ssoProvider, user)
+		if err != nil {
+			return err
+		}
+
+		resources = append(resources, resource)
+	}
+
+	return sendSCIMJSON(w, http.StatusOK, &scimListResponse{
+		Schemas:      []string{scimSchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+func (a *API) scimLoadUser(tx *storage.Connection, r *http.Request) (*models.User, error) {
+	id, err := scimResourceID(r, "User")
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := models.FindSCIMUserByID(tx, getSSOProvider(r.Context()), id)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return nil, newSCIMError(http.StatusNotFound, "", "User not found")
+		}
+
+		return nil, apierrors.NewInternalServerError("Database error finding SCIM user").WithInternalError(err)
+	}
+
+	return user, nil
+}
+
+func (a *API) scimUsersGet(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	user, err := a.scimLoadUser(db, r)
+	if err != nil {
+		return err
+	}
+
+	resource, err := a.scimUserResource(db, getSSOProvider(ctx), user)
+	if err != nil {
+		return err
+	}
+
+	return sendSCIMJSON(w, http.StatusOK, resource)
+}
+
+// scimUsersCreate provisions an SSO user for the SSO provider, with an
+// identity whose provider ID is the userName. Signing in with the SSO
+// provider links to the user if the NameID or subject is the userName.
+func (a *API) scimUsersCreate(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	ssoProvider := getSSOProvider(ctx)
+
+	params := &SCIMUser{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	if err := a.validateSCIMUser(params); err != nil {
+		return err
+	}
+
+	providerType := ssoProvider.SCIMProviderType()
+	email := params.email()
+
+	var user *models.User
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		if terr := scimCheckUserNameAvailable(tx, ssoProvider, params.UserName, uuid.Nil); terr != nil {
+			return terr
+		}
+
+		userMetaData := params.userMetaData()
+		for key, value := range userMetaData {
+			if value == nil {
+				delete(userMetaData, key)
+			}
+		}
+
+		signupParams := SignupParams{
+			Provider: providerType,
+			Email:    email,
+			Aud:      a.requestAud(ctx, r),
+			Data:     userMetaData,
+		}
+
+		var terr error
+		user, terr = signupParams.ToUserModel(true /* <- isSSOUser */)
+		if terr != nil {
+			return terr
+		}
+
+		if user, terr = a.signupNewUser(tx, user); terr != nil {
+			return terr
+		}
+
+		identity, terr := a.createNewIdentity(tx, user, providerType, map[string]interface{}{
+			"sub":            params.UserName,
+			"email":          email,
+			"email_verified": true,
+		})
+		if terr != nil {
+			return terr
+		}
+		user.Identities = []models.Identity{*identity}
+
+		if terr := user.Confirm(tx); terr != nil {
+			return apierrors.NewInternalServerError("Database error confirming user").WithInternalError(terr)
+		}
+
+		if params.ExternalID != "" {
+			if terr := user.UpdateAppMetaData(tx, map[string]interface{}{
+				models.SCIMExternalIDKey: params.ExternalID,
+			}); terr != nil {
+				return apierrors.NewInternalServerError("Database error updating user").WithInternalError(terr)
+			}
+		}
+
+		if params.Active != nil && !*params.Active {
+			if terr := scimSetUserActive(tx, user, false); terr != nil {
+				return terr
+			}
+		}
+
+		return scimAuditLogEntry(r, tx, ssoProvider, user, models.UserSignedUpAction)
+	}); err != nil {
+		return err
+	}
+
+	resource, err := a.scimUserResource(db, ssoProvider, user)
+	if err != nil {
+		return err
+	}
+
+	return sendSCIMJSON(w, http.StatusCreated, resource)
+}
+
+// scimUpdateUser applies the changes from the user's current resource to the
+// updated resource.
+func (a *API) scimUpdateUser(r *http.Request, tx *storage.Connection, ssoProvider *models.SSOProvider, user *models.User, current, updated *SCIMUser) error {
+	if err := a.validateSCIMUser(updated); err != nil {
+		return err
+	}
+
+	identity := scimIdentity(ssoProvider, user)
+	if identity == nil {
+		return apierrors.NewInternalServerError("SCIM user has no identity for the SSO provider")
+	}
+
+	if updated.UserName != current.UserName {
+		if err := scimCheckUserNameAvailable(tx, ssoProvider, updated.UserName, user.ID); err != nil {
+			return err
+		}
+
+		if err := tx.RawQuery(
+			"update "+identity.TableName()+" set provider_id = ?, updated_at = now() where id = ?",
+			updated.UserName, identity.ID,
+		).Exec(); err != nil {
+			return apierrors.NewInternalServerError("Database error updating identity").WithInternalError(err)
+		}
+
+		identity.ProviderID = updated.UserName
+		if err := identity.UpdateIdentityData(tx, map[string]interface{}{
+			"sub": updated.UserName,
+		}); err != nil {
+			return apierrors.NewInternalServerError("Database error updating identity").WithInternalError(err)
+		}
+	}
+
+	if email := updated.email(); email != user.GetEmail() {
+		if err := user.SetEmail(tx, email); err != nil {
+			return apierrors.NewInternalServerError("Database error updating user").WithInternalError(err)
+		}
+
+		if err := identity.UpdateIdentityData(tx, map[string]interface{}{
+			"email": email,
+		}); err != nil {
+			return apierrors.NewInternalServerError("Database error updating identity").WithInternalError(err)
+		}
+	}
+
+	if err := user.UpdateUserMetaData(tx, updated.userMetaData()); err != nil {
+		return apierrors.NewInternalServerError("Database error updating user").WithInternalError(err)
+	}
+
+	if updated.ExternalID != current.ExternalID {
+		var externalID interface{}
+		if updated.ExternalID != "" {
+			externalID = updated.ExternalID
+		}
+
+		if err := user.UpdateAppMetaData(tx, map[string]interface{}{
+			models.SCIMExternalIDKey: externalID,
+		}); err != nil {
+			return apierrors.NewInternalServerError("Database error updating user").WithInternalError(err)
+		}
+	}
+
+	if updated.Active != nil && *updated.Active != *current.Active {
+		if err := scimSetUserActive(tx, user, *updated.Active); err != nil {
+			return err
+		}
+	}
+
+	return scimAuditLogEntry(r, tx, ssoProvider, user, models.UserModifiedAction)
+}
+
+// scimUsersReplace replaces the attributes of a user. The user's active
+// status is kept if it's not set.
+func (a *API) scimUsersReplace(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	ssoProvider := getSSOProvider(ctx)
+
+	params := &SCIMUser{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	var user *models.User
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		if user, terr = a.scimLoadUser(tx, r); terr != nil {
+			return terr
+		}
+
+		current, terr := a.scimUserResource(tx, ssoProvider, user)
+		if terr != nil {
+			return terr
+		}
+
+		return a.scimUpdateUser(r, tx, ssoProvider, user, current, params)
+	}); err != nil {
+		return err
+	}
+
+	resource, err := a.scimUserResource(db, ssoProvider, user)
+	if err != nil {
+		return err
+	}
+
+	return sendSCIMJSON(w, http.StatusOK, resource)
+}
+
+func (a *API) scimUsersPatch(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	ssoProvider := getSSOProvider(ctx)
+
+	params := &SCIMPatchParams{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	var user *models.User
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		if user, terr = a.scimLoadUser(tx, r); terr != nil {
+			return terr
+		}
+
+		current, terr := a.scimUserResource(tx, ssoProvider, user)
+		if terr != nil {
+			return terr
+		}
+
+		updated := *current
+		if current.Name != nil {
+			name := *current.Name
+			updated.Name = &name
+		}
+
+		if terr := applySCIMUserPatch(&updated, params.Operations); terr != nil {
+			return terr
+		}
+
+		return a.scimUpdateUser(r, tx, ssoProvider, user, current, &updated)
+	}); err != nil {
+		return err
+	}
+
+	resource, err := a.scimUserResource(db, ssoProvider, user)
+	if err != nil {
+		return err
+	}
+
+	return sendSCIMJSON(w, http.StatusOK, resource)
+}
+
+// scimUsersDelete soft-deletes a user and revokes the user's sessions.
+func (a *API) scimUsersDelete(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	ssoProvider := getSSOProvider(ctx)
+
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		user, terr := a.scimLoadUser(tx, r)
+		if terr != nil {
+			return terr
+		}
+
+		if terr := scimAuditLogEntry(r, tx, ssoProvider, user, models.UserDeletedAction); terr != nil {
+			return terr
+		}
+
+		if terr := models.RemoveSCIMGroupMemberships(tx, user.ID); terr != nil {
+			return apierrors.NewInternalServerError("Error removing user from SCIM groups").WithInternalError(terr)
+		}
+
+		if terr := user.SoftDeleteUser(tx); terr != nil {
+			return apierrors.NewInternalServerError("Error soft deleting user").WithInternalError(terr)
+		}
+
+		if terr := user.SoftDeleteUserIdentities(tx); terr != nil {
+			return apierrors.NewInternalServerError("Error soft deleting user identities").WithInternalError(terr)
+		}
+
+		if terr := models.DeleteFactorsByUserId(tx, user.ID); terr != nil {
+			return apierrors.NewInternalServerError("Error deleting user's factors").WithInternalError(terr)
+		}
+
+		if terr := models.Logout(tx, user.ID); terr != nil {
+			return apierrors.NewInternalServerError("Error deleting user's sessions").WithInternalError(terr)
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (a *API) scimGroupsList(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	ssoProvider := getSSOProvider(ctx)
+
+	filter, startIndex, count, err := scimListParams(r, models.SCIMFilterDisplayName, models.SCIMFilterExternalID)
+	if err != nil {
+		return err
+	}
+
+	groups, total, err := models.FindSCIMGroups(db, ssoProvider.ID, filter, startIndex-1, count)
+	if err != nil {
+		return apierrors.NewInternalServerError("Database error finding SCIM groups").WithInternalError(err)
+	}
+
+	resources := make([]interface{}, 0, len(groups))
+	for _, group := range groups {
+		resource, err := a.scimGroupResource(db, group)
+		if err != nil {
+			return err
+		}
+
+		resources = append(resources, resource)
+	}
+
+	return sendSCIMJSON(w, http.StatusOK, &scimListResponse{
+		Schemas:      []string{scimSchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+func (a *API) scimLoadGroup(tx *storage.Connection, r *http.Request) (*models.SCIMGroup, error) {
+	id, err := scimResourceID(r, "Group")
+	if err != nil {
+		return nil, err
+	}
+
+	group, err := models.FindSCIMGroupByID(tx, getSSOProvider(r.Context()).ID, id)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return nil, newSCIMError(http.StatusNotFound, "", "Group not found")
+		}
+
+		return nil, apierrors.NewInternalServerError("Database error finding SCIM group").WithInternalError(err)
+	}
+
+	return group, nil
+}
+
+func (a *API) scimGroupsGet(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	group, err := a.scimLoadGroup(db, r)
+	if err != nil {
+		return err
+	}
+
+	resource, err := a.scimGroupResource(db, group)
+	if err != nil {
+		return err
+	}
+
+	return sendSCIMJSON(w, http.StatusOK, resource)
+}
+
+// scimSaveGroup creates or updates the group from the resource, replacing
+// its members. Members must be users of the SSO provider.
+func (a *API) scimSaveGroup(tx *storage.Connection, ssoProvider *models.SSOProvider, group *models.SCIMGroup, resource *SCIMGroup, create bool) error {
+	if err := resource.validate(); err != nil {
+		return err
+	}
+
+	groups, _, err := models.FindSCIMGroups(tx, ssoProvider.ID, &models.SCIMFilter{
+		Attribute: models.SCIMFilterDisplayName,
+		Value:     resource.DisplayName,
+	}, 0, scimMaxResults)
+	if err != nil {
+		return apierrors.NewInternalServerError("Database error finding SCIM groups").WithInternalError(err)
+	}
+
+	for _, other := range groups {
+		if other.ID != group.ID {
+			return newSCIMError(http.StatusConflict, scimTypeUniqueness, "A group with displayName %q already exists", resource.DisplayName)
+		}
+	}
+
+	memberIDs := make([]uuid.UUID, 0, len(resource.Members))
+	for _, member := range resource.Members {
+		id, err := uuid.FromString(member.Value)
+		if err != nil {
+			return newSCIMError(http.StatusBadRequest, scimTypeInvalidValue, "Member %q is not a user of the SSO provider", member.Value)
+		}
+
+		if _, err := models.FindSCIMUserByID(tx, ssoProvider, id); err != nil {
+			if models.IsNotFoundError(err) {
+				return newSCIMError(http.StatusBadRequest, scimTypeInvalidValue, "Member %q is not a user of the SSO provider", member.Value)
+			}
+
+			return apierrors.NewInternalServerError("Database error finding SCIM user").WithInternalError(err)
+		}
+
+		memberIDs = append(memberIDs, id)
+	}
+
+	group.DisplayName = resource.DisplayName
+	group.ExternalID = nil
+	if resource.ExternalID != "" {
+		externalID := resource.ExternalID
+		group.ExternalID = &externalID
+	}
+
+	if create {
+		err = tx.Create(group)
+	} else {
+		err = tx.Update(group)
+	}
+	if err != nil {
+		return apierrors.NewInternalServerError("Database error saving SCIM group").WithInternalError(err)
+	}
+
+	if err := group.ReplaceMembers(tx, memberIDs); err != nil {
+		return apierrors.NewInternalServerError("Database error saving SCIM group members").WithInternalError(err)
+	}
+
+	return nil
+}
+
+func (a *API) scimGroupsCreate(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	ssoProvider := getSSOProvider(ctx)
+
+	params := &SCIMGroup{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	group := models.NewSCIMGroup(ssoProvider.ID, params.DisplayName, nil)
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		return a.scimSaveGroup(tx, ssoProvider, group, params, true)
+	}); err != nil {
+		return err
+	}
+
+	resource, err := a.scimGroupResource(db, group)
+	if err != nil {
+		return err
+	}
+
+	return sendSCIMJSON(w, http.StatusCreated, resource)
+}
+
+func (a *API) scimGroupsReplace(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	ssoProvider := getSSOProvider(ctx)
+
+	params := &SCIMGroup{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	var group *models.SCIMGroup
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		if group, terr = a.scimLoadGroup(tx, r); terr != nil {
+			return terr
+		}
+
+		return a.scimSaveGroup(tx, ssoProvider, group, params, false)
+	}); err != nil {
+		return err
+	}
+
+	resource, err := a.scimGroupResource(db, group)
+	if err != nil {
+		return err
+	}
+
+	return sendSCIMJSON(w, http.StatusOK, resource)
+}
+
+func (a *API) scimGroupsPatch(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	ssoProvider := getSSOProvider(ctx)
+
+	params := &SCIMPatchParams{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	var group *models.SCIMGroup
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		if group, terr = a.scimLoadGroup(tx, r); terr != nil {
+			return terr
+		}
+
+		resource, terr := a.scimGroupResource(tx, group)
+		if terr != nil {
+			return terr
+		}
+
+		if terr := applySCIMGroupPatch(resource, params.Operations); terr != nil {
+			return terr
+		}
+
+		return a.scimSaveGroup(tx, ssoProvider, group, resource, false)
+	}); err != nil {
+		return err
+	}
+
+	resource, err := a.scimGroupResource(db, group)
+	if err != nil {
+		return err
+	}
+
+	return sendSCIMJSON(w, http.StatusOK, resource)
+}
+
+func (a *API) scimGroupsDelete(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		group, terr := a.scimLoadGroup(tx, r)
+		if terr != nil {
+			return terr
+		}
+
+		if terr := tx.Destroy(group); terr != nil {
+			return apierrors.NewInternalServerError("Database error deleting SCIM group").WithInternalError(terr)
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (a *API) scimServiceProviderConfig(w http.ResponseWriter, r *http.Request) error {
+	supported := func(value bool) map[string]interface{} {
+		return map[string]interface{}{"supported": value}
+	}
+
+	return sendSCIMJSON(w, http.StatusOK, map[string]interface{}{
+		"schemas": []string{scimSchemaServiceProviderConfig},
+		"patch":   supported(true),
+		"bulk": map[string]interface{}{
+			"supported":      false,
+			"maxOperations":  0,
+			"maxPayloadSize": 0,
+		},
+		"filter": map[string]interface{}{
+			"supported":  true,
+			"maxResults": scimMaxResults,
+		},
+		"changePassword": supported(false),
+		"sort":           supported(false),
+		"etag":           supported(false),
+		"authenticationSchemes": []map[string]interface{}{{
+			"type":        "oauthbearertoken",
+			"name":        "OAuth Bearer Token",
+			"description": "Authentication with the SCIM token of the SSO provider",
+			"primary":     true,
+		}},
+	})
+}
@@ -0,0 +1,529 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/models"
+)
+
+type SCIMTestSuite struct {
+	suite.Suite
+	API      *API
+	Config   *conf.GlobalConfiguration
+	AdminJWT string
+
+	ProviderID string
+	SCIMToken  string
+}
+
+func TestSCIM(t *testing.T) {
+	api, config, err := setupAPIForTest()
+	require.NoError(t, err)
+
+	ts := &SCIMTestSuite{
+		API:    api,
+		Config: config,
+	}
+	defer api.db.Close()
+
+	if config.SAML.Enabled {
+		suite.Run(t, ts)
+	}
+}
+
+func (ts *SCIMTestSuite) SetupTest() {
+	models.TruncateAll(ts.API.db)
+
+	claims := &AccessTokenClaims{
+		Role: "supabase_admin",
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(ts.Config.JWT.Secret))
+	require.NoError(ts.T(), err, "Error generating admin jwt")
+
+	ts.AdminJWT = token
+
+	w := ts.adminRequest(http.MethodPost, "http://localhost/admin/sso/providers", map[string]interface{}{
+		"type":         "saml",
+		"metadata_xml": validSAMLIDPMetadata("https://example.com/scim-idp"),
+	})
+	require.Equal(ts.T(), http.StatusCreated, w.Code)
+
+	var provider struct {
+		ID string `json:"id"`
+	}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&provider))
+	ts.ProviderID = provider.ID
+
+	w = ts.adminRequest(http.MethodPost, "http://localhost/admin/sso/providers/"+ts.ProviderID+"/scim_token", nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var result struct {
+		Token   string `json:"token"`
+		SCIMURL string `json:"scim_url"`
+	}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&result))
+	require.NotEmpty(ts.T(), result.Token)
+	require.Equal(ts.T(), ts.Config.API.ExternalURL+"/scim/v2", result.SCIMURL)
+
+	ts.SCIMToken = result.Token
+}
+
+func (ts *SCIMTestSuite) adminRequest(method, url string, body interface{}) *httptest.ResponseRecorder {
+	var buffer bytes.Buffer
+	if body != nil {
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(body))
+	}
+
+	req := httptest.NewRequest(method, url, &buffer)
+	req.Header.Set("Authorization", "Bearer "+ts.AdminJWT)
+	w := httptest.NewRecorder()
+
+	ts.API.handler.ServeHTTP(w, req)
+
+	return w
+}
+
+func (ts *SCIMTestSuite) scimRequest(method, path string, body interface{}) *httptest.ResponseRecorder {
+	var buffer bytes.Buffer
+	if body != nil {
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(body))
+	}
+
+	req := httptest.NewRequest(method, "http://localhost/scim/v2"+path, &buffer)
+	req.Header.Set("Authorization", "Bearer "+ts.SCIMToken)
+	req.Header.Set("Content-Type", scimContentType)
+	w := httptest.NewRecorder()
+
+	ts.API.handler.ServeHTTP(w, req)
+
+	return w
+}
+
+func (ts *SCIMTestSuite) createUser(userName, email string) *SCIMUser {
+	w := ts.scimRequest(http.MethodPost, "/Users", map[string]interface{}{
+		"schemas":    []string{scimSchemaUser},
+		"userName":   userName,
+		"externalId": "ext-" + userName,
+		"name": map[string]interface{}{
+			"givenName":  "Some",
+			"familyName": "One",
+		},
+		"emails": []map[string]interface{}{{
+			"value":   email,
+			"primary": true,
+		}},
+		"active": true,
+	})
+	require.Equal(ts.T(), http.StatusCreated, w.Code, w.Body.String())
+	require.Equal(ts.T(), scimContentType, w.Header().Get("Content-Type"))
+
+	var user SCIMUser
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&user))
+
+	return &user
+}
+
+func (ts *SCIMTestSuite) TestSCIMInvalidToken() {
+	ts.SCIMToken = "invalid"
+
+	w := ts.scimRequest(http.MethodGet, "/Users", nil)
+	require.Equal(ts.T(), http.StatusUnauthorized, w.Code)
+
+	var result map[string]interface{}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&result))
+	require.Equal(ts.T(), []interface{}{scimSchemaError}, result["schemas"])
+	require.Equal(ts.T(), "401", result["status"])
+}
+
+func (ts *SCIMTestSuite) TestSCIMTokenDeleted() {
+	w := ts.adminRequest(http.MethodDelete, "http://localhost/admin/sso/providers/"+ts.ProviderID+"/scim_token", nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var provider map[string]interface{}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&provider))
+	require.Equal(ts.T(), false, provider["scim_enabled"])
+
+	w = ts.scimRequest(http.MethodGet, "/Users", nil)
+	require.Equal(ts.T(), http.StatusUnauthorized, w.Code)
+}
+
+func (ts *SCIMTestSuite) TestSCIMUsers() {
+	user := ts.createUser("someone@example.com", "someone@example.com")
+	require.Equal(ts.T(), "someone@example.com", user.UserName)
+	require.Equal(ts.T(), "ext-someone@example.com", user.ExternalID)
+	require.Equal(ts.T(), "Some", user.Name.GivenName)
+	require.True(ts.T(), *user.Active)
+
+	// users can only be provisioned once
+	w := ts.scimRequest(http.MethodPost, "/Users", map[string]interface{}{
+		"userName": "SOMEONE@example.com",
+		"emails":   []map[string]interface{}{{"value": "someone@example.com"}},
+	})
+	require.Equal(ts.T(), http.StatusConflict, w.Code)
+
+	dbUser, err := models.FindUserByID(ts.API.db, uuid.FromStringOrNil(user.ID))
+	require.NoError(ts.T(), err)
+	require.True(ts.T(), dbUser.IsSSOUser)
+	require.True(ts.T(), dbUser.IsConfirmed())
+
+	identity, err := models.FindIdentityByIdAndProvider(ts.API.db, "someone@example.com", "sso:"+ts.ProviderID)
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), dbUser.ID, identity.UserID)
+
+	for _, filter := range []string{
+		`userName eq "Someone@Example.com"`,
+		`externalId eq "ext-someone@example.com"`,
+		`emails.value eq "someone@example.com"`,
+	} {
+		w = ts.scimRequest(http.MethodGet, "/Users?filter="+url.QueryEscape(filter), nil)
+		require.Equal(ts.T(), http.StatusOK, w.Code)
+
+		var list struct {
+			TotalResults int        `json:"totalResults"`
+			Resources    []SCIMUser `json:"Resources"`
+		}
+		require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&list))
+		require.Equal(ts.T(), 1, list.TotalResults, filter)
+		require.Equal(ts.T(), user.ID, list.Resources[0].ID)
+	}
+
+	w = ts.scimRequest(http.MethodGet, "/Users?filter="+url.QueryEscape(`userName sw "some"`), nil)
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+
+	// deactivating a user bans the user and revokes the user's sessions
+	session, err := models.NewSession(dbUser.ID, nil)
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), ts.API.db.Create(session))
+
+	w = ts.scimRequest(http.MethodPatch, "/Users/"+user.ID, map[string]interface{}{
+		"schemas": []string{"urn:ietf:params:scim:api:messages:2.0:PatchOp"},
+		"Operations": []map[string]interface{}{
+			{
+				"op":    "Replace",
+				"path":  "active",
+				"value": "False",
+			},
+			{
+				"op":    "replace",
+				"path":  "name.familyName",
+				"value": "Two",
+			},
+		},
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+
+	var patched SCIMUser
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&patched))
+	require.False(ts.T(), *patched.Active)
+	require.Equal(ts.T(), "Two", patched.Name.FamilyName)
+
+	dbUser, err = models.FindUserByID(ts.API.db, dbUser.ID)
+	require.NoError(ts.T(), err)
+	require.True(ts.T(), dbUser.IsBanned())
+
+	_, err = models.FindSessionByID(ts.API.db, session.ID, false)
+	require.True(ts.T(), models.IsNotFoundError(err))
+
+	// replacing the user activates it again and changes the userName
+	w = ts.scimRequest(http.MethodPut, "/Users/"+user.ID, map[string]interface{}{
+		"userName": "someone.else@example.com",
+		"emails":   []map[string]interface{}{{"value": "someone.else@example.com"}},
+		"active":   true,
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+
+	var replaced SCIMUser
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&replaced))
+	require.True(ts.T(), *replaced.Active)
+	require.Equal(ts.T(), "someone.else@example.com", replaced.UserName)
+	require.Equal(ts.T(), "someone.else@example.com", replaced.Emails[0].Value)
+	require.Empty(ts.T(), replaced.ExternalID)
+	require.Nil(ts.T(), replaced.Name)
+
+	dbUser, err = models.FindUserByID(ts.API.db, dbUser.ID)
+	require.NoError(ts.T(), err)
+	require.NotContains(ts.T(), dbUser.AppMetaData, models.SCIMDeactivatedKey)
+
+	// activating the user doesn't lift bans not set by SCIM
+	require.NoError(ts.T(), dbUser.Ban(ts.API.db, time.Hour))
+
+	w = ts.scimRequest(http.MethodPatch, "/Users/"+user.ID, map[string]interface{}{
+		"schemas": []string{"urn:ietf:params:scim:api:messages:2.0:PatchOp"},
+		"Operations": []map[string]interface{}{
+			{
+				"op":    "replace",
+				"path":  "active",
+				"value": true,
+			},
+		},
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&patched))
+	require.False(ts.T(), *patched.Active)
+
+	dbUser, err = models.FindUserByID(ts.API.db, dbUser.ID)
+	require.NoError(ts.T(), err)
+	require.True(ts.T(), dbUser.IsBanned())
+
+	// deleting the user soft-deletes it
+	w = ts.scimRequest(http.MethodDelete, "/Users/"+user.ID, nil)
+	require.Equal(ts.T(), http.StatusNoContent, w.Code)
+
+	dbUser, err = models.FindUserByID(ts.API.db, dbUser.ID)
+	require.NoError(ts.T(), err)
+	require.NotNil(ts.T(), dbUser.DeletedAt)
+
+	w = ts.scimRequest(http.MethodGet, "/Users/"+user.ID, nil)
+	require.Equal(ts.T(), http.StatusNotFound, w.Code)
+}
+
+func (ts *SCIMTestSuite) TestSCIMGroups() {
+	first := ts.createUser("first@example.com", "first@example.com")
+	second := ts.createUser("second@example.com", "second@example.com")
+
+	w := ts.scimRequest(http.MethodPost, "/Groups", map[string]interface{}{
+		"schemas":     []string{scimSchemaGroup},
+		"displayName": "Engineering",
+		"members": []map[string]interface{}{
+			{"value": first.ID},
+		},
+	})
+	require.Equal(ts.T(), http.StatusCreated, w.Code, w.Body.String())
+
+	var group SCIMGroup
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&group))
+	require.Equal(ts.T(), "Engineering", group.DisplayName)
+	require.Len(ts.T(), group.Members, 1)
+
+	w = ts.scimRequest(http.MethodPost, "/Groups", map[string]interface{}{
+		"displayName": "engineering",
+	})
+	require.Equal(ts.T(), http.StatusConflict, w.Code)
+
+	w = ts.scimRequest(http.MethodPost, "/Groups", map[string]interface{}{
+		"displayName": "Other",
+		"members": []map[string]interface{}{
+			{"value": "00000000-0000-0000-0000-000000000000"},
+		},
+	})
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+
+	w = ts.scimRequest(http.MethodPatch, "/Groups/"+group.ID, map[string]interface{}{
+		"Operations": []map[string]interface{}{
+			{
+				"op":    "add",
+				"path":  "members",
+				"value": []map[string]interface{}{{"value": second.ID}},
+			},
+			{
+				"op":   "remove",
+				"path": fmt.Sprintf("members[value eq %q]", first.ID),
+			},
+		},
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&group))
+	require.Len(ts.T(), group.Members, 1)
+	require.Equal(ts.T(), second.ID, group.Members[0].Value)
+
+	w = ts.scimRequest(http.MethodGet, "/Users/"+second.ID, nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var user SCIMUser
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&user))
+	require.Len(ts.T(), user.Groups, 1)
+	require.Equal(ts.T(), "Engineering", user.Groups[0].Display)
+
+	w = ts.scimRequest(http.MethodGet, "/Groups?filter="+url.QueryEscape(`displayName eq "engineering"`), nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var list struct {
+		TotalResults int `json:"totalResults"`
+	}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&list))
+	require.Equal(ts.T(), 1, list.TotalResults)
+
+	w = ts.scimRequest(http.MethodDelete, "/Groups/"+group.ID, nil)
+	require.Equal(ts.T(), http.StatusNoContent, w.Code)
+
+	w = ts.scimRequest(http.MethodGet, "/Groups/"+group.ID, nil)
+	require.Equal(ts.T(), http.StatusNotFound, w.Code)
+}
+
+func TestParseSCIMFilter(t *testing.T) {
+	examples := []struct {
+		Filter    string
+		Attribute string
+		Value     string
+		Error     bool
+	}{
+		{
+			Filter: "",
+		},
+		{
+			Filter:    `userName eq "someone@example.com"`,
+			Attribute: models.SCIMFilterUserName,
+			Value:     "someone@example.com",
+		},
+		{
+			Filter:    `  externalId EQ "a \"quoted\" id"  `,
+			Attribute: models.SCIMFilterExternalID,
+			Value:     `a "quoted" id`,
+		},
+		{
+			Filter:    `emails eq "someone@example.com"`,
+			Attribute: models.SCIMFilterEmail,
+			Value:     "someone@example.com",
+		},
+		{
+			Filter:    `urn:ietf:params:scim:schemas:core:2.0:User:userName eq "someone"`,
+			Attribute: models.SCIMFilterUserName,
+			Value:     "someone",
+		},
+		{
+			Filter: `userName sw "some"`,
+			Error:  true,
+		},
+		{
+			Filter: `userName eq "a" and externalId eq "b"`,
+			Error:  true,
+		},
+		{
+			Filter: `userName eq someone`,
+			Error:  true,
+		},
+	}
+
+	for _, example := range examples {
+		filter, err := parseSCIMFilter(example.Filter)
+		if example.Error {
+			require.Error(t, err, example.Filter)
+			require.Equal(t, scimTypeInvalidFilter, err.(*scimError).SCIMType)
+			continue
+		}
+
+		require.NoError(t, err, example.Filter)
+
+		if example.Attribute == "" {
+			require.Nil(t, filter)
+		} else {
+			require.Equal(t, &models.SCIMFilter{
+				Attribute: example.Attribute,
+				Value:     example.Value,
+			}, filter)
+		}
+	}
+}
+
+func TestApplySCIMUserPatch(t *testing.T) {
+	active := true
+	user := &SCIMUser{
+		UserName:   "someone@example.com",
+		ExternalID: "ext",
+		Name: &SCIMName{
+			GivenName: "Some",
+		},
+		Emails: []SCIMEmail{{Value: "someone@example.com", Primary: true}},
+		Active: &active,
+	}
+
+	require.NoError(t, applySCIMUserPatch(user, []SCIMPatchOperation{
+		{
+			Op:    "Replace",
+			Value: json.RawMessage(`{"active": false, "name.familyName": "One", "title": "ignored"}`),
+		},
+		{
+			Op:    "replace",
+			Path:  `emails[type eq "work"].value`,
+			Value: json.RawMessage(`"other@example.com"`),
+		},
+		{
+			Op:   "remove",
+			Path: "externalId",
+		},
+	}))
+
+	require.False(t, *user.Active)
+	require.Equal(t, &SCIMName{GivenName: "Some", FamilyName: "One"}, user.Name)
+	require.Equal(t, "other@example.com", user.email())
+	require.Empty(t, user.ExternalID)
+
+	err := applySCIMUserPatch(user, []SCIMPatchOperation{{Op: "remove", Path: "userName"}})
+	require.Error(t, err)
+	require.Equal(t, scimTypeMutability, err.(*scimError).SCIMType)
+
+	err = applySCIMUserPatch(user, []SCIMPatchOperation{{Op: "remove"}})
+	require.Error(t, err)
+	require.Equal(t, scimTypeNoTarget, err.(*scimError).SCIMType)
+
+	err = applySCIMUserPatch(user, []SCIMPatchOperation{{Op: "move", Path: "userName"}})
+	require.Error(t, err)
+
+	err = applySCIMUserPatch(user, []SCIMPatchOperation{{Op: "replace", Path: "active", Value: json.RawMessage(`"maybe"`)}})
+	require.Error(t, err)
+	require.Equal(t, scimTypeInvalidValue, err.(*scimError).SCIMType)
+}
+
+func TestApplySCIMGroupPatch(t *testing.T) {
+	group := &SCIMGroup{
+		DisplayName: "Engineering",
+		Members: []SCIMReference{
+			{Value: "a"},
+			{Value: "b"},
+		},
+	}
+
+	require.NoError(t, applySCIMGroupPatch(group, []SCIMPatchOperation{
+		{
+			Op:    "add",
+			Path:  "members",
+			Value: json.RawMessage(`[{"value": "b"}, {"value": "c"}]`),
+		},
+		{
+			Op:   "remove",
+			Path: `members[value eq "a"]`,
+		},
+		{
+			Op:    "replace",
+			Value: json.RawMessage(`{"displayName": "R&D"}`),
+		},
+	}))
+
+	require.Equal(t, "R&D", group.DisplayName)
+	require.Equal(t, []SCIMReference{{Value: "b"}, {Value: "c"}}, group.Members)
+
+	require.NoError(t, applySCIMGroupPatch(group, []SCIMPatchOperation{
+		{
+			Op:    "replace",
+			Path:  "members",
+			Value: json.RawMessage(`[{"value": "d"}]`),
+		},
+	}))
+	require.Equal(t, []SCIMReference{{Value: "d"}}, group.Members)
+
+	require.NoError(t, applySCIMGroupPatch(group, []SCIMPatchOperation{
+		{
+			Op:   "remove",
+			Path: "members",
+		},
+	}))
+	require.Empty(t, group.Members)
+
+	err := applySCIMGroupPatch(group, []SCIMPatchOperation{{Op: "add", Path: `members[value eq "a"]`}})
+	require.Error(t, err)
+	require.Equal(t, scimTypeInvalidPath, err.(*scimError).SCIMType)
+}
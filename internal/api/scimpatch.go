@@ -0,0 +1,358 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var scimMembersFilterPathRegexp = regexp.MustCompile(`(?i)^members\[(.+)\]$`)
+
+// scimPatchOp returns the lower cased operation, as some SCIM clients send
+// them capitalized.
+func scimPatchOp(operation SCIMPatchOperation) (string, error) {
+	op := strings.ToLower(operation.Op)
+
+	switch op {
+	case "add", "replace", "remove":
+		return op, nil
+
+	default:
+		return "", newSCIMError(http.StatusBadRequest, scimTypeInvalidSyntax, "Unsupported patch operation %q", operation.Op)
+	}
+}
+
+// scimPatchPaths returns the paths and values of an operation. Operations
+// without a path set all of the attributes of the value.
+func scimPatchPaths(op string, operation SCIMPatchOperation) ([]string, map[string]json.RawMessage, error) {
+	if operation.Path != "" {
+		return []string{operation.Path}, map[string]json.RawMessage{operation.Path: operation.Value}, nil
+	}
+
+	if op == "remove" {
+		return nil, nil, newSCIMError(http.StatusBadRequest, scimTypeNoTarget, "Remove operations require a path")
+	}
+
+	values := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(operation.Value, &values); err != nil {
+		return nil, nil, newSCIMError(http.StatusBadRequest, scimTypeInvalidValue, "Patch operations without a path require an object value")
+	}
+
+	paths := make([]string, 0, len(values))
+	for path := range values {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+
+	return paths, values, nil
+}
+
+// scimPatchPath lower cases the path and removes the schema prefix.
+func scimPatchPath(path, schema string) string {
+	path = strings.ToLower(path)
+	return strings.TrimPrefix(path, strings.ToLower(schema)+":")
+}
+
+func decodeSCIMString(path string, value json.RawMessage) (string, error) {
+	var s string
+	if err := json.Unmarshal(value, &s); err != nil {
+		return "", newSCIMError(http.StatusBadRequest, scimTypeInvalidValue, "%s must be a string", path)
+	}
+
+	return s, nil
+}
+
+// decodeSCIMBool decodes a boolean, which some SCIM clients send as a
+// string.
+func decodeSCIMBool(path string, value json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(value, &b); err == nil {
+		return b, nil
+	}
+
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		switch strings.ToLower(s) {
+		case "true":
+			return true, nil
+
+		case "false":
+			return false, nil
+		}
+	}
+
+	return false, newSCIMError(http.StatusBadRequest, scimTypeInvalidValue, "%s must be a boolean", path)
+}
+
+// applySCIMUserPatch applies the patch operations to the user resource.
+// Attributes that aren't supported are ignored.
+func applySCIMUserPatch(u *SCIMUser, operations []SCIMPatchOperation) error {
+	for _, operation := range operations {
+		op, err := scimPatchOp(operation)
+		if err != nil {
+			return err
+		}
+
+		paths, values, err := scimPatchPaths(op, operation)
+		if err != nil {
+			return err
+		}
+
+		for _, path := range paths {
+			if err := applySCIMUserPatchPath(u, op, path, values[path]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func applySCIMUserPatchPath(u *SCIMUser, op, path string, value json.RawMessage) error {
+	remove := op == "remove"
+	attribute := scimPatchPath(path, scimSchemaUser)
+
+	switch {
+	case attribute == "active":
+		if remove {
+			return newSCIMError(http.StatusBadRequest, scimTypeMutability, "active can't be removed")
+		}
+
+		active, err := decodeSCIMBool(path, value)
+		if err != nil {
+			return err
+		}
+
+		u.Active = &active
+
+	case attribute == "username":
+		if remove {
+			return newSCIMError(http.StatusBadRequest, scimTypeMutability, "userName can't be removed")
+		}
+
+		userName, err := decodeSCIMString(path, value)
+		if err != nil {
+			return err
+		}
+
+		u.UserName = userName
+
+	case attribute == "externalid":
+		externalID := ""
+		if !remove {
+			var err error
+			if externalID, err = decodeSCIMString(path, value); err != nil {
+				return err
+			}
+		}
+
+		u.ExternalID = externalID
+
+	case attribute == "name":
+		if remove {
+			u.Name = nil
+			break
+		}
+
+		name := &SCIMName{}
+		if err := json.Unmarshal(value, name); err != nil {
+			return newSCIMError(http.StatusBadRequest, scimTypeInvalidValue, "%s must be an object", path)
+		}
+
+		u.Name = name
+
+	case strings.HasPrefix(attribute, "name."):
+		if u.Name == nil {
+			u.Name = &SCIMName{}
+		}
+
+		part := ""
+		if !remove {
+			var err error
+			if part, err = decodeSCIMString(path, value); err != nil {
+				return err
+			}
+		}
+
+		switch strings.TrimPrefix(attribute, "name.") {
+		case "formatted":
+			u.Name.Formatted = part
+
+		case "givenname":
+			u.Name.GivenName = part
+
+		case "familyname":
+			u.Name.FamilyName = part
+		}
+
+	case attribute == "emails":
+		if remove {
+			u.Emails = nil
+			break
+		}
+
+		var emails []SCIMEmail
+		if err := json.Unmarshal(value, &emails); err != nil {
+			return newSCIMError(http.StatusBadRequest, scimTypeInvalidValue, "%s must be a list of emails", path)
+		}
+
+		u.Emails = emails
+
+	case strings.HasPrefix(attribute, "emails[") && strings.HasSuffix(attribute, "].value"):
+		// e.g. emails[type eq "work"].value, as the user only has one email
+		// address
+		if remove {
+			u.Emails = nil
+			break
+		}
+
+		email, err := decodeSCIMString(path, value)
+		if err != nil {
+			return err
+		}
+
+		u.Emails = []SCIMEmail{{
+			Value:   email,
+			Type:    "work",
+			Primary: true,
+		}}
+	}
+
+	return nil
+}
+
+// applySCIMGroupPatch applies the patch operations to the group resource.
+// Attributes that aren't supported are ignored.
+func applySCIMGroupPatch(g *SCIMGroup, operations []SCIMPatchOperation) error {
+	for _, operation := range operations {
+		op, err := scimPatchOp(operation)
+		if err != nil {
+			return err
+		}
+
+		paths, values, err := scimPatchPaths(op, operation)
+		if err != nil {
+			return err
+		}
+
+		for _, path := range paths {
+			if err := applySCIMGroupPatchPath(g, op, path, values[path]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func applySCIMGroupPatchPath(g *SCIMGroup, op, path string, value json.RawMessage) error {
+	remove := op == "remove"
+	attribute := scimPatchPath(path, scimSchemaGroup)
+
+	switch {
+	case attribute == "displayname":
+		if remove {
+			return newSCIMError(http.StatusBadRequest, scimTypeMutability, "displayName can't be removed")
+		}
+
+		displayName, err := decodeSCIMString(path, value)
+		if err != nil {
+			return err
+		}
+
+		g.DisplayName = displayName
+
+	case attribute == "externalid":
+		externalID := ""
+		if !remove {
+			var err error
+			if externalID, err = decodeSCIMString(path, value); err != nil {
+				return err
+			}
+		}
+
+		g.ExternalID = externalID
+
+	case attribute == "members":
+		var members []SCIMReference
+		if len(value) > 0 && string(value) != "null" {
+			if err := json.Unmarshal(value, &members); err != nil {
+				return newSCIMError(http.StatusBadRequest, scimTypeInvalidValue, "%s must be a list of members", path)
+			}
+		}
+
+		switch op {
+		case "add":
+			g.Members = addSCIMMembers(g.Members, members)
+
+		case "replace":
+			g.Members = addSCIMMembers(nil, members)
+
+		case "remove":
+			if len(members) == 0 {
+				// removes all members
+				g.Members = nil
+			} else {
+				g.Members = removeSCIMMembers(g.Members, members)
+			}
+		}
+
+	case scimMembersFilterPathRegexp.MatchString(attribute):
+		if !remove {
+			return newSCIMError(http.StatusBadRequest, scimTypeInvalidPath, "Only remove operations support filtered member paths")
+		}
+
+		filter, err := parseSCIMFilter(scimMembersFilterPathRegexp.FindStringSubmatch(path)[1])
+		if err != nil {
+			return newSCIMError(http.StatusBadRequest, scimTypeInvalidPath, "Invalid member filter in %q", path)
+		}
+
+		if filter == nil || filter.Attribute != "value" {
+			return newSCIMError(http.StatusBadRequest, scimTypeInvalidPath, "Members can only be filtered by value")
+		}
+
+		g.Members = removeSCIMMembers(g.Members, []SCIMReference{{Value: filter.Value}})
+	}
+
+	return nil
+}
+
+// addSCIMMembers adds the members that aren't members yet.
+func addSCIMMembers(members, added []SCIMReference) []SCIMReference {
+	for _, member := range added {
+		exists := false
+		for _, m := range members {
+			if strings.EqualFold(m.Value, member.Value) {
+				exists = true
+				break
+			}
+		}
+
+		if !exists {
+			members = append(members, member)
+		}
+	}
+
+	return members
+}
+
+func removeSCIMMembers(members, removed []SCIMReference) []SCIMReference {
+	ret := make([]SCIMReference, 0, len(members))
+	for _, member := range members {
+		keep := true
+		for _, m := range removed {
+			if strings.EqualFold(m.Value, member.Value) {
+				keep = false
+				break
+			}
+		}
+
+		if keep {
+			ret = append(ret, member)
+		}
+	}
+
+	return ret
+}
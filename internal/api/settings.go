@@ -7,8 +7,10 @@ type ProviderSettings struct {
 	Apple          bool `json:"apple"`
 	Azure          bool `json:"azure"`
 	Bitbucket      bool `json:"bitbucket"`
+	DingTalk       bool `json:"dingtalk"`
 	Discord        bool `json:"discord"`
 	Facebook       bool `json:"facebook"`
+	Feishu         bool `json:"feishu"`
 	Figma          bool `json:"figma"`
 	Fly            bool `json:"fly"`
 	GitHub         bool `json:"github"`
@@ -16,18 +18,25 @@ type ProviderSettings struct {
 	Google         bool `json:"google"`
 	Keycloak       bool `json:"keycloak"`
 	Kakao          bool `json:"kakao"`
+	Lark           bool `json:"lark"`
 	Linkedin       bool `json:"linkedin"`
 	LinkedinOIDC   bool `json:"linkedin_oidc"`
 	Notion         bool `json:"notion"`
+	QQ             bool `json:"qq"`
 	Spotify        bool `json:"spotify"`
 	Slack          bool `json:"slack"`
 	SlackOIDC      bool `json:"slack_oidc"`
 	WorkOS         bool `json:"workos"`
 	Twitch         bool `json:"twitch"`
 	Twitter        bool `json:"twitter"`
+	WeChat         bool `json:"wechat"`
+	WeChatMP       bool `json:"wechat_mp"`
+	Weibo          bool `json:"weibo"`
 	Email          bool `json:"email"`
 	Phone          bool `json:"phone"`
 	Zoom           bool `json:"zoom"`
+
+	Custom map[string]bool `json:"custom,omitempty"`
 }
 
 type Settings struct {
@@ -40,7 +49,15 @@ type Settings struct {
 }
 
 func (a *API) Settings(w http.ResponseWriter, r *http.Request) error {
-	config := a.config
+	config := a.requestConfig(r.Context())
+
+	var custom map[string]bool
+	for name, provider := range config.External.Custom {
+		if custom == nil {
+			custom = make(map[string]bool)
+		}
+		custom[name] = provider.Enabled
+	}
 
 	return sendJSON(w, http.StatusOK, &Settings{
 		ExternalProviders: ProviderSettings{
@@ -48,8 +65,10 @@ func (a *API) Settings(w http.ResponseWriter, r *http.Request) error {
 			Apple:          config.External.Apple.Enabled,
 			Azure:          config.External.Azure.Enabled,
 			Bitbucket:      config.External.Bitbucket.Enabled,
+			DingTalk:       config.External.DingTalk.Enabled,
 			Discord:        config.External.Discord.Enabled,
 			Facebook:       config.External.Facebook.Enabled,
+			Feishu:         config.External.Feishu.Enabled,
 			Figma:          config.External.Figma.Enabled,
 			Fly:            config.External.Fly.Enabled,
 			GitHub:         config.External.Github.Enabled,
@@ -57,18 +76,25 @@ func (a *API) Settings(w http.ResponseWriter, r *http.Request) error {
 			Google:         config.External.Google.Enabled,
 			Kakao:          config.External.Kakao.Enabled,
 			Keycloak:       config.External.Keycloak.Enabled,
+			Lark:           config.External.Lark.Enabled,
 			Linkedin:       config.External.Linkedin.Enabled,
 			LinkedinOIDC:   config.External.LinkedinOIDC.Enabled,
 			Notion:         config.External.Notion.Enabled,
+			QQ:             config.External.QQ.Enabled,
 			Spotify:        config.External.Spotify.Enabled,
 			Slack:          config.External.Slack.Enabled,
 			SlackOIDC:      config.External.SlackOIDC.Enabled,
 			Twitch:         config.External.Twitch.Enabled,
 			Twitter:        config.External.Twitter.Enabled,
+			WeChat:         config.External.WeChat.Enabled,
+			WeChatMP:       config.External.WeChatMP.Enabled,
+			Weibo:          config.External.Weibo.Enabled,
 			WorkOS:         config.External.WorkOS.Enabled,
 			Email:          config.External.Email.Enabled,
 			Phone:          config.External.Phone.Enabled,
 			Zoom:           config.External.Zoom.Enabled,
+
+			Custom: custom,
 		},
 		DisableSignup:     config.DisableSignup,
 		MailerAutoconfirm: config.Mailer.Autoconfirm,
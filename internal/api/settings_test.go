@@ -46,6 +46,13 @@ func TestSettings_DefaultProviders(t *testing.T) {
 	require.True(t, p.Twitch)
 	require.True(t, p.WorkOS)
 	require.True(t, p.Zoom)
+	require.True(t, p.WeChat)
+	require.True(t, p.WeChatMP)
+	require.True(t, p.QQ)
+	require.True(t, p.Weibo)
+	require.True(t, p.DingTalk)
+	require.True(t, p.Feishu)
+	require.True(t, p.Lark)
 
 }
 
@@ -30,13 +30,13 @@ type SignupParams struct {
 }
 
 func (a *API) validateSignupParams(ctx context.Context, p *SignupParams) error {
-	config := a.config
+	config := a.requestConfig(ctx)
 
 	if p.Password == "" {
 		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Signup requires a valid password")
 	}
 
-	if err := a.checkPasswordStrength(ctx, p.Password); err != nil {
+	if err := a.checkPasswordStrength(ctx, p.Password, p.Email, p.Phone); err != nil {
 		return err
 	}
 	if p.Email != "" && p.Phone != "" {
@@ -109,7 +109,7 @@ func (params *SignupParams) ToUserModel(isSSOUser bool) (user *models.User, err
 // Signup is the endpoint for registering a new user
 func (a *API) Signup(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
-	config := a.config
+	config := a.requestConfig(ctx)
 	db := a.db.WithContext(ctx)
 
 	if config.DisableSignup {
@@ -378,6 +378,9 @@ func (a *API) signupNewUser(conn *storage.Connection, user *models.User) (*model
 		if terr = user.SetRole(tx, config.JWT.DefaultGroupName); terr != nil {
 			return apierrors.NewInternalServerError("Database error updating user").WithInternalError(terr)
 		}
+		if terr = a.recordPasswordHistory(tx, user); terr != nil {
+			return terr
+		}
 		return nil
 	})
 	if err != nil {
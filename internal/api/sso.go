@@ -59,15 +59,6 @@ func (a *API) SingleSignOn(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 	flowType := getFlowFromChallenge(params.CodeChallenge)
-	var flowStateID *uuid.UUID
-	flowStateID = nil
-	if isPKCEFlow(flowType) {
-		flowState, err := generateFlowState(db, models.SSOSAML.String(), models.SSOSAML, codeChallengeMethod, codeChallenge, nil)
-		if err != nil {
-			return err
-		}
-		flowStateID = &flowState.ID
-	}
 
 	var ssoProvider *models.SSOProvider
 
@@ -87,6 +78,30 @@ func (a *API) SingleSignOn(w http.ResponseWriter, r *http.Request) error {
 		}
 	}
 
+	authMethod := models.SSOSAML
+	if ssoProvider.Type() == "oidc" {
+		authMethod = models.SSOOIDC
+	}
+
+	var flowState *models.FlowState
+	var flowStateID *uuid.UUID
+	if isPKCEFlow(flowType) {
+		flowState, err = generateFlowState(db, authMethod.String(), authMethod, codeChallengeMethod, codeChallenge, nil)
+		if err != nil {
+			return err
+		}
+		flowStateID = &flowState.ID
+	}
+
+	if authMethod == models.SSOOIDC {
+		ssoRedirectURL, err := a.ssoOIDCAuthorizationURL(r, ssoProvider, params.RedirectTo, flowState)
+		if err != nil {
+			return err
+		}
+
+		return a.sendSingleSignOnRedirect(w, r, params, ssoRedirectURL)
+	}
+
 	entityDescriptor, err := ssoProvider.SAMLProvider.EntityDescriptor()
 	if err != nil {
 		return apierrors.NewInternalServerError("Error parsing SAML Metadata for SAML provider").WithInternalError(err)
@@ -131,6 +146,12 @@ func (a *API) SingleSignOn(w http.ResponseWriter, r *http.Request) error {
 		return apierrors.NewInternalServerError("Error creating SAML authentication request redirect URL").WithInternalError(err)
 	}
 
+	return a.sendSingleSignOnRedirect(w, r, params, ssoRedirectURL.String())
+}
+
+// sendSingleSignOnRedirect sends the user to the identity provider, or
+// returns its URL if the HTTP redirect is skipped.
+func (a *API) sendSingleSignOnRedirect(w http.ResponseWriter, r *http.Request, params *SingleSignOnParams, ssoRedirectURL string) error {
 	skipHTTPRedirect := false
 
 	if params.SkipHTTPRedirect != nil {
@@ -139,10 +160,10 @@ func (a *API) SingleSignOn(w http.ResponseWriter, r *http.Request) error {
 
 	if skipHTTPRedirect {
 		return sendJSON(w, http.StatusOK, SingleSignOnResponse{
-			URL: ssoRedirectURL.String(),
+			URL: ssoRedirectURL,
 		})
 	}
 
-	http.Redirect(w, r, ssoRedirectURL.String(), http.StatusSeeOther)
+	http.Redirect(w, r, ssoRedirectURL, http.StatusSeeOther)
 	return nil
 }
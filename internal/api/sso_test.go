@@ -16,6 +16,7 @@ import (
 	"github.com/stretchr/testify/suite"
 	"github.com/supabase/auth/internal/conf"
 	"github.com/supabase/auth/internal/models"
+	"golang.org/x/oauth2"
 )
 
 const dateInPast = "2001-02-03T04:05:06.789"
@@ -325,6 +326,53 @@ func (ts *SSOTestSuite) TestAdminCreateSSOProvider() {
 				"metadata_url": "https://accounts.google.com\\o/saml2?idpid=EXAMPLE-WITH-INVALID-METADATA-URL",
 			},
 		},
+		{
+			StatusCode: http.StatusCreated,
+			Request: map[string]interface{}{
+				"type":          "oidc",
+				"issuer":        "https://example.okta.com",
+				"client_id":     "client-id",
+				"client_secret": "client-secret",
+				"scopes":        "groups",
+				"domains": []string{
+					"example.net",
+				},
+				"attribute_mapping": map[string]interface{}{
+					"keys": map[string]interface{}{
+						"groups": map[string]interface{}{
+							"name":  "groups",
+							"array": true,
+						},
+					},
+				},
+			},
+		},
+		{
+			StatusCode: http.StatusBadRequest,
+			Request: map[string]interface{}{
+				"type":          "oidc",
+				"issuer":        "http://example.okta.com",
+				"client_id":     "client-id",
+				"client_secret": "client-secret",
+			},
+		},
+		{
+			StatusCode: http.StatusBadRequest,
+			Request: map[string]interface{}{
+				"type":         "oidc",
+				"issuer":       "https://example.okta.com",
+				"client_id":    "client-id",
+				"metadata_xml": validSAMLIDPMetadata("https://accounts.google.com/o/saml2?idpid=EXAMPLE-OIDC"),
+			},
+		},
+		{
+			StatusCode: http.StatusBadRequest,
+			Request: map[string]interface{}{
+				"type":         "saml",
+				"metadata_xml": validSAMLIDPMetadata("https://accounts.google.com/o/saml2?idpid=EXAMPLE-WITH-ISSUER"),
+				"issuer":       "https://example.okta.com",
+			},
+		},
 		// TODO: add example with metadata_url
 	}
 
@@ -505,6 +553,188 @@ func (ts *SSOTestSuite) TestAdminUpdateSSOProvider() {
 	}
 }
 
+func (ts *SSOTestSuite) TestAdminUpdateOIDCSSOProvider() {
+	body, err := json.Marshal(map[string]interface{}{
+		"type":          "oidc",
+		"issuer":        "https://login.microsoftonline.com/tenant/v2.0",
+		"client_id":     "client-id",
+		"client_secret": "client-secret",
+	})
+	require.NoError(ts.T(), err)
+
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/admin/sso/providers", bytes.NewBuffer(body))
+	req.Header.Set("Authorization", "Bearer "+ts.AdminJWT)
+	w := httptest.NewRecorder()
+
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusCreated, w.Code, w.Body.String())
+
+	var created map[string]interface{}
+	require.NoError(ts.T(), json.Unmarshal(w.Body.Bytes(), &created))
+	require.NotContains(ts.T(), created, "saml")
+	require.Contains(ts.T(), created, "oidc")
+
+	oidc := created["oidc"].(map[string]interface{})
+	require.Equal(ts.T(), "https://login.microsoftonline.com/tenant/v2.0", oidc["issuer"])
+	require.Equal(ts.T(), "client-id", oidc["client_id"])
+	require.NotContains(ts.T(), oidc, "client_secret")
+
+	providerID := created["id"].(string)
+
+	examples := []struct {
+		Status  int
+		Request map[string]interface{}
+	}{
+		{
+			Status: http.StatusBadRequest, // changing the type
+			Request: map[string]interface{}{
+				"type": "saml",
+			},
+		},
+		{
+			Status: http.StatusBadRequest, // SAML only parameter
+			Request: map[string]interface{}{
+				"metadata_url": "https://accounts.google.com/o/saml2?idpid=EXAMPLE-A",
+			},
+		},
+		{
+			Status: http.StatusOK,
+			Request: map[string]interface{}{
+				"client_id":     "new-client-id",
+				"client_secret": "new-client-secret",
+				"scopes":        "groups offline_access",
+				"domains": []string{
+					"example.com",
+				},
+			},
+		},
+	}
+
+	for _, example := range examples {
+		body, err := json.Marshal(example.Request)
+		require.NoError(ts.T(), err)
+
+		req := httptest.NewRequest(http.MethodPut, "http://localhost/admin/sso/providers/"+providerID, bytes.NewBuffer(body))
+		req.Header.Set("Authorization", "Bearer "+ts.AdminJWT)
+		w := httptest.NewRecorder()
+
+		ts.API.handler.ServeHTTP(w, req)
+
+		require.Equal(ts.T(), example.Status, w.Code, w.Body.String())
+	}
+
+	provider, err := models.FindSSOProviderForEmailAddress(ts.API.db, "someone@example.com")
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), providerID, provider.ID.String())
+	require.Equal(ts.T(), "oidc", provider.Type())
+	require.Equal(ts.T(), "new-client-id", provider.OIDCProvider.ClientID)
+	require.Equal(ts.T(), "groups offline_access", *provider.OIDCProvider.Scopes)
+
+	secret, err := provider.OIDCProvider.GetClientSecret(ts.Config.Security.DBEncryption.DecryptionKeys)
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), "new-client-secret", secret)
+}
+
+func (ts *SSOTestSuite) TestSingleSignOnOIDC() {
+	var issuer string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(ts.T(), "/.well-known/openid-configuration", r.URL.Path)
+
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(ts.T(), json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                 issuer,
+			"authorization_endpoint": issuer + "/authorize",
+			"token_endpoint":         issuer + "/token",
+			"jwks_uri":               issuer + "/keys",
+		}))
+	}))
+	defer server.Close()
+	issuer = server.URL
+
+	oidcProvider := models.NewOIDCProvider(issuer, "client-id")
+	require.NoError(ts.T(), oidcProvider.SetClientSecret("client-secret", false, "", ""))
+
+	provider := &models.SSOProvider{
+		OIDCProvider: oidcProvider,
+		SSODomains: []models.SSODomain{
+			{Domain: "example.com"},
+		},
+	}
+	require.NoError(ts.T(), ts.API.db.Eager("OIDCProvider", "SSODomains").Create(provider))
+
+	for _, pkce := range []bool{false, true} {
+		request := map[string]interface{}{
+			"domain":             "example.com",
+			"skip_http_redirect": true,
+		}
+
+		if pkce {
+			request["code_challenge"] = "vby3iMQ4XUuycKkEyNsYHXshPql1Dod7Ebey2iXTXm4"
+			request["code_challenge_method"] = "s256"
+		}
+
+		body, err := json.Marshal(request)
+		require.NoError(ts.T(), err)
+
+		req := httptest.NewRequest(http.MethodPost, "http://localhost/sso", bytes.NewBuffer(body))
+		if pkce {
+			// the audience is restored from the state in the callback
+			req.Header.Set(audHeaderName, "brand")
+		}
+		w := httptest.NewRecorder()
+
+		ts.API.handler.ServeHTTP(w, req)
+		require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+
+		var response struct {
+			URL string `json:"url"`
+		}
+		require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&response))
+
+		u, err := url.Parse(response.URL)
+		require.NoError(ts.T(), err)
+		require.Equal(ts.T(), issuer+"/authorize", u.Scheme+"://"+u.Host+u.Path)
+
+		query := u.Query()
+		require.Equal(ts.T(), "client-id", query.Get("client_id"))
+		require.Equal(ts.T(), ts.API.ssoOIDCCallbackURL(), query.Get("redirect_uri"))
+		require.Equal(ts.T(), "openid profile email", query.Get("scope"))
+
+		// the state is the one handled by the external provider callback
+		claims := ExternalProviderClaims{}
+		_, err = jwt.ParseWithClaims(query.Get("state"), &claims, func(token *jwt.Token) (interface{}, error) {
+			return []byte(ts.Config.JWT.Secret), nil
+		})
+		require.NoError(ts.T(), err)
+		require.Equal(ts.T(), "sso:"+provider.ID.String(), claims.Provider)
+		require.Equal(ts.T(), pkce, claims.FlowStateID != "")
+		require.Equal(ts.T(), pkce, claims.Aud == "brand")
+
+		// the ID token needs to contain the nonce of the state
+		require.NotEmpty(ts.T(), claims.Nonce)
+		require.Equal(ts.T(), claims.Nonce, query.Get("nonce"))
+
+		if pkce {
+			flowState, err := models.FindFlowStateByID(ts.API.db, claims.FlowStateID)
+			require.NoError(ts.T(), err)
+			require.Equal(ts.T(), models.SSOOIDC.String(), flowState.AuthenticationMethod)
+
+			require.NotNil(ts.T(), flowState.ProviderCodeVerifier)
+			require.Equal(ts.T(), "S256", query.Get("code_challenge_method"))
+			require.Equal(ts.T(), oauth2.S256ChallengeFromVerifier(*flowState.ProviderCodeVerifier), query.Get("code_challenge"))
+		} else {
+			require.Empty(ts.T(), query.Get("code_challenge"))
+		}
+	}
+
+	// OIDC SSO providers can't be signed in with through /authorize
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/authorize?provider=sso:"+provider.ID.String(), nil)
+	w := httptest.NewRecorder()
+
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+}
+
 func (ts *SSOTestSuite) TestAdminDeleteSSOProvider() {
 	providers := []struct {
 		ID      string
@@ -13,6 +13,7 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/gofrs/uuid"
 	"github.com/supabase/auth/internal/api/apierrors"
+	"github.com/supabase/auth/internal/crypto"
 	"github.com/supabase/auth/internal/models"
 	"github.com/supabase/auth/internal/observability"
 	"github.com/supabase/auth/internal/storage"
@@ -48,7 +49,7 @@ func (a *API) loadSSOProvider(w http.ResponseWriter, r *http.Request) (context.C
 	return withSSOProvider(r.Context(), provider), nil
 }
 
-// adminSSOProvidersList lists all SAML SSO Identity Providers in the system. Does
+// adminSSOProvidersList lists all SAML and OIDC SSO Identity Providers in the system. Does
 // not deal with pagination at this time.
 func (a *API) adminSSOProvidersList(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
@@ -77,11 +78,75 @@ type CreateSSOProviderParams struct {
 	Domains          []string                    `json:"domains"`
 	AttributeMapping models.SAMLAttributeMapping `json:"attribute_mapping"`
 	NameIDFormat     string                      `json:"name_id_format"`
+
+	Issuer       string  `json:"issuer"`
+	ClientID     string  `json:"client_id"`
+	ClientSecret string  `json:"client_secret"`
+	Scopes       *string `json:"scopes"`
+
+	// OrganizationID attaches the provider to an organization, which users
+	// signing in with the provider join. An empty string detaches it.
+	OrganizationID *string `json:"organization_id"`
+}
+
+// findSSOProviderOrganization finds the organization an SSO provider is being
+// attached to, or nil if it's being detached.
+func findSSOProviderOrganization(db *storage.Connection, organizationID string) (*uuid.UUID, error) {
+	if organizationID == "" {
+		return nil, nil
+	}
+
+	id, err := uuid.FromString(organizationID)
+	if err != nil {
+		return nil, apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "organization_id must be a UUID")
+	}
+
+	organization, err := models.FindOrganizationByID(db, id)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return nil, apierrors.NewBadRequestError(apierrors.ErrorCodeOrganizationNotFound, "Organization not found")
+		}
+		return nil, apierrors.NewInternalServerError("Database error finding organization").WithInternalError(err)
+	}
+
+	return &organization.ID, nil
 }
 
 func (p *CreateSSOProviderParams) validate(forUpdate bool) error {
-	if !forUpdate && p.Type != "saml" {
-		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Only 'saml' supported for SSO provider type")
+	switch p.Type {
+	case "saml":
+		return p.validateSAML(forUpdate)
+
+	case "oidc":
+		return p.validateOIDC(forUpdate)
+
+	default:
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Only 'saml' or 'oidc' supported for SSO provider type")
+	}
+}
+
+func (p *CreateSSOProviderParams) validateOIDC(forUpdate bool) error {
+	if p.MetadataURL != "" || p.MetadataXML != "" || p.NameIDFormat != "" {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "metadata_url, metadata_xml and name_id_format are only supported for 'saml' SSO providers")
+	} else if !forUpdate && (p.Issuer == "" || p.ClientID == "" || p.ClientSecret == "") {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "issuer, client_id and client_secret must be set")
+	} else if p.Issuer != "" {
+		issuerURL, err := url.ParseRequestURI(p.Issuer)
+		if err != nil {
+			return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "issuer is not a valid URL")
+		}
+
+		if issuerURL.Scheme != "https" {
+			return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "issuer is not a HTTPS URL")
+		}
+	}
+
+	return nil
+}
+
+func (p *CreateSSOProviderParams) validateSAML(forUpdate bool) error {
+	if p.Issuer != "" || p.ClientID != "" || p.ClientSecret != "" || p.Scopes != nil {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "issuer, client_id, client_secret and scopes are only supported for 'oidc' SSO providers")
 	} else if p.MetadataURL != "" && p.MetadataXML != "" {
 		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Only one of metadata_xml or metadata_url needs to be set")
 	} else if !forUpdate && p.MetadataURL == "" && p.MetadataXML == "" {
@@ -195,7 +260,8 @@ func fetchSAMLMetadata(ctx context.Context, url string) ([]byte, error) {
 	return data, nil
 }
 
-// adminSSOProvidersCreate creates a new SAML Identity Provider in the system.
+// adminSSOProvidersCreate creates a new SAML or OIDC Identity Provider in the
+// system.
 func (a *API) adminSSOProvidersCreate(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 	db := a.db.WithContext(ctx)
@@ -209,17 +275,68 @@ func (a *API) adminSSOProvidersCreate(w http.ResponseWriter, r *http.Request) er
 		return err
 	}
 
-	rawMetadata, metadata, err := params.metadata(ctx)
+	var provider *models.SSOProvider
+	var err error
+
+	if params.Type == "oidc" {
+		provider, err = a.newOIDCSSOProvider(params)
+	} else {
+		provider, err = a.newSAMLSSOProvider(ctx, db, params)
+	}
 	if err != nil {
 		return err
 	}
 
+	if params.OrganizationID != nil {
+		if provider.OrganizationID, err = findSSOProviderOrganization(db, *params.OrganizationID); err != nil {
+			return err
+		}
+	}
+
+	for _, domain := range params.Domains {
+		existingProvider, err := models.FindSSOProviderByDomain(db, domain)
+		if err != nil && !models.IsNotFoundError(err) {
+			return err
+		}
+		if existingProvider != nil {
+			return apierrors.NewBadRequestError(apierrors.ErrorCodeSSODomainAlreadyExists, "SSO Domain '%s' is already assigned to an SSO identity provider (%s)", domain, existingProvider.ID.String())
+		}
+
+		provider.SSODomains = append(provider.SSODomains, models.SSODomain{
+			Domain: domain,
+		})
+	}
+
+	eagerFields := []string{"SSODomains", "SAMLProvider"}
+	if provider.OIDCProvider != nil {
+		eagerFields = []string{"SSODomains", "OIDCProvider"}
+	}
+
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		if terr := tx.Eager(eagerFields...).Create(provider); terr != nil {
+			return terr
+		}
+
+		return provider.Reload(tx)
+	}); err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusCreated, provider)
+}
+
+func (a *API) newSAMLSSOProvider(ctx context.Context, db *storage.Connection, params *CreateSSOProviderParams) (*models.SSOProvider, error) {
+	rawMetadata, metadata, err := params.metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	existingProvider, err := models.FindSAMLProviderByEntityID(db, metadata.EntityID)
 	if err != nil && !models.IsNotFoundError(err) {
-		return err
+		return nil, err
 	}
 	if existingProvider != nil {
-		return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeSAMLIdPAlreadyExists, "SAML Identity Provider with this EntityID (%s) already exists", metadata.EntityID)
+		return nil, apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeSAMLIdPAlreadyExists, "SAML Identity Provider with this EntityID (%s) already exists", metadata.EntityID)
 	}
 
 	provider := &models.SSOProvider{
@@ -240,34 +357,29 @@ func (a *API) adminSSOProvidersCreate(w http.ResponseWriter, r *http.Request) er
 
 	provider.SAMLProvider.AttributeMapping = params.AttributeMapping
 
-	for _, domain := range params.Domains {
-		existingProvider, err := models.FindSSOProviderByDomain(db, domain)
-		if err != nil && !models.IsNotFoundError(err) {
-			return err
-		}
-		if existingProvider != nil {
-			return apierrors.NewBadRequestError(apierrors.ErrorCodeSSODomainAlreadyExists, "SSO Domain '%s' is already assigned to an SSO identity provider (%s)", domain, existingProvider.ID.String())
-		}
+	return provider, nil
+}
 
-		provider.SSODomains = append(provider.SSODomains, models.SSODomain{
-			Domain: domain,
-		})
-	}
+func (a *API) newOIDCSSOProvider(params *CreateSSOProviderParams) (*models.SSOProvider, error) {
+	dbEncryption := a.config.Security.DBEncryption
 
-	if err := db.Transaction(func(tx *storage.Connection) error {
-		if terr := tx.Eager().Create(provider); terr != nil {
-			return terr
-		}
+	oidcProvider := models.NewOIDCProvider(params.Issuer, params.ClientID)
+	if err := oidcProvider.SetClientSecret(params.ClientSecret, dbEncryption.Encrypt, dbEncryption.EncryptionKeyID, dbEncryption.EncryptionKey); err != nil {
+		return nil, apierrors.NewInternalServerError("Error encrypting OIDC client secret").WithInternalError(err)
+	}
 
-		return tx.Eager().Load(provider)
-	}); err != nil {
-		return err
+	if params.Scopes != nil && *params.Scopes != "" {
+		oidcProvider.Scopes = params.Scopes
 	}
 
-	return sendJSON(w, http.StatusCreated, provider)
+	oidcProvider.AttributeMapping = params.AttributeMapping
+
+	return &models.SSOProvider{
+		OIDCProvider: oidcProvider,
+	}, nil
 }
 
-// adminSSOProvidersGet returns an existing SAML Identity Provider in the system.
+// adminSSOProvidersGet returns an existing SAML or OIDC Identity Provider in the system.
 func (a *API) adminSSOProvidersGet(w http.ResponseWriter, r *http.Request) error {
 	provider := getSSOProvider(r.Context())
 
@@ -284,14 +396,21 @@ func (a *API) adminSSOProvidersUpdate(w http.ResponseWriter, r *http.Request) er
 		return err
 	}
 
+	provider := getSSOProvider(ctx)
+
+	if params.Type == "" {
+		params.Type = provider.Type()
+	} else if params.Type != provider.Type() {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "SSO provider type can't be changed from '%s'", provider.Type())
+	}
+
 	if err := params.validate(true /* <- forUpdate */); err != nil {
 		return err
 	}
 
 	modified := false
 	updateSAMLProvider := false
-
-	provider := getSSOProvider(ctx)
+	updateOIDCProvider := false
 
 	if params.MetadataXML != "" || params.MetadataURL != "" {
 		// metadata is being updated
@@ -313,6 +432,18 @@ func (a *API) adminSSOProvidersUpdate(w http.ResponseWriter, r *http.Request) er
 		modified = true
 	}
 
+	if params.OrganizationID != nil {
+		organizationID, err := findSSOProviderOrganization(db, *params.OrganizationID)
+		if err != nil {
+			return err
+		}
+
+		if !uuidPtrEqual(organizationID, provider.OrganizationID) {
+			provider.OrganizationID = organizationID
+			modified = true
+		}
+	}
+
 	// domains are being "updated" only when params.Domains is not nil, if
 	// it was nil (but not `[]`) then the caller is expecting not to modify
 	// the domains
@@ -350,12 +481,51 @@ func (a *API) adminSSOProvidersUpdate(w http.ResponseWriter, r *http.Request) er
 		}
 	}
 
+	attributeMapping := &provider.SAMLProvider.AttributeMapping
+	if provider.OIDCProvider != nil {
+		attributeMapping = &provider.OIDCProvider.AttributeMapping
+	}
+
 	updateAttributeMapping := false
 	if params.AttributeMapping.Keys != nil {
-		updateAttributeMapping = !provider.SAMLProvider.AttributeMapping.Equal(&params.AttributeMapping)
+		updateAttributeMapping = !attributeMapping.Equal(&params.AttributeMapping)
 		if updateAttributeMapping {
 			modified = true
-			provider.SAMLProvider.AttributeMapping = params.AttributeMapping
+			*attributeMapping = params.AttributeMapping
+		}
+	}
+
+	if oidcProvider := provider.OIDCProvider; oidcProvider != nil {
+		if params.Issuer != "" && params.Issuer != oidcProvider.Issuer {
+			oidcProvider.Issuer = params.Issuer
+			updateOIDCProvider = true
+		}
+
+		if params.ClientID != "" && params.ClientID != oidcProvider.ClientID {
+			oidcProvider.ClientID = params.ClientID
+			updateOIDCProvider = true
+		}
+
+		if params.ClientSecret != "" {
+			dbEncryption := a.config.Security.DBEncryption
+			if err := oidcProvider.SetClientSecret(params.ClientSecret, dbEncryption.Encrypt, dbEncryption.EncryptionKeyID, dbEncryption.EncryptionKey); err != nil {
+				return apierrors.NewInternalServerError("Error encrypting OIDC client secret").WithInternalError(err)
+			}
+			updateOIDCProvider = true
+		}
+
+		if params.Scopes != nil {
+			if *params.Scopes == "" {
+				oidcProvider.Scopes = nil
+			} else {
+				oidcProvider.Scopes = params.Scopes
+			}
+			updateOIDCProvider = true
+		}
+
+		if updateOIDCProvider || updateAttributeMapping {
+			modified = true
+			updateOIDCProvider = true
 		}
 	}
 
@@ -390,13 +560,17 @@ func (a *API) adminSSOProvidersUpdate(w http.ResponseWriter, r *http.Request) er
 				}
 			}
 
-			if updateAttributeMapping || updateSAMLProvider {
+			if updateOIDCProvider {
+				if terr := tx.Update(provider.OIDCProvider); terr != nil {
+					return terr
+				}
+			} else if updateAttributeMapping || updateSAMLProvider {
 				if terr := tx.Eager().Update(&provider.SAMLProvider); terr != nil {
 					return terr
 				}
 			}
 
-			return tx.Eager().Load(provider)
+			return provider.Reload(tx)
 		}); err != nil {
 			return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeConflict, "Updating SSO provider failed, likely due to a conflict. Try again?").WithInternalError(err)
 		}
@@ -405,7 +579,7 @@ func (a *API) adminSSOProvidersUpdate(w http.ResponseWriter, r *http.Request) er
 	return sendJSON(w, http.StatusOK, provider)
 }
 
-// adminSSOProvidersDelete deletes a SAML identity provider.
+// adminSSOProvidersDelete deletes a SAML or OIDC identity provider.
 func (a *API) adminSSOProvidersDelete(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 	db := a.db.WithContext(ctx)
@@ -420,3 +594,41 @@ func (a *API) adminSSOProvidersDelete(w http.ResponseWriter, r *http.Request) er
 
 	return sendJSON(w, http.StatusOK, provider)
 }
+
+// adminSSOProvidersCreateSCIMToken generates a new SCIM token for the SSO
+// provider, replacing any previous one. The token is only returned once.
+func (a *API) adminSSOProvidersCreateSCIMToken(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	provider := getSSOProvider(ctx)
+	token := crypto.SecureAlphanumeric(48)
+
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		return provider.SetSCIMToken(tx, token)
+	}); err != nil {
+		return apierrors.NewInternalServerError("Database error saving SCIM token").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{
+		"token":    token,
+		"scim_url": strings.TrimSuffix(a.config.API.ExternalURL, "/") + "/scim/v2",
+	})
+}
+
+// adminSSOProvidersDeleteSCIMToken disables SCIM provisioning for the SSO
+// provider. Provisioned users and groups are kept.
+func (a *API) adminSSOProvidersDeleteSCIMToken(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	provider := getSSOProvider(ctx)
+
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		return provider.SetSCIMToken(tx, "")
+	}); err != nil {
+		return apierrors.NewInternalServerError("Database error deleting SCIM token").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, provider)
+}
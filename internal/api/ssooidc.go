@@ -0,0 +1,356 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/fatih/structs"
+	"github.com/gofrs/uuid"
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/supabase/auth/internal/api/apierrors"
+	"github.com/supabase/auth/internal/api/provider"
+	"github.com/supabase/auth/internal/crypto"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/utilities"
+	"golang.org/x/oauth2"
+)
+
+// ssoOIDCProviderPrefix prefixes the external provider name of OIDC SSO
+// providers, which is also the provider of the identities they create.
+const ssoOIDCProviderPrefix = "sso:"
+
+// ssoOIDCProvider signs users in with an OpenID Connect SSO provider. It's
+// used by the external provider callback for sso:<id> provider names, which
+// only SingleSignOn puts in the OAuth state.
+type ssoOIDCProvider struct {
+	*oauth2.Config
+	oidc    *oidc.Provider
+	mapping models.SAMLAttributeMapping
+
+	// nonce is the nonce from the state, which the ID token must contain,
+	// and codeVerifier the PKCE code verifier of the flow state, if any.
+	nonce        string
+	codeVerifier string
+}
+
+func (p *ssoOIDCProvider) GetOAuthToken(code string) (*oauth2.Token, error) {
+	opts := []oauth2.AuthCodeOption{}
+	if p.codeVerifier != "" {
+		opts = append(opts, oauth2.VerifierOption(p.codeVerifier))
+	}
+
+	return p.Exchange(context.Background(), code, opts...)
+}
+
+func (p *ssoOIDCProvider) GetUserData(ctx context.Context, tok *oauth2.Token) (*provider.UserProvidedData, error) {
+	rawIDToken, ok := tok.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, errors.New("OIDC SSO provider did not return an ID token")
+	}
+
+	idToken, err := p.oidc.VerifierContext(ctx, &oidc.Config{ClientID: p.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkOIDCNonce(idToken, p.nonce); err != nil {
+		return nil, err
+	}
+
+	claims := make(map[string]interface{})
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+
+	if _, ok := claims["email"]; !ok && p.oidc.UserInfoEndpoint() != "" {
+		// some identity providers only return the email address from the
+		// userinfo endpoint
+		userInfo, err := p.oidc.UserInfo(ctx, oauth2.StaticTokenSource(tok))
+		if err != nil {
+			return nil, err
+		}
+
+		var userInfoClaims map[string]interface{}
+		if err := userInfo.Claims(&userInfoClaims); err != nil {
+			return nil, err
+		}
+
+		// claims of the verified ID token take precedence
+		for key, value := range userInfoClaims {
+			if _, ok := claims[key]; !ok {
+				claims[key] = value
+			}
+		}
+	}
+
+	return ssoOIDCUserData(idToken.Issuer, idToken.Subject, claims, p.mapping)
+}
+
+// checkOIDCNonce checks that the ID token was issued for the sign in with
+// the nonce, so that ID tokens can't be replayed into another sign in.
+func checkOIDCNonce(idToken *oidc.IDToken, nonce string) error {
+	if nonce == "" || subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(nonce)) != 1 {
+		return errors.New("OIDC SSO provider returned an ID token with an invalid nonce")
+	}
+
+	return nil
+}
+
+// mapOIDCClaims maps the claims of an OIDC SSO provider according to the
+// attribute mapping, the same way SAMLAssertion.Process maps SAML attributes.
+// Never returns nil.
+func mapOIDCClaims(claims map[string]interface{}, mapping models.SAMLAttributeMapping) map[string]interface{} {
+	ret := make(map[string]interface{})
+
+	for key, mapper := range mapping.Keys {
+		names := []string{}
+		if mapper.Name != "" {
+			names = append(names, mapper.Name)
+		}
+		names = append(names, mapper.Names...)
+
+		setKey := false
+
+		for _, name := range names {
+			value, ok := claims[name]
+			if !ok || value == nil || value == "" {
+				continue
+			}
+
+			setKey = true
+
+			if mapper.Array {
+				values, _ := ret[key].([]interface{})
+
+				if list, ok := value.([]interface{}); ok {
+					values = append(values, list...)
+				} else {
+					values = append(values, value)
+				}
+
+				ret[key] = values
+			} else {
+				ret[key] = value
+				break
+			}
+		}
+
+		if !setKey && mapper.Default != nil {
+			ret[key] = mapper.Default
+		}
+	}
+
+	return ret
+}
+
+// ssoOIDCUserData converts the claims of an OIDC SSO provider into user data.
+// Standard claims are read as they are, unless mapped, and other mapped
+// claims become custom claims. The email address is trusted like that of a
+// SAML assertion.
+func ssoOIDCUserData(issuer, subject string, claims map[string]interface{}, mapping models.SAMLAttributeMapping) (*provider.UserProvidedData, error) {
+	mapped := mapOIDCClaims(claims, mapping)
+
+	email, ok := mapped["email"].(string)
+	if !ok || email == "" {
+		email, _ = claims["email"].(string)
+	}
+
+	if email == "" {
+		return nil, errors.New("OIDC SSO provider did not return an email address")
+	}
+
+	values := make(map[string]interface{}, len(claims)+len(mapped))
+	for key, value := range claims {
+		values[key] = value
+	}
+	for key, value := range mapped {
+		values[key] = value
+	}
+
+	jsonClaims, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+
+	providerClaims := &provider.Claims{}
+	if err := json.Unmarshal(jsonClaims, providerClaims); err != nil {
+		// non-standard values of standard claims, such as an array audience,
+		// are skipped
+		var typeErr *json.UnmarshalTypeError
+		if !errors.As(err, &typeErr) {
+			return nil, err
+		}
+	}
+
+	providerClaims.Issuer = issuer
+	providerClaims.Subject = subject
+	providerClaims.Email = email
+	providerClaims.EmailVerified = true
+
+	// remove all of the standard claims, so that the rest can go into
+	// CustomClaims
+	for key := range structs.Map(providerClaims) {
+		delete(mapped, key)
+	}
+
+	providerClaims.CustomClaims = mapped
+
+	return &provider.UserProvidedData{
+		Emails: []provider.Email{{
+			Email:    email,
+			Verified: true,
+			Primary:  true,
+		}},
+		Metadata: providerClaims,
+	}, nil
+}
+
+// ssoOIDCCallbackURL is the redirect URI to register with OIDC SSO
+// providers.
+func (a *API) ssoOIDCCallbackURL() string {
+	return strings.TrimSuffix(a.config.API.ExternalURL, "/") + "/callback"
+}
+
+// newSSOOIDCProvider discovers the OpenID Connect configuration of an OIDC
+// SSO provider.
+func (a *API) newSSOOIDCProvider(ctx context.Context, ssoProvider *models.SSOProvider) (*ssoOIDCProvider, error) {
+	config := ssoProvider.OIDCProvider
+	if config == nil {
+		return nil, fmt.Errorf("SSO provider %s does not use OpenID Connect", ssoProvider.ID)
+	}
+
+	clientSecret, err := config.GetClientSecret(a.config.Security.DBEncryption.DecryptionKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	oidcProvider, err := oidc.NewProvider(ctx, config.Issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := []string{oidc.ScopeOpenID, "profile", "email"}
+	if config.Scopes != nil {
+		for _, scope := range strings.Fields(*config.Scopes) {
+			if !slices.Contains(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+
+	return &ssoOIDCProvider{
+		Config: &oauth2.Config{
+			ClientID:     config.ClientID,
+			ClientSecret: clientSecret,
+			Endpoint:     oidcProvider.Endpoint(),
+			RedirectURL:  a.ssoOIDCCallbackURL(),
+			Scopes:       scopes,
+		},
+		oidc:    oidcProvider,
+		mapping: config.AttributeMapping,
+	}, nil
+}
+
+// loadSSOOIDCProvider returns the provider for an sso:<id> provider name,
+// with the nonce and PKCE code verifier of the sign in in the context.
+func (a *API) loadSSOOIDCProvider(ctx context.Context, name string) (*ssoOIDCProvider, error) {
+	if !a.config.SAML.Enabled {
+		return nil, errors.New("SSO is disabled")
+	}
+
+	id, err := uuid.FromString(strings.TrimPrefix(name, ssoOIDCProviderPrefix))
+	if err != nil {
+		return nil, err
+	}
+
+	ssoProvider, err := models.FindSSOProviderByID(a.db.WithContext(ctx), id)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := a.newSSOOIDCProvider(ctx, ssoProvider)
+	if err != nil {
+		return nil, err
+	}
+
+	p.nonce = getOIDCNonce(ctx)
+
+	if flowStateID := getFlowStateID(ctx); flowStateID != "" {
+		flowState, err := models.FindFlowStateByID(a.db.WithContext(ctx), flowStateID)
+		if err != nil {
+			return nil, err
+		}
+
+		if flowState.ProviderCodeVerifier != nil {
+			p.codeVerifier = *flowState.ProviderCodeVerifier
+		}
+	}
+
+	return p, nil
+}
+
+// ssoOIDCAuthorizationURL returns the URL that starts the sign in with an
+// OIDC SSO provider. The provider redirects back to the external provider
+// callback, with the same signed state as other OAuth providers. The state
+// carries the nonce of the request, and the PKCE code verifier is kept in the
+// flow state, if any.
+func (a *API) ssoOIDCAuthorizationURL(r *http.Request, ssoProvider *models.SSOProvider, redirectTo string, flowState *models.FlowState) (string, error) {
+	ctx := r.Context()
+	config := a.requestConfig(ctx)
+
+	p, err := a.newSSOOIDCProvider(ctx, ssoProvider)
+	if err != nil {
+		return "", apierrors.NewInternalServerError("Error discovering OpenID Connect configuration of SSO provider").WithInternalError(err)
+	}
+
+	referrer := config.SiteURL
+	if utilities.IsRedirectURLValid(config, redirectTo) {
+		referrer = redirectTo
+	}
+
+	claims := ExternalProviderClaims{
+		AuthMicroserviceClaims: AuthMicroserviceClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(5 * time.Minute)),
+			},
+			SiteURL:    config.SiteURL,
+			InstanceID: uuid.Nil.String(),
+		},
+		Provider: ssoOIDCProviderPrefix + ssoProvider.ID.String(),
+		Referrer: referrer,
+		Nonce:    crypto.SecureAlphanumeric(32),
+	}
+
+	if aud := a.requestAud(ctx, r); aud != a.config.JWT.Aud {
+		claims.Aud = aud
+	}
+
+	opts := []oauth2.AuthCodeOption{oidc.Nonce(claims.Nonce)}
+
+	if flowState != nil {
+		claims.FlowStateID = flowState.ID.String()
+
+		codeVerifier := oauth2.GenerateVerifier()
+		flowState.ProviderCodeVerifier = &codeVerifier
+		if err := a.db.WithContext(ctx).UpdateOnly(flowState, "provider_code_verifier"); err != nil {
+			return "", apierrors.NewInternalServerError("Database error updating flow state").WithInternalError(err)
+		}
+
+		opts = append(opts, oauth2.S256ChallengeOption(codeVerifier))
+	}
+
+	state, err := signJwt(&config.JWT, claims)
+	if err != nil {
+		return "", apierrors.NewInternalServerError("Error creating state").WithInternalError(err)
+	}
+
+	return p.AuthCodeURL(state, opts...), nil
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/auth/internal/models"
+)
+
+func TestSSOOIDCUserData(t *testing.T) {
+	claims := map[string]interface{}{
+		"iss":    "https://example.okta.com",
+		"sub":    "00u1abcd",
+		"aud":    []interface{}{"client-id"},
+		"email":  "someone@example.com",
+		"name":   "Some One",
+		"groups": []interface{}{"admins", "engineering"},
+		"dept":   "R&D",
+	}
+
+	mapping := models.SAMLAttributeMapping{
+		Keys: map[string]models.SAMLAttribute{
+			"groups": {
+				Name:  "groups",
+				Array: true,
+			},
+			"department": {
+				Names: []string{"department", "dept"},
+			},
+			"role": {
+				Name:    "role",
+				Default: "member",
+			},
+			"full_name": {
+				Name: "name",
+			},
+		},
+	}
+
+	data, err := ssoOIDCUserData("https://example.okta.com", "00u1abcd", claims, mapping)
+	require.NoError(t, err)
+
+	require.Len(t, data.Emails, 1)
+	require.Equal(t, "someone@example.com", data.Emails[0].Email)
+	require.True(t, data.Emails[0].Verified)
+
+	require.Equal(t, "https://example.okta.com", data.Metadata.Issuer)
+	require.Equal(t, "00u1abcd", data.Metadata.Subject)
+	require.Equal(t, "Some One", data.Metadata.Name)
+	require.Equal(t, "Some One", data.Metadata.FullName)
+	require.True(t, data.Metadata.EmailVerified)
+
+	require.Equal(t, map[string]interface{}{
+		"groups":     []interface{}{"admins", "engineering"},
+		"department": "R&D",
+		"role":       "member",
+	}, data.Metadata.CustomClaims)
+}
+
+func TestSSOOIDCUserDataMappedEmail(t *testing.T) {
+	claims := map[string]interface{}{
+		"sub": "00u1abcd",
+		"upn": "someone@example.com",
+	}
+
+	_, err := ssoOIDCUserData("https://login.microsoftonline.com/tenant/v2.0", "00u1abcd", claims, models.SAMLAttributeMapping{})
+	require.Error(t, err)
+
+	data, err := ssoOIDCUserData("https://login.microsoftonline.com/tenant/v2.0", "00u1abcd", claims, models.SAMLAttributeMapping{
+		Keys: map[string]models.SAMLAttribute{
+			"email": {
+				Name: "upn",
+			},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "someone@example.com", data.Metadata.Email)
+	require.Empty(t, data.Metadata.CustomClaims)
+}
+
+func TestCheckOIDCNonce(t *testing.T) {
+	require.NoError(t, checkOIDCNonce(&oidc.IDToken{Nonce: "nonce"}, "nonce"))
+	require.Error(t, checkOIDCNonce(&oidc.IDToken{Nonce: "other"}, "nonce"))
+	require.Error(t, checkOIDCNonce(&oidc.IDToken{}, "nonce"))
+
+	// states without a nonce are refused
+	require.Error(t, checkOIDCNonce(&oidc.IDToken{}, ""))
+}
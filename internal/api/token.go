@@ -13,7 +13,9 @@ import (
 	"github.com/xeipuuv/gojsonschema"
 
 	"github.com/supabase/auth/internal/api/apierrors"
+	"github.com/supabase/auth/internal/conf"
 	"github.com/supabase/auth/internal/hooks/v0hooks"
+	"github.com/supabase/auth/internal/i18n"
 	"github.com/supabase/auth/internal/metering"
 	"github.com/supabase/auth/internal/models"
 	"github.com/supabase/auth/internal/observability"
@@ -32,6 +34,9 @@ type AccessTokenClaims struct {
 	AuthenticationMethodReference []models.AMREntry      `json:"amr,omitempty"`
 	SessionId                     string                 `json:"session_id,omitempty"`
 	IsAnonymous                   bool                   `json:"is_anonymous"`
+	OrganizationID                string                 `json:"organization_id,omitempty"`
+	OrganizationRole              string                 `json:"organization_role,omitempty"`
+	AdminPermissions              []string               `json:"admin_permissions,omitempty"`
 }
 
 // AccessTokenResponse represents an OAuth2 success response
@@ -116,7 +121,7 @@ func (a *API) ResourceOwnerPasswordGrant(ctx context.Context, w http.ResponseWri
 	}
 
 	aud := a.requestAud(ctx, r)
-	config := a.config
+	config := a.requestConfig(ctx)
 
 	if params.Email != "" && params.Phone != "" {
 		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Only an email address or phone number should be provided on login.")
@@ -147,12 +152,22 @@ func (a *API) ResourceOwnerPasswordGrant(ctx context.Context, w http.ResponseWri
 
 	if err != nil {
 		if models.IsNotFoundError(err) {
+			// locked like existing accounts, to not reveal which exist
+			identifier := failedAttemptIdentifier(aud, provider, params.Email+params.Phone)
+			if err := a.recordFailedIdentifierAttempt(w, r, db, identifier, models.FailedPasswordAttempt); err != nil {
+				return err
+			}
 			return apierrors.NewBadRequestError(apierrors.ErrorCodeInvalidCredentials, InvalidLoginMessage)
 		}
 		return apierrors.NewInternalServerError("Database error querying schema").WithInternalError(err)
 	}
 
 	if !user.HasPassword() {
+		if _, err := a.authenticateLocked(w, r, db, user, models.FailedPasswordAttempt, func(tx *storage.Connection) (bool, error) {
+			return false, nil
+		}); err != nil {
+			return err
+		}
 		return apierrors.NewBadRequestError(apierrors.ErrorCodeInvalidCredentials, InvalidLoginMessage)
 	}
 
@@ -160,14 +175,27 @@ func (a *API) ResourceOwnerPasswordGrant(ctx context.Context, w http.ResponseWri
 		return apierrors.NewBadRequestError(apierrors.ErrorCodeUserBanned, "User is banned")
 	}
 
-	isValidPassword, shouldReEncrypt, err := user.Authenticate(ctx, db, params.Password, config.Security.DBEncryption.DecryptionKeys, config.Security.DBEncryption.Encrypt, config.Security.DBEncryption.EncryptionKeyID)
+	var shouldUpdatePassword bool
+	isValidPassword, err := a.authenticateLocked(w, r, db, user, models.FailedPasswordAttempt, func(tx *storage.Connection) (bool, error) {
+		var valid bool
+		var terr error
+		valid, shouldUpdatePassword, terr = user.Authenticate(ctx, tx, params.Password, config.Security.DBEncryption.DecryptionKeys, config.Security.DBEncryption.Encrypt, config.Security.DBEncryption.EncryptionKeyID)
+		return valid, terr
+	})
+	if err != nil {
+		return err
+	}
+
+	// the risk is only enforced after the password is verified, so that
+	// the response doesn't tell whether the account exists
+	risk, err := a.assessRisk(r, db, user)
 	if err != nil {
 		return err
 	}
 
 	var weakPasswordError *WeakPasswordError
 	if isValidPassword {
-		if err := a.checkPasswordStrength(ctx, params.Password); err != nil {
+		if err := a.checkPasswordStrength(ctx, params.Password, user.GetEmail(), user.GetPhone()); err != nil {
 			if wpe, ok := err.(*WeakPasswordError); ok {
 				weakPasswordError = wpe
 			} else {
@@ -175,15 +203,15 @@ func (a *API) ResourceOwnerPasswordGrant(ctx context.Context, w http.ResponseWri
 			}
 		}
 
-		if shouldReEncrypt {
-			if err := user.SetPassword(ctx, params.Password, true, config.Security.DBEncryption.EncryptionKeyID, config.Security.DBEncryption.EncryptionKey); err != nil {
+		if shouldUpdatePassword {
+			if err := user.SetPassword(ctx, params.Password, config.Security.DBEncryption.Encrypt, config.Security.DBEncryption.EncryptionKeyID, config.Security.DBEncryption.EncryptionKey); err != nil {
 				return err
 			}
 
 			// directly change this in the database without
 			// calling user.UpdatePassword() because this
-			// is not a password change, just encryption
-			// change in the database
+			// is not a password change, just a rehash or
+			// encryption change in the database
 			if err := db.UpdateOnly(user, "encrypted_password"); err != nil {
 				return err
 			}
@@ -192,8 +220,10 @@ func (a *API) ResourceOwnerPasswordGrant(ctx context.Context, w http.ResponseWri
 
 	if config.Hook.PasswordVerificationAttempt.Enabled {
 		input := v0hooks.PasswordVerificationAttemptInput{
-			UserID: user.ID,
-			Valid:  isValidPassword,
+			UserID:      user.ID,
+			Valid:       isValidPassword,
+			RiskScore:   risk.Score,
+			RiskReasons: risk.Reasons,
 		}
 		output := v0hooks.PasswordVerificationAttemptOutput{}
 		if err := a.hooksMgr.InvokeHook(nil, r, &input, &output); err != nil {
@@ -216,12 +246,21 @@ func (a *API) ResourceOwnerPasswordGrant(ctx context.Context, w http.ResponseWri
 		return apierrors.NewBadRequestError(apierrors.ErrorCodeInvalidCredentials, InvalidLoginMessage)
 	}
 
+	if err := a.enforceRisk(r, db, user, "token", true, risk); err != nil {
+		return err
+	}
+	grantParams.RequireAAL2 = risk.RequiresAAL2()
+
 	if params.Email != "" && !user.IsConfirmed() {
 		return apierrors.NewBadRequestError(apierrors.ErrorCodeEmailNotConfirmed, "Email not confirmed")
 	} else if params.Phone != "" && !user.IsPhoneConfirmed() {
 		return apierrors.NewBadRequestError(apierrors.ErrorCodePhoneNotConfirmed, "Phone not confirmed")
 	}
 
+	if err := a.checkPasswordExpiry(db, user); err != nil {
+		return err
+	}
+
 	var token *AccessTokenResponse
 	err = db.Transaction(func(tx *storage.Connection) error {
 		var terr error
@@ -230,6 +269,9 @@ func (a *API) ResourceOwnerPasswordGrant(ctx context.Context, w http.ResponseWri
 		}); terr != nil {
 			return terr
 		}
+		if terr = a.clearFailedAttempts(tx, user, models.FailedPasswordAttempt); terr != nil {
+			return terr
+		}
 		token, terr = a.issueRefreshToken(r, tx, user, models.PasswordGrant, grantParams)
 		if terr != nil {
 			return terr
@@ -241,6 +283,9 @@ func (a *API) ResourceOwnerPasswordGrant(ctx context.Context, w http.ResponseWri
 		return err
 	}
 
+	if weakPasswordError != nil {
+		weakPasswordError.Message = weakPasswordError.LocalizedMessage(i18n.GetLanguageFromContextHTTP(r))
+	}
 	token.WeakPassword = weakPasswordError
 
 	metering.RecordLogin("password", user.ID)
@@ -302,6 +347,16 @@ func (a *API) PKCE(ctx context.Context, w http.ResponseWriter, r *http.Request)
 			// error type is already handled in issueRefreshToken
 			return terr
 		}
+		if authMethod == models.SSOSAML {
+			_, _, session, terr := models.FindUserWithRefreshToken(tx, token.RefreshToken, false)
+			if terr != nil {
+				return terr
+			}
+
+			if terr := models.LinkSAMLSessionToSession(tx, flowState.ID, session.ID); terr != nil {
+				return terr
+			}
+		}
 		token.ProviderAccessToken = flowState.ProviderAccessToken
 		// Because not all providers give out a refresh token
 		// See corresponding OAuth2 spec: <https://www.rfc-editor.org/rfc/rfc6749.html#section-5.1>
@@ -321,7 +376,7 @@ func (a *API) PKCE(ctx context.Context, w http.ResponseWriter, r *http.Request)
 }
 
 func (a *API) generateAccessToken(r *http.Request, tx *storage.Connection, user *models.User, sessionId *uuid.UUID, authenticationMethod models.AuthenticationMethod) (string, int64, error) {
-	config := a.config
+	config := a.requestConfig(r.Context())
 	if sessionId == nil {
 		return "", 0, apierrors.NewInternalServerError("Session is required to issue access token")
 	}
@@ -336,7 +391,7 @@ func (a *API) generateAccessToken(r *http.Request, tx *storage.Connection, user
 	}
 
 	issuedAt := time.Now().UTC()
-	expiresAt := issuedAt.Add(time.Second * time.Duration(config.JWT.Exp))
+	expiresAt := issuedAt.Add(time.Second * time.Duration(accessTokenExp(config, session.RequiresAAL2 && aal != models.AAL2)))
 
 	claims := &v0hooks.AccessTokenClaims{
 		RegisteredClaims: jwt.RegisteredClaims{
@@ -357,6 +412,15 @@ func (a *API) generateAccessToken(r *http.Request, tx *storage.Connection, user
 		IsAnonymous:                   user.IsAnonymous,
 	}
 
+	membership, terr := sessionOrganizationMembership(tx, user, session)
+	if terr != nil {
+		return "", 0, terr
+	}
+	if membership != nil {
+		claims.OrganizationID = membership.OrganizationID.String()
+		claims.OrganizationRole = membership.Role
+	}
+
 	var gotrueClaims jwt.Claims = claims
 	if config.Hook.CustomAccessToken.Enabled {
 		input := v0hooks.CustomAccessTokenInput{
@@ -385,7 +449,7 @@ func (a *API) generateAccessToken(r *http.Request, tx *storage.Connection, user
 }
 
 func (a *API) issueRefreshToken(r *http.Request, conn *storage.Connection, user *models.User, authenticationMethod models.AuthenticationMethod, grantParams models.GrantParams) (*AccessTokenResponse, error) {
-	config := a.config
+	config := a.requestConfig(r.Context())
 
 	now := time.Now()
 	user.LastSignInAt = &now
@@ -393,6 +457,7 @@ func (a *API) issueRefreshToken(r *http.Request, conn *storage.Connection, user
 	var tokenString string
 	var expiresAt int64
 	var refreshToken *models.RefreshToken
+	var newDevice bool
 
 	err := conn.Transaction(func(tx *storage.Connection) error {
 		var terr error
@@ -402,11 +467,21 @@ func (a *API) issueRefreshToken(r *http.Request, conn *storage.Connection, user
 			return apierrors.NewInternalServerError("Database error granting user").WithInternalError(terr)
 		}
 
+		if terr := a.cancelUserDeletion(r, tx, user); terr != nil {
+			return terr
+		}
+
 		terr = models.AddClaimToSession(tx, *refreshToken.SessionId, authenticationMethod)
 		if terr != nil {
 			return terr
 		}
 
+		newDevice = a.isNewDevice(r, tx, user, *refreshToken.SessionId)
+
+		if terr := a.recordSignIn(r, tx, user); terr != nil {
+			return terr
+		}
+
 		tokenString, expiresAt, terr = a.generateAccessToken(r, tx, user, refreshToken.SessionId, authenticationMethod)
 		if terr != nil {
 			// Account for Hook Error
@@ -422,19 +497,34 @@ func (a *API) issueRefreshToken(r *http.Request, conn *storage.Connection, user
 		return nil, err
 	}
 
+	if newDevice {
+		a.notifyNewDevice(r, user, *refreshToken.SessionId)
+	}
+
 	return &AccessTokenResponse{
 		Token:        tokenString,
 		TokenType:    "bearer",
-		ExpiresIn:    config.JWT.Exp,
+		ExpiresIn:    accessTokenExp(config, grantParams.RequireAAL2),
 		ExpiresAt:    expiresAt,
 		RefreshToken: refreshToken.Token,
 		User:         user,
 	}, nil
 }
 
+// accessTokenExp returns the lifetime in seconds of access tokens. Tokens of
+// sessions that must be stepped up to AAL2 only last as long as an MFA
+// challenge, as they are only accepted for stepping up.
+func accessTokenExp(config *conf.GlobalConfiguration, stepUp bool) int {
+	if stepUp && config.MFA.ChallengeExpiryDuration < float64(config.JWT.Exp) {
+		return int(config.MFA.ChallengeExpiryDuration)
+	}
+
+	return config.JWT.Exp
+}
+
 func (a *API) updateMFASessionAndClaims(r *http.Request, tx *storage.Connection, user *models.User, authenticationMethod models.AuthenticationMethod, grantParams models.GrantParams) (*AccessTokenResponse, error) {
 	ctx := r.Context()
-	config := a.config
+	config := a.requestConfig(ctx)
 	var tokenString string
 	var expiresAt int64
 	var refreshToken *models.RefreshToken
@@ -5,6 +5,7 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/supabase/auth/internal/api/apierrors"
@@ -33,6 +34,20 @@ func (p *IdTokenGrantParams) getProvider(ctx context.Context, config *conf.Globa
 	var providerType string
 	var acceptableClientIDs []string
 
+	var customName string
+	var customProvider *conf.CustomOAuthProviderConfiguration
+	var isCustom bool
+
+	if strings.HasPrefix(p.Provider, conf.CustomProviderPrefix) {
+		customName = p.Provider
+		customProvider, isCustom = config.External.CustomProvider(p.Provider)
+		if !isCustom {
+			return nil, false, "", nil, apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, fmt.Sprintf("Custom provider %q not found", p.Provider))
+		}
+	} else if p.Provider == "" {
+		customName, customProvider, isCustom = config.External.CustomProviderByIssuer(p.Issuer)
+	}
+
 	switch true {
 	case p.Provider == "apple" || provider.IsAppleIssuer(p.Issuer):
 		cfg = &config.External.Apple
@@ -99,6 +114,15 @@ func (p *IdTokenGrantParams) getProvider(ctx context.Context, config *conf.Globa
 		issuer = provider.IssuerVercelMarketplace
 		acceptableClientIDs = append(acceptableClientIDs, config.External.VercelMarketplace.ClientID...)
 
+	case isCustom:
+		if customProvider.Issuer == "" {
+			return nil, false, "", nil, apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, fmt.Sprintf("Custom provider %q has no issuer to verify ID tokens with", customName))
+		}
+		cfg = &customProvider.OAuthProviderConfiguration
+		providerType = customName
+		issuer = customProvider.Issuer
+		acceptableClientIDs = append(acceptableClientIDs, customProvider.ClientID...)
+
 	default:
 		log.WithField("issuer", p.Issuer).WithField("client_id", p.ClientID).Warn("Use of POST /token with arbitrary issuer and client_id is deprecated for security reasons. Please switch to using the API with provider only!")
 
@@ -145,7 +169,7 @@ func (a *API) IdTokenGrant(ctx context.Context, w http.ResponseWriter, r *http.R
 	log := observability.GetLogEntry(r).Entry
 
 	db := a.db.WithContext(ctx)
-	config := a.config
+	config := a.requestConfig(ctx)
 
 	params := &IdTokenGrantParams{}
 	if err := retrieveRequestParams(r, params); err != nil {
@@ -174,10 +198,17 @@ func (a *API) IdTokenGrant(ctx context.Context, w http.ResponseWriter, r *http.R
 		}
 	}
 
-	idToken, userData, err := provider.ParseIDToken(ctx, oidcProvider, oidcConfig, params.IdToken, provider.ParseIDTokenOptions{
+	parseOptions := provider.ParseIDTokenOptions{
 		SkipAccessTokenCheck: params.AccessToken == "",
 		AccessToken:          params.AccessToken,
-	})
+	}
+
+	if customProvider, ok := config.External.CustomProvider(providerType); ok {
+		parseOptions.Custom = true
+		parseOptions.ClaimMapping = customProvider.ClaimMapping
+	}
+
+	idToken, userData, err := provider.ParseIDToken(ctx, oidcProvider, oidcConfig, params.IdToken, parseOptions)
 	if err != nil {
 		return apierrors.NewOAuthError("invalid request", "Bad ID token").WithInternalError(err)
 	}
@@ -263,6 +294,16 @@ func (a *API) IdTokenGrant(ctx context.Context, w http.ResponseWriter, r *http.R
 			return terr
 		}
 
+		risk, terr := a.assessRisk(r, tx, user)
+		if terr != nil {
+			return terr
+		}
+
+		if terr := a.enforceRisk(r, tx, user, "token", true, risk); terr != nil {
+			return terr
+		}
+		grantParams.RequireAAL2 = risk.RequiresAAL2()
+
 		token, terr = a.issueRefreshToken(r, tx, user, models.OAuth, grantParams)
 		if terr != nil {
 			return terr
@@ -23,7 +23,7 @@ type RefreshTokenGrantParams struct {
 // RefreshTokenGrant implements the refresh_token grant type flow
 func (a *API) RefreshTokenGrant(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 	db := a.db.WithContext(ctx)
-	config := a.config
+	config := a.requestConfig(ctx)
 
 	params := &RefreshTokenGrantParams{}
 	if err := retrieveRequestParams(r, params); err != nil {
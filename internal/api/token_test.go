@@ -14,6 +14,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
@@ -300,6 +301,358 @@ func (ts *TokenTestSuite) TestTokenPasswordGrantSuccess() {
 	assert.Equal(ts.T(), http.StatusOK, w.Code)
 }
 
+func (ts *TokenTestSuite) TestTokenPasswordGrantExpiredPassword() {
+	ts.Config.Password.MaxAge = time.Hour
+	defer func() {
+		ts.Config.Password.MaxAge = 0
+		ts.API.overrideTime = nil
+	}()
+
+	signIn := func() *httptest.ResponseRecorder {
+		var buffer bytes.Buffer
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
+			"email":    "test@example.com",
+			"password": "password",
+		}))
+
+		req := httptest.NewRequest(http.MethodPost, "http://localhost/token?grant_type=password", &buffer)
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		ts.API.handler.ServeHTTP(w, req)
+		return w
+	}
+
+	require.Equal(ts.T(), http.StatusOK, signIn().Code)
+
+	ts.API.overrideTime = func() time.Time {
+		return time.Now().Add(2 * time.Hour)
+	}
+
+	w := signIn()
+	require.Equal(ts.T(), http.StatusUnprocessableEntity, w.Code)
+
+	var data map[string]interface{}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+	require.Equal(ts.T(), "weak_password", data["error_code"])
+	require.Equal(ts.T(), []interface{}{"expired"}, data["weak_password"].(map[string]interface{})["reasons"])
+}
+
+func (ts *TokenTestSuite) TestTokenPasswordGrantPasswordAgeNotRecorded() {
+	ts.Config.Password.MaxAge = time.Hour
+	defer func() {
+		ts.Config.Password.MaxAge = 0
+	}()
+
+	// a user whose password was set long ago, before it was recorded
+	u, err := models.FindUserByEmailAndAudience(ts.API.db, "test@example.com", ts.Config.JWT.Aud)
+	require.NoError(ts.T(), err)
+	u.CreatedAt = time.Now().Add(-24 * time.Hour)
+	u.PasswordChangedAt = nil
+	require.NoError(ts.T(), ts.API.db.UpdateOnly(u, "created_at", "password_changed_at"))
+
+	var buffer bytes.Buffer
+	require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
+		"email":    "test@example.com",
+		"password": "password",
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/token?grant_type=password", &buffer)
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+
+	u, err = models.FindUserByEmailAndAudience(ts.API.db, "test@example.com", ts.Config.JWT.Aud)
+	require.NoError(ts.T(), err)
+	require.NotNil(ts.T(), u.PasswordChangedAt)
+	require.WithinDuration(ts.T(), time.Now(), *u.PasswordChangedAt, time.Minute)
+}
+
+func (ts *TokenTestSuite) TestTokenPasswordGrantLockout() {
+	ts.Config.Security.Lockout = conf.LockoutConfiguration{
+		Enabled:     true,
+		MaxAttempts: 3,
+		Window:      15 * time.Minute,
+		Strategy:    conf.LockoutStrategyLockout,
+		Duration:    15 * time.Minute,
+	}
+	defer func() {
+		ts.Config.Security.Lockout = conf.LockoutConfiguration{}
+		ts.API.overrideTime = nil
+	}()
+
+	signIn := func(password string) *httptest.ResponseRecorder {
+		var buffer bytes.Buffer
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
+			"email":    "test@example.com",
+			"password": password,
+		}))
+
+		req := httptest.NewRequest(http.MethodPost, "http://localhost/token?grant_type=password", &buffer)
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		ts.API.handler.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 3; i++ {
+		require.Equal(ts.T(), http.StatusBadRequest, signIn("wrong-password").Code)
+	}
+
+	// the correct password is refused while locked
+	w := signIn("password")
+	require.Equal(ts.T(), http.StatusTooManyRequests, w.Code)
+	require.NotEmpty(ts.T(), w.Header().Get("Retry-After"))
+
+	var data map[string]interface{}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+	require.Equal(ts.T(), "user_locked", data["error_code"])
+
+	ts.API.overrideTime = func() time.Time {
+		return time.Now().Add(16 * time.Minute)
+	}
+
+	require.Equal(ts.T(), http.StatusOK, signIn("password").Code)
+
+	attempts, err := models.FindFailedAuthAttempts(ts.API.db, ts.User.ID, models.FailedPasswordAttempt, time.Time{})
+	require.NoError(ts.T(), err)
+	require.Empty(ts.T(), attempts)
+}
+
+func (ts *TokenTestSuite) TestTokenPasswordGrantLockoutUnknownAccount() {
+	ts.Config.Security.Lockout = conf.LockoutConfiguration{
+		Enabled:     true,
+		MaxAttempts: 3,
+		Window:      15 * time.Minute,
+		Strategy:    conf.LockoutStrategyLockout,
+		Duration:    15 * time.Minute,
+	}
+	defer func() {
+		ts.Config.Security.Lockout = conf.LockoutConfiguration{}
+	}()
+
+	signIn := func(email string) *httptest.ResponseRecorder {
+		var buffer bytes.Buffer
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
+			"email":    email,
+			"password": "wrong-password",
+		}))
+
+		req := httptest.NewRequest(http.MethodPost, "http://localhost/token?grant_type=password", &buffer)
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		ts.API.handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// accounts that don't exist are locked like existing ones
+	for _, email := range []string{"test@example.com", "unknown@example.com"} {
+		for i := 0; i < 3; i++ {
+			require.Equal(ts.T(), http.StatusBadRequest, signIn(email).Code)
+		}
+
+		w := signIn(email)
+		require.Equal(ts.T(), http.StatusTooManyRequests, w.Code, email)
+		require.NotEmpty(ts.T(), w.Header().Get("Retry-After"))
+
+		var data map[string]interface{}
+		require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+		require.Equal(ts.T(), "user_locked", data["error_code"])
+	}
+}
+
+func (ts *TokenTestSuite) TestTokenPasswordGrantRisk() {
+	ts.Config.Security.Risk = conf.RiskConfiguration{
+		Enabled:            true,
+		CountryHeader:      "CF-IPCountry",
+		NewCountryScore:    30,
+		FailedAttemptScore: 40,
+		MaxTravelSpeed:     1000,
+		VelocityWindow:     time.Hour,
+		BlockThreshold:     60,
+	}
+	defer func() {
+		ts.Config.Security.Risk = conf.RiskConfiguration{}
+	}()
+
+	signIn := func(password, country string) *httptest.ResponseRecorder {
+		var buffer bytes.Buffer
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
+			"email":    "test@example.com",
+			"password": password,
+		}))
+
+		req := httptest.NewRequest(http.MethodPost, "http://localhost/token?grant_type=password", &buffer)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("CF-IPCountry", country)
+
+		w := httptest.NewRecorder()
+		ts.API.handler.ServeHTTP(w, req)
+		return w
+	}
+
+	require.Equal(ts.T(), http.StatusOK, signIn("password", "DE").Code)
+
+	events, err := models.FindSignInEvents(ts.API.db, ts.User.ID, 10)
+	require.NoError(ts.T(), err)
+	require.Len(ts.T(), events, 1)
+	require.Equal(ts.T(), "DE", events[0].Country)
+
+	// a new country with a failed attempt is blocked, but only once the
+	// password is verified
+	require.Equal(ts.T(), http.StatusBadRequest, signIn("wrong-password", "FR").Code)
+	require.Equal(ts.T(), http.StatusBadRequest, signIn("wrong-password", "FR").Code)
+
+	w := signIn("password", "FR")
+	require.Equal(ts.T(), http.StatusForbidden, w.Code)
+
+	var data map[string]interface{}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+	require.Equal(ts.T(), "sign_in_blocked", data["error_code"])
+
+	// the known country is still allowed
+	require.Equal(ts.T(), http.StatusOK, signIn("password", "DE").Code)
+}
+
+func (ts *TokenTestSuite) TestTokenPasswordGrantRiskWithoutCaptcha() {
+	ts.Config.Security.Risk = conf.RiskConfiguration{
+		Enabled:         true,
+		CountryHeader:   "CF-IPCountry",
+		NewCountryScore: 30,
+		MaxTravelSpeed:  1000,
+		VelocityWindow:  time.Hour,
+		MFAThreshold:    30,
+	}
+	defer func() {
+		ts.Config.Security.Risk = conf.RiskConfiguration{}
+	}()
+
+	signIn := func(country string) *httptest.ResponseRecorder {
+		var buffer bytes.Buffer
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
+			"email":    "test@example.com",
+			"password": "password",
+		}))
+
+		req := httptest.NewRequest(http.MethodPost, "http://localhost/token?grant_type=password", &buffer)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("CF-IPCountry", country)
+
+		w := httptest.NewRecorder()
+		ts.API.handler.ServeHTTP(w, req)
+		return w
+	}
+
+	require.Equal(ts.T(), http.StatusOK, signIn("DE").Code)
+
+	// without a verified factor MFA falls back to captcha, which is
+	// disabled, so the sign in is blocked
+	w := signIn("FR")
+	require.Equal(ts.T(), http.StatusForbidden, w.Code)
+
+	var data map[string]interface{}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+	require.Equal(ts.T(), "sign_in_blocked", data["error_code"])
+}
+
+func TestAccessTokenExp(t *testing.T) {
+	config := &conf.GlobalConfiguration{}
+	config.JWT.Exp = 3600
+	config.MFA.ChallengeExpiryDuration = 300
+
+	require.Equal(t, 3600, accessTokenExp(config, false))
+	require.Equal(t, 300, accessTokenExp(config, true))
+
+	config.JWT.Exp = 60
+	require.Equal(t, 60, accessTokenExp(config, true))
+}
+
+func (ts *TokenTestSuite) TestNewDeviceRevokeSignIn() {
+	ts.Config.Mailer.Notifications.NewDevice.Enabled = true
+	ts.Config.Mailer.Notifications.RevokeLinkExpiry = time.Hour
+	defer func() {
+		ts.Config.Mailer.Notifications.NewDevice.Enabled = false
+	}()
+
+	var buffer bytes.Buffer
+	require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
+		"email":    "test@example.com",
+		"password": "password",
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/token?grant_type=password", &buffer)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "new-device")
+
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	known, _, err := models.HasSignedInFrom(ts.API.db, ts.User.ID, "192.0.2.1", "new-device", *ts.RefreshToken.SessionId)
+	require.NoError(ts.T(), err)
+	require.True(ts.T(), known)
+
+	revoke := func(token string) *url.URL {
+		req := httptest.NewRequest(http.MethodPost, "http://localhost/revoke_sign_in", strings.NewReader(url.Values{"token": {token}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		ts.API.handler.ServeHTTP(w, req)
+		require.Equal(ts.T(), http.StatusSeeOther, w.Code)
+
+		location, err := url.Parse(w.Header().Get("Location"))
+		require.NoError(ts.T(), err)
+		return location
+	}
+
+	// access tokens can't be used to revoke sessions
+	accessToken, _, err := ts.API.generateAccessToken(req, ts.API.db, ts.User, ts.RefreshToken.SessionId, models.PasswordGrant)
+	require.NoError(ts.T(), err)
+	require.Contains(ts.T(), revoke(accessToken).Fragment, "error_code=revoke_link_invalid")
+
+	token, err := signJwt(&ts.Config.JWT, RevokeSignInClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+		RevokeUserID:    ts.User.ID.String(),
+		RevokeSessionID: ts.RefreshToken.SessionId.String(),
+	})
+	require.NoError(ts.T(), err)
+
+	// following the link only asks for confirmation
+	confirmReq := httptest.NewRequest(http.MethodGet, "http://localhost/revoke_sign_in?token="+url.QueryEscape(token), nil)
+	confirm := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(confirm, confirmReq)
+	require.Equal(ts.T(), http.StatusOK, confirm.Code)
+	require.Contains(ts.T(), confirm.Body.String(), `method="post"`)
+
+	_, err = models.FindSessionByID(ts.API.db, *ts.RefreshToken.SessionId, false)
+	require.NoError(ts.T(), err)
+
+	other, err := models.NewSession(ts.User.ID, nil)
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), ts.API.db.Create(other))
+
+	require.Contains(ts.T(), revoke(token).Fragment, "message=")
+
+	// the user is signed out everywhere
+	_, err = models.FindSessionByID(ts.API.db, *ts.RefreshToken.SessionId, false)
+	require.True(ts.T(), models.IsNotFoundError(err))
+
+	_, err = models.FindSessionByID(ts.API.db, other.ID, false)
+	require.True(ts.T(), models.IsNotFoundError(err))
+
+	user, err := models.FindUserByID(ts.API.db, ts.User.ID)
+	require.NoError(ts.T(), err)
+	require.False(ts.T(), user.HasPassword())
+
+	// the link can't be used again
+	require.Contains(ts.T(), revoke(token).Fragment, "error_code=revoke_link_invalid")
+}
+
 func (ts *TokenTestSuite) TestTokenRefreshTokenGrantSuccess() {
 	var buffer bytes.Buffer
 	require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
@@ -26,8 +26,8 @@ type UserUpdateParams struct {
 	CodeChallengeMethod string                 `json:"code_challenge_method"`
 }
 
-func (a *API) validateUserUpdateParams(ctx context.Context, p *UserUpdateParams) error {
-	config := a.config
+func (a *API) validateUserUpdateParams(ctx context.Context, user *models.User, p *UserUpdateParams) error {
+	config := a.requestConfig(ctx)
 
 	var err error
 	if p.Email != "" {
@@ -50,7 +50,7 @@ func (a *API) validateUserUpdateParams(ctx context.Context, p *UserUpdateParams)
 	}
 
 	if p.Password != nil {
-		if err := a.checkPasswordStrength(ctx, *p.Password); err != nil {
+		if err := a.checkPasswordStrength(ctx, *p.Password, user.GetEmail(), user.GetPhone(), p.Email, p.Phone); err != nil {
 			return err
 		}
 	}
@@ -80,7 +80,7 @@ func (a *API) UserGet(w http.ResponseWriter, r *http.Request) error {
 func (a *API) UserUpdate(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 	db := a.db.WithContext(ctx)
-	config := a.config
+	config := a.requestConfig(ctx)
 	aud := a.requestAud(ctx, r)
 
 	params := &UserUpdateParams{}
@@ -91,7 +91,7 @@ func (a *API) UserUpdate(w http.ResponseWriter, r *http.Request) error {
 	user := getUser(ctx)
 	session := getSession(ctx)
 
-	if err := a.validateUserUpdateParams(ctx, params); err != nil {
+	if err := a.validateUserUpdateParams(ctx, user, params); err != nil {
 		return err
 	}
 
@@ -174,6 +174,10 @@ func (a *API) UserUpdate(w http.ResponseWriter, r *http.Request) error {
 			if isSamePassword {
 				return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeSamePassword, "New password should be different from the old password.")
 			}
+
+			if err := a.checkPasswordHistory(ctx, db, user, password); err != nil {
+				return err
+			}
 		}
 
 		if err := user.SetPassword(ctx, password, config.Security.DBEncryption.Encrypt, config.Security.DBEncryption.EncryptionKeyID, config.Security.DBEncryption.EncryptionKey); err != nil {
@@ -193,6 +197,10 @@ func (a *API) UserUpdate(w http.ResponseWriter, r *http.Request) error {
 				return apierrors.NewInternalServerError("Error during password storage").WithInternalError(terr)
 			}
 
+			if terr = a.recordPasswordHistory(tx, user); terr != nil {
+				return terr
+			}
+
 			if terr := models.NewAuditLogEntry(r, tx, user, models.UserUpdatePasswordAction, "", nil); terr != nil {
 				return terr
 			}
@@ -263,5 +271,9 @@ func (a *API) UserUpdate(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
+	if params.Password != nil {
+		a.notifyUser(r, user, mailer.PasswordChangedNotification, nil)
+	}
+
 	return sendJSON(w, http.StatusOK, user)
 }
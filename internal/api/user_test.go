@@ -556,3 +556,52 @@ func (ts *UserTestSuite) TestUserUpdatePasswordLogoutOtherSessions() {
 	ts.API.handler.ServeHTTP(w, req)
 	require.NotEqual(ts.T(), http.StatusOK, w.Code)
 }
+
+func (ts *UserTestSuite) TestUserUpdatePasswordHistory() {
+	ts.Config.Security.UpdatePasswordRequireReauthentication = false
+	ts.Config.Password.HistoryCount = 2
+	defer func() {
+		ts.Config.Password.HistoryCount = 0
+	}()
+
+	u, err := models.FindUserByEmailAndAudience(ts.API.db, "test@example.com", ts.Config.JWT.Aud)
+	require.NoError(ts.T(), err)
+
+	token := ts.generateAccessTokenAndSession(u)
+
+	updatePassword := func(password string) *httptest.ResponseRecorder {
+		var buffer bytes.Buffer
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
+			"password": password,
+		}))
+
+		req := httptest.NewRequest(http.MethodPut, "http://localhost/user", &buffer)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+		w := httptest.NewRecorder()
+		ts.API.handler.ServeHTTP(w, req)
+		return w
+	}
+
+	require.Equal(ts.T(), http.StatusOK, updatePassword("first-password").Code)
+	require.Equal(ts.T(), http.StatusOK, updatePassword("second-password").Code)
+
+	// first-password is one of the last 2 passwords
+	w := updatePassword("first-password")
+	require.Equal(ts.T(), http.StatusUnprocessableEntity, w.Code)
+
+	var data map[string]interface{}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+	require.Equal(ts.T(), "weak_password", data["error_code"])
+	require.Equal(ts.T(), []interface{}{"reused"}, data["weak_password"].(map[string]interface{})["reasons"])
+
+	require.Equal(ts.T(), http.StatusOK, updatePassword("third-password").Code)
+
+	// first-password has dropped out of the history
+	require.Equal(ts.T(), http.StatusOK, updatePassword("first-password").Code)
+
+	entries, err := models.FindPasswordHistory(ts.API.db, u.ID, 10)
+	require.NoError(ts.T(), err)
+	require.Len(ts.T(), entries, 2)
+}
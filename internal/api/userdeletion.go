@@ -0,0 +1,268 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/supabase/auth/internal/api/apierrors"
+	mail "github.com/supabase/auth/internal/mailer"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/storage"
+	"github.com/supabase/auth/internal/utilities"
+)
+
+// UserDeleteParams are the parameters of deleting the user's own account.
+type UserDeleteParams struct {
+	Nonce string `json:"nonce"`
+}
+
+func (a *API) requireUserDeletionEnabled(w http.ResponseWriter, r *http.Request) (context.Context, error) {
+	ctx := r.Context()
+	if !a.requestConfig(ctx).UserDeletion.Enabled {
+		return nil, apierrors.NewNotFoundError(apierrors.ErrorCodeUserDeletionDisabled, "Account deletion is disabled")
+	}
+	return ctx, nil
+}
+
+// UserDelete schedules the deletion of the user's account after the grace
+// period, signing the user out everywhere. Signing in again before the
+// deletion is due cancels it.
+func (a *API) UserDelete(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	config := a.requestConfig(ctx)
+
+	params := &UserDeleteParams{}
+	if body, _ := utilities.GetBodyBytes(r); len(body) != 0 {
+		if err := retrieveRequestParams(r, params); err != nil {
+			return err
+		}
+	}
+
+	user := getUser(ctx)
+	session := getSession(ctx)
+
+	if user.IsSSOUser {
+		return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeUserSSOManaged, "Deleting a SSO account is only possible via SSO")
+	}
+
+	if user.HasMFAEnabled() && (session == nil || !session.IsAAL2()) {
+		return apierrors.NewHTTPError(http.StatusUnauthorized, apierrors.ErrorCodeInsufficientAAL, "AAL2 session is required to delete the account when MFA is enabled.")
+	}
+
+	if params.Nonce == "" {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeReauthenticationNeeded, "Account deletion requires reauthentication")
+	}
+
+	if err := a.verifyReauthentication(params.Nonce, db, config, user); err != nil {
+		return err
+	}
+
+	deletionScheduledAt := time.Now().Add(config.UserDeletion.GracePeriod)
+
+	err := db.Transaction(func(tx *storage.Connection) error {
+		if terr := models.NewAuditLogEntry(r, tx, user, models.UserDeletionRequestedAction, "", map[string]interface{}{
+			"deletion_scheduled_at": deletionScheduledAt,
+		}); terr != nil {
+			return apierrors.NewInternalServerError("Error recording audit log entry").WithInternalError(terr)
+		}
+
+		if terr := user.ScheduleDeletion(tx, deletionScheduledAt); terr != nil {
+			return apierrors.NewInternalServerError("Database error scheduling user deletion").WithInternalError(terr)
+		}
+
+		if terr := models.Logout(tx, user.ID); terr != nil {
+			return apierrors.NewInternalServerError("Error deleting user's sessions").WithInternalError(terr)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	a.notifyUser(r, user, mail.DeletionScheduledNotification, map[string]interface{}{
+		"DeletionScheduledAt": deletionScheduledAt.UTC().Format(time.RFC1123),
+	})
+
+	return sendJSON(w, http.StatusOK, user)
+}
+
+// cancelUserDeletion cancels the scheduled deletion of the user signing in.
+func (a *API) cancelUserDeletion(r *http.Request, tx *storage.Connection, user *models.User) error {
+	if user.DeletionScheduledAt == nil {
+		return nil
+	}
+
+	if err := models.NewAuditLogEntry(r, tx, user, models.UserDeletionCancelledAction, "", nil); err != nil {
+		return apierrors.NewInternalServerError("Error recording audit log entry").WithInternalError(err)
+	}
+
+	if err := user.CancelDeletion(tx); err != nil {
+		return apierrors.NewInternalServerError("Database error cancelling user deletion").WithInternalError(err)
+	}
+
+	return nil
+}
+
+// deleteUser deletes the user, or with softDelete obfuscates its personal
+// data and deletes its identities' data, factors and sessions.
+func deleteUser(tx *storage.Connection, user *models.User, softDelete bool) error {
+	if !softDelete {
+		if err := tx.Destroy(user); err != nil {
+			return apierrors.NewInternalServerError("Database error deleting user").WithInternalError(err)
+		}
+		return nil
+	}
+
+	if user.DeletedAt != nil {
+		// user has been soft deleted already
+		return nil
+	}
+	if err := user.SoftDeleteUser(tx); err != nil {
+		return apierrors.NewInternalServerError("Error soft deleting user").WithInternalError(err)
+	}
+
+	if err := user.SoftDeleteUserIdentities(tx); err != nil {
+		return apierrors.NewInternalServerError("Error soft deleting user identities").WithInternalError(err)
+	}
+
+	// hard delete all associated factors
+	if err := models.DeleteFactorsByUserId(tx, user.ID); err != nil {
+		return apierrors.NewInternalServerError("Error deleting user's factors").WithInternalError(err)
+	}
+	// hard delete all associated sessions
+	if err := models.Logout(tx, user.ID); err != nil {
+		return apierrors.NewInternalServerError("Error deleting user's sessions").WithInternalError(err)
+	}
+
+	return nil
+}
+
+// RunUserDeletionWorker deletes the users whose scheduled deletion is due
+// until the context is done, checking at the configured interval.
+func (a *API) RunUserDeletionWorker(ctx context.Context) {
+	config := a.config
+	log := logrus.WithField("component", "user_deletion")
+
+	ticker := time.NewTicker(config.UserDeletion.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if count, err := a.DeleteScheduledUsers(ctx); err != nil && ctx.Err() == nil {
+			log.WithError(err).WithField("deleted_users", count).Warn("Error deleting scheduled users")
+		} else if count > 0 {
+			log.WithField("deleted_users", count).Info("Deleted scheduled users")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// maxUserDeletionRetryBackoff is the longest time before the deletion of a
+// user that failed is retried.
+const maxUserDeletionRetryBackoff = 24 * time.Hour
+
+// userDeletionRetryBackoff returns how long to wait before retrying the
+// deletion of a user that failed the number of times, doubling from a
+// minute up to a day.
+func userDeletionRetryBackoff(attempts int) time.Duration {
+	backoff := time.Minute
+	for i := 1; i < attempts && backoff < maxUserDeletionRetryBackoff; i++ {
+		backoff *= 2
+	}
+
+	return min(backoff, maxUserDeletionRetryBackoff)
+}
+
+// DeleteScheduledUsers deletes the users whose scheduled deletion is due, in
+// batches. Each user is claimed in its own transaction, so that several
+// workers can run at once and a user signing in meanwhile isn't deleted. A
+// user whose deletion fails is logged and retried later with a backoff,
+// without stopping the deletion of the other users. It returns the number of
+// deleted users.
+func (a *API) DeleteScheduledUsers(ctx context.Context) (int, error) {
+	config := a.config
+	db := a.db.WithContext(ctx)
+	log := logrus.WithField("component", "user_deletion")
+
+	count := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+
+		now := time.Now()
+		users, err := models.FindUsersDueForDeletion(db, now, config.UserDeletion.BatchSize)
+		if err != nil {
+			return count, err
+		}
+
+		deleted, failed := 0, 0
+		for _, due := range users {
+			ok := false
+			err := db.Transaction(func(tx *storage.Connection) error {
+				user, terr := models.ClaimUserDeletion(tx, due.ID, now)
+				if terr != nil || user == nil {
+					return terr
+				}
+
+				if terr := models.NewAuditLogEntry(nil, tx, user, models.UserDeletedAction, "", map[string]interface{}{
+					"user_id":    user.ID,
+					"user_email": user.Email,
+					"user_phone": user.Phone,
+					"scheduled":  true,
+				}); terr != nil {
+					return terr
+				}
+
+				if terr := deleteUser(tx, user, config.UserDeletion.SoftDelete); terr != nil {
+					return terr
+				}
+
+				if config.UserDeletion.SoftDelete {
+					if terr := user.CancelDeletion(tx); terr != nil {
+						return terr
+					}
+				}
+
+				ok = true
+				return nil
+			})
+			if err == nil {
+				if ok {
+					deleted++
+				}
+				continue
+			}
+
+			if ctx.Err() != nil {
+				return count + deleted, ctx.Err()
+			}
+
+			failed++
+			retryAt := time.Now().Add(userDeletionRetryBackoff(due.DeletionAttempts + 1))
+			log.WithError(err).WithFields(logrus.Fields{
+				"user_id":  due.ID,
+				"attempts": due.DeletionAttempts + 1,
+				"retry_at": retryAt,
+			}).Warn("Error deleting scheduled user, retrying later")
+
+			if terr := due.DeferDeletion(db, retryAt); terr != nil {
+				// without deferring the user it'd be found again
+				return count + deleted, terr
+			}
+		}
+
+		count += deleted
+		if len(users) < config.UserDeletion.BatchSize || deleted+failed == 0 {
+			return count, nil
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"github.com/supabase/auth/internal/api/apierrors"
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/crypto"
+	"github.com/supabase/auth/internal/models"
+)
+
+type UserDeletionTestSuite struct {
+	suite.Suite
+	API    *API
+	Config *conf.GlobalConfiguration
+}
+
+func TestUserDeletion(t *testing.T) {
+	api, config, err := setupAPIForTest()
+	require.NoError(t, err)
+
+	ts := &UserDeletionTestSuite{
+		API:    api,
+		Config: config,
+	}
+	defer api.db.Close()
+
+	suite.Run(t, ts)
+}
+
+func (ts *UserDeletionTestSuite) SetupTest() {
+	models.TruncateAll(ts.API.db)
+
+	ts.Config.UserDeletion.Enabled = true
+	ts.Config.UserDeletion.GracePeriod = 24 * time.Hour
+	ts.Config.UserDeletion.SoftDelete = false
+	ts.Config.UserDeletion.BatchSize = 100
+
+	u, err := models.NewUser("", "test@example.com", "password", ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err, "Error creating test user model")
+	now := time.Now()
+	u.EmailConfirmedAt = &now
+	require.NoError(ts.T(), ts.API.db.Create(u), "Error saving new test user")
+}
+
+func (ts *UserDeletionTestSuite) user() *models.User {
+	u, err := models.FindUserByEmailAndAudience(ts.API.db, "test@example.com", ts.Config.JWT.Aud)
+	require.NoError(ts.T(), err)
+	return u
+}
+
+func (ts *UserDeletionTestSuite) token(u *models.User) string {
+	session, err := models.NewSession(u.ID, nil)
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), ts.API.db.Create(session))
+
+	req := httptest.NewRequest(http.MethodPost, "/token?grant_type=password", nil)
+	token, _, err := ts.API.generateAccessToken(req, ts.API.db, u, &session.ID, models.PasswordGrant)
+	require.NoError(ts.T(), err)
+	return token
+}
+
+func (ts *UserDeletionTestSuite) request(method, url, token string, body interface{}) *httptest.ResponseRecorder {
+	var buffer bytes.Buffer
+	if body != nil {
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(body))
+	}
+
+	req := httptest.NewRequest(method, url, &buffer)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	}
+
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	return w
+}
+
+// scheduleDeletion deletes the test user's account with a known nonce.
+func (ts *UserDeletionTestSuite) scheduleDeletion() *models.User {
+	u := ts.user()
+	token := ts.token(u)
+
+	now := time.Now()
+	u.ReauthenticationToken = crypto.GenerateTokenHash(u.GetEmail(), "123456")
+	u.ReauthenticationSentAt = &now
+	require.NoError(ts.T(), ts.API.db.Update(u))
+
+	w := ts.request(http.MethodDelete, "http://localhost/user", token, map[string]interface{}{
+		"nonce": "123456",
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+
+	return ts.user()
+}
+
+func (ts *UserDeletionTestSuite) TestUserDelete() {
+	u := ts.user()
+	token := ts.token(u)
+
+	ts.Config.UserDeletion.Enabled = false
+	w := ts.request(http.MethodDelete, "http://localhost/user", token, nil)
+	require.Equal(ts.T(), http.StatusNotFound, w.Code)
+	ts.Config.UserDeletion.Enabled = true
+
+	w = ts.request(http.MethodDelete, "http://localhost/user", token, nil)
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+
+	data := &HTTPError{}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(data))
+	require.Equal(ts.T(), apierrors.ErrorCodeReauthenticationNeeded, data.ErrorCode)
+
+	w = ts.request(http.MethodDelete, "http://localhost/user", token, map[string]interface{}{
+		"nonce": "000000",
+	})
+	require.Equal(ts.T(), http.StatusUnprocessableEntity, w.Code)
+
+	u = ts.scheduleDeletion()
+	require.NotNil(ts.T(), u.DeletionScheduledAt)
+	require.WithinDuration(ts.T(), time.Now().Add(24*time.Hour), *u.DeletionScheduledAt, time.Minute)
+
+	sessions, err := models.FindAllSessionsForUser(ts.API.db, u.ID, false)
+	require.NoError(ts.T(), err)
+	require.Empty(ts.T(), sessions)
+}
+
+func (ts *UserDeletionTestSuite) TestSignInCancelsDeletion() {
+	ts.scheduleDeletion()
+
+	w := ts.request(http.MethodPost, "http://localhost/token?grant_type=password", "", map[string]interface{}{
+		"email":    "test@example.com",
+		"password": "password",
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+
+	require.Nil(ts.T(), ts.user().DeletionScheduledAt)
+}
+
+func (ts *UserDeletionTestSuite) TestDeleteScheduledUsers() {
+	u := ts.scheduleDeletion()
+
+	// not due yet
+	count, err := ts.API.DeleteScheduledUsers(context.Background())
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), 0, count)
+
+	require.NoError(ts.T(), u.ScheduleDeletion(ts.API.db, time.Now().Add(-time.Minute)))
+
+	count, err = ts.API.DeleteScheduledUsers(context.Background())
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), 1, count)
+
+	_, err = models.FindUserByID(ts.API.db, u.ID)
+	require.True(ts.T(), models.IsNotFoundError(err))
+}
+
+func (ts *UserDeletionTestSuite) TestDeleteScheduledUsersSkipsDeferred() {
+	u := ts.scheduleDeletion()
+	require.NoError(ts.T(), u.ScheduleDeletion(ts.API.db, time.Now().Add(-time.Minute)))
+	require.NoError(ts.T(), u.DeferDeletion(ts.API.db, time.Now().Add(time.Minute)))
+
+	// retried later
+	count, err := ts.API.DeleteScheduledUsers(context.Background())
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), 0, count)
+
+	require.NoError(ts.T(), u.DeferDeletion(ts.API.db, time.Now().Add(-time.Second)))
+
+	count, err = ts.API.DeleteScheduledUsers(context.Background())
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), 1, count)
+}
+
+func (ts *UserDeletionTestSuite) TestDeleteScheduledUsersSoftDelete() {
+	ts.Config.UserDeletion.SoftDelete = true
+
+	u := ts.scheduleDeletion()
+	require.NoError(ts.T(), u.ScheduleDeletion(ts.API.db, time.Now().Add(-time.Minute)))
+
+	count, err := ts.API.DeleteScheduledUsers(context.Background())
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), 1, count)
+
+	u, err = models.FindUserByID(ts.API.db, u.ID)
+	require.NoError(ts.T(), err)
+	require.NotNil(ts.T(), u.DeletedAt)
+	require.Nil(ts.T(), u.DeletionScheduledAt)
+	require.Empty(ts.T(), u.GetEmail())
+}
+
+func (ts *UserDeletionTestSuite) TestUserExport() {
+	u := ts.user()
+	require.NoError(ts.T(), models.NewAuditLogEntry(nil, ts.API.db, u, models.LoginAction, "", nil))
+
+	admin, err := models.NewUser("", "admin@example.com", "password", ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), ts.API.db.Create(admin))
+	require.NoError(ts.T(), models.NewAuditLogEntry(nil, ts.API.db, admin, models.UserModifiedAction, "", map[string]interface{}{
+		"user_id": u.ID,
+	}))
+	require.NoError(ts.T(), models.NewAuditLogEntry(nil, ts.API.db, admin, models.LoginAction, "", nil))
+
+	w := ts.request(http.MethodGet, "http://localhost/user/export", ts.token(u), nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+	require.Contains(ts.T(), w.Header().Get("Content-Disposition"), "user.json")
+
+	data := &UserExportResponse{}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(data))
+	require.Equal(ts.T(), u.ID, data.User.ID)
+	require.Len(ts.T(), data.Identities, len(u.Identities))
+	require.Len(ts.T(), data.Sessions, 1)
+
+	// the login, the admin's update and the export itself
+	require.Len(ts.T(), data.AuditLogEntries, 3)
+	require.Equal(ts.T(), string(models.UserModifiedAction), data.AuditLogEntries[1].Payload["action"])
+	require.Equal(ts.T(), string(models.UserDataExportedAction), data.AuditLogEntries[2].Payload["action"])
+}
+
+func TestUserDeletionRetryBackoff(t *testing.T) {
+	require.Equal(t, time.Minute, userDeletionRetryBackoff(1))
+	require.Equal(t, 2*time.Minute, userDeletionRetryBackoff(2))
+	require.Equal(t, 8*time.Minute, userDeletionRetryBackoff(4))
+	require.Equal(t, 24*time.Hour, userDeletionRetryBackoff(20))
+	require.Equal(t, 24*time.Hour, userDeletionRetryBackoff(1000))
+}
@@ -0,0 +1,272 @@
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/supabase/auth/internal/api/apierrors"
+	"github.com/supabase/auth/internal/crypto"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/observability"
+)
+
+// userExportBatchSize is the number of users read at once while exporting.
+const userExportBatchSize = 1000
+
+// ExportUsers writes the users of the audience to w in the format of
+// imports, reading them in batches so that exports of any size use little
+// memory. With includePasswordHashes, password hashes are exported too, and
+// decrypted if they're encrypted in the database, so that they can be
+// imported elsewhere. It returns the number of exported users.
+func (a *API) ExportUsers(ctx context.Context, aud, format string, w io.Writer, includePasswordHashes bool) (int, error) {
+	db := a.db.WithContext(ctx)
+
+	var writeRecord func(record *UserImportRecord) error
+	var flush func() error
+
+	switch format {
+	case UserImportFormatNDJSON:
+		encoder := json.NewEncoder(w)
+		writeRecord = func(record *UserImportRecord) error {
+			return encoder.Encode(record)
+		}
+		flush = func() error { return nil }
+
+	case UserImportFormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(userImportCSVColumns); err != nil {
+			return 0, err
+		}
+		writeRecord = func(record *UserImportRecord) error {
+			fields, err := userExportCSVFields(record)
+			if err != nil {
+				return err
+			}
+			return writer.Write(fields)
+		}
+		flush = func() error {
+			writer.Flush()
+			return writer.Error()
+		}
+
+	default:
+		return 0, apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Format must be ndjson or csv")
+	}
+
+	count := 0
+	afterID := uuid.Nil
+	for {
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+
+		users, err := models.FindUsersInAudienceAfterID(db, aud, afterID, userExportBatchSize)
+		if err != nil {
+			return count, err
+		}
+
+		for _, user := range users {
+			record, err := a.userExportRecord(user, includePasswordHashes)
+			if err != nil {
+				return count, err
+			}
+			if err := writeRecord(record); err != nil {
+				return count, err
+			}
+			count++
+		}
+
+		if err := flush(); err != nil {
+			return count, err
+		}
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+
+		if len(users) < userExportBatchSize {
+			return count, nil
+		}
+		afterID = users[len(users)-1].ID
+	}
+}
+
+// userExportRecord returns the record of the user in exports.
+func (a *API) userExportRecord(user *models.User, includePasswordHash bool) (*UserImportRecord, error) {
+	config := a.config
+
+	record := &UserImportRecord{
+		ID:               user.ID.String(),
+		Email:            user.GetEmail(),
+		Phone:            user.GetPhone(),
+		Role:             user.Role,
+		EmailConfirmedAt: user.EmailConfirmedAt,
+		PhoneConfirmedAt: user.PhoneConfirmedAt,
+		UserMetaData:     user.UserMetaData,
+		AppMetaData:      user.AppMetaData,
+		CreatedAt:        &user.CreatedAt,
+	}
+
+	if includePasswordHash && user.HasPassword() {
+		hash := *user.EncryptedPassword
+		if es := crypto.ParseEncryptedString(hash); es != nil {
+			decrypted, err := es.Decrypt(user.ID.String(), config.Security.DBEncryption.DecryptionKeys)
+			if err != nil {
+				return nil, err
+			}
+			hash = string(decrypted)
+		}
+		record.PasswordHash = hash
+	}
+
+	for _, identity := range user.Identities {
+		record.Identities = append(record.Identities, UserImportIdentity{
+			Provider:     identity.Provider,
+			ProviderID:   identity.ProviderID,
+			IdentityData: identity.IdentityData,
+		})
+	}
+
+	return record, nil
+}
+
+func userExportCSVFields(record *UserImportRecord) ([]string, error) {
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+
+	formatJSON := func(v interface{}, empty bool) (string, error) {
+		if empty {
+			return "", nil
+		}
+		data, err := json.Marshal(v)
+		return string(data), err
+	}
+
+	userMetaData, err := formatJSON(record.UserMetaData, len(record.UserMetaData) == 0)
+	if err != nil {
+		return nil, err
+	}
+	appMetaData, err := formatJSON(record.AppMetaData, len(record.AppMetaData) == 0)
+	if err != nil {
+		return nil, err
+	}
+	identities, err := formatJSON(record.Identities, len(record.Identities) == 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// in the order of userImportCSVColumns
+	return []string{
+		record.ID,
+		record.Email,
+		record.Phone,
+		record.PasswordHash,
+		record.Role,
+		formatTime(record.EmailConfirmedAt),
+		formatTime(record.PhoneConfirmedAt),
+		userMetaData,
+		appMetaData,
+		identities,
+		formatTime(record.CreatedAt),
+	}, nil
+}
+
+// adminUsersExport streams the users of the audience as NDJSON or CSV.
+func (a *API) adminUsersExport(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	adminUser := getAdminUser(ctx)
+
+	format, err := userImportFormat(r, r.Header.Get("Accept"))
+	if err != nil {
+		return err
+	}
+
+	aud := a.requestAud(ctx, r)
+	if queryAud := r.URL.Query().Get("aud"); queryAud != "" {
+		aud = queryAud
+	}
+
+	includePasswordHashes := r.URL.Query().Get("include_password_hashes") == "true"
+
+	if err := models.NewAuditLogEntry(r, db, adminUser, models.UsersExportedAction, "", map[string]interface{}{
+		"aud":                     aud,
+		"format":                  format,
+		"include_password_hashes": includePasswordHashes,
+	}); err != nil {
+		return apierrors.NewInternalServerError("Database error recording audit log entry").WithInternalError(err)
+	}
+
+	contentType := "application/x-ndjson"
+	if format == UserImportFormatCSV {
+		contentType = "text/csv"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", "attachment; filename=\"users."+format+"\"")
+	w.WriteHeader(http.StatusOK)
+
+	// the status was sent, so errors can only truncate the export
+	if count, err := a.ExportUsers(ctx, aud, format, w, includePasswordHashes); err != nil {
+		observability.GetLogEntry(r).Entry.WithError(err).WithField("exported_users", count).Warn("Error exporting users")
+	}
+
+	return nil
+}
+
+// UserExportResponse is the data held about a user, for data portability.
+type UserExportResponse struct {
+	User            *models.User            `json:"user"`
+	Identities      []models.Identity       `json:"identities"`
+	Factors         []models.Factor         `json:"factors"`
+	Sessions        []*models.Session       `json:"sessions"`
+	AuditLogEntries []*models.AuditLogEntry `json:"audit_log_entries"`
+	ExportedAt      time.Time               `json:"exported_at"`
+}
+
+// UserExport returns the user's account, identities, factors, sessions and
+// audit log entries as a JSON attachment.
+func (a *API) UserExport(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	user, err := models.FindUserByID(db, getUser(ctx).ID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return apierrors.NewNotFoundError(apierrors.ErrorCodeUserNotFound, "User not found")
+		}
+		return apierrors.NewInternalServerError("Database error finding user").WithInternalError(err)
+	}
+
+	sessions, err := models.FindAllSessionsForUser(db, user.ID, false)
+	if err != nil {
+		return apierrors.NewInternalServerError("Database error finding sessions").WithInternalError(err)
+	}
+
+	if err := models.NewAuditLogEntry(r, db, user, models.UserDataExportedAction, "", nil); err != nil {
+		return apierrors.NewInternalServerError("Database error recording audit log entry").WithInternalError(err)
+	}
+
+	entries, err := models.FindAuditLogEntriesByUserID(db, user.ID)
+	if err != nil {
+		return apierrors.NewInternalServerError("Database error finding audit log entries").WithInternalError(err)
+	}
+
+	w.Header().Set("Content-Disposition", "attachment; filename=\"user.json\"")
+
+	return sendJSON(w, http.StatusOK, &UserExportResponse{
+		User:            user,
+		Identities:      user.Identities,
+		Factors:         user.Factors,
+		Sessions:        sessions,
+		AuditLogEntries: entries,
+		ExportedAt:      time.Now(),
+	})
+}
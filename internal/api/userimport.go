@@ -0,0 +1,698 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/structs"
+	"github.com/go-chi/chi/v5"
+	"github.com/gofrs/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/supabase/auth/internal/api/apierrors"
+	"github.com/supabase/auth/internal/api/provider"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// Formats of bulk user imports and exports.
+const (
+	UserImportFormatNDJSON = "ndjson"
+	UserImportFormatCSV    = "csv"
+)
+
+// userImportUploadBatchSize is the number of rows stored at once while an
+// import is uploaded.
+const userImportUploadBatchSize = 500
+
+// userImportMaxLineSize is the maximum size of a line of an NDJSON import.
+const userImportMaxLineSize = 1024 * 1024
+
+// userImportCSVColumns are the columns of CSV imports and exports. Metadata
+// and identities are JSON encoded.
+var userImportCSVColumns = []string{
+	"id",
+	"email",
+	"phone",
+	"password_hash",
+	"role",
+	"email_confirmed_at",
+	"phone_confirmed_at",
+	"user_metadata",
+	"app_metadata",
+	"identities",
+	"created_at",
+}
+
+// UserImportRecord is a user in bulk imports and exports.
+type UserImportRecord struct {
+	ID           string `json:"id,omitempty"`
+	Email        string `json:"email,omitempty"`
+	Phone        string `json:"phone,omitempty"`
+	PasswordHash string `json:"password_hash,omitempty"`
+	Role         string `json:"role,omitempty"`
+
+	// EmailConfirm and PhoneConfirm confirm the user at the time of the
+	// import, if the time of the confirmation isn't known.
+	EmailConfirmedAt *time.Time `json:"email_confirmed_at,omitempty"`
+	PhoneConfirmedAt *time.Time `json:"phone_confirmed_at,omitempty"`
+	EmailConfirm     bool       `json:"email_confirm,omitempty"`
+	PhoneConfirm     bool       `json:"phone_confirm,omitempty"`
+
+	UserMetaData map[string]interface{} `json:"user_metadata,omitempty"`
+	AppMetaData  map[string]interface{} `json:"app_metadata,omitempty"`
+	Identities   []UserImportIdentity   `json:"identities,omitempty"`
+	CreatedAt    *time.Time             `json:"created_at,omitempty"`
+}
+
+// UserImportIdentity is an identity of a user in bulk imports and exports.
+type UserImportIdentity struct {
+	Provider     string                 `json:"provider"`
+	ProviderID   string                 `json:"provider_id"`
+	IdentityData map[string]interface{} `json:"identity_data,omitempty"`
+}
+
+// AdminUserImportErrorsResponse is the response of listing the failed rows
+// of an import.
+type AdminUserImportErrorsResponse struct {
+	Errors []*models.UserImportRow `json:"errors"`
+}
+
+// userImportRowError is an error importing a row, which fails the row
+// rather than the import.
+type userImportRowError struct {
+	message string
+}
+
+func (e *userImportRowError) Error() string {
+	return e.message
+}
+
+func newUserImportRowError(format string, args ...interface{}) error {
+	return &userImportRowError{message: fmt.Sprintf(format, args...)}
+}
+
+// userImportFormat returns the format of an import or export request, from
+// the format query parameter or the content type.
+func userImportFormat(r *http.Request, contentType string) (string, error) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		if strings.HasPrefix(contentType, "text/csv") {
+			format = UserImportFormatCSV
+		} else {
+			format = UserImportFormatNDJSON
+		}
+	}
+
+	switch format {
+	case UserImportFormatNDJSON, UserImportFormatCSV:
+		return format, nil
+	}
+
+	return "", apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Format must be ndjson or csv")
+}
+
+// readUserImport reads the records of an import, calling fn with each record
+// or the error parsing it. Errors of the input as a whole are returned.
+func readUserImport(r io.Reader, format string, fn func(line int, record *UserImportRecord, err error) error) error {
+	switch format {
+	case UserImportFormatNDJSON:
+		return readUserImportNDJSON(r, fn)
+
+	case UserImportFormatCSV:
+		return readUserImportCSV(r, fn)
+	}
+
+	return fmt.Errorf("unsupported user import format %q", format)
+}
+
+func readUserImportNDJSON(r io.Reader, fn func(line int, record *UserImportRecord, err error) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), userImportMaxLineSize)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+
+		data := strings.TrimSpace(scanner.Text())
+		if data == "" {
+			continue
+		}
+
+		record := &UserImportRecord{}
+		if err := json.Unmarshal([]byte(data), record); err != nil {
+			if err := fn(line, nil, newUserImportRowError("Invalid JSON: %v", err)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := fn(line, record, nil); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("line %d: %w", line+1, err)
+	}
+
+	return nil
+}
+
+func readUserImportCSV(r io.Reader, fn func(line int, record *UserImportRecord, err error) error) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, column := range header {
+		column = strings.TrimSpace(column)
+		if !isStringInSlice(column, userImportCSVColumns) && column != "email_confirm" && column != "phone_confirm" {
+			return fmt.Errorf("unknown column %q", column)
+		}
+		columns[column] = i
+	}
+
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+
+		line, _ := reader.FieldPos(0)
+
+		if err != nil {
+			if _, ok := err.(*csv.ParseError); !ok {
+				return err
+			}
+			if err := fn(line, nil, newUserImportRowError("Invalid CSV: %v", err)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		record, err := parseUserImportCSVRecord(columns, fields)
+		if err := fn(line, record, err); err != nil {
+			return err
+		}
+	}
+}
+
+func parseUserImportCSVRecord(columns map[string]int, fields []string) (*UserImportRecord, error) {
+	field := func(column string) string {
+		if i, ok := columns[column]; ok && i < len(fields) {
+			return strings.TrimSpace(fields[i])
+		}
+		return ""
+	}
+
+	record := &UserImportRecord{
+		ID:           field("id"),
+		Email:        field("email"),
+		Phone:        field("phone"),
+		PasswordHash: field("password_hash"),
+		Role:         field("role"),
+	}
+
+	for column, dest := range map[string]**time.Time{
+		"email_confirmed_at": &record.EmailConfirmedAt,
+		"phone_confirmed_at": &record.PhoneConfirmedAt,
+		"created_at":         &record.CreatedAt,
+	} {
+		if value := field(column); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return nil, newUserImportRowError("Invalid %s: %v", column, err)
+			}
+			*dest = &t
+		}
+	}
+
+	for column, dest := range map[string]*bool{
+		"email_confirm": &record.EmailConfirm,
+		"phone_confirm": &record.PhoneConfirm,
+	} {
+		if value := field(column); value != "" {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, newUserImportRowError("Invalid %s: %v", column, err)
+			}
+			*dest = b
+		}
+	}
+
+	for column, dest := range map[string]interface{}{
+		"user_metadata": &record.UserMetaData,
+		"app_metadata":  &record.AppMetaData,
+		"identities":    &record.Identities,
+	} {
+		if value := field(column); value != "" {
+			if err := json.Unmarshal([]byte(value), dest); err != nil {
+				return nil, newUserImportRowError("Invalid %s: %v", column, err)
+			}
+		}
+	}
+
+	return record, nil
+}
+
+// ImportUsers stores the records read from r as a new import job and starts
+// the job, so that it is processed in the background. Rows that can't be
+// parsed, or with one of the admin roles unless allowAdminRoles, are stored as
+// failed.
+func (a *API) ImportUsers(ctx context.Context, aud, format string, r io.Reader, allowAdminRoles bool) (*models.UserImportJob, error) {
+	db := a.db.WithContext(ctx)
+
+	job, err := models.NewUserImportJob(db, aud, format)
+	if err != nil {
+		return nil, apierrors.NewInternalServerError("Database error creating user import").WithInternalError(err)
+	}
+
+	batch := make([]*models.UserImportRow, 0, userImportUploadBatchSize)
+	flush := func() error {
+		if err := db.Transaction(func(tx *storage.Connection) error {
+			return models.AddUserImportRows(tx, job, batch)
+		}); err != nil {
+			return apierrors.NewInternalServerError("Database error storing user import").WithInternalError(err)
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	if err := readUserImport(r, format, func(line int, record *UserImportRecord, rerr error) error {
+		row := &models.UserImportRow{Line: line}
+		if rerr == nil && !allowAdminRoles && isStringInSlice(record.Role, a.config.JWT.AdminRoles) {
+			rerr = newUserImportRowError("Assigning the %s role requires the %s admin permission", record.Role, AdminPermissionAdminRolesAssign)
+		}
+		if rerr != nil {
+			message := rerr.Error()
+			row.Error = &message
+		} else {
+			data, err := recordToJSONMap(record)
+			if err != nil {
+				return err
+			}
+			row.Data = data
+		}
+
+		batch = append(batch, row)
+		if len(batch) >= userImportUploadBatchSize {
+			return flush()
+		}
+		return nil
+	}); err != nil {
+		if _, ok := err.(*HTTPError); ok {
+			return nil, err
+		}
+		return nil, apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Unable to read user import: %v", err).WithInternalError(err)
+	}
+
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	if err := job.Start(db); err != nil {
+		return nil, apierrors.NewInternalServerError("Database error starting user import").WithInternalError(err)
+	}
+
+	return job, nil
+}
+
+func recordToJSONMap(record *UserImportRecord) (models.JSONMap, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+
+	m := models.JSONMap{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// RunUserImportWorker processes pending import jobs until the context is
+// done, polling for new jobs at the configured interval.
+func (a *API) RunUserImportWorker(ctx context.Context) {
+	config := a.config
+	log := logrus.WithField("component", "user_import")
+
+	ticker := time.NewTicker(config.UserImport.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := a.ProcessUserImports(ctx); err != nil && ctx.Err() == nil {
+			log.WithError(err).Warn("Error processing user imports")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessUserImports processes all pending import jobs, oldest first.
+func (a *API) ProcessUserImports(ctx context.Context) error {
+	db := a.db.WithContext(ctx)
+
+	jobs, err := models.FindPendingUserImportJobs(db)
+	if err != nil {
+		return err
+	}
+
+	for _, job := range jobs {
+		if err := a.ProcessUserImportJob(ctx, job); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ProcessUserImportJob imports the pending rows of the job until none are
+// left, then completes it. Rows are claimed one by one, so that several
+// workers can process the same job, and processing resumes from the
+// pending rows if it is interrupted.
+func (a *API) ProcessUserImportJob(ctx context.Context, job *models.UserImportJob) error {
+	config := a.config
+	db := a.db.WithContext(ctx)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rows, err := models.FindPendingUserImportRows(db, job.ID, config.UserImport.BatchSize)
+		if err != nil {
+			return err
+		}
+
+		if len(rows) == 0 {
+			_, err := models.CompleteUserImportJob(db, job.ID)
+			return err
+		}
+
+		processed := false
+		for _, row := range rows {
+			claimed, err := a.processUserImportRow(db, job, row)
+			if err != nil {
+				return err
+			}
+			processed = processed || claimed
+		}
+
+		if !processed {
+			// the remaining rows are being processed by another
+			// worker, which completes the job
+			return nil
+		}
+	}
+}
+
+// processUserImportRow imports the row unless another worker claimed it,
+// returning whether it was claimed.
+func (a *API) processUserImportRow(db *storage.Connection, job *models.UserImportJob, row *models.UserImportRow) (bool, error) {
+	claimed := false
+	err := db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		claimed, terr = models.ClaimUserImportRow(tx, row)
+		if terr != nil || !claimed {
+			return terr
+		}
+
+		record := &UserImportRecord{}
+		if terr := decodeJSONMap(row.Data, record); terr != nil {
+			return newUserImportRowError("Invalid row: %v", terr)
+		}
+
+		if terr := a.importUser(tx, job.Aud, record); terr != nil {
+			return terr
+		}
+
+		return row.MarkImported(tx)
+	})
+	if err == nil || !claimed {
+		return claimed, err
+	}
+
+	message := err.Error()
+	if _, ok := err.(*userImportRowError); !ok {
+		if herr, ok := err.(*HTTPError); ok {
+			message = herr.Message
+		} else {
+			message = "Database error importing user"
+			logrus.WithField("component", "user_import").WithError(err).WithField("line", row.Line).Warn("Error importing user")
+		}
+	}
+
+	// failing to record the error, for example while the database is
+	// unavailable, stops processing so that the row is retried later
+	return true, db.Transaction(func(tx *storage.Connection) error {
+		return row.MarkFailed(tx, message)
+	})
+}
+
+func decodeJSONMap(m models.JSONMap, v interface{}) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// importUser creates the user of the record, along with its identities.
+func (a *API) importUser(tx *storage.Connection, aud string, record *UserImportRecord) error {
+	config := a.config
+
+	var err error
+	if record.Email == "" && record.Phone == "" {
+		return newUserImportRowError("Cannot import a user without either an email or phone")
+	}
+
+	if record.Email != "" {
+		if record.Email, err = a.validateEmail(record.Email); err != nil {
+			return err
+		}
+		if user, err := models.IsDuplicatedEmail(tx, record.Email, aud, nil); err != nil {
+			return err
+		} else if user != nil {
+			return newUserImportRowError("%s", DuplicateEmailMsg)
+		}
+	}
+
+	if record.Phone != "" {
+		if record.Phone, err = validatePhone(record.Phone); err != nil {
+			return err
+		}
+		if exists, err := models.IsDuplicatedPhone(tx, record.Phone, aud); err != nil {
+			return err
+		} else if exists {
+			return newUserImportRowError("Phone number already registered by another user")
+		}
+	}
+
+	var user *models.User
+	if record.PasswordHash != "" {
+		user, err = models.NewUserWithPasswordHash(record.Phone, record.Email, record.PasswordHash, aud, record.UserMetaData)
+		if err != nil {
+			return newUserImportRowError("Invalid password_hash: %v", err)
+		}
+	} else {
+		user, err = models.NewUser(record.Phone, record.Email, "", aud, record.UserMetaData)
+		if err != nil {
+			return err
+		}
+	}
+
+	if user.UserMetaData == nil {
+		user.UserMetaData = models.JSONMap{}
+	}
+
+	if record.ID != "" {
+		id, err := uuid.FromString(record.ID)
+		if err != nil || id == uuid.Nil {
+			return newUserImportRowError("ID must be a non-nil UUID")
+		}
+		if _, err := models.FindUserByID(tx, id); err == nil {
+			return newUserImportRowError("A user with this ID already exists")
+		} else if !models.IsNotFoundError(err) {
+			return err
+		}
+		user.ID = id
+	}
+
+	user.Role = config.JWT.DefaultGroupName
+	if record.Role != "" {
+		user.Role = record.Role
+	}
+
+	now := time.Now()
+	user.EmailConfirmedAt = record.EmailConfirmedAt
+	if user.EmailConfirmedAt == nil && record.EmailConfirm {
+		user.EmailConfirmedAt = &now
+	}
+	user.PhoneConfirmedAt = record.PhoneConfirmedAt
+	if user.PhoneConfirmedAt == nil && record.PhoneConfirm {
+		user.PhoneConfirmedAt = &now
+	}
+	if record.CreatedAt != nil {
+		user.CreatedAt = *record.CreatedAt
+	}
+
+	user.AppMetaData = models.JSONMap{}
+	for key, value := range record.AppMetaData {
+		user.AppMetaData[key] = value
+	}
+
+	if err := tx.Create(user); err != nil {
+		return err
+	}
+
+	if user.HasPassword() {
+		if err := a.recordPasswordHistory(tx, user); err != nil {
+			return err
+		}
+	}
+
+	hasIdentity := map[string]bool{}
+	for _, identity := range record.Identities {
+		if identity.Provider == "" || identity.ProviderID == "" {
+			return newUserImportRowError("Identities require a provider and provider_id")
+		}
+		if _, err := models.FindIdentityByIdAndProvider(tx, identity.ProviderID, identity.Provider); err == nil {
+			return newUserImportRowError("A %s identity with provider_id %s already exists", identity.Provider, identity.ProviderID)
+		} else if !models.IsNotFoundError(err) {
+			return err
+		}
+
+		identityData := map[string]interface{}{}
+		for key, value := range identity.IdentityData {
+			identityData[key] = value
+		}
+		identityData["sub"] = identity.ProviderID
+
+		if _, err := a.createNewIdentity(tx, user, identity.Provider, identityData); err != nil {
+			return err
+		}
+		hasIdentity[identity.Provider] = true
+	}
+
+	if user.GetEmail() != "" && !hasIdentity["email"] {
+		if _, err := a.createNewIdentity(tx, user, "email", structs.Map(provider.Claims{
+			Subject: user.ID.String(),
+			Email:   user.GetEmail(),
+		})); err != nil {
+			return err
+		}
+	}
+
+	if user.GetPhone() != "" && !hasIdentity["phone"] {
+		if _, err := a.createNewIdentity(tx, user, "phone", structs.Map(provider.Claims{
+			Subject: user.ID.String(),
+			Phone:   user.GetPhone(),
+		})); err != nil {
+			return err
+		}
+	}
+
+	return user.UpdateAppMetaDataProviders(tx)
+}
+
+func (a *API) loadUserImportJob(w http.ResponseWriter, r *http.Request) (context.Context, error) {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	jobID, err := uuid.FromString(chi.URLParam(r, "job_id"))
+	if err != nil {
+		return nil, apierrors.NewNotFoundError(apierrors.ErrorCodeValidationFailed, "job_id must be an UUID")
+	}
+
+	job, err := models.FindUserImportJobByID(db, jobID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return nil, apierrors.NewNotFoundError(apierrors.ErrorCodeUserImportNotFound, "User import not found")
+		}
+		return nil, apierrors.NewInternalServerError("Database error loading user import").WithInternalError(err)
+	}
+
+	return withUserImportJob(ctx, job), nil
+}
+
+// adminUsersImport stores an NDJSON or CSV upload of users as an import job,
+// which is processed in the background.
+func (a *API) adminUsersImport(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	adminUser := getAdminUser(ctx)
+
+	format, err := userImportFormat(r, r.Header.Get("Content-Type"))
+	if err != nil {
+		return err
+	}
+
+	aud := a.requestAud(ctx, r)
+	if queryAud := r.URL.Query().Get("aud"); queryAud != "" {
+		aud = queryAud
+	}
+
+	allowAdminRoles := getAdminPermissions(ctx).Has(AdminPermissionAdminRolesAssign)
+
+	job, err := a.ImportUsers(ctx, aud, format, r.Body, allowAdminRoles)
+	if err != nil {
+		return err
+	}
+
+	if err := models.NewAuditLogEntry(r, db, adminUser, models.UserImportStartedAction, "", map[string]interface{}{
+		"job_id":     job.ID,
+		"total_rows": job.TotalRows,
+	}); err != nil {
+		return apierrors.NewInternalServerError("Database error recording audit log entry").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusAccepted, job)
+}
+
+// adminUsersImportGet returns the progress of an import job.
+func (a *API) adminUsersImportGet(w http.ResponseWriter, r *http.Request) error {
+	return sendJSON(w, http.StatusOK, getUserImportJob(r.Context()))
+}
+
+// adminUsersImportErrors lists the failed rows of an import job with their
+// errors.
+func (a *API) adminUsersImportErrors(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	job := getUserImportJob(ctx)
+
+	pageParams, err := paginate(r)
+	if err != nil {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "Bad Pagination Parameters: %v", err).WithInternalError(err)
+	}
+
+	rows, err := models.FindFailedUserImportRows(db, job.ID, pageParams)
+	if err != nil {
+		return apierrors.NewInternalServerError("Database error finding user import errors").WithInternalError(err)
+	}
+	addPaginationHeaders(w, r, pageParams)
+
+	return sendJSON(w, http.StatusOK, AdminUserImportErrorsResponse{
+		Errors: rows,
+	})
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type userImportLine struct {
+	line   int
+	record *UserImportRecord
+	err    error
+}
+
+func readUserImportLines(t *testing.T, format, input string) []userImportLine {
+	var lines []userImportLine
+	require.NoError(t, readUserImport(strings.NewReader(input), format, func(line int, record *UserImportRecord, err error) error {
+		lines = append(lines, userImportLine{line: line, record: record, err: err})
+		return nil
+	}))
+	return lines
+}
+
+func TestReadUserImportNDJSON(t *testing.T) {
+	input := `{"email": "a@example.com", "password_hash": "$2a$10$abc", "email_confirm": true, "user_metadata": {"name": "A"}}
+
+{"email": "b@example.com", "identities": [{"provider": "google", "provider_id": "123"}]}
+not json
+`
+
+	lines := readUserImportLines(t, UserImportFormatNDJSON, input)
+	require.Len(t, lines, 3)
+
+	require.Equal(t, 1, lines[0].line)
+	require.NoError(t, lines[0].err)
+	require.Equal(t, "a@example.com", lines[0].record.Email)
+	require.Equal(t, "$2a$10$abc", lines[0].record.PasswordHash)
+	require.True(t, lines[0].record.EmailConfirm)
+	require.Equal(t, "A", lines[0].record.UserMetaData["name"])
+
+	// empty lines are skipped, but still counted
+	require.Equal(t, 3, lines[1].line)
+	require.Equal(t, []UserImportIdentity{{Provider: "google", ProviderID: "123"}}, lines[1].record.Identities)
+
+	require.Equal(t, 4, lines[2].line)
+	require.Error(t, lines[2].err)
+	require.IsType(t, &userImportRowError{}, lines[2].err)
+}
+
+func TestReadUserImportCSV(t *testing.T) {
+	input := `email,phone,email_confirmed_at,email_confirm,user_metadata
+a@example.com,,2024-01-02T03:04:05Z,,"{""name"": ""A""}"
+b@example.com,12345678,,true,
+c@example.com,,yesterday,,
+`
+
+	lines := readUserImportLines(t, UserImportFormatCSV, input)
+	require.Len(t, lines, 3)
+
+	require.Equal(t, 2, lines[0].line)
+	require.NoError(t, lines[0].err)
+	require.Equal(t, "a@example.com", lines[0].record.Email)
+	require.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), *lines[0].record.EmailConfirmedAt)
+	require.Equal(t, "A", lines[0].record.UserMetaData["name"])
+
+	require.Equal(t, 3, lines[1].line)
+	require.Equal(t, "12345678", lines[1].record.Phone)
+	require.True(t, lines[1].record.EmailConfirm)
+
+	require.Equal(t, 4, lines[2].line)
+	require.Error(t, lines[2].err)
+
+	err := readUserImport(strings.NewReader("email,unknown\n"), UserImportFormatCSV, func(int, *UserImportRecord, error) error {
+		return nil
+	})
+	require.Error(t, err)
+}
+
+func TestUserExportCSVRoundTrip(t *testing.T) {
+	confirmedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	record := &UserImportRecord{
+		ID:               "7f6c4a9e-3b3e-4b8a-9d0e-0c6a7d5f1b2c",
+		Email:            "a@example.com",
+		PasswordHash:     "$2a$10$abc",
+		Role:             "authenticated",
+		EmailConfirmedAt: &confirmedAt,
+		UserMetaData:     map[string]interface{}{"name": "A, B"},
+		Identities:       []UserImportIdentity{{Provider: "google", ProviderID: "123"}},
+		CreatedAt:        &confirmedAt,
+	}
+
+	fields, err := userExportCSVFields(record)
+	require.NoError(t, err)
+	require.Len(t, fields, len(userImportCSVColumns))
+
+	var buffer bytes.Buffer
+	buffer.WriteString(strings.Join(userImportCSVColumns, ",") + "\n")
+	for i, field := range fields {
+		if i > 0 {
+			buffer.WriteString(",")
+		}
+		buffer.WriteString(`"` + strings.ReplaceAll(field, `"`, `""`) + `"`)
+	}
+	buffer.WriteString("\n")
+
+	lines := readUserImportLines(t, UserImportFormatCSV, buffer.String())
+	require.Len(t, lines, 1)
+	require.NoError(t, lines[0].err)
+	require.Equal(t, record, lines[0].record)
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/supabase/auth/internal/models"
+)
+
+// userSearchAppMetaDataPrefix prefixes the query parameters filtering users
+// by app metadata, as in `app_metadata.plan=pro` or
+// `app_metadata.org.id=42` for nested keys.
+const userSearchAppMetaDataPrefix = "app_metadata."
+
+// userSearchParams returns the structured user search filters of the query.
+func userSearchParams(r *http.Request) (*models.UserSearchParams, error) {
+	query := r.URL.Query()
+
+	params := &models.UserSearchParams{
+		Filter:      query.Get("filter"),
+		Email:       strings.TrimSpace(query.Get("email")),
+		EmailPrefix: strings.TrimSpace(query.Get("email_prefix")),
+		// phone numbers are stored without the leading +
+		Phone:       strings.TrimPrefix(strings.TrimSpace(query.Get("phone")), "+"),
+		PhonePrefix: strings.TrimPrefix(strings.TrimSpace(query.Get("phone_prefix")), "+"),
+		Provider:    query.Get("provider"),
+	}
+
+	bools := map[string]**bool{
+		"confirmed":   &params.Confirmed,
+		"banned":      &params.Banned,
+		"anonymous":   &params.Anonymous,
+		"mfa_enabled": &params.MFAEnabled,
+	}
+	for name, dest := range bools {
+		if value := query.Get(name); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be true or false", name)
+			}
+			*dest = &parsed
+		}
+	}
+
+	times := map[string]**time.Time{
+		"created_after":       &params.CreatedAfter,
+		"created_before":      &params.CreatedBefore,
+		"last_sign_in_after":  &params.LastSignInAfter,
+		"last_sign_in_before": &params.LastSignInBefore,
+	}
+	for name, dest := range times {
+		if value := query.Get(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+			}
+			*dest = &parsed
+		}
+	}
+
+	for key, values := range query {
+		if !strings.HasPrefix(key, userSearchAppMetaDataPrefix) {
+			continue
+		}
+
+		path := strings.Split(strings.TrimPrefix(key, userSearchAppMetaDataPrefix), ".")
+		for _, element := range path {
+			if element == "" {
+				return nil, fmt.Errorf("%s is not a valid app metadata path", key)
+			}
+		}
+
+		for _, value := range values {
+			params.AppMetaData = append(params.AppMetaData, models.AppMetaDataFilter{
+				Path:  path,
+				Value: value,
+			})
+		}
+	}
+
+	return params, nil
+}
+
+// userCountMode returns how the users matching a search are counted, which
+// is estimated by default.
+func userCountMode(r *http.Request) (models.UserCountMode, error) {
+	switch mode := models.UserCountMode(r.URL.Query().Get("count")); mode {
+	case "":
+		return models.UserCountEstimated, nil
+	case models.UserCountExact, models.UserCountEstimated, models.UserCountNone:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("count must be exact, estimated or none")
+	}
+}
+
+func encodeUserSearchCursor(cursor *models.UserSearchCursor) string {
+	if cursor == nil {
+		return ""
+	}
+
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		panic(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeUserSearchCursor decodes the cursor returned with the previous page.
+// An empty cursor starts from the first page.
+func decodeUserSearchCursor(value string) (*models.UserSearchCursor, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	cursor := &models.UserSearchCursor{}
+	if err := json.Unmarshal(data, cursor); err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	return cursor, nil
+}
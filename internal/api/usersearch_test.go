@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/auth/internal/models"
+)
+
+func TestUserSearchParams(t *testing.T) {
+	req := httptest.NewRequest("GET", "/admin/users?email_prefix=Al&phone=%2B123&confirmed=true&banned=0&created_after=2024-01-02T03:04:05Z&app_metadata.org.id=42&app_metadata.plan=pro", nil)
+
+	params, err := userSearchParams(req)
+	require.NoError(t, err)
+
+	require.Equal(t, "Al", params.EmailPrefix)
+	require.Equal(t, "123", params.Phone)
+	require.True(t, *params.Confirmed)
+	require.False(t, *params.Banned)
+	require.Nil(t, params.Anonymous)
+	require.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), *params.CreatedAfter)
+	require.ElementsMatch(t, []models.AppMetaDataFilter{
+		{Path: []string{"org", "id"}, Value: "42"},
+		{Path: []string{"plan"}, Value: "pro"},
+	}, params.AppMetaData)
+
+	for _, query := range []string{"mfa_enabled=yes", "last_sign_in_before=yesterday", "app_metadata..id=1"} {
+		_, err := userSearchParams(httptest.NewRequest("GET", "/admin/users?"+query, nil))
+		require.Error(t, err, query)
+	}
+}
+
+func TestUserSearchCursor(t *testing.T) {
+	cursor := &models.UserSearchCursor{
+		CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 123456000, time.UTC),
+		ID:        uuid.Must(uuid.NewV4()),
+	}
+
+	decoded, err := decodeUserSearchCursor(encodeUserSearchCursor(cursor))
+	require.NoError(t, err)
+	require.Equal(t, cursor, decoded)
+
+	decoded, err = decodeUserSearchCursor("")
+	require.NoError(t, err)
+	require.Nil(t, decoded)
+
+	_, err = decodeUserSearchCursor("not a cursor")
+	require.Error(t, err)
+}
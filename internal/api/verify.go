@@ -96,7 +96,7 @@ func (a *API) Verify(w http.ResponseWriter, r *http.Request) error {
 	case http.MethodGet:
 		params.Token = r.FormValue("token")
 		params.Type = r.FormValue("type")
-		params.RedirectTo = utilities.GetReferrer(r, a.config)
+		params.RedirectTo = utilities.GetReferrer(r, a.requestConfig(r.Context()))
 		if err := params.Validate(r, a); err != nil {
 			return err
 		}
@@ -296,7 +296,7 @@ func (a *API) verifyPost(w http.ResponseWriter, r *http.Request, params *VerifyP
 }
 
 func (a *API) signupVerify(r *http.Request, ctx context.Context, conn *storage.Connection, user *models.User) (*models.User, error) {
-	config := a.config
+	config := a.requestConfig(ctx)
 
 	shouldUpdatePassword := false
 	if !user.HasPassword() && user.InvitedAt != nil {
@@ -356,6 +356,11 @@ func (a *API) recoverVerify(r *http.Request, conn *storage.Connection, user *mod
 		if terr = user.Recover(tx); terr != nil {
 			return terr
 		}
+		// following the recovery link proves access to the email
+		// address, so it also lifts a password lockout
+		if terr = a.unlockUser(r, tx, user, user, models.FailedPasswordAttempt); terr != nil {
+			return terr
+		}
 		if !user.IsConfirmed() {
 			if terr = models.NewAuditLogEntry(r, tx, user, models.UserSignedUpAction, "", nil); terr != nil {
 				return terr
@@ -379,6 +384,8 @@ func (a *API) recoverVerify(r *http.Request, conn *storage.Connection, user *mod
 }
 
 func (a *API) smsVerify(r *http.Request, conn *storage.Connection, user *models.User, params *VerifyParams) (*models.User, error) {
+	previousPhone := user.GetPhone()
+	wasAnonymous := user.IsAnonymous
 
 	err := conn.Transaction(func(tx *storage.Connection) error {
 
@@ -433,6 +440,14 @@ func (a *API) smsVerify(r *http.Request, conn *storage.Connection, user *models.
 	if err != nil {
 		return nil, err
 	}
+
+	if params.Type == phoneChangeVerification && !wasAnonymous {
+		a.notifyUser(r, user, mail.PhoneChangedNotification, map[string]interface{}{
+			"Phone":         user.GetPhone(),
+			"PreviousPhone": previousPhone,
+		})
+	}
+
 	return user, nil
 }
 
@@ -495,7 +510,7 @@ func (a *API) prepPKCERedirectURL(rurl, code string) (string, error) {
 }
 
 func (a *API) emailChangeVerify(r *http.Request, conn *storage.Connection, params *VerifyParams, user *models.User) (*models.User, error) {
-	config := a.config
+	config := a.requestConfig(r.Context())
 	if !config.Mailer.Autoconfirm &&
 		config.Mailer.SecureEmailChangeEnabled &&
 		user.EmailChangeConfirmStatus == zeroConfirmation &&
@@ -535,6 +550,10 @@ func (a *API) emailChangeVerify(r *http.Request, conn *storage.Connection, param
 		return nil, nil
 	}
 
+	// the previous address is notified unless the user was anonymous
+	previous := *user
+	notify := !user.IsAnonymous && user.GetEmail() != ""
+
 	// one email is confirmed at this point if GOTRUE_MAILER_SECURE_EMAIL_CHANGE_ENABLED is enabled
 	err := conn.Transaction(func(tx *storage.Connection) error {
 		if terr := models.NewAuditLogEntry(r, tx, user, models.UserModifiedAction, "", nil); terr != nil {
@@ -580,6 +599,12 @@ func (a *API) emailChangeVerify(r *http.Request, conn *storage.Connection, param
 		return nil, err
 	}
 
+	if notify {
+		a.notifyUser(r, &previous, mail.EmailChangedNotification, map[string]interface{}{
+			"NewEmail": user.GetEmail(),
+		})
+	}
+
 	return user, nil
 }
 
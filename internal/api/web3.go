@@ -2,15 +2,19 @@ package api
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/supabase/auth/internal/api/apierrors"
 	"github.com/supabase/auth/internal/api/provider"
 	"github.com/supabase/auth/internal/models"
 	"github.com/supabase/auth/internal/storage"
 	"github.com/supabase/auth/internal/utilities"
+	"github.com/supabase/auth/internal/utilities/siwe"
 	"github.com/supabase/auth/internal/utilities/siws"
 )
 
@@ -21,9 +25,9 @@ type Web3GrantParams struct {
 }
 
 func (a *API) Web3Grant(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
-	config := a.config
+	config := a.requestConfig(ctx)
 
-	if !config.External.Web3Solana.Enabled {
+	if !config.External.Web3Solana.Enabled && !config.External.Web3Ethereum.Enabled {
 		return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeWeb3ProviderDisabled, "Web3 provider is disabled")
 	}
 
@@ -32,15 +36,126 @@ func (a *API) Web3Grant(ctx context.Context, w http.ResponseWriter, r *http.Requ
 		return err
 	}
 
-	if params.Chain != "solana" {
+	switch params.Chain {
+	case "solana":
+		if !config.External.Web3Solana.Enabled {
+			return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeWeb3ProviderDisabled, "Web3 provider is disabled")
+		}
+
+		return a.web3GrantSolana(ctx, w, r, params)
+
+	case "ethereum":
+		if !config.External.Web3Ethereum.Enabled {
+			return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeWeb3ProviderDisabled, "Web3 provider is disabled")
+		}
+
+		return a.web3GrantEthereum(ctx, w, r, params)
+
+	default:
 		return apierrors.NewBadRequestError(apierrors.ErrorCodeWeb3UnsupportedChain, "Unsupported chain")
 	}
+}
+
+// Web3NonceResponse is the response of GET /web3/nonce.
+type Web3NonceResponse struct {
+	Nonce     string `json:"nonce"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// Web3Nonce issues a single use nonce for a web3 sign in message. The nonce
+// is bound to the chain and the IP address of the request, and is valid for
+// the maximum validity duration of messages on the chain.
+func (a *API) Web3Nonce(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	config := a.requestConfig(ctx)
+	db := a.db.WithContext(ctx)
+
+	chain := r.URL.Query().Get("chain")
+
+	var validity time.Duration
+	switch chain {
+	case "solana":
+		if !config.External.Web3Solana.Enabled {
+			return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeWeb3ProviderDisabled, "Web3 provider is disabled")
+		}
+		validity = config.External.Web3Solana.MaximumValidityDuration
+
+	case "ethereum":
+		if !config.External.Web3Ethereum.Enabled {
+			return apierrors.NewUnprocessableEntityError(apierrors.ErrorCodeWeb3ProviderDisabled, "Web3 provider is disabled")
+		}
+		validity = config.External.Web3Ethereum.MaximumValidityDuration
+
+	default:
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeWeb3UnsupportedChain, "Unsupported chain")
+	}
+
+	nonce := models.NewWeb3Nonce(chain, utilities.GetIPAddress(r), a.Now(), validity)
+	if err := db.Create(nonce); err != nil {
+		return apierrors.NewInternalServerError("Database error creating web3 nonce").WithInternalError(err)
+	}
 
-	return a.web3GrantSolana(ctx, w, r, params)
+	return sendJSON(w, http.StatusOK, &Web3NonceResponse{
+		Nonce:     nonce.Nonce,
+		ExpiresAt: nonce.ExpiresAt.Unix(),
+	})
+}
+
+// consumeWeb3Nonce consumes the nonce of a signed message, so that the
+// message can't be replayed. Messages with nonces issued by the server are
+// rejected when the nonce has been used, has expired or was issued to another
+// IP address or for another chain. Other nonces are only accepted when the
+// chain doesn't require nonces issued by the server, and are recorded until
+// the message expires so that they can only be used once. Messages without a
+// nonce are recorded by their digest instead.
+func (a *API) consumeWeb3Nonce(db *storage.Connection, r *http.Request, chain, message, nonce string, required bool, expiresAt time.Time) error {
+	if nonce == "" {
+		if required {
+			return apierrors.NewOAuthError("invalid_grant", "Signed message must contain a nonce issued by this server")
+		}
+
+		digest := sha256.Sum256([]byte(message))
+		return a.recordWeb3Nonce(db, r, chain, "message:"+hex.EncodeToString(digest[:]), expiresAt)
+	}
+
+	consumed, err := models.ConsumeWeb3Nonce(db, chain, nonce, utilities.GetIPAddress(r), a.Now())
+	if err != nil {
+		return apierrors.NewInternalServerError("Database error consuming web3 nonce").WithInternalError(err)
+	}
+
+	if consumed {
+		return nil
+	}
+
+	if _, err := models.FindWeb3Nonce(db, nonce); err == nil {
+		return apierrors.NewOAuthError("invalid_grant", "Nonce in signed message has already been used, has expired or was issued to another client")
+	} else if !models.IsNotFoundError(err) {
+		return apierrors.NewInternalServerError("Database error finding web3 nonce").WithInternalError(err)
+	}
+
+	if required {
+		return apierrors.NewOAuthError("invalid_grant", "Nonce in signed message was not issued by this server")
+	}
+
+	return a.recordWeb3Nonce(db, r, chain, nonce, expiresAt)
+}
+
+// recordWeb3Nonce records a nonce that wasn't issued by the server as used.
+func (a *API) recordWeb3Nonce(db *storage.Connection, r *http.Request, chain, nonce string, expiresAt time.Time) error {
+	recorded, err := models.RecordWeb3Nonce(db, chain, nonce, utilities.GetIPAddress(r), a.Now(), expiresAt)
+	if err != nil {
+		return apierrors.NewInternalServerError("Database error recording web3 nonce").WithInternalError(err)
+	}
+
+	if !recorded {
+		return apierrors.NewOAuthError("invalid_grant", "Nonce in signed message has already been used, has expired or was issued to another client")
+	}
+
+	return nil
 }
 
 func (a *API) web3GrantSolana(ctx context.Context, w http.ResponseWriter, r *http.Request, params *Web3GrantParams) error {
-	config := a.config
+	config := a.requestConfig(ctx)
 	db := a.db.WithContext(ctx)
 
 	if len(params.Message) < 64 {
@@ -102,6 +217,10 @@ func (a *API) web3GrantSolana(ctx context.Context, w http.ResponseWriter, r *htt
 		return apierrors.NewOAuthError("invalid_grant", "Solana message was issued too far in the future")
 	}
 
+	if err := a.consumeWeb3Nonce(db, r, params.Chain, params.Message, parsedMessage.Nonce, config.External.Web3Solana.RequireNonce, latestExpiryAt); err != nil {
+		return err
+	}
+
 	const providerType = "web3"
 	providerId := strings.Join([]string{
 		providerType,
@@ -169,3 +288,143 @@ func (a *API) web3GrantSolana(ctx context.Context, w http.ResponseWriter, r *htt
 
 	return sendJSON(w, http.StatusOK, token)
 }
+
+func (a *API) web3GrantEthereum(ctx context.Context, w http.ResponseWriter, r *http.Request, params *Web3GrantParams) error {
+	config := a.requestConfig(ctx)
+	db := a.db.WithContext(ctx)
+
+	if len(params.Message) < 64 {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "message is too short")
+	} else if len(params.Message) > 20*1024 {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "message must not exceed 20KB")
+	}
+
+	// signatures of smart contract wallets can be longer than 65 bytes
+	if !strings.HasPrefix(params.Signature, "0x") || len(params.Signature) < 2+2*65 || len(params.Signature) > 2+2*4096 {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "signature must be at least 65 bytes encoded as 0x prefixed hex")
+	}
+
+	signatureBytes, err := hex.DecodeString(params.Signature[2:])
+	if err != nil {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, "signature does not contain valid hex characters")
+	}
+
+	parsedMessage, err := siwe.ParseMessage(params.Message)
+	if err != nil {
+		return apierrors.NewBadRequestError(apierrors.ErrorCodeValidationFailed, err.Error())
+	}
+
+	valid, err := parsedMessage.VerifySignature(ctx, signatureBytes, a.web3ContractVerifier)
+	if err != nil {
+		return apierrors.NewOAuthError("server_error", "Unable to verify contract wallet signature").WithInternalError(err)
+	}
+
+	if !valid {
+		return apierrors.NewOAuthError("invalid_grant", "Signature does not match address in message")
+	}
+
+	if parsedMessage.URI.Scheme != "https" && parsedMessage.URI.Hostname() != "localhost" {
+		return apierrors.NewOAuthError("invalid_grant", "Signed Ethereum message is using URI which does not use HTTPS")
+	}
+
+	if !utilities.IsRedirectURLValid(config, parsedMessage.URI.String()) {
+		return apierrors.NewOAuthError("invalid_grant", "Signed Ethereum message is using URI which is not allowed on this server, message was signed for another app")
+	}
+
+	if parsedMessage.URI.Hostname() != "localhost" && (parsedMessage.URI.Host != parsedMessage.Domain || !utilities.IsRedirectURLValid(config, "https://"+parsedMessage.Domain+"/")) {
+		return apierrors.NewOAuthError("invalid_grant", "Signed Ethereum message is using a Domain that does not match the one in URI which is not allowed on this server")
+	}
+
+	now := a.Now()
+
+	if !parsedMessage.NotBefore.IsZero() && now.Before(parsedMessage.NotBefore) {
+		return apierrors.NewOAuthError("invalid_grant", "Signed Ethereum message becomes valid in the future")
+	}
+
+	if !parsedMessage.ExpirationTime.IsZero() && now.After(parsedMessage.ExpirationTime) {
+		return apierrors.NewOAuthError("invalid_grant", "Signed Ethereum message is expired")
+	}
+
+	latestExpiryAt := parsedMessage.IssuedAt.Add(config.External.Web3Ethereum.MaximumValidityDuration)
+
+	if now.After(latestExpiryAt) {
+		return apierrors.NewOAuthError("invalid_grant", "Ethereum message was issued too long ago")
+	}
+
+	earliestIssuedAt := parsedMessage.IssuedAt.Add(-config.External.Web3Ethereum.MaximumValidityDuration)
+
+	if now.Before(earliestIssuedAt) {
+		return apierrors.NewOAuthError("invalid_grant", "Ethereum message was issued too far in the future")
+	}
+
+	if err := a.consumeWeb3Nonce(db, r, params.Chain, params.Message, parsedMessage.Nonce, config.External.Web3Ethereum.RequireNonce, latestExpiryAt); err != nil {
+		return err
+	}
+
+	const providerType = "web3"
+	providerId := strings.Join([]string{
+		providerType,
+		params.Chain,
+		parsedMessage.Address,
+	}, ":")
+
+	userData := provider.UserProvidedData{
+		Metadata: &provider.Claims{
+			CustomClaims: map[string]interface{}{
+				"address":   parsedMessage.Address,
+				"chain":     params.Chain,
+				"network":   parsedMessage.Network(),
+				"domain":    parsedMessage.Domain,
+				"statement": parsedMessage.Statement,
+			},
+			Subject: providerId,
+		},
+		Emails: []provider.Email{},
+	}
+
+	var token *AccessTokenResponse
+	var grantParams models.GrantParams
+	grantParams.FillGrantParams(r)
+
+	if err := a.triggerBeforeUserCreatedExternal(r, db, &userData, providerType); err != nil {
+		return err
+	}
+
+	err = db.Transaction(func(tx *storage.Connection) error {
+		user, terr := a.createAccountFromExternalIdentity(tx, r, &userData, providerType)
+		if terr != nil {
+			return terr
+		}
+
+		if terr := models.NewAuditLogEntry(r, tx, user, models.LoginAction, "", map[string]interface{}{
+			"provider": providerType,
+			"chain":    params.Chain,
+			"network":  parsedMessage.Network(),
+			"address":  parsedMessage.Address,
+			"domain":   parsedMessage.Domain,
+			"uri":      parsedMessage.URI,
+		}); terr != nil {
+			return terr
+		}
+
+		token, terr = a.issueRefreshToken(r, tx, user, models.Web3, grantParams)
+		if terr != nil {
+			return terr
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		switch err.(type) {
+		case *storage.CommitWithError:
+			return err
+		case *HTTPError:
+			return err
+		default:
+			return apierrors.NewOAuthError("server_error", "Internal Server Error").WithInternalError(err)
+		}
+	}
+
+	return sendJSON(w, http.StatusOK, token)
+}
@@ -2,6 +2,8 @@ package api
 
 import (
 	"bytes"
+	"context"
+	"encoding/hex"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -9,11 +11,15 @@ import (
 	"testing"
 	"time"
 
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"github.com/supabase/auth/internal/api/apierrors"
 	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/utilities/siwe"
 )
 
 type Web3TestSuite struct {
@@ -35,6 +41,10 @@ func TestWeb3(t *testing.T) {
 	suite.Run(t, ts)
 }
 
+func (ts *Web3TestSuite) SetupTest() {
+	models.TruncateAll(ts.API.db)
+}
+
 func (ts *Web3TestSuite) TestNonSolana() {
 	var buffer bytes.Buffer
 	require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
@@ -624,3 +634,262 @@ func (ts *Web3TestSuite) TestValidationRules_BasicValidation() {
 
 	assert.Equal(ts.T(), http.StatusBadRequest, w.Code)
 }
+
+type web3TestContractVerifier struct {
+	signature []byte
+}
+
+func (v *web3TestContractVerifier) IsValidSignature(ctx context.Context, chainID uint64, address string, hash, signature []byte) (bool, error) {
+	return bytes.Equal(v.signature, signature), nil
+}
+
+// signEthereumMessage signs the message like personal_sign and returns the 0x
+// prefixed r || s || v signature
+func signEthereumMessage(key *secp256k1.PrivateKey, message string) string {
+	compact := ecdsa.SignCompact(key, siwe.HashMessage(message), false)
+
+	return "0x" + hex.EncodeToString(append(compact[1:], compact[0]))
+}
+
+func (ts *Web3TestSuite) requestEthereumGrant(message, signature string) *httptest.ResponseRecorder {
+	var buffer bytes.Buffer
+	require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
+		"chain":     "ethereum",
+		"message":   message,
+		"signature": signature,
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/token?grant_type=web3", &buffer)
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+
+	return w
+}
+
+func (ts *Web3TestSuite) TestEthereum_HappyPath() {
+	defer func() {
+		ts.API.overrideTime = nil
+	}()
+
+	ts.API.overrideTime = func() time.Time {
+		t, _ := time.Parse(time.RFC3339, "2025-03-29T00:09:59Z")
+		return t
+	}
+
+	key := secp256k1.PrivKeyFromBytes([]byte{31: 1})
+	message := "supabase.com wants you to sign in with your Ethereum account:\n0x7E5F4552091A69125d5DfCb7b8C2659029395Bdf\n\nStatement\n\nURI: https://supabase.com/\nVersion: 1\nChain ID: 1\nNonce: 32891756\nIssued At: 2025-03-29T00:00:00Z\nExpiration Time: 2025-03-29T00:10:00Z"
+
+	w := ts.requestEthereumGrant(message, signEthereumMessage(key, message))
+	assert.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var firstResult struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		User         struct {
+			Identities []struct {
+				Provider     string                 `json:"provider"`
+				IdentityData map[string]interface{} `json:"identity_data"`
+			} `json:"identities"`
+		} `json:"user"`
+	}
+
+	assert.NoError(ts.T(), json.NewDecoder(w.Result().Body).Decode(&firstResult))
+
+	assert.NotEmpty(ts.T(), firstResult.AccessToken)
+	assert.NotEmpty(ts.T(), firstResult.RefreshToken)
+	require.Len(ts.T(), firstResult.User.Identities, 1)
+	assert.Equal(ts.T(), "web3", firstResult.User.Identities[0].Provider)
+	assert.Equal(ts.T(), "web3:ethereum:0x7E5F4552091A69125d5DfCb7b8C2659029395Bdf", firstResult.User.Identities[0].IdentityData["sub"])
+	assert.Equal(ts.T(), "eip155:1", firstResult.User.Identities[0].IdentityData["custom_claims"].(map[string]interface{})["network"])
+}
+
+func (ts *Web3TestSuite) TestEthereum_ContractWallet() {
+	defer func() {
+		ts.API.overrideTime = nil
+		ts.API.web3ContractVerifier = nil
+	}()
+
+	ts.API.overrideTime = func() time.Time {
+		t, _ := time.Parse(time.RFC3339, "2025-03-29T00:09:59Z")
+		return t
+	}
+
+	signature := bytes.Repeat([]byte{1}, 96)
+	ts.API.web3ContractVerifier = &web3TestContractVerifier{signature: signature}
+
+	message := "supabase.com wants you to sign in with your Ethereum account:\n0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed\n\n\nURI: https://supabase.com/\nVersion: 1\nChain ID: 137\nNonce: 32891756\nIssued At: 2025-03-29T00:00:00Z"
+
+	w := ts.requestEthereumGrant(message, "0x"+hex.EncodeToString(signature))
+	assert.Equal(ts.T(), http.StatusOK, w.Code)
+
+	w = ts.requestEthereumGrant(message, "0x"+hex.EncodeToString(bytes.Repeat([]byte{2}, 96)))
+	assert.Equal(ts.T(), http.StatusBadRequest, w.Code)
+}
+
+func (ts *Web3TestSuite) TestEthereum_InvalidSignature() {
+	defer func() {
+		ts.API.overrideTime = nil
+	}()
+
+	ts.API.overrideTime = func() time.Time {
+		t, _ := time.Parse(time.RFC3339, "2025-03-29T00:09:59Z")
+		return t
+	}
+
+	key := secp256k1.PrivKeyFromBytes([]byte{31: 2})
+	message := "supabase.com wants you to sign in with your Ethereum account:\n0x7E5F4552091A69125d5DfCb7b8C2659029395Bdf\n\n\nURI: https://supabase.com/\nVersion: 1\nChain ID: 1\nNonce: 32891756\nIssued At: 2025-03-29T00:00:00Z"
+
+	w := ts.requestEthereumGrant(message, signEthereumMessage(key, message))
+	assert.Equal(ts.T(), http.StatusBadRequest, w.Code)
+
+	var firstResult struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+
+	assert.NoError(ts.T(), json.NewDecoder(w.Result().Body).Decode(&firstResult))
+
+	assert.Equal(ts.T(), "invalid_grant", firstResult.Error)
+	assert.Equal(ts.T(), "Signature does not match address in message", firstResult.ErrorDescription)
+
+	w = ts.requestEthereumGrant(message, "0xzz")
+	assert.Equal(ts.T(), http.StatusBadRequest, w.Code)
+}
+
+func (ts *Web3TestSuite) TestEthereum_Disabled() {
+	defer func() {
+		ts.Config.External.Web3Ethereum.Enabled = true
+	}()
+
+	ts.Config.External.Web3Ethereum.Enabled = false
+
+	w := ts.requestEthereumGrant("", "")
+
+	var firstResult struct {
+		ErrorCode string `json:"error_code"`
+		Message   string `json:"msg"`
+	}
+
+	assert.NoError(ts.T(), json.NewDecoder(w.Result().Body).Decode(&firstResult))
+	assert.Equal(ts.T(), apierrors.ErrorCodeWeb3ProviderDisabled, firstResult.ErrorCode)
+	assert.Equal(ts.T(), "Web3 provider is disabled", firstResult.Message)
+}
+
+func (ts *Web3TestSuite) requestWeb3Nonce(chain string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/web3/nonce?chain="+chain, nil)
+
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+
+	return w
+}
+
+func (ts *Web3TestSuite) TestNonce_Replay() {
+	defer func() {
+		ts.API.overrideTime = nil
+	}()
+
+	ts.API.overrideTime = func() time.Time {
+		t, _ := time.Parse(time.RFC3339, "2025-03-29T00:09:59Z")
+		return t
+	}
+
+	w := ts.requestWeb3Nonce("ethereum")
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var nonce Web3NonceResponse
+	require.NoError(ts.T(), json.NewDecoder(w.Result().Body).Decode(&nonce))
+	require.Len(ts.T(), nonce.Nonce, 32)
+	require.Equal(ts.T(), ts.API.Now().Add(ts.Config.External.Web3Ethereum.MaximumValidityDuration).Unix(), nonce.ExpiresAt)
+
+	key := secp256k1.PrivKeyFromBytes([]byte{31: 1})
+	message := "supabase.com wants you to sign in with your Ethereum account:\n0x7E5F4552091A69125d5DfCb7b8C2659029395Bdf\n\n\nURI: https://supabase.com/\nVersion: 1\nChain ID: 1\nNonce: " + nonce.Nonce + "\nIssued At: 2025-03-29T00:00:00Z"
+	signature := signEthereumMessage(key, message)
+
+	w = ts.requestEthereumGrant(message, signature)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	w = ts.requestEthereumGrant(message, signature)
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+
+	var firstResult struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+
+	require.NoError(ts.T(), json.NewDecoder(w.Result().Body).Decode(&firstResult))
+	require.Equal(ts.T(), "invalid_grant", firstResult.Error)
+	require.Equal(ts.T(), "Nonce in signed message has already been used, has expired or was issued to another client", firstResult.ErrorDescription)
+}
+
+func (ts *Web3TestSuite) TestNonce_ClientReplay() {
+	defer func() {
+		ts.API.overrideTime = nil
+	}()
+
+	ts.API.overrideTime = func() time.Time {
+		t, _ := time.Parse(time.RFC3339, "2025-03-29T00:09:59Z")
+		return t
+	}
+
+	key := secp256k1.PrivKeyFromBytes([]byte{31: 1})
+	message := "supabase.com wants you to sign in with your Ethereum account:\n0x7E5F4552091A69125d5DfCb7b8C2659029395Bdf\n\n\nURI: https://supabase.com/\nVersion: 1\nChain ID: 1\nNonce: 32891756\nIssued At: 2025-03-29T00:00:00Z"
+	signature := signEthereumMessage(key, message)
+
+	w := ts.requestEthereumGrant(message, signature)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	w = ts.requestEthereumGrant(message, signature)
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+
+	var firstResult struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+
+	require.NoError(ts.T(), json.NewDecoder(w.Result().Body).Decode(&firstResult))
+	require.Equal(ts.T(), "invalid_grant", firstResult.Error)
+	require.Equal(ts.T(), "Nonce in signed message has already been used, has expired or was issued to another client", firstResult.ErrorDescription)
+}
+
+func (ts *Web3TestSuite) TestNonce_Required() {
+	defer func() {
+		ts.API.overrideTime = nil
+		ts.Config.External.Web3Ethereum.RequireNonce = false
+	}()
+
+	ts.Config.External.Web3Ethereum.RequireNonce = true
+	ts.API.overrideTime = func() time.Time {
+		t, _ := time.Parse(time.RFC3339, "2025-03-29T00:09:59Z")
+		return t
+	}
+
+	key := secp256k1.PrivKeyFromBytes([]byte{31: 1})
+	message := "supabase.com wants you to sign in with your Ethereum account:\n0x7E5F4552091A69125d5DfCb7b8C2659029395Bdf\n\n\nURI: https://supabase.com/\nVersion: 1\nChain ID: 1\nNonce: 32891756\nIssued At: 2025-03-29T00:00:00Z"
+
+	w := ts.requestEthereumGrant(message, signEthereumMessage(key, message))
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+
+	var firstResult struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+
+	require.NoError(ts.T(), json.NewDecoder(w.Result().Body).Decode(&firstResult))
+	require.Equal(ts.T(), "invalid_grant", firstResult.Error)
+	require.Equal(ts.T(), "Nonce in signed message was not issued by this server", firstResult.ErrorDescription)
+}
+
+func (ts *Web3TestSuite) TestNonce_UnsupportedChain() {
+	w := ts.requestWeb3Nonce("blockchain")
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+
+	var firstResult struct {
+		ErrorCode string `json:"error_code"`
+	}
+
+	require.NoError(ts.T(), json.NewDecoder(w.Result().Body).Decode(&firstResult))
+	require.Equal(ts.T(), apierrors.ErrorCodeWeb3UnsupportedChain, firstResult.ErrorCode)
+}
@@ -0,0 +1,369 @@
+package conf
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/gobwas/glob"
+)
+
+// AudiencesConfiguration configures the configuration overlays of
+// audiences, which are loaded from GOTRUE_AUDIENCES_FILE and the database.
+type AudiencesConfiguration struct {
+	// File is a JSON file with an object of overlays by audience.
+	File string `json:"file"`
+
+	// CacheTTL is how long overlays stored in the database are cached,
+	// and so how long changes take to apply on other instances.
+	CacheTTL time.Duration `json:"cache_ttl" split_words:"true" default:"1m"`
+
+	// Overlays are loaded from File.
+	Overlays map[string]AudienceConfiguration `json:"-" ignored:"true"`
+}
+
+func (c *AudiencesConfiguration) Validate() error {
+	if c.CacheTTL < 0 {
+		return fmt.Errorf("conf: GOTRUE_AUDIENCES_CACHE_TTL must not be negative")
+	}
+
+	return nil
+}
+
+// AudienceConfiguration overrides part of the configuration for the
+// requests of an audience, so that one deployment can serve several apps
+// with their own redirects, providers and emails. Unset fields keep the
+// global value.
+type AudienceConfiguration struct {
+	// Hosts select the audience for requests to them that don't name an
+	// audience in the X-JWT-AUD header.
+	Hosts []string `json:"hosts,omitempty"`
+
+	SiteURL       string   `json:"site_url,omitempty"`
+	URIAllowList  []string `json:"uri_allow_list,omitempty"`
+	DisableSignup *bool    `json:"disable_signup,omitempty"`
+
+	// External overrides providers by name, such as google or
+	// custom:corp. The email and phone providers can only be enabled or
+	// disabled.
+	External map[string]AudienceProviderConfiguration `json:"external,omitempty"`
+
+	SMTP     AudienceSMTPConfiguration     `json:"smtp"`
+	Mailer   AudienceMailerConfiguration   `json:"mailer"`
+	Password AudiencePasswordConfiguration `json:"password"`
+	MFA      AudienceMFAConfiguration      `json:"mfa"`
+}
+
+type AudienceProviderConfiguration struct {
+	Enabled     *bool    `json:"enabled,omitempty"`
+	ClientID    []string `json:"client_id,omitempty"`
+	Secret      string   `json:"secret,omitempty"`
+	RedirectURI string   `json:"redirect_uri,omitempty"`
+}
+
+func (c *AudienceProviderConfiguration) apply(provider *OAuthProviderConfiguration) {
+	if c.Enabled != nil {
+		provider.Enabled = *c.Enabled
+	}
+	if c.ClientID != nil {
+		provider.ClientID = c.ClientID
+	}
+	if c.Secret != "" {
+		provider.Secret = c.Secret
+	}
+	if c.RedirectURI != "" {
+		provider.RedirectURI = c.RedirectURI
+	}
+}
+
+// AudienceSMTPConfiguration overrides the sender of emails.
+type AudienceSMTPConfiguration struct {
+	AdminEmail string `json:"admin_email,omitempty"`
+	SenderName string `json:"sender_name,omitempty"`
+}
+
+type AudienceMailerConfiguration struct {
+	Autoconfirm *bool `json:"autoconfirm,omitempty"`
+
+	// Subjects and Templates override the non-empty subjects and
+	// template URLs.
+	Subjects  EmailContentConfiguration `json:"subjects"`
+	Templates EmailContentConfiguration `json:"templates"`
+}
+
+type AudiencePasswordConfiguration struct {
+	MinLength          int                        `json:"min_length,omitempty"`
+	RequiredCharacters PasswordRequiredCharacters `json:"required_characters,omitempty"`
+}
+
+type AudienceMFAConfiguration struct {
+	MaxVerifiedFactors int                         `json:"max_verified_factors,omitempty"`
+	TOTP               *MFAFactorTypeConfiguration `json:"totp,omitempty"`
+	Phone              *MFAFactorTypeConfiguration `json:"phone,omitempty"`
+	WebAuthn           *MFAFactorTypeConfiguration `json:"web_authn,omitempty"`
+}
+
+// ParseAudienceConfiguration parses an overlay, rejecting unknown settings
+// so that typos don't go unnoticed.
+func ParseAudienceConfiguration(data []byte) (*AudienceConfiguration, error) {
+	audience := &AudienceConfiguration{}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(audience); err != nil {
+		return nil, err
+	}
+
+	return audience, nil
+}
+
+// loadAudienceConfigurations loads the overlays in the JSON file, if any.
+func loadAudienceConfigurations(filename string) (map[string]AudienceConfiguration, error) {
+	if filename == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(filename) // #nosec G304
+	if err != nil {
+		return nil, fmt.Errorf("conf: GOTRUE_AUDIENCES_FILE: %w", err)
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("conf: GOTRUE_AUDIENCES_FILE: %w", err)
+	}
+
+	audiences := make(map[string]AudienceConfiguration, len(raw))
+	for aud, data := range raw {
+		audience, err := ParseAudienceConfiguration(data)
+		if err != nil {
+			return nil, fmt.Errorf("conf: GOTRUE_AUDIENCES_FILE: audience %q: %w", aud, err)
+		}
+		audiences[aud] = *audience
+	}
+
+	return audiences, nil
+}
+
+// validateAudiences checks that the overlays in GOTRUE_AUDIENCES_FILE apply
+// to the configuration.
+func (c *GlobalConfiguration) validateAudiences() error {
+	hosts := make(map[string]string)
+
+	for aud, audience := range c.Audiences.Overlays {
+		if _, err := c.WithAudience(&audience); err != nil {
+			return fmt.Errorf("conf: GOTRUE_AUDIENCES_FILE: audience %q: %w", aud, err)
+		}
+
+		for _, host := range audience.Hosts {
+			host = NormalizeAudienceHost(host)
+			if other, ok := hosts[host]; ok {
+				return fmt.Errorf("conf: GOTRUE_AUDIENCES_FILE: host %q is used by audiences %q and %q", host, other, aud)
+			}
+			hosts[host] = aud
+		}
+	}
+
+	return nil
+}
+
+// NormalizeAudienceHost returns the lowercase host without its port, as
+// hosts of overlays are matched.
+func NormalizeAudienceHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	return strings.ToLower(host)
+}
+
+// WithAudience returns a copy of the configuration with the overlay
+// applied. The copy shares the maps and slices the overlay doesn't change
+// with c, so neither may be modified.
+func (c *GlobalConfiguration) WithAudience(audience *AudienceConfiguration) (*GlobalConfiguration, error) {
+	config := *c
+
+	for _, host := range audience.Hosts {
+		if host == "" || strings.ContainsAny(host, "/ ") {
+			return nil, fmt.Errorf("invalid host %q", host)
+		}
+	}
+
+	if audience.SiteURL != "" {
+		if _, err := url.ParseRequestURI(audience.SiteURL); err != nil {
+			return nil, fmt.Errorf("invalid site_url: %w", err)
+		}
+		config.SiteURL = audience.SiteURL
+	}
+
+	if audience.URIAllowList != nil {
+		config.URIAllowList = audience.URIAllowList
+		config.URIAllowListMap = make(map[string]glob.Glob, len(audience.URIAllowList))
+		for _, uri := range audience.URIAllowList {
+			g, err := glob.Compile(uri, '.', '/')
+			if err != nil {
+				return nil, fmt.Errorf("invalid uri_allow_list entry %q: %w", uri, err)
+			}
+			config.URIAllowListMap[uri] = g
+		}
+	}
+
+	if audience.DisableSignup != nil {
+		config.DisableSignup = *audience.DisableSignup
+	}
+
+	if err := config.External.applyAudience(audience.External); err != nil {
+		return nil, err
+	}
+
+	if audience.SMTP.AdminEmail != "" {
+		config.SMTP.AdminEmail = audience.SMTP.AdminEmail
+	}
+	if audience.SMTP.SenderName != "" {
+		config.SMTP.SenderName = audience.SMTP.SenderName
+	}
+	// updates the from address
+	if err := config.SMTP.Validate(); err != nil {
+		return nil, err
+	}
+
+	if audience.Mailer.Autoconfirm != nil {
+		config.Mailer.Autoconfirm = *audience.Mailer.Autoconfirm
+		if config.Mailer.Autoconfirm && config.Mailer.AllowUnverifiedEmailSignIns {
+			return nil, errors.New("mailer.autoconfirm can't be enabled along with GOTRUE_MAILER_ALLOW_UNVERIFIED_EMAIL_SIGN_INS")
+		}
+	}
+	config.Mailer.Subjects = audience.Mailer.Subjects.overlay(config.Mailer.Subjects)
+	config.Mailer.Templates = audience.Mailer.Templates.overlay(config.Mailer.Templates)
+
+	if audience.Password.MinLength != 0 {
+		if audience.Password.MinLength < defaultMinPasswordLength {
+			return nil, fmt.Errorf("password.min_length must be at least %d", defaultMinPasswordLength)
+		}
+		if config.Password.MaxLength > 0 && config.Password.MaxLength < audience.Password.MinLength {
+			return nil, errors.New("password.min_length must not be more than GOTRUE_PASSWORD_MAX_LENGTH")
+		}
+		config.Password.MinLength = audience.Password.MinLength
+	}
+	if audience.Password.RequiredCharacters != nil {
+		config.Password.RequiredCharacters = audience.Password.RequiredCharacters
+	}
+
+	if audience.MFA.MaxVerifiedFactors < 0 {
+		return nil, errors.New("mfa.max_verified_factors must not be negative")
+	}
+	if audience.MFA.MaxVerifiedFactors != 0 {
+		config.MFA.MaxVerifiedFactors = audience.MFA.MaxVerifiedFactors
+	}
+	if audience.MFA.TOTP != nil {
+		config.MFA.TOTP = TOTPFactorTypeConfiguration(*audience.MFA.TOTP)
+	}
+	if audience.MFA.WebAuthn != nil {
+		config.MFA.WebAuthn = *audience.MFA.WebAuthn
+	}
+	if audience.MFA.Phone != nil {
+		config.MFA.Phone.MFAFactorTypeConfiguration = *audience.MFA.Phone
+		if (config.MFA.Phone.EnrollEnabled || config.MFA.Phone.VerifyEnabled) && config.MFA.Phone.SMSTemplate == nil {
+			smsTemplate := config.MFA.Phone.Template
+			if smsTemplate == "" {
+				smsTemplate = "Your code is {{ .Code }}"
+			}
+			template, err := template.New("").Parse(smsTemplate)
+			if err != nil {
+				return nil, err
+			}
+			config.MFA.Phone.SMSTemplate = template
+		}
+	}
+
+	return &config, nil
+}
+
+// overlay returns the content with the non-empty fields of c.
+func (c EmailContentConfiguration) overlay(content EmailContentConfiguration) EmailContentConfiguration {
+	v := reflect.ValueOf(&content).Elem()
+	o := reflect.ValueOf(c)
+	for i := 0; i < o.NumField(); i++ {
+		if value := o.Field(i).String(); value != "" {
+			v.Field(i).SetString(value)
+		}
+	}
+
+	return content
+}
+
+// applyAudience applies the provider overrides of an audience. The custom
+// providers are copied, as they are kept in a map.
+func (c *ProviderConfiguration) applyAudience(providers map[string]AudienceProviderConfiguration) error {
+	if len(providers) == 0 {
+		return nil
+	}
+
+	custom := make(CustomOAuthProviders, len(c.Custom))
+	for name, provider := range c.Custom {
+		custom[name] = provider
+	}
+	c.Custom = custom
+
+	for name, overlay := range providers {
+		switch name {
+		case "email", "phone":
+			if overlay.ClientID != nil || overlay.Secret != "" || overlay.RedirectURI != "" {
+				return fmt.Errorf("external.%s can only be enabled or disabled", name)
+			}
+			if overlay.Enabled == nil {
+				continue
+			}
+			if name == "email" {
+				c.Email.Enabled = *overlay.Enabled
+			} else {
+				c.Phone.Enabled = *overlay.Enabled
+			}
+
+		default:
+			if customName, ok := strings.CutPrefix(name, CustomProviderPrefix); ok {
+				provider, ok := custom[customName]
+				if !ok {
+					return fmt.Errorf("unknown provider external.%s", name)
+				}
+				overlay.apply(&provider.OAuthProviderConfiguration)
+				custom[customName] = provider
+				continue
+			}
+
+			provider := c.oauthProvider(name)
+			if provider == nil {
+				return fmt.Errorf("unknown provider external.%s", name)
+			}
+			overlay.apply(provider)
+		}
+	}
+
+	return nil
+}
+
+// oauthProvider returns the built-in OAuth provider named by its JSON key.
+func (c *ProviderConfiguration) oauthProvider(name string) *OAuthProviderConfiguration {
+	v := reflect.ValueOf(c).Elem()
+	oauthType := reflect.TypeOf(OAuthProviderConfiguration{})
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if field.Type != oauthType {
+			continue
+		}
+		if key, _, _ := strings.Cut(field.Tag.Get("json"), ","); key == name {
+			return v.Field(i).Addr().Interface().(*OAuthProviderConfiguration)
+		}
+	}
+
+	return nil
+}
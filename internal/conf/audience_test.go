@@ -0,0 +1,120 @@
+package conf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithAudience(t *testing.T) {
+	config := &GlobalConfiguration{
+		SiteURL:      "https://app.example.com",
+		URIAllowList: []string{"https://app.example.com/**"},
+		External: ProviderConfiguration{
+			Google: OAuthProviderConfiguration{Enabled: true, ClientID: []string{"global"}},
+			Email:  EmailProviderConfiguration{Enabled: true},
+			Custom: CustomOAuthProviders{
+				"corp": {OAuthProviderConfiguration: OAuthProviderConfiguration{Enabled: true}},
+			},
+		},
+		SMTP:     SMTPConfiguration{AdminEmail: "admin@example.com"},
+		Password: PasswordConfiguration{MinLength: 6},
+		Mailer: MailerConfiguration{
+			Subjects: EmailContentConfiguration{Invite: "Invited", Recovery: "Reset"},
+		},
+	}
+
+	audience, err := ParseAudienceConfiguration([]byte(`{
+		"hosts": ["brand.example.com"],
+		"site_url": "https://brand.example.com",
+		"uri_allow_list": ["https://brand.example.com/**"],
+		"external": {
+			"google": {"client_id": ["brand"]},
+			"custom:corp": {"enabled": false},
+			"email": {"enabled": false}
+		},
+		"smtp": {"admin_email": "hello@brand.example.com", "sender_name": "Brand"},
+		"mailer": {"subjects": {"invite": "Welcome to Brand"}},
+		"password": {"min_length": 10},
+		"mfa": {"max_verified_factors": 3}
+	}`))
+	require.NoError(t, err)
+
+	overlaid, err := config.WithAudience(audience)
+	require.NoError(t, err)
+
+	require.Equal(t, "https://brand.example.com", overlaid.SiteURL)
+	require.Contains(t, overlaid.URIAllowListMap, "https://brand.example.com/**")
+	require.True(t, overlaid.External.Google.Enabled)
+	require.Equal(t, []string{"brand"}, overlaid.External.Google.ClientID)
+	require.False(t, overlaid.External.Custom["corp"].Enabled)
+	require.False(t, overlaid.External.Email.Enabled)
+	require.Equal(t, `"Brand" <hello@brand.example.com>`, overlaid.SMTP.FromAddress())
+	require.Equal(t, "Welcome to Brand", overlaid.Mailer.Subjects.Invite)
+	require.Equal(t, "Reset", overlaid.Mailer.Subjects.Recovery)
+	require.Equal(t, 10, overlaid.Password.MinLength)
+	require.Equal(t, 3, overlaid.MFA.MaxVerifiedFactors)
+
+	// the global configuration is unchanged
+	require.Equal(t, "https://app.example.com", config.SiteURL)
+	require.Nil(t, config.URIAllowListMap)
+	require.Equal(t, []string{"global"}, config.External.Google.ClientID)
+	require.True(t, config.External.Custom["corp"].Enabled)
+	require.True(t, config.External.Email.Enabled)
+	require.Equal(t, "Invited", config.Mailer.Subjects.Invite)
+	require.Equal(t, 6, config.Password.MinLength)
+}
+
+func TestWithAudienceInvalid(t *testing.T) {
+	config := &GlobalConfiguration{}
+
+	cases := []string{
+		`{"site_url": "not a url"}`,
+		`{"uri_allow_list": ["https://example.com/[a"]}`,
+		`{"external": {"unknown": {"enabled": true}}}`,
+		`{"external": {"custom:unknown": {"enabled": true}}}`,
+		`{"external": {"email": {"secret": "secret"}}}`,
+		`{"password": {"min_length": 2}}`,
+		`{"hosts": ["example.com/path"]}`,
+	}
+
+	for _, c := range cases {
+		audience, err := ParseAudienceConfiguration([]byte(c))
+		require.NoError(t, err, c)
+
+		_, err = config.WithAudience(audience)
+		require.Error(t, err, c)
+	}
+
+	_, err := ParseAudienceConfiguration([]byte(`{"site_ulr": "https://example.com"}`))
+	require.Error(t, err)
+}
+
+func TestLoadAudienceConfigurations(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "audiences.json")
+	require.NoError(t, os.WriteFile(filename, []byte(`{
+		"brand-a": {"hosts": ["a.example.com"], "site_url": "https://a.example.com"},
+		"brand-b": {"hosts": ["B.example.com:443"]}
+	}`), 0600))
+
+	audiences, err := loadAudienceConfigurations(filename)
+	require.NoError(t, err)
+	require.Len(t, audiences, 2)
+	require.Equal(t, "https://a.example.com", audiences["brand-a"].SiteURL)
+
+	config := &GlobalConfiguration{Audiences: AudiencesConfiguration{Overlays: audiences}}
+	require.NoError(t, config.validateAudiences())
+
+	audiences["brand-c"] = AudienceConfiguration{Hosts: []string{"b.example.com"}}
+	require.Error(t, config.validateAudiences())
+
+	require.NoError(t, os.WriteFile(filename, []byte(`{"brand-a": {"unknown": true}}`), 0600))
+	_, err = loadAudienceConfigurations(filename)
+	require.Error(t, err)
+
+	audiences, err = loadAudienceConfigurations("")
+	require.NoError(t, err)
+	require.Nil(t, audiences)
+}
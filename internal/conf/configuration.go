@@ -10,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"text/template"
 	"time"
@@ -23,6 +24,10 @@ import (
 )
 
 const defaultMinPasswordLength int = 6
+
+// maxPasswordLength is the longest password accepted, as bcrypt only uses
+// the first 72 bytes.
+const maxPasswordLength int = 72
 const defaultChallengeExpiryDuration float64 = 300
 const defaultFactorExpiryDuration time.Duration = 300 * time.Second
 const defaultFlowStateExpiryDuration time.Duration = 300 * time.Second
@@ -70,6 +75,17 @@ type OAuthProviderConfiguration struct {
 
 type AnonymousProviderConfiguration struct {
 	Enabled bool `json:"enabled" default:"false"`
+
+	// CleanupAfterDays is the number of days after which anonymous users
+	// are deleted. Zero disables the cleanup.
+	CleanupAfterDays int `json:"cleanup_after_days" split_words:"true" default:"30"`
+}
+
+func (a *AnonymousProviderConfiguration) Validate() error {
+	if a.CleanupAfterDays < 0 {
+		return fmt.Errorf("conf: GOTRUE_EXTERNAL_ANONYMOUS_USERS_CLEANUP_AFTER_DAYS must not be negative")
+	}
+	return nil
 }
 
 type EmailProviderConfiguration struct {
@@ -111,6 +127,10 @@ type JWTConfiguration struct {
 	KeyID            string         `json:"key_id" split_words:"true"`
 	Keys             JwtKeysDecoder `json:"keys"`
 	ValidMethods     []string       `json:"-"`
+
+	// RestrictUserAdminPermissions limits admin tokens issued to signed-in
+	// users to the permissions of their admin_permissions claim.
+	RestrictUserAdminPermissions bool `json:"restrict_user_admin_permissions" split_words:"true"`
 }
 
 type MFAFactorTypeConfiguration struct {
@@ -231,15 +251,105 @@ type HIBPConfiguration struct {
 
 	UserAgent string `json:"user_agent" split_words:"true" default:"https://github.com/supabase/gotrue"`
 
+	// OfflinePath is a directory of range files or a filter built with
+	// `gotrue hibp build`, used instead of the HaveIBeenPwned.org API.
+	OfflinePath string `json:"offline_path" split_words:"true"`
+
 	Bloom HIBPBloomConfiguration `json:"bloom"`
 }
 
+// PasswordHashingConfiguration selects the algorithm used for new password
+// hashes. Existing hashes using another algorithm or other parameters are
+// replaced on the next successful sign in.
+type PasswordHashingConfiguration struct {
+	Algorithm string `json:"algorithm" default:"bcrypt"`
+
+	BcryptCost int `json:"bcrypt_cost" split_words:"true" default:"10"`
+
+	// Argon2Memory is in KiB, defaults follow the OWASP recommendation.
+	Argon2Memory  uint32 `json:"argon2_memory" split_words:"true" default:"19456"`
+	Argon2Time    uint32 `json:"argon2_time" split_words:"true" default:"2"`
+	Argon2Threads uint8  `json:"argon2_threads" split_words:"true" default:"1"`
+}
+
+func (c *PasswordHashingConfiguration) Validate() error {
+	switch c.Algorithm {
+	case "bcrypt":
+		// bcrypt.MinCost and bcrypt.MaxCost
+		if c.BcryptCost < 4 || c.BcryptCost > 31 {
+			return fmt.Errorf("conf: GOTRUE_PASSWORD_HASHING_BCRYPT_COST must be between 4 and 31")
+		}
+
+	case "argon2id":
+		if c.Argon2Memory < 8*uint32(c.Argon2Threads) {
+			return fmt.Errorf("conf: GOTRUE_PASSWORD_HASHING_ARGON2_MEMORY must be at least 8 KiB per thread")
+		}
+
+		if c.Argon2Time < 1 {
+			return fmt.Errorf("conf: GOTRUE_PASSWORD_HASHING_ARGON2_TIME must be at least 1")
+		}
+
+		if c.Argon2Threads < 1 {
+			return fmt.Errorf("conf: GOTRUE_PASSWORD_HASHING_ARGON2_THREADS must be at least 1")
+		}
+
+	default:
+		return fmt.Errorf("conf: GOTRUE_PASSWORD_HASHING_ALGORITHM must be one of bcrypt or argon2id, got %q", c.Algorithm)
+	}
+
+	return nil
+}
+
 type PasswordConfiguration struct {
 	MinLength int `json:"min_length" split_words:"true"`
+	MaxLength int `json:"max_length" split_words:"true"`
 
 	RequiredCharacters PasswordRequiredCharacters `json:"required_characters" split_words:"true"`
 
+	// BannedWords are rejected (case-insensitive) anywhere in a password.
+	BannedWords []string `json:"banned_words" split_words:"true"`
+
+	// HistoryCount is the number of previous passwords that can't be
+	// reused.
+	HistoryCount int `json:"history_count" split_words:"true"`
+
+	// MaxAge is how long a password can be used to sign in before it
+	// has to be changed.
+	MaxAge time.Duration `json:"max_age" split_words:"true"`
+
 	HIBP HIBPConfiguration `json:"hibp"`
+
+	Hashing PasswordHashingConfiguration `json:"hashing"`
+}
+
+// HistoryEnabled reports whether password changes need to be recorded in the
+// password history.
+func (c *PasswordConfiguration) HistoryEnabled() bool {
+	return c.HistoryCount > 0 || c.MaxAge > 0
+}
+
+func (c *PasswordConfiguration) Validate() error {
+	if c.MaxLength < 0 {
+		return fmt.Errorf("conf: GOTRUE_PASSWORD_MAX_LENGTH must not be negative")
+	}
+
+	if c.MaxLength > 0 && c.MaxLength < c.MinLength {
+		return fmt.Errorf("conf: GOTRUE_PASSWORD_MAX_LENGTH must not be less than GOTRUE_PASSWORD_MIN_LENGTH")
+	}
+
+	if c.MaxLength > maxPasswordLength {
+		return fmt.Errorf("conf: GOTRUE_PASSWORD_MAX_LENGTH must not be more than %d", maxPasswordLength)
+	}
+
+	if c.HistoryCount < 0 {
+		return fmt.Errorf("conf: GOTRUE_PASSWORD_HISTORY_COUNT must not be negative")
+	}
+
+	if c.MaxAge < 0 {
+		return fmt.Errorf("conf: GOTRUE_PASSWORD_MAX_AGE must not be negative")
+	}
+
+	return c.Hashing.Validate()
 }
 
 // GlobalConfiguration holds all the configuration that applies to all instances.
@@ -267,17 +377,72 @@ type GlobalConfiguration struct {
 	SiteURL         string   `json:"site_url" split_words:"true" required:"true"`
 	URIAllowList    []string `json:"uri_allow_list" split_words:"true"`
 	URIAllowListMap map[string]glob.Glob
-	Password        PasswordConfiguration    `json:"password"`
-	JWT             JWTConfiguration         `json:"jwt"`
-	Mailer          MailerConfiguration      `json:"mailer"`
-	Sms             SmsProviderConfiguration `json:"sms"`
-	DisableSignup   bool                     `json:"disable_signup" split_words:"true"`
-	Hook            HookConfiguration        `json:"hook" split_words:"true"`
-	Security        SecurityConfiguration    `json:"security"`
-	Sessions        SessionsConfiguration    `json:"sessions"`
-	MFA             MFAConfiguration         `json:"MFA"`
-	SAML            SAMLConfiguration        `json:"saml"`
-	CORS            CORSConfiguration        `json:"cors"`
+	Password        PasswordConfiguration     `json:"password"`
+	JWT             JWTConfiguration          `json:"jwt"`
+	Mailer          MailerConfiguration       `json:"mailer"`
+	Sms             SmsProviderConfiguration  `json:"sms"`
+	DisableSignup   bool                      `json:"disable_signup" split_words:"true"`
+	Hook            HookConfiguration         `json:"hook" split_words:"true"`
+	Security        SecurityConfiguration     `json:"security"`
+	Sessions        SessionsConfiguration     `json:"sessions"`
+	MFA             MFAConfiguration          `json:"MFA"`
+	SAML            SAMLConfiguration         `json:"saml"`
+	CORS            CORSConfiguration         `json:"cors"`
+	UserImport      UserImportConfiguration   `json:"user_import" split_words:"true"`
+	UserDeletion    UserDeletionConfiguration `json:"user_deletion" split_words:"true"`
+	Audiences       AudiencesConfiguration    `json:"audiences"`
+}
+
+// UserDeletionConfiguration holds the configuration of self-service account
+// deletion and of the background worker that deletes the accounts once their
+// grace period is over.
+type UserDeletionConfiguration struct {
+	Enabled bool `json:"enabled" default:"false"`
+	// GracePeriod is how long a scheduled deletion can be cancelled by
+	// signing in.
+	GracePeriod time.Duration `json:"grace_period" split_words:"true" default:"720h"`
+	// SoftDelete keeps the user row with obfuscated personal data instead of
+	// deleting it.
+	SoftDelete bool `json:"soft_delete" split_words:"true" default:"false"`
+
+	// WorkerEnabled runs the worker in the API server. It also deletes
+	// accounts scheduled before self-service deletion was disabled.
+	WorkerEnabled bool          `json:"worker_enabled" split_words:"true" default:"true"`
+	PollInterval  time.Duration `json:"poll_interval" split_words:"true" default:"1m"`
+	BatchSize     int           `json:"batch_size" split_words:"true" default:"100"`
+}
+
+func (c *UserDeletionConfiguration) Validate() error {
+	if c.GracePeriod < 0 {
+		return fmt.Errorf("conf: GOTRUE_USER_DELETION_GRACE_PERIOD must not be negative")
+	}
+	if c.PollInterval <= 0 {
+		return fmt.Errorf("conf: GOTRUE_USER_DELETION_POLL_INTERVAL must be positive")
+	}
+	if c.BatchSize < 1 {
+		return fmt.Errorf("conf: GOTRUE_USER_DELETION_BATCH_SIZE must be at least 1")
+	}
+	return nil
+}
+
+// UserImportConfiguration holds the configuration of the background worker
+// that processes bulk user imports.
+type UserImportConfiguration struct {
+	// WorkerEnabled runs the worker in the API server. Imports can
+	// also be processed with the admin import command.
+	WorkerEnabled bool          `json:"worker_enabled" split_words:"true" default:"true"`
+	PollInterval  time.Duration `json:"poll_interval" split_words:"true" default:"5s"`
+	BatchSize     int           `json:"batch_size" split_words:"true" default:"100"`
+}
+
+func (c *UserImportConfiguration) Validate() error {
+	if c.PollInterval <= 0 {
+		return fmt.Errorf("conf: GOTRUE_USER_IMPORT_POLL_INTERVAL must be positive")
+	}
+	if c.BatchSize < 1 {
+		return fmt.Errorf("conf: GOTRUE_USER_IMPORT_BATCH_SIZE must be at least 1")
+	}
+	return nil
 }
 
 type CORSConfiguration struct {
@@ -319,8 +484,10 @@ type ProviderConfiguration struct {
 	Apple                   OAuthProviderConfiguration     `json:"apple"`
 	Azure                   OAuthProviderConfiguration     `json:"azure"`
 	Bitbucket               OAuthProviderConfiguration     `json:"bitbucket"`
+	DingTalk                OAuthProviderConfiguration     `json:"dingtalk"`
 	Discord                 OAuthProviderConfiguration     `json:"discord"`
 	Facebook                OAuthProviderConfiguration     `json:"facebook"`
+	Feishu                  OAuthProviderConfiguration     `json:"feishu"`
 	Figma                   OAuthProviderConfiguration     `json:"figma"`
 	Fly                     OAuthProviderConfiguration     `json:"fly"`
 	Github                  OAuthProviderConfiguration     `json:"github"`
@@ -329,14 +496,19 @@ type ProviderConfiguration struct {
 	Kakao                   OAuthProviderConfiguration     `json:"kakao"`
 	Notion                  OAuthProviderConfiguration     `json:"notion"`
 	Keycloak                OAuthProviderConfiguration     `json:"keycloak"`
+	Lark                    OAuthProviderConfiguration     `json:"lark"`
 	Linkedin                OAuthProviderConfiguration     `json:"linkedin"`
 	LinkedinOIDC            OAuthProviderConfiguration     `json:"linkedin_oidc" envconfig:"LINKEDIN_OIDC"`
+	QQ                      OAuthProviderConfiguration     `json:"qq"`
 	Spotify                 OAuthProviderConfiguration     `json:"spotify"`
 	Slack                   OAuthProviderConfiguration     `json:"slack"`
 	SlackOIDC               OAuthProviderConfiguration     `json:"slack_oidc" envconfig:"SLACK_OIDC"`
 	Twitter                 OAuthProviderConfiguration     `json:"twitter"`
 	Twitch                  OAuthProviderConfiguration     `json:"twitch"`
 	VercelMarketplace       OAuthProviderConfiguration     `json:"vercel_marketplace" split_words:"true"`
+	WeChat                  OAuthProviderConfiguration     `json:"wechat" envconfig:"WECHAT"`
+	WeChatMP                OAuthProviderConfiguration     `json:"wechat_mp" envconfig:"WECHAT_MP"`
+	Weibo                   OAuthProviderConfiguration     `json:"weibo"`
 	WorkOS                  OAuthProviderConfiguration     `json:"workos"`
 	Email                   EmailProviderConfiguration     `json:"email"`
 	Phone                   PhoneProviderConfiguration     `json:"phone"`
@@ -346,12 +518,47 @@ type ProviderConfiguration struct {
 	AllowedIdTokenIssuers   []string                       `json:"allowed_id_token_issuers" split_words:"true"`
 	FlowStateExpiryDuration time.Duration                  `json:"flow_state_expiry_duration" split_words:"true"`
 
-	Web3Solana SolanaConfiguration `json:"web3_solana" split_words:"true"`
+	Web3Solana   SolanaConfiguration   `json:"web3_solana" split_words:"true"`
+	Web3Ethereum EthereumConfiguration `json:"web3_ethereum" split_words:"true"`
+
+	// Custom are loaded from GOTRUE_EXTERNAL_CUSTOM_<NAME>_* variables.
+	Custom CustomOAuthProviders `json:"custom" ignored:"true"`
 }
 
 type SolanaConfiguration struct {
 	Enabled                 bool          `json:"enabled,omitempty" split_words:"true"`
 	MaximumValidityDuration time.Duration `json:"maximum_validity_duration,omitempty" default:"10m" split_words:"true"`
+
+	// RequireNonce requires messages to use a nonce issued by GET
+	// /web3/nonce. Messages can only be used once either way.
+	RequireNonce bool `json:"require_nonce,omitempty" split_words:"true"`
+}
+
+type EthereumConfiguration struct {
+	Enabled                 bool          `json:"enabled,omitempty" split_words:"true"`
+	MaximumValidityDuration time.Duration `json:"maximum_validity_duration,omitempty" default:"10m" split_words:"true"`
+
+	// RequireNonce requires messages to use a nonce issued by GET
+	// /web3/nonce. Messages can only be used once either way.
+	RequireNonce bool `json:"require_nonce,omitempty" split_words:"true"`
+
+	// RPCURLs maps chain IDs to JSON-RPC endpoints, used to verify the
+	// EIP-1271 signatures of smart contract wallets on those chains.
+	RPCURLs map[string]string `json:"rpc_urls,omitempty" envconfig:"RPC_URLS"`
+}
+
+func (c *EthereumConfiguration) Validate() error {
+	for chainID, rpcURL := range c.RPCURLs {
+		if id, err := strconv.ParseUint(chainID, 10, 64); err != nil || id == 0 {
+			return fmt.Errorf("conf: GOTRUE_EXTERNAL_WEB3_ETHEREUM_RPC_URLS has invalid chain ID %q", chainID)
+		}
+
+		if _, err := url.ParseRequestURI(rpcURL); err != nil {
+			return fmt.Errorf("conf: GOTRUE_EXTERNAL_WEB3_ETHEREUM_RPC_URLS has invalid URL for chain %s: %w", chainID, err)
+		}
+	}
+
+	return nil
 }
 
 type SMTPConfiguration struct {
@@ -406,6 +613,10 @@ type MailerConfiguration struct {
 	Templates EmailContentConfiguration `json:"templates"`
 	URLPaths  EmailContentConfiguration `json:"url_paths"`
 
+	TemplateStore MailerTemplateStoreConfiguration `json:"template_store" split_words:"true"`
+
+	Notifications MailerNotificationsConfiguration `json:"notifications"`
+
 	SecureEmailChangeEnabled bool `json:"secure_email_change_enabled" split_words:"true" default:"true"`
 
 	OtpExp    uint `json:"otp_exp" split_words:"true"`
@@ -423,7 +634,84 @@ type MailerConfiguration struct {
 	blockedMXRecords map[string]bool     `json:"-"`
 }
 
+// MailerNotificationsConfiguration configures the security notifications
+// sent to users, which are informational and don't require any action.
+type MailerNotificationsConfiguration struct {
+	NewDevice        MailerNotificationConfiguration `json:"new_device" split_words:"true"`
+	PasswordChanged  MailerNotificationConfiguration `json:"password_changed" split_words:"true"`
+	EmailChanged     MailerNotificationConfiguration `json:"email_changed" split_words:"true"`
+	PhoneChanged     MailerNotificationConfiguration `json:"phone_changed" split_words:"true"`
+	FactorEnrolled   MailerNotificationConfiguration `json:"factor_enrolled" split_words:"true"`
+	FactorRemoved    MailerNotificationConfiguration `json:"factor_removed" split_words:"true"`
+	IdentityLinked   MailerNotificationConfiguration `json:"identity_linked" split_words:"true"`
+	IdentityUnlinked MailerNotificationConfiguration `json:"identity_unlinked" split_words:"true"`
+
+	DeletionScheduled MailerNotificationConfiguration `json:"deletion_scheduled" split_words:"true"`
+
+	// RevokeLinkExpiry is how long the link to revoke the session in new
+	// device notifications stays valid.
+	RevokeLinkExpiry time.Duration `json:"revoke_link_expiry" split_words:"true" default:"168h"`
+}
+
+func (c *MailerNotificationsConfiguration) Validate() error {
+	if c.NewDevice.Enabled && c.RevokeLinkExpiry <= 0 {
+		return fmt.Errorf("conf: GOTRUE_MAILER_NOTIFICATIONS_REVOKE_LINK_EXPIRY must be positive")
+	}
+
+	return nil
+}
+
+// MailerNotificationConfiguration configures a notification. The subject and
+// template URL default to built-in, localized ones.
+type MailerNotificationConfiguration struct {
+	Enabled  bool   `json:"enabled" default:"false"`
+	Subject  string `json:"subject"`
+	Template string `json:"template"`
+}
+
+// MailerTemplateStoreConfiguration configures mail templates managed by Auth
+// itself, instead of being fetched from a URL at send time.
+type MailerTemplateStoreConfiguration struct {
+	// Path is a directory containing one <type>.html template (and
+	// optionally <type>.txt) per mail type, with shared templates in the
+	// layouts/ and partials/ subdirectories.
+	Path string `json:"path"`
+
+	// Database enables templates stored in the mail_templates table, which
+	// take precedence over template files.
+	Database bool `json:"database"`
+
+	// InlineCSS moves the rules of <style> elements into style attributes,
+	// as many mail clients ignore stylesheets.
+	InlineCSS bool `json:"inline_css" split_words:"true" default:"true"`
+}
+
+func (c *MailerTemplateStoreConfiguration) Validate() error {
+	if c.Path == "" {
+		return nil
+	}
+
+	info, err := os.Stat(c.Path)
+	if err != nil {
+		return fmt.Errorf("conf: mailer template store path is not readable: %w", err)
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("conf: mailer template store path %q is not a directory", c.Path)
+	}
+
+	return nil
+}
+
 func (c *MailerConfiguration) Validate() error {
+	if err := c.TemplateStore.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Notifications.Validate(); err != nil {
+		return err
+	}
+
 	headers := make(map[string][]string)
 
 	if c.EmailValidationServiceHeaders != "" {
@@ -534,10 +822,42 @@ type VonageProviderConfiguration struct {
 	From      string `json:"from" split_words:"true"`
 }
 
+const (
+	CaptchaProviderHCaptcha    = "hcaptcha"
+	CaptchaProviderTurnstile   = "turnstile"
+	CaptchaProviderRecaptcha   = "recaptcha"
+	CaptchaProviderRecaptchaV3 = "recaptcha_v3"
+	CaptchaProviderGeeTest     = "geetest"
+	CaptchaProviderTencent     = "tencent"
+)
+
+// CaptchaRoutes are the routes that can require captcha. The otp route
+// includes the magic link endpoint.
+var CaptchaRoutes = []string{"signup", "token", "otp", "recover", "resend", "sso"}
+
 type CaptchaConfiguration struct {
 	Enabled  bool   `json:"enabled" default:"false"`
 	Provider string `json:"provider" default:"hcaptcha"`
 	Secret   string `json:"provider_secret"`
+
+	// AppID is the GeeTest captcha ID or the Tencent Captcha CaptchaAppId.
+	AppID string `json:"app_id" split_words:"true"`
+
+	// TencentSecretID and TencentSecretKey are the Tencent Cloud API
+	// credentials used to verify Tencent Captcha tickets.
+	TencentSecretID  string `json:"tencent_secret_id" split_words:"true"`
+	TencentSecretKey string `json:"-" split_words:"true"`
+
+	// Routes are the routes requiring captcha, all of CaptchaRoutes if
+	// empty.
+	Routes []string `json:"routes"`
+
+	// MinScore is the minimum reCAPTCHA v3 score of requests, which
+	// RouteMinScores overrides per route. RouteActions are the reCAPTCHA
+	// v3 actions expected per route.
+	MinScore       float64            `json:"min_score" split_words:"true" default:"0.5"`
+	RouteMinScores map[string]float64 `json:"route_min_scores" split_words:"true"`
+	RouteActions   map[string]string  `json:"route_actions" split_words:"true"`
 }
 
 func (c *CaptchaConfiguration) Validate() error {
@@ -545,7 +865,24 @@ func (c *CaptchaConfiguration) Validate() error {
 		return nil
 	}
 
-	if c.Provider != "hcaptcha" && c.Provider != "turnstile" {
+	switch c.Provider {
+	case CaptchaProviderHCaptcha, CaptchaProviderTurnstile, CaptchaProviderRecaptcha, CaptchaProviderRecaptchaV3:
+
+	case CaptchaProviderGeeTest:
+		if c.AppID == "" {
+			return errors.New("captcha app ID (the GeeTest captcha ID) is empty")
+		}
+
+	case CaptchaProviderTencent:
+		if _, err := strconv.ParseUint(c.AppID, 10, 64); err != nil {
+			return errors.New("captcha app ID (the Tencent CaptchaAppId) must be a number")
+		}
+
+		if c.TencentSecretID == "" || c.TencentSecretKey == "" {
+			return errors.New("captcha Tencent Cloud secret ID and secret key are required")
+		}
+
+	default:
 		return fmt.Errorf("unsupported captcha provider: %s", c.Provider)
 	}
 
@@ -555,9 +892,235 @@ func (c *CaptchaConfiguration) Validate() error {
 		return errors.New("captcha provider secret is empty")
 	}
 
+	for _, route := range c.Routes {
+		if !isCaptchaRoute(route) {
+			return fmt.Errorf("unsupported captcha route %q, expected one of %s", route, strings.Join(CaptchaRoutes, ", "))
+		}
+	}
+
+	if c.MinScore < 0 || c.MinScore > 1 {
+		return errors.New("captcha minimum score must be between 0 and 1")
+	}
+
+	for route, score := range c.RouteMinScores {
+		if !isCaptchaRoute(route) {
+			return fmt.Errorf("unsupported captcha route %q in route minimum scores", route)
+		}
+
+		if score < 0 || score > 1 {
+			return fmt.Errorf("captcha minimum score of route %q must be between 0 and 1", route)
+		}
+	}
+
+	for route := range c.RouteActions {
+		if !isCaptchaRoute(route) {
+			return fmt.Errorf("unsupported captcha route %q in route actions", route)
+		}
+	}
+
+	return nil
+}
+
+func isCaptchaRoute(route string) bool {
+	for _, r := range CaptchaRoutes {
+		if r == route {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RequiresCaptcha reports whether requests to the route require captcha.
+// Routes that aren't one of CaptchaRoutes always do.
+func (c *CaptchaConfiguration) RequiresCaptcha(route string) bool {
+	if !c.Enabled {
+		return false
+	}
+
+	if len(c.Routes) == 0 || !isCaptchaRoute(route) {
+		return true
+	}
+
+	for _, r := range c.Routes {
+		if r == route {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MinScoreFor returns the minimum reCAPTCHA v3 score of requests to the
+// route.
+func (c *CaptchaConfiguration) MinScoreFor(route string) float64 {
+	if score, ok := c.RouteMinScores[route]; ok {
+		return score
+	}
+
+	return c.MinScore
+}
+
+const (
+	LockoutStrategyLockout = "lockout"
+	LockoutStrategyDelay   = "delay"
+)
+
+// LockoutConfiguration configures the protection of accounts against
+// guessing passwords and MFA codes. Failed attempts are counted per user
+// within a sliding window.
+type LockoutConfiguration struct {
+	Enabled bool `json:"enabled" default:"false"`
+
+	// MaxAttempts is the number of failed attempts within Window after
+	// which further attempts are refused for a while.
+	MaxAttempts int           `json:"max_attempts" split_words:"true" default:"5"`
+	Window      time.Duration `json:"window" default:"15m"`
+
+	// Strategy is either "lockout", refusing attempts for Duration, or
+	// "delay", refusing attempts for DelayBase, doubled for every further
+	// failed attempt up to DelayMax.
+	Strategy  string        `json:"strategy" default:"lockout"`
+	Duration  time.Duration `json:"duration" default:"15m"`
+	DelayBase time.Duration `json:"delay_base" split_words:"true" default:"1s"`
+	DelayMax  time.Duration `json:"delay_max" split_words:"true" default:"15m"`
+}
+
+func (c *LockoutConfiguration) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.MaxAttempts < 1 {
+		return fmt.Errorf("conf: GOTRUE_SECURITY_LOCKOUT_MAX_ATTEMPTS must be at least 1")
+	}
+
+	if c.Window <= 0 {
+		return fmt.Errorf("conf: GOTRUE_SECURITY_LOCKOUT_WINDOW must be positive")
+	}
+
+	switch c.Strategy {
+	case LockoutStrategyLockout:
+		if c.Duration <= 0 {
+			return fmt.Errorf("conf: GOTRUE_SECURITY_LOCKOUT_DURATION must be positive")
+		}
+
+	case LockoutStrategyDelay:
+		if c.DelayBase <= 0 {
+			return fmt.Errorf("conf: GOTRUE_SECURITY_LOCKOUT_DELAY_BASE must be positive")
+		}
+
+		if c.DelayMax < c.DelayBase {
+			return fmt.Errorf("conf: GOTRUE_SECURITY_LOCKOUT_DELAY_MAX must not be less than GOTRUE_SECURITY_LOCKOUT_DELAY_BASE")
+		}
+
+	default:
+		return fmt.Errorf("conf: unsupported lockout strategy %q, expected %q or %q", c.Strategy, LockoutStrategyLockout, LockoutStrategyDelay)
+	}
+
 	return nil
 }
 
+// LockedUntil returns until when attempts are refused after failures failed
+// attempts within the window, the last of them at last. It returns the zero
+// time if attempts aren't refused.
+func (c *LockoutConfiguration) LockedUntil(failures int, last time.Time) time.Time {
+	if !c.Enabled || failures < c.MaxAttempts {
+		return time.Time{}
+	}
+
+	if c.Strategy != LockoutStrategyDelay {
+		return last.Add(c.Duration)
+	}
+
+	delay := c.DelayBase
+	for i := c.MaxAttempts; i < failures && delay < c.DelayMax; i++ {
+		delay *= 2
+	}
+
+	if delay > c.DelayMax {
+		delay = c.DelayMax
+	}
+
+	return last.Add(delay)
+}
+
+const (
+	RiskActionAllow   = "allow"
+	RiskActionCaptcha = "captcha"
+	RiskActionMFA     = "mfa"
+	RiskActionBlock   = "block"
+)
+
+// RiskConfiguration configures the risk engine, which scores sign in
+// attempts from their context and allows them, requires captcha, requires
+// MFA or blocks them depending on the score.
+type RiskConfiguration struct {
+	Enabled bool `json:"enabled" default:"false"`
+
+	// CountryHeader, LatitudeHeader and LongitudeHeader are request headers
+	// with the client's location, which must be set by a trusted proxy.
+	CountryHeader   string `json:"country_header" split_words:"true"`
+	LatitudeHeader  string `json:"latitude_header" split_words:"true"`
+	LongitudeHeader string `json:"longitude_header" split_words:"true"`
+
+	// The score of each signal. FailedAttemptScore is added for every
+	// failed attempt within VelocityWindow.
+	NewDeviceScore        int `json:"new_device_score" split_words:"true" default:"20"`
+	NewCountryScore       int `json:"new_country_score" split_words:"true" default:"30"`
+	ImpossibleTravelScore int `json:"impossible_travel_score" split_words:"true" default:"60"`
+	FailedAttemptScore    int `json:"failed_attempt_score" split_words:"true" default:"10"`
+
+	// MaxTravelSpeed is the speed in km/h above which travel since the
+	// previous sign in is considered impossible.
+	MaxTravelSpeed float64       `json:"max_travel_speed" split_words:"true" default:"1000"`
+	VelocityWindow time.Duration `json:"velocity_window" split_words:"true" default:"1h"`
+
+	// The scores from which captcha or MFA is required, or attempts are
+	// blocked. Zero disables the action.
+	CaptchaThreshold int `json:"captcha_threshold" split_words:"true"`
+	MFAThreshold     int `json:"mfa_threshold" split_words:"true" default:"50"`
+	BlockThreshold   int `json:"block_threshold" split_words:"true" default:"100"`
+}
+
+func (c *RiskConfiguration) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.NewDeviceScore < 0 || c.NewCountryScore < 0 || c.ImpossibleTravelScore < 0 || c.FailedAttemptScore < 0 {
+		return fmt.Errorf("conf: GOTRUE_SECURITY_RISK_*_SCORE must not be negative")
+	}
+
+	if c.CaptchaThreshold < 0 || c.MFAThreshold < 0 || c.BlockThreshold < 0 {
+		return fmt.Errorf("conf: GOTRUE_SECURITY_RISK_*_THRESHOLD must not be negative")
+	}
+
+	if c.MaxTravelSpeed <= 0 {
+		return fmt.Errorf("conf: GOTRUE_SECURITY_RISK_MAX_TRAVEL_SPEED must be positive")
+	}
+
+	if c.VelocityWindow <= 0 {
+		return fmt.Errorf("conf: GOTRUE_SECURITY_RISK_VELOCITY_WINDOW must be positive")
+	}
+
+	return nil
+}
+
+// Action returns the action for an attempt with the score.
+func (c *RiskConfiguration) Action(score int) string {
+	switch {
+	case c.BlockThreshold > 0 && score >= c.BlockThreshold:
+		return RiskActionBlock
+	case c.MFAThreshold > 0 && score >= c.MFAThreshold:
+		return RiskActionMFA
+	case c.CaptchaThreshold > 0 && score >= c.CaptchaThreshold:
+		return RiskActionCaptcha
+	}
+
+	return RiskActionAllow
+}
+
 // DatabaseEncryptionConfiguration configures Auth to encrypt certain columns.
 // Once Encrypt is set to true, data will start getting encrypted with the
 // provided encryption key. Setting it to false just stops encryption from
@@ -613,6 +1176,9 @@ type SecurityConfiguration struct {
 	UpdatePasswordRequireReauthentication bool                 `json:"update_password_require_reauthentication" split_words:"true"`
 	ManualLinkingEnabled                  bool                 `json:"manual_linking_enabled" split_words:"true" default:"false"`
 
+	Lockout LockoutConfiguration `json:"lockout"`
+	Risk    RiskConfiguration    `json:"risk"`
+
 	DBEncryption DatabaseEncryptionConfiguration `json:"database_encryption" split_words:"true"`
 }
 
@@ -621,6 +1187,18 @@ func (c *SecurityConfiguration) Validate() error {
 		return err
 	}
 
+	if err := c.Lockout.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Risk.Validate(); err != nil {
+		return err
+	}
+
+	if c.Risk.Enabled && c.Risk.CaptchaThreshold > 0 && !c.Captcha.Enabled {
+		return fmt.Errorf("conf: GOTRUE_SECURITY_RISK_CAPTCHA_THRESHOLD requires captcha to be enabled")
+	}
+
 	if err := c.DBEncryption.Validate(); err != nil {
 		return err
 	}
@@ -652,6 +1230,7 @@ type HookConfiguration struct {
 
 	BeforeUserCreated ExtensibilityPointConfiguration `json:"before_user_created" split_words:"true"`
 	AfterUserCreated  ExtensibilityPointConfiguration `json:"after_user_created" split_words:"true"`
+	UserMerged        ExtensibilityPointConfiguration `json:"user_merged" split_words:"true"`
 }
 
 type HTTPHookSecrets []string
@@ -685,6 +1264,7 @@ func (h *HookConfiguration) Validate() error {
 		h.SendEmail,
 		h.BeforeUserCreated,
 		h.AfterUserCreated,
+		h.UserMerged,
 	}
 	for _, point := range points {
 		if err := point.ValidateExtensibilityPoint(); err != nil {
@@ -862,6 +1442,18 @@ func loadGlobal(config *GlobalConfiguration) error {
 		return err
 	}
 
+	customProviders, err := loadCustomOAuthProviders()
+	if err != nil {
+		return err
+	}
+	config.External.Custom = customProviders
+
+	audiences, err := loadAudienceConfigurations(config.Audiences.File)
+	if err != nil {
+		return err
+	}
+	config.Audiences.Overlays = audiences
+
 	if err := config.ApplyDefaults(); err != nil {
 		return err
 	}
@@ -914,6 +1506,12 @@ func populateGlobal(config *GlobalConfiguration) error {
 		}
 	}
 
+	if config.Hook.UserMerged.Enabled {
+		if err := config.Hook.UserMerged.PopulateExtensibilityPoint(); err != nil {
+			return err
+		}
+	}
+
 	if config.SAML.Enabled {
 		if err := config.SAML.PopulateFields(config.API.ExternalURL); err != nil {
 			return err
@@ -946,7 +1544,7 @@ func populateGlobal(config *GlobalConfiguration) error {
 		config.MFA.Phone.SMSTemplate = template
 	}
 
-	return nil
+	return config.validateAudiences()
 }
 
 // ApplyDefaults sets defaults for a GlobalConfiguration
@@ -1137,6 +1735,13 @@ func (c *GlobalConfiguration) Validate() error {
 		&c.Sessions,
 		&c.Hook,
 		&c.JWT.Keys,
+		&c.Password,
+		&c.UserImport,
+		&c.UserDeletion,
+		&c.Audiences,
+		&c.External.AnonymousUsers,
+		&c.External.Web3Ethereum,
+		c.External.Custom,
 	}
 
 	for _, validatable := range validatables {
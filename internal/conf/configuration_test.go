@@ -540,6 +540,29 @@ func TestValidate(t *testing.T) {
 			val: &SessionsConfiguration{Timebox: toPtr(time.Duration(1))},
 		},
 
+		{
+			val: &LockoutConfiguration{Enabled: false, Strategy: "invalid"},
+		},
+		{
+			val: &LockoutConfiguration{Enabled: true, MaxAttempts: 5, Window: time.Minute, Strategy: "lockout", Duration: time.Minute},
+		},
+		{
+			val: &LockoutConfiguration{Enabled: true, MaxAttempts: 0, Window: time.Minute, Strategy: "lockout", Duration: time.Minute},
+			err: `conf: GOTRUE_SECURITY_LOCKOUT_MAX_ATTEMPTS must be at least 1`,
+		},
+		{
+			val: &LockoutConfiguration{Enabled: true, MaxAttempts: 5, Window: time.Minute, Strategy: "lockout"},
+			err: `conf: GOTRUE_SECURITY_LOCKOUT_DURATION must be positive`,
+		},
+		{
+			val: &LockoutConfiguration{Enabled: true, MaxAttempts: 5, Window: time.Minute, Strategy: "delay", DelayBase: time.Minute, DelayMax: time.Second},
+			err: `conf: GOTRUE_SECURITY_LOCKOUT_DELAY_MAX must not be less than GOTRUE_SECURITY_LOCKOUT_DELAY_BASE`,
+		},
+		{
+			val: &LockoutConfiguration{Enabled: true, MaxAttempts: 5, Window: time.Minute, Strategy: "invalid"},
+			err: `conf: unsupported lockout strategy "invalid", expected "lockout" or "delay"`,
+		},
+
 		{
 			val: &SMTPConfiguration{},
 		},
@@ -900,6 +923,35 @@ func TestValidate(t *testing.T) {
 				require.NoError(t, err)
 			},
 		},
+		{
+			val: &PasswordHashingConfiguration{Algorithm: "bcrypt", BcryptCost: 12},
+		},
+		{
+			val: &PasswordHashingConfiguration{Algorithm: "bcrypt", BcryptCost: 3},
+			err: `GOTRUE_PASSWORD_HASHING_BCRYPT_COST must be between 4 and 31`,
+		},
+		{
+			val: &PasswordHashingConfiguration{Algorithm: "argon2id", Argon2Memory: 19456, Argon2Time: 2, Argon2Threads: 1},
+		},
+		{
+			val: &PasswordHashingConfiguration{Algorithm: "argon2id", Argon2Memory: 8, Argon2Time: 1, Argon2Threads: 2},
+			err: `GOTRUE_PASSWORD_HASHING_ARGON2_MEMORY must be at least 8 KiB per thread`,
+		},
+		{
+			val: &PasswordHashingConfiguration{Algorithm: "argon2id", Argon2Memory: 19456, Argon2Time: 0, Argon2Threads: 1},
+			err: `GOTRUE_PASSWORD_HASHING_ARGON2_TIME must be at least 1`,
+		},
+		{
+			val: &PasswordHashingConfiguration{Algorithm: "scrypt"},
+			err: `GOTRUE_PASSWORD_HASHING_ALGORITHM must be one of bcrypt or argon2id`,
+		},
+		{
+			val: &PasswordConfiguration{
+				MaxLength: 100,
+				Hashing:   PasswordHashingConfiguration{Algorithm: "bcrypt", BcryptCost: 10},
+			},
+			err: `GOTRUE_PASSWORD_MAX_LENGTH must not be more than 72`,
+		},
 	}
 
 	for idx, tc := range cases {
@@ -1221,3 +1273,95 @@ func TestLoading(t *testing.T) {
 func toPtr[T any](v T) *T {
 	return &(&([1]T{T(v)}))[0]
 }
+
+func TestLockoutLockedUntil(t *testing.T) {
+	last := time.Now()
+
+	lockout := &LockoutConfiguration{
+		Enabled:     true,
+		MaxAttempts: 3,
+		Strategy:    LockoutStrategyLockout,
+		Duration:    15 * time.Minute,
+	}
+	require.True(t, lockout.LockedUntil(2, last).IsZero())
+	require.Equal(t, last.Add(15*time.Minute), lockout.LockedUntil(3, last))
+	require.Equal(t, last.Add(15*time.Minute), lockout.LockedUntil(10, last))
+
+	delay := &LockoutConfiguration{
+		Enabled:     true,
+		MaxAttempts: 3,
+		Strategy:    LockoutStrategyDelay,
+		DelayBase:   time.Second,
+		DelayMax:    time.Minute,
+	}
+	require.True(t, delay.LockedUntil(2, last).IsZero())
+	require.Equal(t, last.Add(time.Second), delay.LockedUntil(3, last))
+	require.Equal(t, last.Add(2*time.Second), delay.LockedUntil(4, last))
+	require.Equal(t, last.Add(8*time.Second), delay.LockedUntil(6, last))
+	require.Equal(t, last.Add(time.Minute), delay.LockedUntil(100, last))
+
+	delay.Enabled = false
+	require.True(t, delay.LockedUntil(100, last).IsZero())
+}
+
+func TestRiskAction(t *testing.T) {
+	risk := &RiskConfiguration{
+		Enabled:          true,
+		MaxTravelSpeed:   1000,
+		VelocityWindow:   time.Hour,
+		CaptchaThreshold: 20,
+		MFAThreshold:     50,
+		BlockThreshold:   100,
+	}
+	require.NoError(t, risk.Validate())
+
+	require.Equal(t, RiskActionAllow, risk.Action(0))
+	require.Equal(t, RiskActionCaptcha, risk.Action(20))
+	require.Equal(t, RiskActionMFA, risk.Action(60))
+	require.Equal(t, RiskActionBlock, risk.Action(150))
+
+	risk.BlockThreshold = 0
+	require.Equal(t, RiskActionMFA, risk.Action(150))
+
+	risk.MFAThreshold = -1
+	require.Error(t, risk.Validate())
+}
+
+func TestCaptchaRoutes(t *testing.T) {
+	c := &CaptchaConfiguration{
+		Enabled:        true,
+		Provider:       CaptchaProviderRecaptchaV3,
+		Secret:         "secret",
+		Routes:         []string{"signup", "recover"},
+		MinScore:       0.5,
+		RouteMinScores: map[string]float64{"signup": 0.7},
+	}
+	require.NoError(t, c.Validate())
+
+	require.True(t, c.RequiresCaptcha("signup"))
+	require.False(t, c.RequiresCaptcha("token"))
+	require.True(t, c.RequiresCaptcha(""))
+	require.Equal(t, 0.7, c.MinScoreFor("signup"))
+	require.Equal(t, 0.5, c.MinScoreFor("recover"))
+
+	c.Routes = nil
+	require.True(t, c.RequiresCaptcha("token"))
+
+	c.Routes = []string{"login"}
+	require.EqualError(t, c.Validate(), `unsupported captcha route "login", expected one of signup, token, otp, recover, resend, sso`)
+
+	c.Routes = nil
+	c.RouteMinScores = map[string]float64{"signup": 2}
+	require.EqualError(t, c.Validate(), `captcha minimum score of route "signup" must be between 0 and 1`)
+
+	c.Enabled = false
+	require.False(t, c.RequiresCaptcha("signup"))
+
+	tencent := &CaptchaConfiguration{
+		Enabled:  true,
+		Provider: CaptchaProviderTencent,
+		Secret:   "secret",
+		AppID:    "not-a-number",
+	}
+	require.EqualError(t, tencent.Validate(), "captcha app ID (the Tencent CaptchaAppId) must be a number")
+}
@@ -0,0 +1,136 @@
+package conf
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/kelseyhightower/envconfig"
+)
+
+// CustomProviderPrefix prefixes the names of custom OAuth providers, to keep
+// them apart from the built in providers.
+const CustomProviderPrefix = "custom:"
+
+const customProviderEnvPrefix = "GOTRUE_EXTERNAL_CUSTOM_"
+
+var customProviderNameRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9_]*$`)
+
+// CustomOAuthProviderConfiguration configures a generic OAuth2 or OpenID
+// Connect provider, such as an internal corporate identity provider.
+//
+// OpenID Connect providers only need an Issuer, the endpoints are discovered
+// from it. Plain OAuth2 providers need explicit authorization, token and
+// userinfo URLs instead.
+type CustomOAuthProviderConfiguration struct {
+	OAuthProviderConfiguration
+
+	Issuer           string   `json:"issuer"`
+	AuthorizationURL string   `json:"authorization_url" split_words:"true"`
+	TokenURL         string   `json:"token_url" split_words:"true"`
+	UserinfoURL      string   `json:"userinfo_url" split_words:"true"`
+	Scopes           []string `json:"scopes"`
+
+	// ClaimMapping maps claims, such as sub or email, to JSONPath
+	// expressions evaluated against the userinfo response or ID token.
+	// Claims that aren't mapped are read from the standard OpenID Connect
+	// claim of the same name.
+	ClaimMapping map[string]string `json:"claim_mapping" split_words:"true"`
+}
+
+func (c *CustomOAuthProviderConfiguration) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.Issuer == "" && (c.AuthorizationURL == "" || c.TokenURL == "" || c.UserinfoURL == "") {
+		return errors.New("issuer or authorization, token and userinfo URLs are required")
+	}
+
+	for claim, path := range c.ClaimMapping {
+		if claim == "" || !strings.HasPrefix(path, "$") {
+			return fmt.Errorf("invalid claim mapping %q: %q, expected a claim name and a JSONPath expression starting with $", claim, path)
+		}
+	}
+
+	return nil
+}
+
+// CustomOAuthProviders holds the custom OAuth providers by name.
+type CustomOAuthProviders map[string]CustomOAuthProviderConfiguration
+
+func (c CustomOAuthProviders) Validate() error {
+	for name, provider := range c {
+		if !customProviderNameRegexp.MatchString(name) {
+			return fmt.Errorf("conf: custom provider name %q must only contain lowercase letters, digits and underscores", name)
+		}
+
+		if err := provider.Validate(); err != nil {
+			return fmt.Errorf("conf: custom provider %q: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// CustomProvider returns the custom provider configuration for a provider
+// name such as custom:corp.
+func (c *ProviderConfiguration) CustomProvider(name string) (*CustomOAuthProviderConfiguration, bool) {
+	if !strings.HasPrefix(name, CustomProviderPrefix) {
+		return nil, false
+	}
+
+	provider, ok := c.Custom[strings.TrimPrefix(name, CustomProviderPrefix)]
+	if !ok {
+		return nil, false
+	}
+
+	return &provider, true
+}
+
+// loadCustomOAuthProviders loads the custom providers defined with
+// GOTRUE_EXTERNAL_CUSTOM_<NAME>_* variables. A provider is defined by its
+// GOTRUE_EXTERNAL_CUSTOM_<NAME>_ENABLED variable, and its name is <NAME> in
+// lowercase.
+func loadCustomOAuthProviders() (CustomOAuthProviders, error) {
+	providers := make(CustomOAuthProviders)
+
+	for _, env := range os.Environ() {
+		key, _, _ := strings.Cut(env, "=")
+		if !strings.HasPrefix(key, customProviderEnvPrefix) || !strings.HasSuffix(key, "_ENABLED") {
+			continue
+		}
+
+		envName := strings.TrimSuffix(strings.TrimPrefix(key, customProviderEnvPrefix), "_ENABLED")
+		if envName == "" {
+			continue
+		}
+
+		var provider CustomOAuthProviderConfiguration
+		if err := envconfig.Process(customProviderEnvPrefix+envName, &provider); err != nil {
+			return nil, err
+		}
+
+		providers[strings.ToLower(envName)] = provider
+	}
+
+	return providers, nil
+}
+
+// CustomProviderByIssuer returns the name, including its prefix, and the
+// configuration of the enabled custom provider with the issuer.
+func (c *ProviderConfiguration) CustomProviderByIssuer(issuer string) (string, *CustomOAuthProviderConfiguration, bool) {
+	if issuer == "" {
+		return "", nil, false
+	}
+
+	for name, provider := range c.Custom {
+		if provider.Enabled && provider.Issuer == issuer {
+			return CustomProviderPrefix + name, &provider, true
+		}
+	}
+
+	return "", nil, false
+}
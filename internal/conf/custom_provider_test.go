@@ -0,0 +1,111 @@
+package conf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadCustomOAuthProviders(t *testing.T) {
+	t.Setenv("GOTRUE_EXTERNAL_CUSTOM_CORP_ENABLED", "true")
+	t.Setenv("GOTRUE_EXTERNAL_CUSTOM_CORP_CLIENT_ID", "client")
+	t.Setenv("GOTRUE_EXTERNAL_CUSTOM_CORP_SECRET", "secret")
+	t.Setenv("GOTRUE_EXTERNAL_CUSTOM_CORP_ISSUER", "https://idp.example.com")
+	t.Setenv("GOTRUE_EXTERNAL_CUSTOM_CORP_SCOPES", "openid,groups")
+	t.Setenv("GOTRUE_EXTERNAL_CUSTOM_CORP_CLAIM_MAPPING", "sub:$.employee_id,email:$.mail")
+	t.Setenv("GOTRUE_EXTERNAL_CUSTOM_LEGACY_SSO_ENABLED", "false")
+	t.Setenv("GOTRUE_EXTERNAL_CUSTOM_LEGACY_SSO_AUTHORIZATION_URL", "https://legacy.example.com/authorize")
+
+	providers, err := loadCustomOAuthProviders()
+	require.NoError(t, err)
+	require.Len(t, providers, 2)
+	require.NoError(t, providers.Validate())
+
+	corp := providers["corp"]
+	require.True(t, corp.Enabled)
+	require.Equal(t, []string{"client"}, corp.ClientID)
+	require.Equal(t, "secret", corp.Secret)
+	require.Equal(t, "https://idp.example.com", corp.Issuer)
+	require.Equal(t, []string{"openid", "groups"}, corp.Scopes)
+	require.Equal(t, map[string]string{"sub": "$.employee_id", "email": "$.mail"}, corp.ClaimMapping)
+
+	legacy := providers["legacy_sso"]
+	require.False(t, legacy.Enabled)
+	require.Equal(t, "https://legacy.example.com/authorize", legacy.AuthorizationURL)
+
+	config := ProviderConfiguration{Custom: providers}
+
+	p, ok := config.CustomProvider("custom:corp")
+	require.True(t, ok)
+	require.Equal(t, "https://idp.example.com", p.Issuer)
+
+	_, ok = config.CustomProvider("corp")
+	require.False(t, ok)
+
+	name, _, ok := config.CustomProviderByIssuer("https://idp.example.com")
+	require.True(t, ok)
+	require.Equal(t, "custom:corp", name)
+
+	_, _, ok = config.CustomProviderByIssuer("https://legacy.example.com")
+	require.False(t, ok)
+}
+
+func TestCustomOAuthProvidersValidate(t *testing.T) {
+	enabled := OAuthProviderConfiguration{Enabled: true}
+
+	cases := []struct {
+		desc      string
+		providers CustomOAuthProviders
+		valid     bool
+	}{
+		{
+			desc:      "issuer",
+			providers: CustomOAuthProviders{"corp": {OAuthProviderConfiguration: enabled, Issuer: "https://idp.example.com"}},
+			valid:     true,
+		},
+		{
+			desc: "explicit URLs",
+			providers: CustomOAuthProviders{"corp": {
+				OAuthProviderConfiguration: enabled,
+				AuthorizationURL:           "https://idp.example.com/authorize",
+				TokenURL:                   "https://idp.example.com/token",
+				UserinfoURL:                "https://idp.example.com/userinfo",
+			}},
+			valid: true,
+		},
+		{
+			desc:      "disabled without endpoints",
+			providers: CustomOAuthProviders{"corp": {}},
+			valid:     true,
+		},
+		{
+			desc: "missing userinfo URL",
+			providers: CustomOAuthProviders{"corp": {
+				OAuthProviderConfiguration: enabled,
+				AuthorizationURL:           "https://idp.example.com/authorize",
+				TokenURL:                   "https://idp.example.com/token",
+			}},
+		},
+		{
+			desc: "invalid claim mapping",
+			providers: CustomOAuthProviders{"corp": {
+				OAuthProviderConfiguration: enabled,
+				Issuer:                     "https://idp.example.com",
+				ClaimMapping:               map[string]string{"sub": "id"},
+			}},
+		},
+		{
+			desc:      "invalid name",
+			providers: CustomOAuthProviders{"Corp-IdP": {}},
+		},
+	}
+
+	for _, c := range cases {
+		err := c.providers.Validate()
+		if c.valid {
+			require.NoError(t, err, c.desc)
+		} else {
+			require.Error(t, err, c.desc)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package crypto
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base32"
@@ -33,6 +34,20 @@ func GenerateTokenHash(emailOrPhone, otp string) string {
 	return fmt.Sprintf("%x", sha256.Sum224([]byte(emailOrPhone+otp)))
 }
 
+// GenerateAudienceSignature signs the audience of an email link, bound to the
+// token of the link so that it can't be moved to another link.
+func GenerateAudienceSignature(secret, aud, token string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(aud + "\x00" + token))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyAudienceSignature checks the signature of the audience of an email
+// link.
+func VerifyAudienceSignature(secret, aud, token, signature string) bool {
+	return hmac.Equal([]byte(GenerateAudienceSignature(secret, aud, token)), []byte(signature))
+}
+
 // Generated a random secure integer from [0, max[
 func secureRandomInt(max int) int {
 	randomInt := must(rand.Int(rand.Reader, big.NewInt(int64(max))))
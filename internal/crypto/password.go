@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/rand"
 	"crypto/subtle"
 	"encoding/base64"
 	"errors"
@@ -43,6 +44,103 @@ const (
 // GenerateHashFromPassword.
 var PasswordHashCost = DefaultHashCost
 
+const (
+	BcryptAlgorithm   = "bcrypt"
+	Argon2idAlgorithm = "argon2id"
+
+	argon2SaltLen = 16
+	argon2KeyLen  = 32
+)
+
+// PasswordHashingParameters are the algorithm and its parameters used for new
+// password hashes.
+type PasswordHashingParameters struct {
+	Algorithm string
+
+	BcryptCost int
+
+	// Argon2Memory is in KiB.
+	Argon2Memory  uint32
+	Argon2Time    uint32
+	Argon2Threads uint8
+}
+
+// PasswordHashing holds the algorithm and parameters for all new hashes
+// generated with GenerateFromPassword. Hashes using other algorithms or
+// parameters are reported by NeedsRehash.
+var PasswordHashing = PasswordHashingParameters{
+	Algorithm:     BcryptAlgorithm,
+	BcryptCost:    bcrypt.DefaultCost,
+	Argon2Memory:  19 * 1024,
+	Argon2Time:    2,
+	Argon2Threads: 1,
+}
+
+// effective returns the parameters adjusted for PasswordHashCost.
+func (p PasswordHashingParameters) effective() PasswordHashingParameters {
+	if PasswordHashCost == QuickHashCost {
+		p.BcryptCost = bcrypt.MinCost
+		p.Argon2Memory = 64
+		p.Argon2Time = 1
+		p.Argon2Threads = 1
+	}
+
+	if p.BcryptCost == 0 {
+		p.BcryptCost = bcrypt.DefaultCost
+	}
+
+	return p
+}
+
+// PasswordHashPattern returns a POSIX regular expression matching the hashes
+// generated with the current PasswordHashing parameters.
+func PasswordHashPattern() string {
+	p := PasswordHashing.effective()
+
+	if p.Algorithm == Argon2idAlgorithm {
+		return fmt.Sprintf(`^\$argon2id\$v=%d\$m=%d,t=%d,p=%d\$`, argon2.Version, p.Argon2Memory, p.Argon2Time, p.Argon2Threads)
+	}
+
+	return fmt.Sprintf(`^\$2[aby]\$%02d\$`, p.BcryptCost)
+}
+
+// NeedsRehash reports whether a (decrypted) password hash was not generated
+// with the current PasswordHashing algorithm and parameters, and should be
+// replaced on the next successful sign in.
+func NeedsRehash(hash string) bool {
+	p := PasswordHashing.effective()
+
+	switch p.Algorithm {
+	case Argon2idAlgorithm:
+		if !strings.HasPrefix(hash, Argon2Prefix) {
+			return true
+		}
+
+		input, err := ParseArgon2Hash(hash)
+		if err != nil {
+			return true
+		}
+
+		return input.alg != Argon2idAlgorithm ||
+			input.memory != uint64(p.Argon2Memory) ||
+			input.time != uint64(p.Argon2Time) ||
+			input.threads != uint64(p.Argon2Threads) ||
+			len(input.rawHash) != argon2KeyLen
+
+	default:
+		if strings.HasPrefix(hash, Argon2Prefix) || strings.HasPrefix(hash, FirebaseScryptPrefix) {
+			return true
+		}
+
+		cost, err := bcrypt.Cost([]byte(hash))
+		if err != nil {
+			return true
+		}
+
+		return cost != p.BcryptCost
+	}
+}
+
 var (
 	generateFromPasswordSubmittedCounter = observability.ObtainMetricCounter("gotrue_generate_from_password_submitted", "Number of submitted GenerateFromPassword hashing attempts")
 	generateFromPasswordCompletedCounter = observability.ObtainMetricCounter("gotrue_generate_from_password_completed", "Number of completed GenerateFromPassword hashing attempts")
@@ -331,6 +429,8 @@ func CompareHashAndPassword(ctx context.Context, hash, password string) error {
 		return compareHashAndPasswordArgon2(ctx, hash, password)
 	} else if strings.HasPrefix(hash, FirebaseScryptPrefix) {
 		return compareHashAndPasswordFirebaseScrypt(ctx, hash, password)
+	} else if ok, err := compareHashAndPasswordLegacy(ctx, hash, password); ok {
+		return err
 	}
 
 	// assume bcrypt
@@ -358,30 +458,56 @@ func CompareHashAndPassword(ctx context.Context, hash, password string) error {
 	return err
 }
 
-// GenerateFromPassword generates a password hash from a
-// password, using PasswordHashCost. Context can be used to cancel the hashing
-// if the algorithm supports it.
+// GenerateFromPassword generates a password hash from a password, using the
+// PasswordHashing algorithm and parameters adjusted for PasswordHashCost.
+// Context can be used to cancel the hashing if the algorithm supports it.
 func GenerateFromPassword(ctx context.Context, password string) (string, error) {
-	hashCost := bcrypt.DefaultCost
+	p := PasswordHashing.effective()
 
-	switch PasswordHashCost {
-	case QuickHashCost:
-		hashCost = bcrypt.MinCost
+	if p.Algorithm == Argon2idAlgorithm {
+		return generateFromPasswordArgon2id(ctx, password, p)
 	}
 
 	attributes := []attribute.KeyValue{
 		attribute.String("alg", "bcrypt"),
-		attribute.Int("bcrypt_cost", hashCost),
+		attribute.Int("bcrypt_cost", p.BcryptCost),
 	}
 
 	generateFromPasswordSubmittedCounter.Add(ctx, 1, metric.WithAttributes(attributes...))
 	defer generateFromPasswordCompletedCounter.Add(ctx, 1, metric.WithAttributes(attributes...))
 
-	hash := must(bcrypt.GenerateFromPassword([]byte(password), hashCost))
+	hash := must(bcrypt.GenerateFromPassword([]byte(password), p.BcryptCost))
 
 	return string(hash), nil
 }
 
+func generateFromPasswordArgon2id(ctx context.Context, password string, p PasswordHashingParameters) (string, error) {
+	attributes := []attribute.KeyValue{
+		attribute.String("alg", Argon2idAlgorithm),
+		attribute.Int64("m", int64(p.Argon2Memory)),
+		attribute.Int64("t", int64(p.Argon2Time)),
+		attribute.Int("p", int(p.Argon2Threads)),
+	}
+
+	generateFromPasswordSubmittedCounter.Add(ctx, 1, metric.WithAttributes(attributes...))
+	defer generateFromPasswordCompletedCounter.Add(ctx, 1, metric.WithAttributes(attributes...))
+
+	salt := make([]byte, argon2SaltLen)
+	must(rand.Read(salt))
+
+	key := argon2.IDKey([]byte(password), salt, p.Argon2Time, p.Argon2Memory, p.Argon2Threads, argon2KeyLen)
+
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		p.Argon2Memory,
+		p.Argon2Time,
+		p.Argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
 func GeneratePassword(requiredChars []string, length int) string {
 	passwordBuilder := strings.Builder{}
 	passwordBuilder.Grow(length)
@@ -0,0 +1,474 @@
+package crypto
+
+import (
+	"context"
+	"crypto/sha1" // #nosec G505 -- required to verify imported legacy hashes
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Prefixes of the password hash formats that can be imported from other
+// systems. Hashes in these formats are only verified, new hashes always use
+// PasswordHashing.
+const (
+	PBKDF2Prefix       = "$pbkdf2"
+	DjangoPBKDF2Prefix = "pbkdf2_"
+	ScryptPrefix       = "$scrypt$"
+)
+
+// Upper bounds of the cost parameters of imported hashes, so that a hash
+// can't make verifying its password take unbounded CPU time or memory. They
+// are well above the defaults of the systems the hashes are imported from.
+const (
+	// maxPBKDF2Work bounds the iterations times the number of blocks of
+	// the derived key, which is what PBKDF2 computes.
+	maxPBKDF2Work = 2_000_000
+	// maxPBKDF2KeyLength bounds the length of the derived key.
+	maxPBKDF2KeyLength = 64
+	// maxScryptMemory bounds N*r, as scrypt uses 128*N*r bytes of memory.
+	maxScryptMemory = 1 << 21
+	// maxScryptWork bounds N*r*p.
+	maxScryptWork = 1 << 24
+)
+
+var ErrPBKDF2MismatchedHashAndPassword = errors.New("crypto: pbkdf2 hash and password mismatch")
+var ErrPHCScryptMismatchedHashAndPassword = errors.New("crypto: scrypt hash and password mismatch")
+
+// pbkdf2HashRegexp matches the passlib / PHC format, where the salt and hash
+// use the adapted base64 alphabet: https://passlib.readthedocs.io/en/stable/lib/passlib.hash.pbkdf2_digest.html
+var pbkdf2HashRegexp = regexp.MustCompile(`^\$pbkdf2(?:-(?P<alg>sha1|sha256|sha512))?\$(?:i=)?(?P<i>[0-9]+)(?:,l=[0-9]+)?\$(?P<salt>[^$]+)\$(?P<hash>[^$]+)$`)
+
+// djangoPBKDF2HashRegexp matches the Django format: https://docs.djangoproject.com/en/stable/topics/auth/passwords/#how-django-stores-passwords
+var djangoPBKDF2HashRegexp = regexp.MustCompile(`^pbkdf2_(?P<alg>sha1|sha256)\$(?P<i>[0-9]+)\$(?P<salt>[^$]+)\$(?P<hash>[^$]+)$`)
+
+// scryptHashRegexp matches the PHC scrypt format: https://github.com/P-H-C/phc-string-format/blob/master/phc-sf-spec.md
+var scryptHashRegexp = regexp.MustCompile(`^\$scrypt\$ln=(?P<ln>[0-9]+),r=(?P<r>[0-9]+),p=(?P<p>[0-9]+)\$(?P<salt>[^$]+)\$(?P<hash>[^$]+)$`)
+
+type PBKDF2HashInput struct {
+	format     string
+	alg        string
+	iterations uint64
+	salt       []byte
+	rawHash    []byte
+}
+
+type ScryptHashInput struct {
+	memoryPower uint64
+	rounds      uint64
+	threads     uint64
+	salt        []byte
+	rawHash     []byte
+}
+
+func pbkdf2HashFunc(alg string) func() hash.Hash {
+	switch alg {
+	case "sha1":
+		return sha1.New
+	case "sha512":
+		return sha512.New
+	default:
+		return sha256.New
+	}
+}
+
+// decodeAdaptedBase64 decodes passlib's base64 variant, which uses . instead
+// of + and omits padding.
+func decodeAdaptedBase64(s string) ([]byte, error) {
+	return base64.RawStdEncoding.DecodeString(strings.ReplaceAll(s, ".", "+"))
+}
+
+// checkPBKDF2Cost rejects PBKDF2 hashes that are too expensive to verify.
+func checkPBKDF2Cost(input *PBKDF2HashInput) error {
+	if len(input.rawHash) > maxPBKDF2KeyLength {
+		return fmt.Errorf("crypto: pbkdf2 hash is longer than %d bytes", maxPBKDF2KeyLength)
+	}
+
+	hashLen := uint64(pbkdf2HashFunc(input.alg)().Size()) // #nosec G115
+	blocks := (uint64(len(input.rawHash)) + hashLen - 1) / hashLen
+	if input.iterations > maxPBKDF2Work/blocks {
+		return fmt.Errorf("crypto: pbkdf2 hash has too many iterations i=%d", input.iterations)
+	}
+
+	return nil
+}
+
+func parseIterations(i string) (uint64, error) {
+	iterations, err := strconv.ParseUint(i, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("crypto: pbkdf2 hash has invalid iterations %q %w", i, err)
+	}
+	if iterations == 0 {
+		return 0, errors.New("crypto: pbkdf2 hash has invalid iterations=0")
+	}
+
+	return iterations, nil
+}
+
+// ParsePBKDF2Hash parses a PBKDF2 hash in the passlib / PHC format
+// ($pbkdf2-sha256$29000$salt$hash) or the Django format
+// (pbkdf2_sha256$600000$salt$hash).
+func ParsePBKDF2Hash(hash string) (*PBKDF2HashInput, error) {
+	if strings.HasPrefix(hash, DjangoPBKDF2Prefix) {
+		submatch := djangoPBKDF2HashRegexp.FindStringSubmatchIndex(hash)
+		if submatch == nil {
+			return nil, errors.New("crypto: incorrect django pbkdf2 hash format")
+		}
+
+		alg := string(djangoPBKDF2HashRegexp.ExpandString(nil, "$alg", hash, submatch))
+		i := string(djangoPBKDF2HashRegexp.ExpandString(nil, "$i", hash, submatch))
+		salt := string(djangoPBKDF2HashRegexp.ExpandString(nil, "$salt", hash, submatch))
+		hashB64 := string(djangoPBKDF2HashRegexp.ExpandString(nil, "$hash", hash, submatch))
+
+		iterations, err := parseIterations(i)
+		if err != nil {
+			return nil, err
+		}
+
+		rawHash, err := base64.StdEncoding.DecodeString(hashB64)
+		if err != nil {
+			return nil, fmt.Errorf("crypto: django pbkdf2 hash has invalid base64 in the hash section %w", err)
+		}
+		if len(rawHash) == 0 {
+			return nil, errors.New("crypto: django pbkdf2 hash is empty")
+		}
+
+		input := &PBKDF2HashInput{
+			format:     "django",
+			alg:        alg,
+			iterations: iterations,
+			// Django uses the salt as-is, it is not encoded
+			salt:    []byte(salt),
+			rawHash: rawHash,
+		}
+		if err := checkPBKDF2Cost(input); err != nil {
+			return nil, err
+		}
+
+		return input, nil
+	}
+
+	submatch := pbkdf2HashRegexp.FindStringSubmatchIndex(hash)
+	if submatch == nil {
+		return nil, errors.New("crypto: incorrect pbkdf2 hash format")
+	}
+
+	alg := string(pbkdf2HashRegexp.ExpandString(nil, "$alg", hash, submatch))
+	i := string(pbkdf2HashRegexp.ExpandString(nil, "$i", hash, submatch))
+	saltB64 := string(pbkdf2HashRegexp.ExpandString(nil, "$salt", hash, submatch))
+	hashB64 := string(pbkdf2HashRegexp.ExpandString(nil, "$hash", hash, submatch))
+
+	if alg == "" {
+		alg = "sha1"
+	}
+
+	iterations, err := parseIterations(i)
+	if err != nil {
+		return nil, err
+	}
+
+	salt, err := decodeAdaptedBase64(saltB64)
+	if err != nil {
+		return nil, fmt.Errorf("crypto: pbkdf2 hash has invalid base64 in the salt section %w", err)
+	}
+
+	rawHash, err := decodeAdaptedBase64(hashB64)
+	if err != nil {
+		return nil, fmt.Errorf("crypto: pbkdf2 hash has invalid base64 in the hash section %w", err)
+	}
+	if len(rawHash) == 0 {
+		return nil, errors.New("crypto: pbkdf2 hash is empty")
+	}
+
+	input := &PBKDF2HashInput{
+		format:     "phc",
+		alg:        alg,
+		iterations: iterations,
+		salt:       salt,
+		rawHash:    rawHash,
+	}
+	if err := checkPBKDF2Cost(input); err != nil {
+		return nil, err
+	}
+
+	return input, nil
+}
+
+// ParseASPNETIdentityHash parses a base64 encoded ASP.NET Identity password
+// hash, either version 2 (PBKDF2-SHA1 with 1000 iterations) or version 3
+// (PBKDF2 with the PRF and iterations stored in the hash). See:
+// https://github.com/dotnet/aspnetcore/blob/main/src/Identity/Extensions.Core/src/PasswordHasher.cs
+func ParseASPNETIdentityHash(hash string) (*PBKDF2HashInput, error) {
+	data, err := base64.StdEncoding.DecodeString(hash)
+	if err != nil {
+		return nil, fmt.Errorf("crypto: ASP.NET Identity hash has invalid base64 %w", err)
+	}
+
+	if len(data) == 0 {
+		return nil, errors.New("crypto: ASP.NET Identity hash is empty")
+	}
+
+	switch data[0] {
+	case 0x00:
+		// 1 byte format marker, 16 bytes salt, 32 bytes subkey
+		if len(data) != 1+16+32 {
+			return nil, errors.New("crypto: ASP.NET Identity v2 hash has invalid length")
+		}
+
+		return &PBKDF2HashInput{
+			format:     "aspnet_v2",
+			alg:        "sha1",
+			iterations: 1000,
+			salt:       data[1:17],
+			rawHash:    data[17:],
+		}, nil
+
+	case 0x01:
+		// 1 byte format marker, uint32 PRF, uint32 iterations, uint32 salt
+		// length, salt and subkey, all big-endian
+		if len(data) < 13 {
+			return nil, errors.New("crypto: ASP.NET Identity v3 hash is too short")
+		}
+
+		var alg string
+		switch binary.BigEndian.Uint32(data[1:5]) {
+		case 0:
+			alg = "sha1"
+		case 1:
+			alg = "sha256"
+		case 2:
+			alg = "sha512"
+		default:
+			return nil, errors.New("crypto: ASP.NET Identity v3 hash uses an unsupported PRF")
+		}
+
+		iterations := uint64(binary.BigEndian.Uint32(data[5:9]))
+		if iterations == 0 {
+			return nil, errors.New("crypto: ASP.NET Identity v3 hash has invalid iterations=0")
+		}
+
+		saltLen := uint64(binary.BigEndian.Uint32(data[9:13]))
+		if saltLen < 8 || uint64(len(data)) <= 13+saltLen {
+			return nil, errors.New("crypto: ASP.NET Identity v3 hash has invalid salt length")
+		}
+
+		input := &PBKDF2HashInput{
+			format:     "aspnet_v3",
+			alg:        alg,
+			iterations: iterations,
+			salt:       data[13 : 13+saltLen],
+			rawHash:    data[13+saltLen:],
+		}
+		if err := checkPBKDF2Cost(input); err != nil {
+			return nil, err
+		}
+
+		return input, nil
+	}
+
+	return nil, errors.New("crypto: ASP.NET Identity hash uses an unsupported format")
+}
+
+// isASPNETIdentityHash reports whether hash looks like an ASP.NET Identity
+// hash, which unlike the other formats has no prefix.
+func isASPNETIdentityHash(hash string) bool {
+	if strings.HasPrefix(hash, "$") {
+		return false
+	}
+
+	_, err := ParseASPNETIdentityHash(hash)
+	return err == nil
+}
+
+func ParseScryptHash(hash string) (*ScryptHashInput, error) {
+	submatch := scryptHashRegexp.FindStringSubmatchIndex(hash)
+	if submatch == nil {
+		return nil, errors.New("crypto: incorrect scrypt hash format")
+	}
+
+	ln := string(scryptHashRegexp.ExpandString(nil, "$ln", hash, submatch))
+	r := string(scryptHashRegexp.ExpandString(nil, "$r", hash, submatch))
+	p := string(scryptHashRegexp.ExpandString(nil, "$p", hash, submatch))
+	saltB64 := string(scryptHashRegexp.ExpandString(nil, "$salt", hash, submatch))
+	hashB64 := string(scryptHashRegexp.ExpandString(nil, "$hash", hash, submatch))
+
+	memoryPower, err := strconv.ParseUint(ln, 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("crypto: scrypt hash has invalid ln parameter %q %w", ln, err)
+	}
+	if memoryPower == 0 || memoryPower > 30 {
+		return nil, fmt.Errorf("crypto: scrypt hash has invalid ln=%d", memoryPower)
+	}
+
+	rounds, err := strconv.ParseUint(r, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("crypto: scrypt hash has invalid r parameter %q %w", r, err)
+	}
+	if rounds == 0 {
+		return nil, errors.New("crypto: scrypt hash has invalid r=0")
+	}
+
+	threads, err := strconv.ParseUint(p, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("crypto: scrypt hash has invalid p parameter %q %w", p, err)
+	}
+	if threads == 0 {
+		return nil, errors.New("crypto: scrypt hash has invalid p=0")
+	}
+
+	if memory := (uint64(1) << memoryPower) * rounds; memory > maxScryptMemory || memory*threads > maxScryptWork {
+		return nil, fmt.Errorf("crypto: scrypt hash parameters ln=%d,r=%d,p=%d are too expensive", memoryPower, rounds, threads)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(saltB64, "="))
+	if err != nil {
+		return nil, fmt.Errorf("crypto: scrypt hash has invalid base64 in the salt section %w", err)
+	}
+
+	rawHash, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(hashB64, "="))
+	if err != nil {
+		return nil, fmt.Errorf("crypto: scrypt hash has invalid base64 in the hash section %w", err)
+	}
+	if len(rawHash) == 0 {
+		return nil, errors.New("crypto: scrypt hash is empty")
+	}
+
+	return &ScryptHashInput{
+		memoryPower: memoryPower,
+		rounds:      rounds,
+		threads:     threads,
+		salt:        salt,
+		rawHash:     rawHash,
+	}, nil
+}
+
+func compareHashAndPasswordPBKDF2(ctx context.Context, input *PBKDF2HashInput, password string) error {
+	attributes := []attribute.KeyValue{
+		attribute.String("alg", "pbkdf2"),
+		attribute.String("format", input.format),
+		attribute.String("prf", input.alg),
+		attribute.Int64("i", int64(input.iterations)), // #nosec G115
+		attribute.Int("len", len(input.rawHash)),
+	}
+
+	var match bool
+	compareHashAndPasswordSubmittedCounter.Add(ctx, 1, metric.WithAttributes(attributes...))
+	defer func() {
+		attributes = append(attributes, attribute.Bool("match", match))
+		compareHashAndPasswordCompletedCounter.Add(ctx, 1, metric.WithAttributes(attributes...))
+	}()
+
+	derivedKey := pbkdf2.Key([]byte(password), input.salt, int(input.iterations), len(input.rawHash), pbkdf2HashFunc(input.alg)) // #nosec G115
+
+	match = subtle.ConstantTimeCompare(derivedKey, input.rawHash) == 1
+	if !match {
+		return ErrPBKDF2MismatchedHashAndPassword
+	}
+
+	return nil
+}
+
+func compareHashAndPasswordScrypt(ctx context.Context, hash, password string) error {
+	input, err := ParseScryptHash(hash)
+	if err != nil {
+		return err
+	}
+
+	attributes := []attribute.KeyValue{
+		attribute.String("alg", "scrypt"),
+		attribute.Int64("ln", int64(input.memoryPower)), // #nosec G115
+		attribute.Int64("r", int64(input.rounds)),       // #nosec G115
+		attribute.Int64("p", int64(input.threads)),      // #nosec G115
+		attribute.Int("len", len(input.rawHash)),
+	}
+
+	var match bool
+	compareHashAndPasswordSubmittedCounter.Add(ctx, 1, metric.WithAttributes(attributes...))
+	defer func() {
+		attributes = append(attributes, attribute.Bool("match", match))
+		compareHashAndPasswordCompletedCounter.Add(ctx, 1, metric.WithAttributes(attributes...))
+	}()
+
+	derivedKey, err := scrypt.Key([]byte(password), input.salt, 1<<input.memoryPower, int(input.rounds), int(input.threads), len(input.rawHash)) // #nosec G115
+	if err != nil {
+		return err
+	}
+
+	match = subtle.ConstantTimeCompare(derivedKey, input.rawHash) == 1
+	if !match {
+		return ErrPHCScryptMismatchedHashAndPassword
+	}
+
+	return nil
+}
+
+// compareHashAndPasswordLegacy verifies hashes in one of the formats that can
+// be imported from other systems. The first return value is false if hash is
+// not in one of those formats.
+func compareHashAndPasswordLegacy(ctx context.Context, hash, password string) (bool, error) {
+	switch {
+	case strings.HasPrefix(hash, PBKDF2Prefix), strings.HasPrefix(hash, DjangoPBKDF2Prefix):
+		input, err := ParsePBKDF2Hash(hash)
+		if err != nil {
+			return true, err
+		}
+
+		return true, compareHashAndPasswordPBKDF2(ctx, input, password)
+
+	case strings.HasPrefix(hash, ScryptPrefix):
+		return true, compareHashAndPasswordScrypt(ctx, hash, password)
+
+	case isASPNETIdentityHash(hash):
+		input, err := ParseASPNETIdentityHash(hash)
+		if err != nil {
+			return true, err
+		}
+
+		return true, compareHashAndPasswordPBKDF2(ctx, input, password)
+	}
+
+	return false, nil
+}
+
+// ValidatePasswordHash checks that hash is in one of the supported formats:
+// bcrypt, argon2, Firebase scrypt, PBKDF2 (passlib / PHC or Django), PHC
+// scrypt or ASP.NET Identity.
+func ValidatePasswordHash(hash string) error {
+	var err error
+
+	switch {
+	case strings.HasPrefix(hash, Argon2Prefix):
+		_, err = ParseArgon2Hash(hash)
+
+	case strings.HasPrefix(hash, FirebaseScryptPrefix):
+		_, err = ParseFirebaseScryptHash(hash)
+
+	case strings.HasPrefix(hash, PBKDF2Prefix), strings.HasPrefix(hash, DjangoPBKDF2Prefix):
+		_, err = ParsePBKDF2Hash(hash)
+
+	case strings.HasPrefix(hash, ScryptPrefix):
+		_, err = ParseScryptHash(hash)
+
+	case isASPNETIdentityHash(hash):
+		// already parsed
+
+	default:
+		_, err = bcrypt.Cost([]byte(hash))
+	}
+
+	return err
+}
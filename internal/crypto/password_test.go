@@ -176,3 +176,116 @@ func TestBcrypt(t *testing.T) {
 		assert.Error(t, CompareHashAndPassword(context.Background(), example, "test"))
 	}
 }
+
+func TestGenerateFromPasswordArgon2id(t *testing.T) {
+	defer func(hashing PasswordHashingParameters) {
+		PasswordHashing = hashing
+	}(PasswordHashing)
+
+	PasswordHashing = PasswordHashingParameters{
+		Algorithm:     Argon2idAlgorithm,
+		Argon2Memory:  64,
+		Argon2Time:    1,
+		Argon2Threads: 1,
+	}
+
+	hash, err := GenerateFromPassword(context.Background(), "test")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$"))
+	assert.Regexp(t, PasswordHashPattern(), hash)
+
+	assert.NoError(t, CompareHashAndPassword(context.Background(), hash, "test"))
+	assert.Error(t, CompareHashAndPassword(context.Background(), hash, "test1"))
+
+	assert.False(t, NeedsRehash(hash))
+}
+
+func TestNeedsRehash(t *testing.T) {
+	defer func(cost HashCost, hashing PasswordHashingParameters) {
+		PasswordHashCost = cost
+		PasswordHashing = hashing
+	}(PasswordHashCost, PasswordHashing)
+
+	PasswordHashCost = DefaultHashCost
+
+	bcrypt10 := "$2y$10$va66S4MxFrH6G6L7BzYl0.QgcYgvSr/F92gc.3botlz7bG4p/g/1i"
+	bcrypt11 := "$2y$11$4lH57PU7bGATpRcx93vIoObH3qDmft/pytbOzDG9/1WsyNmN5u4di"
+	argon2id := "$argon2id$v=19$m=32,t=3,p=2$SFVpOWJ0eXhjRzVkdGN1RQ$RXnb8rh7LaDcn07xsssqqulZYXOM/EUCEFMVcAcyYVk"
+	argon2i := "$argon2i$v=19$m=32,t=3,p=2$bGJRWThNOHJJTVBSdHl2dQ$NfEnUOuUpb7F2fQkgFUG4g"
+	fbscrypt := "$fbscrypt$v=1,n=14,r=8,p=1,ss=Bw==,sk=ou9tdYTGyYm8kuR6Dt0Bp0kDuAYoXrK16mbZO4yGwAn3oLspjnN0/c41v8xZnO1n14J3MjKj1b2g6AUCAlFwMw==$C0sHCg9ek77hsg==$ZGlmZmVyZW50aGFzaA=="
+
+	PasswordHashing = PasswordHashingParameters{Algorithm: BcryptAlgorithm, BcryptCost: 10}
+
+	assert.False(t, NeedsRehash(bcrypt10))
+	assert.True(t, NeedsRehash(bcrypt11))
+	assert.True(t, NeedsRehash(argon2id))
+	assert.True(t, NeedsRehash(fbscrypt))
+	assert.Regexp(t, PasswordHashPattern(), bcrypt10)
+	assert.NotRegexp(t, PasswordHashPattern(), bcrypt11)
+
+	PasswordHashing = PasswordHashingParameters{Algorithm: Argon2idAlgorithm, Argon2Memory: 32, Argon2Time: 3, Argon2Threads: 2}
+
+	assert.False(t, NeedsRehash(argon2id))
+	assert.True(t, NeedsRehash(argon2i))
+	assert.True(t, NeedsRehash(bcrypt10))
+	assert.Regexp(t, PasswordHashPattern(), argon2id)
+	assert.NotRegexp(t, PasswordHashPattern(), argon2i)
+
+	PasswordHashing.Argon2Memory = 64
+
+	assert.True(t, NeedsRehash(argon2id))
+}
+
+func TestLegacyHashes(t *testing.T) {
+	// all of these hash the `test` string with various parameters
+
+	examples := []string{
+		// Django
+		"pbkdf2_sha256$1000$seasalt123$ZO7bxTYpraRtIypOjUtO6D950mGJEU4QfwlZxcI4klo=",
+		// passlib / PHC PBKDF2
+		"$pbkdf2-sha512$1000$MDEyMzQ1Njc4OWFiY2RlZg$MftdIn9AsokTS9TDgFGFS1Bv8wXxMEkm13YRwjoqGVPG5mt/ESN6V.tryNJ536oFjFZcUVxPi175obtb4HuiTw",
+		"$pbkdf2$1000$MDEyMzQ1Njc4OWFiY2RlZg$QvEGxi97vkYNn6fjg3u.Y5XyQc4",
+		// PHC scrypt
+		"$scrypt$ln=4,r=8,p=1$MDEyMzQ1Njc4OWFiY2RlZg$UAzMStjVIRWO15kJMZknPqstv1zxFq97yqLiS6CLGbo",
+		// ASP.NET Identity v3 and v2
+		"AQAAAAEAAAPoAAAAEDAxMjM0NTY3ODlhYmNkZWbVUeK7/fUzgnZMzKomGZCxl/k7uVgVOfVw7xb1rjEqIg==",
+		"ADAxMjM0NTY3ODlhYmNkZWZC8QbGL3u+Rg2fp+ODe75jlfJBzoOuyDgtN2UtxMC9sA==",
+	}
+
+	for _, example := range examples {
+		assert.NoError(t, ValidatePasswordHash(example), example)
+		assert.NoError(t, CompareHashAndPassword(context.Background(), example, "test"), example)
+		assert.Error(t, CompareHashAndPassword(context.Background(), example, "test1"), example)
+		assert.True(t, NeedsRehash(example), example)
+	}
+
+	negativeExamples := []string{
+		// unsupported django algorithm
+		"pbkdf2_md5$1000$seasalt123$ZO7bxTYpraRtIypOjUtO6D950mGJEU4QfwlZxcI4klo=",
+		// zero iterations
+		"pbkdf2_sha256$0$seasalt123$ZO7bxTYpraRtIypOjUtO6D950mGJEU4QfwlZxcI4klo=",
+		// invalid base64
+		"$pbkdf2-sha256$1000$MDEyMzQ1Njc4OWFiY2RlZg$!!!",
+		// too many iterations
+		"pbkdf2_sha256$100000000$seasalt123$ZO7bxTYpraRtIypOjUtO6D950mGJEU4QfwlZxcI4klo=",
+		// iterations within the bound, but of a derived key of 3 blocks
+		"$pbkdf2$1000000$MDEyMzQ1Njc4OWFiY2RlZg$AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8gISIjJCUmJygpKissLS4vMDEyMzQ1Njc4OTo7",
+		// ln too large
+		"$scrypt$ln=64,r=8,p=1$MDEyMzQ1Njc4OWFiY2RlZg$UAzMStjVIRWO15kJMZknPqstv1zxFq97yqLiS6CLGbo",
+		// too much memory
+		"$scrypt$ln=20,r=8,p=1$MDEyMzQ1Njc4OWFiY2RlZg$UAzMStjVIRWO15kJMZknPqstv1zxFq97yqLiS6CLGbo",
+		// too much work
+		"$scrypt$ln=16,r=8,p=64$MDEyMzQ1Njc4OWFiY2RlZg$UAzMStjVIRWO15kJMZknPqstv1zxFq97yqLiS6CLGbo",
+		// zero r
+		"$scrypt$ln=4,r=0,p=1$MDEyMzQ1Njc4OWFiY2RlZg$UAzMStjVIRWO15kJMZknPqstv1zxFq97yqLiS6CLGbo",
+		// ASP.NET Identity v3 with unsupported PRF
+		"AQAAAAMAAAPoAAAAEDAxMjM0NTY3ODlhYmNkZWbVUeK7/fUzgnZMzKomGZCxl/k7uVgVOfVw7xb1rjEqIg==",
+		// ASP.NET Identity v2 with invalid length
+		"ADAxMjM0NTY3ODlhYmNkZWZC8QbGL3u+Rg2fp+ODe75jlfJBzoOuyDgtN2Ut",
+	}
+
+	for _, example := range negativeExamples {
+		assert.Error(t, ValidatePasswordHash(example), example)
+		assert.Error(t, CompareHashAndPassword(context.Background(), example, "test"), example)
+	}
+}
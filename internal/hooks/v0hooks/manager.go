@@ -62,6 +62,8 @@ func configByName(
 		return &cfg.BeforeUserCreated, true
 	case AfterUserCreated:
 		return &cfg.AfterUserCreated, true
+	case UserMerged:
+		return &cfg.UserMerged, true
 	default:
 		return nil, false
 	}
@@ -146,6 +148,14 @@ func (o *Manager) invokeHook(
 		}
 		return o.dispatch(
 			r.Context(), &o.config.Hook.AfterUserCreated, conn, input, output)
+
+	case *UserMergedInput:
+		if _, ok := output.(*UserMergedOutput); !ok {
+			return apierrors.NewInternalServerError(
+				"output should be *hooks.UserMergedOutput")
+		}
+		return o.dispatch(
+			r.Context(), &o.config.Hook.UserMerged, conn, input, output)
 	}
 }
 
@@ -420,6 +420,12 @@ func TestHooks(t *testing.T) {
 			res:    M{},
 			errStr: "500: output should be *hooks.AfterUserCreatedOutput",
 		},
+		{
+			desc:   "fail - user_merged - invalid output type",
+			req:    &UserMergedInput{},
+			res:    M{},
+			errStr: "500: output should be *hooks.UserMergedOutput",
+		},
 
 		// fail - invalid query
 		{
@@ -543,6 +549,9 @@ func TestConfig(t *testing.T) {
 			AfterUserCreated: conf.ExtensibilityPointConfiguration{
 				URI: "http:localhost/" + string(AfterUserCreated),
 			},
+			UserMerged: conf.ExtensibilityPointConfiguration{
+				URI: "http:localhost/" + string(UserMerged),
+			},
 		},
 	}
 	cfg := &globalCfg.Hook
@@ -571,6 +580,8 @@ func TestConfig(t *testing.T) {
 			name: BeforeUserCreated, exp: &cfg.BeforeUserCreated},
 		{cfg: cfg, ok: true,
 			name: AfterUserCreated, exp: &cfg.AfterUserCreated},
+		{cfg: cfg, ok: true,
+			name: UserMerged, exp: &cfg.UserMerged},
 	}
 	for _, test := range tests {
 		t.Run(string(test.name), func(t *testing.T) {
@@ -23,6 +23,7 @@ const (
 	PasswordVerification Name = "password-verification"
 	BeforeUserCreated    Name = "before-user-created"
 	AfterUserCreated     Name = "after-user-created"
+	UserMerged           Name = "user-merged"
 )
 
 const (
@@ -91,6 +92,30 @@ func NewAfterUserCreatedInput(
 
 type AfterUserCreatedOutput struct{}
 
+// UserMergedInput is sent when an anonymous user is merged into an existing
+// user, before the anonymous user is deleted. Postgres hooks run in the same
+// transaction, so rows moved from the anonymous user are moved atomically. A
+// hook returning an error aborts the merge.
+type UserMergedInput struct {
+	Metadata        *Metadata `json:"metadata"`
+	AnonymousUserID uuid.UUID `json:"anonymous_user_id"`
+	TargetUserID    uuid.UUID `json:"target_user_id"`
+}
+
+func NewUserMergedInput(
+	r *http.Request,
+	anonymousUser *models.User,
+	targetUser *models.User,
+) *UserMergedInput {
+	return &UserMergedInput{
+		Metadata:        NewMetadata(r, UserMerged),
+		AnonymousUserID: anonymousUser.ID,
+		TargetUserID:    targetUser.ID,
+	}
+}
+
+type UserMergedOutput struct{}
+
 // TODO(joel): Move this to phone package
 type SMS struct {
 	OTP     string `json:"otp,omitempty"`
@@ -109,6 +134,8 @@ type AccessTokenClaims struct {
 	AuthenticationMethodReference []models.AMREntry      `json:"amr,omitempty"`
 	SessionId                     string                 `json:"session_id,omitempty"`
 	IsAnonymous                   bool                   `json:"is_anonymous"`
+	OrganizationID                string                 `json:"organization_id,omitempty"`
+	OrganizationRole              string                 `json:"organization_role,omitempty"`
 }
 
 type MFAVerificationAttemptInput struct {
@@ -126,6 +153,11 @@ type MFAVerificationAttemptOutput struct {
 type PasswordVerificationAttemptInput struct {
 	UserID uuid.UUID `json:"user_id"`
 	Valid  bool      `json:"valid"`
+
+	// RiskScore and RiskReasons are the risk engine's assessment of the
+	// attempt, if the risk engine is enabled.
+	RiskScore   int      `json:"risk_score"`
+	RiskReasons []string `json:"risk_reasons,omitempty"`
 }
 
 type PasswordVerificationAttemptOutput struct {
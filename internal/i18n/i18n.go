@@ -1,6 +1,7 @@
 package i18n
 
 import (
+	"fmt"
 	"net/http"
 	"sort"
 	"strconv"
@@ -46,6 +47,16 @@ var Messages = map[Language]map[string]string{
 		"weak_password":                "Password does not meet security requirements",
 		"same_password":                "New password must be different from the current password",
 
+		// Weak password reasons, see WeakPasswordError in the api package
+		"weak_password_length":     "Password should be at least %d characters.",
+		"weak_password_max_length": "Password should be at most %d characters.",
+		"weak_password_characters": "Password should contain at least one character of each: %s.",
+		"weak_password_pwned":      "Password is known to be weak and easy to guess, please choose a different one.",
+		"weak_password_context":    "Password should not contain your email address or phone number.",
+		"weak_password_dictionary": "Password contains a word that is not allowed, please choose a different one.",
+		"weak_password_reused":     "Password should be different from your last %d passwords.",
+		"weak_password_expired":    "Password has expired and needs to be changed.",
+
 		// Session related errors
 		"session_not_found":          "Session not found",
 		"session_expired":            "Session has expired",
@@ -57,6 +68,18 @@ var Messages = map[Language]map[string]string{
 		// Authentication related errors
 		"bad_jwt":                    "Invalid JWT token",
 		"not_admin":                  "Not authorized as admin",
+		"admin_permission_denied":    "Admin permission denied",
+		"invalid_admin_api_key":      "Invalid admin API key",
+		"admin_api_key_not_found":    "Admin API key not found",
+		"user_locked":                "Too many failed sign in attempts, please try again later or reset your password",
+		"mfa_verification_locked":    "Too many failed MFA verification attempts, please try again later",
+		"sign_in_blocked":            "This sign in attempt was blocked for security reasons",
+		"revoke_link_invalid":        "The link is invalid or has expired",
+		"user_not_anonymous":         "Only anonymous users can be merged into another account",
+		"merge_target_invalid":       "The account to merge into is invalid",
+		"user_import_not_found":      "User import not found",
+		"audience_config_not_found":  "Audience config not found",
+		"user_deletion_disabled":     "Account deletion is disabled",
 		"no_authorization":           "No authorization provided",
 		"invalid_credentials":        "Invalid login credentials",
 		"reauthentication_needed":    "Reauthentication required",
@@ -116,6 +139,12 @@ var Messages = map[Language]map[string]string{
 		"sso_domain_already_exists":  "SSO domain already exists",
 		"saml_entity_id_mismatch":    "SAML entity ID mismatch",
 
+		// Organization related errors
+		"organization_not_found":             "Organization not found",
+		"organization_already_exists":        "Organization already exists",
+		"organization_member_not_found":      "Organization member not found",
+		"organization_member_already_exists": "User is already a member of the organization",
+
 		// Rate limit related errors
 		"over_request_rate_limit":    "Too many requests, please try again later",
 		"over_email_send_rate_limit": "Too many email sending attempts, please try again later",
@@ -163,6 +192,16 @@ var Messages = map[Language]map[string]string{
 		"weak_password":                "密码不符合安全要求",
 		"same_password":                "新密码必须与当前密码不同",
 
+		// Weak password reasons, see WeakPasswordError in the api package
+		"weak_password_length":     "密码长度至少为%d个字符。",
+		"weak_password_max_length": "密码长度最多为%d个字符。",
+		"weak_password_characters": "密码应至少包含以下每组中的一个字符：%s。",
+		"weak_password_pwned":      "该密码已知容易被猜到，请选择其他密码。",
+		"weak_password_context":    "密码不应包含您的邮箱地址或手机号码。",
+		"weak_password_dictionary": "密码包含不允许使用的词语，请选择其他密码。",
+		"weak_password_reused":     "密码不能与最近%d次使用的密码相同。",
+		"weak_password_expired":    "密码已过期，需要修改。",
+
 		// Session related errors
 		"session_not_found":          "会话不存在",
 		"session_expired":            "会话已过期",
@@ -174,6 +213,18 @@ var Messages = map[Language]map[string]string{
 		// Authentication related errors
 		"bad_jwt":                    "无效的JWT令牌",
 		"not_admin":                  "未授权为管理员",
+		"admin_permission_denied":    "缺少管理员权限",
+		"invalid_admin_api_key":      "无效的管理员API密钥",
+		"admin_api_key_not_found":    "管理员API密钥不存在",
+		"user_locked":                "登录失败次数过多，请稍后再试或重置密码",
+		"mfa_verification_locked":    "MFA验证失败次数过多，请稍后再试",
+		"sign_in_blocked":            "出于安全原因，此次登录已被阻止",
+		"revoke_link_invalid":        "链接无效或已过期",
+		"user_not_anonymous":         "只有匿名用户可以合并到其他账户",
+		"merge_target_invalid":       "要合并到的账户无效",
+		"user_import_not_found":      "未找到用户导入任务",
+		"audience_config_not_found":  "未找到受众配置",
+		"user_deletion_disabled":     "账户删除已禁用",
 		"no_authorization":           "未提供授权",
 		"invalid_credentials":        "无效的登录凭据",
 		"reauthentication_needed":    "需要重新认证",
@@ -233,6 +284,12 @@ var Messages = map[Language]map[string]string{
 		"sso_domain_already_exists":  "SSO域名已存在",
 		"saml_entity_id_mismatch":    "SAML实体ID不匹配",
 
+		// Organization related errors
+		"organization_not_found":             "组织不存在",
+		"organization_already_exists":        "组织已存在",
+		"organization_member_not_found":      "组织成员不存在",
+		"organization_member_already_exists": "用户已是该组织的成员",
+
 		// Rate limit related errors
 		"over_request_rate_limit":    "请求过于频繁，请稍后再试",
 		"over_email_send_rate_limit": "邮件发送过于频繁，请稍后再试",
@@ -322,6 +379,12 @@ func GetMessage(lang Language, key string) string {
 	return key
 }
 
+// GetMessagef returns the localized message for given key and language,
+// formatted with args
+func GetMessagef(lang Language, key string, args ...interface{}) string {
+	return fmt.Sprintf(GetMessage(lang, key), args...)
+}
+
 // GetUserFriendlyMessage returns a user-friendly error message, hiding internal details
 func GetUserFriendlyMessage(lang Language, errorCode string, originalMessage string) string {
 	// Try to get message by error code first
@@ -3,6 +3,7 @@ package i18n
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -205,6 +206,26 @@ func TestGetMessage(t *testing.T) {
 	}
 }
 
+func TestGetMessagef(t *testing.T) {
+	if result := GetMessagef(LanguageEnglish, "weak_password_length", 8); result != "Password should be at least 8 characters." {
+		t.Errorf("Expected English message, got '%s'", result)
+	}
+
+	if result := GetMessagef(LanguageChinese, "weak_password_reused", 5); result != "密码不能与最近5次使用的密码相同。" {
+		t.Errorf("Expected Chinese message, got '%s'", result)
+	}
+
+	// every weak password reason should be translated
+	for key := range Messages[LanguageEnglish] {
+		if !strings.HasPrefix(key, "weak_password_") {
+			continue
+		}
+		if _, ok := Messages[LanguageChinese][key]; !ok {
+			t.Errorf("Missing Chinese translation for '%s'", key)
+		}
+	}
+}
+
 func TestGetUserFriendlyMessage(t *testing.T) {
 	tests := []struct {
 		name            string
@@ -0,0 +1,358 @@
+package mailer
+
+import (
+	"bytes"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var (
+	cssCommentRegexp    = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	cssSimpleSelector   = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9-]*)?((?:[.#][a-zA-Z_][a-zA-Z0-9_-]*)*)$`)
+	cssSelectorPart     = regexp.MustCompile(`[.#][a-zA-Z_][a-zA-Z0-9_-]*`)
+	textBlankLinesRegex = regexp.MustCompile(`\n{3,}`)
+)
+
+type cssSelector struct {
+	tag     string
+	id      string
+	classes []string
+}
+
+func (s *cssSelector) specificity() int {
+	score := len(s.classes) * 10
+	if s.id != "" {
+		score += 100
+	}
+	if s.tag != "" {
+		score += 1
+	}
+	return score
+}
+
+func (s *cssSelector) matches(n *html.Node) bool {
+	if s.tag != "" && !strings.EqualFold(s.tag, n.Data) {
+		return false
+	}
+
+	if s.id != "" && getAttr(n, "id") != s.id {
+		return false
+	}
+
+	if len(s.classes) > 0 {
+		classes := strings.Fields(getAttr(n, "class"))
+		for _, want := range s.classes {
+			found := false
+			for _, class := range classes {
+				if class == want {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func parseCSSSelector(s string) *cssSelector {
+	m := cssSimpleSelector.FindStringSubmatch(s)
+	if m == nil || (m[1] == "" && m[2] == "") {
+		return nil
+	}
+
+	sel := &cssSelector{tag: m[1]}
+	for _, part := range cssSelectorPart.FindAllString(m[2], -1) {
+		if part[0] == '#' {
+			if sel.id != "" {
+				return nil
+			}
+			sel.id = part[1:]
+		} else {
+			sel.classes = append(sel.classes, part[1:])
+		}
+	}
+
+	return sel
+}
+
+type cssRule struct {
+	selector     *cssSelector
+	declarations string
+	order        int
+}
+
+// parseCSS splits a stylesheet into rules that can be inlined and the
+// remainder that can't, such as at-rules or selectors with combinators and
+// pseudo-classes.
+func parseCSS(css string) ([]cssRule, string) {
+	css = cssCommentRegexp.ReplaceAllString(css, "")
+
+	var rules []cssRule
+	var rest strings.Builder
+
+	for len(strings.TrimSpace(css)) > 0 {
+		open := strings.Index(css, "{")
+		if open < 0 {
+			break
+		}
+
+		prelude := strings.TrimSpace(css[:open])
+
+		// find the matching closing brace, accounting for nested blocks in
+		// at-rules such as @media
+		depth, end := 0, -1
+		for i := open; i < len(css); i++ {
+			if css[i] == '{' {
+				depth++
+			} else if css[i] == '}' {
+				depth--
+				if depth == 0 {
+					end = i
+					break
+				}
+			}
+		}
+		if end < 0 {
+			break
+		}
+
+		block := css[open+1 : end]
+		whole := css[:end+1]
+		css = css[end+1:]
+
+		if strings.HasPrefix(prelude, "@") {
+			rest.WriteString(strings.TrimSpace(whole))
+			rest.WriteString("\n")
+			continue
+		}
+
+		var unsupported []string
+		for _, s := range strings.Split(prelude, ",") {
+			s = strings.TrimSpace(s)
+			if sel := parseCSSSelector(s); sel != nil {
+				rules = append(rules, cssRule{
+					selector:     sel,
+					declarations: strings.TrimSpace(block),
+					order:        len(rules),
+				})
+			} else {
+				unsupported = append(unsupported, s)
+			}
+		}
+
+		if len(unsupported) > 0 {
+			rest.WriteString(strings.Join(unsupported, ", "))
+			rest.WriteString(" {")
+			rest.WriteString(block)
+			rest.WriteString("}\n")
+		}
+	}
+
+	return rules, strings.TrimSpace(rest.String())
+}
+
+func getAttr(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+func setAttr(n *html.Node, key, val string) {
+	for i := range n.Attr {
+		if n.Attr[i].Key == key {
+			n.Attr[i].Val = val
+			return
+		}
+	}
+	n.Attr = append(n.Attr, html.Attribute{Key: key, Val: val})
+}
+
+func walkHTML(n *html.Node, fn func(*html.Node)) {
+	fn(n)
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walkHTML(c, fn)
+	}
+}
+
+// inlineCSS moves the rules of <style> elements into the style attribute of
+// the elements they apply to. Only simple selectors (tag, class and ID) are
+// inlined, everything else stays in the <style> element. Existing style
+// attributes take precedence over inlined rules.
+func inlineCSS(body string) (string, error) {
+	if !strings.Contains(body, "<style") {
+		return body, nil
+	}
+
+	doc, err := html.Parse(strings.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+
+	var rules []cssRule
+	var styles []*html.Node
+
+	walkHTML(doc, func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Style {
+			styles = append(styles, n)
+		}
+	})
+
+	for _, style := range styles {
+		var css strings.Builder
+		for c := style.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.TextNode {
+				css.WriteString(c.Data)
+			}
+		}
+
+		parsed, rest := parseCSS(css.String())
+		for _, rule := range parsed {
+			rule.order += len(rules)
+			rules = append(rules, rule)
+		}
+
+		if rest == "" {
+			style.Parent.RemoveChild(style)
+		} else {
+			for c := style.FirstChild; c != nil; c = style.FirstChild {
+				style.RemoveChild(c)
+			}
+			style.AppendChild(&html.Node{Type: html.TextNode, Data: rest})
+		}
+	}
+
+	sort.SliceStable(rules, func(i, j int) bool {
+		si, sj := rules[i].selector.specificity(), rules[j].selector.specificity()
+		if si != sj {
+			return si < sj
+		}
+		return rules[i].order < rules[j].order
+	})
+
+	walkHTML(doc, func(n *html.Node) {
+		if n.Type != html.ElementNode {
+			return
+		}
+
+		var declarations []string
+		for _, rule := range rules {
+			if rule.declarations != "" && rule.selector.matches(n) {
+				declarations = append(declarations, strings.TrimSuffix(rule.declarations, ";"))
+			}
+		}
+		if len(declarations) == 0 {
+			return
+		}
+
+		if existing := strings.TrimSpace(getAttr(n, "style")); existing != "" {
+			declarations = append(declarations, strings.TrimSuffix(existing, ";"))
+		}
+		setAttr(n, "style", strings.Join(declarations, "; "))
+	})
+
+	buf := &bytes.Buffer{}
+	if err := html.Render(buf, doc); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+var textBlockElements = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Table: true, atom.Tr: true,
+	atom.Ul: true, atom.Ol: true, atom.Li: true, atom.Blockquote: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Hr: true, atom.Section: true, atom.Header: true, atom.Footer: true,
+}
+
+// htmlToText derives a plain-text alternative from an HTML mail body. Links
+// are kept by appending their target to the link text.
+func htmlToText(body string) (string, error) {
+	doc, err := html.Parse(strings.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			text := strings.Join(strings.Fields(n.Data), " ")
+			if text == "" {
+				return
+			}
+			if strings.HasPrefix(n.Data, " ") || strings.HasPrefix(n.Data, "\n") {
+				if s := b.String(); len(s) > 0 && !strings.HasSuffix(s, " ") && !strings.HasSuffix(s, "\n") {
+					b.WriteString(" ")
+				}
+			}
+			b.WriteString(text)
+			if strings.HasSuffix(n.Data, " ") || strings.HasSuffix(n.Data, "\n") {
+				b.WriteString(" ")
+			}
+			return
+
+		case html.ElementNode:
+			switch n.DataAtom {
+			case atom.Head, atom.Style, atom.Script, atom.Title:
+				return
+			case atom.Br:
+				b.WriteString("\n")
+				return
+			}
+
+			block := textBlockElements[n.DataAtom]
+			if block {
+				b.WriteString("\n")
+				if n.DataAtom == atom.Li {
+					b.WriteString("- ")
+				}
+			}
+
+			start := b.Len()
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				walk(c)
+			}
+
+			if n.DataAtom == atom.A {
+				href := getAttr(n, "href")
+				label := strings.TrimSpace(b.String()[start:])
+				if href != "" && href != label && !strings.HasPrefix(href, "mailto:") {
+					b.WriteString(" (" + href + ")")
+				}
+			}
+
+			if block && n.DataAtom != atom.Li {
+				b.WriteString("\n")
+			}
+			return
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	lines := strings.Split(b.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+
+	text := strings.Join(lines, "\n")
+	text = textBlankLinesRegex.ReplaceAllString(text, "\n\n")
+
+	return strings.TrimSpace(text), nil
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/supabase/auth/internal/conf"
 	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/storage"
 )
 
 // Mailer defines the interface a mailer must implement.
@@ -18,13 +19,20 @@ type Mailer interface {
 	MagicLinkMail(r *http.Request, user *models.User, otp, referrerURL string, externalURL *url.URL) error
 	EmailChangeMail(r *http.Request, user *models.User, otpNew, otpCurrent, referrerURL string, externalURL *url.URL) error
 	ReauthenticateMail(r *http.Request, user *models.User, otp string) error
+	NotificationMail(r *http.Request, user *models.User, notificationType string, data map[string]interface{}) error
 	GetEmailActionLink(user *models.User, actionType, referrerURL string, externalURL *url.URL) (string, error)
+	Preview(r *http.Request, user *models.User, data EmailData, externalURL *url.URL) ([]*Message, error)
 }
 
 type EmailParams struct {
 	Token      string
 	Type       string
 	RedirectTo string
+
+	// Aud is the audience of the user, signed with AudSignature, which
+	// selects the configuration of the audience when the link is opened.
+	Aud          string
+	AudSignature string
 }
 
 type EmailData struct {
@@ -35,13 +43,35 @@ type EmailData struct {
 	SiteURL         string `json:"site_url"`
 	TokenNew        string `json:"token_new"`
 	TokenHashNew    string `json:"token_hash_new"`
+
+	// NotificationData is the data of notifications, which have no token.
+	NotificationData map[string]interface{} `json:"notification_data,omitempty"`
 }
 
-// NewMailer returns a new gotrue mailer
-func NewMailer(globalConfig *conf.GlobalConfiguration) Mailer {
+// NewMailer returns a new gotrue mailer. The database connection is only used
+// for templates stored in the database and may be nil. The layouts of the
+// local templates path are parsed for every mail unless given.
+func NewMailer(globalConfig *conf.GlobalConfiguration, db *storage.Connection, layouts *LocalLayouts) Mailer {
 	from := globalConfig.SMTP.FromAddress()
 	u, _ := url.ParseRequestURI(globalConfig.API.ExternalURL)
 
+	renderer := &TemplateRenderer{
+		BaseURL:   globalConfig.SiteURL,
+		InlineCSS: globalConfig.Mailer.TemplateStore.InlineCSS,
+		Logger:    logrus.StandardLogger(),
+	}
+
+	store := globalConfig.Mailer.TemplateStore
+	if store.Path != "" || store.Database {
+		renderer.Local = &LocalTemplates{
+			Path:    store.Path,
+			Layouts: layouts,
+		}
+		if store.Database {
+			renderer.Local.DB = db
+		}
+	}
+
 	var mailClient MailClient
 	if globalConfig.SMTP.Host == "" {
 		logrus.Infof("Noop mail client being used for %v", globalConfig.SiteURL)
@@ -56,7 +86,7 @@ func NewMailer(globalConfig *conf.GlobalConfiguration) Mailer {
 			Pass:           globalConfig.SMTP.Pass,
 			LocalName:      u.Hostname(),
 			From:           from,
-			BaseURL:        globalConfig.SiteURL,
+			Renderer:       renderer,
 			Logger:         logrus.StandardLogger(),
 			MailLogging:    globalConfig.SMTP.LoggingEnabled,
 			EmailValidator: newEmailValidator(globalConfig.Mailer),
@@ -64,9 +94,10 @@ func NewMailer(globalConfig *conf.GlobalConfiguration) Mailer {
 	}
 
 	return &TemplateMailer{
-		SiteURL: globalConfig.SiteURL,
-		Config:  globalConfig,
-		Mailer:  mailClient,
+		SiteURL:  globalConfig.SiteURL,
+		Config:   globalConfig,
+		Mailer:   mailClient,
+		Renderer: renderer,
 	}
 }
 
@@ -88,6 +119,9 @@ func getPath(filepath string, params *EmailParams) (*url.URL, error) {
 	}
 	if params != nil {
 		path.RawQuery = fmt.Sprintf("token=%s&type=%s&redirect_to=%s", url.QueryEscape(params.Token), url.QueryEscape(params.Type), encodeRedirectURL(params.RedirectTo))
+		if params.Aud != "" {
+			path.RawQuery += fmt.Sprintf("&aud=%s&aud_signature=%s", url.QueryEscape(params.Aud), url.QueryEscape(params.AudSignature))
+		}
 	}
 	return path, nil
 }
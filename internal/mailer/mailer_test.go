@@ -56,6 +56,18 @@ func TestGetPath(t *testing.T) {
 			Params:   &params,
 			Expected: "https://test.example.com?token=token&type=signup&redirect_to=https://example.com",
 		},
+		{
+			SiteURL: "https://test.example.com",
+			Path:    "f",
+			Params: &EmailParams{
+				Token:        "token",
+				Type:         "signup",
+				RedirectTo:   "https://example.com",
+				Aud:          "brand",
+				AudSignature: "signature",
+			},
+			Expected: "https://test.example.com/f?token=token&type=signup&redirect_to=https://example.com&aud=brand&aud_signature=signature",
+		},
 	}
 
 	for _, c := range cases {
@@ -1,14 +1,11 @@
 package mailer
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"html/template"
 	"io"
-	"log"
 	"net/http"
-	"strings"
 	"sync"
 	"time"
 
@@ -30,10 +27,8 @@ type MailmeMailer struct {
 	Port           int
 	User           string
 	Pass           string
-	BaseURL        string
 	LocalName      string
-	FuncMap        template.FuncMap
-	cache          *TemplateCache
+	Renderer       *TemplateRenderer
 	Logger         logrus.FieldLogger
 	MailLogging    bool
 	EmailValidator *EmailValidator
@@ -48,15 +43,8 @@ func (m *MailmeMailer) Mail(
 	headers map[string][]string,
 	typ string,
 ) error {
-	if m.FuncMap == nil {
-		m.FuncMap = map[string]interface{}{}
-	}
-	if m.cache == nil {
-		m.cache = &TemplateCache{
-			templates: map[string]*MailTemplate{},
-			funcMap:   m.FuncMap,
-			logger:    m.Logger,
-		}
+	if m.Renderer == nil {
+		m.Renderer = &TemplateRenderer{Logger: m.Logger}
 	}
 
 	if m.EmailValidator != nil {
@@ -65,18 +53,7 @@ func (m *MailmeMailer) Mail(
 		}
 	}
 
-	tmp, err := template.New("Subject").Funcs(template.FuncMap(m.FuncMap)).Parse(subjectTemplate)
-	if err != nil {
-		return err
-	}
-
-	subject := &bytes.Buffer{}
-	err = tmp.Execute(subject, templateData)
-	if err != nil {
-		return err
-	}
-
-	body, err := m.MailBody(templateURL, defaultTemplate, templateData)
+	msg, err := m.Renderer.Render(ctx, typ, subjectTemplate, templateURL, defaultTemplate, templateData)
 	if err != nil {
 		return err
 	}
@@ -84,7 +61,7 @@ func (m *MailmeMailer) Mail(
 	mail := gomail.NewMessage()
 	mail.SetHeader("From", m.From)
 	mail.SetHeader("To", to)
-	mail.SetHeader("Subject", subject.String())
+	mail.SetHeader("Subject", msg.Subject)
 
 	for k, v := range headers {
 		if v != nil {
@@ -92,7 +69,8 @@ func (m *MailmeMailer) Mail(
 		}
 	}
 
-	mail.SetBody("text/html", body)
+	mail.SetBody("text/plain", msg.Text)
+	mail.AddAlternative("text/html", msg.HTML)
 
 	dial := gomail.NewDialer(m.Host, m.Port, m.User, m.Pass)
 	if m.LocalName != "" {
@@ -184,47 +162,3 @@ func (t *TemplateCache) fetchTemplate(url string, triesLeft int) (string, error)
 	}
 	return "", errors.New("mailer: unable to fetch mail template")
 }
-
-func (m *MailmeMailer) MailBody(url string, defaultTemplate string, data map[string]interface{}) (string, error) {
-	if m.FuncMap == nil {
-		m.FuncMap = map[string]interface{}{}
-	}
-	if m.cache == nil {
-		m.cache = &TemplateCache{templates: map[string]*MailTemplate{}, funcMap: m.FuncMap}
-	}
-
-	var temp *template.Template
-	var err error
-
-	if url != "" {
-		var absoluteURL string
-		if strings.HasPrefix(url, "http") {
-			absoluteURL = url
-		} else {
-			absoluteURL = m.BaseURL + url
-		}
-		temp, err = m.cache.Get(absoluteURL)
-		if err != nil {
-			log.Printf("Error loading template from %v: %v\n", url, err)
-		}
-	}
-
-	if temp == nil {
-		cached, ok := m.cache.templates[url]
-		if ok {
-			temp = cached.tmp
-		} else {
-			temp, err = m.cache.Set(url, defaultTemplate, 0)
-			if err != nil {
-				return "", err
-			}
-		}
-	}
-
-	buf := &bytes.Buffer{}
-	err = temp.Execute(buf, data)
-	if err != nil {
-		return "", err
-	}
-	return buf.String(), nil
-}
@@ -0,0 +1,227 @@
+package mailer
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/i18n"
+	"github.com/supabase/auth/internal/models"
+)
+
+// Notification types, which are informational mails about security relevant
+// events on the user's account.
+const (
+	NewDeviceNotification         = "new_device"
+	PasswordChangedNotification   = "password_changed"
+	EmailChangedNotification      = "email_changed"
+	PhoneChangedNotification      = "phone_changed"
+	FactorEnrolledNotification    = "factor_enrolled"
+	FactorRemovedNotification     = "factor_removed"
+	IdentityLinkedNotification    = "identity_linked"
+	IdentityUnlinkedNotification  = "identity_unlinked"
+	DeletionScheduledNotification = "deletion_scheduled"
+)
+
+var defaultNotificationSubjects = map[string]map[i18n.Language]string{
+	NewDeviceNotification: {
+		i18n.LanguageEnglish: "New sign in to your account",
+		i18n.LanguageChinese: "您的账户有新的登录",
+	},
+	PasswordChangedNotification: {
+		i18n.LanguageEnglish: "Your password was changed",
+		i18n.LanguageChinese: "您的密码已更改",
+	},
+	EmailChangedNotification: {
+		i18n.LanguageEnglish: "Your email address was changed",
+		i18n.LanguageChinese: "您的邮箱地址已更改",
+	},
+	PhoneChangedNotification: {
+		i18n.LanguageEnglish: "Your phone number was changed",
+		i18n.LanguageChinese: "您的手机号码已更改",
+	},
+	FactorEnrolledNotification: {
+		i18n.LanguageEnglish: "A multi-factor authentication method was added",
+		i18n.LanguageChinese: "已添加多因素认证方式",
+	},
+	FactorRemovedNotification: {
+		i18n.LanguageEnglish: "A multi-factor authentication method was removed",
+		i18n.LanguageChinese: "已移除多因素认证方式",
+	},
+	IdentityLinkedNotification: {
+		i18n.LanguageEnglish: "A sign in method was linked to your account",
+		i18n.LanguageChinese: "已有登录方式关联到您的账户",
+	},
+	IdentityUnlinkedNotification: {
+		i18n.LanguageEnglish: "A sign in method was unlinked from your account",
+		i18n.LanguageChinese: "已有登录方式与您的账户解除关联",
+	},
+	DeletionScheduledNotification: {
+		i18n.LanguageEnglish: "Your account is scheduled for deletion",
+		i18n.LanguageChinese: "您的账户已计划删除",
+	},
+}
+
+var defaultNotificationTemplates = map[string]map[i18n.Language]string{
+	NewDeviceNotification: {
+		i18n.LanguageEnglish: `<h2>New sign in to your account</h2>
+
+<p>Your account {{ .Email }} was signed in to from a new device.</p>
+<p>IP address: {{ .IPAddress }}<br>Device: {{ .UserAgent }}<br>Time: {{ .SignedInAt }}</p>
+<p>If this was you, you can ignore this email. If it wasn't, follow this link to sign out the device and reset your password:</p>
+<p><a href="{{ .RevokeURL }}">This wasn't me</a></p>`,
+		i18n.LanguageChinese: `<h2>您的账户有新的登录</h2>
+
+<p>您的账户 {{ .Email }} 在新设备上登录。</p>
+<p>IP 地址：{{ .IPAddress }}<br>设备：{{ .UserAgent }}<br>时间：{{ .SignedInAt }}</p>
+<p>如果是您本人操作，请忽略此邮件。如果不是，请点击以下链接退出该设备并重置密码：</p>
+<p><a href="{{ .RevokeURL }}">这不是我</a></p>`,
+	},
+	PasswordChangedNotification: {
+		i18n.LanguageEnglish: `<h2>Your password was changed</h2>
+
+<p>The password of your account {{ .Email }} was changed.</p>
+<p>If you didn't change it, reset your password and contact support immediately.</p>`,
+		i18n.LanguageChinese: `<h2>您的密码已更改</h2>
+
+<p>您的账户 {{ .Email }} 的密码已更改。</p>
+<p>如果不是您本人操作，请立即重置密码并联系客服。</p>`,
+	},
+	EmailChangedNotification: {
+		i18n.LanguageEnglish: `<h2>Your email address was changed</h2>
+
+<p>The email address of your account was changed from {{ .Email }} to {{ .NewEmail }}.</p>
+<p>If you didn't change it, contact support immediately.</p>`,
+		i18n.LanguageChinese: `<h2>您的邮箱地址已更改</h2>
+
+<p>您账户的邮箱地址已从 {{ .Email }} 更改为 {{ .NewEmail }}。</p>
+<p>如果不是您本人操作，请立即联系客服。</p>`,
+	},
+	PhoneChangedNotification: {
+		i18n.LanguageEnglish: `<h2>Your phone number was changed</h2>
+
+<p>The phone number of your account {{ .Email }} was changed to {{ .Phone }}.</p>
+<p>If you didn't change it, reset your password and contact support immediately.</p>`,
+		i18n.LanguageChinese: `<h2>您的手机号码已更改</h2>
+
+<p>您的账户 {{ .Email }} 的手机号码已更改为 {{ .Phone }}。</p>
+<p>如果不是您本人操作，请立即重置密码并联系客服。</p>`,
+	},
+	FactorEnrolledNotification: {
+		i18n.LanguageEnglish: `<h2>A multi-factor authentication method was added</h2>
+
+<p>A {{ .FactorType }} factor{{ if .FriendlyName }} named {{ .FriendlyName }}{{ end }} was added to your account {{ .Email }}.</p>
+<p>If you didn't add it, reset your password and contact support immediately.</p>`,
+		i18n.LanguageChinese: `<h2>已添加多因素认证方式</h2>
+
+<p>您的账户 {{ .Email }} 已添加 {{ .FactorType }} 认证因素{{ if .FriendlyName }}（{{ .FriendlyName }}）{{ end }}。</p>
+<p>如果不是您本人操作，请立即重置密码并联系客服。</p>`,
+	},
+	FactorRemovedNotification: {
+		i18n.LanguageEnglish: `<h2>A multi-factor authentication method was removed</h2>
+
+<p>A {{ .FactorType }} factor{{ if .FriendlyName }} named {{ .FriendlyName }}{{ end }} was removed from your account {{ .Email }}.</p>
+<p>If you didn't remove it, reset your password and contact support immediately.</p>`,
+		i18n.LanguageChinese: `<h2>已移除多因素认证方式</h2>
+
+<p>您的账户 {{ .Email }} 已移除 {{ .FactorType }} 认证因素{{ if .FriendlyName }}（{{ .FriendlyName }}）{{ end }}。</p>
+<p>如果不是您本人操作，请立即重置密码并联系客服。</p>`,
+	},
+	IdentityLinkedNotification: {
+		i18n.LanguageEnglish: `<h2>A sign in method was linked to your account</h2>
+
+<p>Your {{ .Provider }} account was linked to your account {{ .Email }}, and can now be used to sign in.</p>
+<p>If you didn't link it, reset your password and contact support immediately.</p>`,
+		i18n.LanguageChinese: `<h2>已有登录方式关联到您的账户</h2>
+
+<p>您的 {{ .Provider }} 账户已关联到您的账户 {{ .Email }}，现在可用于登录。</p>
+<p>如果不是您本人操作，请立即重置密码并联系客服。</p>`,
+	},
+	IdentityUnlinkedNotification: {
+		i18n.LanguageEnglish: `<h2>A sign in method was unlinked from your account</h2>
+
+<p>Your {{ .Provider }} account was unlinked from your account {{ .Email }}, and can no longer be used to sign in.</p>
+<p>If you didn't unlink it, reset your password and contact support immediately.</p>`,
+		i18n.LanguageChinese: `<h2>已有登录方式与您的账户解除关联</h2>
+
+<p>您的 {{ .Provider }} 账户已与您的账户 {{ .Email }} 解除关联，无法再用于登录。</p>
+<p>如果不是您本人操作，请立即重置密码并联系客服。</p>`,
+	},
+	DeletionScheduledNotification: {
+		i18n.LanguageEnglish: `<h2>Your account is scheduled for deletion</h2>
+
+<p>Your account {{ .Email }} will be deleted on {{ .DeletionScheduledAt }}.</p>
+<p>Sign in before then to keep your account. If you didn't request the deletion, sign in, reset your password and contact support immediately.</p>`,
+		i18n.LanguageChinese: `<h2>您的账户已计划删除</h2>
+
+<p>您的账户 {{ .Email }} 将于 {{ .DeletionScheduledAt }} 被删除。</p>
+<p>在此之前登录即可保留您的账户。如果不是您本人操作，请登录后立即重置密码并联系客服。</p>`,
+	},
+}
+
+// NotificationConfig returns the configuration of the notification type.
+func NotificationConfig(config *conf.MailerNotificationsConfiguration, typ string) (*conf.MailerNotificationConfiguration, bool) {
+	switch typ {
+	case NewDeviceNotification:
+		return &config.NewDevice, true
+	case PasswordChangedNotification:
+		return &config.PasswordChanged, true
+	case EmailChangedNotification:
+		return &config.EmailChanged, true
+	case PhoneChangedNotification:
+		return &config.PhoneChanged, true
+	case FactorEnrolledNotification:
+		return &config.FactorEnrolled, true
+	case FactorRemovedNotification:
+		return &config.FactorRemoved, true
+	case IdentityLinkedNotification:
+		return &config.IdentityLinked, true
+	case IdentityUnlinkedNotification:
+		return &config.IdentityUnlinked, true
+	case DeletionScheduledNotification:
+		return &config.DeletionScheduled, true
+	}
+
+	return nil, false
+}
+
+// localized returns the text for the language, falling back to English.
+func localized(texts map[i18n.Language]string, lang i18n.Language) string {
+	if text, ok := texts[lang]; ok {
+		return text
+	}
+	return texts[i18n.LanguageEnglish]
+}
+
+// NotificationMail sends a notification of the type to the user, in the
+// language of the request unless a template is configured. The data is
+// available in the template along with the user's details.
+func (m *TemplateMailer) NotificationMail(r *http.Request, user *models.User, typ string, data map[string]interface{}) error {
+	config, ok := NotificationConfig(&m.Config.Mailer.Notifications, typ)
+	if !ok {
+		return fmt.Errorf("mailer: invalid notification type %q", typ)
+	}
+
+	lang := i18n.GetLanguageFromContextHTTP(r)
+
+	templateData := map[string]interface{}{
+		"SiteURL":  m.Config.SiteURL,
+		"Email":    user.Email,
+		"Data":     user.UserMetaData,
+		"Language": string(lang),
+	}
+	for key, value := range data {
+		templateData[key] = value
+	}
+
+	return m.Mailer.Mail(
+		r.Context(),
+		user.GetEmail(),
+		withDefault(config.Subject, localized(defaultNotificationSubjects[typ], lang)),
+		config.Template,
+		localized(defaultNotificationTemplates[typ], lang),
+		templateData,
+		m.Headers(typ),
+		typ,
+	)
+}
@@ -0,0 +1,89 @@
+package mailer
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/i18n"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/storage"
+)
+
+func TestNotificationMail(t *testing.T) {
+	m := &TemplateMailer{
+		SiteURL: "https://example.com",
+		Config: &conf.GlobalConfiguration{
+			SiteURL: "https://example.com",
+		},
+	}
+	user := &models.User{Email: storage.NullString("user@example.com")}
+
+	data := EmailData{
+		EmailActionType: NewDeviceNotification,
+		NotificationData: map[string]interface{}{
+			"IPAddress": "127.0.0.1",
+			"UserAgent": "test-agent",
+			"RevokeURL": "https://auth.example.com/revoke_sign_in?token=abc",
+		},
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	messages, err := m.Preview(r, user, data, nil)
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	require.Equal(t, "user@example.com", messages[0].To)
+	require.Equal(t, "New sign in to your account", messages[0].Subject)
+	require.Contains(t, messages[0].HTML, "test-agent")
+	require.Contains(t, messages[0].HTML, "https://auth.example.com/revoke_sign_in?token=abc")
+
+	r = r.WithContext(context.WithValue(r.Context(), i18n.UserLanguageKey, i18n.LanguageChinese))
+	messages, err = m.Preview(r, user, data, nil)
+	require.NoError(t, err)
+	require.Equal(t, "您的账户有新的登录", messages[0].Subject)
+
+	m.Config.Mailer.Notifications.NewDevice.Subject = "Was this you?"
+	messages, err = m.Preview(r, user, data, nil)
+	require.NoError(t, err)
+	require.Equal(t, "Was this you?", messages[0].Subject)
+
+	data.EmailActionType = "unknown"
+	_, err = m.Preview(r, user, data, nil)
+	require.Error(t, err)
+}
+
+func TestNotificationTemplates(t *testing.T) {
+	m := &TemplateMailer{
+		SiteURL: "https://example.com",
+		Config: &conf.GlobalConfiguration{
+			SiteURL: "https://example.com",
+		},
+	}
+	user := &models.User{Email: storage.NullString("user@example.com")}
+
+	for typ, templates := range defaultNotificationTemplates {
+		_, ok := NotificationConfig(&m.Config.Mailer.Notifications, typ)
+		require.True(t, ok, typ)
+
+		for _, lang := range []i18n.Language{i18n.LanguageEnglish, i18n.LanguageChinese} {
+			require.NotEmpty(t, templates[lang], typ)
+			require.NotEmpty(t, defaultNotificationSubjects[typ][lang], typ)
+
+			r := httptest.NewRequest("GET", "/", nil)
+			r = r.WithContext(context.WithValue(r.Context(), i18n.UserLanguageKey, lang))
+
+			messages, err := m.Preview(r, user, EmailData{
+				EmailActionType: typ,
+				NotificationData: map[string]interface{}{
+					"Provider":   "github",
+					"FactorType": "totp",
+				},
+			}, nil)
+			require.NoError(t, err, typ)
+			require.Equal(t, defaultNotificationSubjects[typ][lang], messages[0].Subject)
+			require.Contains(t, messages[0].HTML, "user@example.com", typ)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+
+	"github.com/supabase/auth/internal/models"
+)
+
+// previewMailClient renders mails instead of sending them.
+type previewMailClient struct {
+	renderer *TemplateRenderer
+
+	mu       sync.Mutex
+	messages []*Message
+}
+
+func (c *previewMailClient) Mail(
+	ctx context.Context,
+	to, subjectTemplate, templateURL, defaultTemplate string,
+	templateData map[string]interface{},
+	headers map[string][]string,
+	typ string,
+) error {
+	msg, err := c.renderer.Render(ctx, typ, subjectTemplate, templateURL, defaultTemplate, templateData)
+	if err != nil {
+		return err
+	}
+	msg.To = to
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.messages = append(c.messages, msg)
+	return nil
+}
+
+// Preview renders the mails that would be sent to the user for the email
+// action type in data, without sending them. The tokens in data are used in
+// place of the user's tokens.
+func (m *TemplateMailer) Preview(r *http.Request, user *models.User, data EmailData, externalURL *url.URL) ([]*Message, error) {
+	renderer := m.Renderer
+	if renderer == nil {
+		renderer = &TemplateRenderer{BaseURL: m.SiteURL}
+	}
+
+	client := &previewMailClient{renderer: renderer}
+	preview := &TemplateMailer{
+		SiteURL:  m.SiteURL,
+		Config:   m.Config,
+		Mailer:   client,
+		Renderer: renderer,
+	}
+
+	user.ConfirmationToken = data.TokenHash
+	user.RecoveryToken = data.TokenHash
+	user.EmailChangeTokenNew = data.TokenHashNew
+	user.EmailChangeTokenCurrent = data.TokenHash
+
+	var err error
+	switch data.EmailActionType {
+	case SignupVerification:
+		err = preview.ConfirmationMail(r, user, data.Token, data.RedirectTo, externalURL)
+	case MagicLinkVerification:
+		err = preview.MagicLinkMail(r, user, data.Token, data.RedirectTo, externalURL)
+	case ReauthenticationVerification:
+		err = preview.ReauthenticateMail(r, user, data.Token)
+	case RecoveryVerification:
+		err = preview.RecoveryMail(r, user, data.Token, data.RedirectTo, externalURL)
+	case InviteVerification:
+		err = preview.InviteMail(r, user, data.Token, data.RedirectTo, externalURL)
+	case EmailChangeVerification:
+		err = preview.EmailChangeMail(r, user, data.TokenNew, data.Token, data.RedirectTo, externalURL)
+	default:
+		if _, ok := NotificationConfig(&m.Config.Mailer.Notifications, data.EmailActionType); ok {
+			err = preview.NotificationMail(r, user, data.EmailActionType, data.NotificationData)
+			break
+		}
+		err = fmt.Errorf("mailer: invalid email action type %q", data.EmailActionType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// email change mails are rendered concurrently
+	sort.SliceStable(client.messages, func(i, j int) bool {
+		return client.messages[i].To < client.messages[j].To
+	})
+
+	return client.messages, nil
+}
@@ -0,0 +1,350 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"html"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+
+	"github.com/sirupsen/logrus"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// Message is a rendered mail, ready to be sent.
+type Message struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	HTML    string `json:"html"`
+	Text    string `json:"text"`
+}
+
+// TemplateRenderer renders the subject and body of a mail. Templates are
+// looked up in this order:
+//
+//  1. The mail_templates table, when LocalTemplates.DB is set.
+//  2. The <type>.html file in LocalTemplates.Path.
+//  3. The template URL configured for the mail type.
+//  4. The built-in default template.
+//
+// A plain-text alternative is derived from the HTML body unless the template
+// provides one.
+type TemplateRenderer struct {
+	BaseURL   string
+	FuncMap   template.FuncMap
+	Local     *LocalTemplates
+	InlineCSS bool
+	Logger    logrus.FieldLogger
+
+	init  sync.Once
+	cache *TemplateCache
+}
+
+// LocalTemplates are templates managed by Auth, stored on disk or in the
+// database. Both can use the layouts and partials found under Path, which
+// are parsed for every mail unless Layouts has them already.
+type LocalTemplates struct {
+	Path    string
+	DB      *storage.Connection
+	Layouts *LocalLayouts
+}
+
+// LocalLayouts are the parsed layouts and partials of a local templates path.
+type LocalLayouts struct {
+	Path string
+
+	set *template.Template
+}
+
+// ParseLocalLayouts parses the layouts/*.html and partials/*.html files under
+// path, so that they can be parsed once and used for every mail.
+func ParseLocalLayouts(path string, funcMap template.FuncMap) (*LocalLayouts, error) {
+	set := template.New("layouts").Funcs(funcMap)
+	for _, dir := range []string{"layouts", "partials"} {
+		matches, err := filepath.Glob(filepath.Join(path, dir, "*.html"))
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) > 0 {
+			if _, err := set.ParseFiles(matches...); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &LocalLayouts{Path: path, set: set}, nil
+}
+
+// layouts returns a copy of the parsed layouts and partials of Path, to
+// which the mail's template can be added.
+func (t *LocalTemplates) layouts(funcMap template.FuncMap) (*template.Template, error) {
+	layouts := t.Layouts
+	if layouts == nil || layouts.Path != t.Path {
+		var err error
+		if layouts, err = ParseLocalLayouts(t.Path, funcMap); err != nil {
+			return nil, err
+		}
+	}
+
+	set, err := layouts.set.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	return set.Funcs(funcMap), nil
+}
+
+func (r *TemplateRenderer) setup() {
+	r.init.Do(func() {
+		if r.FuncMap == nil {
+			r.FuncMap = template.FuncMap{}
+		}
+		if r.Logger == nil {
+			r.Logger = logrus.StandardLogger()
+		}
+		r.cache = &TemplateCache{
+			templates: map[string]*MailTemplate{},
+			funcMap:   r.FuncMap,
+			logger:    r.Logger,
+		}
+	})
+}
+
+// Render renders the mail of type typ. The subject template, template URL and
+// default template are only used when no local template exists for typ.
+func (r *TemplateRenderer) Render(ctx context.Context, typ, subjectTemplate, templateURL, defaultTemplate string, data map[string]interface{}) (*Message, error) {
+	r.setup()
+
+	msg, err := r.Local.render(ctx, typ, r.FuncMap, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if msg == nil {
+		msg = &Message{}
+		if err := r.renderRemote(msg, subjectTemplate, templateURL, defaultTemplate, data); err != nil {
+			return nil, err
+		}
+	} else if msg.Subject == "" {
+		if msg.Subject, err = r.renderSubject(subjectTemplate, data); err != nil {
+			return nil, err
+		}
+	}
+
+	if r.InlineCSS {
+		inlined, err := inlineCSS(msg.HTML)
+		if err != nil {
+			return nil, err
+		}
+		msg.HTML = inlined
+	}
+
+	if msg.Text == "" {
+		text, err := htmlToText(msg.HTML)
+		if err != nil {
+			return nil, err
+		}
+		msg.Text = text
+	}
+
+	return msg, nil
+}
+
+func (r *TemplateRenderer) renderSubject(subjectTemplate string, data map[string]interface{}) (string, error) {
+	tmp, err := template.New("Subject").Funcs(r.FuncMap).Parse(subjectTemplate)
+	if err != nil {
+		return "", err
+	}
+
+	buf := &bytes.Buffer{}
+	if err := tmp.Execute(buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func (r *TemplateRenderer) renderRemote(msg *Message, subjectTemplate, url, defaultTemplate string, data map[string]interface{}) error {
+	var err error
+	if msg.Subject, err = r.renderSubject(subjectTemplate, data); err != nil {
+		return err
+	}
+
+	var temp *template.Template
+	if url != "" {
+		var absoluteURL string
+		if strings.HasPrefix(url, "http") {
+			absoluteURL = url
+		} else {
+			absoluteURL = r.BaseURL + url
+		}
+		temp, err = r.cache.Get(absoluteURL)
+		if err != nil {
+			r.Logger.WithError(err).Warnf("Error loading template from %v", url)
+		}
+	}
+
+	if temp == nil {
+		r.cache.mutex.Lock()
+		cached, ok := r.cache.templates[url]
+		r.cache.mutex.Unlock()
+
+		if ok {
+			temp = cached.tmp
+		} else {
+			temp, err = r.cache.Set(url, defaultTemplate, 0)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := temp.Execute(buf, data); err != nil {
+		return err
+	}
+	msg.HTML = buf.String()
+
+	return nil
+}
+
+// render renders the local template for typ, returning nil when there is
+// none.
+//
+// The HTML template is parsed together with all layouts/*.html and
+// partials/*.html files. If it defines a "content" template and a layout
+// defines "layout", the layout is rendered; otherwise the template itself is.
+// The template may also define "subject" and "text" templates.
+func (t *LocalTemplates) render(ctx context.Context, typ string, funcMap template.FuncMap, data map[string]interface{}) (*Message, error) {
+	if t == nil {
+		return nil, nil
+	}
+
+	var subjectSource, htmlSource, textSource string
+
+	found := false
+	if t.DB != nil {
+		stored, err := models.FindMailTemplateByType(t.DB.WithContext(ctx), typ)
+		if err != nil && !models.IsNotFoundError(err) {
+			return nil, err
+		}
+		if stored != nil {
+			found = true
+			htmlSource = stored.ContentHTML
+			if stored.Subject != nil {
+				subjectSource = *stored.Subject
+			}
+			if stored.ContentText != nil {
+				textSource = *stored.ContentText
+			}
+		}
+	}
+
+	if !found && t.Path != "" {
+		content, err := readTemplateFile(filepath.Join(t.Path, typ+".html"))
+		if err != nil {
+			return nil, err
+		}
+		if content != nil {
+			found = true
+			htmlSource = *content
+		}
+
+		if found {
+			content, err := readTemplateFile(filepath.Join(t.Path, typ+".txt"))
+			if err != nil {
+				return nil, err
+			}
+			if content != nil {
+				textSource = *content
+			}
+		}
+	}
+
+	if !found {
+		return nil, nil
+	}
+
+	set := template.New(typ).Funcs(funcMap)
+	if t.Path != "" {
+		var err error
+		if set, err = t.layouts(funcMap); err != nil {
+			return nil, err
+		}
+	}
+
+	page, err := set.New("page").Parse(htmlSource)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := page
+	if set.Lookup("content") != nil && set.Lookup("layout") != nil {
+		entry = set.Lookup("layout")
+	}
+
+	msg := &Message{}
+
+	buf := &bytes.Buffer{}
+	if err := entry.Execute(buf, data); err != nil {
+		return nil, err
+	}
+	msg.HTML = buf.String()
+
+	if subjectSource != "" {
+		if msg.Subject, err = renderText("Subject", subjectSource, funcMap, data); err != nil {
+			return nil, err
+		}
+	} else if subject := set.Lookup("subject"); subject != nil {
+		buf.Reset()
+		if err := subject.Execute(buf, data); err != nil {
+			return nil, err
+		}
+		msg.Subject = strings.TrimSpace(html.UnescapeString(buf.String()))
+	}
+
+	if textSource != "" {
+		if msg.Text, err = renderText("Text", textSource, funcMap, data); err != nil {
+			return nil, err
+		}
+	} else if text := set.Lookup("text"); text != nil {
+		buf.Reset()
+		if err := text.Execute(buf, data); err != nil {
+			return nil, err
+		}
+		msg.Text = strings.TrimSpace(html.UnescapeString(buf.String()))
+	}
+
+	return msg, nil
+}
+
+func readTemplateFile(path string) (*string, error) {
+	content, err := os.ReadFile(path) // #nosec G304 -- path is from configuration
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	s := string(content)
+	return &s, nil
+}
+
+func renderText(name, source string, funcMap template.FuncMap, data map[string]interface{}) (string, error) {
+	tmp, err := texttemplate.New(name).Funcs(texttemplate.FuncMap(funcMap)).Parse(source)
+	if err != nil {
+		return "", err
+	}
+
+	buf := &bytes.Buffer{}
+	if err := tmp.Execute(buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
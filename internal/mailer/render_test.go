@@ -0,0 +1,135 @@
+package mailer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeTemplateFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+	return dir
+}
+
+func TestTemplateRendererLocalTemplates(t *testing.T) {
+	dir := writeTemplateFiles(t, map[string]string{
+		"layouts/base.html": `{{ define "layout" }}<html><head><style>p { color: red } .big { font-size: 20px }</style></head>` +
+			`<body>{{ template "content" . }}{{ template "footer" . }}</body></html>{{ end }}`,
+		"partials/footer.html": `{{ define "footer" }}<p class="big">Sent by {{ .SiteURL }}</p>{{ end }}`,
+		"recovery.html": `{{ define "subject" }}Reset for {{ .Email }}{{ end }}` +
+			`{{ define "content" }}<h2>Reset password</h2><p>Follow <a href="{{ .ConfirmationURL }}">this link</a></p>{{ end }}`,
+		"invite.html": `<p>Invite {{ .Email }}</p>`,
+		"invite.txt":  `Invite for {{ .Email }}`,
+	})
+
+	renderer := &TemplateRenderer{
+		Local:     &LocalTemplates{Path: dir},
+		InlineCSS: true,
+	}
+
+	data := map[string]interface{}{
+		"SiteURL":         "https://example.com",
+		"ConfirmationURL": "https://example.com/verify?token=abc&type=recovery",
+		"Email":           "user@example.com",
+	}
+
+	t.Run("LayoutAndPartials", func(t *testing.T) {
+		msg, err := renderer.Render(context.Background(), "recovery", "Default subject", "", defaultRecoveryMail, data)
+		require.NoError(t, err)
+
+		require.Equal(t, "Reset for user@example.com", msg.Subject)
+		require.Contains(t, msg.HTML, "<h2>Reset password</h2>")
+		require.Contains(t, msg.HTML, `<p style="color: red">Follow`)
+		require.Contains(t, msg.HTML, `<p class="big" style="color: red; font-size: 20px">Sent by https://example.com</p>`)
+		require.NotContains(t, msg.HTML, "<style>")
+
+		require.Equal(t, "Reset password\n\nFollow this link (https://example.com/verify?token=abc&type=recovery)\n\nSent by https://example.com", msg.Text)
+	})
+
+	t.Run("TextTemplate", func(t *testing.T) {
+		msg, err := renderer.Render(context.Background(), "invite", "You have been invited", "", defaultInviteMail, data)
+		require.NoError(t, err)
+
+		require.Equal(t, "You have been invited", msg.Subject)
+		require.Equal(t, "<p>Invite user@example.com</p>", msg.HTML)
+		require.Equal(t, "Invite for user@example.com", msg.Text)
+	})
+
+	t.Run("FallbackToDefault", func(t *testing.T) {
+		msg, err := renderer.Render(context.Background(), "magiclink", "Your Magic Link", "", defaultMagicLinkMail, data)
+		require.NoError(t, err)
+
+		require.Equal(t, "Your Magic Link", msg.Subject)
+		require.Contains(t, msg.HTML, "<h2>Magic Link</h2>")
+		require.Contains(t, msg.Text, "Magic Link\n\nFollow this link to login:")
+	})
+
+	t.Run("ParsedLayouts", func(t *testing.T) {
+		layouts, err := ParseLocalLayouts(dir, nil)
+		require.NoError(t, err)
+
+		parsed := &TemplateRenderer{
+			Local: &LocalTemplates{Path: dir, Layouts: layouts},
+		}
+
+		// the layouts and partials are not read again
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "partials", "footer.html"), []byte(`{{ define "footer" }}changed{{ end }}`), 0o600))
+
+		for i := 0; i < 2; i++ {
+			msg, err := parsed.Render(context.Background(), "recovery", "Default subject", "", defaultRecoveryMail, data)
+			require.NoError(t, err)
+			require.Contains(t, msg.HTML, "<h2>Reset password</h2>")
+			require.Contains(t, msg.HTML, "Sent by https://example.com")
+		}
+	})
+}
+
+func TestInlineCSS(t *testing.T) {
+	cases := []struct {
+		desc     string
+		body     string
+		expected string
+	}{
+		{
+			desc:     "no stylesheet",
+			body:     `<p>hello</p>`,
+			expected: `<p>hello</p>`,
+		},
+		{
+			desc:     "specificity and existing style attribute",
+			body:     `<style>#main { color: blue } p { color: red; margin: 0 } .x { color: green }</style><p id="main" class="x" style="color: black">hi</p>`,
+			expected: `<html><head></head><body><p id="main" class="x" style="color: red; margin: 0; color: green; color: blue; color: black">hi</p></body></html>`,
+		},
+		{
+			desc:     "unsupported rules are kept",
+			body:     `<style>p { color: red } a:hover { color: blue } @media (max-width: 600px) { p { color: green } }</style><p>hi</p>`,
+			expected: `<html><head><style>a:hover { color: blue }` + "\n" + `@media (max-width: 600px) { p { color: green } }</style></head><body><p style="color: red">hi</p></body></html>`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			out, err := inlineCSS(c.body)
+			require.NoError(t, err)
+			require.Equal(t, c.expected, out)
+		})
+	}
+}
+
+func TestHTMLToText(t *testing.T) {
+	text, err := htmlToText(defaultEmailChangeMail)
+	require.NoError(t, err)
+	require.Equal(t, "Confirm email address change\n\nFollow this link to confirm the update of your email address from {{ .Email }} to {{ .NewEmail }}:\n\nChange email address ({{ .ConfirmationURL }})\n\nAlternatively, enter the code: {{ .Token }}", text)
+
+	text, err = htmlToText(`<ul><li>one</li><li>two</li></ul>line<br>break <a href="mailto:a@example.com">a@example.com</a>`)
+	require.NoError(t, err)
+	require.Equal(t, "- one\n- two\nline\nbreak a@example.com", text)
+}
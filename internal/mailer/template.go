@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/crypto"
 	"github.com/supabase/auth/internal/models"
 )
 
@@ -36,9 +37,10 @@ type MailClient interface {
 
 // TemplateMailer will send mail and use templates from the site for easy mail styling
 type TemplateMailer struct {
-	SiteURL string
-	Config  *conf.GlobalConfiguration
-	Mailer  MailClient
+	SiteURL  string
+	Config   *conf.GlobalConfiguration
+	Mailer   MailClient
+	Renderer *TemplateRenderer
 }
 
 func encodeRedirectURL(referrerURL string) string {
@@ -137,11 +139,11 @@ func (m *TemplateMailer) Headers(messageType string) map[string][]string {
 
 // InviteMail sends a invite mail to a new user
 func (m *TemplateMailer) InviteMail(r *http.Request, user *models.User, otp, referrerURL string, externalURL *url.URL) error {
-	path, err := getPath(m.Config.Mailer.URLPaths.Invite, &EmailParams{
+	path, err := getPath(m.Config.Mailer.URLPaths.Invite, m.withAudience(user, &EmailParams{
 		Token:      user.ConfirmationToken,
 		Type:       "invite",
 		RedirectTo: referrerURL,
-	})
+	}))
 
 	if err != nil {
 		return err
@@ -171,11 +173,11 @@ func (m *TemplateMailer) InviteMail(r *http.Request, user *models.User, otp, ref
 
 // ConfirmationMail sends a signup confirmation mail to a new user
 func (m *TemplateMailer) ConfirmationMail(r *http.Request, user *models.User, otp, referrerURL string, externalURL *url.URL) error {
-	path, err := getPath(m.Config.Mailer.URLPaths.Confirmation, &EmailParams{
+	path, err := getPath(m.Config.Mailer.URLPaths.Confirmation, m.withAudience(user, &EmailParams{
 		Token:      user.ConfirmationToken,
 		Type:       "signup",
 		RedirectTo: referrerURL,
-	})
+	}))
 	if err != nil {
 		return err
 	}
@@ -260,11 +262,11 @@ func (m *TemplateMailer) EmailChangeMail(r *http.Request, user *models.User, otp
 	for _, email := range emails {
 		path, err := getPath(
 			m.Config.Mailer.URLPaths.EmailChange,
-			&EmailParams{
+			m.withAudience(user, &EmailParams{
 				Token:      email.TokenHash,
 				Type:       "email_change",
 				RedirectTo: referrerURL,
-			},
+			}),
 		)
 		if err != nil {
 			return err
@@ -305,11 +307,11 @@ func (m *TemplateMailer) EmailChangeMail(r *http.Request, user *models.User, otp
 
 // RecoveryMail sends a password recovery mail
 func (m *TemplateMailer) RecoveryMail(r *http.Request, user *models.User, otp, referrerURL string, externalURL *url.URL) error {
-	path, err := getPath(m.Config.Mailer.URLPaths.Recovery, &EmailParams{
+	path, err := getPath(m.Config.Mailer.URLPaths.Recovery, m.withAudience(user, &EmailParams{
 		Token:      user.RecoveryToken,
 		Type:       "recovery",
 		RedirectTo: referrerURL,
-	})
+	}))
 	if err != nil {
 		return err
 	}
@@ -337,11 +339,11 @@ func (m *TemplateMailer) RecoveryMail(r *http.Request, user *models.User, otp, r
 
 // MagicLinkMail sends a login link mail
 func (m *TemplateMailer) MagicLinkMail(r *http.Request, user *models.User, otp, referrerURL string, externalURL *url.URL) error {
-	path, err := getPath(m.Config.Mailer.URLPaths.Recovery, &EmailParams{
+	path, err := getPath(m.Config.Mailer.URLPaths.Recovery, m.withAudience(user, &EmailParams{
 		Token:      user.RecoveryToken,
 		Type:       "magiclink",
 		RedirectTo: referrerURL,
-	})
+	}))
 	if err != nil {
 		return err
 	}
@@ -368,6 +370,18 @@ func (m *TemplateMailer) MagicLinkMail(r *http.Request, user *models.User, otp,
 	)
 }
 
+// withAudience adds the signed audience of users that aren't in the default
+// audience to the link parameters.
+func (m *TemplateMailer) withAudience(user *models.User, params *EmailParams) *EmailParams {
+	if user.Aud == "" || user.Aud == m.Config.JWT.Aud {
+		return params
+	}
+
+	params.Aud = user.Aud
+	params.AudSignature = crypto.GenerateAudienceSignature(m.Config.JWT.Secret, user.Aud, params.Token)
+	return params
+}
+
 // GetEmailActionLink returns a magiclink, recovery or invite link based on the actionType passed.
 func (m TemplateMailer) GetEmailActionLink(user *models.User, actionType, referrerURL string, externalURL *url.URL) (string, error) {
 	var err error
@@ -375,41 +389,41 @@ func (m TemplateMailer) GetEmailActionLink(user *models.User, actionType, referr
 
 	switch actionType {
 	case "magiclink":
-		path, err = getPath(m.Config.Mailer.URLPaths.Recovery, &EmailParams{
+		path, err = getPath(m.Config.Mailer.URLPaths.Recovery, m.withAudience(user, &EmailParams{
 			Token:      user.RecoveryToken,
 			Type:       "magiclink",
 			RedirectTo: referrerURL,
-		})
+		}))
 	case "recovery":
-		path, err = getPath(m.Config.Mailer.URLPaths.Recovery, &EmailParams{
+		path, err = getPath(m.Config.Mailer.URLPaths.Recovery, m.withAudience(user, &EmailParams{
 			Token:      user.RecoveryToken,
 			Type:       "recovery",
 			RedirectTo: referrerURL,
-		})
+		}))
 	case "invite":
-		path, err = getPath(m.Config.Mailer.URLPaths.Invite, &EmailParams{
+		path, err = getPath(m.Config.Mailer.URLPaths.Invite, m.withAudience(user, &EmailParams{
 			Token:      user.ConfirmationToken,
 			Type:       "invite",
 			RedirectTo: referrerURL,
-		})
+		}))
 	case "signup":
-		path, err = getPath(m.Config.Mailer.URLPaths.Confirmation, &EmailParams{
+		path, err = getPath(m.Config.Mailer.URLPaths.Confirmation, m.withAudience(user, &EmailParams{
 			Token:      user.ConfirmationToken,
 			Type:       "signup",
 			RedirectTo: referrerURL,
-		})
+		}))
 	case "email_change_current":
-		path, err = getPath(m.Config.Mailer.URLPaths.EmailChange, &EmailParams{
+		path, err = getPath(m.Config.Mailer.URLPaths.EmailChange, m.withAudience(user, &EmailParams{
 			Token:      user.EmailChangeTokenCurrent,
 			Type:       "email_change",
 			RedirectTo: referrerURL,
-		})
+		}))
 	case "email_change_new":
-		path, err = getPath(m.Config.Mailer.URLPaths.EmailChange, &EmailParams{
+		path, err = getPath(m.Config.Mailer.URLPaths.EmailChange, m.withAudience(user, &EmailParams{
 			Token:      user.EmailChangeTokenNew,
 			Type:       "email_change",
 			RedirectTo: referrerURL,
-		})
+		}))
 	default:
 		return "", fmt.Errorf("invalid email action link type: %s", actionType)
 	}
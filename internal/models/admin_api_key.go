@@ -0,0 +1,177 @@
+package models
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/crypto"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// AdminAPIKeyPrefix prefixes admin API keys, which tells them apart from
+// admin JWTs in the Authorization header.
+const AdminAPIKeyPrefix = "sb_admin_"
+
+// adminAPIKeyHintLength is the number of random characters of the key kept
+// in the hint.
+const adminAPIKeyHintLength = 4
+
+// HashAdminAPIKey hashes an admin API key. Keys are random, so they don't
+// need a slow password hash.
+func HashAdminAPIKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+// AdminAPIKeyPermissions are the admin permissions granted to an admin API
+// key.
+type AdminAPIKeyPermissions []string
+
+func (p *AdminAPIKeyPermissions) Scan(src interface{}) error {
+	b, ok := src.([]byte)
+	if !ok {
+		return errors.New("scan source was not []byte")
+	}
+	return json.Unmarshal(b, p)
+}
+
+func (p AdminAPIKeyPermissions) Value() (driver.Value, error) {
+	if p == nil {
+		p = AdminAPIKeyPermissions{}
+	}
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// AdminAPIKey is an opaque key that authenticates requests to the admin
+// API, as an alternative to admin JWTs. Only a hash of the key is stored.
+type AdminAPIKey struct {
+	ID          uuid.UUID              `json:"id" db:"id"`
+	Name        string                 `json:"name" db:"name"`
+	KeyHash     string                 `json:"-" db:"key_hash"`
+	KeyHint     string                 `json:"key_hint" db:"key_hint"`
+	Permissions AdminAPIKeyPermissions `json:"permissions" db:"permissions"`
+
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+func (AdminAPIKey) TableName() string {
+	tableName := "admin_api_keys"
+	return tableName
+}
+
+// NewAdminAPIKey returns a new admin API key along with the key itself,
+// which is only available now.
+func NewAdminAPIKey(name string, permissions []string, expiresAt *time.Time) (*AdminAPIKey, string) {
+	k := &AdminAPIKey{
+		ID:          uuid.Must(uuid.NewV4()),
+		Name:        name,
+		Permissions: permissions,
+		ExpiresAt:   expiresAt,
+	}
+
+	return k, k.setKey()
+}
+
+func (k *AdminAPIKey) setKey() string {
+	key := AdminAPIKeyPrefix + crypto.SecureAlphanumeric(48)
+
+	k.KeyHash = HashAdminAPIKey(key)
+	k.KeyHint = key[:len(AdminAPIKeyPrefix)+adminAPIKeyHintLength]
+
+	return key
+}
+
+// IsActive reports whether the key can be used, i.e. it has neither been
+// revoked nor expired.
+func (k *AdminAPIKey) IsActive(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+// Rotate replaces the key with a new one, keeping the permissions and
+// expiry. The previous key stops working immediately.
+func (k *AdminAPIKey) Rotate(tx *storage.Connection) (string, error) {
+	key := k.setKey()
+
+	if err := tx.UpdateOnly(k, "key_hash", "key_hint", "updated_at"); err != nil {
+		return "", errors.Wrap(err, "error rotating admin API key")
+	}
+
+	return key, nil
+}
+
+// Revoke stops the key from working.
+func (k *AdminAPIKey) Revoke(tx *storage.Connection) error {
+	now := time.Now()
+	k.RevokedAt = &now
+
+	return tx.UpdateOnly(k, "revoked_at", "updated_at")
+}
+
+// UpdateLastUsedAt records that the key was just used.
+func (k *AdminAPIKey) UpdateLastUsedAt(tx *storage.Connection) error {
+	now := time.Now()
+	k.LastUsedAt = &now
+
+	return tx.UpdateOnly(k, "last_used_at")
+}
+
+func FindAdminAPIKeyByID(tx *storage.Connection, id uuid.UUID) (*AdminAPIKey, error) {
+	var k AdminAPIKey
+
+	if err := tx.Q().Where("id = ?", id).First(&k); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, AdminAPIKeyNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error finding admin API key")
+	}
+
+	return &k, nil
+}
+
+// FindAdminAPIKeyByKey finds the admin API key with the key, whether or not
+// it's active.
+func FindAdminAPIKeyByKey(tx *storage.Connection, key string) (*AdminAPIKey, error) {
+	var k AdminAPIKey
+
+	if err := tx.Q().Where("key_hash = ?", HashAdminAPIKey(key)).First(&k); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, AdminAPIKeyNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error finding admin API key by key")
+	}
+
+	return &k, nil
+}
+
+// FindAdminAPIKeys finds all admin API keys, newest first.
+func FindAdminAPIKeys(tx *storage.Connection) ([]*AdminAPIKey, error) {
+	keys := []*AdminAPIKey{}
+
+	if err := tx.Q().Order("created_at desc, id asc").All(&keys); err != nil && errors.Cause(err) != sql.ErrNoRows {
+		return nil, errors.Wrap(err, "error finding admin API keys")
+	}
+
+	return keys, nil
+}
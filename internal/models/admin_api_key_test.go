@@ -0,0 +1,52 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAdminAPIKey(t *testing.T) {
+	k, key := NewAdminAPIKey("support", []string{"users:read"}, nil)
+
+	require.True(t, strings.HasPrefix(key, AdminAPIKeyPrefix))
+	require.Equal(t, HashAdminAPIKey(key), k.KeyHash)
+	require.NotContains(t, k.KeyHash, key)
+	require.True(t, strings.HasPrefix(key, k.KeyHint))
+	require.Len(t, k.KeyHint, len(AdminAPIKeyPrefix)+adminAPIKeyHintLength)
+
+	_, other := NewAdminAPIKey("support", []string{"users:read"}, nil)
+	require.NotEqual(t, key, other)
+}
+
+func TestAdminAPIKeyIsActive(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Minute)
+
+	k, _ := NewAdminAPIKey("support", []string{"users:read"}, nil)
+	require.True(t, k.IsActive(now))
+
+	k.ExpiresAt = &future
+	require.True(t, k.IsActive(now))
+
+	k.ExpiresAt = &past
+	require.False(t, k.IsActive(now))
+
+	k.ExpiresAt = nil
+	k.RevokedAt = &past
+	require.False(t, k.IsActive(now))
+}
+
+func TestAdminAPIKeyPermissionsValue(t *testing.T) {
+	var permissions AdminAPIKeyPermissions
+
+	value, err := permissions.Value()
+	require.NoError(t, err)
+	require.Equal(t, "[]", value)
+
+	require.NoError(t, permissions.Scan([]byte(`["users:read","audit:read"]`)))
+	require.Equal(t, AdminAPIKeyPermissions{"users:read", "audit:read"}, permissions)
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// AudienceConfig is the configuration overlay of an audience managed in the
+// database. It takes precedence over the overlay of the audience in
+// GOTRUE_AUDIENCES_FILE.
+type AudienceConfig struct {
+	ID     uuid.UUID `json:"id" db:"id"`
+	Aud    string    `json:"aud" db:"aud"`
+	Config JSONMap   `json:"config" db:"config"`
+
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+func (AudienceConfig) TableName() string {
+	return "audience_configs"
+}
+
+// NewAudienceConfig returns a new overlay of the audience.
+func NewAudienceConfig(aud string, config map[string]interface{}) *AudienceConfig {
+	return &AudienceConfig{
+		ID:     uuid.Must(uuid.NewV4()),
+		Aud:    aud,
+		Config: config,
+	}
+}
+
+// FindAudienceConfigByAud finds the overlay of the audience.
+func FindAudienceConfigByAud(tx *storage.Connection, aud string) (*AudienceConfig, error) {
+	var c AudienceConfig
+
+	if err := tx.Q().Where("aud = ?", aud).First(&c); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, AudienceConfigNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error finding audience config")
+	}
+
+	return &c, nil
+}
+
+// FindAudienceConfigs finds the overlays of all audiences, by audience.
+func FindAudienceConfigs(tx *storage.Connection) ([]*AudienceConfig, error) {
+	configs := []*AudienceConfig{}
+
+	if err := tx.Q().Order("aud asc").All(&configs); err != nil && errors.Cause(err) != sql.ErrNoRows {
+		return nil, errors.Wrap(err, "error finding audience configs")
+	}
+
+	return configs, nil
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"bytes"
+	"database/sql"
 	"fmt"
 	"net/http"
 	"time"
@@ -23,12 +24,18 @@ const (
 	UserSignedUpAction              AuditAction = "user_signedup"
 	UserInvitedAction               AuditAction = "user_invited"
 	UserDeletedAction               AuditAction = "user_deleted"
+	UserImportStartedAction         AuditAction = "user_import_started"
+	UsersExportedAction             AuditAction = "users_exported"
 	UserModifiedAction              AuditAction = "user_modified"
 	UserRecoveryRequestedAction     AuditAction = "user_recovery_requested"
 	UserReauthenticateAction        AuditAction = "user_reauthenticate_requested"
 	UserConfirmationRequestedAction AuditAction = "user_confirmation_requested"
 	UserRepeatedSignUpAction        AuditAction = "user_repeated_signup"
 	UserUpdatePasswordAction        AuditAction = "user_updated_password"
+	UserLockedAction                AuditAction = "user_locked"
+	UserUnlockedAction              AuditAction = "user_unlocked"
+	SignInRevokedAction             AuditAction = "sign_in_revoked"
+	UserMergedAction                AuditAction = "user_merged"
 	TokenRevokedAction              AuditAction = "token_revoked"
 	TokenRefreshedAction            AuditAction = "token_refreshed"
 	GenerateRecoveryCodesAction     AuditAction = "generate_recovery_codes"
@@ -41,6 +48,17 @@ const (
 	UpdateFactorAction              AuditAction = "factor_updated"
 	MFACodeLoginAction              AuditAction = "mfa_code_login"
 	IdentityUnlinkAction            AuditAction = "identity_unlinked"
+	OrganizationMemberAddedAction   AuditAction = "organization_member_added"
+	OrganizationMemberRemovedAction AuditAction = "organization_member_removed"
+	AdminAPIKeyCreatedAction        AuditAction = "admin_api_key_created"
+	AdminAPIKeyRotatedAction        AuditAction = "admin_api_key_rotated"
+	AdminAPIKeyRevokedAction        AuditAction = "admin_api_key_revoked"
+	AdminAPIKeyUsedAction           AuditAction = "admin_api_key_used"
+	AudienceConfigUpdatedAction     AuditAction = "audience_config_updated"
+	AudienceConfigDeletedAction     AuditAction = "audience_config_deleted"
+	UserDeletionRequestedAction     AuditAction = "user_deletion_requested"
+	UserDeletionCancelledAction     AuditAction = "user_deletion_cancelled"
+	UserDataExportedAction          AuditAction = "user_data_exported"
 
 	account       auditLogType = "account"
 	team          auditLogType = "team"
@@ -48,6 +66,7 @@ const (
 	user          auditLogType = "user"
 	factor        auditLogType = "factor"
 	recoveryCodes auditLogType = "recovery_codes"
+	adminAPIKey   auditLogType = "admin_api_key"
 )
 
 var ActionLogTypeMap = map[AuditAction]auditLogType{
@@ -56,7 +75,13 @@ var ActionLogTypeMap = map[AuditAction]auditLogType{
 	InviteAcceptedAction:            account,
 	UserSignedUpAction:              team,
 	UserInvitedAction:               team,
+	OrganizationMemberAddedAction:   team,
+	OrganizationMemberRemovedAction: team,
 	UserDeletedAction:               team,
+	UserImportStartedAction:         team,
+	UsersExportedAction:             team,
+	AudienceConfigUpdatedAction:     team,
+	AudienceConfigDeletedAction:     team,
 	TokenRevokedAction:              token,
 	TokenRefreshedAction:            token,
 	UserModifiedAction:              user,
@@ -64,6 +89,13 @@ var ActionLogTypeMap = map[AuditAction]auditLogType{
 	UserConfirmationRequestedAction: user,
 	UserRepeatedSignUpAction:        user,
 	UserUpdatePasswordAction:        user,
+	UserLockedAction:                user,
+	UserUnlockedAction:              user,
+	SignInRevokedAction:             user,
+	UserMergedAction:                user,
+	UserDeletionRequestedAction:     user,
+	UserDeletionCancelledAction:     user,
+	UserDataExportedAction:          user,
 	GenerateRecoveryCodesAction:     user,
 	EnrollFactorAction:              factor,
 	UnenrollFactorAction:            factor,
@@ -73,6 +105,10 @@ var ActionLogTypeMap = map[AuditAction]auditLogType{
 	UpdateFactorAction:              factor,
 	MFACodeLoginAction:              factor,
 	DeleteRecoveryCodesAction:       recoveryCodes,
+	AdminAPIKeyCreatedAction:        adminAPIKey,
+	AdminAPIKeyRotatedAction:        adminAPIKey,
+	AdminAPIKeyRevokedAction:        adminAPIKey,
+	AdminAPIKeyUsedAction:           adminAPIKey,
 }
 
 // AuditLogEntry is the database model for audit log entries.
@@ -112,9 +148,12 @@ func NewAuditLogEntry(r *http.Request, tx *storage.Connection, actor *User, acti
 		IPAddress: ipAddress,
 	}
 
-	observability.LogEntrySetFields(r, logrus.Fields{
-		"auth_event": logrus.Fields(payload),
-	})
+	// entries of background workers aren't recorded for a request
+	if r != nil {
+		observability.LogEntrySetFields(r, logrus.Fields{
+			"auth_event": logrus.Fields(payload),
+		})
+	}
 
 	if name, ok := actor.UserMetaData["full_name"]; ok {
 		l.Payload["actor_name"] = name
@@ -131,6 +170,19 @@ func NewAuditLogEntry(r *http.Request, tx *storage.Connection, actor *User, acti
 	return nil
 }
 
+// FindAuditLogEntriesByUserID finds the audit log entries of the actions of
+// the user and of the actions performed on the user, such as by an admin,
+// oldest first.
+func FindAuditLogEntriesByUserID(tx *storage.Connection, userID uuid.UUID) ([]*AuditLogEntry, error) {
+	logs := []*AuditLogEntry{}
+
+	if err := tx.Q().Where("instance_id = ? and (payload->>'actor_id' = ? or payload->'traits'->>'user_id' = ?)", uuid.Nil, userID.String(), userID.String()).Order("created_at asc").All(&logs); err != nil && errors.Cause(err) != sql.ErrNoRows {
+		return nil, errors.Wrap(err, "error finding audit log entries")
+	}
+
+	return logs, nil
+}
+
 func FindAuditLogEntries(tx *storage.Connection, filterColumns []string, filterValue string, pageParams *Pagination) ([]*AuditLogEntry, error) {
 	q := tx.Q().Order("created_at desc").Where("instance_id = ?", uuid.Nil)
 
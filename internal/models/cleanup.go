@@ -40,6 +40,9 @@ func NewCleanup(config *conf.GlobalConfiguration) *Cleanup {
 	tableFlowStates := FlowState{}.TableName()
 	tableMFAChallenges := Challenge{}.TableName()
 	tableMFAFactors := Factor{}.TableName()
+	tableWeb3Nonces := Web3Nonce{}.TableName()
+	tableSAMLSessions := SAMLSession{}.TableName()
+	tableUserImportJobs := UserImportJob{}.TableName()
 
 	c := &Cleanup{}
 
@@ -58,15 +61,33 @@ func NewCleanup(config *conf.GlobalConfiguration) *Cleanup {
 		fmt.Sprintf("delete from %q where id in (select id from %q where created_at < now() - interval '24 hours' limit 100 for update skip locked);", tableFlowStates, tableFlowStates),
 		fmt.Sprintf("delete from %q where id in (select id from %q where created_at < now() - interval '24 hours' limit 100 for update skip locked);", tableMFAChallenges, tableMFAChallenges),
 		fmt.Sprintf("delete from %q where id in (select id from %q where created_at < now() - interval '24 hours' and status = 'unverified' limit 100 for update skip locked);", tableMFAFactors, tableMFAFactors),
+		// web3 nonces are kept until they expire to prevent replays
+		fmt.Sprintf("delete from %q where id in (select id from %q where expires_at < now() limit 100 for update skip locked);", tableWeb3Nonces, tableWeb3Nonces),
+		// SAML sessions of PKCE flows whose auth code was never exchanged
+		fmt.Sprintf("delete from %q where id in (select id from %q where session_id is null and created_at < now() - interval '24 hours' limit 100 for update skip locked);", tableSAMLSessions, tableSAMLSessions),
+		// user imports are kept for a week after completing so that
+		// their failed rows can be inspected, and uploads that were
+		// interrupted are never processed; 10 at once so that cascades
+		// don't overwork the database
+		fmt.Sprintf("delete from %q where id in (select id from %q where (status = 'completed' and completed_at < now() - interval '7 days') or (status = 'uploading' and created_at < now() - interval '24 hours') limit 10 for update skip locked);", tableUserImportJobs, tableUserImportJobs),
 	)
 
-	if config.External.AnonymousUsers.Enabled {
-		// delete anonymous users older than 30 days
+	if config.External.AnonymousUsers.Enabled && config.External.AnonymousUsers.CleanupAfterDays > 0 {
+		// delete stale anonymous users, ones that weren't merged into
+		// another user or converted to a permanent user
 		c.cleanupStatements = append(c.cleanupStatements,
-			fmt.Sprintf("delete from %q where id in (select id from %q where created_at < now() - interval '30 days' and is_anonymous is true limit 100 for update skip locked);", tableUsers, tableUsers),
+			fmt.Sprintf("delete from %q where id in (select id from %q where created_at < now() - interval '%d days' and is_anonymous is true limit 100 for update skip locked);", tableUsers, tableUsers, config.External.AnonymousUsers.CleanupAfterDays),
 		)
 	}
 
+	if config.Security.Lockout.Enabled || config.Security.Risk.Enabled {
+		tableFailedAuthAttempts := FailedAuthAttempt{}.TableName()
+		windowSeconds := int(max(config.Security.Lockout.Window, config.Security.Risk.VelocityWindow).Seconds())
+
+		// failed attempts outside of the window no longer count
+		c.cleanupStatements = append(c.cleanupStatements, fmt.Sprintf("delete from %q where id in (select id from %q where created_at < now() - interval '%d seconds' limit 100 for update skip locked);", tableFailedAuthAttempts, tableFailedAuthAttempts, windowSeconds))
+	}
+
 	if config.Sessions.Timebox != nil {
 		timeboxSeconds := int((*config.Sessions.Timebox).Seconds())
 
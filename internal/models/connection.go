@@ -46,9 +46,23 @@ func TruncateAll(conn *storage.Connection) error {
 			(&pop.Model{Value: SSOProvider{}}).TableName(),
 			(&pop.Model{Value: SSODomain{}}).TableName(),
 			(&pop.Model{Value: SAMLProvider{}}).TableName(),
+			(&pop.Model{Value: OIDCProvider{}}).TableName(),
 			(&pop.Model{Value: SAMLRelayState{}}).TableName(),
+			(&pop.Model{Value: SAMLSession{}}).TableName(),
 			(&pop.Model{Value: FlowState{}}).TableName(),
 			(&pop.Model{Value: OneTimeToken{}}).TableName(),
+			(&pop.Model{Value: PasswordHistory{}}).TableName(),
+			(&pop.Model{Value: Web3Nonce{}}).TableName(),
+			(&pop.Model{Value: SCIMGroup{}}).TableName(),
+			(&pop.Model{Value: SCIMGroupMember{}}).TableName(),
+			(&pop.Model{Value: Organization{}}).TableName(),
+			(&pop.Model{Value: OrganizationMember{}}).TableName(),
+			(&pop.Model{Value: AdminAPIKey{}}).TableName(),
+			(&pop.Model{Value: FailedAuthAttempt{}}).TableName(),
+			(&pop.Model{Value: SignInEvent{}}).TableName(),
+			(&pop.Model{Value: UserImportRow{}}).TableName(),
+			(&pop.Model{Value: UserImportJob{}}).TableName(),
+			(&pop.Model{Value: AudienceConfig{}}).TableName(),
 		}
 
 		for _, tableName := range tables {
@@ -23,10 +23,28 @@ func IsNotFoundError(err error) bool {
 		return true
 	case SAMLRelayStateNotFoundError, *SAMLRelayStateNotFoundError:
 		return true
+	case SAMLSessionNotFoundError, *SAMLSessionNotFoundError:
+		return true
 	case FlowStateNotFoundError, *FlowStateNotFoundError:
 		return true
 	case OneTimeTokenNotFoundError, *OneTimeTokenNotFoundError:
 		return true
+	case MailTemplateNotFoundError, *MailTemplateNotFoundError:
+		return true
+	case Web3NonceNotFoundError, *Web3NonceNotFoundError:
+		return true
+	case SCIMGroupNotFoundError, *SCIMGroupNotFoundError:
+		return true
+	case OrganizationNotFoundError, *OrganizationNotFoundError:
+		return true
+	case OrganizationMemberNotFoundError, *OrganizationMemberNotFoundError:
+		return true
+	case AdminAPIKeyNotFoundError, *AdminAPIKeyNotFoundError:
+		return true
+	case UserImportJobNotFoundError, *UserImportJobNotFoundError:
+		return true
+	case AudienceConfigNotFoundError, *AudienceConfigNotFoundError:
+		return true
 	}
 	return false
 }
@@ -102,6 +120,14 @@ func (e SAMLRelayStateNotFoundError) Error() string {
 	return "SAML RelayState not found"
 }
 
+// SAMLSessionNotFoundError represents an error when a SAML session can't be
+// found.
+type SAMLSessionNotFoundError struct{}
+
+func (e SAMLSessionNotFoundError) Error() string {
+	return "SAML session not found"
+}
+
 // FlowStateNotFoundError represents an error when an FlowState can't be
 // found.
 type FlowStateNotFoundError struct{}
@@ -110,6 +136,66 @@ func (e FlowStateNotFoundError) Error() string {
 	return "Flow State not found"
 }
 
+// MailTemplateNotFoundError represents an error when a mail template can't be
+// found.
+type MailTemplateNotFoundError struct{}
+
+func (e MailTemplateNotFoundError) Error() string {
+	return "Mail template not found"
+}
+
+// Web3NonceNotFoundError represents an error when a web3 nonce can't be found.
+type Web3NonceNotFoundError struct{}
+
+func (e Web3NonceNotFoundError) Error() string {
+	return "Web3 nonce not found"
+}
+
+// SCIMGroupNotFoundError represents an error when a SCIM group can't be found.
+type SCIMGroupNotFoundError struct{}
+
+func (e SCIMGroupNotFoundError) Error() string {
+	return "SCIM group not found"
+}
+
+// OrganizationNotFoundError represents an error when an organization can't be
+// found.
+type OrganizationNotFoundError struct{}
+
+func (e OrganizationNotFoundError) Error() string {
+	return "Organization not found"
+}
+
+// OrganizationMemberNotFoundError represents an error when a user is not a
+// member of an organization.
+type OrganizationMemberNotFoundError struct{}
+
+func (e OrganizationMemberNotFoundError) Error() string {
+	return "Organization member not found"
+}
+
+// AdminAPIKeyNotFoundError represents when an admin API key is not found.
+type AdminAPIKeyNotFoundError struct{}
+
+func (e AdminAPIKeyNotFoundError) Error() string {
+	return "Admin API key not found"
+}
+
+// UserImportJobNotFoundError represents when a user import job is not found.
+type UserImportJobNotFoundError struct{}
+
+func (e UserImportJobNotFoundError) Error() string {
+	return "User import job not found"
+}
+
+// AudienceConfigNotFoundError represents when the configuration overlay of an
+// audience is not found.
+type AudienceConfigNotFoundError struct{}
+
+func (e AudienceConfigNotFoundError) Error() string {
+	return "Audience config not found"
+}
+
 func IsUniqueConstraintViolatedError(err error) bool {
 	switch err.(type) {
 	case UserEmailUniqueConflictError, *UserEmailUniqueConflictError:
@@ -55,6 +55,7 @@ const (
 	TokenRefresh
 	Anonymous
 	Web3
+	SSOOIDC
 )
 
 func (authMethod AuthenticationMethod) String() string {
@@ -89,6 +90,8 @@ func (authMethod AuthenticationMethod) String() string {
 		return "mfa/webauthn"
 	case Web3:
 		return "web3"
+	case SSOOIDC:
+		return "sso/oidc"
 	}
 	return ""
 }
@@ -126,6 +129,8 @@ func ParseAuthenticationMethod(authMethod string) (AuthenticationMethod, error)
 		return MFAWebAuthn, nil
 	case "web3":
 		return Web3, nil
+	case "sso/oidc":
+		return SSOOIDC, nil
 
 	}
 	return 0, fmt.Errorf("unsupported authentication method %q", authMethod)
@@ -0,0 +1,143 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// FailedAuthAttemptKind is what a failed attempt tried to verify. Attempts
+// of each kind are counted separately.
+type FailedAuthAttemptKind string
+
+const (
+	FailedPasswordAttempt FailedAuthAttemptKind = "password"
+	FailedMFAAttempt      FailedAuthAttemptKind = "mfa"
+)
+
+// FailedAuthAttempt is a failed attempt to sign in with a password or to
+// verify an MFA factor, used to lock accounts against guessing. Attempts to
+// sign in to accounts that don't exist have an Identifier instead of a
+// UserID, so that they're locked the same way.
+type FailedAuthAttempt struct {
+	ID         uuid.UUID             `json:"id" db:"id"`
+	UserID     *uuid.UUID            `json:"user_id" db:"user_id"`
+	Identifier *string               `json:"-" db:"identifier"`
+	Kind       FailedAuthAttemptKind `json:"kind" db:"kind"`
+	IPAddress  string                `json:"ip_address" db:"ip_address"`
+	CreatedAt  time.Time             `json:"created_at" db:"created_at"`
+}
+
+func (FailedAuthAttempt) TableName() string {
+	tableName := "failed_auth_attempts"
+	return tableName
+}
+
+// AddFailedAuthAttempt records a failed attempt of the user.
+func AddFailedAuthAttempt(tx *storage.Connection, userID uuid.UUID, kind FailedAuthAttemptKind, ipAddress string) error {
+	attempt := &FailedAuthAttempt{
+		ID:        uuid.Must(uuid.NewV4()),
+		UserID:    &userID,
+		Kind:      kind,
+		IPAddress: ipAddress,
+	}
+
+	if err := tx.Create(attempt); err != nil {
+		return errors.Wrap(err, "error creating failed auth attempt")
+	}
+
+	return nil
+}
+
+// AddFailedIdentifierAttempt records a failed attempt for an identifier
+// without an account.
+func AddFailedIdentifierAttempt(tx *storage.Connection, identifier string, kind FailedAuthAttemptKind, ipAddress string) error {
+	attempt := &FailedAuthAttempt{
+		ID:         uuid.Must(uuid.NewV4()),
+		Identifier: &identifier,
+		Kind:       kind,
+		IPAddress:  ipAddress,
+	}
+
+	if err := tx.Create(attempt); err != nil {
+		return errors.Wrap(err, "error creating failed auth attempt")
+	}
+
+	return nil
+}
+
+// LockFailedAuthAttempts locks the user until the end of the transaction, so
+// that concurrent attempts of the user are checked against the lockout and
+// recorded one at a time.
+func LockFailedAuthAttempts(tx *storage.Connection, userID uuid.UUID) error {
+	if err := tx.RawQuery("select id from "+(&User{}).TableName()+" where id = ? for update", userID).Exec(); err != nil {
+		return errors.Wrap(err, "error locking user")
+	}
+
+	return nil
+}
+
+// LockFailedIdentifierAttempts locks the identifier until the end of the
+// transaction, like LockFailedAuthAttempts.
+func LockFailedIdentifierAttempts(tx *storage.Connection, identifier string) error {
+	if err := tx.RawQuery("select pg_advisory_xact_lock(hashtext(?))", "failed_auth_attempts:"+identifier).Exec(); err != nil {
+		return errors.Wrap(err, "error locking failed auth attempts")
+	}
+
+	return nil
+}
+
+// FindFailedAuthAttempts returns the failed attempts of the user since the
+// given time, most recent first.
+func FindFailedAuthAttempts(tx *storage.Connection, userID uuid.UUID, kind FailedAuthAttemptKind, since time.Time) ([]*FailedAuthAttempt, error) {
+	attempts := []*FailedAuthAttempt{}
+
+	if err := tx.Q().Where("user_id = ? and kind = ? and created_at > ?", userID, kind, since).Order("created_at desc").All(&attempts); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return attempts, nil
+		}
+
+		return nil, errors.Wrap(err, "error finding failed auth attempts")
+	}
+
+	return attempts, nil
+}
+
+// FindFailedIdentifierAttempts returns the failed attempts for the
+// identifier since the given time, most recent first.
+func FindFailedIdentifierAttempts(tx *storage.Connection, identifier string, kind FailedAuthAttemptKind, since time.Time) ([]*FailedAuthAttempt, error) {
+	attempts := []*FailedAuthAttempt{}
+
+	if err := tx.Q().Where("identifier = ? and kind = ? and created_at > ?", identifier, kind, since).Order("created_at desc").All(&attempts); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return attempts, nil
+		}
+
+		return nil, errors.Wrap(err, "error finding failed auth attempts")
+	}
+
+	return attempts, nil
+}
+
+// ClearFailedAuthAttempts deletes the failed attempts of the given kinds of
+// the user, unlocking it. It returns the number of deleted attempts.
+func ClearFailedAuthAttempts(tx *storage.Connection, userID uuid.UUID, kinds ...FailedAuthAttemptKind) (int, error) {
+	cleared := 0
+
+	for _, kind := range kinds {
+		count, err := tx.RawQuery(
+			"delete from "+(&FailedAuthAttempt{}).TableName()+" where user_id = ? and kind = ?",
+			userID, kind,
+		).ExecWithCount()
+		if err != nil {
+			return cleared, errors.Wrap(err, "error clearing failed auth attempts")
+		}
+
+		cleared += count
+	}
+
+	return cleared, nil
+}
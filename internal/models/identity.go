@@ -86,6 +86,19 @@ func FindIdentityByIdAndProvider(tx *storage.Connection, providerId, provider st
 	return identity, nil
 }
 
+// FindIdentitiesByIdAndProviders searches for identities with the matching id
+// in any of the providers given.
+func FindIdentitiesByIdAndProviders(tx *storage.Connection, providerId string, providers []string) ([]*Identity, error) {
+	identities := []*Identity{}
+	if err := tx.Q().Where("provider_id = ? AND provider = any (?)", providerId, providers).All(&identities); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return identities, nil
+		}
+		return nil, errors.Wrap(err, "error finding identities")
+	}
+	return identities, nil
+}
+
 // FindIdentitiesByUserID returns all identities associated to a user ID.
 func FindIdentitiesByUserID(tx *storage.Connection, userID uuid.UUID) ([]*Identity, error) {
 	identities := []*Identity{}
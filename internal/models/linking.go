@@ -88,6 +88,32 @@ func DetermineAccountLinking(tx *storage.Connection, config *conf.GlobalConfigur
 	// the identity does not exist, so we need to check if we should create a new account
 	// or link to an existing one
 
+	// providers sharing the subject namespace, such as WeChat apps bound to
+	// the same WeChat Open Platform account, identify the same user with the
+	// same subject
+	if unionProviders := provider.UnionIDProviders(providerName); len(unionProviders) > 0 {
+		identities, terr := FindIdentitiesByIdAndProviders(tx, sub, unionProviders)
+		if terr != nil {
+			return AccountLinkingResult{}, terr
+		}
+
+		if len(identities) > 0 {
+			user, terr := FindUserByID(tx, identities[0].UserID)
+			if terr != nil {
+				return AccountLinkingResult{}, terr
+			}
+
+			candidateEmail.Email = user.GetEmail()
+			return AccountLinkingResult{
+				Decision:       LinkAccount,
+				User:           user,
+				Identities:     identities,
+				LinkingDomain:  GetAccountLinkingDomain(providerName),
+				CandidateEmail: candidateEmail,
+			}, nil
+		}
+	}
+
 	// this is the linking domain for the new identity
 	candidateLinkingDomain := GetAccountLinkingDomain(providerName)
 	if len(verifiedEmails) == 0 {
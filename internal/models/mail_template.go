@@ -0,0 +1,41 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// MailTemplate is a mail template managed in the database. It takes
+// precedence over template files and template URLs for the same type.
+type MailTemplate struct {
+	Type        string  `json:"type" db:"type"`
+	Subject     *string `json:"subject,omitempty" db:"subject"`
+	ContentHTML string  `json:"content_html" db:"content_html"`
+	ContentText *string `json:"content_text,omitempty" db:"content_text"`
+
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+func (MailTemplate) TableName() string {
+	return "mail_templates"
+}
+
+// FindMailTemplateByType finds the mail template for the mail type, such as
+// invite, confirm or recovery.
+func FindMailTemplateByType(tx *storage.Connection, typ string) (*MailTemplate, error) {
+	var template MailTemplate
+
+	if err := tx.Q().Where("type = ?", typ).First(&template); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, MailTemplateNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error finding mail template")
+	}
+
+	return &template, nil
+}
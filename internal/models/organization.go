@@ -0,0 +1,222 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// Roles of organization members.
+const (
+	OrganizationRoleOwner  = "owner"
+	OrganizationRoleAdmin  = "admin"
+	OrganizationRoleMember = "member"
+)
+
+// IsValidOrganizationRole reports whether the role is one of the roles of
+// organization members.
+func IsValidOrganizationRole(role string) bool {
+	switch role {
+	case OrganizationRoleOwner, OrganizationRoleAdmin, OrganizationRoleMember:
+		return true
+	}
+
+	return false
+}
+
+// Organization groups users, such as the employees of a customer. Sessions
+// can select one of the user's organizations, which is then added to the
+// access token.
+type Organization struct {
+	ID       uuid.UUID `json:"id" db:"id"`
+	Name     string    `json:"name" db:"name"`
+	Slug     string    `json:"slug" db:"slug"`
+	Metadata JSONMap   `json:"metadata" db:"metadata"`
+
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+func (Organization) TableName() string {
+	tableName := "organizations"
+	return tableName
+}
+
+func NewOrganization(name, slug string, metadata map[string]interface{}) *Organization {
+	return &Organization{
+		ID:       uuid.Must(uuid.NewV4()),
+		Name:     name,
+		Slug:     slug,
+		Metadata: metadata,
+	}
+}
+
+// OrganizationMember is a user's membership of an organization.
+type OrganizationMember struct {
+	ID             uuid.UUID `json:"id" db:"id"`
+	OrganizationID uuid.UUID `json:"organization_id" db:"organization_id"`
+	UserID         uuid.UUID `json:"user_id" db:"user_id"`
+	Role           string    `json:"role" db:"role"`
+
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+func (OrganizationMember) TableName() string {
+	tableName := "organization_members"
+	return tableName
+}
+
+func NewOrganizationMember(organizationID, userID uuid.UUID, role string) *OrganizationMember {
+	return &OrganizationMember{
+		ID:             uuid.Must(uuid.NewV4()),
+		OrganizationID: organizationID,
+		UserID:         userID,
+		Role:           role,
+	}
+}
+
+// UpdateRole changes the member's role.
+func (m *OrganizationMember) UpdateRole(tx *storage.Connection, role string) error {
+	m.Role = role
+	return tx.UpdateOnly(m, "role", "updated_at")
+}
+
+// UserOrganization is an organization the user is a member of, along with
+// the user's role.
+type UserOrganization struct {
+	Organization
+	Role string `json:"role" db:"role"`
+}
+
+func FindOrganizationByID(tx *storage.Connection, id uuid.UUID) (*Organization, error) {
+	var organization Organization
+
+	if err := tx.Q().Where("id = ?", id).First(&organization); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, OrganizationNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error finding organization")
+	}
+
+	return &organization, nil
+}
+
+func FindOrganizationBySlug(tx *storage.Connection, slug string) (*Organization, error) {
+	var organization Organization
+
+	if err := tx.Q().Where("slug = ?", slug).First(&organization); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, OrganizationNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error finding organization by slug")
+	}
+
+	return &organization, nil
+}
+
+// FindOrganizations finds all organizations by name, a page at a time if
+// pageParams is set.
+func FindOrganizations(tx *storage.Connection, pageParams *Pagination) ([]*Organization, error) {
+	organizations := []*Organization{}
+	q := tx.Q().Order("name asc, id asc")
+
+	var err error
+	if pageParams != nil {
+		err = q.Paginate(int(pageParams.Page), int(pageParams.PerPage)).All(&organizations) // #nosec G115
+		pageParams.Count = uint64(q.Paginator.TotalEntriesSize)                             // #nosec G115
+	} else {
+		err = q.All(&organizations)
+	}
+
+	if err != nil && errors.Cause(err) != sql.ErrNoRows {
+		return nil, errors.Wrap(err, "error finding organizations")
+	}
+
+	return organizations, nil
+}
+
+// FindOrganizationMembership finds the user's membership of the
+// organization.
+func FindOrganizationMembership(tx *storage.Connection, organizationID, userID uuid.UUID) (*OrganizationMember, error) {
+	var member OrganizationMember
+
+	if err := tx.Q().Where("organization_id = ? and user_id = ?", organizationID, userID).First(&member); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, OrganizationMemberNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error finding organization member")
+	}
+
+	return &member, nil
+}
+
+// FindOrganizationMembers finds the members of the organization, in the
+// order they joined.
+func FindOrganizationMembers(tx *storage.Connection, organizationID uuid.UUID) ([]*OrganizationMember, error) {
+	members := []*OrganizationMember{}
+
+	if err := tx.Q().Where("organization_id = ?", organizationID).Order("created_at asc, id asc").All(&members); err != nil && errors.Cause(err) != sql.ErrNoRows {
+		return nil, errors.Wrap(err, "error finding organization members")
+	}
+
+	return members, nil
+}
+
+// FindUserOrganizations finds the organizations the user is a member of, by
+// name.
+func FindUserOrganizations(tx *storage.Connection, userID uuid.UUID) ([]*UserOrganization, error) {
+	organizations := []*UserOrganization{}
+
+	if err := tx.RawQuery(
+		"select o.*, m.role from "+(&pop.Model{Value: Organization{}}).TableName()+" o join "+(&pop.Model{Value: OrganizationMember{}}).TableName()+" m on m.organization_id = o.id where m.user_id = ? order by o.name asc, o.id asc",
+		userID,
+	).All(&organizations); err != nil && errors.Cause(err) != sql.ErrNoRows {
+		return nil, errors.Wrap(err, "error finding organizations of user")
+	}
+
+	return organizations, nil
+}
+
+// AddOrganizationMember makes the user a member of the organization with the
+// role, unless the user is a member already. It returns the membership
+// either way.
+func AddOrganizationMember(tx *storage.Connection, organizationID, userID uuid.UUID, role string) (*OrganizationMember, error) {
+	member, err := FindOrganizationMembership(tx, organizationID, userID)
+	if err == nil {
+		return member, nil
+	} else if !IsNotFoundError(err) {
+		return nil, err
+	}
+
+	member = NewOrganizationMember(organizationID, userID, role)
+	if err := tx.Create(member); err != nil {
+		return nil, errors.Wrap(err, "error adding organization member")
+	}
+
+	return member, nil
+}
+
+// RemoveOrganizationMember removes the membership and deselects the
+// organization from the user's sessions.
+func RemoveOrganizationMember(tx *storage.Connection, member *OrganizationMember) error {
+	if err := tx.Destroy(member); err != nil {
+		return errors.Wrap(err, "error removing organization member")
+	}
+
+	if err := tx.RawQuery(
+		"update "+(&pop.Model{Value: Session{}}).TableName()+" set organization_id = null where user_id = ? and organization_id = ?",
+		member.UserID, member.OrganizationID,
+	).Exec(); err != nil {
+		return errors.Wrap(err, "error deselecting organization from sessions")
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/supabase/auth/internal/storage"
+)
+
+// legacyPasswordHashesInterval is how long the number of legacy password
+// hashes is cached, as counting them requires a full scan of the users table.
+const legacyPasswordHashesInterval = 10 * time.Minute
+
+// RegisterLegacyPasswordHashesMetric registers an OpenTelemetry gauge with the
+// number of users whose password hash will be replaced on their next sign in,
+// to track the progress of a password hashing migration.
+func RegisterLegacyPasswordHashesMetric(db *storage.Connection) {
+	var (
+		mu        sync.Mutex
+		count     int64
+		countedAt time.Time
+	)
+
+	meter := otel.Meter("gotrue")
+
+	_, err := meter.Int64ObservableGauge(
+		"gotrue_legacy_password_hashes",
+		metric.WithDescription("Number of users with a password hash not using the current password hashing algorithm and parameters"),
+		metric.WithInt64Callback(func(ctx context.Context, o metric.Int64Observer) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			if time.Since(countedAt) >= legacyPasswordHashesInterval {
+				n, err := CountUsersWithLegacyPasswordHash(db.WithContext(ctx))
+				if err != nil {
+					return err
+				}
+
+				count = int64(n)
+				countedAt = time.Now()
+			}
+
+			o.Observe(count)
+			return nil
+		}),
+	)
+
+	if err != nil {
+		logrus.WithError(err).Error("unable to get gotrue.gotrue_legacy_password_hashes gauge metric")
+	}
+}
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/crypto"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// PasswordHistory is a previous password hash of a user. It is used to
+// prevent reusing recent passwords and to determine when the password was
+// last changed.
+type PasswordHistory struct {
+	ID                uuid.UUID `json:"id" db:"id"`
+	UserID            uuid.UUID `json:"user_id" db:"user_id"`
+	EncryptedPassword string    `json:"-" db:"encrypted_password"`
+	CreatedAt         time.Time `json:"created_at" db:"created_at"`
+}
+
+func (PasswordHistory) TableName() string {
+	tableName := "password_history"
+	return tableName
+}
+
+// AddPasswordHistory records the current password hash of the user, keeping
+// only the keep most recent entries.
+func AddPasswordHistory(tx *storage.Connection, user *User, keep int) error {
+	if user.EncryptedPassword == nil || *user.EncryptedPassword == "" {
+		return nil
+	}
+
+	entry := &PasswordHistory{
+		ID:                uuid.Must(uuid.NewV4()),
+		UserID:            user.ID,
+		EncryptedPassword: *user.EncryptedPassword,
+	}
+
+	if err := tx.Create(entry); err != nil {
+		return errors.Wrap(err, "error creating password history entry")
+	}
+
+	if keep < 1 {
+		keep = 1
+	}
+
+	table := (&PasswordHistory{}).TableName()
+
+	if err := tx.RawQuery(
+		"delete from "+table+" where user_id = ? and id not in (select id from "+table+" where user_id = ? order by created_at desc limit ?)",
+		user.ID, user.ID, keep,
+	).Exec(); err != nil {
+		return errors.Wrap(err, "error pruning password history")
+	}
+
+	return nil
+}
+
+// FindPasswordHistory returns the limit most recent password history entries
+// of the user, most recent first.
+func FindPasswordHistory(tx *storage.Connection, userID uuid.UUID, limit int) ([]*PasswordHistory, error) {
+	entries := []*PasswordHistory{}
+
+	if err := tx.Q().Where("user_id = ?", userID).Order("created_at desc").Limit(limit).All(&entries); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return entries, nil
+		}
+
+		return nil, errors.Wrap(err, "error finding password history")
+	}
+
+	return entries, nil
+}
+
+// IsReusedPassword reports whether password matches one of the count most
+// recent passwords of the user.
+func (u *User) IsReusedPassword(ctx context.Context, tx *storage.Connection, password string, decryptionKeys map[string]string, count int) (bool, error) {
+	entries, err := FindPasswordHistory(tx, u.ID, count)
+	if err != nil {
+		return false, err
+	}
+
+	for _, entry := range entries {
+		hash := entry.EncryptedPassword
+
+		if es := crypto.ParseEncryptedString(hash); es != nil {
+			h, err := es.Decrypt(u.ID.String(), decryptionKeys)
+			if err != nil {
+				return false, err
+			}
+
+			hash = string(h)
+		}
+
+		if crypto.CompareHashAndPassword(ctx, hash, password) == nil {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// StartPasswordAge records now as when the password was last changed, for
+// users whose password was set before it was recorded. The most recent
+// password history entry is used instead if there is one.
+func (u *User) StartPasswordAge(tx *storage.Connection, now time.Time) error {
+	entries, err := FindPasswordHistory(tx, u.ID, 1)
+	if err != nil {
+		return err
+	}
+
+	changedAt := now
+	if len(entries) > 0 {
+		changedAt = entries[0].CreatedAt
+	}
+	u.PasswordChangedAt = &changedAt
+
+	return tx.UpdateOnly(u, "password_changed_at")
+}
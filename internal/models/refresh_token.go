@@ -44,6 +44,12 @@ type GrantParams struct {
 	SessionNotAfter *time.Time
 	SessionTag      *string
 
+	OrganizationID *uuid.UUID
+
+	// RequireAAL2 marks the new session as requiring a step up to AAL2
+	// before it can be refreshed.
+	RequireAAL2 bool
+
 	UserAgent string
 	IP        string
 }
@@ -148,6 +154,9 @@ func createRefreshToken(tx *storage.Connection, user *User, oldToken *RefreshTok
 			session.Tag = params.SessionTag
 		}
 
+		session.OrganizationID = params.OrganizationID
+		session.RequiresAAL2 = params.RequireAAL2
+
 		if err := tx.Create(session); err != nil {
 			return nil, errors.Wrap(err, "error creating new session")
 		}
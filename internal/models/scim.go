@@ -0,0 +1,347 @@
+package models
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// SCIMExternalIDKey is the app metadata key under which the SCIM externalId
+// of a provisioned user is kept.
+const SCIMExternalIDKey = "scim_external_id"
+
+// SCIMDeactivatedKey is the app metadata key set on users deactivated by
+// SCIM, so that activating them only lifts bans set by SCIM.
+const SCIMDeactivatedKey = "scim_deactivated"
+
+// SCIM filter attributes, lower cased as SCIM attribute names are case
+// insensitive.
+const (
+	SCIMFilterUserName    = "username"
+	SCIMFilterExternalID  = "externalid"
+	SCIMFilterEmail       = "emails.value"
+	SCIMFilterDisplayName = "displayname"
+)
+
+// SCIMFilter is an `attribute eq "value"` SCIM filter, the only kind of
+// filter supported.
+type SCIMFilter struct {
+	Attribute string
+	Value     string
+}
+
+// HashSCIMToken hashes a SCIM bearer token. Only the hash is stored, so the
+// token is shown once when it's generated.
+func HashSCIMToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// SCIMProviderType is the identity provider of users provisioned by the SSO
+// provider, which is the same as the one of users signing in with it.
+func (p *SSOProvider) SCIMProviderType() string {
+	return "sso:" + p.ID.String()
+}
+
+// SetSCIMToken sets the bearer token SCIM clients of the provider
+// authenticate with. An empty token disables SCIM provisioning.
+func (p *SSOProvider) SetSCIMToken(tx *storage.Connection, token string) error {
+	if token == "" {
+		p.SCIMTokenHash = nil
+	} else {
+		hash := HashSCIMToken(token)
+		p.SCIMTokenHash = &hash
+	}
+
+	return tx.UpdateOnly(p, "scim_token_hash", "updated_at")
+}
+
+// FindSSOProviderBySCIMToken finds the SSO provider a SCIM bearer token was
+// generated for.
+func FindSSOProviderBySCIMToken(tx *storage.Connection, token string) (*SSOProvider, error) {
+	var ssoProvider SSOProvider
+
+	if err := tx.Eager().Q().Where("scim_token_hash = ?", HashSCIMToken(token)).First(&ssoProvider); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, SSOProviderNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error finding SSO provider by SCIM token")
+	}
+
+	return &ssoProvider, nil
+}
+
+// scimUsersQuery returns the where clause matching the users provisioned by
+// or signed in with the SSO provider, and those matching the filter.
+func scimUsersQuery(ssoProvider *SSOProvider, filter *SCIMFilter) (string, []interface{}, error) {
+	identityClause := "i.user_id = users.id and i.provider = ?"
+	identityArgs := []interface{}{ssoProvider.SCIMProviderType()}
+
+	clause := "users.deleted_at is null"
+	var args []interface{}
+
+	if filter != nil {
+		switch filter.Attribute {
+		case SCIMFilterUserName:
+			identityClause += " and lower(i.provider_id) = ?"
+			identityArgs = append(identityArgs, strings.ToLower(filter.Value))
+
+		case SCIMFilterExternalID:
+			clause += " and users.raw_app_meta_data->>'" + SCIMExternalIDKey + "' = ?"
+			args = append(args, filter.Value)
+
+		case SCIMFilterEmail:
+			clause += " and lower(users.email) = ?"
+			args = append(args, strings.ToLower(filter.Value))
+
+		default:
+			return "", nil, fmt.Errorf("unsupported SCIM filter attribute %q", filter.Attribute)
+		}
+	}
+
+	clause += " and exists (select 1 from " + (&pop.Model{Value: Identity{}}).TableName() + " i where " + identityClause + ")"
+
+	return clause, append(args, identityArgs...), nil
+}
+
+// FindSCIMUsers finds the users of the SSO provider matching the filter, if
+// any, ordered by creation. It returns a page of at most limit users starting
+// at offset, along with the total number of matching users.
+func FindSCIMUsers(tx *storage.Connection, ssoProvider *SSOProvider, filter *SCIMFilter, offset, limit int) ([]*User, int, error) {
+	clause, args, err := scimUsersQuery(ssoProvider, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := tx.Q().Where(clause, args...).Count(&User{})
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "error counting SCIM users")
+	}
+
+	users := []*User{}
+	if limit <= 0 || total <= offset {
+		return users, total, nil
+	}
+
+	query := "select * from " + (&pop.Model{Value: User{}}).TableName() + " users where " + clause + " order by users.created_at asc, users.id asc limit ? offset ?"
+	if err := tx.Eager("Identities").RawQuery(query, append(args, limit, offset)...).All(&users); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return users, total, nil
+		}
+
+		return nil, 0, errors.Wrap(err, "error finding SCIM users")
+	}
+
+	return users, total, nil
+}
+
+// FindSCIMUserByID finds a user of the SSO provider by ID.
+func FindSCIMUserByID(tx *storage.Connection, ssoProvider *SSOProvider, id uuid.UUID) (*User, error) {
+	clause, args, err := scimUsersQuery(ssoProvider, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var user User
+	if err := tx.Eager("Identities").Q().Where("users.id = ? and "+clause, append([]interface{}{id}, args...)...).First(&user); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, UserNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error finding SCIM user")
+	}
+
+	return &user, nil
+}
+
+// SCIMGroup is a group provisioned over SCIM by an SSO provider.
+type SCIMGroup struct {
+	ID            uuid.UUID `db:"id" json:"id"`
+	SSOProviderID uuid.UUID `db:"sso_provider_id" json:"sso_provider_id"`
+	ExternalID    *string   `db:"external_id" json:"external_id,omitempty"`
+	DisplayName   string    `db:"display_name" json:"display_name"`
+
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+func (g SCIMGroup) TableName() string {
+	return "scim_groups"
+}
+
+// SCIMGroupMember is a user's membership of a SCIM group.
+type SCIMGroupMember struct {
+	GroupID   uuid.UUID `db:"group_id" json:"group_id"`
+	UserID    uuid.UUID `db:"user_id" json:"user_id"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+func (m SCIMGroupMember) TableName() string {
+	return "scim_group_members"
+}
+
+func NewSCIMGroup(ssoProviderID uuid.UUID, displayName string, externalID *string) *SCIMGroup {
+	return &SCIMGroup{
+		ID:            uuid.Must(uuid.NewV4()),
+		SSOProviderID: ssoProviderID,
+		ExternalID:    externalID,
+		DisplayName:   displayName,
+	}
+}
+
+// FindSCIMGroupByID finds a group of the SSO provider by ID.
+func FindSCIMGroupByID(tx *storage.Connection, ssoProviderID, id uuid.UUID) (*SCIMGroup, error) {
+	var group SCIMGroup
+
+	if err := tx.Q().Where("id = ? and sso_provider_id = ?", id, ssoProviderID).First(&group); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, SCIMGroupNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error finding SCIM group")
+	}
+
+	return &group, nil
+}
+
+// FindSCIMGroups finds the groups of the SSO provider matching the filter,
+// if any, ordered by creation. It returns a page of at most limit groups
+// starting at offset, along with the total number of matching groups.
+func FindSCIMGroups(tx *storage.Connection, ssoProviderID uuid.UUID, filter *SCIMFilter, offset, limit int) ([]*SCIMGroup, int, error) {
+	clause := "sso_provider_id = ?"
+	args := []interface{}{ssoProviderID}
+
+	if filter != nil {
+		switch filter.Attribute {
+		case SCIMFilterDisplayName:
+			clause += " and lower(display_name) = ?"
+			args = append(args, strings.ToLower(filter.Value))
+
+		case SCIMFilterExternalID:
+			clause += " and external_id = ?"
+			args = append(args, filter.Value)
+
+		default:
+			return nil, 0, fmt.Errorf("unsupported SCIM filter attribute %q", filter.Attribute)
+		}
+	}
+
+	total, err := tx.Q().Where(clause, args...).Count(&SCIMGroup{})
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "error counting SCIM groups")
+	}
+
+	groups := []*SCIMGroup{}
+	if limit <= 0 || total <= offset {
+		return groups, total, nil
+	}
+
+	query := "select * from " + (&pop.Model{Value: SCIMGroup{}}).TableName() + " where " + clause + " order by created_at asc, id asc limit ? offset ?"
+	if err := tx.RawQuery(query, append(args, limit, offset)...).All(&groups); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return groups, total, nil
+		}
+
+		return nil, 0, errors.Wrap(err, "error finding SCIM groups")
+	}
+
+	return groups, total, nil
+}
+
+// FindSCIMGroupsForUser finds the groups of the SSO provider the user is a
+// member of.
+func FindSCIMGroupsForUser(tx *storage.Connection, ssoProviderID, userID uuid.UUID) ([]*SCIMGroup, error) {
+	groups := []*SCIMGroup{}
+
+	query := "select g.* from " + (&pop.Model{Value: SCIMGroup{}}).TableName() + " g join " + (&pop.Model{Value: SCIMGroupMember{}}).TableName() + " m on m.group_id = g.id where g.sso_provider_id = ? and m.user_id = ? order by g.created_at asc, g.id asc"
+	if err := tx.RawQuery(query, ssoProviderID, userID).All(&groups); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return groups, nil
+		}
+
+		return nil, errors.Wrap(err, "error finding SCIM groups of user")
+	}
+
+	return groups, nil
+}
+
+// MemberIDs returns the IDs of the group's members, in the order they were
+// added.
+func (g *SCIMGroup) MemberIDs(tx *storage.Connection) ([]uuid.UUID, error) {
+	members := []SCIMGroupMember{}
+
+	query := "select * from " + (&pop.Model{Value: SCIMGroupMember{}}).TableName() + " where group_id = ? order by created_at asc, user_id asc"
+	if err := tx.RawQuery(query, g.ID).All(&members); err != nil && errors.Cause(err) != sql.ErrNoRows {
+		return nil, errors.Wrap(err, "error finding SCIM group members")
+	}
+
+	ids := make([]uuid.UUID, 0, len(members))
+	for _, member := range members {
+		ids = append(ids, member.UserID)
+	}
+
+	return ids, nil
+}
+
+// AddMembers adds the users to the group, skipping those that are already
+// members.
+func (g *SCIMGroup) AddMembers(tx *storage.Connection, userIDs []uuid.UUID) error {
+	for _, userID := range userIDs {
+		if err := tx.RawQuery(
+			"insert into "+(&pop.Model{Value: SCIMGroupMember{}}).TableName()+" (group_id, user_id, created_at) values (?, ?, now()) on conflict do nothing",
+			g.ID, userID,
+		).Exec(); err != nil {
+			return errors.Wrap(err, "error adding SCIM group member")
+		}
+	}
+
+	return nil
+}
+
+// RemoveMembers removes the users from the group.
+func (g *SCIMGroup) RemoveMembers(tx *storage.Connection, userIDs []uuid.UUID) error {
+	for _, userID := range userIDs {
+		if err := tx.RawQuery(
+			"delete from "+(&pop.Model{Value: SCIMGroupMember{}}).TableName()+" where group_id = ? and user_id = ?",
+			g.ID, userID,
+		).Exec(); err != nil {
+			return errors.Wrap(err, "error removing SCIM group member")
+		}
+	}
+
+	return nil
+}
+
+// ReplaceMembers replaces all of the group's members with the users.
+func (g *SCIMGroup) ReplaceMembers(tx *storage.Connection, userIDs []uuid.UUID) error {
+	if err := tx.RawQuery(
+		"delete from "+(&pop.Model{Value: SCIMGroupMember{}}).TableName()+" where group_id = ?",
+		g.ID,
+	).Exec(); err != nil {
+		return errors.Wrap(err, "error removing SCIM group members")
+	}
+
+	return g.AddMembers(tx, userIDs)
+}
+
+// RemoveSCIMGroupMemberships removes the user from all groups, for when the
+// user is deleted.
+func RemoveSCIMGroupMemberships(tx *storage.Connection, userID uuid.UUID) error {
+	if err := tx.RawQuery(
+		"delete from "+(&pop.Model{Value: SCIMGroupMember{}}).TableName()+" where user_id = ?",
+		userID,
+	).Exec(); err != nil {
+		return errors.Wrap(err, "error removing SCIM group memberships")
+	}
+
+	return nil
+}
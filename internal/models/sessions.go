@@ -89,6 +89,12 @@ type Session struct {
 	IP          *string    `json:"ip,omitempty" db:"ip"`
 
 	Tag *string `json:"tag" db:"tag"`
+
+	OrganizationID *uuid.UUID `json:"organization_id,omitempty" db:"organization_id"`
+
+	// RequiresAAL2 is set by the risk engine for sessions that can't be
+	// refreshed before they are stepped up to AAL2.
+	RequiresAAL2 bool `json:"requires_aal2,omitempty" db:"requires_aal2"`
 }
 
 func (Session) TableName() string {
@@ -157,6 +163,10 @@ func (s *Session) CheckValidity(config SessionValidityConfig, now time.Time, ref
 		return SessionLowAAL
 	}
 
+	if s.RequiresAAL2 && CompareAAL(ParseAAL(s.AAL), AAL2) < 0 {
+		return SessionLowAAL
+	}
+
 	return SessionValid
 }
 
@@ -299,6 +309,13 @@ func LogoutAllExceptMe(tx *storage.Connection, sessionId uuid.UUID, userID uuid.
 	return tx.RawQuery("DELETE FROM "+(&pop.Model{Value: Session{}}).TableName()+" WHERE id != ? AND user_id = ?", sessionId, userID).Exec()
 }
 
+// SelectOrganization selects the organization whose claims are added to the
+// session's access tokens. Nil deselects it.
+func (s *Session) SelectOrganization(tx *storage.Connection, organizationID *uuid.UUID) error {
+	s.OrganizationID = organizationID
+	return tx.UpdateOnly(s, "organization_id", "updated_at")
+}
+
 func (s *Session) UpdateAALAndAssociatedFactor(tx *storage.Connection, aal AuthenticatorAssuranceLevel, factorID *uuid.UUID) error {
 	s.FactorID = factorID
 	aalAsString := aal.String()
@@ -313,7 +330,7 @@ func (s *Session) CalculateAALAndAMR(user *User) (aal AuthenticatorAssuranceLeve
 			aal = AAL2
 		}
 		entry := AMREntry{Method: claim.GetAuthenticationMethod(), Timestamp: claim.UpdatedAt.Unix()}
-		if entry.Method == SSOSAML.String() {
+		if entry.Method == SSOSAML.String() || entry.Method == SSOOIDC.String() {
 			// SSO users should only have one identity since they are excluded from account linking
 			// These checks act as a safeguard in the event future changes break this assumption.
 			identities := user.Identities
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// SignInEvent is a successful sign in of a user along with the device and
+// location it was made from.
+type SignInEvent struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	UserID    uuid.UUID `json:"user_id" db:"user_id"`
+	IPAddress string    `json:"ip_address" db:"ip_address"`
+	UserAgent string    `json:"user_agent" db:"user_agent"`
+
+	// Country, Latitude and Longitude are only known if the request
+	// contained the configured location headers.
+	Country   string   `json:"country,omitempty" db:"country"`
+	Latitude  *float64 `json:"latitude,omitempty" db:"latitude"`
+	Longitude *float64 `json:"longitude,omitempty" db:"longitude"`
+
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+func (SignInEvent) TableName() string {
+	tableName := "sign_in_events"
+	return tableName
+}
+
+// HasLocation reports whether the coordinates of the sign in are known.
+func (e *SignInEvent) HasLocation() bool {
+	return e.Latitude != nil && e.Longitude != nil
+}
+
+// AddSignInEvent records a sign in, keeping only the keep most recent sign
+// ins of the user.
+func AddSignInEvent(tx *storage.Connection, event *SignInEvent, keep int) error {
+	if event.ID == uuid.Nil {
+		event.ID = uuid.Must(uuid.NewV4())
+	}
+
+	if err := tx.Create(event); err != nil {
+		return errors.Wrap(err, "error creating sign in event")
+	}
+
+	if keep < 1 {
+		keep = 1
+	}
+
+	table := (&SignInEvent{}).TableName()
+
+	if err := tx.RawQuery(
+		"delete from "+table+" where user_id = ? and id not in (select id from "+table+" where user_id = ? order by created_at desc limit ?)",
+		event.UserID, event.UserID, keep,
+	).Exec(); err != nil {
+		return errors.Wrap(err, "error pruning sign in events")
+	}
+
+	return nil
+}
+
+// FindSignInEvents returns the limit most recent sign ins of the user, most
+// recent first.
+func FindSignInEvents(tx *storage.Connection, userID uuid.UUID, limit int) ([]*SignInEvent, error) {
+	events := []*SignInEvent{}
+
+	if err := tx.Q().Where("user_id = ?", userID).Order("created_at desc").Limit(limit).All(&events); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return events, nil
+		}
+
+		return nil, errors.Wrap(err, "error finding sign in events")
+	}
+
+	return events, nil
+}
+
+// HasSignedInFrom reports whether the user has a recorded sign in or a
+// session, other than the session, from the IP address and user agent. It
+// also reports whether the user has any recorded sign in or other session at
+// all, as there is nothing to compare against on the first sign in.
+func HasSignedInFrom(tx *storage.Connection, userID uuid.UUID, ipAddress, userAgent string, sessionID uuid.UUID) (bool, bool, error) {
+	known, err := tx.Q().Where("user_id = ? and ip_address = ? and user_agent = ?", userID, ipAddress, userAgent).Exists(&SignInEvent{})
+	if err != nil {
+		return false, false, errors.Wrap(err, "error finding sign in events")
+	}
+
+	if !known {
+		known, err = tx.Q().Where("user_id = ? and id <> ? and host(ip) = ? and user_agent = ?", userID, sessionID, ipAddress, userAgent).Exists(&Session{})
+		if err != nil {
+			return false, false, errors.Wrap(err, "error finding sessions")
+		}
+	}
+
+	if known {
+		return true, true, nil
+	}
+
+	signedIn, err := tx.Q().Where("user_id = ?", userID).Exists(&SignInEvent{})
+	if err != nil {
+		return false, false, errors.Wrap(err, "error finding sign in events")
+	}
+
+	if !signedIn {
+		signedIn, err = tx.Q().Where("user_id = ? and id <> ?", userID, sessionID).Exists(&Session{})
+		if err != nil {
+			return false, false, errors.Wrap(err, "error finding sessions")
+		}
+	}
+
+	return false, signedIn, nil
+}
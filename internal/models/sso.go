@@ -10,16 +10,22 @@ import (
 
 	"github.com/crewjam/saml"
 	"github.com/crewjam/saml/samlsp"
+	"github.com/gobuffalo/pop/v6"
 	"github.com/gofrs/uuid"
 	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/crypto"
 	"github.com/supabase/auth/internal/storage"
 )
 
 type SSOProvider struct {
 	ID uuid.UUID `db:"id" json:"id"`
 
-	SAMLProvider SAMLProvider `has_one:"saml_providers" fk_id:"sso_provider_id" json:"saml,omitempty"`
-	SSODomains   []SSODomain  `has_many:"sso_domains" fk_id:"sso_provider_id" json:"domains"`
+	SAMLProvider SAMLProvider  `has_one:"saml_providers" fk_id:"sso_provider_id" json:"saml,omitempty"`
+	OIDCProvider *OIDCProvider `has_one:"oidc_providers" fk_id:"sso_provider_id" json:"oidc,omitempty"`
+	SSODomains   []SSODomain   `has_many:"sso_domains" fk_id:"sso_provider_id" json:"domains"`
+
+	SCIMTokenHash  *string    `db:"scim_token_hash" json:"-"`
+	OrganizationID *uuid.UUID `db:"organization_id" json:"organization_id,omitempty"`
 
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
@@ -30,9 +36,54 @@ func (p SSOProvider) TableName() string {
 }
 
 func (p SSOProvider) Type() string {
+	if p.OIDCProvider != nil {
+		return "oidc"
+	}
+
 	return "saml"
 }
 
+// AfterEagerFind drops the OIDC provider of SAML SSO providers, as pop
+// allocates has_one pointers before it knows whether a row exists.
+func (p *SSOProvider) AfterEagerFind(tx *pop.Connection) error {
+	if p.OIDCProvider != nil && p.OIDCProvider.ID == uuid.Nil {
+		p.OIDCProvider = nil
+	}
+
+	return nil
+}
+
+// Reload eagerly reloads the provider along with its associations.
+func (p *SSOProvider) Reload(tx *storage.Connection) error {
+	if err := tx.Eager().Load(p); err != nil {
+		return err
+	}
+
+	return p.AfterEagerFind(tx.Connection)
+}
+
+// MarshalJSON only includes the SAML or OIDC configuration matching the
+// provider's type, and whether SCIM provisioning is enabled instead of the
+// SCIM token hash.
+func (p SSOProvider) MarshalJSON() ([]byte, error) {
+	type ssoProvider SSOProvider
+
+	value := struct {
+		ssoProvider
+		SAMLProvider *SAMLProvider `json:"saml,omitempty"`
+		SCIMEnabled  bool          `json:"scim_enabled"`
+	}{
+		ssoProvider: ssoProvider(p),
+		SCIMEnabled: p.SCIMTokenHash != nil,
+	}
+
+	if p.OIDCProvider == nil {
+		value.SAMLProvider = &p.SAMLProvider
+	}
+
+	return json.Marshal(value)
+}
+
 type SAMLAttribute struct {
 	Name    string      `json:"name,omitempty"`
 	Names   []string    `json:"names,omitempty"`
@@ -135,6 +186,67 @@ func (p SAMLProvider) EntityDescriptor() (*saml.EntityDescriptor, error) {
 	return samlsp.ParseMetadata([]byte(p.MetadataXML))
 }
 
+type OIDCProvider struct {
+	ID uuid.UUID `db:"id" json:"-"`
+
+	SSOProvider   *SSOProvider `belongs_to:"sso_providers" json:"-"`
+	SSOProviderID uuid.UUID    `db:"sso_provider_id" json:"-"`
+
+	Issuer       string `db:"issuer" json:"issuer"`
+	ClientID     string `db:"client_id" json:"client_id"`
+	ClientSecret string `db:"client_secret" json:"-"`
+
+	// Scopes is a space separated list of scopes requested in addition to
+	// openid, profile and email.
+	Scopes *string `db:"scopes" json:"scopes,omitempty"`
+
+	AttributeMapping SAMLAttributeMapping `db:"attribute_mapping" json:"attribute_mapping,omitempty"`
+
+	CreatedAt time.Time `db:"created_at" json:"-"`
+	UpdatedAt time.Time `db:"updated_at" json:"-"`
+}
+
+func (p OIDCProvider) TableName() string {
+	return "oidc_providers"
+}
+
+// NewOIDCProvider creates a new OIDC provider with an ID, so that its client
+// secret can be encrypted before it's saved.
+func NewOIDCProvider(issuer, clientID string) *OIDCProvider {
+	return &OIDCProvider{
+		ID:       uuid.Must(uuid.NewV4()),
+		Issuer:   issuer,
+		ClientID: clientID,
+	}
+}
+
+func (p *OIDCProvider) SetClientSecret(secret string, encrypt bool, encryptionKeyID, encryptionKey string) error {
+	p.ClientSecret = secret
+	if encrypt {
+		es, err := crypto.NewEncryptedString(p.ID.String(), []byte(secret), encryptionKeyID, encryptionKey)
+		if err != nil {
+			return err
+		}
+
+		p.ClientSecret = es.String()
+	}
+
+	return nil
+}
+
+func (p *OIDCProvider) GetClientSecret(decryptionKeys map[string]string) (string, error) {
+	if es := crypto.ParseEncryptedString(p.ClientSecret); es != nil {
+		bytes, err := es.Decrypt(p.ID.String(), decryptionKeys)
+		if err != nil {
+			return "", err
+		}
+
+		return string(bytes), nil
+	}
+
+	return p.ClientSecret, nil
+}
+
 type SSODomain struct {
 	ID uuid.UUID `db:"id" json:"-"`
 
@@ -161,6 +273,10 @@ type SAMLRelayState struct {
 
 	RedirectTo string `db:"redirect_to"`
 
+	// LogoutRequest holds the signed LogoutRequest XML for Single Logout
+	// requests sent with the HTTP-POST binding.
+	LogoutRequest *string `db:"logout_request"`
+
 	CreatedAt   time.Time  `db:"created_at" json:"-"`
 	UpdatedAt   time.Time  `db:"updated_at" json:"-"`
 	FlowStateID *uuid.UUID `db:"flow_state_id" json:"flow_state_id,omitempty"`
@@ -171,6 +287,112 @@ func (s SAMLRelayState) TableName() string {
 	return "saml_relay_states"
 }
 
+// IsLogout reports whether the relay state was created for a Single Logout
+// request, rather than an authentication request.
+func (s *SAMLRelayState) IsLogout() bool {
+	return s.LogoutRequest != nil
+}
+
+// SAMLSession links a session to the NameID and SessionIndex of the SAML
+// assertion it was created with, so that it can be found and ended when the
+// identity provider sends a LogoutRequest. Sessions created with PKCE are
+// linked through the flow state until the auth code is exchanged.
+type SAMLSession struct {
+	ID uuid.UUID `db:"id" json:"id"`
+
+	SessionID     *uuid.UUID `db:"session_id" json:"session_id,omitempty"`
+	SSOProviderID uuid.UUID  `db:"sso_provider_id" json:"sso_provider_id"`
+	FlowStateID   *uuid.UUID `db:"flow_state_id" json:"flow_state_id,omitempty"`
+
+	NameID       string  `db:"name_id" json:"name_id"`
+	NameIDFormat *string `db:"name_id_format" json:"name_id_format,omitempty"`
+	SessionIndex *string `db:"session_index" json:"session_index,omitempty"`
+
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+func (s SAMLSession) TableName() string {
+	return "saml_sessions"
+}
+
+// NewSAMLSession creates a SAML session for the NameID and SessionIndex. An
+// empty format or session index is stored as NULL.
+func NewSAMLSession(ssoProviderID uuid.UUID, nameID, nameIDFormat, sessionIndex string) *SAMLSession {
+	s := &SAMLSession{
+		ID:            uuid.Must(uuid.NewV4()),
+		SSOProviderID: ssoProviderID,
+		NameID:        nameID,
+	}
+
+	if nameIDFormat != "" {
+		s.NameIDFormat = &nameIDFormat
+	}
+
+	if sessionIndex != "" {
+		s.SessionIndex = &sessionIndex
+	}
+
+	return s
+}
+
+// FindSAMLSessionBySessionID finds the SAML session linked to a session.
+func FindSAMLSessionBySessionID(tx *storage.Connection, sessionID uuid.UUID) (*SAMLSession, error) {
+	var s SAMLSession
+
+	if err := tx.Q().Where("session_id = ?", sessionID).First(&s); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, SAMLSessionNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error finding SAML session")
+	}
+
+	return &s, nil
+}
+
+// FindSAMLSessionsByNameID finds the SAML sessions of the SSO provider with
+// the NameID. If session indexes are given, only sessions with one of them
+// are returned, as a LogoutRequest with session indexes only applies to
+// those sessions.
+func FindSAMLSessionsByNameID(tx *storage.Connection, ssoProviderID uuid.UUID, nameID string, sessionIndexes []string) ([]*SAMLSession, error) {
+	sessions := []*SAMLSession{}
+
+	q := tx.Q().Where("sso_provider_id = ? and name_id = ? and session_id is not null", ssoProviderID, nameID)
+
+	if len(sessionIndexes) > 0 {
+		indexes := make([]interface{}, 0, len(sessionIndexes))
+		for _, index := range sessionIndexes {
+			indexes = append(indexes, index)
+		}
+
+		q = q.Where("session_index in (?)", indexes...)
+	}
+
+	if err := q.All(&sessions); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return sessions, nil
+		}
+
+		return nil, errors.Wrap(err, "error finding SAML sessions by NameID")
+	}
+
+	return sessions, nil
+}
+
+// LinkSAMLSessionToSession links the SAML session created with the flow
+// state to the session issued when its auth code is exchanged.
+func LinkSAMLSessionToSession(tx *storage.Connection, flowStateID, sessionID uuid.UUID) error {
+	if err := tx.RawQuery(
+		"update "+(&SAMLSession{}).TableName()+" set session_id = ?, flow_state_id = null, updated_at = now() where flow_state_id = ?",
+		sessionID, flowStateID,
+	).Exec(); err != nil {
+		return errors.Wrap(err, "error linking SAML session to session")
+	}
+
+	return nil
+}
+
 func FindSAMLProviderByEntityID(tx *storage.Connection, entityId string) (*SSOProvider, error) {
 	var samlProvider SAMLProvider
 	if err := tx.Q().Where("entity_id = ?", entityId).First(&samlProvider); err != nil {
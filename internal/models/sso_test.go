@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	tst "testing"
 
 	"github.com/stretchr/testify/require"
@@ -230,3 +231,73 @@ func (ts *SSOTestSuite) TestFindSAMLProviderByEntityID() {
 		}
 	}
 }
+
+func (ts *SSOTestSuite) TestOIDCProvider() {
+	samlProvider := &SSOProvider{
+		SAMLProvider: SAMLProvider{
+			EntityID:    "https://example.com/saml/metadata",
+			MetadataXML: "<example />",
+		},
+	}
+
+	require.NoError(ts.T(), ts.db.Eager().Create(samlProvider))
+
+	oidcProvider := NewOIDCProvider("https://example.okta.com", "client-id")
+	require.NoError(ts.T(), oidcProvider.SetClientSecret("client-secret", true, "key-id", "pwFoiPyybQMqNmYVN0gUnpbfpGQV2sDv9vp0ZAxi_Y4"))
+
+	provider := &SSOProvider{
+		OIDCProvider: oidcProvider,
+		SSODomains: []SSODomain{
+			{
+				Domain: "example.com",
+			},
+		},
+	}
+
+	require.NoError(ts.T(), ts.db.Eager("OIDCProvider", "SSODomains").Create(provider))
+
+	rp, err := FindSSOProviderForEmailAddress(ts.db, "someone@example.com")
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), "oidc", rp.Type())
+	require.Equal(ts.T(), "https://example.okta.com", rp.OIDCProvider.Issuer)
+	require.NotEqual(ts.T(), "client-secret", rp.OIDCProvider.ClientSecret)
+
+	secret, err := rp.OIDCProvider.GetClientSecret(map[string]string{"key-id": "pwFoiPyybQMqNmYVN0gUnpbfpGQV2sDv9vp0ZAxi_Y4"})
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), "client-secret", secret)
+
+	rp, err = FindSSOProviderByID(ts.db, samlProvider.ID)
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), "saml", rp.Type())
+	require.Nil(ts.T(), rp.OIDCProvider)
+
+	require.NoError(ts.T(), rp.Reload(ts.db))
+	require.Nil(ts.T(), rp.OIDCProvider)
+
+	providers, err := FindAllSAMLProviders(ts.db)
+	require.NoError(ts.T(), err)
+	require.Len(ts.T(), providers, 2)
+}
+
+func TestSSOProviderMarshalJSON(t *tst.T) {
+	samlJSON, err := json.Marshal(SSOProvider{
+		SAMLProvider: SAMLProvider{
+			EntityID: "https://example.com/saml/metadata",
+		},
+	})
+	require.NoError(t, err)
+	require.Contains(t, string(samlJSON), `"saml":{"entity_id":"https://example.com/saml/metadata"`)
+	require.NotContains(t, string(samlJSON), `"oidc"`)
+
+	oidcJSON, err := json.Marshal(SSOProvider{
+		OIDCProvider: &OIDCProvider{
+			Issuer:       "https://example.okta.com",
+			ClientID:     "client-id",
+			ClientSecret: "client-secret",
+		},
+	})
+	require.NoError(t, err)
+	require.Contains(t, string(oidcJSON), `"oidc":{"issuer":"https://example.okta.com","client_id":"client-id"`)
+	require.NotContains(t, string(oidcJSON), `"saml"`)
+	require.NotContains(t, string(oidcJSON), "client-secret")
+}
@@ -15,7 +15,6 @@ import (
 	"github.com/pkg/errors"
 	"github.com/supabase/auth/internal/crypto"
 	"github.com/supabase/auth/internal/storage"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // User respresents a registered user with email/password authentication
@@ -31,6 +30,10 @@ type User struct {
 	EmailConfirmedAt  *time.Time `json:"email_confirmed_at,omitempty" db:"email_confirmed_at"`
 	InvitedAt         *time.Time `json:"invited_at,omitempty" db:"invited_at"`
 
+	// PasswordChangedAt is when the password was last set. It is nil for
+	// passwords set before it was recorded.
+	PasswordChangedAt *time.Time `json:"-" db:"password_changed_at"`
+
 	Phone            storage.NullString `json:"phone" db:"phone"`
 	PhoneConfirmedAt *time.Time         `json:"phone_confirmed_at,omitempty" db:"phone_confirmed_at"`
 
@@ -70,28 +73,24 @@ type User struct {
 	DeletedAt   *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
 	IsAnonymous bool       `json:"is_anonymous" db:"is_anonymous"`
 
+	// DeletionScheduledAt is when the user, who deleted their own
+	// account, is deleted unless they sign in before then.
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty" db:"deletion_scheduled_at"`
+
+	// DeletionAttempts is the number of times the scheduled deletion
+	// failed, and DeletionRetryAt when it is attempted again.
+	DeletionAttempts int        `json:"-" db:"deletion_attempts"`
+	DeletionRetryAt  *time.Time `json:"-" db:"deletion_retry_at"`
+
 	DONTUSEINSTANCEID uuid.UUID `json:"-" db:"instance_id"`
 }
 
 func NewUserWithPasswordHash(phone, email, passwordHash, aud string, userData map[string]interface{}) (*User, error) {
-	if strings.HasPrefix(passwordHash, crypto.Argon2Prefix) {
-		_, err := crypto.ParseArgon2Hash(passwordHash)
-		if err != nil {
-			return nil, err
-		}
-	} else if strings.HasPrefix(passwordHash, crypto.FirebaseScryptPrefix) {
-		_, err := crypto.ParseFirebaseScryptHash(passwordHash)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		// verify that the hash is a bcrypt hash
-		_, err := bcrypt.Cost([]byte(passwordHash))
-		if err != nil {
-			return nil, err
-		}
+	if err := crypto.ValidatePasswordHash(passwordHash); err != nil {
+		return nil, err
 	}
 	id := uuid.Must(uuid.NewV4())
+	now := time.Now()
 	user := &User{
 		ID:                id,
 		Aud:               aud,
@@ -99,6 +98,7 @@ func NewUserWithPasswordHash(phone, email, passwordHash, aud string, userData ma
 		Phone:             storage.NullString(phone),
 		UserMetaData:      userData,
 		EncryptedPassword: &passwordHash,
+		PasswordChangedAt: &now,
 	}
 	return user, nil
 }
@@ -129,6 +129,10 @@ func NewUser(phone, email, password, aud string, userData map[string]interface{}
 		UserMetaData:      userData,
 		EncryptedPassword: &passwordHash,
 	}
+	if passwordHash != "" {
+		now := time.Now()
+		user.PasswordChangedAt = &now
+	}
 	return user, nil
 }
 
@@ -365,7 +369,10 @@ func (u *User) UpdatePassword(tx *storage.Connection, sessionID *uuid.UUID) erro
 	u.ReauthenticationToken = ""
 	u.ReauthenticationSentAt = nil
 
-	if err := tx.UpdateOnly(u, "encrypted_password", "confirmation_token", "confirmation_sent_at", "recovery_token", "recovery_sent_at", "email_change_token_current", "email_change_token_new", "email_change_sent_at", "phone_change_token", "phone_change_sent_at", "reauthentication_token", "reauthentication_sent_at"); err != nil {
+	now := time.Now()
+	u.PasswordChangedAt = &now
+
+	if err := tx.UpdateOnly(u, "encrypted_password", "password_changed_at", "confirmation_token", "confirmation_sent_at", "recovery_token", "recovery_sent_at", "email_change_token_current", "email_change_token_new", "email_change_sent_at", "phone_change_token", "phone_change_sent_at", "reauthentication_token", "reauthentication_sent_at"); err != nil {
 		return err
 	}
 
@@ -382,7 +389,10 @@ func (u *User) UpdatePassword(tx *storage.Connection, sessionID *uuid.UUID) erro
 	}
 }
 
-// Authenticate a user from a password
+// Authenticate a user from a password. The second return value reports
+// whether the password hash should be replaced, either because it needs to be
+// (re-)encrypted or because it was not generated with the current password
+// hashing algorithm and parameters.
 func (u *User) Authenticate(ctx context.Context, tx *storage.Connection, password string, decryptionKeys map[string]string, encrypt bool, encryptionKeyID string) (bool, bool, error) {
 	if u.EncryptedPassword == nil {
 		return false, false, nil
@@ -404,25 +414,13 @@ func (u *User) Authenticate(ctx context.Context, tx *storage.Connection, passwor
 		hash = string(h)
 	}
 
-	compareErr := crypto.CompareHashAndPassword(ctx, hash, password)
-
-	if !strings.HasPrefix(hash, crypto.Argon2Prefix) && !strings.HasPrefix(hash, crypto.FirebaseScryptPrefix) {
-		// check if cost exceeds default cost or is too low
-		cost, err := bcrypt.Cost([]byte(hash))
-		if err != nil {
-			return compareErr == nil, false, err
-		}
-
-		if cost > bcrypt.DefaultCost || cost == bcrypt.MinCost {
-			// don't bother with encrypting the password in Authenticate
-			// since it's handled separately
-			if err := u.SetPassword(ctx, password, false, "", ""); err != nil {
-				return compareErr == nil, false, err
-			}
-		}
+	if err := crypto.CompareHashAndPassword(ctx, hash, password); err != nil {
+		return false, false, nil
 	}
 
-	return compareErr == nil, encrypt && (es == nil || es.ShouldReEncrypt(encryptionKeyID)), nil
+	shouldReEncrypt := encrypt && (es == nil || es.ShouldReEncrypt(encryptionKeyID))
+
+	return true, shouldReEncrypt || crypto.NeedsRehash(hash), nil
 }
 
 // ConfirmReauthentication resets the reauthentication token
@@ -602,6 +600,15 @@ func CountOtherUsers(tx *storage.Connection, id uuid.UUID) (int, error) {
 	return userCount, errors.Wrap(err, "error finding registered users")
 }
 
+// CountUsersWithLegacyPasswordHash counts the users whose password hash was not
+// generated with the current password hashing algorithm and parameters.
+// Encrypted password hashes are not counted, as they can't be inspected in the
+// database.
+func CountUsersWithLegacyPasswordHash(tx *storage.Connection) (int, error) {
+	count, err := tx.Q().Where("encrypted_password is not null and encrypted_password != '' and encrypted_password not like '{%' and encrypted_password !~ ?", crypto.PasswordHashPattern()).Count(&User{})
+	return count, errors.Wrap(err, "error counting users with legacy password hashes")
+}
+
 func findUser(tx *storage.Connection, query string, args ...interface{}) (*User, error) {
 	obj := &User{}
 	if err := tx.Eager().Q().Where(query, args...).First(obj); err != nil {
@@ -690,30 +697,60 @@ func FindUserWithRefreshToken(tx *storage.Connection, token string, forUpdate bo
 
 // FindUsersInAudience finds users with the matching audience.
 func FindUsersInAudience(tx *storage.Connection, aud string, pageParams *Pagination, sortParams *SortParams, filter string) ([]*User, error) {
+	return SearchUsersInAudience(tx, aud, &UserSearchParams{Filter: filter}, pageParams, sortParams)
+}
+
+// FindUsersInAudienceAfterID returns up to limit users of the audience with
+// an ID greater than afterID, ordered by ID and with their identities, so
+// that all users can be read in batches.
+func FindUsersInAudienceAfterID(tx *storage.Connection, aud string, afterID uuid.UUID, limit int) ([]*User, error) {
 	users := []*User{}
-	q := tx.Q().Where("instance_id = ? and aud = ?", uuid.Nil, aud)
 
-	if filter != "" {
-		lf := "%" + filter + "%"
-		// we must specify the collation in order to get case insensitive search for the JSON column
-		q = q.Where("(email LIKE ? OR raw_user_meta_data->>'full_name' ILIKE ?)", lf, lf)
+	if err := tx.Eager("Identities").Q().Where("instance_id = ? and aud = ? and id > ? and deleted_at is null", uuid.Nil, aud, afterID).Order("id asc").Limit(limit).All(&users); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return users, nil
+		}
+
+		return nil, errors.Wrap(err, "error finding users")
 	}
 
-	if sortParams != nil && len(sortParams.Fields) > 0 {
-		for _, field := range sortParams.Fields {
-			q = q.Order(field.Name + " " + string(field.Dir))
+	return users, nil
+}
+
+// FindUsersDueForDeletion returns up to limit users whose scheduled deletion
+// is due at the time, most overdue first. Users whose deletion failed are
+// left out until it is retried.
+func FindUsersDueForDeletion(tx *storage.Connection, now time.Time, limit int) ([]*User, error) {
+	users := []*User{}
+
+	if err := tx.Q().Where("instance_id = ? and deletion_scheduled_at <= ? and (deletion_retry_at is null or deletion_retry_at <= ?) and deleted_at is null", uuid.Nil, now, now).Order("deletion_scheduled_at asc").Limit(limit).All(&users); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return users, nil
 		}
+
+		return nil, errors.Wrap(err, "error finding users due for deletion")
 	}
 
-	var err error
-	if pageParams != nil {
-		err = q.Paginate(int(pageParams.Page), int(pageParams.PerPage)).All(&users) // #nosec G115
-		pageParams.Count = uint64(q.Paginator.TotalEntriesSize)                     // #nosec G115
-	} else {
-		err = q.All(&users)
+	return users, nil
+}
+
+// ClaimUserDeletion locks the user for deletion in the transaction and
+// returns it. It returns nil if the deletion was cancelled or the user is
+// locked by another transaction, such as a sign in or another worker.
+func ClaimUserDeletion(tx *storage.Connection, id uuid.UUID, now time.Time) (*User, error) {
+	user := &User{}
+	if err := tx.RawQuery(
+		fmt.Sprintf("select * from %q where id = ? and deletion_scheduled_at <= ? and (deletion_retry_at is null or deletion_retry_at <= ?) and deleted_at is null for update skip locked", user.TableName()),
+		id, now, now,
+	).First(user); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, errors.Wrap(err, "error claiming user deletion")
 	}
 
-	return users, err
+	return user, nil
 }
 
 // IsDuplicatedEmail returns whether a user exists with a matching email and audience.
@@ -810,6 +847,33 @@ func (u *User) UpdateBannedUntil(tx *storage.Connection) error {
 	return tx.UpdateOnly(u, "banned_until")
 }
 
+// ScheduleDeletion schedules the deletion of the user at the time.
+func (u *User) ScheduleDeletion(tx *storage.Connection, at time.Time) error {
+	u.DeletionScheduledAt = &at
+	u.DeletionAttempts = 0
+	u.DeletionRetryAt = nil
+
+	return tx.UpdateOnly(u, "deletion_scheduled_at", "deletion_attempts", "deletion_retry_at")
+}
+
+// CancelDeletion cancels the scheduled deletion of the user, if any.
+func (u *User) CancelDeletion(tx *storage.Connection) error {
+	u.DeletionScheduledAt = nil
+	u.DeletionAttempts = 0
+	u.DeletionRetryAt = nil
+
+	return tx.UpdateOnly(u, "deletion_scheduled_at", "deletion_attempts", "deletion_retry_at")
+}
+
+// DeferDeletion records a failed attempt at the scheduled deletion of the
+// user, which is retried at the time.
+func (u *User) DeferDeletion(tx *storage.Connection, retryAt time.Time) error {
+	u.DeletionAttempts++
+	u.DeletionRetryAt = &retryAt
+
+	return tx.UpdateOnly(u, "deletion_attempts", "deletion_retry_at")
+}
+
 // RemoveUnconfirmedIdentities removes potentially malicious unconfirmed identities from a user (if any)
 func (u *User) RemoveUnconfirmedIdentities(tx *storage.Connection, identity *Identity) error {
 	if identity.Provider != "email" && identity.Provider != "phone" {
@@ -0,0 +1,285 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// UserImportStatus is the status of a bulk user import.
+type UserImportStatus string
+
+const (
+	// UserImportUploading jobs are still receiving rows. Rows of jobs
+	// whose upload was interrupted are never processed.
+	UserImportUploading UserImportStatus = "uploading"
+	UserImportPending   UserImportStatus = "pending"
+	UserImportCompleted UserImportStatus = "completed"
+)
+
+// UserImportRowStatus is the status of a row of a bulk user import.
+// Imported rows are deleted, so only pending and failed rows exist.
+type UserImportRowStatus string
+
+const (
+	UserImportRowPending UserImportRowStatus = "pending"
+	UserImportRowFailed  UserImportRowStatus = "failed"
+)
+
+// UserImportJob is a bulk user import, which is processed in the background
+// row by row.
+type UserImportJob struct {
+	ID           uuid.UUID        `json:"id" db:"id"`
+	Aud          string           `json:"aud" db:"aud"`
+	Format       string           `json:"format" db:"format"`
+	Status       UserImportStatus `json:"status" db:"status"`
+	TotalRows    int              `json:"total_rows" db:"total_rows"`
+	ImportedRows int              `json:"imported_rows" db:"imported_rows"`
+	FailedRows   int              `json:"failed_rows" db:"failed_rows"`
+	CreatedAt    time.Time        `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time        `json:"updated_at" db:"updated_at"`
+	CompletedAt  *time.Time       `json:"completed_at,omitempty" db:"completed_at"`
+}
+
+func (UserImportJob) TableName() string {
+	tableName := "user_import_jobs"
+	return tableName
+}
+
+// UserImportRow is a row of a bulk user import that is pending or failed.
+type UserImportRow struct {
+	ID        uuid.UUID           `json:"-" db:"id"`
+	JobID     uuid.UUID           `json:"-" db:"job_id"`
+	Line      int                 `json:"line" db:"line"`
+	Data      JSONMap             `json:"-" db:"data"`
+	Status    UserImportRowStatus `json:"-" db:"status"`
+	Error     *string             `json:"error,omitempty" db:"error"`
+	CreatedAt time.Time           `json:"-" db:"created_at"`
+	UpdatedAt time.Time           `json:"-" db:"updated_at"`
+}
+
+func (UserImportRow) TableName() string {
+	tableName := "user_import_rows"
+	return tableName
+}
+
+// NewUserImportJob creates a job that receives rows until it is started.
+func NewUserImportJob(tx *storage.Connection, aud, format string) (*UserImportJob, error) {
+	job := &UserImportJob{
+		ID:     uuid.Must(uuid.NewV4()),
+		Aud:    aud,
+		Format: format,
+		Status: UserImportUploading,
+	}
+
+	if err := tx.Create(job); err != nil {
+		return nil, errors.Wrap(err, "error creating user import job")
+	}
+
+	return job, nil
+}
+
+// AddUserImportRows adds rows to the job with a single statement. Rows with
+// an error are added as failed.
+func AddUserImportRows(tx *storage.Connection, job *UserImportJob, rows []*UserImportRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	table := (&UserImportRow{}).TableName()
+
+	values := make([]string, 0, len(rows))
+	args := make([]interface{}, 0, len(rows)*5)
+	failed := 0
+
+	for _, row := range rows {
+		row.ID = uuid.Must(uuid.NewV4())
+		row.JobID = job.ID
+		row.Status = UserImportRowPending
+		if row.Error != nil {
+			row.Status = UserImportRowFailed
+			failed++
+		}
+		if row.Data == nil {
+			row.Data = JSONMap{}
+		}
+
+		values = append(values, "(?, ?, ?, ?, ?, ?)")
+		args = append(args, row.ID, row.JobID, row.Line, row.Data, row.Status, row.Error)
+	}
+
+	if err := tx.RawQuery(
+		fmt.Sprintf("insert into %q (id, job_id, line, data, status, error) values %s", table, strings.Join(values, ", ")),
+		args...,
+	).Exec(); err != nil {
+		return errors.Wrap(err, "error adding user import rows")
+	}
+
+	if err := tx.RawQuery(
+		fmt.Sprintf("update %q set total_rows = total_rows + ?, failed_rows = failed_rows + ?, updated_at = now() where id = ?", job.TableName()),
+		len(rows), failed, job.ID,
+	).Exec(); err != nil {
+		return errors.Wrap(err, "error updating user import job")
+	}
+
+	job.TotalRows += len(rows)
+	job.FailedRows += failed
+
+	return nil
+}
+
+// Start marks the upload of the job as finished, so that its rows are
+// processed.
+func (j *UserImportJob) Start(tx *storage.Connection) error {
+	j.Status = UserImportPending
+	return tx.UpdateOnly(j, "status")
+}
+
+// FindUserImportJobByID finds the job with the ID.
+func FindUserImportJobByID(tx *storage.Connection, id uuid.UUID) (*UserImportJob, error) {
+	job := &UserImportJob{}
+
+	if err := tx.Q().Where("id = ?", id).First(job); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, UserImportJobNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error finding user import job")
+	}
+
+	return job, nil
+}
+
+// FindPendingUserImportJobs returns the jobs whose rows are being processed,
+// oldest first.
+func FindPendingUserImportJobs(tx *storage.Connection) ([]*UserImportJob, error) {
+	jobs := []*UserImportJob{}
+
+	if err := tx.Q().Where("status = ?", UserImportPending).Order("created_at asc").All(&jobs); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return jobs, nil
+		}
+
+		return nil, errors.Wrap(err, "error finding pending user import jobs")
+	}
+
+	return jobs, nil
+}
+
+// FindPendingUserImportRows returns up to limit pending rows of the job, in
+// the order they were uploaded.
+func FindPendingUserImportRows(tx *storage.Connection, jobID uuid.UUID, limit int) ([]*UserImportRow, error) {
+	rows := []*UserImportRow{}
+
+	if err := tx.Q().Where("job_id = ? and status = ?", jobID, UserImportRowPending).Order("line asc").Limit(limit).All(&rows); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return rows, nil
+		}
+
+		return nil, errors.Wrap(err, "error finding pending user import rows")
+	}
+
+	return rows, nil
+}
+
+// FindFailedUserImportRows returns the failed rows of the job, in the order
+// they were uploaded.
+func FindFailedUserImportRows(tx *storage.Connection, jobID uuid.UUID, pageParams *Pagination) ([]*UserImportRow, error) {
+	q := tx.Q().Where("job_id = ? and status = ?", jobID, UserImportRowFailed).Order("line asc")
+
+	rows := []*UserImportRow{}
+	var err error
+	if pageParams != nil {
+		err = q.Paginate(int(pageParams.Page), int(pageParams.PerPage)).All(&rows) // #nosec G115
+		pageParams.Count = uint64(q.Paginator.TotalEntriesSize)                    // #nosec G115
+	} else {
+		err = q.All(&rows)
+	}
+
+	return rows, err
+}
+
+// ClaimUserImportRow locks the row for processing in the transaction. It
+// returns false if the row was processed or is locked by another worker.
+func ClaimUserImportRow(tx *storage.Connection, row *UserImportRow) (bool, error) {
+	table := row.TableName()
+
+	claimed := &UserImportRow{}
+	if err := tx.RawQuery(
+		fmt.Sprintf("select * from %q where id = ? and status = ? for update skip locked", table),
+		row.ID, UserImportRowPending,
+	).First(claimed); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return false, nil
+		}
+
+		return false, errors.Wrap(err, "error claiming user import row")
+	}
+
+	return true, nil
+}
+
+// MarkImported deletes the row and counts it as imported.
+func (r *UserImportRow) MarkImported(tx *storage.Connection) error {
+	if err := tx.Destroy(r); err != nil {
+		return errors.Wrap(err, "error deleting user import row")
+	}
+
+	if err := tx.RawQuery(
+		fmt.Sprintf("update %q set imported_rows = imported_rows + 1, updated_at = now() where id = ?", (&UserImportJob{}).TableName()),
+		r.JobID,
+	).Exec(); err != nil {
+		return errors.Wrap(err, "error updating user import job")
+	}
+
+	return nil
+}
+
+// MarkFailed records the error of the row and counts it as failed, unless
+// the row was processed in the meantime. The password hash of the row is
+// removed, as failed rows are kept until the job is deleted.
+func (r *UserImportRow) MarkFailed(tx *storage.Connection, reason string) error {
+	count, err := tx.RawQuery(
+		fmt.Sprintf("update %q set status = ?, error = ?, data = data - 'password_hash', updated_at = now() where id = ? and status = ?", r.TableName()),
+		UserImportRowFailed, reason, r.ID, UserImportRowPending,
+	).ExecWithCount()
+	if err != nil {
+		return errors.Wrap(err, "error marking user import row as failed")
+	}
+
+	if count == 0 {
+		return nil
+	}
+
+	r.Status = UserImportRowFailed
+	r.Error = &reason
+	delete(r.Data, "password_hash")
+
+	if err := tx.RawQuery(
+		fmt.Sprintf("update %q set failed_rows = failed_rows + 1, updated_at = now() where id = ?", (&UserImportJob{}).TableName()),
+		r.JobID,
+	).Exec(); err != nil {
+		return errors.Wrap(err, "error updating user import job")
+	}
+
+	return nil
+}
+
+// CompleteUserImportJob marks the job as completed if it has no pending rows
+// left, returning whether it did.
+func CompleteUserImportJob(tx *storage.Connection, jobID uuid.UUID) (bool, error) {
+	count, err := tx.RawQuery(
+		fmt.Sprintf("update %q set status = ?, completed_at = now(), updated_at = now() where id = ? and status = ? and not exists (select 1 from %q where job_id = ? and status = ?)", (&UserImportJob{}).TableName(), (&UserImportRow{}).TableName()),
+		UserImportCompleted, jobID, UserImportPending, jobID, UserImportRowPending,
+	).ExecWithCount()
+	if err != nil {
+		return false, errors.Wrap(err, "error completing user import job")
+	}
+
+	return count > 0, nil
+}
@@ -0,0 +1,260 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// UserCountMode is how the total number of users matching a search is
+// counted.
+type UserCountMode string
+
+const (
+	UserCountExact     UserCountMode = "exact"
+	UserCountEstimated UserCountMode = "estimated"
+	UserCountNone      UserCountMode = "none"
+)
+
+// UserSearchParams are the filters of a user search. Unset filters match all
+// users, and set filters must all match.
+type UserSearchParams struct {
+	// Filter is a substring of the email or full name.
+	Filter string
+
+	Email       string
+	EmailPrefix string
+	Phone       string
+	PhonePrefix string
+
+	// Provider matches users with an identity of the provider.
+	Provider string
+
+	Confirmed  *bool
+	Banned     *bool
+	Anonymous  *bool
+	MFAEnabled *bool
+
+	CreatedAfter     *time.Time
+	CreatedBefore    *time.Time
+	LastSignInAfter  *time.Time
+	LastSignInBefore *time.Time
+
+	// AppMetaData matches the text value at each path of the app metadata.
+	AppMetaData []AppMetaDataFilter
+}
+
+// AppMetaDataFilter matches users whose app metadata has the value at the
+// path of keys, compared as text.
+type AppMetaDataFilter struct {
+	Path  []string
+	Value string
+}
+
+// UserSearchCursor is the position of the last user of a page of search
+// results, from which the next page starts.
+type UserSearchCursor struct {
+	CreatedAt time.Time `json:"created_at"`
+	ID        uuid.UUID `json:"id"`
+}
+
+// escapeLike escapes the wildcards of LIKE patterns in s.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// textArrayLiteral returns the Postgres text array literal of the elements.
+func textArrayLiteral(elements []string) string {
+	quoted := make([]string, len(elements))
+	for i, element := range elements {
+		quoted[i] = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(element) + `"`
+	}
+	return "{" + strings.Join(quoted, ",") + "}"
+}
+
+// userSearchQuery returns the where clause matching the users of the
+// audience that match the search params.
+func userSearchQuery(aud string, params *UserSearchParams) (string, []interface{}) {
+	clauses := []string{"users.instance_id = ? and users.aud = ?"}
+	args := []interface{}{uuid.Nil, aud}
+
+	add := func(clause string, clauseArgs ...interface{}) {
+		clauses = append(clauses, clause)
+		args = append(args, clauseArgs...)
+	}
+
+	addBool := func(value *bool, clause string) {
+		if value == nil {
+			return
+		}
+		if *value {
+			clauses = append(clauses, clause)
+		} else {
+			clauses = append(clauses, "not ("+clause+")")
+		}
+	}
+
+	if params == nil {
+		return clauses[0], args
+	}
+
+	if params.Filter != "" {
+		lf := "%" + params.Filter + "%"
+		// we must specify the collation in order to get case insensitive search for the JSON column
+		add("(users.email like ? or users.raw_user_meta_data->>'full_name' ilike ?)", lf, lf)
+	}
+
+	if params.Email != "" {
+		add("users.email = ?", strings.ToLower(params.Email))
+	}
+	if params.EmailPrefix != "" {
+		add("users.email like ?", escapeLike(strings.ToLower(params.EmailPrefix))+"%")
+	}
+	if params.Phone != "" {
+		add("users.phone = ?", params.Phone)
+	}
+	if params.PhonePrefix != "" {
+		add("users.phone like ?", escapeLike(params.PhonePrefix)+"%")
+	}
+
+	if params.Provider != "" {
+		add("exists (select 1 from "+(&pop.Model{Value: Identity{}}).TableName()+" i where i.user_id = users.id and i.provider = ?)", params.Provider)
+	}
+
+	addBool(params.Confirmed, "coalesce(users.email_confirmed_at, users.phone_confirmed_at) is not null")
+	addBool(params.Banned, "coalesce(users.banned_until > now(), false)")
+	addBool(params.Anonymous, "users.is_anonymous")
+	addBool(params.MFAEnabled, "exists (select 1 from "+(&pop.Model{Value: Factor{}}).TableName()+" f where f.user_id = users.id and f.status = '"+FactorStateVerified.String()+"')")
+
+	if params.CreatedAfter != nil {
+		add("users.created_at >= ?", *params.CreatedAfter)
+	}
+	if params.CreatedBefore != nil {
+		add("users.created_at < ?", *params.CreatedBefore)
+	}
+	if params.LastSignInAfter != nil {
+		add("users.last_sign_in_at >= ?", *params.LastSignInAfter)
+	}
+	if params.LastSignInBefore != nil {
+		add("users.last_sign_in_at < ?", *params.LastSignInBefore)
+	}
+
+	for _, filter := range params.AppMetaData {
+		add("users.raw_app_meta_data #>> ?::text[] = ?", textArrayLiteral(filter.Path), filter.Value)
+	}
+
+	return strings.Join(clauses, " and "), args
+}
+
+// SearchUsersInAudience finds the users of the audience matching the search
+// params, a page at a time by offset.
+func SearchUsersInAudience(tx *storage.Connection, aud string, params *UserSearchParams, pageParams *Pagination, sortParams *SortParams) ([]*User, error) {
+	users := []*User{}
+	clause, args := userSearchQuery(aud, params)
+	q := tx.Q().Where(clause, args...)
+
+	if sortParams != nil && len(sortParams.Fields) > 0 {
+		for _, field := range sortParams.Fields {
+			q = q.Order(field.Name + " " + string(field.Dir))
+		}
+	}
+
+	var err error
+	if pageParams != nil {
+		err = q.Paginate(int(pageParams.Page), int(pageParams.PerPage)).All(&users) // #nosec G115
+		pageParams.Count = uint64(q.Paginator.TotalEntriesSize)                     // #nosec G115
+	} else {
+		err = q.All(&users)
+	}
+
+	return users, err
+}
+
+// SearchUsersInAudienceAfter finds up to limit users of the audience matching
+// the search params, ordered by creation in the direction and starting after
+// the cursor, if any. Unlike offset pagination, the cost of a page doesn't
+// grow with its position. It returns the cursor of the next page, which is
+// nil on the last page.
+func SearchUsersInAudienceAfter(tx *storage.Connection, aud string, params *UserSearchParams, cursor *UserSearchCursor, dir SortDirection, limit int) ([]*User, *UserSearchCursor, error) {
+	users := []*User{}
+	clause, args := userSearchQuery(aud, params)
+
+	comparison := "<"
+	if dir == Ascending {
+		comparison = ">"
+	} else {
+		dir = Descending
+	}
+
+	if cursor != nil {
+		clause += " and (users.created_at, users.id) " + comparison + " (?, ?)"
+		args = append(args, cursor.CreatedAt, cursor.ID)
+	}
+
+	// one more user than asked for tells whether there's a next page
+	if err := tx.Q().Where(clause, args...).Order("users.created_at " + string(dir) + ", users.id " + string(dir)).Limit(limit + 1).All(&users); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return users, nil, nil
+		}
+
+		return nil, nil, errors.Wrap(err, "error finding users")
+	}
+
+	if len(users) <= limit {
+		return users, nil, nil
+	}
+
+	users = users[:limit]
+	last := users[len(users)-1]
+
+	return users, &UserSearchCursor{CreatedAt: last.CreatedAt, ID: last.ID}, nil
+}
+
+// CountUsersInAudience counts the users of the audience matching the search
+// params. Estimated counts come from the query planner, so they are cheap
+// but can be off, especially for selective filters.
+func CountUsersInAudience(tx *storage.Connection, aud string, params *UserSearchParams, mode UserCountMode) (uint64, error) {
+	clause, args := userSearchQuery(aud, params)
+
+	switch mode {
+	case UserCountExact:
+		count, err := tx.Q().Where(clause, args...).Count(&User{})
+		if err != nil {
+			return 0, errors.Wrap(err, "error counting users")
+		}
+		return uint64(count), nil // #nosec G115
+
+	case UserCountEstimated:
+		var result struct {
+			Plan string `db:"QUERY PLAN"`
+		}
+
+		query := "explain (format json) select 1 from " + (&pop.Model{Value: User{}}).TableName() + " users where " + clause
+		if err := tx.RawQuery(query, args...).First(&result); err != nil {
+			return 0, errors.Wrap(err, "error estimating user count")
+		}
+
+		var plans []struct {
+			Plan struct {
+				Rows float64 `json:"Plan Rows"`
+			} `json:"Plan"`
+		}
+		if err := json.Unmarshal([]byte(result.Plan), &plans); err != nil {
+			return 0, errors.Wrap(err, "error parsing user count estimate")
+		}
+		if len(plans) == 0 {
+			return 0, errors.New("error parsing user count estimate: empty plan")
+		}
+
+		return uint64(plans[0].Plan.Rows), nil
+
+	default:
+		return 0, nil
+	}
+}
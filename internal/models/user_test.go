@@ -3,6 +3,7 @@ package models
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -129,6 +130,30 @@ func (ts *UserTestSuite) TestFindUsersInAudience() {
 	require.Len(ts.T(), n, 1)
 }
 
+func (ts *UserTestSuite) TestSearchUsersInAudienceAfter() {
+	u := ts.createUser()
+
+	confirmed := false
+	params := &UserSearchParams{EmailPrefix: "david@", Provider: "email", Confirmed: &confirmed}
+
+	n, next, err := SearchUsersInAudienceAfter(ts.db, u.Aud, params, nil, Descending, 1)
+	require.NoError(ts.T(), err)
+	require.Len(ts.T(), n, 1)
+	require.Equal(ts.T(), u.ID, n[0].ID)
+	require.Nil(ts.T(), next)
+
+	n, _, err = SearchUsersInAudienceAfter(ts.db, u.Aud, params, &UserSearchCursor{CreatedAt: u.CreatedAt, ID: u.ID}, Descending, 1)
+	require.NoError(ts.T(), err)
+	require.Len(ts.T(), n, 0)
+
+	count, err := CountUsersInAudience(ts.db, u.Aud, params, UserCountExact)
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), uint64(1), count)
+
+	_, err = CountUsersInAudience(ts.db, u.Aud, params, UserCountEstimated)
+	require.NoError(ts.T(), err)
+}
+
 func (ts *UserTestSuite) TestFindUserByID() {
 	u := ts.createUser()
 
@@ -389,6 +414,18 @@ func (ts *UserTestSuite) TestNewUserWithPasswordHashSuccess() {
 			desc: "Valid Firebase scrypt hash",
 			hash: "$fbscrypt$v=1,n=14,r=8,p=1,ss=Bw==,sk=ou9tdYTGyYm8kuR6Dt0Bp0kDuAYoXrK16mbZO4yGwAn3oLspjnN0/c41v8xZnO1n14J3MjKj1b2g6AUCAlFwMw==$C0sHCg9ek77hsg==$ZGlmZmVyZW50aGFzaA==",
 		},
+		{
+			desc: "Valid Django PBKDF2 hash",
+			hash: "pbkdf2_sha256$1000$seasalt123$ZO7bxTYpraRtIypOjUtO6D950mGJEU4QfwlZxcI4klo=",
+		},
+		{
+			desc: "Valid PHC scrypt hash",
+			hash: "$scrypt$ln=4,r=8,p=1$MDEyMzQ1Njc4OWFiY2RlZg$UAzMStjVIRWO15kJMZknPqstv1zxFq97yqLiS6CLGbo",
+		},
+		{
+			desc: "Valid ASP.NET Identity v3 hash",
+			hash: "AQAAAAEAAAPoAAAAEDAxMjM0NTY3ODlhYmNkZWbVUeK7/fUzgnZMzKomGZCxl/k7uVgVOfVw7xb1rjEqIg==",
+		},
 	}
 
 	for _, c := range cases {
@@ -417,6 +454,10 @@ func (ts *UserTestSuite) TestNewUserWithPasswordHashFailure() {
 			desc: "Invalid scrypt hash",
 			hash: "$fbscrypt$invalid",
 		},
+		{
+			desc: "Invalid PBKDF2 hash",
+			hash: "pbkdf2_sha256$invalid",
+		},
 	}
 
 	for _, c := range cases {
@@ -429,39 +470,87 @@ func (ts *UserTestSuite) TestNewUserWithPasswordHashFailure() {
 }
 
 func (ts *UserTestSuite) TestAuthenticate() {
+	defer func(cost crypto.HashCost, hashing crypto.PasswordHashingParameters) {
+		crypto.PasswordHashCost = cost
+		crypto.PasswordHashing = hashing
+	}(crypto.PasswordHashCost, crypto.PasswordHashing)
+
+	crypto.PasswordHashCost = crypto.DefaultHashCost
+
 	// every case uses "test" as the password
 	cases := []struct {
-		desc             string
-		hash             string
-		expectedHashCost int
+		desc                 string
+		hash                 string
+		hashing              crypto.PasswordHashingParameters
+		expectedShouldUpdate bool
 	}{
 		{
-			desc:             "Invalid bcrypt hash cost of 11",
-			hash:             "$2y$11$4lH57PU7bGATpRcx93vIoObH3qDmft/pytbOzDG9/1WsyNmN5u4di",
-			expectedHashCost: bcrypt.MinCost,
+			desc:                 "Invalid bcrypt hash cost of 11",
+			hash:                 "$2y$11$4lH57PU7bGATpRcx93vIoObH3qDmft/pytbOzDG9/1WsyNmN5u4di",
+			hashing:              crypto.PasswordHashingParameters{Algorithm: crypto.BcryptAlgorithm, BcryptCost: bcrypt.DefaultCost},
+			expectedShouldUpdate: true,
+		},
+		{
+			desc:                 "Valid bcrypt hash cost of 10",
+			hash:                 "$2y$10$va66S4MxFrH6G6L7BzYl0.QgcYgvSr/F92gc.3botlz7bG4p/g/1i",
+			hashing:              crypto.PasswordHashingParameters{Algorithm: crypto.BcryptAlgorithm, BcryptCost: bcrypt.DefaultCost},
+			expectedShouldUpdate: false,
 		},
 		{
-			desc:             "Valid bcrypt hash cost of 10",
-			hash:             "$2y$10$va66S4MxFrH6G6L7BzYl0.QgcYgvSr/F92gc.3botlz7bG4p/g/1i",
-			expectedHashCost: bcrypt.DefaultCost,
+			desc:                 "Bcrypt hash with argon2id configured",
+			hash:                 "$2y$10$va66S4MxFrH6G6L7BzYl0.QgcYgvSr/F92gc.3botlz7bG4p/g/1i",
+			hashing:              crypto.PasswordHashingParameters{Algorithm: crypto.Argon2idAlgorithm, Argon2Memory: 64, Argon2Time: 1, Argon2Threads: 1},
+			expectedShouldUpdate: true,
 		},
 	}
 
 	for _, c := range cases {
 		ts.Run(c.desc, func() {
+			crypto.PasswordHashing = c.hashing
+
 			u, err := NewUserWithPasswordHash("", "", c.hash, "", nil)
 			require.NoError(ts.T(), err)
 			require.NoError(ts.T(), ts.db.Create(u))
 			require.NotNil(ts.T(), u)
 
-			isAuthenticated, _, err := u.Authenticate(context.Background(), ts.db, "test", nil, false, "")
+			isAuthenticated, shouldUpdate, err := u.Authenticate(context.Background(), ts.db, "test", nil, false, "")
 			require.NoError(ts.T(), err)
 			require.True(ts.T(), isAuthenticated)
+			require.Equal(ts.T(), c.expectedShouldUpdate, shouldUpdate)
 
-			// check hash cost
-			hashCost, err := bcrypt.Cost([]byte(*u.EncryptedPassword))
+			isAuthenticated, shouldUpdate, err = u.Authenticate(context.Background(), ts.db, "wrong", nil, false, "")
 			require.NoError(ts.T(), err)
-			require.Equal(ts.T(), c.expectedHashCost, hashCost)
+			require.False(ts.T(), isAuthenticated)
+			require.False(ts.T(), shouldUpdate)
 		})
 	}
 }
+
+func (ts *UserTestSuite) TestScheduledDeletion() {
+	u := ts.createUserWithEmail("deleted@example.com")
+	now := time.Now()
+
+	require.NoError(ts.T(), u.ScheduleDeletion(ts.db, now.Add(time.Hour)))
+	users, err := FindUsersDueForDeletion(ts.db, now, 10)
+	require.NoError(ts.T(), err)
+	require.Empty(ts.T(), users)
+
+	require.NoError(ts.T(), u.ScheduleDeletion(ts.db, now.Add(-time.Hour)))
+	users, err = FindUsersDueForDeletion(ts.db, now, 10)
+	require.NoError(ts.T(), err)
+	require.Len(ts.T(), users, 1)
+	require.Equal(ts.T(), u.ID, users[0].ID)
+
+	claimed, err := ClaimUserDeletion(ts.db, u.ID, now)
+	require.NoError(ts.T(), err)
+	require.NotNil(ts.T(), claimed)
+
+	require.NoError(ts.T(), u.CancelDeletion(ts.db))
+	users, err = FindUsersDueForDeletion(ts.db, now, 10)
+	require.NoError(ts.T(), err)
+	require.Empty(ts.T(), users)
+
+	claimed, err = ClaimUserDeletion(ts.db, u.ID, now)
+	require.NoError(ts.T(), err)
+	require.Nil(ts.T(), claimed)
+}
@@ -1,4 +1,93 @@
 package models
 
+import (
+	"database/sql"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/crypto"
+	"github.com/supabase/auth/internal/storage"
+)
+
 const Web3Provider = "web3"
 const Web3Grant = "web3"
+
+// Web3Nonce is a single use nonce issued for a web3 sign in message. It can
+// only be used from the IP address it was issued to, before it expires.
+// Consumed nonces are kept until they expire, so that replayed messages can
+// be told apart from messages with nonces not issued by the server. Nonces
+// chosen by clients are recorded as consumed when they're used, so that they
+// can't be used again either.
+type Web3Nonce struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	Nonce      string     `json:"nonce" db:"nonce"`
+	Chain      string     `json:"chain" db:"chain"`
+	IPAddress  string     `json:"ip_address" db:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
+	ConsumedAt *time.Time `json:"consumed_at,omitempty" db:"consumed_at"`
+}
+
+func (Web3Nonce) TableName() string {
+	tableName := "web3_nonces"
+	return tableName
+}
+
+// NewWeb3Nonce creates a random nonce for the chain, valid for the duration.
+// SIWE requires nonces to be at least 8 alphanumeric characters.
+func NewWeb3Nonce(chain, ipAddress string, now time.Time, validity time.Duration) *Web3Nonce {
+	return &Web3Nonce{
+		ID:        uuid.Must(uuid.NewV4()),
+		Nonce:     crypto.SecureAlphanumeric(32),
+		Chain:     chain,
+		IPAddress: ipAddress,
+		CreatedAt: now,
+		ExpiresAt: now.Add(validity),
+	}
+}
+
+// FindWeb3Nonce finds a nonce, whether it's been consumed or not.
+func FindWeb3Nonce(tx *storage.Connection, nonce string) (*Web3Nonce, error) {
+	var n Web3Nonce
+
+	if err := tx.Q().Where("nonce = ?", nonce).First(&n); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, Web3NonceNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error finding web3 nonce")
+	}
+
+	return &n, nil
+}
+
+// ConsumeWeb3Nonce atomically marks the nonce as consumed, if it was issued
+// for the chain to the IP address, has not expired and has not been consumed
+// yet. It reports whether the nonce was consumed.
+func ConsumeWeb3Nonce(tx *storage.Connection, chain, nonce, ipAddress string, now time.Time) (bool, error) {
+	count, err := tx.RawQuery(
+		"update "+(&Web3Nonce{}).TableName()+" set consumed_at = ? where nonce = ? and chain = ? and ip_address = ? and expires_at > ? and consumed_at is null",
+		now, nonce, chain, ipAddress, now,
+	).ExecWithCount()
+	if err != nil {
+		return false, errors.Wrap(err, "error consuming web3 nonce")
+	}
+
+	return count > 0, nil
+}
+
+// RecordWeb3Nonce records a nonce that wasn't issued by the server as
+// consumed, until the signed message it was used in expires. It reports
+// whether the nonce was recorded, which is false if it was already used.
+func RecordWeb3Nonce(tx *storage.Connection, chain, nonce, ipAddress string, now, expiresAt time.Time) (bool, error) {
+	count, err := tx.RawQuery(
+		"insert into "+(&Web3Nonce{}).TableName()+" (id, nonce, chain, ip_address, created_at, expires_at, consumed_at) values (?, ?, ?, ?, ?, ?, ?) on conflict (nonce) do nothing",
+		uuid.Must(uuid.NewV4()), nonce, chain, ipAddress, now, expiresAt, now,
+	).ExecWithCount()
+	if err != nil {
+		return false, errors.Wrap(err, "error recording web3 nonce")
+	}
+
+	return count > 0, nil
+}
@@ -13,6 +13,7 @@ import (
 	"fmt"
 
 	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/conf"
 	"github.com/supabase/auth/internal/utilities"
 )
 
@@ -28,6 +29,10 @@ type VerificationResponse struct {
 	Success    bool     `json:"success"`
 	ErrorCodes []string `json:"error-codes"`
 	Hostname   string   `json:"hostname"`
+
+	// Score and Action are only returned by reCAPTCHA v3.
+	Score  float64 `json:"score"`
+	Action string  `json:"action"`
 }
 
 var Client *http.Client
@@ -46,14 +51,24 @@ func init() {
 	Client = &http.Client{Timeout: defaultTimeout}
 }
 
-func VerifyRequest(requestBody *GotrueRequest, clientIP, secretKey, captchaProvider string) (VerificationResponse, error) {
+func VerifyRequest(requestBody *GotrueRequest, clientIP string, config *conf.CaptchaConfiguration) (VerificationResponse, error) {
 	captchaResponse := strings.TrimSpace(requestBody.Security.Token)
 
 	if captchaResponse == "" {
 		return VerificationResponse{}, errors.New("no captcha response (captcha_token) found in request")
 	}
 
-	captchaURL, err := GetCaptchaURL(captchaProvider)
+	secretKey := strings.TrimSpace(config.Secret)
+
+	switch config.Provider {
+	case conf.CaptchaProviderGeeTest:
+		return verifyGeeTest(captchaResponse, config.AppID, secretKey)
+
+	case conf.CaptchaProviderTencent:
+		return verifyTencentCaptcha(captchaResponse, clientIP, config)
+	}
+
+	captchaURL, err := GetCaptchaURL(config.Provider)
 	if err != nil {
 		return VerificationResponse{}, err
 	}
@@ -95,6 +110,8 @@ func GetCaptchaURL(captchaProvider string) (string, error) {
 		return "https://hcaptcha.com/siteverify", nil
 	case "turnstile":
 		return "https://challenges.cloudflare.com/turnstile/v0/siteverify", nil
+	case "recaptcha", "recaptcha_v3":
+		return "https://www.google.com/recaptcha/api/siteverify", nil
 	default:
 		return "", fmt.Errorf("captcha Provider %q could not be found", captchaProvider)
 	}
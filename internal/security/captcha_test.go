@@ -0,0 +1,116 @@
+package security
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/auth/internal/conf"
+)
+
+func TestVerifyGeeTest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		require.Equal(t, "captcha-id", r.URL.Query().Get("captcha_id"))
+		require.Equal(t, "lot", r.PostForm.Get("lot_number"))
+		// HMAC-SHA256 of the lot number with the captcha key
+		require.Len(t, r.PostForm.Get("sign_token"), 64)
+
+		result := "success"
+		if r.PostForm.Get("pass_token") != "pass" {
+			result = "fail"
+		}
+
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]string{
+			"status": "success",
+			"result": result,
+			"reason": "pass_token expired",
+		}))
+	}))
+	defer server.Close()
+
+	defer func(url string) { geeTestValidateURL = url }(geeTestValidateURL)
+	geeTestValidateURL = server.URL
+
+	config := &conf.CaptchaConfiguration{
+		Enabled:  true,
+		Provider: conf.CaptchaProviderGeeTest,
+		AppID:    "captcha-id",
+		Secret:   "captcha-key",
+	}
+
+	token := func(passToken string) *GotrueRequest {
+		return &GotrueRequest{Security: GotrueSecurity{
+			Token: `{"lot_number":"lot","captcha_output":"output","pass_token":"` + passToken + `","gen_time":"1700000000"}`,
+		}}
+	}
+
+	res, err := VerifyRequest(token("pass"), "127.0.0.1", config)
+	require.NoError(t, err)
+	require.True(t, res.Success)
+
+	res, err = VerifyRequest(token("expired"), "127.0.0.1", config)
+	require.NoError(t, err)
+	require.False(t, res.Success)
+	require.Equal(t, []string{"pass_token expired"}, res.ErrorCodes)
+
+	res, err = VerifyRequest(&GotrueRequest{Security: GotrueSecurity{Token: "not-json"}}, "127.0.0.1", config)
+	require.NoError(t, err)
+	require.False(t, res.Success)
+}
+
+func TestVerifyTencentCaptcha(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "TC3-HMAC-SHA256 Credential=secret-id/"))
+		require.Equal(t, "DescribeCaptchaResult", r.Header.Get("X-TC-Action"))
+
+		var body tencentCaptchaRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		require.Equal(t, uint64(190000000), body.CaptchaAppId)
+		require.Equal(t, "app-secret-key", body.AppSecretKey)
+		require.Equal(t, "127.0.0.1", body.UserIp)
+
+		code := 1
+		if body.Ticket != "ticket" {
+			code = 9
+		}
+
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{
+			"Response": map[string]interface{}{
+				"CaptchaCode": code,
+				"CaptchaMsg":  "verify ticket timeout",
+			},
+		}))
+	}))
+	defer server.Close()
+
+	defer func(url string) { tencentCaptchaURL = url }(tencentCaptchaURL)
+	tencentCaptchaURL = server.URL
+
+	config := &conf.CaptchaConfiguration{
+		Enabled:          true,
+		Provider:         conf.CaptchaProviderTencent,
+		AppID:            "190000000",
+		Secret:           "app-secret-key",
+		TencentSecretID:  "secret-id",
+		TencentSecretKey: "secret-key",
+	}
+
+	token := func(ticket string) *GotrueRequest {
+		return &GotrueRequest{Security: GotrueSecurity{
+			Token: `{"ticket":"` + ticket + `","randstr":"rand"}`,
+		}}
+	}
+
+	res, err := VerifyRequest(token("ticket"), "127.0.0.1", config)
+	require.NoError(t, err)
+	require.True(t, res.Success)
+
+	res, err = VerifyRequest(token("expired"), "127.0.0.1", config)
+	require.NoError(t, err)
+	require.False(t, res.Success)
+	require.Equal(t, []string{"9 verify ticket timeout"}, res.ErrorCodes)
+}
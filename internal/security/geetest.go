@@ -0,0 +1,85 @@
+package security
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/utilities"
+)
+
+// geeTestValidateURL is the GeeTest v4 secondary validation endpoint.
+var geeTestValidateURL = "https://gcaptcha4.geetest.com/validate"
+
+// geeTestToken is the result of the GeeTest v4 client's getValidate(),
+// which clients send JSON encoded as the captcha token.
+type geeTestToken struct {
+	LotNumber     string `json:"lot_number"`
+	CaptchaOutput string `json:"captcha_output"`
+	PassToken     string `json:"pass_token"`
+	GenTime       string `json:"gen_time"`
+}
+
+type geeTestResponse struct {
+	Status string `json:"status"`
+	Result string `json:"result"`
+	Reason string `json:"reason"`
+	Code   string `json:"code"`
+	Msg    string `json:"msg"`
+}
+
+func verifyGeeTest(token, captchaID, captchaKey string) (VerificationResponse, error) {
+	var t geeTestToken
+	if err := json.Unmarshal([]byte(token), &t); err != nil || t.LotNumber == "" {
+		return VerificationResponse{
+			ErrorCodes: []string{"invalid-input-response"},
+		}, nil
+	}
+
+	data := url.Values{}
+	data.Set("lot_number", t.LotNumber)
+	data.Set("captcha_output", t.CaptchaOutput)
+	data.Set("pass_token", t.PassToken)
+	data.Set("gen_time", t.GenTime)
+	data.Set("sign_token", hex.EncodeToString(hmacSHA256([]byte(captchaKey), t.LotNumber)))
+
+	r, err := http.NewRequest(http.MethodPost, geeTestValidateURL+"?"+url.Values{"captcha_id": {captchaID}}.Encode(), strings.NewReader(data.Encode()))
+	if err != nil {
+		return VerificationResponse{}, errors.Wrap(err, "couldn't initialize request object for captcha check")
+	}
+	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := Client.Do(r)
+	if err != nil {
+		return VerificationResponse{}, errors.Wrap(err, "failed to verify captcha response")
+	}
+	defer utilities.SafeClose(res.Body)
+
+	var response geeTestResponse
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return VerificationResponse{}, errors.Wrap(err, "failed to decode captcha response: not JSON")
+	}
+
+	if response.Status != "success" {
+		return VerificationResponse{}, errors.Errorf("captcha verification failed: %s (%s)", response.Msg, response.Code)
+	}
+
+	if response.Result != "success" {
+		return VerificationResponse{
+			ErrorCodes: []string{response.Reason},
+		}, nil
+	}
+
+	return VerificationResponse{Success: true}, nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
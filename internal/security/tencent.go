@@ -0,0 +1,133 @@
+package security
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/conf"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
+	tcerr "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/errors"
+	tchttp "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/http"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/profile"
+)
+
+// tencentCaptchaURL is the Tencent Cloud API endpoint of Tencent Captcha.
+var tencentCaptchaURL = "https://captcha.tencentcloudapi.com"
+
+const (
+	tencentCaptchaService = "captcha"
+	tencentCaptchaAction  = "DescribeCaptchaResult"
+	tencentCaptchaVersion = "2019-07-22"
+)
+
+// tencentCaptchaToken is the ticket and random string returned by the
+// Tencent Captcha client, which clients send JSON encoded as the captcha
+// token.
+type tencentCaptchaToken struct {
+	Ticket  string `json:"ticket"`
+	Randstr string `json:"randstr"`
+}
+
+type tencentCaptchaRequest struct {
+	CaptchaType  uint64
+	Ticket       string
+	UserIp       string
+	Randstr      string
+	CaptchaAppId uint64
+	AppSecretKey string
+}
+
+type tencentCaptchaResponse struct {
+	Response struct {
+		CaptchaCode int64
+		CaptchaMsg  string
+		EvilLevel   int64
+		Error       *struct {
+			Code    string
+			Message string
+		}
+	}
+}
+
+func verifyTencentCaptcha(token, clientIP string, config *conf.CaptchaConfiguration) (VerificationResponse, error) {
+	var t tencentCaptchaToken
+	if err := json.Unmarshal([]byte(token), &t); err != nil || t.Ticket == "" {
+		return VerificationResponse{
+			ErrorCodes: []string{"invalid-input-response"},
+		}, nil
+	}
+
+	appID, err := strconv.ParseUint(config.AppID, 10, 64)
+	if err != nil {
+		return VerificationResponse{}, errors.Wrap(err, "invalid Tencent CaptchaAppId")
+	}
+
+	payload, err := json.Marshal(&tencentCaptchaRequest{
+		CaptchaType:  9,
+		Ticket:       t.Ticket,
+		UserIp:       clientIP,
+		Randstr:      t.Randstr,
+		CaptchaAppId: appID,
+		AppSecretKey: config.Secret,
+	})
+	if err != nil {
+		return VerificationResponse{}, err
+	}
+
+	client, err := newTencentCaptchaClient(config.TencentSecretID, config.TencentSecretKey)
+	if err != nil {
+		return VerificationResponse{}, errors.Wrap(err, "couldn't initialize client for captcha check")
+	}
+
+	request := tchttp.NewCommonRequest(tencentCaptchaService, tencentCaptchaVersion, tencentCaptchaAction)
+	if err := request.SetActionParameters(payload); err != nil {
+		return VerificationResponse{}, errors.Wrap(err, "couldn't initialize request object for captcha check")
+	}
+
+	res := tchttp.NewCommonResponse()
+	if err := client.Send(request, res); err != nil {
+		var serr *tcerr.TencentCloudSDKError
+		if errors.As(err, &serr) {
+			return VerificationResponse{}, errors.Errorf("captcha verification failed: %s (%s)", serr.Message, serr.Code)
+		}
+		return VerificationResponse{}, errors.Wrap(err, "failed to verify captcha response")
+	}
+
+	var response tencentCaptchaResponse
+	if err := json.Unmarshal(res.GetBody(), &response); err != nil {
+		return VerificationResponse{}, errors.Wrap(err, "failed to decode captcha response: not JSON")
+	}
+
+	if response.Response.CaptchaCode != 1 {
+		return VerificationResponse{
+			ErrorCodes: []string{fmt.Sprintf("%d %s", response.Response.CaptchaCode, response.Response.CaptchaMsg)},
+		}, nil
+	}
+
+	return VerificationResponse{Success: true}, nil
+}
+
+// newTencentCaptchaClient returns a Tencent Cloud API client of the
+// captcha endpoint, which signs requests with TC3-HMAC-SHA256.
+func newTencentCaptchaClient(secretID, secretKey string) (*common.Client, error) {
+	u, err := url.Parse(tencentCaptchaURL)
+	if err != nil {
+		return nil, err
+	}
+
+	cpf := profile.NewClientProfile()
+	cpf.HttpProfile.Scheme = strings.ToUpper(u.Scheme)
+	cpf.HttpProfile.Endpoint = u.Host
+	cpf.HttpProfile.ReqTimeout = int(Client.Timeout.Seconds())
+
+	client := common.NewCommonClient(common.NewCredential(secretID, secretKey), "", cpf)
+	if Client.Transport != nil {
+		client.WithHttpTransport(Client.Transport)
+	}
+
+	return client, nil
+}
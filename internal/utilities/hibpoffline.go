@@ -0,0 +1,234 @@
+package utilities
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1" // #nosec G505 -- the Pwned Passwords dataset uses SHA-1
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bits-and-blooms/bloom/v3"
+)
+
+// hibpFilterMagic identifies files built with BuildHIBPFilter.
+const hibpFilterMagic = "GOTRUE-HIBP-FILTER-1\n"
+
+// HIBPChecker checks whether a password is in the Pwned Passwords dataset.
+type HIBPChecker interface {
+	Check(ctx context.Context, password string) (bool, error)
+}
+
+// LoadHIBPOffline loads a local copy of the Pwned Passwords dataset. If path
+// is a directory it is expected to hold the range files as downloaded by the
+// official downloader (one <PREFIX>.txt file per 5 character hash prefix),
+// otherwise it is expected to be a filter built with BuildHIBPFilter.
+func LoadHIBPOffline(path string) (HIBPChecker, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		return &HIBPRangeFiles{Dir: path}, nil
+	}
+
+	f, err := os.Open(path) // #nosec G304
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadHIBPFilter(bufio.NewReader(f))
+}
+
+func hibpHash(password string) []byte {
+	sum := sha1.Sum([]byte(password)) // #nosec G401
+
+	hash := make([]byte, hibpHashLength)
+	hex.Encode(hash, sum[:])
+
+	return bytes.ToUpper(hash)
+}
+
+// HIBPRangeFiles looks up passwords in a directory of range files, reading
+// only the file for the hash prefix of the password like the online range
+// API does.
+type HIBPRangeFiles struct {
+	Dir string
+}
+
+func (r *HIBPRangeFiles) Check(ctx context.Context, password string) (bool, error) {
+	hash := hibpHash(password)
+	prefix, suffix := hash[:hibpHashPrefixLength], hash[hibpHashPrefixLength:]
+
+	f, err := os.Open(filepath.Join(r.Dir, string(prefix)+".txt")) // #nosec G304
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	found := false
+	err = scanHIBPLines(f, func(lineSuffix []byte, count uint64) bool {
+		if bytes.Equal(lineSuffix, suffix) {
+			found = count > 0
+			return false
+		}
+		return true
+	})
+
+	return found, err
+}
+
+// scanHIBPLines calls fn for each SUFFIX:COUNT line, until fn returns false.
+func scanHIBPLines(r io.Reader, fn func(hash []byte, count uint64) bool) error {
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+
+		hash, countBytes, ok := bytes.Cut(line, []byte(":"))
+		if !ok || len(hash) == 0 {
+			continue
+		}
+
+		count, err := strconv.ParseUint(string(countBytes), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		if !fn(bytes.ToUpper(hash), count) {
+			return nil
+		}
+	}
+
+	return scanner.Err()
+}
+
+// HIBPFilter is a bloom filter of all hashes in the Pwned Passwords dataset.
+// It's much smaller than the dataset, at the cost of a false positive rate
+// chosen when building it.
+type HIBPFilter struct {
+	filter *bloom.BloomFilter
+}
+
+func (f *HIBPFilter) Check(ctx context.Context, password string) (bool, error) {
+	return f.filter.Test(hibpHash(password)), nil
+}
+
+func (f *HIBPFilter) Cap() uint {
+	return f.filter.Cap()
+}
+
+// ReadHIBPFilter reads a filter written by BuildHIBPFilter.
+func ReadHIBPFilter(r io.Reader) (*HIBPFilter, error) {
+	magic := make([]byte, len(hibpFilterMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != hibpFilterMagic {
+		return nil, errors.New("hibp: not a pwned passwords filter file")
+	}
+
+	filter := &bloom.BloomFilter{}
+	if _, err := filter.ReadFrom(r); err != nil {
+		return nil, fmt.Errorf("hibp: unable to read pwned passwords filter: %w", err)
+	}
+
+	return &HIBPFilter{filter: filter}, nil
+}
+
+// HIBPBuildOptions configure BuildHIBPFilter.
+type HIBPBuildOptions struct {
+	// FalsePositives is the target false positive rate of the filter.
+	FalsePositives float64
+
+	// MinCount excludes passwords seen fewer times than this in breaches.
+	MinCount uint64
+}
+
+// BuildHIBPFilter compiles a Pwned Passwords dataset into a filter that can be
+// loaded with LoadHIBPOffline. input is either a directory of range files or a
+// single file with HASH:COUNT lines, such as the SHA-1 "ordered by hash"
+// download. It returns the number of hashes added.
+func BuildHIBPFilter(input string, output io.Writer, opts HIBPBuildOptions) (uint, error) {
+	var n uint
+
+	// the filter needs to be sized up front, so the dataset is read twice
+	if err := walkHIBPDataset(input, opts.MinCount, func([]byte) { n++ }); err != nil {
+		return 0, err
+	}
+
+	if n == 0 {
+		return 0, errors.New("hibp: no hashes found in the dataset")
+	}
+
+	filter := bloom.NewWithEstimates(n, opts.FalsePositives)
+	if err := walkHIBPDataset(input, opts.MinCount, func(hash []byte) { filter.Add(hash) }); err != nil {
+		return 0, err
+	}
+
+	if _, err := io.WriteString(output, hibpFilterMagic); err != nil {
+		return 0, err
+	}
+
+	if _, err := filter.WriteTo(output); err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// walkHIBPDataset calls fn with every full hex-encoded hash in the dataset
+// seen at least minCount times.
+func walkHIBPDataset(input string, minCount uint64, fn func(hash []byte)) error {
+	info, err := os.Stat(input)
+	if err != nil {
+		return err
+	}
+
+	readFile := func(path string, prefix []byte) error {
+		f, err := os.Open(path) // #nosec G304
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		return scanHIBPLines(f, func(hash []byte, count uint64) bool {
+			if count > 0 && count >= minCount {
+				full := append(append(make([]byte, 0, hibpHashLength), prefix...), hash...)
+				if len(full) == hibpHashLength {
+					fn(full)
+				}
+			}
+			return true
+		})
+	}
+
+	if !info.IsDir() {
+		return readFile(input, nil)
+	}
+
+	entries, err := os.ReadDir(input)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		prefix := strings.ToUpper(strings.TrimSuffix(name, ".txt"))
+
+		if entry.IsDir() || !strings.HasSuffix(name, ".txt") || len(prefix) != hibpHashPrefixLength {
+			continue
+		}
+
+		if err := readFile(filepath.Join(input, name), []byte(prefix)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package utilities
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	tst "testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeHIBPRangeFiles(t *tst.T) string {
+	dir := t.TempDir()
+
+	// SHA-1 of "password" is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8 and
+	// of "hunter2" is F3BBBD66A63D4BF1747940578EC3D0103530E21D
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "5BAA6.txt"), []byte(
+		"003D68EB55068C33ACE09247EE4C639306B:3\r\n"+
+			"1E4C9B93F3F0682250B6CF8331B7EE68FD8:10434004\r\n"+
+			"1E4C9B93F3F0682250B6CF8331B7EE68FD9:0\r\n",
+	), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "F3BBB.txt"), []byte(
+		"D66A63D4BF1747940578EC3D0103530E21D:1\r\n",
+	), 0o600))
+
+	return dir
+}
+
+func TestHIBPRangeFiles(t *tst.T) {
+	dir := writeHIBPRangeFiles(t)
+
+	checker, err := LoadHIBPOffline(dir)
+	require.NoError(t, err)
+	require.IsType(t, &HIBPRangeFiles{}, checker)
+
+	pwned, err := checker.Check(context.Background(), "password")
+	require.NoError(t, err)
+	require.True(t, pwned)
+
+	pwned, err = checker.Check(context.Background(), "hunter2")
+	require.NoError(t, err)
+	require.True(t, pwned)
+
+	// no range file for the prefix
+	_, err = checker.Check(context.Background(), "correct horse battery staple")
+	require.Error(t, err)
+}
+
+func TestHIBPFilter(t *tst.T) {
+	dir := writeHIBPRangeFiles(t)
+
+	ordered := filepath.Join(t.TempDir(), "ordered.txt")
+	require.NoError(t, os.WriteFile(ordered, []byte(
+		"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:10434004\n"+
+			"F3BBBD66A63D4BF1747940578EC3D0103530E21D:1\n",
+	), 0o600))
+
+	for input, expected := range map[string]uint{dir: 3, ordered: 2} {
+		var buf bytes.Buffer
+
+		n, err := BuildHIBPFilter(input, &buf, HIBPBuildOptions{FalsePositives: 0.0001})
+		require.NoError(t, err)
+		require.Equal(t, expected, n, input)
+
+		filter, err := ReadHIBPFilter(&buf)
+		require.NoError(t, err)
+
+		for _, password := range []string{"password", "hunter2"} {
+			pwned, err := filter.Check(context.Background(), password)
+			require.NoError(t, err)
+			require.True(t, pwned, password)
+		}
+
+		pwned, err := filter.Check(context.Background(), "correct horse battery staple")
+		require.NoError(t, err)
+		require.False(t, pwned)
+	}
+
+	// passwords seen fewer than min count times are excluded
+	output := filepath.Join(t.TempDir(), "filter.bin")
+	f, err := os.Create(output)
+	require.NoError(t, err)
+
+	n, err := BuildHIBPFilter(ordered, f, HIBPBuildOptions{FalsePositives: 0.0001, MinCount: 2})
+	require.NoError(t, err)
+	require.Equal(t, uint(1), n)
+	require.NoError(t, f.Close())
+
+	checker, err := LoadHIBPOffline(output)
+	require.NoError(t, err)
+
+	pwned, err := checker.Check(context.Background(), "hunter2")
+	require.NoError(t, err)
+	require.False(t, pwned)
+
+	_, err = ReadHIBPFilter(bytes.NewReader([]byte("not a filter")))
+	require.Error(t, err)
+}
@@ -0,0 +1,41 @@
+package siwe
+
+import (
+	"encoding/hex"
+	"regexp"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+var addressPattern = regexp.MustCompile("^0x[0-9a-fA-F]{40}$")
+
+func keccak256(data ...[]byte) []byte {
+	hash := sha3.NewLegacyKeccak256()
+	for _, d := range data {
+		hash.Write(d)
+	}
+
+	return hash.Sum(nil)
+}
+
+// ChecksumAddress returns the EIP-55 mixed case form of an Ethereum address.
+func ChecksumAddress(address string) string {
+	lower := strings.ToLower(strings.TrimPrefix(address, "0x"))
+	hash := hex.EncodeToString(keccak256([]byte(lower)))
+
+	checksummed := []byte(lower)
+	for i, c := range checksummed {
+		if c >= 'a' && c <= 'f' && hash[i] >= '8' {
+			checksummed[i] = c - 'a' + 'A'
+		}
+	}
+
+	return "0x" + string(checksummed)
+}
+
+// IsValidAddress reports whether the address is a 0x prefixed Ethereum
+// address with a valid EIP-55 checksum.
+func IsValidAddress(address string) bool {
+	return addressPattern.MatchString(address) && ChecksumAddress(address) == address
+}
@@ -0,0 +1,226 @@
+package siwe
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/supabase/auth/internal/utilities/siws"
+)
+
+// SIWEMessage is the final structured form of a parsed EIP-4361 Sign-In With
+// Ethereum message.
+type SIWEMessage struct {
+	Raw string
+
+	Scheme         string
+	Domain         string
+	Address        string
+	Statement      string
+	URI            *url.URL
+	Version        string
+	ChainID        uint64
+	Nonce          string
+	IssuedAt       time.Time
+	NotBefore      time.Time
+	RequestID      string
+	ExpirationTime time.Time
+	Resources      []*url.URL
+}
+
+const headerSuffix = " wants you to sign in with your Ethereum account:"
+
+var noncePattern = regexp.MustCompile("^[a-zA-Z0-9]{8,}$")
+
+func parseTimestamp(value string) (time.Time, bool) {
+	ts, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		ts, err = time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return time.Time{}, false
+		}
+	}
+
+	return ts, true
+}
+
+func ParseMessage(raw string) (*SIWEMessage, error) {
+	lines := strings.Split(raw, "\n")
+	if len(lines) < 6 {
+		return nil, errors.New("siwe: message needs at least 6 lines")
+	}
+
+	// Parse first line exactly
+	header := lines[0]
+	if !strings.HasSuffix(header, headerSuffix) {
+		return nil, fmt.Errorf("siwe: message first line does not end in %q", headerSuffix)
+	}
+
+	domain := strings.TrimSpace(strings.TrimSuffix(header, headerSuffix))
+
+	var scheme string
+	if s, d, found := strings.Cut(domain, "://"); found {
+		scheme, domain = s, d
+	}
+
+	if !siws.IsValidDomain(domain) {
+		return nil, errors.New("siwe: domain in first line of message is not valid")
+	}
+
+	address := strings.TrimSpace(lines[1])
+	if !addressPattern.MatchString(address) {
+		return nil, errors.New("siwe: wallet address is not a hex encoded Ethereum address")
+	}
+
+	if !IsValidAddress(address) {
+		return nil, errors.New("siwe: wallet address is not in EIP-55 checksum format")
+	}
+
+	msg := &SIWEMessage{
+		Raw:     raw,
+		Scheme:  scheme,
+		Domain:  domain,
+		Address: address,
+	}
+
+	if lines[2] != "" {
+		return nil, errors.New("siwe: third line must be empty")
+	}
+
+	startIndex := 3
+	if lines[3] != "" && lines[4] == "" {
+		msg.Statement = lines[3]
+		startIndex = 5
+	}
+
+	inResources := false
+	for i := startIndex; i < len(lines); i += 1 {
+		line := strings.TrimSpace(lines[i])
+
+		if inResources {
+			if strings.HasPrefix(line, "- ") {
+				resource := strings.TrimSpace(strings.TrimPrefix(line, "- "))
+
+				resourceURL, err := url.ParseRequestURI(resource)
+				if err != nil {
+					return nil, fmt.Errorf("siwe: Resource at position %d has invalid URI", len(msg.Resources))
+				}
+
+				msg.Resources = append(msg.Resources, resourceURL)
+				continue
+			} else {
+				inResources = false
+			}
+		}
+
+		if line == "Resources:" {
+			inResources = true
+			continue
+		}
+
+		if line == "" {
+			continue
+		}
+
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			return nil, fmt.Errorf("siwe: encountered unparsable line at index %d", i)
+		}
+
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "URI":
+			uri, err := url.ParseRequestURI(value)
+			if err != nil {
+				return nil, errors.New("siwe: URI is not valid")
+			}
+
+			msg.URI = uri
+
+		case "Version":
+			msg.Version = value
+
+		case "Chain ID":
+			chainID, err := strconv.ParseUint(value, 10, 64)
+			if err != nil || chainID == 0 {
+				return nil, errors.New("siwe: Chain ID is not valid")
+			}
+
+			msg.ChainID = chainID
+
+		case "Nonce":
+			if !noncePattern.MatchString(value) {
+				return nil, errors.New("siwe: Nonce must be at least 8 alphanumeric characters")
+			}
+
+			msg.Nonce = value
+
+		case "Issued At":
+			ts, ok := parseTimestamp(value)
+			if !ok {
+				return nil, errors.New("siwe: Issued At is not a valid ISO8601 timestamp")
+			}
+			msg.IssuedAt = ts
+
+		case "Expiration Time":
+			ts, ok := parseTimestamp(value)
+			if !ok {
+				return nil, errors.New("siwe: Expiration Time is not a valid ISO8601 timestamp")
+			}
+			msg.ExpirationTime = ts
+
+		case "Not Before":
+			ts, ok := parseTimestamp(value)
+			if !ok {
+				return nil, errors.New("siwe: Not Before is not a valid ISO8601 timestamp")
+			}
+			msg.NotBefore = ts
+
+		case "Request ID":
+			msg.RequestID = value
+		}
+	}
+
+	if msg.Version != "1" {
+		return nil, fmt.Errorf("siwe: Version value is not supported, expected 1 got %q", msg.Version)
+	}
+
+	if msg.IssuedAt.IsZero() {
+		return nil, errors.New("siwe: Issued At is not specified")
+	}
+
+	if msg.URI == nil {
+		return nil, errors.New("siwe: URI is not specified")
+	}
+
+	if msg.ChainID == 0 {
+		return nil, errors.New("siwe: Chain ID is not specified")
+	}
+
+	if msg.Nonce == "" {
+		return nil, errors.New("siwe: Nonce is not specified")
+	}
+
+	if !msg.ExpirationTime.IsZero() && msg.IssuedAt.After(msg.ExpirationTime) {
+		return nil, errors.New("siwe: Issued At is after Expiration Time")
+	}
+
+	if !msg.NotBefore.IsZero() && !msg.ExpirationTime.IsZero() {
+		if msg.NotBefore.After(msg.ExpirationTime) {
+			return nil, errors.New("siwe: Not Before is after Expiration Time")
+		}
+	}
+
+	return msg, nil
+}
+
+// Network returns the CAIP-2 identifier of the chain the message was signed
+// for, such as eip155:1 for Ethereum mainnet.
+func (m *SIWEMessage) Network() string {
+	return "eip155:" + strconv.FormatUint(m.ChainID, 10)
+}
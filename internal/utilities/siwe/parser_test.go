@@ -0,0 +1,130 @@
+package siwe
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testAddress = "0x7E5F4552091A69125d5DfCb7b8C2659029395Bdf"
+
+func TestParseMessage(t *testing.T) {
+	negativeExamples := []struct {
+		example string
+		error   string
+	}{
+		{
+			example: "",
+			error:   "message needs at least 6 lines",
+		},
+		{
+			example: "domain.com whatever\n\n\n\n\n\n",
+			error:   "message first line does not end in \" wants you to sign in with your Ethereum account:\"",
+		},
+		{
+			example: "******* wants you to sign in with your Ethereum account:\n\n\n\n\n\n",
+			error:   "domain in first line of message is not valid",
+		},
+		{
+			example: "domain.com wants you to sign in with your Ethereum account:\n4Cw1koUQtqybLFem7uqhzMBznMPGARbFS4cjaYbM9RnR\n\n\n\n\n",
+			error:   "wallet address is not a hex encoded Ethereum address",
+		},
+		{
+			example: "domain.com wants you to sign in with your Ethereum account:\n0x7e5f4552091a69125d5dfcb7b8c2659029395bdf\n\n\n\n\n",
+			error:   "wallet address is not in EIP-55 checksum format",
+		},
+		{
+			example: "domain.com wants you to sign in with your Ethereum account:\n" + testAddress + "\nURI: https://google.com\n\n\n",
+			error:   "third line must be empty",
+		},
+		{
+			example: "domain.com wants you to sign in with your Ethereum account:\n" + testAddress + "\n\nStatement\n\nNot Parsable\n",
+			error:   "encountered unparsable line at index 5",
+		},
+		{
+			example: "domain.com wants you to sign in with your Ethereum account:\n" + testAddress + "\n\nURI: https://domain.com\nVersion: 1\nChain ID: mainnet\nNonce: 12345678\nIssued At: 2025-01-01T00:00:00Z",
+			error:   "Chain ID is not valid",
+		},
+		{
+			example: "domain.com wants you to sign in with your Ethereum account:\n" + testAddress + "\n\nURI: https://domain.com\nVersion: 1\nChain ID: 1\nNonce: 1234\nIssued At: 2025-01-01T00:00:00Z",
+			error:   "Nonce must be at least 8 alphanumeric characters",
+		},
+		{
+			example: "domain.com wants you to sign in with your Ethereum account:\n" + testAddress + "\n\nURI: https://domain.com\nVersion: 1\nNonce: 12345678\nIssued At: 2025-01-01T00:00:00Z",
+			error:   "Chain ID is not specified",
+		},
+		{
+			example: "domain.com wants you to sign in with your Ethereum account:\n" + testAddress + "\n\nURI: https://domain.com\nVersion: 1\nChain ID: 1\nIssued At: 2025-01-01T00:00:00Z\n",
+			error:   "Nonce is not specified",
+		},
+		{
+			example: "domain.com wants you to sign in with your Ethereum account:\n" + testAddress + "\n\nURI: https://domain.com\nVersion: 2\nChain ID: 1\nNonce: 12345678\nIssued At: 2025-01-01T00:00:00Z",
+			error:   "Version value is not supported, expected 1 got \"2\"",
+		},
+		{
+			example: "domain.com wants you to sign in with your Ethereum account:\n" + testAddress + "\n\nVersion: 1\nChain ID: 1\nNonce: 12345678\nIssued At: 2025-01-01T00:00:00Z",
+			error:   "URI is not specified",
+		},
+		{
+			example: "domain.com wants you to sign in with your Ethereum account:\n" + testAddress + "\n\nURI: https://domain.com\nVersion: 1\nChain ID: 1\nNonce: 12345678\nIssued At: 2025-01-02T00:00:00Z\nExpiration Time: 2025-01-01T00:00:00Z",
+			error:   "Issued At is after Expiration Time",
+		},
+	}
+
+	for i, example := range negativeExamples {
+		_, err := ParseMessage(example.example)
+
+		t.Run(fmt.Sprintf("negative example %d", i), func(t *testing.T) {
+			require.NotNil(t, err)
+			require.Equal(t, "siwe: "+example.error, err.Error())
+		})
+	}
+
+	positiveExamples := []string{
+		"https://domain.com wants you to sign in with your Ethereum account:\n" + testAddress + "\n\nStatement\n\nURI: https://domain.com\nVersion: 1\nChain ID: 137\nNonce: 32891756\nIssued At: 2025-01-01T00:00:00Z\nRequest ID: abcdef\nResources:\n- https://domain.com/terms",
+		"domain.com wants you to sign in with your Ethereum account:\n" + testAddress + "\n\n\nURI: https://domain.com\nVersion: 1\nChain ID: 137\nNonce: 32891756\nIssued At: 2025-01-01T00:00:00Z\nRequest ID: abcdef",
+	}
+
+	for i, example := range positiveExamples {
+		t.Run(fmt.Sprintf("positive example %d", i), func(t *testing.T) {
+			parsed, err := ParseMessage(example)
+
+			require.Nil(t, err)
+			require.Equal(t, "domain.com", parsed.Domain)
+			require.Equal(t, testAddress, parsed.Address)
+
+			if i == 0 {
+				require.Equal(t, "https", parsed.Scheme)
+				require.Equal(t, "Statement", parsed.Statement)
+				require.Len(t, parsed.Resources, 1)
+			} else {
+				require.Equal(t, "", parsed.Scheme)
+				require.Equal(t, "", parsed.Statement)
+			}
+
+			require.Equal(t, "2025-01-01 00:00:00 +0000 UTC", parsed.IssuedAt.String())
+			require.Equal(t, "https://domain.com", parsed.URI.String())
+			require.Equal(t, uint64(137), parsed.ChainID)
+			require.Equal(t, "eip155:137", parsed.Network())
+			require.Equal(t, "32891756", parsed.Nonce)
+			require.Equal(t, "abcdef", parsed.RequestID)
+		})
+	}
+}
+
+func TestChecksumAddress(t *testing.T) {
+	// test vectors from EIP-55
+	for _, address := range []string{
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+		"0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359",
+		"0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB",
+		"0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb",
+	} {
+		require.Equal(t, address, ChecksumAddress(address))
+		require.True(t, IsValidAddress(address))
+	}
+
+	require.False(t, IsValidAddress("0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed"))
+	require.False(t, IsValidAddress("5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"))
+}
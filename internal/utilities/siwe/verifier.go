@@ -0,0 +1,190 @@
+package siwe
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+// HashMessage returns the EIP-191 hash of a message, as signed by the
+// personal_sign wallet method.
+func HashMessage(message string) []byte {
+	return keccak256([]byte("\x19Ethereum Signed Message:\n"+strconv.Itoa(len(message))), []byte(message))
+}
+
+// RecoverAddress recovers the checksummed address of the key that signed the
+// hash. The signature is in the 65 byte r || s || v format used by Ethereum
+// wallets, where v is 0, 1, 27 or 28.
+func RecoverAddress(hash, signature []byte) (string, error) {
+	if len(signature) != 65 {
+		return "", errors.New("siwe: signature must be 65 bytes")
+	}
+
+	v := signature[64]
+	if v >= 27 {
+		v -= 27
+	}
+
+	if v > 1 {
+		return "", errors.New("siwe: signature has an invalid recovery ID")
+	}
+
+	// decred expects <27 + recovery ID><r><s>
+	compact := make([]byte, 65)
+	compact[0] = 27 + v
+	copy(compact[1:], signature[:64])
+
+	publicKey, _, err := ecdsa.RecoverCompact(compact, hash)
+	if err != nil {
+		return "", fmt.Errorf("siwe: %w", err)
+	}
+
+	// the address is the last 20 bytes of the hash of the uncompressed
+	// public key, without its 0x04 prefix
+	return ChecksumAddress(hex.EncodeToString(keccak256(publicKey.SerializeUncompressed()[1:])[12:])), nil
+}
+
+// ContractVerifier verifies signatures of smart contract wallets, which can't
+// be recovered from the signature alone.
+type ContractVerifier interface {
+	// IsValidSignature reports whether the contract at address on the chain
+	// accepts the signature of the hash.
+	IsValidSignature(ctx context.Context, chainID uint64, address string, hash, signature []byte) (bool, error)
+}
+
+// VerifySignature verifies the signature of the message. Signatures of
+// externally owned accounts are recovered, and other signatures are checked
+// with the contract verifier, if there is one.
+func (m *SIWEMessage) VerifySignature(ctx context.Context, signature []byte, contracts ContractVerifier) (bool, error) {
+	hash := HashMessage(m.Raw)
+
+	if len(signature) == 65 {
+		if address, err := RecoverAddress(hash, signature); err == nil && address == m.Address {
+			return true, nil
+		}
+	}
+
+	if contracts == nil {
+		return false, nil
+	}
+
+	return contracts.IsValidSignature(ctx, m.ChainID, m.Address, hash, signature)
+}
+
+// eip1271MagicValue is returned by isValidSignature(bytes32,bytes) when the
+// signature is valid. It's also the function's selector.
+var eip1271MagicValue = []byte{0x16, 0x26, 0xba, 0x7e}
+
+// RPCContractVerifier implements EIP-1271 by calling isValidSignature on the
+// contract through the JSON-RPC endpoint of each chain. Signatures for chains
+// without an endpoint are invalid.
+type RPCContractVerifier struct {
+	HTTPClient *http.Client
+	URLs       map[uint64]string
+}
+
+type rpcRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      int           `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type rpcResponse struct {
+	Result string `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// encodeIsValidSignature ABI encodes a call to isValidSignature(bytes32,bytes).
+func encodeIsValidSignature(hash, signature []byte) []byte {
+	padded := (len(signature) + 31) / 32 * 32
+
+	data := make([]byte, 4+32+32+32+padded)
+	copy(data, eip1271MagicValue)
+	copy(data[4:36], hash)
+	// offset of the signature bytes, after the two head words
+	binary.BigEndian.PutUint64(data[36+24:68], 64)
+	binary.BigEndian.PutUint64(data[68+24:100], uint64(len(signature)))
+	copy(data[100:], signature)
+
+	return data
+}
+
+func (v *RPCContractVerifier) IsValidSignature(ctx context.Context, chainID uint64, address string, hash, signature []byte) (bool, error) {
+	rpcURL, ok := v.URLs[chainID]
+	if !ok {
+		return false, nil
+	}
+
+	body, err := json.Marshal(rpcRequest{
+		JSONRPC: "2.0",
+		ID:      1,
+		Method:  "eth_call",
+		Params: []interface{}{
+			map[string]string{
+				"to":   address,
+				"data": "0x" + hex.EncodeToString(encodeIsValidSignature(hash, signature)),
+			},
+			"latest",
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rpcURL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := v.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("siwe: JSON-RPC request for chain %d failed: %w", chainID, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("siwe: JSON-RPC request for chain %d failed with status %d", chainID, res.StatusCode)
+	}
+
+	var response rpcResponse
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return false, fmt.Errorf("siwe: JSON-RPC response for chain %d is invalid: %w", chainID, err)
+	}
+
+	if response.Error != nil {
+		// contracts revert on invalid signatures
+		if response.Error.Code == 3 || strings.Contains(response.Error.Message, "revert") {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("siwe: JSON-RPC request for chain %d failed: %s", chainID, response.Error.Message)
+	}
+
+	result, err := hex.DecodeString(strings.TrimPrefix(response.Result, "0x"))
+	if err != nil {
+		return false, fmt.Errorf("siwe: JSON-RPC result for chain %d is invalid: %w", chainID, err)
+	}
+
+	// the result is the magic value left aligned in a 32 byte word, accounts
+	// without code return no data
+	return len(result) >= 4 && bytes.Equal(result[:4], eip1271MagicValue), nil
+}
@@ -0,0 +1,116 @@
+package siwe
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/stretchr/testify/require"
+)
+
+// sign signs the message like personal_sign, returning r || s || v
+func sign(key *secp256k1.PrivateKey, message string) []byte {
+	compact := ecdsa.SignCompact(key, HashMessage(message), false)
+
+	signature := make([]byte, 65)
+	copy(signature, compact[1:])
+	signature[64] = compact[0]
+
+	return signature
+}
+
+func testMessage(t *testing.T, address string) *SIWEMessage {
+	message, err := ParseMessage("domain.com wants you to sign in with your Ethereum account:\n" + address + "\n\n\nURI: https://domain.com\nVersion: 1\nChain ID: 1\nNonce: 32891756\nIssued At: 2025-01-01T00:00:00Z")
+	require.NoError(t, err)
+
+	return message
+}
+
+func TestVerifySignature(t *testing.T) {
+	key := secp256k1.PrivKeyFromBytes([]byte{31: 1})
+	message := testMessage(t, testAddress)
+
+	signature := sign(key, message.Raw)
+
+	address, err := RecoverAddress(HashMessage(message.Raw), signature)
+	require.NoError(t, err)
+	require.Equal(t, testAddress, address)
+
+	valid, err := message.VerifySignature(context.Background(), signature, nil)
+	require.NoError(t, err)
+	require.True(t, valid)
+
+	// v as 0 or 1
+	signature[64] -= 27
+	valid, err = message.VerifySignature(context.Background(), signature, nil)
+	require.NoError(t, err)
+	require.True(t, valid)
+
+	otherKey := secp256k1.PrivKeyFromBytes([]byte{31: 2})
+	valid, err = message.VerifySignature(context.Background(), sign(otherKey, message.Raw), nil)
+	require.NoError(t, err)
+	require.False(t, valid)
+
+	_, err = RecoverAddress(HashMessage(message.Raw), signature[:64])
+	require.Error(t, err)
+}
+
+func TestRPCContractVerifier(t *testing.T) {
+	const contract = "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+	validSignature := []byte(strings.Repeat("\x01", 70))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string `json:"method"`
+			Params []json.RawMessage
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.Equal(t, "eth_call", req.Method)
+
+		var call struct {
+			To   string `json:"to"`
+			Data string `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(req.Params[0], &call))
+		require.Equal(t, contract, call.To)
+
+		data, err := hex.DecodeString(strings.TrimPrefix(call.Data, "0x"))
+		require.NoError(t, err)
+		require.Equal(t, eip1271MagicValue, data[:4])
+		require.Len(t, data, 4+32*3+96)
+
+		if strings.Contains(call.Data, hex.EncodeToString(validSignature)) {
+			fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"result":"0x1626ba7e00000000000000000000000000000000000000000000000000000000"}`)
+		} else {
+			fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"error":{"code":3,"message":"execution reverted"}}`)
+		}
+	}))
+	defer server.Close()
+
+	verifier := &RPCContractVerifier{
+		URLs: map[uint64]string{1: server.URL},
+	}
+
+	message := testMessage(t, contract)
+
+	valid, err := message.VerifySignature(context.Background(), validSignature, verifier)
+	require.NoError(t, err)
+	require.True(t, valid)
+
+	valid, err = message.VerifySignature(context.Background(), []byte(strings.Repeat("\x02", 70)), verifier)
+	require.NoError(t, err)
+	require.False(t, valid)
+
+	// no endpoint for the chain
+	message.ChainID = 10
+	valid, err = message.VerifySignature(context.Background(), validSignature, verifier)
+	require.NoError(t, err)
+	require.False(t, valid)
+}
@@ -0,0 +1,13 @@
+-- adds a table for mail templates managed in the database
+
+create table if not exists {{ index .Options "Namespace" }}.mail_templates (
+  type text primary key,
+  subject text null,
+  content_html text not null,
+  content_text text null,
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now(),
+  check (char_length(type) > 0)
+);
+
+comment on table {{ index .Options "Namespace" }}.mail_templates is 'Auth: Mail templates that take precedence over template files and URLs.';
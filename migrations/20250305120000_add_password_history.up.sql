@@ -0,0 +1,13 @@
+-- adds a table for previous password hashes, used to prevent password reuse
+-- and to enforce a maximum password age
+
+create table if not exists {{ index .Options "Namespace" }}.password_history (
+  id uuid not null primary key,
+  user_id uuid not null references {{ index .Options "Namespace" }}.users(id) on delete cascade,
+  encrypted_password text not null,
+  created_at timestamptz not null default now()
+);
+
+create index if not exists password_history_user_id_created_at_idx on {{ index .Options "Namespace" }}.password_history (user_id, created_at desc);
+
+comment on table {{ index .Options "Namespace" }}.password_history is 'Auth: Stores previous password hashes of users to prevent password reuse.';
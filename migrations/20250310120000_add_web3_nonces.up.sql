@@ -0,0 +1,17 @@
+-- adds a table for nonces issued for web3 sign in messages, used to prevent
+-- signed messages from being replayed
+
+create table if not exists {{ index .Options "Namespace" }}.web3_nonces (
+  id uuid not null primary key,
+  nonce text not null unique,
+  chain text not null,
+  ip_address text not null,
+  created_at timestamptz not null default now(),
+  expires_at timestamptz not null,
+  consumed_at timestamptz null
+);
+
+-- nonces, including the ones chosen by clients, are kept until they expire
+create index if not exists web3_nonces_expires_at_idx on {{ index .Options "Namespace" }}.web3_nonces (expires_at);
+
+comment on table {{ index .Options "Namespace" }}.web3_nonces is 'Auth: Stores nonces issued for web3 sign in messages to prevent replay attacks.';
@@ -0,0 +1,27 @@
+-- adds a table linking sessions to the SAML NameID and SessionIndex they were
+-- created with, used to find the sessions to end on SAML Single Logout
+
+create table if not exists {{ index .Options "Namespace" }}.saml_sessions (
+  id uuid not null primary key,
+  session_id uuid null,
+  sso_provider_id uuid not null,
+  flow_state_id uuid null,
+  name_id text not null,
+  name_id_format text null,
+  session_index text null,
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now(),
+  foreign key (session_id) references {{ index .Options "Namespace" }}.sessions (id) on delete cascade,
+  foreign key (sso_provider_id) references {{ index .Options "Namespace" }}.sso_providers (id) on delete cascade,
+  foreign key (flow_state_id) references {{ index .Options "Namespace" }}.flow_state (id) on delete set null
+);
+
+create index if not exists saml_sessions_session_id_idx on {{ index .Options "Namespace" }}.saml_sessions (session_id);
+create index if not exists saml_sessions_sso_provider_id_name_id_idx on {{ index .Options "Namespace" }}.saml_sessions (sso_provider_id, name_id);
+create index if not exists saml_sessions_flow_state_id_idx on {{ index .Options "Namespace" }}.saml_sessions (flow_state_id);
+
+comment on table {{ index .Options "Namespace" }}.saml_sessions is 'Auth: Links sessions to the SAML NameID and SessionIndex they were created with, for Single Logout.';
+
+-- logout requests sent with the HTTP-POST binding are rendered from the relay
+-- state, as they can't be put in a URL
+alter table {{ index .Options "Namespace" }}.saml_relay_states add column if not exists logout_request text null;
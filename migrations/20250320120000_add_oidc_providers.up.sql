@@ -0,0 +1,19 @@
+-- adds a table for SSO providers that use OpenID Connect instead of SAML 2.0
+
+create table if not exists {{ index .Options "Namespace" }}.oidc_providers (
+  id uuid not null primary key,
+  sso_provider_id uuid not null,
+  issuer text not null,
+  client_id text not null,
+  client_secret text not null,
+  scopes text null,
+  attribute_mapping jsonb null,
+  created_at timestamptz null,
+  updated_at timestamptz null,
+  unique (sso_provider_id),
+  foreign key (sso_provider_id) references {{ index .Options "Namespace" }}.sso_providers (id) on delete cascade,
+  constraint "issuer not empty" check (char_length(issuer) > 0),
+  constraint "client_id not empty" check (char_length(client_id) > 0)
+);
+
+comment on table {{ index .Options "Namespace" }}.oidc_providers is 'Auth: Manages OpenID Connect Identity Provider connections.';
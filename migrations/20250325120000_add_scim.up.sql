@@ -0,0 +1,33 @@
+-- adds SCIM 2.0 provisioning of users and groups for SSO providers
+
+alter table {{ index .Options "Namespace" }}.sso_providers add column if not exists scim_token_hash text null;
+create unique index if not exists sso_providers_scim_token_hash_idx on {{ index .Options "Namespace" }}.sso_providers (scim_token_hash);
+
+create table if not exists {{ index .Options "Namespace" }}.scim_groups (
+  id uuid not null primary key,
+  sso_provider_id uuid not null,
+  external_id text null,
+  display_name text not null,
+  created_at timestamptz null,
+  updated_at timestamptz null,
+  unique (sso_provider_id, display_name),
+  foreign key (sso_provider_id) references {{ index .Options "Namespace" }}.sso_providers (id) on delete cascade,
+  constraint "display_name not empty" check (char_length(display_name) > 0)
+);
+
+create index if not exists scim_groups_sso_provider_id_external_id_idx on {{ index .Options "Namespace" }}.scim_groups (sso_provider_id, external_id);
+
+comment on table {{ index .Options "Namespace" }}.scim_groups is 'Auth: Manages groups provisioned over SCIM by SSO providers.';
+
+create table if not exists {{ index .Options "Namespace" }}.scim_group_members (
+  group_id uuid not null,
+  user_id uuid not null,
+  created_at timestamptz null,
+  primary key (group_id, user_id),
+  foreign key (group_id) references {{ index .Options "Namespace" }}.scim_groups (id) on delete cascade,
+  foreign key (user_id) references {{ index .Options "Namespace" }}.users (id) on delete cascade
+);
+
+create index if not exists scim_group_members_user_id_idx on {{ index .Options "Namespace" }}.scim_group_members (user_id);
+
+comment on table {{ index .Options "Namespace" }}.scim_group_members is 'Auth: Manages the members of groups provisioned over SCIM.';
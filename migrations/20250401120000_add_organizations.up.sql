@@ -0,0 +1,38 @@
+-- adds organizations with memberships, which sessions, SSO providers and
+-- invitations can be scoped to
+
+create table if not exists {{ index .Options "Namespace" }}.organizations (
+  id uuid not null primary key,
+  name text not null,
+  slug text not null unique,
+  metadata jsonb null,
+  created_at timestamptz null,
+  updated_at timestamptz null,
+  constraint "name not empty" check (char_length(name) > 0),
+  constraint "slug not empty" check (char_length(slug) > 0)
+);
+
+comment on table {{ index .Options "Namespace" }}.organizations is 'Auth: Manages organizations users can be members of.';
+
+create table if not exists {{ index .Options "Namespace" }}.organization_members (
+  id uuid not null primary key,
+  organization_id uuid not null,
+  user_id uuid not null,
+  role text not null,
+  created_at timestamptz null,
+  updated_at timestamptz null,
+  unique (organization_id, user_id),
+  foreign key (organization_id) references {{ index .Options "Namespace" }}.organizations (id) on delete cascade,
+  foreign key (user_id) references {{ index .Options "Namespace" }}.users (id) on delete cascade,
+  constraint "role not empty" check (char_length(role) > 0)
+);
+
+create index if not exists organization_members_user_id_idx on {{ index .Options "Namespace" }}.organization_members (user_id);
+
+comment on table {{ index .Options "Namespace" }}.organization_members is 'Auth: Manages the members of organizations and their roles.';
+
+alter table {{ index .Options "Namespace" }}.sessions add column if not exists organization_id uuid null references {{ index .Options "Namespace" }}.organizations (id) on delete set null;
+comment on column {{ index .Options "Namespace" }}.sessions.organization_id is 'Auth: The organization selected for the session, whose claims are added to access tokens.';
+
+alter table {{ index .Options "Namespace" }}.sso_providers add column if not exists organization_id uuid null references {{ index .Options "Namespace" }}.organizations (id) on delete set null;
+comment on column {{ index .Options "Namespace" }}.sso_providers.organization_id is 'Auth: Users signing in with the SSO provider become members of this organization.';
@@ -0,0 +1,18 @@
+-- adds opaque admin API keys with permissions, as an alternative to admin JWTs
+
+create table if not exists {{ index .Options "Namespace" }}.admin_api_keys (
+  id uuid not null primary key,
+  name text not null,
+  key_hash text not null unique,
+  key_hint text not null,
+  permissions jsonb not null,
+  expires_at timestamptz null,
+  last_used_at timestamptz null,
+  revoked_at timestamptz null,
+  created_at timestamptz null,
+  updated_at timestamptz null,
+  constraint "name not empty" check (char_length(name) > 0)
+);
+
+comment on table {{ index .Options "Namespace" }}.admin_api_keys is 'Auth: Manages admin API keys. Only hashes of the keys are stored.';
+comment on column {{ index .Options "Namespace" }}.admin_api_keys.key_hint is 'Auth: The start of the key, to tell keys apart.';
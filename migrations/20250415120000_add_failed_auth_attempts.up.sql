@@ -0,0 +1,21 @@
+-- adds a table for failed password and MFA verification attempts, used to
+-- lock accounts against guessing. Attempts on accounts that don't exist are
+-- recorded by a hash of the identifier, so that they are locked like
+-- existing accounts
+
+create table if not exists {{ index .Options "Namespace" }}.failed_auth_attempts (
+  id uuid not null primary key,
+  user_id uuid null references {{ index .Options "Namespace" }}.users(id) on delete cascade,
+  identifier text null,
+  kind text not null,
+  ip_address text not null default '',
+  created_at timestamptz not null default now(),
+  constraint "kind is password or mfa" check (kind in ('password', 'mfa')),
+  constraint "user_id or identifier is set" check (user_id is not null or identifier is not null)
+);
+
+create index if not exists failed_auth_attempts_user_id_kind_created_at_idx on {{ index .Options "Namespace" }}.failed_auth_attempts (user_id, kind, created_at desc);
+create index if not exists failed_auth_attempts_identifier_kind_created_at_idx on {{ index .Options "Namespace" }}.failed_auth_attempts (identifier, kind, created_at desc) where identifier is not null;
+create index if not exists failed_auth_attempts_created_at_idx on {{ index .Options "Namespace" }}.failed_auth_attempts (created_at);
+
+comment on table {{ index .Options "Namespace" }}.failed_auth_attempts is 'Auth: Stores recent failed password and MFA verification attempts of users for account lockout.';
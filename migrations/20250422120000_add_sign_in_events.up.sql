@@ -0,0 +1,20 @@
+-- adds a table for recent sign ins of users and their context, used by the
+-- risk engine to recognize new devices, new countries and impossible travel
+
+create table if not exists {{ index .Options "Namespace" }}.sign_in_events (
+  id uuid not null primary key,
+  user_id uuid not null references {{ index .Options "Namespace" }}.users(id) on delete cascade,
+  ip_address text not null default '',
+  user_agent text not null default '',
+  country text not null default '',
+  latitude double precision null,
+  longitude double precision null,
+  created_at timestamptz not null default now()
+);
+
+create index if not exists sign_in_events_user_id_created_at_idx on {{ index .Options "Namespace" }}.sign_in_events (user_id, created_at desc);
+
+comment on table {{ index .Options "Namespace" }}.sign_in_events is 'Auth: Stores recent sign ins of users with their device and location.';
+
+alter table {{ index .Options "Namespace" }}.sessions add column if not exists requires_aal2 boolean not null default false;
+comment on column {{ index .Options "Namespace" }}.sessions.requires_aal2 is 'Auth: Set when the risk engine requires the session to be stepped up to AAL2 before it can be refreshed.';
@@ -0,0 +1,34 @@
+-- adds tables for bulk user imports, which are processed in the background
+-- and can be resumed from the rows that weren't processed yet
+
+create table if not exists {{ index .Options "Namespace" }}.user_import_jobs (
+  id uuid not null primary key,
+  aud text not null default '',
+  format text not null,
+  status text not null,
+  total_rows integer not null default 0,
+  imported_rows integer not null default 0,
+  failed_rows integer not null default 0,
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now(),
+  completed_at timestamptz null
+);
+
+create index if not exists user_import_jobs_status_created_at_idx on {{ index .Options "Namespace" }}.user_import_jobs (status, created_at);
+
+comment on table {{ index .Options "Namespace" }}.user_import_jobs is 'Auth: Stores bulk user imports and their progress.';
+
+create table if not exists {{ index .Options "Namespace" }}.user_import_rows (
+  id uuid not null primary key,
+  job_id uuid not null references {{ index .Options "Namespace" }}.user_import_jobs(id) on delete cascade,
+  line integer not null,
+  data jsonb not null default '{}',
+  status text not null,
+  error text null,
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now()
+);
+
+create index if not exists user_import_rows_job_id_status_line_idx on {{ index .Options "Namespace" }}.user_import_rows (job_id, status, line);
+
+comment on table {{ index .Options "Namespace" }}.user_import_rows is 'Auth: Stores the rows of bulk user imports that are pending or failed. Imported rows are deleted.';
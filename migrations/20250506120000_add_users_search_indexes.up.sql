@@ -0,0 +1,6 @@
+-- supports cursor pagination of users by creation in the admin API
+create index if not exists users_aud_created_at_id_idx on {{ index .Options "Namespace" }}.users (aud, created_at, id);
+
+-- supports prefix searches of users by email and phone
+create index if not exists users_email_pattern_idx on {{ index .Options "Namespace" }}.users (email text_pattern_ops);
+create index if not exists users_phone_pattern_idx on {{ index .Options "Namespace" }}.users (phone text_pattern_ops);
@@ -0,0 +1,12 @@
+-- adds audience_configs, which stores configuration overlays of audiences
+-- that take precedence over the overlays in GOTRUE_AUDIENCES_FILE
+
+create table if not exists {{ index .Options "Namespace" }}.audience_configs (
+  id uuid not null primary key,
+  aud text not null unique,
+  config jsonb not null default '{}',
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now()
+);
+
+comment on table {{ index .Options "Namespace" }}.audience_configs is 'Auth: Stores configuration overlays of audiences.';
@@ -0,0 +1,12 @@
+-- adds deletion_scheduled_at to users, which is when users that deleted
+-- their own account are deleted unless they sign in before then. A user
+-- whose deletion failed is retried at deletion_retry_at, with a backoff
+-- growing with deletion_attempts, instead of blocking the deletion of the
+-- users after it
+
+alter table {{ index .Options "Namespace" }}.users
+  add column if not exists deletion_scheduled_at timestamptz null,
+  add column if not exists deletion_attempts integer not null default 0,
+  add column if not exists deletion_retry_at timestamptz null;
+
+create index if not exists users_deletion_scheduled_at_idx on {{ index .Options "Namespace" }}.users (deletion_scheduled_at) where deletion_scheduled_at is not null;
@@ -0,0 +1,13 @@
+-- records when the password of users was last set, for the maximum password
+-- age
+
+alter table {{ index .Options "Namespace" }}.users add column if not exists password_changed_at timestamptz null;
+
+update {{ index .Options "Namespace" }}.users u
+  set password_changed_at = h.created_at
+  from (
+    select user_id, max(created_at) as created_at
+    from {{ index .Options "Namespace" }}.password_history
+    group by user_id
+  ) h
+  where h.user_id = u.id and u.password_changed_at is null;
@@ -0,0 +1,6 @@
+-- indexes the audit log entries by the user performing the action and by
+-- the user it was performed on, to find all the entries of a user
+
+create index if not exists audit_logs_actor_id_idx on {{ index .Options "Namespace" }}.audit_log_entries ((payload->>'actor_id'));
+
+create index if not exists audit_logs_traits_user_id_idx on {{ index .Options "Namespace" }}.audit_log_entries ((payload->'traits'->>'user_id'));
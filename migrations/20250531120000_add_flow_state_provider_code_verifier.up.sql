@@ -0,0 +1,4 @@
+-- adds provider_code_verifier to flow_state, the PKCE code verifier of the
+-- authorization request sent to an OIDC SSO provider
+
+alter table {{ index .Options "Namespace" }}.flow_state add column if not exists provider_code_verifier text null;